@@ -0,0 +1,159 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaderElectionLeaseName = "prometheus-operator"
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+type leaderElectionConfig struct {
+	Enabled       bool
+	Namespace     string
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// runWithLeaderElection blocks until the operator holds the Lease object and
+// then calls run. Followers keep serving the web endpoints but don't
+// reconcile anything. When the leadership is lost, an error is returned
+// immediately so that the process exits and restarts as a follower instead
+// of risking two replicas reconciling the same objects.
+func runWithLeaderElection(
+	ctx context.Context,
+	logger log.Logger,
+	kclient kubernetes.Interface,
+	r prometheus.Registerer,
+	cfg leaderElectionConfig,
+	run func(context.Context) error,
+) error {
+	identity := cfg.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		identity = hostname
+	}
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = "default"
+		if b, err := ioutil.ReadFile(serviceAccountNamespace); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+
+	isLeader := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "prometheus_operator_leader",
+		Help: "Whether this instance of the operator is the leader (1) or a follower (0).",
+	})
+	r.MustRegister(isLeader)
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaderElectionLeaseName,
+			Namespace: namespace,
+		},
+		Client: kclient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	leCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		started = make(chan struct{})
+		done    = make(chan error, 1)
+	)
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            leaderElectionLeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				level.Info(logger).Log("msg", "acquired leadership", "identity", identity)
+				isLeader.Set(1)
+				close(started)
+
+				err := run(ctx)
+				done <- err
+				if err != nil {
+					cancel()
+				}
+			},
+			OnStoppedLeading: func() {
+				isLeader.Set(0)
+			},
+			OnNewLeader: func(current string) {
+				if current != identity {
+					level.Info(logger).Log("msg", "following the current leader", "leader", current, "namespace", namespace, "lease", leaderElectionLeaseName)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	level.Info(logger).Log("msg", "waiting for leadership", "identity", identity, "namespace", namespace, "lease", leaderElectionLeaseName)
+	le.Run(leCtx)
+
+	select {
+	case <-started:
+	default:
+		// The context was canceled before acquiring the lease.
+		return nil
+	}
+
+	if ctx.Err() != nil {
+		// Graceful shutdown, wait for the controllers to stop.
+		return <-done
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	default:
+	}
+
+	return errors.New("leadership lost")
+}
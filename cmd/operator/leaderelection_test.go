@@ -0,0 +1,70 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testLeaderElectionConfig = leaderElectionConfig{
+	Enabled:       true,
+	Namespace:     "monitoring",
+	Identity:      "replica-0",
+	LeaseDuration: 2 * time.Second,
+	RenewDeadline: time.Second,
+	RetryPeriod:   100 * time.Millisecond,
+}
+
+func TestRunWithLeaderElection(t *testing.T) {
+	kclient := fake.NewSimpleClientset()
+	runErr := errors.New("controller failed")
+
+	err := runWithLeaderElection(context.Background(), log.NewNopLogger(), kclient, prometheus.NewRegistry(), testLeaderElectionConfig, func(ctx context.Context) error {
+		lease, err := kclient.CoordinationV1().Leases("monitoring").Get(ctx, leaderElectionLeaseName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if *lease.Spec.HolderIdentity != "replica-0" {
+			return errors.New("unexpected lease holder " + *lease.Spec.HolderIdentity)
+		}
+		return runErr
+	})
+
+	if err != runErr {
+		t.Fatalf("expected error %v, got %v", runErr, err)
+	}
+}
+
+func TestRunWithLeaderElectionShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := runWithLeaderElection(ctx, log.NewNopLogger(), fake.NewSimpleClientset(), prometheus.NewRegistry(), testLeaderElectionConfig, func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error on shutdown, got %v", err)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/prometheus-operator/prometheus-operator/pkg/admission"
 	alertmanagercontroller "github.com/prometheus-operator/prometheus-operator/pkg/alertmanager"
 	"github.com/prometheus-operator/prometheus-operator/pkg/api"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
 	prometheuscontroller "github.com/prometheus-operator/prometheus-operator/pkg/prometheus"
@@ -45,6 +46,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/sync/errgroup"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 )
 
@@ -138,6 +140,11 @@ var (
 	rawTLSCipherSuites string
 	serverTLS          bool
 
+	leCfg = leaderElectionConfig{}
+
+	// rateLimiter holds the work queue settings shared by the controllers.
+	rateLimiter operator.RateLimiterConfig
+
 	flagset = flag.CommandLine
 )
 
@@ -175,10 +182,12 @@ func init() {
 	flagset.StringVar(&cfg.ThanosDefaultBaseImage, "thanos-default-base-image", operator.DefaultThanosBaseImage, "Thanos default base image (path without tag/version)")
 	flagset.Var(ns, "namespaces", "Namespaces to scope the interaction of the Prometheus Operator and the apiserver (allow list). This is mutually exclusive with --deny-namespaces.")
 	flagset.Var(deniedNs, "deny-namespaces", "Namespaces not to scope the interaction of the Prometheus Operator (deny list). This is mutually exclusive with --namespaces.")
+	flagset.StringVar(&cfg.Namespaces.Selector, "namespace-selector", "", "Label selector of the Namespace objects to scope the interaction of the Prometheus Operator and the apiserver. The namespaces are updated without restart when they are added, removed or relabeled. This is mutually exclusive with --namespaces and --deny-namespaces.")
 	flagset.Var(prometheusNs, "prometheus-instance-namespaces", "Namespaces where Prometheus custom resources and corresponding Secrets, Configmaps and StatefulSets are watched/created. If set this takes precedence over --namespaces or --deny-namespaces for Prometheus custom resources.")
 	flagset.Var(alertmanagerNs, "alertmanager-instance-namespaces", "Namespaces where Alertmanager custom resources and corresponding StatefulSets are watched/created. If set this takes precedence over --namespaces or --deny-namespaces for Alertmanager custom resources.")
 	flagset.Var(thanosRulerNs, "thanos-ruler-instance-namespaces", "Namespaces where ThanosRuler custom resources and corresponding StatefulSets are watched/created. If set this takes precedence over --namespaces or --deny-namespaces for ThanosRuler custom resources.")
 	flagset.Var(&cfg.Labels, "labels", "Labels to be add to all resources created by the operator")
+	flagset.Var(&cfg.DefaultExternalLabels, "default-external-labels", "External labels added to the configuration of all Prometheus instances, e.g. 'cluster=prod-eu1,environment=production'. Labels defined in the externalLabels field of a Prometheus resource take precedence.")
 	flagset.StringVar(&cfg.LocalHost, "localhost", "localhost", "EXPERIMENTAL (could be removed in future releases) - Host used to communicate between local services on a pod. Fixes issues where localhost resolves incorrectly.")
 	flagset.StringVar(&cfg.ClusterDomain, "cluster-domain", "", "The domain of the cluster. This is used to generate service FQDNs. If this is not specified, DNS search domain expansion is used instead.")
 	flagset.StringVar(&cfg.LogLevel, "log-level", logLevelInfo, fmt.Sprintf("Log level to use. Possible values: %s", strings.Join(availableLogLevels, ", ")))
@@ -187,11 +196,44 @@ func init() {
 	flagset.StringVar(&cfg.AlertManagerSelector, "alertmanager-instance-selector", "", "Label selector to filter AlertManager Custom Resources to watch.")
 	flagset.StringVar(&cfg.ThanosRulerSelector, "thanos-ruler-instance-selector", "", "Label selector to filter ThanosRuler Custom Resources to watch.")
 	flagset.StringVar(&cfg.SecretListWatchSelector, "secret-field-selector", "", "Field selector to filter Secrets to watch")
+	flagset.DurationVar(&cfg.TargetsCheckInterval, "targets-check-interval", 0, "Interval at which the operator checks the targets of the Prometheus instances and reports unhealthy or missing targets as Events on the ServiceMonitors, PodMonitors and NodeMonitors. Zero disables the checks.")
+	flagset.DurationVar(&cfg.AutoResourcesInterval, "auto-resources-interval", 0, "Interval at which the operator scrapes the TSDB head series of the Prometheus instances defining autoResources to adjust their memory requests. Zero disables the recommendations.")
+	flagset.StringVar(&cfg.ServiceDiscoveryRole, "service-discovery-role", string(monitoringv1.EndpointsRole), fmt.Sprintf("Kubernetes service discovery role used by the ServiceMonitors which don't define one. Possible values: %s, %s. The operator falls back to %s when the cluster doesn't serve the EndpointSlice API.", monitoringv1.EndpointsRole, monitoringv1.EndpointSliceRole, monitoringv1.EndpointsRole))
+	flagset.DurationVar(&cfg.GCInterval, "gc-interval", 0, "Interval at which the operator deletes the generated Secrets and ConfigMaps whose Prometheus, PrometheusAgent, Alertmanager or ThanosRuler resource doesn't exist anymore. Zero, the default, disables the garbage collection.")
+	flagset.BoolVar(&cfg.EnableConfigDiff, "enable-config-diff", false, "Log the diff of the generated Prometheus configurations when they change and record a summary as an Event on the Prometheus objects. The values read from Secrets are redacted.")
+	flagset.StringVar(&cfg.Mode, "mode", operator.ModeFull, fmt.Sprintf("Operating mode of the operator. Possible values: %s, %s. In %s mode, the operator generates the configuration Secrets and ConfigMaps of the Prometheus, Alertmanager and ThanosRuler resources but creates no Service, ServiceAccount or StatefulSet, the workloads being managed externally.", operator.ModeFull, operator.ModeConfigOnly, operator.ModeConfigOnly))
+	flagset.BoolVar(&leCfg.Enabled, "leader-elect", false, "Enable leader election so that only one replica of the operator reconciles the resources at a time. Followers serve the web endpoints but don't reconcile anything. The leader exits when it loses the leadership.")
+	flagset.StringVar(&leCfg.Namespace, "leader-election-namespace", "", "Namespace of the Lease object used for leader election. Defaults to the namespace of the operator's service account.")
+	flagset.StringVar(&leCfg.Identity, "leader-election-id", "", "Identity of the operator replica in the leader election. Defaults to the hostname.")
+	flagset.DurationVar(&leCfg.LeaseDuration, "leader-election-lease-duration", 15*time.Second, "Duration that followers wait before trying to acquire a lease which hasn't been renewed.")
+	flagset.DurationVar(&leCfg.RenewDeadline, "leader-election-renew-deadline", 10*time.Second, "Duration that the leader retries renewing its lease before giving up the leadership.")
+	flagset.DurationVar(&leCfg.RetryPeriod, "leader-election-retry-period", 2*time.Second, "Duration between leader election attempts.")
+
+	defaults := operator.DefaultControllerConfig()
+	for _, c := range []struct {
+		name string
+		cfg  *operator.ControllerConfig
+	}{
+		{"prometheus", &cfg.PrometheusController},
+		{"alertmanager", &cfg.AlertmanagerController},
+		{"thanos-ruler", &cfg.ThanosRulerController},
+	} {
+		flagset.DurationVar(&c.cfg.ResyncPeriod, c.name+"-resync-period", defaults.ResyncPeriod, fmt.Sprintf("Period at which the %s controller resyncs all its objects. Zero disables the resyncs.", c.name))
+		flagset.IntVar(&c.cfg.Workers, c.name+"-workers", defaults.Workers, fmt.Sprintf("Number of objects synced concurrently by the %s controller.", c.name))
+	}
+	flagset.DurationVar(&rateLimiter.BaseDelay, "workqueue-base-delay", defaults.RateLimiter.BaseDelay, "Delay before requeuing an object after its first failed sync, doubled after every further failure.")
+	flagset.DurationVar(&rateLimiter.MaxDelay, "workqueue-max-delay", defaults.RateLimiter.MaxDelay, "Maximum delay before requeuing an object whose syncs keep failing.")
+	flagset.Float64Var(&rateLimiter.QPS, "workqueue-qps", defaults.RateLimiter.QPS, "Overall rate at which the objects are requeued by each controller.")
+	flagset.IntVar(&rateLimiter.Burst, "workqueue-burst", defaults.RateLimiter.Burst, "Number of objects requeued without delay above --workqueue-qps by each controller.")
 }
 
 func Main() int {
 	flagset.Parse(os.Args[1:])
 
+	cfg.PrometheusController.RateLimiter = rateLimiter
+	cfg.AlertmanagerController.RateLimiter = rateLimiter
+	cfg.ThanosRulerController.RateLimiter = rateLimiter
+
 	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stdout))
 	if cfg.LogFormat == logFormatJson {
 		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stdout))
@@ -223,6 +265,25 @@ func Main() int {
 		return 1
 	}
 
+	if cfg.Namespaces.Selector != "" && (len(ns) > 0 || len(deniedNs) > 0) {
+		fmt.Fprint(os.Stderr, "--namespace-selector is mutually exclusive with --namespaces and --deny-namespaces. Please provide only one of them.\n")
+		return 1
+	}
+
+	switch monitoringv1.ServiceDiscoveryRole(cfg.ServiceDiscoveryRole) {
+	case monitoringv1.EndpointsRole, monitoringv1.EndpointSliceRole:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --service-discovery-role %q\n", cfg.ServiceDiscoveryRole)
+		return 1
+	}
+
+	switch cfg.Mode {
+	case operator.ModeFull, operator.ModeConfigOnly:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --mode %q\n", cfg.Mode)
+		return 1
+	}
+
 	cfg.Namespaces.AllowList = ns
 	if len(cfg.Namespaces.AllowList) == 0 {
 		cfg.Namespaces.AllowList[v1.NamespaceAll] = struct{}{}
@@ -250,6 +311,7 @@ func Main() int {
 	r := prometheus.NewRegistry()
 
 	k8sutil.MustRegisterClientGoMetrics(r)
+	k8sutil.MustRegisterWorkqueueMetrics(r)
 
 	po, err := prometheuscontroller.New(ctx, cfg, log.With(logger, "component", "prometheusoperator"), r)
 	if err != nil {
@@ -272,6 +334,23 @@ func Main() int {
 		return 1
 	}
 
+	var kclient kubernetes.Interface
+	if leCfg.Enabled {
+		restCfg, err := k8sutil.NewClusterConfig(cfg.Host, cfg.TLSInsecure, &cfg.TLSConfig)
+		if err != nil {
+			fmt.Fprint(os.Stderr, "instantiating cluster config failed: ", err)
+			cancel()
+			return 1
+		}
+
+		kclient, err = kubernetes.NewForConfig(restCfg)
+		if err != nil {
+			fmt.Fprint(os.Stderr, "instantiating kubernetes client failed: ", err)
+			cancel()
+			return 1
+		}
+	}
+
 	mux := http.NewServeMux()
 	web, err := api.New(cfg, log.With(logger, "component", "api"))
 	if err != nil {
@@ -333,9 +412,21 @@ func Main() int {
 	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
 	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
 
-	wg.Go(func() error { return po.Run(ctx) })
-	wg.Go(func() error { return ao.Run(ctx) })
-	wg.Go(func() error { return to.Run(ctx) })
+	runControllers := func(ctx context.Context) error {
+		wg, ctx := errgroup.WithContext(ctx)
+		wg.Go(func() error { return po.Run(ctx) })
+		wg.Go(func() error { return ao.Run(ctx) })
+		wg.Go(func() error { return to.Run(ctx) })
+		return wg.Wait()
+	}
+
+	if leCfg.Enabled {
+		wg.Go(func() error {
+			return runWithLeaderElection(ctx, log.With(logger, "component", "leaderelection"), kclient, r, leCfg, runControllers)
+		})
+	} else {
+		wg.Go(func() error { return runControllers(ctx) })
+	}
 
 	if tlsConfig != nil {
 		r, err := rbacproxytls.NewCertReloader(
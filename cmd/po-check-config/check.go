@@ -0,0 +1,122 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/configgen"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// check writes the configuration generated for the Prometheus resources of
+// the manifests, or only for the one with the given name if not empty. It
+// returns warnings for the objects which don't end up in any configuration.
+func check(ctx context.Context, logger log.Logger, w io.Writer, m *manifests, name string, externalLabels map[string]string) ([]string, error) {
+	var prometheuses []*monitoringv1.Prometheus
+	for _, p := range m.Prometheuses {
+		if name == "" || name == p.Name || name == key("", p.ObjectMeta) {
+			prometheuses = append(prometheuses, p)
+		}
+	}
+
+	if len(prometheuses) == 0 {
+		if name != "" {
+			return nil, errors.Errorf("Prometheus %q not found", name)
+		}
+		return nil, errors.New("no Prometheus resource found")
+	}
+
+	var warnings []string
+	selected := map[string]struct{}{}
+	for i, p := range prometheuses {
+		in := m.Input
+		in.Prometheus = p
+		in.DefaultExternalLabels = externalLabels
+
+		if len(in.Namespaces) == 0 && hasNamespaceSelector(p) {
+			warnings = append(warnings, fmt.Sprintf("Prometheus %s: the namespace selectors match only the Namespace objects of the manifests and none were found", key("", p.ObjectMeta)))
+		}
+
+		sel, err := configgen.Select(in)
+		if err != nil {
+			return warnings, errors.Wrapf(err, "Prometheus %s", key("", p.ObjectMeta))
+		}
+		for _, sm := range sel.ServiceMonitors {
+			selected[key(monitoringv1.ServiceMonitorsKind, sm.ObjectMeta)] = struct{}{}
+		}
+		for _, pm := range sel.PodMonitors {
+			selected[key(monitoringv1.PodMonitorsKind, pm.ObjectMeta)] = struct{}{}
+		}
+		for _, probe := range sel.Probes {
+			selected[key(monitoringv1.ProbesKind, probe.ObjectMeta)] = struct{}{}
+		}
+		for _, nm := range sel.NodeMonitors {
+			selected[key(monitoringv1.NodeMonitorsKind, nm.ObjectMeta)] = struct{}{}
+		}
+
+		cfg, err := configgen.Generate(ctx, logger, in)
+		if err != nil {
+			return warnings, errors.Wrapf(err, "Prometheus %s", key("", p.ObjectMeta))
+		}
+
+		if i > 0 {
+			fmt.Fprint(w, "---\n")
+		}
+		fmt.Fprintf(w, "# Prometheus %s\n%s", key("", p.ObjectMeta), cfg)
+	}
+
+	var unselected []string
+	for _, sm := range m.Input.ServiceMonitors {
+		unselected = append(unselected, key(monitoringv1.ServiceMonitorsKind, sm.ObjectMeta))
+	}
+	for _, pm := range m.Input.PodMonitors {
+		unselected = append(unselected, key(monitoringv1.PodMonitorsKind, pm.ObjectMeta))
+	}
+	for _, probe := range m.Input.Probes {
+		unselected = append(unselected, key(monitoringv1.ProbesKind, probe.ObjectMeta))
+	}
+	for _, nm := range m.Input.NodeMonitors {
+		unselected = append(unselected, key(monitoringv1.NodeMonitorsKind, nm.ObjectMeta))
+	}
+	for _, k := range unselected {
+		if _, found := selected[k]; !found {
+			warnings = append(warnings, fmt.Sprintf("%s isn't selected by any Prometheus resource", k))
+		}
+	}
+
+	return warnings, nil
+}
+
+func hasNamespaceSelector(p *monitoringv1.Prometheus) bool {
+	return p.Spec.ServiceMonitorNamespaceSelector != nil ||
+		p.Spec.PodMonitorNamespaceSelector != nil ||
+		p.Spec.ProbeNamespaceSelector != nil ||
+		p.Spec.NodeMonitorNamespaceSelector != nil
+}
+
+// key returns "<kind> <namespace>/<name>", or "<namespace>/<name>" when the
+// kind is empty.
+func key(kind string, m metav1.ObjectMeta) string {
+	if kind == "" {
+		return m.Namespace + "/" + m.Name
+	}
+	return kind + " " + m.Namespace + "/" + m.Name
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+const testManifests = `
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: k8s
+  namespace: monitoring
+spec:
+  serviceMonitorSelector:
+    matchLabels:
+      team: frontend
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+  namespace: monitoring
+  labels:
+    team: frontend
+spec:
+  endpoints:
+  - port: web
+    basicAuth:
+      username:
+        name: web-auth
+        key: user
+      password:
+        name: web-auth
+        key: password
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: web-auth
+    namespace: monitoring
+  stringData:
+    user: admin
+    password: secret
+- apiVersion: monitoring.coreos.com/v1
+  kind: ServiceMonitor
+  metadata:
+    name: backend
+    labels:
+      team: backend
+  spec:
+    endpoints:
+    - port: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+
+func TestCheck(t *testing.T) {
+	l := newLoader("monitoring")
+	if err := l.load(strings.NewReader(testManifests), "test.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.m.Input.Secrets) != 1 || string(l.m.Input.Secrets[0].Data["password"]) != "secret" {
+		t.Fatalf("expected the stringData of the Secret to be converted, got %v", l.m.Input.Secrets)
+	}
+
+	expectedWarnings := []string{`ignoring Deployment "web": kind not used by the configuration`}
+	if !reflect.DeepEqual(l.m.Warnings, expectedWarnings) {
+		t.Fatalf("expected warnings %v, got %v", expectedWarnings, l.m.Warnings)
+	}
+
+	var buf bytes.Buffer
+	warnings, err := check(context.Background(), log.NewNopLogger(), &buf, &l.m, "", map[string]string{"cluster": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedWarnings = []string{"ServiceMonitor monitoring/backend isn't selected by any Prometheus resource"}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Fatalf("expected warnings %v, got %v", expectedWarnings, warnings)
+	}
+
+	cfg := buf.String()
+	for _, s := range []string{
+		"# Prometheus monitoring/k8s\n",
+		"cluster: test\n",
+		"job_name: monitoring/web/0\n",
+		"password: secret\n",
+	} {
+		if !strings.Contains(cfg, s) {
+			t.Fatalf("expected %q in the configuration:\n%s", s, cfg)
+		}
+	}
+	if strings.Contains(cfg, "monitoring/backend") {
+		t.Fatalf("unexpected backend job in the configuration:\n%s", cfg)
+	}
+
+	if _, err := check(context.Background(), log.NewNopLogger(), &buf, &l.m, "other", nil); err == nil {
+		t.Fatal("expected an error for an unknown Prometheus resource")
+	}
+}
+
+func TestCheckInvalidMonitor(t *testing.T) {
+	l := newLoader("default")
+	err := l.load(strings.NewReader(`
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+spec:
+  unknownField: true
+`), "test.yaml")
+	if err == nil {
+		t.Fatal("expected an error for the unknown field")
+	}
+
+	l = newLoader("default")
+	if err := l.load(strings.NewReader(`
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: k8s
+spec:
+  serviceMonitorSelector: {}
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+spec:
+  endpoints:
+  - port: web
+    bearerTokenSecret:
+      name: missing
+      key: token
+`), "test.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := check(context.Background(), log.NewNopLogger(), &buf, &l.m, "k8s", nil); err == nil {
+		t.Fatal("expected an error for the missing Secret")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/configgen"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// manifests holds the objects read from the manifest files.
+type manifests struct {
+	// Input holds the monitoring objects, Namespaces, Secrets and
+	// ConfigMaps. Its Prometheus field is left empty.
+	Input        configgen.Input
+	Prometheuses []*monitoringv1.Prometheus
+	Warnings     []string
+}
+
+type loader struct {
+	namespace string
+	m         manifests
+}
+
+// newLoader returns a loader which sets the given namespace to the objects
+// without one, like kubectl does.
+func newLoader(namespace string) *loader {
+	return &loader{namespace: namespace}
+}
+
+// loadPath reads the manifests from the given file or, for a directory, from
+// the YAML and JSON files it contains.
+func (l *loader) loadPath(p string) error {
+	return filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		// Files given explicitly are always read.
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			if path != p {
+				return nil
+			}
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return l.load(f, path)
+	})
+}
+
+// load decodes the objects of a multi-document YAML or JSON stream.
+func (l *loader) load(r io.Reader, source string) error {
+	d := k8syaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw json.RawMessage
+		if err := d.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to decode %s", source)
+		}
+
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+
+		if err := l.add(raw); err != nil {
+			return errors.Wrapf(err, "invalid object in %s", source)
+		}
+	}
+}
+
+func (l *loader) add(raw []byte) error {
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return err
+	}
+
+	switch meta.Kind {
+	case "List":
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			if err := l.add(item); err != nil {
+				return err
+			}
+		}
+
+	case monitoringv1.PrometheusesKind:
+		p := &monitoringv1.Prometheus{}
+		if err := decodeStrict(raw, p); err != nil {
+			return errors.Wrap(err, "prometheus is invalid")
+		}
+		l.setNamespace(&p.ObjectMeta)
+		l.m.Prometheuses = append(l.m.Prometheuses, p)
+
+	case monitoringv1.ServiceMonitorsKind:
+		sm := &monitoringv1.ServiceMonitor{}
+		if err := decodeStrict(raw, sm); err != nil {
+			return errors.Wrap(err, "serviceMonitor is invalid")
+		}
+		l.setNamespace(&sm.ObjectMeta)
+		l.m.Input.ServiceMonitors = append(l.m.Input.ServiceMonitors, sm)
+
+	case monitoringv1.PodMonitorsKind:
+		pm := &monitoringv1.PodMonitor{}
+		if err := decodeStrict(raw, pm); err != nil {
+			return errors.Wrap(err, "podMonitor is invalid")
+		}
+		l.setNamespace(&pm.ObjectMeta)
+		l.m.Input.PodMonitors = append(l.m.Input.PodMonitors, pm)
+
+	case monitoringv1.ProbesKind:
+		probe := &monitoringv1.Probe{}
+		if err := decodeStrict(raw, probe); err != nil {
+			return errors.Wrap(err, "probe is invalid")
+		}
+		l.setNamespace(&probe.ObjectMeta)
+		l.m.Input.Probes = append(l.m.Input.Probes, probe)
+
+	case monitoringv1.NodeMonitorsKind:
+		nm := &monitoringv1.NodeMonitor{}
+		if err := decodeStrict(raw, nm); err != nil {
+			return errors.Wrap(err, "nodeMonitor is invalid")
+		}
+		l.setNamespace(&nm.ObjectMeta)
+		l.m.Input.NodeMonitors = append(l.m.Input.NodeMonitors, nm)
+
+	case "Namespace":
+		ns := &v1.Namespace{}
+		if err := json.Unmarshal(raw, ns); err != nil {
+			return err
+		}
+		l.m.Input.Namespaces = append(l.m.Input.Namespaces, ns)
+
+	case "Secret":
+		s := &v1.Secret{}
+		if err := json.Unmarshal(raw, s); err != nil {
+			return err
+		}
+		l.setNamespace(&s.ObjectMeta)
+		// The API server merges stringData into data on write.
+		if len(s.StringData) > 0 && s.Data == nil {
+			s.Data = map[string][]byte{}
+		}
+		for k, v := range s.StringData {
+			s.Data[k] = []byte(v)
+		}
+		s.StringData = nil
+		l.m.Input.Secrets = append(l.m.Input.Secrets, s)
+
+	case "ConfigMap":
+		cm := &v1.ConfigMap{}
+		if err := json.Unmarshal(raw, cm); err != nil {
+			return err
+		}
+		l.setNamespace(&cm.ObjectMeta)
+		l.m.Input.ConfigMaps = append(l.m.Input.ConfigMaps, cm)
+
+	default:
+		var om struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		_ = json.Unmarshal(raw, &om)
+		l.m.Warnings = append(l.m.Warnings, fmt.Sprintf("ignoring %s %q: kind not used by the configuration", meta.Kind, om.Metadata.Name))
+	}
+
+	return nil
+}
+
+func (l *loader) setNamespace(m *metav1.ObjectMeta) {
+	if m.Namespace == "" {
+		m.Namespace = l.namespace
+	}
+}
+
+// decodeStrict decodes the object and fails on unknown fields, like po-lint.
+func decodeStrict(raw []byte, obj interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(obj)
+}
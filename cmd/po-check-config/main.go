@@ -0,0 +1,85 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	var (
+		namespace      = flag.String("namespace", "default", "namespace of the objects which don't define one")
+		prometheus     = flag.String("prometheus", "", "name or namespace/name of the Prometheus resource to check, all the Prometheus resources are checked when empty")
+		externalLabels = flag.String("default-external-labels", "", "comma-separated list of key=value external labels, like the --default-external-labels flag of the operator")
+	)
+	flag.Usage = func() {
+		log.SetFlags(0)
+		log.Printf("Usage: %s [flags] <file or directory>...", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	lbls, err := parseLabels(*externalLabels)
+	if err != nil {
+		log.Fatalf("invalid external labels: %v", err)
+	}
+
+	l := newLoader(*namespace)
+	for _, p := range flag.Args() {
+		if err := l.loadPath(p); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	logger := kitlog.NewLogfmtLogger(kitlog.NewSyncWriter(os.Stderr))
+	warnings, err := check(context.Background(), logger, os.Stdout, &l.m, *prometheus, lbls)
+
+	for _, w := range append(l.m.Warnings, warnings...) {
+		log.Printf("warning: %s", w)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func parseLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	labels := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("expected key=value, got %q", kv)
+		}
+		labels[parts[0]] = parts[1]
+	}
+
+	return labels, nil
+}
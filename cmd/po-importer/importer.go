@@ -0,0 +1,462 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	monitoring "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sYAML "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// The types below only describe the subset of the Prometheus configuration
+// that can be expressed with the custom resources. Everything else ends up
+// in the inline maps which are used to decide that a scrape configuration
+// has to be kept as an additional scrape configuration.
+
+type promConfig struct {
+	Global        yaml.MapSlice          `yaml:"global,omitempty"`
+	RuleFiles     []string               `yaml:"rule_files,omitempty"`
+	ScrapeConfigs []yaml.MapSlice        `yaml:"scrape_configs,omitempty"`
+	Other         map[string]interface{} `yaml:",inline"`
+}
+
+type scrapeConfig struct {
+	JobName              string                 `yaml:"job_name"`
+	HonorLabels          bool                   `yaml:"honor_labels,omitempty"`
+	HonorTimestamps      *bool                  `yaml:"honor_timestamps,omitempty"`
+	Params               map[string][]string    `yaml:"params,omitempty"`
+	ScrapeInterval       string                 `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout        string                 `yaml:"scrape_timeout,omitempty"`
+	MetricsPath          string                 `yaml:"metrics_path,omitempty"`
+	Scheme               string                 `yaml:"scheme,omitempty"`
+	SampleLimit          uint64                 `yaml:"sample_limit,omitempty"`
+	TargetLimit          uint64                 `yaml:"target_limit,omitempty"`
+	LabelLimit           uint64                 `yaml:"label_limit,omitempty"`
+	TLSConfig            *tlsConfig             `yaml:"tls_config,omitempty"`
+	BearerTokenFile      string                 `yaml:"bearer_token_file,omitempty"`
+	ProxyURL             string                 `yaml:"proxy_url,omitempty"`
+	KubernetesSDConfigs  []kubernetesSDConfig   `yaml:"kubernetes_sd_configs,omitempty"`
+	StaticConfigs        []staticConfig         `yaml:"static_configs,omitempty"`
+	RelabelConfigs       []relabelConfig        `yaml:"relabel_configs,omitempty"`
+	MetricRelabelConfigs []relabelConfig        `yaml:"metric_relabel_configs,omitempty"`
+	Unsupported          map[string]interface{} `yaml:",inline"`
+}
+
+type tlsConfig struct {
+	CAFile             string                 `yaml:"ca_file,omitempty"`
+	CertFile           string                 `yaml:"cert_file,omitempty"`
+	KeyFile            string                 `yaml:"key_file,omitempty"`
+	ServerName         string                 `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool                   `yaml:"insecure_skip_verify,omitempty"`
+	Unsupported        map[string]interface{} `yaml:",inline"`
+}
+
+type kubernetesSDConfig struct {
+	Role       string `yaml:"role"`
+	Namespaces *struct {
+		Names       []string               `yaml:"names,omitempty"`
+		Unsupported map[string]interface{} `yaml:",inline"`
+	} `yaml:"namespaces,omitempty"`
+	Unsupported map[string]interface{} `yaml:",inline"`
+}
+
+type staticConfig struct {
+	Targets     []string               `yaml:"targets"`
+	Labels      map[string]string      `yaml:"labels,omitempty"`
+	Unsupported map[string]interface{} `yaml:",inline"`
+}
+
+type relabelConfig struct {
+	SourceLabels []string               `yaml:"source_labels,flow,omitempty"`
+	Separator    string                 `yaml:"separator,omitempty"`
+	TargetLabel  string                 `yaml:"target_label,omitempty"`
+	Regex        string                 `yaml:"regex,omitempty"`
+	Modulus      uint64                 `yaml:"modulus,omitempty"`
+	Replacement  string                 `yaml:"replacement,omitempty"`
+	Action       string                 `yaml:"action,omitempty"`
+	Unsupported  map[string]interface{} `yaml:",inline"`
+}
+
+// result holds the objects generated from a Prometheus configuration.
+type result struct {
+	ServiceMonitors []*monitoringv1.ServiceMonitor
+	Probes          []*monitoringv1.Probe
+	PrometheusRules []*monitoringv1.PrometheusRule
+
+	// AdditionalScrapeConfigs holds the scrape configurations which can't
+	// be converted, unmodified.
+	AdditionalScrapeConfigs []yaml.MapSlice
+
+	// Warnings explains what hasn't been converted and why.
+	Warnings []string
+}
+
+type importer struct {
+	namespace string
+	labels    map[string]string
+
+	// names records the object names already in use per kind.
+	names map[string]map[string]struct{}
+}
+
+func newImporter(namespace string, labels map[string]string) *importer {
+	return &importer{
+		namespace: namespace,
+		labels:    labels,
+		names:     map[string]map[string]struct{}{},
+	}
+}
+
+// importConfig converts the Prometheus configuration to custom resources.
+// The rule files are resolved relative to baseDir.
+func (i *importer) importConfig(content []byte, baseDir string) (*result, error) {
+	var cfg promConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, errors.Wrap(err, "unmarshal Prometheus configuration")
+	}
+
+	res := &result{}
+
+	if len(cfg.Global) > 0 {
+		res.Warnings = append(res.Warnings, "global: not converted, configure the equivalent fields of the Prometheus resource")
+	}
+	for _, k := range sortedKeys(cfg.Other) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%s: not converted, configure the equivalent fields of the Prometheus resource", k))
+	}
+
+	for _, raw := range cfg.ScrapeConfigs {
+		b, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal scrape configuration")
+		}
+
+		var sc scrapeConfig
+		if err := yaml.Unmarshal(b, &sc); err != nil {
+			return nil, errors.Wrap(err, "unmarshal scrape configuration")
+		}
+
+		if err := i.convertScrapeConfig(&sc, res); err != nil {
+			res.AdditionalScrapeConfigs = append(res.AdditionalScrapeConfigs, raw)
+			res.Warnings = append(res.Warnings, fmt.Sprintf("job %q: %v, kept as additional scrape configuration", sc.JobName, err))
+		}
+	}
+
+	for _, pattern := range cfg.RuleFiles {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule files pattern %q", pattern)
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			rule, err := i.convertRuleFile(f)
+			if err != nil {
+				return nil, err
+			}
+			res.PrometheusRules = append(res.PrometheusRules, rule)
+		}
+	}
+
+	return res, nil
+}
+
+func (i *importer) convertScrapeConfig(sc *scrapeConfig, res *result) error {
+	if err := checkUnsupported(sc.Unsupported); err != nil {
+		return err
+	}
+	if sc.TLSConfig != nil {
+		if err := checkUnsupported(sc.TLSConfig.Unsupported); err != nil {
+			return errors.Wrap(err, "tls_config")
+		}
+	}
+
+	switch {
+	case len(sc.KubernetesSDConfigs) > 0 && len(sc.StaticConfigs) == 0:
+		sm, err := i.toServiceMonitor(sc)
+		if err != nil {
+			return err
+		}
+		res.ServiceMonitors = append(res.ServiceMonitors, sm)
+	case len(sc.StaticConfigs) > 0 && len(sc.KubernetesSDConfigs) == 0 && len(sc.Params["module"]) > 0:
+		probe, err := i.toProbe(sc)
+		if err != nil {
+			return err
+		}
+		res.Probes = append(res.Probes, probe)
+	default:
+		return errors.New("only Kubernetes endpoints discovery and blackbox exporter jobs can be converted")
+	}
+
+	return nil
+}
+
+// toServiceMonitor converts a job discovering the Kubernetes endpoints. The
+// ServiceMonitor selects all the Services and keeps the relabeling rules of
+// the job to filter the targets.
+func (i *importer) toServiceMonitor(sc *scrapeConfig) (*monitoringv1.ServiceMonitor, error) {
+	if len(sc.KubernetesSDConfigs) != 1 {
+		return nil, errors.New("more than one Kubernetes discovery configuration")
+	}
+
+	sd := sc.KubernetesSDConfigs[0]
+	if sd.Role != "endpoints" {
+		return nil, errors.Errorf("unsupported Kubernetes discovery role %q", sd.Role)
+	}
+	if err := checkUnsupported(sd.Unsupported); err != nil {
+		return nil, errors.Wrap(err, "kubernetes_sd_configs")
+	}
+
+	nsSelector := monitoringv1.NamespaceSelector{Any: true}
+	if sd.Namespaces != nil {
+		if err := checkUnsupported(sd.Namespaces.Unsupported); err != nil {
+			return nil, errors.Wrap(err, "kubernetes_sd_configs.namespaces")
+		}
+		if len(sd.Namespaces.Names) > 0 {
+			nsSelector = monitoringv1.NamespaceSelector{MatchNames: sd.Namespaces.Names}
+		}
+	}
+
+	relabelings, err := convertRelabelConfigs(sc.RelabelConfigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "relabel_configs")
+	}
+	metricRelabelings, err := convertRelabelConfigs(sc.MetricRelabelConfigs)
+	if err != nil {
+		return nil, errors.Wrap(err, "metric_relabel_configs")
+	}
+
+	// The operator sets the job label to the name of the Service, restore
+	// the original value before applying the relabeling rules of the job.
+	relabelings = append([]*monitoringv1.RelabelConfig{
+		{TargetLabel: "job", Replacement: sc.JobName},
+	}, relabelings...)
+
+	ep := monitoringv1.Endpoint{
+		Path:                 sc.MetricsPath,
+		Scheme:               sc.Scheme,
+		Params:               sc.Params,
+		Interval:             sc.ScrapeInterval,
+		ScrapeTimeout:        sc.ScrapeTimeout,
+		BearerTokenFile:      sc.BearerTokenFile,
+		HonorLabels:          sc.HonorLabels,
+		HonorTimestamps:      sc.HonorTimestamps,
+		RelabelConfigs:       relabelings,
+		MetricRelabelConfigs: metricRelabelings,
+	}
+	if sc.ProxyURL != "" {
+		ep.ProxyURL = &sc.ProxyURL
+	}
+	if sc.TLSConfig != nil {
+		ep.TLSConfig = &monitoringv1.TLSConfig{
+			CAFile:             sc.TLSConfig.CAFile,
+			CertFile:           sc.TLSConfig.CertFile,
+			KeyFile:            sc.TLSConfig.KeyFile,
+			ServerName:         sc.TLSConfig.ServerName,
+			InsecureSkipVerify: sc.TLSConfig.InsecureSkipVerify,
+		}
+	}
+
+	return &monitoringv1.ServiceMonitor{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       monitoringv1.ServiceMonitorsKind,
+			APIVersion: monitoring.GroupName + "/" + monitoringv1.Version,
+		},
+		ObjectMeta: i.objectMeta(monitoringv1.ServiceMonitorsKind, sc.JobName),
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Endpoints:         []monitoringv1.Endpoint{ep},
+			NamespaceSelector: nsSelector,
+			SampleLimit:       sc.SampleLimit,
+		},
+	}, nil
+}
+
+// toProbe converts a job following the multi-target exporter pattern
+// documented by the blackbox exporter.
+func (i *importer) toProbe(sc *scrapeConfig) (*monitoringv1.Probe, error) {
+	switch {
+	case len(sc.Params) != 1 || len(sc.Params["module"]) != 1:
+		return nil, errors.New("only the module parameter is supported for probes")
+	case len(sc.StaticConfigs) != 1:
+		return nil, errors.New("more than one static configuration")
+	case sc.TLSConfig != nil || sc.BearerTokenFile != "" || sc.ProxyURL != "":
+		return nil, errors.New("authentication settings aren't supported for probes")
+	case sc.HonorLabels || sc.HonorTimestamps != nil:
+		return nil, errors.New("honor_labels and honor_timestamps aren't supported for probes")
+	case len(sc.MetricRelabelConfigs) > 0:
+		return nil, errors.New("metric_relabel_configs isn't supported for probes")
+	}
+
+	static := sc.StaticConfigs[0]
+	if err := checkUnsupported(static.Unsupported); err != nil {
+		return nil, errors.Wrap(err, "static_configs")
+	}
+
+	var proberURL string
+	for _, rc := range sc.RelabelConfigs {
+		if err := checkUnsupported(rc.Unsupported); err != nil {
+			return nil, errors.Wrap(err, "relabel_configs")
+		}
+		if (rc.Action != "" && rc.Action != "replace") || (rc.Regex != "" && rc.Regex != "(.*)") {
+			return nil, errors.New("relabel_configs don't match the blackbox exporter pattern")
+		}
+
+		switch {
+		case isRelabeling(rc, "__address__", "__param_target"),
+			isRelabeling(rc, "__param_target", "instance"):
+		case len(rc.SourceLabels) == 0 && rc.TargetLabel == "__address__" && rc.Replacement != "":
+			proberURL = rc.Replacement
+		default:
+			return nil, errors.New("relabel_configs don't match the blackbox exporter pattern")
+		}
+	}
+	if proberURL == "" {
+		return nil, errors.New("no relabeling rule pointing __address__ to the prober")
+	}
+
+	return &monitoringv1.Probe{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       monitoringv1.ProbesKind,
+			APIVersion: monitoring.GroupName + "/" + monitoringv1.Version,
+		},
+		ObjectMeta: i.objectMeta(monitoringv1.ProbesKind, sc.JobName),
+		Spec: monitoringv1.ProbeSpec{
+			JobName: sc.JobName,
+			ProberSpec: monitoringv1.ProberSpec{
+				URL:    proberURL,
+				Scheme: sc.Scheme,
+				Path:   sc.MetricsPath,
+			},
+			Module: sc.Params["module"][0],
+			Targets: monitoringv1.ProbeTargets{
+				StaticConfig: &monitoringv1.ProbeTargetStaticConfig{
+					Targets: static.Targets,
+					Labels:  static.Labels,
+				},
+			},
+			Interval:      sc.ScrapeInterval,
+			ScrapeTimeout: sc.ScrapeTimeout,
+			SampleLimit:   sc.SampleLimit,
+			TargetLimit:   sc.TargetLimit,
+			LabelLimit:    sc.LabelLimit,
+		},
+	}, nil
+}
+
+func (i *importer) convertRuleFile(filename string) (*monitoringv1.PrometheusRule, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read rule file %q", filename)
+	}
+
+	spec := monitoringv1.PrometheusRuleSpec{}
+	if err := k8sYAML.NewYAMLOrJSONDecoder(bytes.NewBuffer(content), 1000).Decode(&spec); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal rule file %q", filename)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	return &monitoringv1.PrometheusRule{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       monitoringv1.PrometheusRuleKind,
+			APIVersion: monitoring.GroupName + "/" + monitoringv1.Version,
+		},
+		ObjectMeta: i.objectMeta(monitoringv1.PrometheusRuleKind, name),
+		Spec:       spec,
+	}, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// objectMeta returns the metadata of a new object, the name is derived from
+// the given string and made unique among the objects of the same kind.
+func (i *importer) objectMeta(kind, s string) metav1.ObjectMeta {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if name == "" {
+		name = strings.ToLower(kind)
+	}
+
+	if i.names[kind] == nil {
+		i.names[kind] = map[string]struct{}{}
+	}
+	unique := name
+	for n := 1; ; n++ {
+		if _, found := i.names[kind][unique]; !found {
+			break
+		}
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+	i.names[kind][unique] = struct{}{}
+
+	return metav1.ObjectMeta{
+		Name:      unique,
+		Namespace: i.namespace,
+		Labels:    i.labels,
+	}
+}
+
+func convertRelabelConfigs(rcs []relabelConfig) ([]*monitoringv1.RelabelConfig, error) {
+	var res []*monitoringv1.RelabelConfig
+	for _, rc := range rcs {
+		if err := checkUnsupported(rc.Unsupported); err != nil {
+			return nil, err
+		}
+		res = append(res, &monitoringv1.RelabelConfig{
+			SourceLabels: rc.SourceLabels,
+			Separator:    rc.Separator,
+			TargetLabel:  rc.TargetLabel,
+			Regex:        rc.Regex,
+			Modulus:      rc.Modulus,
+			Replacement:  rc.Replacement,
+			Action:       rc.Action,
+		})
+	}
+	return res, nil
+}
+
+func isRelabeling(rc relabelConfig, source, target string) bool {
+	return len(rc.SourceLabels) == 1 && rc.SourceLabels[0] == source && rc.TargetLabel == target &&
+		(rc.Replacement == "" || rc.Replacement == "$1")
+}
+
+func checkUnsupported(fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return errors.Errorf("unsupported fields %s", strings.Join(sortedKeys(fields), ", "))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
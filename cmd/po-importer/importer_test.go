@@ -0,0 +1,209 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+const testConfig = `
+global:
+  scrape_interval: 30s
+alerting:
+  alertmanagers:
+  - static_configs:
+    - targets: ['alertmanager:9093']
+rule_files:
+- rules/*.yml
+scrape_configs:
+- job_name: kubernetes-service-endpoints
+  scrape_interval: 1m
+  kubernetes_sd_configs:
+  - role: endpoints
+    namespaces:
+      names: [monitoring]
+  relabel_configs:
+  - source_labels: [__meta_kubernetes_service_annotation_prometheus_io_scrape]
+    action: keep
+    regex: true
+  metric_relabel_configs:
+  - source_labels: [__name__]
+    regex: go_.*
+    action: drop
+- job_name: blackbox/http
+  metrics_path: /probe
+  params:
+    module: [http_2xx]
+  static_configs:
+  - targets: ['https://example.com']
+    labels:
+      team: frontend
+  relabel_configs:
+  - source_labels: [__address__]
+    target_label: __param_target
+  - source_labels: [__param_target]
+    target_label: instance
+  - target_label: __address__
+    replacement: blackbox-exporter:9115
+- job_name: node
+  static_configs:
+  - targets: ['node1:9100']
+- job_name: pods
+  kubernetes_sd_configs:
+  - role: pod
+`
+
+const testRules = `
+groups:
+- name: example
+  rules:
+  - alert: InstanceDown
+    expr: up == 0
+    for: 5m
+`
+
+func TestImportConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "po-importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "rules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "rules", "Example.yml"), []byte(testRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := newImporter("monitoring", map[string]string{"team": "sre"}).importConfig([]byte(testConfig), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.ServiceMonitors) != 1 {
+		t.Fatalf("expected 1 ServiceMonitor, got %d", len(res.ServiceMonitors))
+	}
+	sm := res.ServiceMonitors[0]
+	if sm.Name != "kubernetes-service-endpoints" || sm.Namespace != "monitoring" || sm.Labels["team"] != "sre" {
+		t.Fatalf("unexpected ServiceMonitor metadata: %v", sm.ObjectMeta)
+	}
+	if !reflect.DeepEqual(sm.Spec.NamespaceSelector, monitoringv1.NamespaceSelector{MatchNames: []string{"monitoring"}}) {
+		t.Fatalf("unexpected namespace selector: %v", sm.Spec.NamespaceSelector)
+	}
+	ep := sm.Spec.Endpoints[0]
+	if ep.Interval != "1m" {
+		t.Fatalf("expected interval 1m, got %q", ep.Interval)
+	}
+	expectedRelabelings := []*monitoringv1.RelabelConfig{
+		{TargetLabel: "job", Replacement: "kubernetes-service-endpoints"},
+		{SourceLabels: []string{"__meta_kubernetes_service_annotation_prometheus_io_scrape"}, Action: "keep", Regex: "true"},
+	}
+	if !reflect.DeepEqual(ep.RelabelConfigs, expectedRelabelings) {
+		t.Fatalf("unexpected relabelings: %v", ep.RelabelConfigs)
+	}
+	if len(ep.MetricRelabelConfigs) != 1 || ep.MetricRelabelConfigs[0].Action != "drop" {
+		t.Fatalf("unexpected metric relabelings: %v", ep.MetricRelabelConfigs)
+	}
+
+	if len(res.Probes) != 1 {
+		t.Fatalf("expected 1 Probe, got %d", len(res.Probes))
+	}
+	probe := res.Probes[0]
+	if probe.Name != "blackbox-http" || probe.Spec.JobName != "blackbox/http" || probe.Spec.Module != "http_2xx" {
+		t.Fatalf("unexpected Probe: %v", probe)
+	}
+	expectedProber := monitoringv1.ProberSpec{URL: "blackbox-exporter:9115", Path: "/probe"}
+	if probe.Spec.ProberSpec != expectedProber {
+		t.Fatalf("unexpected prober: %v", probe.Spec.ProberSpec)
+	}
+	expectedTargets := &monitoringv1.ProbeTargetStaticConfig{
+		Targets: []string{"https://example.com"},
+		Labels:  map[string]string{"team": "frontend"},
+	}
+	if !reflect.DeepEqual(probe.Spec.Targets.StaticConfig, expectedTargets) {
+		t.Fatalf("unexpected targets: %v", probe.Spec.Targets.StaticConfig)
+	}
+
+	if len(res.PrometheusRules) != 1 {
+		t.Fatalf("expected 1 PrometheusRule, got %d", len(res.PrometheusRules))
+	}
+	rule := res.PrometheusRules[0]
+	if rule.Name != "example" || len(rule.Spec.Groups) != 1 || rule.Spec.Groups[0].Rules[0].Alert != "InstanceDown" {
+		t.Fatalf("unexpected PrometheusRule: %v", rule)
+	}
+
+	if len(res.AdditionalScrapeConfigs) != 2 {
+		t.Fatalf("expected 2 additional scrape configs, got %d", len(res.AdditionalScrapeConfigs))
+	}
+	for i, job := range []string{"node", "pods"} {
+		if res.AdditionalScrapeConfigs[i][0].Value != job {
+			t.Fatalf("expected job %q to be kept as additional scrape config, got %v", job, res.AdditionalScrapeConfigs[i][0].Value)
+		}
+	}
+
+	var out strings.Builder
+	if err := writeResult(&out, res, "monitoring", nil, "additional-scrape-configs"); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "---\n"); n != 4 {
+		t.Fatalf("expected 4 documents, got %d:\n%s", n, out.String())
+	}
+	if !strings.Contains(out.String(), "prometheus-additional.yaml: |") {
+		t.Fatalf("expected the additional scrape configs Secret:\n%s", out.String())
+	}
+
+	// global and alerting + the 2 unconverted jobs.
+	if len(res.Warnings) != 4 {
+		t.Fatalf("expected 4 warnings, got %v", res.Warnings)
+	}
+}
+
+func TestToProbeRejectsCustomRelabelings(t *testing.T) {
+	sc := &scrapeConfig{
+		JobName:       "blackbox",
+		Params:        map[string][]string{"module": {"http_2xx"}},
+		StaticConfigs: []staticConfig{{Targets: []string{"https://example.com"}}},
+		RelabelConfigs: []relabelConfig{
+			{TargetLabel: "__address__", Replacement: "blackbox-exporter:9115"},
+			{SourceLabels: []string{"__param_target"}, TargetLabel: "host", Regex: "https://(.*)"},
+		},
+	}
+
+	if _, err := newImporter("default", nil).toProbe(sc); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestObjectMetaUniqueNames(t *testing.T) {
+	i := newImporter("default", nil)
+
+	for _, expected := range []string{"my-job", "my-job-1", "my-job-2"} {
+		if name := i.objectMeta(monitoringv1.ServiceMonitorsKind, "My_Job").Name; name != expected {
+			t.Fatalf("expected %q, got %q", expected, name)
+		}
+	}
+
+	if name := i.objectMeta(monitoringv1.ProbesKind, "My_Job").Name; name != "my-job" {
+		t.Fatalf("expected names to be unique per kind, got %q", name)
+	}
+}
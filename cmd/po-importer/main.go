@@ -0,0 +1,144 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const additionalScrapeConfigsKey = "prometheus-additional.yaml"
+
+func main() {
+	var (
+		configFile = flag.String("config-file", "", "path to the Prometheus configuration file")
+		namespace  = flag.String("namespace", "default", "namespace of the generated objects")
+		labels     = flag.String("labels", "", "comma-separated list of key=value labels added to the generated objects")
+		secretName = flag.String("additional-scrape-configs-secret", "additional-scrape-configs", "name of the Secret holding the scrape configurations which can't be converted")
+	)
+	flag.Parse()
+
+	if *configFile == "" {
+		log.Print("please specify 'config-file' flag")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	lbls, err := parseLabels(*labels)
+	if err != nil {
+		log.Fatalf("invalid labels: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		log.Fatalf("failed to read file '%v': %v", *configFile, err)
+	}
+
+	res, err := newImporter(*namespace, lbls).importConfig(content, filepath.Dir(*configFile))
+	if err != nil {
+		log.Fatalf("failed to import configuration: %v", err)
+	}
+
+	for _, w := range res.Warnings {
+		log.Print(w)
+	}
+
+	if err := writeResult(os.Stdout, res, *namespace, lbls, *secretName); err != nil {
+		log.Fatalf("failed to write manifests: %v", err)
+	}
+
+	if len(res.AdditionalScrapeConfigs) > 0 {
+		log.Printf("reference the Secret from the Prometheus resource with 'additionalScrapeConfigs: {name: %s, key: %s}'", *secretName, additionalScrapeConfigsKey)
+	}
+}
+
+// writeResult writes the generated objects as a multi-document YAML stream.
+// The scrape configurations which couldn't be converted are wrapped in a
+// Secret suitable for the additionalScrapeConfigs field.
+func writeResult(w io.Writer, res *result, namespace string, labels map[string]string, secretName string) error {
+	var objects []interface{}
+	for _, o := range res.ServiceMonitors {
+		objects = append(objects, o)
+	}
+	for _, o := range res.Probes {
+		objects = append(objects, o)
+	}
+	for _, o := range res.PrometheusRules {
+		objects = append(objects, o)
+	}
+
+	if len(res.AdditionalScrapeConfigs) > 0 {
+		b, err := yamlv2.Marshal(res.AdditionalScrapeConfigs)
+		if err != nil {
+			return errors.Wrap(err, "marshal additional scrape configurations")
+		}
+
+		objects = append(objects, &v1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Secret",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: namespace,
+				Labels:    labels,
+			},
+			StringData: map[string]string{
+				additionalScrapeConfigsKey: string(b),
+			},
+		})
+	}
+
+	for _, o := range objects {
+		b, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseLabels(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	labels := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("expected key=value, got %q", kv)
+		}
+		labels[parts[0]] = parts[1]
+	}
+
+	return labels, nil
+}
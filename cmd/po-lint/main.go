@@ -113,6 +113,34 @@ func main() {
 			if err != nil {
 				log.Fatalf("podMonitor is invalid: %v", err)
 			}
+		case v1.PrometheusAgentsKind:
+			j, err := yaml.YAMLToJSON(content)
+			if err != nil {
+				log.Fatalf("unable to convert YALM to JSON: %v", err)
+			}
+
+			decoder := json.NewDecoder(bytes.NewBuffer(j))
+			decoder.DisallowUnknownFields()
+
+			var prometheusAgent v1.PrometheusAgent
+			err = decoder.Decode(&prometheusAgent)
+			if err != nil {
+				log.Fatalf("prometheusAgent is invalid: %v", err)
+			}
+		case v1.NodeMonitorsKind:
+			j, err := yaml.YAMLToJSON(content)
+			if err != nil {
+				log.Fatalf("unable to convert YALM to JSON: %v", err)
+			}
+
+			decoder := json.NewDecoder(bytes.NewBuffer(j))
+			decoder.DisallowUnknownFields()
+
+			var nodeMonitor v1.NodeMonitor
+			err = decoder.Decode(&nodeMonitor)
+			if err != nil {
+				log.Fatalf("nodeMonitor is invalid: %v", err)
+			}
 		case v1.ProbesKind:
 			j, err := yaml.YAMLToJSON(content)
 			if err != nil {
@@ -141,7 +169,7 @@ func main() {
 				log.Fatalf("thanosRuler is invalid: %v", err)
 			}
 		default:
-			log.Fatal("MetaType is unknown to linter. Not in Alertmanager, Prometheus, PrometheusRule, ServiceMonitor, PodMonitor, Probe, ThanosRuler")
+			log.Fatal("MetaType is unknown to linter. Not in Alertmanager, Prometheus, PrometheusAgent, PrometheusRule, ServiceMonitor, PodMonitor, NodeMonitor, Probe, ThanosRuler")
 		}
 	}
 }
@@ -0,0 +1,155 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// urlFetcher periodically downloads remote files into a local directory.
+// Files are only rewritten when their content changes so that the reloader
+// watching the directory triggers a reload only when needed.
+type urlFetcher struct {
+	logger   log.Logger
+	client   *http.Client
+	dir      string
+	interval time.Duration
+	// files maps the output file names to the URLs they are fetched from.
+	files map[string]*url.URL
+}
+
+func newURLFetcher(logger log.Logger, dir string, interval time.Duration, urls []*url.URL) (*urlFetcher, error) {
+	if dir == "" {
+		return nil, errors.New("an output directory is required to fetch remote files")
+	}
+
+	files := make(map[string]*url.URL, len(urls))
+	for _, u := range urls {
+		name := path.Base(u.Path)
+		if name == "." || name == "/" {
+			return nil, errors.Errorf("can't infer the file name from URL %q", u.String())
+		}
+
+		if prev, found := files[name]; found {
+			return nil, errors.Errorf("URLs %q and %q resolve to the same file name %q", prev.String(), u.String(), name)
+		}
+		files[name] = u
+	}
+
+	// The directory needs to exist before the reloader starts watching it.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create the output directory")
+	}
+
+	return &urlFetcher{
+		logger:   logger,
+		client:   &http.Client{Timeout: interval},
+		dir:      dir,
+		interval: interval,
+		files:    files,
+	}, nil
+}
+
+// Run fetches the remote files until the context is canceled.
+func (f *urlFetcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		f.fetchAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *urlFetcher) fetchAll(ctx context.Context) {
+	for name, u := range f.files {
+		if err := f.fetch(ctx, name, u); err != nil {
+			level.Error(f.logger).Log("msg", "failed to fetch remote file", "url", u.String(), "err", err)
+		}
+	}
+}
+
+// fetch downloads the content of the URL and writes it to the named file
+// if it differs from the current content.
+func (f *urlFetcher) fetch(ctx context.Context, name string, u *url.URL) error {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response body")
+	}
+
+	filename := filepath.Join(f.dir, name)
+	current, err := ioutil.ReadFile(filename)
+	if err == nil && bytes.Equal(current, b) {
+		return nil
+	}
+
+	// Write to a temporary file first and rename it so that the reloader
+	// never sees a partially written file. The temporary file is created
+	// outside of the watched directory to not trigger a reload.
+	tmp, err := ioutil.TempFile(filepath.Dir(f.dir), "."+name+".")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return errors.Wrap(err, "failed to rename file")
+	}
+
+	level.Info(f.logger).Log("msg", "remote file updated", "url", u.String(), "file", filename)
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestNewURLFetcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		name string
+		dir  string
+		urls []string
+		err  bool
+	}{
+		{
+			name: "valid",
+			dir:  dir,
+			urls: []string{"http://example.com/rules/a.yaml", "http://example.com/b.yaml"},
+		},
+		{
+			name: "missing directory",
+			urls: []string{"http://example.com/a.yaml"},
+			err:  true,
+		},
+		{
+			name: "no file name",
+			dir:  dir,
+			urls: []string{"http://example.com/"},
+			err:  true,
+		},
+		{
+			name: "duplicate file name",
+			dir:  dir,
+			urls: []string{"http://example.com/a/rules.yaml", "http://example.com/b/rules.yaml"},
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var urls []*url.URL
+			for _, u := range tc.urls {
+				urls = append(urls, mustParseURL(t, u))
+			}
+
+			_, err := newURLFetcher(log.NewNopLogger(), tc.dir, time.Minute, urls)
+			if tc.err && err == nil {
+				t.Fatal("expecting error, got no error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+		})
+	}
+}
+
+func TestURLFetcherFetch(t *testing.T) {
+	root, err := ioutil.TempDir("", "fetcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "remote")
+
+	content := "groups: []\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rules.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	u := mustParseURL(t, srv.URL+"/rules.yaml")
+	f, err := newURLFetcher(log.NewNopLogger(), dir, time.Minute, []*url.URL{u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, "rules.yaml")
+	if err := f.fetch(context.Background(), "rules.yaml", u); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Fatalf("expected %q, got %q", content, string(b))
+	}
+
+	// The file shouldn't be rewritten when the content doesn't change.
+	before, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, before.ModTime().Add(-time.Hour), before.ModTime().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.fetch(context.Background(), "rules.yaml", u); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(before.ModTime().Add(-time.Hour)) {
+		t.Fatal("expected the file not to be rewritten")
+	}
+
+	content = "groups:\n- name: test\n  rules: []\n"
+	if err := f.fetch(context.Background(), "rules.yaml", u); err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Fatalf("expected %q, got %q", content, string(b))
+	}
+
+	// Errors leave the current file untouched.
+	if err := f.fetch(context.Background(), "rules.yaml", mustParseURL(t, srv.URL+"/missing.yaml")); err == nil {
+		t.Fatal("expecting error, got no error")
+	}
+	b, err = ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Fatalf("expected %q, got %q", content, string(b))
+	}
+
+	// No temporary file is left behind.
+	for d, expected := range map[string]string{root: "remote", dir: "rules.yaml"} {
+		files, err := ioutil.ReadDir(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || files[0].Name() != expected {
+			t.Fatalf("expected only %q in %q, got %v", expected, d, files)
+		}
+	}
+}
@@ -53,6 +53,16 @@ func main() {
 
 	rulesDir := app.Flag("rules-dir", "Rules directory to watch non-recursively").Strings()
 
+	watchedDirs := app.Flag("watched-dir", "Directory to watch non-recursively, can be repeated").Strings()
+
+	watchedURLs := app.Flag("watched-url", "URL of a remote file to poll, can be repeated. The file is written to --watched-url-dir").URLList()
+
+	watchedURLDir := app.Flag("watched-url-dir", "Directory where the remote files are written to, it is watched non-recursively. The temporary files are written to its parent directory, which must be on the same filesystem").
+		String()
+
+	watchedURLInterval := app.Flag("watched-url-interval", "Interval at which the remote files are polled").
+		Default("1m").Duration()
+
 	createStatefulsetOrdinalFrom := app.Flag(
 		"statefulset-ordinal-from-envvar",
 		fmt.Sprintf("parse this environment variable to create %s, containing the statefulset ordinal number", statefulsetOrdinalEnvvar)).
@@ -86,10 +96,28 @@ func main() {
 
 	logger.Log("msg", fmt.Sprintf("Starting prometheus-config-reloader version '%v'.", version.Version))
 
+	dirs := append(*rulesDir, *watchedDirs...)
+
 	var g run.Group
+	if len(*watchedURLs) > 0 {
+		fetcher, err := newURLFetcher(log.With(logger, "component", "fetcher"), *watchedURLDir, *watchedURLInterval, *watchedURLs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		dirs = append(dirs, *watchedURLDir)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return fetcher.Run(ctx)
+		}, func(error) {
+			cancel()
+		})
+	}
+
 	{
 		ctx, cancel := context.WithCancel(context.Background())
-		rel := reloader.New(logger, *reloadURL, *cfgFile, *cfgSubstFile, *rulesDir)
+		rel := reloader.New(logger, *reloadURL, *cfgFile, *cfgSubstFile, dirs)
 
 		g.Add(func() error {
 			return rel.Watch(ctx)
@@ -47,6 +47,8 @@ local k = import 'ksonnet/ksonnet.beta.4/k.libsonnet';
     '0servicemonitorCustomResourceDefinition': import 'servicemonitor-crd.libsonnet',
     '0podmonitorCustomResourceDefinition': import 'podmonitor-crd.libsonnet',
     '0probeCustomResourceDefinition': import 'probe-crd.libsonnet',
+    '0nodemonitorCustomResourceDefinition': import 'nodemonitor-crd.libsonnet',
+    '0prometheusagentCustomResourceDefinition': import 'prometheusagent-crd.libsonnet',
     '0prometheusruleCustomResourceDefinition': import 'prometheusrule-crd.libsonnet',
     '0thanosrulerCustomResourceDefinition': import 'thanosruler-crd.libsonnet',
 
@@ -70,14 +72,20 @@ local k = import 'ksonnet/ksonnet.beta.4/k.libsonnet';
                              policyRule.withResources([
                                'alertmanagers',
                                'alertmanagers/finalizers',
+                               'alertmanagers/status',
                                'prometheuses',
                                'prometheuses/finalizers',
+                               'prometheuses/status',
                                'thanosrulers',
                                'thanosrulers/finalizers',
+                               'thanosrulers/status',
                                'servicemonitors',
                                'podmonitors',
                                'probes',
+                               'nodemonitors',
+                               'prometheusagents',
                                'prometheusrules',
+                               'prometheusrules/status',
                              ]) +
                              policyRule.withVerbs(['*']);
 
@@ -109,6 +117,7 @@ local k = import 'ksonnet/ksonnet.beta.4/k.libsonnet';
                             'services',
                             'services/finalizers',
                             'endpoints',
+                            'serviceaccounts',
                           ]) +
                           policyRule.withVerbs(['get', 'create', 'update', 'delete']);
 
@@ -126,7 +135,49 @@ local k = import 'ksonnet/ksonnet.beta.4/k.libsonnet';
                             ]) +
                             policyRule.withVerbs(['get', 'list', 'watch']);
 
-      local rules = [monitoringRule, appsRule, coreRule, podRule, routingRule, nodeRule, namespaceRule];
+      local eventRule = policyRule.new() +
+                        policyRule.withApiGroups(['']) +
+                        policyRule.withResources([
+                          'events',
+                        ]) +
+                        policyRule.withVerbs(['create', 'patch']);
+
+      local leaseRule = policyRule.new() +
+                        policyRule.withApiGroups(['coordination.k8s.io']) +
+                        policyRule.withResources([
+                          'leases',
+                        ]) +
+                        policyRule.withVerbs(['get', 'create', 'update']);
+
+      local networkPolicyRule = policyRule.new() +
+                                policyRule.withApiGroups(['networking.k8s.io']) +
+                                policyRule.withResources([
+                                  'networkpolicies',
+                                ]) +
+                                policyRule.withVerbs(['get', 'create', 'update', 'delete']);
+
+      local accessReviewRule = policyRule.new() +
+                               policyRule.withApiGroups(['authorization.k8s.io']) +
+                               policyRule.withResources([
+                                 'subjectaccessreviews',
+                               ]) +
+                               policyRule.withVerbs(['create']);
+
+      local pvcRule = policyRule.new() +
+                      policyRule.withApiGroups(['']) +
+                      policyRule.withResources([
+                        'persistentvolumeclaims',
+                      ]) +
+                      policyRule.withVerbs(['list', 'update']);
+
+      local storageClassRule = policyRule.new() +
+                               policyRule.withApiGroups(['storage.k8s.io']) +
+                               policyRule.withResources([
+                                 'storageclasses',
+                               ]) +
+                               policyRule.withVerbs(['get']);
+
+      local rules = [monitoringRule, appsRule, coreRule, podRule, routingRule, nodeRule, namespaceRule, eventRule, leaseRule, networkPolicyRule, accessReviewRule, pvcRule, storageClassRule];
 
       clusterRole.new() +
       clusterRole.mixin.metadata.withLabels(po.commonLabels) +
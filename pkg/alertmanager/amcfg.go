@@ -0,0 +1,374 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	alertmanagerConfigKey = "alertmanager.yaml"
+	// labelAlertmanagerName identifies the Alertmanager owning a generated
+	// Secret.
+	labelAlertmanagerName = "alertmanager-name"
+)
+
+// generatesConfig returns whether the operator writes the configuration of
+// the Alertmanager to the generated configuration Secret.
+func generatesConfig(am *monitoringv1.Alertmanager) bool {
+	return am.Spec.AlertmanagerConfiguration != nil || len(am.Spec.Templates) > 0
+}
+
+// provisionAlertmanagerConfiguration merges the settings managed by the
+// operator and the templates with the base configuration and writes the
+// result to the generated configuration Secret. It returns the conflicting
+// settings.
+func (c *Operator) provisionAlertmanagerConfiguration(ctx context.Context, am *monitoringv1.Alertmanager) ([]string, error) {
+	sClient := c.kclient.CoreV1().Secrets(am.Namespace)
+
+	baseName := am.Spec.ConfigSecret
+	if baseName == "" {
+		baseName = configSecretName(am.Name)
+	}
+
+	base, err := sClient.Get(ctx, baseName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get base configuration secret %q", baseName)
+	}
+
+	var (
+		global   yaml.MapSlice
+		strategy monitoringv1.AlertmanagerMergeStrategy
+	)
+	if am.Spec.AlertmanagerConfiguration != nil {
+		global, err = c.loadGlobalConfig(ctx, am.Namespace, am.Spec.AlertmanagerConfiguration.Global)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load global configuration")
+		}
+		strategy = am.Spec.AlertmanagerConfiguration.MergeStrategy
+	}
+
+	conf, conflicts, err := mergeGlobalConfig(base.Data[alertmanagerConfigKey], global, strategy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to merge base configuration from secret %q", baseName)
+	}
+
+	if err := checkTemplates(am); err != nil {
+		return nil, err
+	}
+	var templates []string
+	for _, t := range am.Spec.Templates {
+		templates = append(templates, path.Join(templatesDir, templateFilename(t)))
+	}
+	conf, err = addTemplates(conf, templates)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to add templates to base configuration from secret %q", baseName)
+	}
+
+	// Keep the other keys of the base Secret (e.g. templates) since the
+	// generated Secret replaces it in the Pods.
+	data := make(map[string][]byte, len(base.Data))
+	for k, v := range base.Data {
+		data[k] = v
+	}
+	data[alertmanagerConfigKey] = conf
+
+	return conflicts, c.createOrUpdateGeneratedSecret(ctx, am, generatedConfigSecretName(am.Name), data)
+}
+
+// createOrUpdateGeneratedSecret writes a Secret owned by the Alertmanager
+// object, updating it only when its data changed.
+func (c *Operator) createOrUpdateGeneratedSecret(ctx context.Context, am *monitoringv1.Alertmanager, name string, data map[string][]byte) error {
+	sClient := c.kclient.CoreV1().Secrets(am.Namespace)
+
+	labels := map[string]string{labelAlertmanagerName: am.Name}
+	for k, v := range managedByOperatorLabels {
+		labels[k] = v
+	}
+
+	boolTrue := true
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: c.config.Labels.Merge(labels),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         am.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               am.Kind,
+					Name:               am.Name,
+					UID:                am.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+
+	cur, err := sClient.Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		level.Debug(c.logger).Log("msg", "creating generated secret", "secret", s.Name, "alertmanager", am.Name, "namespace", am.Namespace)
+		_, err = sClient.Create(ctx, s, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(cur.Data, s.Data) {
+		return nil
+	}
+
+	level.Debug(c.logger).Log("msg", "updating generated secret", "secret", s.Name, "alertmanager", am.Name, "namespace", am.Namespace)
+	_, err = sClient.Update(ctx, s, metav1.UpdateOptions{})
+	return err
+}
+
+// makeConfigurationMergedCondition returns the ConfigurationMerged condition
+// reporting the given conflicts.
+func makeConfigurationMergedCondition(am *monitoringv1.Alertmanager, conflicts []string, current []monitoringv1.Condition) monitoringv1.Condition {
+	cond := monitoringv1.Condition{
+		Type:               monitoringv1.ConfigurationMerged,
+		Status:             monitoringv1.ConditionTrue,
+		ObservedGeneration: am.Generation,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	if len(conflicts) > 0 {
+		winner := "base configuration"
+		if am.Spec.AlertmanagerConfiguration.MergeStrategy == monitoringv1.AlertmanagerMergeStrategyOverride {
+			winner = "operator-managed settings"
+		}
+		cond.Status = monitoringv1.ConditionFalse
+		cond.Reason = "ConflictingSettings"
+		cond.Message = fmt.Sprintf("settings %s conflict with the base configuration, the %s take precedence", strings.Join(conflicts, ", "), winner)
+	}
+
+	for _, c := range current {
+		if c.Type == cond.Type && c.Status == cond.Status {
+			cond.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	return cond
+}
+
+// loadGlobalConfig returns the global parameters in the order of the
+// Alertmanager configuration file, resolving the Secret references.
+func (c *Operator) loadGlobalConfig(ctx context.Context, ns string, g *monitoringv1.AlertmanagerGlobalConfig) (yaml.MapSlice, error) {
+	if g == nil {
+		return nil, nil
+	}
+
+	getSecretKey := func(sel *v1.SecretKeySelector) (string, error) {
+		b, err := c.getSecretKey(ctx, ns, *sel)
+		return string(b), err
+	}
+
+	var cfg yaml.MapSlice
+	addString := func(k, v string) {
+		if v != "" {
+			cfg = append(cfg, yaml.MapItem{Key: k, Value: v})
+		}
+	}
+
+	addString("resolve_timeout", g.ResolveTimeout)
+	addString("smtp_from", g.SMTPFrom)
+	addString("smtp_smarthost", g.SMTPSmarthost)
+	addString("smtp_hello", g.SMTPHello)
+	addString("smtp_auth_username", g.SMTPAuthUsername)
+	if g.SMTPAuthPassword != nil {
+		v, err := getSecretKey(g.SMTPAuthPassword)
+		if err != nil {
+			return nil, errors.Wrap(err, "smtpAuthPassword")
+		}
+		addString("smtp_auth_password", v)
+	}
+	if g.SMTPRequireTLS != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "smtp_require_tls", Value: *g.SMTPRequireTLS})
+	}
+	if g.SlackAPIURL != nil {
+		v, err := getSecretKey(g.SlackAPIURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "slackAPIURL")
+		}
+		addString("slack_api_url", v)
+	}
+	addString("pagerduty_url", g.PagerdutyURL)
+	addString("opsgenie_api_url", g.OpsGenieAPIURL)
+
+	return cfg, nil
+}
+
+// getSecretKey returns the value of the key referenced by the selector.
+func (c *Operator) getSecretKey(ctx context.Context, ns string, sel v1.SecretKeySelector) ([]byte, error) {
+	s, err := c.kclient.CoreV1().Secrets(ns).Get(ctx, sel.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get secret %q", sel.Name)
+	}
+	b, ok := s.Data[sel.Key]
+	if !ok {
+		return nil, errors.Errorf("key %q in secret %q not found", sel.Key, sel.Name)
+	}
+	return b, nil
+}
+
+// mergeGlobalConfig merges the global parameters into the base
+// configuration. Parameters defined in both with different values are
+// returned as conflicts and resolved according to the merge strategy. The
+// order of the base configuration is preserved.
+func mergeGlobalConfig(base []byte, global yaml.MapSlice, strategy monitoringv1.AlertmanagerMergeStrategy) ([]byte, []string, error) {
+	var cfg yaml.MapSlice
+	if err := yaml.Unmarshal(base, &cfg); err != nil {
+		return nil, nil, err
+	}
+
+	idx := -1
+	var baseGlobal yaml.MapSlice
+	for i, item := range cfg {
+		if item.Key != "global" {
+			continue
+		}
+		idx = i
+		if item.Value != nil {
+			var ok bool
+			if baseGlobal, ok = item.Value.(yaml.MapSlice); !ok {
+				return nil, nil, errors.New("global section isn't a map")
+			}
+		}
+	}
+
+	var conflicts []string
+	for _, item := range global {
+		found := false
+		for i := range baseGlobal {
+			if baseGlobal[i].Key != item.Key {
+				continue
+			}
+			found = true
+			if reflect.DeepEqual(baseGlobal[i].Value, item.Value) {
+				break
+			}
+			conflicts = append(conflicts, fmt.Sprintf("global.%s", item.Key))
+			if strategy == monitoringv1.AlertmanagerMergeStrategyOverride {
+				baseGlobal[i].Value = item.Value
+			}
+			break
+		}
+		if !found {
+			baseGlobal = append(baseGlobal, item)
+		}
+	}
+
+	if len(baseGlobal) > 0 {
+		if idx == -1 {
+			cfg = append(yaml.MapSlice{{Key: "global", Value: baseGlobal}}, cfg...)
+		} else {
+			cfg[idx].Value = baseGlobal
+		}
+	}
+
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, conflicts, nil
+}
+
+// checkTemplates checks that each template references either a ConfigMap or
+// a Secret.
+func checkTemplates(am *monitoringv1.Alertmanager) error {
+	for i, t := range am.Spec.Templates {
+		if err := t.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid template %d", i)
+		}
+		if t.Secret == nil && t.ConfigMap == nil {
+			return errors.Errorf("invalid template %d: either configMap or secret must be set", i)
+		}
+	}
+
+	return nil
+}
+
+// templateFilename returns the name of the template file in the templates
+// volume.
+func templateFilename(t monitoringv1.SecretOrConfigMap) string {
+	if t.Secret != nil {
+		return fmt.Sprintf("secret_%s_%s", t.Secret.Name, t.Secret.Key)
+	}
+	return fmt.Sprintf("configmap_%s_%s", t.ConfigMap.Name, t.ConfigMap.Key)
+}
+
+// addTemplates appends the given template files to the `templates` section
+// of the configuration, skipping the files which are already listed.
+func addTemplates(conf []byte, templates []string) ([]byte, error) {
+	if len(templates) == 0 {
+		return conf, nil
+	}
+
+	var cfg yaml.MapSlice
+	if err := yaml.Unmarshal(conf, &cfg); err != nil {
+		return nil, err
+	}
+
+	idx := -1
+	var current []interface{}
+	for i, item := range cfg {
+		if item.Key != "templates" {
+			continue
+		}
+		idx = i
+		if item.Value != nil {
+			var ok bool
+			if current, ok = item.Value.([]interface{}); !ok {
+				return nil, errors.New("templates section isn't a list")
+			}
+		}
+	}
+
+	for _, t := range templates {
+		found := false
+		for _, c := range current {
+			if c == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			current = append(current, t)
+		}
+	}
+
+	if idx == -1 {
+		cfg = append(cfg, yaml.MapItem{Key: "templates", Value: current})
+	} else {
+		cfg[idx].Value = current
+	}
+
+	return yaml.Marshal(cfg)
+}
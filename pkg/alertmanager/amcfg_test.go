@@ -0,0 +1,188 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMergeGlobalConfig(t *testing.T) {
+	global := yaml.MapSlice{
+		{Key: "resolve_timeout", Value: "10m"},
+		{Key: "smtp_from", Value: "alerts@example.com"},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		base      string
+		strategy  monitoringv1.AlertmanagerMergeStrategy
+		expected  string
+		conflicts []string
+	}{
+		{
+			name: "no global section",
+			base: `route:
+  receiver: "null"
+receivers:
+- name: "null"
+`,
+			expected: `global:
+  resolve_timeout: 10m
+  smtp_from: alerts@example.com
+route:
+  receiver: "null"
+receivers:
+- name: "null"
+`,
+		},
+		{
+			name: "merge",
+			base: `global:
+  resolve_timeout: 5m
+  smtp_hello: example.com
+route:
+  receiver: "null"
+`,
+			expected: `global:
+  resolve_timeout: 5m
+  smtp_hello: example.com
+  smtp_from: alerts@example.com
+route:
+  receiver: "null"
+`,
+			conflicts: []string{"global.resolve_timeout"},
+		},
+		{
+			name: "override",
+			base: `global:
+  resolve_timeout: 5m
+  smtp_from: alerts@example.com
+route:
+  receiver: "null"
+`,
+			strategy: monitoringv1.AlertmanagerMergeStrategyOverride,
+			expected: `global:
+  resolve_timeout: 10m
+  smtp_from: alerts@example.com
+route:
+  receiver: "null"
+`,
+			conflicts: []string{"global.resolve_timeout"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, conflicts, err := mergeGlobalConfig([]byte(tc.base), global, tc.strategy)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(cfg))
+			require.Equal(t, tc.conflicts, conflicts)
+		})
+	}
+}
+
+func TestAddTemplates(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		base      string
+		templates []string
+		expected  string
+	}{
+		{
+			name:     "no templates",
+			base:     "route:\n  receiver: default\n",
+			expected: "route:\n  receiver: default\n",
+		},
+		{
+			name:      "no templates section",
+			base:      "route:\n  receiver: default\n",
+			templates: []string{"/etc/alertmanager/templates/configmap_tmpl_default.tmpl"},
+			expected:  "route:\n  receiver: default\ntemplates:\n- /etc/alertmanager/templates/configmap_tmpl_default.tmpl\n",
+		},
+		{
+			name:      "existing templates",
+			base:      "templates:\n- /custom/*.tmpl\n- /etc/alertmanager/templates/configmap_tmpl_default.tmpl\n",
+			templates: []string{"/etc/alertmanager/templates/configmap_tmpl_default.tmpl", "/etc/alertmanager/templates/secret_tmpl_email.tmpl"},
+			expected:  "templates:\n- /custom/*.tmpl\n- /etc/alertmanager/templates/configmap_tmpl_default.tmpl\n- /etc/alertmanager/templates/secret_tmpl_email.tmpl\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := addTemplates([]byte(tc.base), tc.templates)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(b))
+		})
+	}
+
+	_, err := addTemplates([]byte("templates: foo\n"), []string{"/etc/alertmanager/templates/bar"})
+	require.Error(t, err)
+}
+
+func TestProvisionAlertmanagerConfiguration(t *testing.T) {
+	am := &monitoringv1.Alertmanager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.AlertmanagerSpec{
+			AlertmanagerConfiguration: &monitoringv1.AlertmanagerConfiguration{
+				Global: &monitoringv1.AlertmanagerGlobalConfig{
+					SlackAPIURL: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "slack"},
+						Key:                  "url",
+					},
+				},
+			},
+		},
+	}
+
+	c := &Operator{
+		kclient: fake.NewSimpleClientset(
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "alertmanager-test", Namespace: "default"},
+				Data: map[string][]byte{
+					"alertmanager.yaml": []byte("route:\n  receiver: \"null\"\n"),
+					"template.tmpl":     []byte("{{ define \"x\" }}{{ end }}"),
+				},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "default"},
+				Data: map[string][]byte{
+					"url": []byte("https://hooks.slack.com/services/x"),
+				},
+			},
+		),
+		logger: log.NewNopLogger(),
+	}
+
+	conflicts, err := c.provisionAlertmanagerConfiguration(context.Background(), am)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+
+	s, err := c.kclient.CoreV1().Secrets("default").Get(context.Background(), "alertmanager-test-generated", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "global:\n  slack_api_url: https://hooks.slack.com/services/x\nroute:\n  receiver: \"null\"\n", string(s.Data["alertmanager.yaml"]))
+	require.Equal(t, "{{ define \"x\" }}{{ end }}", string(s.Data["template.tmpl"]))
+
+	cond := makeConfigurationMergedCondition(am, []string{"global.slack_api_url"}, nil)
+	require.Equal(t, monitoringv1.ConditionFalse, cond.Status)
+	require.Equal(t, "ConflictingSettings", cond.Reason)
+}
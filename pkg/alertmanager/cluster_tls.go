@@ -0,0 +1,114 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	clusterTLSConfigDir  = "/etc/alertmanager/cluster_tls_config"
+	clusterTLSConfigFile = "cluster-tls-config.yaml"
+
+	clusterTLSServerCertKey     = "server.crt"
+	clusterTLSServerKeyKey      = "server.key"
+	clusterTLSServerClientCAKey = "server-client-ca.crt"
+	clusterTLSClientCertKey     = "client.crt"
+	clusterTLSClientKeyKey      = "client.key"
+	clusterTLSClientCAKey       = "client-ca.crt"
+)
+
+func clusterTLSConfigSecretName(name string) string {
+	return fmt.Sprintf("%s-cluster-tls-config", prefixedName(name))
+}
+
+// provisionClusterTLSConfig writes the cluster TLS configuration file along
+// with the referenced certificates and keys to the generated cluster TLS
+// Secret.
+func (c *Operator) provisionClusterTLSConfig(ctx context.Context, am *monitoringv1.Alertmanager) error {
+	tlsConfig := am.Spec.ClusterTLS
+
+	data := map[string][]byte{}
+	for _, s := range []struct {
+		sel   *v1.SecretKeySelector
+		key   string
+		field string
+	}{
+		{&tlsConfig.Server.Cert, clusterTLSServerCertKey, "server.cert"},
+		{&tlsConfig.Server.KeySecret, clusterTLSServerKeyKey, "server.keySecret"},
+		{tlsConfig.Server.ClientCA, clusterTLSServerClientCAKey, "server.clientCA"},
+		{&tlsConfig.Client.Cert, clusterTLSClientCertKey, "client.cert"},
+		{&tlsConfig.Client.KeySecret, clusterTLSClientKeyKey, "client.keySecret"},
+		{tlsConfig.Client.CA, clusterTLSClientCAKey, "client.ca"},
+	} {
+		if s.sel == nil {
+			continue
+		}
+		b, err := c.getSecretKey(ctx, am.Namespace, *s.sel)
+		if err != nil {
+			return errors.Wrap(err, s.field)
+		}
+		data[s.key] = b
+	}
+
+	conf, err := makeClusterTLSConfig(tlsConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate cluster TLS configuration")
+	}
+	data[clusterTLSConfigFile] = conf
+
+	return c.createOrUpdateGeneratedSecret(ctx, am, clusterTLSConfigSecretName(am.Name), data)
+}
+
+// makeClusterTLSConfig returns the content of the file passed to the
+// --cluster.tls-config flag.
+func makeClusterTLSConfig(tlsConfig *monitoringv1.ClusterTLSConfig) ([]byte, error) {
+	server := yaml.MapSlice{
+		{Key: "cert_file", Value: path.Join(clusterTLSConfigDir, clusterTLSServerCertKey)},
+		{Key: "key_file", Value: path.Join(clusterTLSConfigDir, clusterTLSServerKeyKey)},
+	}
+	if tlsConfig.Server.ClientCA != nil {
+		server = append(server,
+			yaml.MapItem{Key: "client_ca_file", Value: path.Join(clusterTLSConfigDir, clusterTLSServerClientCAKey)},
+			yaml.MapItem{Key: "client_auth_type", Value: "RequireAndVerifyClientCert"},
+		)
+	}
+
+	client := yaml.MapSlice{
+		{Key: "cert_file", Value: path.Join(clusterTLSConfigDir, clusterTLSClientCertKey)},
+		{Key: "key_file", Value: path.Join(clusterTLSConfigDir, clusterTLSClientKeyKey)},
+	}
+	if tlsConfig.Client.CA != nil {
+		client = append(client, yaml.MapItem{Key: "ca_file", Value: path.Join(clusterTLSConfigDir, clusterTLSClientCAKey)})
+	}
+	if tlsConfig.Client.ServerName != "" {
+		client = append(client, yaml.MapItem{Key: "server_name", Value: tlsConfig.Client.ServerName})
+	}
+	if tlsConfig.Client.InsecureSkipVerify {
+		client = append(client, yaml.MapItem{Key: "insecure_skip_verify", Value: true})
+	}
+
+	return yaml.Marshal(yaml.MapSlice{
+		{Key: "tls_server_config", Value: server},
+		{Key: "tls_client_config", Value: client},
+	})
+}
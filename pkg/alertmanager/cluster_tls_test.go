@@ -0,0 +1,110 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func secretKey(name, key string) v1.SecretKeySelector {
+	return v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: name},
+		Key:                  key,
+	}
+}
+
+func TestProvisionClusterTLSConfig(t *testing.T) {
+	ca := secretKey("cluster-ca", "ca.crt")
+	am := &monitoringv1.Alertmanager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.AlertmanagerSpec{
+			ClusterTLS: &monitoringv1.ClusterTLSConfig{
+				Server: monitoringv1.ClusterTLSServerConfig{
+					Cert:      secretKey("cluster-tls", "tls.crt"),
+					KeySecret: secretKey("cluster-tls", "tls.key"),
+					ClientCA:  &ca,
+				},
+				Client: monitoringv1.ClusterTLSClientConfig{
+					Cert:       secretKey("cluster-tls", "tls.crt"),
+					KeySecret:  secretKey("cluster-tls", "tls.key"),
+					CA:         &ca,
+					ServerName: "alertmanager-operated",
+				},
+			},
+		},
+	}
+
+	c := &Operator{
+		kclient: fake.NewSimpleClientset(
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-tls", Namespace: "default"},
+				Data: map[string][]byte{
+					"tls.crt": []byte("cert"),
+					"tls.key": []byte("key"),
+				},
+			},
+			&v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-ca", Namespace: "default"},
+				Data: map[string][]byte{
+					"ca.crt": []byte("ca"),
+				},
+			},
+		),
+		logger: log.NewNopLogger(),
+	}
+
+	require.NoError(t, c.provisionClusterTLSConfig(context.Background(), am))
+
+	s, err := c.kclient.CoreV1().Secrets("default").Get(context.Background(), "alertmanager-test-cluster-tls-config", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string][]byte{
+		"cluster-tls-config.yaml": []byte(`tls_server_config:
+  cert_file: /etc/alertmanager/cluster_tls_config/server.crt
+  key_file: /etc/alertmanager/cluster_tls_config/server.key
+  client_ca_file: /etc/alertmanager/cluster_tls_config/server-client-ca.crt
+  client_auth_type: RequireAndVerifyClientCert
+tls_client_config:
+  cert_file: /etc/alertmanager/cluster_tls_config/client.crt
+  key_file: /etc/alertmanager/cluster_tls_config/client.key
+  ca_file: /etc/alertmanager/cluster_tls_config/client-ca.crt
+  server_name: alertmanager-operated
+`),
+		"server.crt":           []byte("cert"),
+		"server.key":           []byte("key"),
+		"server-client-ca.crt": []byte("ca"),
+		"client.crt":           []byte("cert"),
+		"client.key":           []byte("key"),
+		"client-ca.crt":        []byte("ca"),
+	}, s.Data)
+
+	require.True(t, referencesSecret(am, "cluster-ca"))
+	require.False(t, referencesSecret(am, "alertmanager-test"))
+
+	// Missing keys are reported.
+	am.Spec.ClusterTLS.Client.KeySecret = secretKey("cluster-tls", "missing")
+	require.Error(t, c.provisionClusterTLSConfig(context.Background(), am))
+}
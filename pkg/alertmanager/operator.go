@@ -27,6 +27,7 @@ import (
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
 	prometheusoperator "github.com/prometheus-operator/prometheus-operator/pkg/prometheus"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -38,16 +39,13 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
-const (
-	resyncPeriod = 5 * time.Minute
-)
-
 // Operator manages life cycle of Alertmanager deployments and
 // monitoring configurations.
 type Operator struct {
@@ -55,8 +53,13 @@ type Operator struct {
 	mclient monitoringclient.Interface
 	logger  log.Logger
 
+	// nsWatcher updates the namespaces of the informers when a namespace
+	// selector is configured.
+	nsWatcher *operator.NamespaceWatcher
+
 	alrtInfs *informers.ForResource
 	ssetInfs *informers.ForResource
+	secrInfs *informers.ForResource
 
 	queue workqueue.RateLimitingInterface
 
@@ -76,6 +79,9 @@ type Config struct {
 	Namespaces                   prometheusoperator.Namespaces
 	Labels                       prometheusoperator.Labels
 	AlertManagerSelector         string
+	GCInterval                   time.Duration
+	Controller                   operator.ControllerConfig
+	Mode                         string
 }
 
 // New creates a new controller.
@@ -95,11 +101,15 @@ func New(ctx context.Context, c prometheusoperator.Config, logger log.Logger, r
 		return nil, errors.Wrap(err, "instantiating monitoring client failed")
 	}
 
+	if err := c.AlertmanagerController.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid controller configuration")
+	}
+
 	o := &Operator{
 		kclient: client,
 		mclient: mclient,
 		logger:  logger,
-		queue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "alertmanager"),
+		queue:   c.AlertmanagerController.NewRateLimitingQueue("alertmanager"),
 		metrics: operator.NewMetrics("alertmanager", r),
 		config: Config{
 			Host:                         c.Host,
@@ -112,12 +122,23 @@ func New(ctx context.Context, c prometheusoperator.Config, logger log.Logger, r
 			Namespaces:                   c.Namespaces,
 			Labels:                       c.Labels,
 			AlertManagerSelector:         c.AlertManagerSelector,
+			GCInterval:                   c.GCInterval,
+			Controller:                   c.AlertmanagerController,
+			Mode:                         c.Mode,
 		},
 	}
+	resyncPeriod := c.AlertmanagerController.ResyncPeriod
+
+	if c.Namespaces.Selector != "" {
+		o.nsWatcher, err = operator.NewNamespaceWatcher(logger, client, c.Namespaces.Selector, resyncPeriod, o.enqueueAll)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	o.alrtInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			o.config.Namespaces.AlertmanagerAllowList,
+			o.nsWatcher.AllowList(o.config.Namespaces.AlertmanagerAllowList),
 			o.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -133,7 +154,7 @@ func New(ctx context.Context, c prometheusoperator.Config, logger log.Logger, r
 
 	o.ssetInfs, err = informers.NewInformersForResource(
 		informers.NewKubeInformerFactories(
-			o.config.Namespaces.AlertmanagerAllowList,
+			o.nsWatcher.AllowList(o.config.Namespaces.AlertmanagerAllowList),
 			o.config.Namespaces.DenyList,
 			o.kclient,
 			resyncPeriod,
@@ -145,6 +166,22 @@ func New(ctx context.Context, c prometheusoperator.Config, logger log.Logger, r
 		return nil, errors.Wrap(err, "error creating statefulset informers")
 	}
 
+	o.secrInfs, err = informers.NewInformersForResource(
+		informers.NewKubeInformerFactories(
+			o.nsWatcher.AllowList(o.config.Namespaces.AlertmanagerAllowList),
+			o.config.Namespaces.DenyList,
+			o.kclient,
+			resyncPeriod,
+			nil,
+		),
+		v1.SchemeGroupVersion.WithResource(string(v1.ResourceSecrets)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating secret informers")
+	}
+
+	o.nsWatcher.Watch(o.config.Namespaces.AlertmanagerAllowList, o.alrtInfs, o.ssetInfs, o.secrInfs)
+
 	return o, nil
 }
 
@@ -158,6 +195,7 @@ func (c *Operator) waitForCacheSync(stopc <-chan struct{}) error {
 	}{
 		{"Alertmanager", c.alrtInfs},
 		{"StatefulSet", c.ssetInfs},
+		{"Secret", c.secrInfs},
 	} {
 		for _, inf := range infs.informersForResource.GetInformers() {
 			if !cache.WaitForCacheSync(stopc, inf.Informer().HasSynced) {
@@ -188,6 +226,11 @@ func (c *Operator) addHandlers() {
 		DeleteFunc: c.handleStatefulSetDelete,
 		UpdateFunc: c.handleStatefulSetUpdate,
 	})
+	c.secrInfs.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handleSecretAdd,
+		DeleteFunc: c.handleSecretDelete,
+		UpdateFunc: c.handleSecretUpdate,
+	})
 }
 
 // Run the controller.
@@ -215,19 +258,65 @@ func (c *Operator) Run(ctx context.Context) error {
 		return nil
 	}
 
-	go c.worker(ctx)
+	for i := 0; i < c.config.Controller.Workers; i++ {
+		go c.worker(ctx)
+	}
+
+	if err := c.nsWatcher.Init(ctx); err != nil {
+		return err
+	}
 
 	go c.alrtInfs.Start(ctx.Done())
 	go c.ssetInfs.Start(ctx.Done())
+	go c.secrInfs.Start(ctx.Done())
 	if err := c.waitForCacheSync(ctx.Done()); err != nil {
 		return err
 	}
 	c.addHandlers()
+	go c.nsWatcher.Run(ctx)
+
+	if c.config.GCInterval > 0 {
+		go c.orphanCollector().Run(ctx, c.config.GCInterval)
+	}
 
 	<-ctx.Done()
 	return nil
 }
 
+// volumeResizer returns the resizer of the volumes of the Alertmanager
+// StatefulSets.
+func (c *Operator) volumeResizer() *operator.VolumeResizer {
+	return &operator.VolumeResizer{
+		Logger:     c.logger,
+		KubeClient: c.kclient,
+		Metrics:    c.metrics,
+	}
+}
+
+// orphanCollector returns the garbage collector of the Secrets generated for
+// Alertmanager resources which don't exist anymore.
+func (c *Operator) orphanCollector() *operator.OrphanCollector {
+	return &operator.OrphanCollector{
+		Logger:           log.With(c.logger, "component", "gc"),
+		KubeClient:       c.kclient,
+		Metrics:          c.metrics,
+		AllowList:        c.config.Namespaces.AlertmanagerAllowList,
+		DenyList:         c.config.Namespaces.DenyList,
+		NamespaceWatcher: c.nsWatcher,
+		Owners: []operator.OrphanOwner{{
+			Kind:  monitoringv1.AlertmanagersKind,
+			Label: labelAlertmanagerName,
+			Exists: func(ctx context.Context, namespace, name string) (bool, error) {
+				_, err := c.mclient.MonitoringV1().Alertmanagers(namespace).Get(ctx, name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					return false, nil
+				}
+				return err == nil, err
+			},
+		}},
+	}
+}
+
 func (c *Operator) keyFunc(obj interface{}) (string, bool) {
 	k, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
@@ -251,6 +340,18 @@ func (c *Operator) getObject(obj interface{}) (metav1.Object, bool) {
 	return o, true
 }
 
+// enqueueAll enqueues all the Alertmanager objects.
+func (c *Operator) enqueueAll() {
+	objs, err := c.alrtInfs.List(labels.Everything())
+	if err != nil {
+		level.Error(c.logger).Log("msg", "listing all Alertmanager instances from cache failed", "err", err)
+		return
+	}
+	for _, obj := range objs {
+		c.enqueue(obj)
+	}
+}
+
 // enqueue adds a key to the queue. If obj is a key already it gets added
 // directly. Otherwise, the key is extracted via keyFunc.
 func (c *Operator) enqueue(obj interface{}) {
@@ -285,7 +386,9 @@ func (c *Operator) processNextWorkItem(ctx context.Context) bool {
 	defer c.queue.Done(key)
 
 	c.metrics.ReconcileCounter().Inc()
+	start := time.Now()
 	err := c.sync(ctx, key.(string))
+	c.metrics.ObserveReconcile(time.Since(start), err)
 	if err == nil {
 		c.queue.Forget(key)
 		return true
@@ -401,6 +504,88 @@ func (c *Operator) handleStatefulSetUpdate(oldo, curo interface{}) {
 	}
 }
 
+func (c *Operator) handleSecretAdd(obj interface{}) {
+	c.enqueueForSecret(obj, "add")
+}
+
+func (c *Operator) handleSecretDelete(obj interface{}) {
+	c.enqueueForSecret(obj, "delete")
+}
+
+func (c *Operator) handleSecretUpdate(old, cur interface{}) {
+	if old.(*v1.Secret).ResourceVersion == cur.(*v1.Secret).ResourceVersion {
+		return
+	}
+
+	c.enqueueForSecret(cur, "update")
+}
+
+// enqueueForSecret enqueues the Alertmanager objects which merge their base
+// configuration from the given Secret or reference it in their cluster TLS
+// or web configuration.
+func (c *Operator) enqueueForSecret(obj interface{}, action string) {
+	o, ok := c.getObject(obj)
+	if !ok {
+		return
+	}
+
+	err := c.alrtInfs.ListAllByNamespace(o.GetNamespace(), labels.Everything(), func(obj interface{}) {
+		am := obj.(*monitoringv1.Alertmanager)
+		if !referencesSecret(am, o.GetName()) {
+			return
+		}
+
+		level.Debug(c.logger).Log("msg", "referenced secret changed", "secret", o.GetName(), "alertmanager", am.Name, "namespace", am.Namespace)
+		c.metrics.TriggerByCounter("Secret", action).Inc()
+		c.enqueue(am)
+	})
+	if err != nil {
+		level.Error(c.logger).Log("msg", "listing Alertmanager instances from cache failed", "err", err)
+	}
+}
+
+// referencesSecret returns whether the Secret is an input of the Secrets
+// generated for the Alertmanager object.
+func referencesSecret(am *monitoringv1.Alertmanager, name string) bool {
+	if generatesConfig(am) {
+		baseName := am.Spec.ConfigSecret
+		if baseName == "" {
+			baseName = configSecretName(am.Name)
+		}
+		if baseName == name {
+			return true
+		}
+	}
+
+	if tlsConfig := am.Spec.ClusterTLS; tlsConfig != nil {
+		for _, sel := range []*v1.SecretKeySelector{
+			&tlsConfig.Server.Cert,
+			&tlsConfig.Server.KeySecret,
+			tlsConfig.Server.ClientCA,
+			&tlsConfig.Client.Cert,
+			&tlsConfig.Client.KeySecret,
+			tlsConfig.Client.CA,
+		} {
+			if sel != nil && sel.Name == name {
+				return true
+			}
+		}
+	}
+
+	return webconfig.References(am.Spec.Web, "Secret", name)
+}
+
+// provisionWebConfig writes the web configuration file and the TLS assets
+// it references to the web config Secret.
+func (c *Operator) provisionWebConfig(ctx context.Context, am *monitoringv1.Alertmanager) error {
+	data, err := webconfig.MakeSecretData(ctx, c.kclient.CoreV1(), am.Namespace, webConfigDir, am.Spec.Web)
+	if err != nil {
+		return err
+	}
+
+	return c.createOrUpdateGeneratedSecret(ctx, am, webConfigSecretName(am.Name), data)
+}
+
 func (c *Operator) sync(ctx context.Context, key string) error {
 	aobj, err := c.alrtInfs.Get(key)
 
@@ -423,12 +608,49 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 
 	level.Info(c.logger).Log("msg", "sync alertmanager", "key", key)
 
+	var conflicts []string
+	if generatesConfig(am) {
+		conflicts, err = c.provisionAlertmanagerConfiguration(ctx, am)
+		if err != nil {
+			err = errors.Wrap(err, "provisioning alertmanager configuration failed")
+		}
+	}
+	if err == nil && am.Spec.ClusterTLS != nil {
+		if err = c.provisionClusterTLSConfig(ctx, am); err != nil {
+			err = errors.Wrap(err, "provisioning cluster TLS configuration failed")
+		}
+	}
+	if err == nil && am.Spec.Web != nil {
+		if err = c.provisionWebConfig(ctx, am); err != nil {
+			err = errors.Wrap(err, "provisioning web configuration failed")
+		}
+	}
+	if err == nil && c.config.Mode != operator.ModeConfigOnly {
+		err = c.syncStatefulSet(ctx, key, am)
+	}
+	if sErr := c.updateStatus(ctx, am, err, conflicts); sErr != nil {
+		level.Warn(c.logger).Log("msg", "failed to update alertmanager status", "key", key, "err", sErr)
+	}
+
+	return err
+}
+
+// syncStatefulSet ensures that the governing service and the StatefulSet of
+// the Alertmanager cluster are up to date.
+func (c *Operator) syncStatefulSet(ctx context.Context, key string, am *monitoringv1.Alertmanager) error {
 	// Create governing service if it doesn't exist.
 	svcClient := c.kclient.CoreV1().Services(am.Namespace)
-	if err = k8sutil.CreateOrUpdateService(ctx, svcClient, makeStatefulSetService(am, c.config)); err != nil {
+	if err := k8sutil.CreateOrUpdateService(ctx, svcClient, makeStatefulSetService(am, c.config)); err != nil {
 		return errors.Wrap(err, "synchronizing governing service failed")
 	}
 
+	if am.Spec.ManageServiceAccount {
+		saClient := c.kclient.CoreV1().ServiceAccounts(am.Namespace)
+		if err := k8sutil.CreateOrUpdateServiceAccount(ctx, saClient, makeServiceAccount(am, c.config)); err != nil {
+			return errors.Wrap(err, "synchronizing service account failed")
+		}
+	}
+
 	ssetClient := c.kclient.AppsV1().StatefulSets(am.Namespace)
 	// Ensure we have a StatefulSet running Alertmanager deployed.
 	obj, err := c.ssetInfs.Get(alertmanagerKeyToStatefulSetKey(key))
@@ -446,6 +668,7 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 		if _, err := ssetClient.Create(ctx, sset, metav1.CreateOptions{}); err != nil {
 			return errors.Wrap(err, "creating statefulset failed")
 		}
+		c.metrics.GeneratedObjectCounter("statefulset", operator.OperationCreate).Inc()
 		return nil
 	}
 
@@ -455,6 +678,14 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 	}
 
 	operator.SanitizeSTS(sset)
+	c.metrics.GeneratedObjectCounter("statefulset", operator.OperationUpdate).Inc()
+
+	// The StatefulSet is recreated by the next reconciliation once the
+	// volumes are resized.
+	if resized, err := c.volumeResizer().Resize(ctx, am.Spec.Storage, obj.(*appsv1.StatefulSet), sset); err != nil || resized {
+		return err
+	}
+
 	_, err = ssetClient.Update(ctx, sset, metav1.UpdateOptions{})
 	sErr, ok := err.(*apierrors.StatusError)
 
@@ -489,6 +720,54 @@ func checkAlertmanagerSpecDeprecation(key string, a *monitoringv1.Alertmanager,
 	}
 }
 
+// updateStatus updates the status subresource of the Alertmanager object
+// with the current state of its pods and the outcome of the last
+// reconciliation. The configuration conflicts are reported when the operator
+// manages part of the configuration.
+func (c *Operator) updateStatus(ctx context.Context, am *monitoringv1.Alertmanager, reconcileErr error, conflicts []string) error {
+	status, _, err := AlertmanagerStatus(ctx, c.kclient, am)
+	if err != nil {
+		if !apierrors.IsNotFound(errors.Cause(err)) {
+			return err
+		}
+		// The StatefulSet doesn't exist when the reconciliation failed
+		// before creating it or in config-only mode.
+		status = &monitoringv1.AlertmanagerStatus{Paused: am.Spec.Paused}
+	}
+
+	desired := minReplicas
+	if am.Spec.Replicas != nil {
+		desired = *am.Spec.Replicas
+	}
+	// The operator doesn't deploy any pod in config-only mode.
+	if c.config.Mode == operator.ModeConfigOnly {
+		desired = 0
+	}
+
+	var current []monitoringv1.Condition
+	if am.Status != nil {
+		current = am.Status.Conditions
+	}
+	status.Selector = ListOptions(am.Name).LabelSelector
+	status.Conditions = operator.MakeConditions(am.Generation, desired, status.AvailableReplicas, reconcileErr, current)
+	if am.Spec.AlertmanagerConfiguration != nil && reconcileErr == nil {
+		status.Conditions = append(status.Conditions, makeConfigurationMergedCondition(am, conflicts, current))
+	}
+
+	// Skip the update when nothing changed to avoid triggering another
+	// reconciliation.
+	if reflect.DeepEqual(am.Status, status) {
+		return nil
+	}
+
+	am.Status = status
+	if _, err := c.mclient.MonitoringV1().Alertmanagers(am.Namespace).UpdateStatus(ctx, am, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update status subresource")
+	}
+
+	return nil
+}
+
 func ListOptions(name string) metav1.ListOptions {
 	return metav1.ListOptions{
 		LabelSelector: fields.SelectorFromSet(fields.Set(map[string]string{
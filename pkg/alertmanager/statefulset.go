@@ -16,9 +16,12 @@ package alertmanager
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
@@ -31,6 +34,7 @@ import (
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
 )
 
 const (
@@ -40,13 +44,20 @@ const (
 	configmapsDir          = "/etc/alertmanager/configmaps/"
 	alertmanagerConfDir    = "/etc/alertmanager/config"
 	alertmanagerConfFile   = alertmanagerConfDir + "/alertmanager.yaml"
+	templatesDir           = "/etc/alertmanager/templates"
+	webConfigDir           = "/etc/alertmanager/web_config"
 	alertmanagerStorageDir = "/alertmanager"
 	defaultPortName        = "web"
 )
 
 var (
-	minReplicas         int32 = 1
-	probeTimeoutSeconds int32 = 3
+	minReplicas                 int32 = 1
+	probeTimeoutSeconds         int32 = 3
+	managedByOperatorLabel            = "managed-by"
+	managedByOperatorLabelValue       = "prometheus-operator"
+	managedByOperatorLabels           = map[string]string{
+		managedByOperatorLabel: managedByOperatorLabelValue,
+	}
 )
 
 func makeStatefulSet(am *monitoringv1.Alertmanager, old *appsv1.StatefulSet, config Config) (*appsv1.StatefulSet, error) {
@@ -153,9 +164,41 @@ func makeStatefulSet(am *monitoringv1.Alertmanager, old *appsv1.StatefulSet, con
 		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, volume)
 	}
 
+	statefulset, err = k8sutil.PatchStatefulSet(statefulset, am.Spec.StatefulSetPatch)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to apply statefulset patch")
+	}
+
 	return statefulset, nil
 }
 
+// serviceAccountName returns the name of the ServiceAccount used to run the
+// Alertmanager Pods.
+func serviceAccountName(a *monitoringv1.Alertmanager) string {
+	if a.Spec.ManageServiceAccount && a.Spec.ServiceAccountName == "" {
+		return prefixedName(a.Name)
+	}
+	return a.Spec.ServiceAccountName
+}
+
+func makeServiceAccount(a *monitoringv1.Alertmanager, config Config) *v1.ServiceAccount {
+	return &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceAccountName(a),
+			Labels:      config.Labels.Merge(a.Spec.ServiceAccountLabels),
+			Annotations: a.Spec.ServiceAccountAnnotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Name:       a.GetName(),
+					Kind:       a.Kind,
+					APIVersion: a.APIVersion,
+					UID:        a.GetUID(),
+				},
+			},
+		},
+	}
+}
+
 func makeStatefulSetService(p *monitoringv1.Alertmanager, config Config) *v1.Service {
 
 	if p.Spec.PortName == "" {
@@ -207,6 +250,80 @@ func makeStatefulSetService(p *monitoringv1.Alertmanager, config Config) *v1.Ser
 	return svc
 }
 
+// clusterPeerAddress returns the host:port address of an external cluster
+// peer.
+func clusterPeerAddress(peer monitoringv1.ClusterPeer) (string, error) {
+	switch {
+	case peer.Address != "" && peer.Service != nil:
+		return "", errors.New("address and service are mutually exclusive")
+	case peer.Address != "":
+		if _, _, err := net.SplitHostPort(peer.Address); err != nil {
+			return "", errors.Wrapf(err, "invalid address %q", peer.Address)
+		}
+		return peer.Address, nil
+	case peer.Service != nil:
+		svc := peer.Service
+		if svc.Name == "" || svc.Namespace == "" || svc.ClusterDomain == "" {
+			return "", errors.New("service requires name, namespace and clusterDomain")
+		}
+		port := int32(9094)
+		if svc.Port != nil {
+			port = *svc.Port
+		}
+		host := fmt.Sprintf("%s.%s.svc.%s", svc.Name, svc.Namespace, strings.TrimSuffix(svc.ClusterDomain, "."))
+		return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+	default:
+		return "", errors.New("one of address or service is required")
+	}
+}
+
+// clusterEnabled returns whether the Alertmanager replicas run in cluster
+// mode. A single replica runs without clustering unless it is forced or
+// external peers are defined.
+func clusterEnabled(a *monitoringv1.Alertmanager) bool {
+	return *a.Spec.Replicas != 1 || a.Spec.ForceEnableClusterMode || len(a.Spec.ClusterPeers) > 0
+}
+
+// checkClusterTimings returns an error if the gossip timings of the cluster
+// are invalid.
+func checkClusterTimings(a *monitoringv1.Alertmanager) error {
+	timings := []struct {
+		field string
+		value string
+		d     time.Duration
+	}{
+		{field: "clusterGossipInterval", value: a.Spec.ClusterGossipInterval},
+		{field: "clusterPushPullInterval", value: a.Spec.ClusterPushPullInterval},
+		{field: "clusterPeerTimeout", value: a.Spec.ClusterPeerTimeout},
+	}
+
+	for i := range timings {
+		t := &timings[i]
+		if t.value == "" {
+			continue
+		}
+		if !clusterEnabled(a) {
+			return errors.Errorf("%s requires the cluster mode which is disabled for a single replica, set forceEnableClusterMode to enable it", t.field)
+		}
+
+		d, err := time.ParseDuration(t.value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s %q", t.field, t.value)
+		}
+		if d <= 0 {
+			return errors.Errorf("%s %q must be positive", t.field, t.value)
+		}
+		t.d = d
+	}
+
+	gossip, pushPull := timings[0], timings[1]
+	if gossip.d > 0 && pushPull.d > 0 && gossip.d >= pushPull.d {
+		return errors.Errorf("clusterGossipInterval %q must be lower than clusterPushPullInterval %q", gossip.value, pushPull.value)
+	}
+
+	return nil
+}
+
 func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.StatefulSetSpec, error) {
 	// Before editing 'a' create deep copy, to prevent side effects. For more
 	// details see https://github.com/prometheus-operator/prometheus-operator/issues/1659
@@ -229,16 +346,20 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 		return nil, errors.Wrap(err, "failed to parse alertmanager version")
 	}
 
+	if err := checkClusterTimings(a); err != nil {
+		return nil, errors.Wrap(err, "invalid cluster settings")
+	}
+
 	amArgs := []string{
 		fmt.Sprintf("--config.file=%s", alertmanagerConfFile),
 		fmt.Sprintf("--storage.path=%s", alertmanagerStorageDir),
 		fmt.Sprintf("--data.retention=%s", a.Spec.Retention),
 	}
 
-	if *a.Spec.Replicas == 1 && !a.Spec.ForceEnableClusterMode {
-		amArgs = append(amArgs, "--cluster.listen-address=")
-	} else {
+	if clusterEnabled(a) {
 		amArgs = append(amArgs, "--cluster.listen-address=[$(POD_IP)]:9094")
+	} else {
+		amArgs = append(amArgs, "--cluster.listen-address=")
 	}
 
 	if a.Spec.ListenLocal {
@@ -271,26 +392,29 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 		amArgs = append(amArgs, fmt.Sprintf("--cluster.advertise-address=%s", a.Spec.ClusterAdvertiseAddress))
 	}
 
-	localReloadURL := &url.URL{
-		Scheme: "http",
-		Host:   config.LocalHost + ":9093",
-		Path:   path.Clean(webRoutePrefix + "/-/reload"),
+	clusterTLS := a.Spec.ClusterTLS != nil && version.GTE(semver.MustParse("0.24.0"))
+	if clusterTLS {
+		amArgs = append(amArgs, fmt.Sprintf("--cluster.tls-config=%s", path.Join(clusterTLSConfigDir, clusterTLSConfigFile)))
 	}
 
-	livenessProbeHandler := v1.Handler{
-		HTTPGet: &v1.HTTPGetAction{
-			Path: path.Clean(webRoutePrefix + "/-/healthy"),
-			Port: intstr.FromString(a.Spec.PortName),
-		},
+	// web holds the web settings applied to the Alertmanager version.
+	var web *monitoringv1.WebSpec
+	if version.GTE(semver.MustParse("0.22.0")) {
+		web = a.Spec.Web
+	}
+	if web != nil {
+		amArgs = append(amArgs, fmt.Sprintf("--web.config.file=%s", path.Join(webConfigDir, webconfig.ConfigFile)))
 	}
 
-	readinessProbeHandler := v1.Handler{
-		HTTPGet: &v1.HTTPGetAction{
-			Path: path.Clean(webRoutePrefix + "/-/ready"),
-			Port: intstr.FromString(a.Spec.PortName),
-		},
+	localReloadURL := &url.URL{
+		Scheme: webconfig.Scheme(web),
+		Host:   config.LocalHost + ":9093",
+		Path:   path.Clean(webRoutePrefix + "/-/reload"),
 	}
 
+	livenessProbeHandler := webconfig.ProbeHandler(web, path.Clean(webRoutePrefix+"/-/healthy"), intstr.FromString(a.Spec.PortName))
+	readinessProbeHandler := webconfig.ProbeHandler(web, path.Clean(webRoutePrefix+"/-/ready"), intstr.FromString(a.Spec.PortName))
+
 	var livenessProbe *v1.Probe
 	var readinessProbe *v1.Probe
 	if !a.Spec.ListenLocal {
@@ -346,6 +470,14 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 		amArgs = append(amArgs, fmt.Sprintf("--cluster.peer=%s", peer))
 	}
 
+	for i, peer := range a.Spec.ClusterPeers {
+		addr, err := clusterPeerAddress(peer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "clusterPeers[%d]", i)
+		}
+		amArgs = append(amArgs, fmt.Sprintf("--cluster.peer=%s", addr))
+	}
+
 	ports := []v1.ContainerPort{
 		{
 			Name:          "mesh-tcp",
@@ -390,6 +522,16 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 			// quickly remove a cluster member after its pod restarted or during a
 			// regular rolling update.
 			amArgs = append(amArgs, "--cluster.reconnect-timeout=5m")
+
+			if a.Spec.ClusterGossipInterval != "" {
+				amArgs = append(amArgs, fmt.Sprintf("--cluster.gossip-interval=%s", a.Spec.ClusterGossipInterval))
+			}
+			if a.Spec.ClusterPushPullInterval != "" {
+				amArgs = append(amArgs, fmt.Sprintf("--cluster.pushpull-interval=%s", a.Spec.ClusterPushPullInterval))
+			}
+			if a.Spec.ClusterPeerTimeout != "" {
+				amArgs = append(amArgs, fmt.Sprintf("--cluster.peer-timeout=%s", a.Spec.ClusterPeerTimeout))
+			}
 		}
 		if version.Minor < 13 {
 			for i := range amArgs {
@@ -407,12 +549,19 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 		return nil, errors.Errorf("unsupported Alertmanager major version %s", version)
 	}
 
+	// The merged configuration is mounted instead of the base configuration
+	// when the operator manages part of the configuration.
+	configSecret := a.Spec.ConfigSecret
+	if generatesConfig(a) {
+		configSecret = generatedConfigSecretName(a.Name)
+	}
+
 	volumes := []v1.Volume{
 		{
 			Name: "config-volume",
 			VolumeSource: v1.VolumeSource{
 				Secret: &v1.SecretVolumeSource{
-					SecretName: a.Spec.ConfigSecret,
+					SecretName: configSecret,
 				},
 			},
 		},
@@ -437,6 +586,28 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 		},
 	}
 
+	if clusterTLS {
+		volumes = append(volumes, v1.Volume{
+			Name: "cluster-tls-config",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: clusterTLSConfigSecretName(a.Name),
+				},
+			},
+		})
+		amVolumeMounts = append(amVolumeMounts, v1.VolumeMount{
+			Name:      "cluster-tls-config",
+			ReadOnly:  true,
+			MountPath: clusterTLSConfigDir,
+		})
+	}
+
+	if web != nil {
+		vol, mount := webconfig.Volume(webConfigSecretName(a.Name), webConfigDir)
+		volumes = append(volumes, vol)
+		amVolumeMounts = append(amVolumeMounts, mount)
+	}
+
 	reloadWatchDirs := []string{alertmanagerConfDir}
 	configReloaderVolumeMounts := []v1.VolumeMount{
 		{
@@ -488,6 +659,48 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 		reloadWatchDirs = append(reloadWatchDirs, mountPath)
 	}
 
+	if len(a.Spec.Templates) > 0 {
+		var sources []v1.VolumeProjection
+		for _, t := range a.Spec.Templates {
+			if t.Secret != nil {
+				sources = append(sources, v1.VolumeProjection{
+					Secret: &v1.SecretProjection{
+						LocalObjectReference: t.Secret.LocalObjectReference,
+						Items:                []v1.KeyToPath{{Key: t.Secret.Key, Path: templateFilename(t)}},
+						Optional:             t.Secret.Optional,
+					},
+				})
+				continue
+			}
+			if t.ConfigMap != nil {
+				sources = append(sources, v1.VolumeProjection{
+					ConfigMap: &v1.ConfigMapProjection{
+						LocalObjectReference: t.ConfigMap.LocalObjectReference,
+						Items:                []v1.KeyToPath{{Key: t.ConfigMap.Key, Path: templateFilename(t)}},
+						Optional:             t.ConfigMap.Optional,
+					},
+				})
+			}
+		}
+
+		volumes = append(volumes, v1.Volume{
+			Name: "templates",
+			VolumeSource: v1.VolumeSource{
+				Projected: &v1.ProjectedVolumeSource{
+					Sources: sources,
+				},
+			},
+		})
+		mount := v1.VolumeMount{
+			Name:      "templates",
+			ReadOnly:  true,
+			MountPath: templatesDir,
+		}
+		amVolumeMounts = append(amVolumeMounts, mount)
+		configReloaderVolumeMounts = append(configReloaderVolumeMounts, mount)
+		reloadWatchDirs = append(reloadWatchDirs, templatesDir)
+	}
+
 	amVolumeMounts = append(amVolumeMounts, a.Spec.VolumeMounts...)
 
 	resources := v1.ResourceRequirements{Limits: v1.ResourceList{}}
@@ -536,6 +749,7 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 			Args:                     configReloaderArgs,
 			VolumeMounts:             configReloaderVolumeMounts,
 			Resources:                resources,
+			SecurityContext:          a.Spec.ConfigReloaderSecurityContext,
 			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 		},
 	}
@@ -565,11 +779,12 @@ func makeStatefulSetSpec(a *monitoringv1.Alertmanager, config Config) (*appsv1.S
 			Spec: v1.PodSpec{
 				NodeSelector:                  a.Spec.NodeSelector,
 				PriorityClassName:             a.Spec.PriorityClassName,
+				HostAliases:                   a.Spec.HostAliases,
 				TerminationGracePeriodSeconds: &terminationGracePeriod,
 				InitContainers:                a.Spec.InitContainers,
 				Containers:                    containers,
 				Volumes:                       volumes,
-				ServiceAccountName:            a.Spec.ServiceAccountName,
+				ServiceAccountName:            serviceAccountName(a),
 				SecurityContext:               a.Spec.SecurityContext,
 				Tolerations:                   a.Spec.Tolerations,
 				Affinity:                      a.Spec.Affinity,
@@ -582,6 +797,14 @@ func configSecretName(name string) string {
 	return prefixedName(name)
 }
 
+func generatedConfigSecretName(name string) string {
+	return fmt.Sprintf("%s-generated", prefixedName(name))
+}
+
+func webConfigSecretName(name string) string {
+	return fmt.Sprintf("%s-web-config", prefixedName(name))
+}
+
 func volumeName(name string) string {
 	return fmt.Sprintf("%s-db", prefixedName(name))
 }
@@ -129,6 +129,24 @@ func TestPodLabelsAnnotations(t *testing.T) {
 	}
 }
 
+func TestHostAliases(t *testing.T) {
+	hostAliases := []v1.HostAlias{
+		{
+			IP:        "10.0.0.1",
+			Hostnames: []string{"foo.example.com", "bar.example.com"},
+		},
+	}
+	sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
+		Spec: monitoringv1.AlertmanagerSpec{
+			HostAliases: hostAliases,
+		},
+	}, nil, defaultTestConfig)
+	require.NoError(t, err)
+	if !reflect.DeepEqual(hostAliases, sset.Spec.Template.Spec.HostAliases) {
+		t.Fatal("Host aliases are not properly propagated")
+	}
+}
+
 func TestPodLabelsShouldNotBeSelectorLabels(t *testing.T) {
 	labels := map[string]string{
 		"testlabel": "testvalue",
@@ -377,6 +395,103 @@ func TestMakeStatefulSetSpecPeersWithClusterDomain(t *testing.T) {
 	}
 }
 
+func TestMakeStatefulSetSpecClusterTimings(t *testing.T) {
+	replicas := int32(3)
+	a := monitoringv1.Alertmanager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "alertmanager",
+			Namespace: "monitoring",
+		},
+		Spec: monitoringv1.AlertmanagerSpec{
+			Version:                 "v0.21.0",
+			Replicas:                &replicas,
+			ClusterGossipInterval:   "100ms",
+			ClusterPushPullInterval: "30s",
+			ClusterPeerTimeout:      "5s",
+		},
+	}
+
+	statefulSet, err := makeStatefulSetSpec(&a, defaultTestConfig)
+	require.NoError(t, err)
+
+	require.Subset(t, statefulSet.Template.Spec.Containers[0].Args, []string{
+		"--cluster.gossip-interval=100ms",
+		"--cluster.pushpull-interval=30s",
+		"--cluster.peer-timeout=5s",
+	})
+}
+
+func TestCheckClusterTimings(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		replicas int32
+		force    bool
+		gossip   string
+		pushPull string
+		peer     string
+		expected bool
+	}{
+		{
+			name:     "no timings",
+			replicas: 1,
+		},
+		{
+			name:     "valid timings",
+			replicas: 3,
+			gossip:   "100ms",
+			pushPull: "30s",
+			peer:     "5s",
+		},
+		{
+			name:     "single replica",
+			replicas: 1,
+			peer:     "5s",
+			expected: true,
+		},
+		{
+			name:     "single replica with forced cluster mode",
+			replicas: 1,
+			force:    true,
+			peer:     "5s",
+		},
+		{
+			name:     "invalid duration",
+			replicas: 3,
+			peer:     "5",
+			expected: true,
+		},
+		{
+			name:     "negative duration",
+			replicas: 3,
+			gossip:   "-1s",
+			expected: true,
+		},
+		{
+			name:     "gossip interval greater than push-pull interval",
+			replicas: 3,
+			gossip:   "1m",
+			pushPull: "30s",
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &monitoringv1.Alertmanager{
+				Spec: monitoringv1.AlertmanagerSpec{
+					Replicas:                &tc.replicas,
+					ForceEnableClusterMode:  tc.force,
+					ClusterGossipInterval:   tc.gossip,
+					ClusterPushPullInterval: tc.pushPull,
+					ClusterPeerTimeout:      tc.peer,
+				},
+			}
+			err := checkClusterTimings(a)
+			if tc.expected != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
 func TestMakeStatefulSetSpecAdditionalPeers(t *testing.T) {
 	a := monitoringv1.Alertmanager{}
 	a.Spec.Version = "v0.15.3"
@@ -402,6 +517,43 @@ func TestMakeStatefulSetSpecAdditionalPeers(t *testing.T) {
 	}
 }
 
+func TestMakeStatefulSetSpecClusterPeers(t *testing.T) {
+	port := int32(19094)
+	a := monitoringv1.Alertmanager{}
+	a.Spec.Version = operator.DefaultAlertmanagerVersion
+	replicas := int32(1)
+	a.Spec.Replicas = &replicas
+	a.Spec.ClusterPeers = []monitoringv1.ClusterPeer{
+		{Address: "alertmanager.eu.example.com:9094"},
+		{Service: &monitoringv1.ClusterPeerService{Name: "alertmanager-operated", Namespace: "monitoring", ClusterDomain: "us.example.com."}},
+		{Service: &monitoringv1.ClusterPeerService{Name: "alertmanager-operated", Namespace: "monitoring", ClusterDomain: "ap.example.com", Port: &port}},
+	}
+
+	statefulSet, err := makeStatefulSetSpec(&a, defaultTestConfig)
+	require.NoError(t, err)
+
+	amArgs := statefulSet.Template.Spec.Containers[0].Args
+	for _, expected := range []string{
+		"--cluster.listen-address=[$(POD_IP)]:9094",
+		"--cluster.peer=alertmanager.eu.example.com:9094",
+		"--cluster.peer=alertmanager-operated.monitoring.svc.us.example.com:9094",
+		"--cluster.peer=alertmanager-operated.monitoring.svc.ap.example.com:19094",
+	} {
+		require.Contains(t, amArgs, expected)
+	}
+
+	for _, peer := range []monitoringv1.ClusterPeer{
+		{},
+		{Address: "alertmanager.eu.example.com"},
+		{Address: "alertmanager.eu.example.com:9094", Service: &monitoringv1.ClusterPeerService{}},
+		{Service: &monitoringv1.ClusterPeerService{Name: "alertmanager-operated"}},
+	} {
+		a.Spec.ClusterPeers = []monitoringv1.ClusterPeer{peer}
+		_, err := makeStatefulSetSpec(&a, defaultTestConfig)
+		require.Error(t, err, "peer %+v", peer)
+	}
+}
+
 func TestAdditionalSecretsMounted(t *testing.T) {
 	secrets := []string{"secret1", "secret2"}
 	sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
@@ -724,3 +876,254 @@ func TestClusterListenAddressForMultiReplica(t *testing.T) {
 		t.Fatal("expected stateful set to contain arg '--cluster.listen-address=[$(POD_IP)]:9094'")
 	}
 }
+
+func TestGeneratedConfigSecretMounted(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     monitoringv1.AlertmanagerSpec
+		expected string
+	}{
+		{
+			name:     "default",
+			expected: "alertmanager-test",
+		},
+		{
+			name: "custom config secret",
+			spec: monitoringv1.AlertmanagerSpec{
+				ConfigSecret: "custom",
+			},
+			expected: "custom",
+		},
+		{
+			name: "operator-managed configuration",
+			spec: monitoringv1.AlertmanagerSpec{
+				ConfigSecret:              "custom",
+				AlertmanagerConfiguration: &monitoringv1.AlertmanagerConfiguration{},
+			},
+			expected: "alertmanager-test-generated",
+		},
+		{
+			name: "templates",
+			spec: monitoringv1.AlertmanagerSpec{
+				Templates: []monitoringv1.SecretOrConfigMap{
+					{
+						ConfigMap: &v1.ConfigMapKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "templates"},
+							Key:                  "default.tmpl",
+						},
+					},
+				},
+			},
+			expected: "alertmanager-test-generated",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: tc.spec,
+			}, nil, defaultTestConfig)
+			require.NoError(t, err)
+
+			for _, v := range sset.Spec.Template.Spec.Volumes {
+				if v.Name == "config-volume" {
+					require.Equal(t, tc.expected, v.Secret.SecretName)
+					return
+				}
+			}
+			t.Fatal("config volume not found")
+		})
+	}
+}
+
+func TestTemplatesVolume(t *testing.T) {
+	sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.AlertmanagerSpec{
+			Templates: []monitoringv1.SecretOrConfigMap{
+				{
+					ConfigMap: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "templates"},
+						Key:                  "default.tmpl",
+					},
+				},
+				{
+					Secret: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "templates"},
+						Key:                  "default.tmpl",
+					},
+				},
+			},
+		},
+	}, nil, defaultTestConfig)
+	require.NoError(t, err)
+
+	var vol *v1.Volume
+	for i, v := range sset.Spec.Template.Spec.Volumes {
+		if v.Name == "templates" {
+			vol = &sset.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	require.NotNil(t, vol, "templates volume not found")
+	require.Len(t, vol.Projected.Sources, 2)
+	require.Equal(t, "configmap_templates_default.tmpl", vol.Projected.Sources[0].ConfigMap.Items[0].Path)
+	require.Equal(t, "secret_templates_default.tmpl", vol.Projected.Sources[1].Secret.Items[0].Path)
+
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		found := false
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == "templates" && vm.MountPath == templatesDir {
+				found = true
+			}
+		}
+		require.True(t, found, "templates volume not mounted in container %s", c.Name)
+
+		if c.Name == "config-reloader" {
+			require.Contains(t, c.Args, "-volume-dir="+templatesDir)
+		}
+	}
+}
+
+func TestClusterTLSConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		expected bool
+	}{
+		{
+			name:     "supported version",
+			version:  "v0.24.0",
+			expected: true,
+		},
+		{
+			name:    "unsupported version",
+			version: "v0.23.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: monitoringv1.AlertmanagerSpec{
+					Version:    tc.version,
+					ClusterTLS: &monitoringv1.ClusterTLSConfig{},
+				},
+			}, nil, defaultTestConfig)
+			require.NoError(t, err)
+
+			var foundArg, foundVolume, foundMount bool
+			for _, arg := range sset.Spec.Template.Spec.Containers[0].Args {
+				if arg == "--cluster.tls-config=/etc/alertmanager/cluster_tls_config/cluster-tls-config.yaml" {
+					foundArg = true
+				}
+			}
+			for _, v := range sset.Spec.Template.Spec.Volumes {
+				if v.Name == "cluster-tls-config" && v.Secret.SecretName == "alertmanager-test-cluster-tls-config" {
+					foundVolume = true
+				}
+			}
+			for _, m := range sset.Spec.Template.Spec.Containers[0].VolumeMounts {
+				if m.Name == "cluster-tls-config" && m.MountPath == "/etc/alertmanager/cluster_tls_config" {
+					foundMount = true
+				}
+			}
+
+			require.Equal(t, tc.expected, foundArg, "unexpected --cluster.tls-config argument")
+			require.Equal(t, tc.expected, foundVolume, "unexpected cluster TLS volume")
+			require.Equal(t, tc.expected, foundMount, "unexpected cluster TLS volume mount")
+		})
+	}
+}
+
+func TestConfigReloaderSecurityContext(t *testing.T) {
+	boolTrue := true
+	sc := &v1.SecurityContext{ReadOnlyRootFilesystem: &boolTrue}
+
+	sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
+		Spec: monitoringv1.AlertmanagerSpec{
+			ConfigReloaderSecurityContext: sc,
+		},
+	}, nil, defaultTestConfig)
+	if err != nil {
+		t.Fatalf("Unexpected error while making StatefulSet: %v", err)
+	}
+
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		var expected *v1.SecurityContext
+		if c.Name == "config-reloader" {
+			expected = sc
+		}
+		if !reflect.DeepEqual(c.SecurityContext, expected) {
+			t.Fatalf("expected security context %v for container %q, got %v", expected, c.Name, c.SecurityContext)
+		}
+	}
+}
+
+func TestWebConfig(t *testing.T) {
+	web := &monitoringv1.WebSpec{
+		TLSConfig: &monitoringv1.WebTLSConfig{
+			Cert: monitoringv1.SecretOrConfigMap{
+				Secret: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "web-tls"},
+					Key:                  "tls.crt",
+				},
+			},
+			KeySecret: v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "web-tls"},
+				Key:                  "tls.key",
+			},
+		},
+	}
+	arg := "--web.config.file=/etc/alertmanager/web_config/web-config.yaml"
+
+	for _, tc := range []struct {
+		version  string
+		expected bool
+	}{
+		{version: "v0.21.0"},
+		{version: "v0.22.0", expected: true},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			sset, err := makeStatefulSet(&monitoringv1.Alertmanager{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: monitoringv1.AlertmanagerSpec{
+					Version: tc.version,
+					Web:     web,
+				},
+			}, nil, defaultTestConfig)
+			require.NoError(t, err)
+
+			am := sset.Spec.Template.Spec.Containers[0]
+			if !tc.expected {
+				require.NotContains(t, am.Args, arg)
+				require.Equal(t, v1.URIScheme(""), am.ReadinessProbe.HTTPGet.Scheme)
+				return
+			}
+
+			require.Contains(t, am.Args, arg)
+			require.Equal(t, v1.URISchemeHTTPS, am.ReadinessProbe.HTTPGet.Scheme)
+			require.Equal(t, v1.URISchemeHTTPS, am.LivenessProbe.HTTPGet.Scheme)
+
+			var found bool
+			for _, vol := range sset.Spec.Template.Spec.Volumes {
+				if vol.Name == "web-config" {
+					found = true
+					require.Equal(t, "alertmanager-test-web-config", vol.Secret.SecretName)
+				}
+			}
+			require.True(t, found, "web-config volume not found")
+
+			for _, c := range sset.Spec.Template.Spec.Containers {
+				if c.Name == "config-reloader" {
+					require.Contains(t, c.Args, "-webhook-url=https://:9093/-/reload")
+				}
+			}
+		})
+	}
+}
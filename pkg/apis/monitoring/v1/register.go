@@ -49,12 +49,16 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Prometheus{},
 		&PrometheusList{},
+		&PrometheusAgent{},
+		&PrometheusAgentList{},
 		&ServiceMonitor{},
 		&ServiceMonitorList{},
 		&PodMonitor{},
 		&PodMonitorList{},
 		&Probe{},
 		&ProbeList{},
+		&NodeMonitor{},
+		&NodeMonitorList{},
 		&Alertmanager{},
 		&AlertmanagerList{},
 		&PrometheusRule{},
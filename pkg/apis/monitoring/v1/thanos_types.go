@@ -29,15 +29,16 @@ const (
 // ThanosRuler defines a ThanosRuler deployment.
 // +genclient
 // +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 type ThanosRuler struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of the desired behavior of the ThanosRuler cluster. More info:
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 	Spec ThanosRulerSpec `json:"spec"`
-	// Most recent observed status of the ThanosRuler cluster. Read-only. Not
-	// included when requesting from the apiserver, only from the ThanosRuler
-	// Operator API itself. More info:
+	// Most recent observed status of the ThanosRuler cluster. Read-only. Updated
+	// by the operator through the status subresource. More info:
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 	Status *ThanosRulerStatus `json:"status,omitempty"`
 }
@@ -82,11 +83,31 @@ type ThanosRulerSpec struct {
 	// SecurityContext holds pod-level security attributes and common container settings.
 	// This defaults to the default PodSecurityContext.
 	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// RulesConfigmapReloaderSecurityContext defines the security context of
+	// the `rules-configmap-reloader` container. It takes precedence over the
+	// settings of the pod security context.
+	RulesConfigmapReloaderSecurityContext *v1.SecurityContext `json:"rulesConfigmapReloaderSecurityContext,omitempty"`
 	// Priority class assigned to the Pods
 	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// HostAliases are the entries added to the hosts file of the Pods, for
+	// instance to resolve names which aren't resolvable by the cluster DNS.
+	HostAliases []v1.HostAlias `json:"hostAliases,omitempty"`
 	// ServiceAccountName is the name of the ServiceAccount to use to run the
 	// Thanos Ruler Pods.
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ManageServiceAccount instructs the operator to create the ServiceAccount
+	// used to run the Thanos Ruler Pods and to keep its labels and annotations up
+	// to date. The ServiceAccount is named after serviceAccountName or
+	// defaults to `thanos-ruler-<name>` if empty.
+	ManageServiceAccount bool `json:"manageServiceAccount,omitempty"`
+	// ServiceAccountAnnotations are added to the managed ServiceAccount, for
+	// instance to bind it to a cloud provider identity (e.g. GKE Workload
+	// Identity or EKS IAM roles for service accounts).
+	// Only used when manageServiceAccount is true.
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
+	// ServiceAccountLabels are added to the managed ServiceAccount.
+	// Only used when manageServiceAccount is true.
+	ServiceAccountLabels map[string]string `json:"serviceAccountLabels,omitempty"`
 	// Storage spec to specify how storage shall be used.
 	Storage *StorageSpec `json:"storage,omitempty"`
 	// Volumes allows configuration of additional volumes on the output StatefulSet definition. Volumes specified will
@@ -97,6 +118,14 @@ type ThanosRulerSpec struct {
 	// ListenLocal makes the Thanos ruler listen on loopback, so that it
 	// does not bind against the Pod IP.
 	ListenLocal bool `json:"listenLocal,omitempty"`
+	// Web defines the TLS, HTTP and basic authentication settings of the
+	// web server, passed to the --http.config flag. Only valid in Thanos
+	// versions 0.22.0 and newer.
+	Web *WebSpec `json:"web,omitempty"`
+	// ExportDatasource makes the operator write the `thanos-ruler-<name>-datasource`
+	// ConfigMap describing the Grafana datasource of the Thanos ruler, so that
+	// Grafana can provision it. The ConfigMap is deleted when unset.
+	ExportDatasource *DatasourceExportSpec `json:"exportDatasource,omitempty"`
 	// QueryEndpoints defines Thanos querier endpoints from which to query metrics.
 	// Maps to the --query flag of thanos ruler.
 	QueryEndpoints []string `json:"queryEndpoints,omitempty"`
@@ -105,6 +134,13 @@ type ThanosRulerSpec struct {
 	// Maps to the `query.config` CLI argument.
 	// Only available with thanos v0.11.0 and higher.
 	QueryConfig *v1.SecretKeySelector `json:"queryConfig,omitempty"`
+	// QueryEndpointConfigs defines the Thanos querier endpoints along with
+	// their HTTP settings. The configuration is validated by the operator
+	// and rendered to the `query.config` CLI argument. Mutually exclusive
+	// with QueryConfig. If this is defined, the QueryEndpoints field will be
+	// ignored.
+	// Only available with thanos v0.11.0 and higher.
+	QueryEndpointConfigs []ThanosQueryEndpointConfig `json:"queryEndpointConfigs,omitempty"`
 	// Define URLs to send alerts to Alertmanager.  For Thanos v0.10.0 and higher,
 	// AlertManagersConfig should be used instead.  Note: this field will be ignored
 	// if AlertManagersConfig is specified.
@@ -113,6 +149,11 @@ type ThanosRulerSpec struct {
 	// Define configuration for connecting to alertmanager.  Only available with thanos v0.10.0
 	// and higher.  Maps to the `alertmanagers.config` arg.
 	AlertManagersConfig *v1.SecretKeySelector `json:"alertmanagersConfig,omitempty"`
+	// AlertRelabelConfigs defines the relabeling rules applied to the alerts
+	// before they are sent to the Alertmanagers. The rules are validated by
+	// the operator and rendered to the `alert.relabel-config` CLI argument.
+	// Only available with thanos v0.11.0 and higher.
+	AlertRelabelConfigs []*RelabelConfig `json:"alertRelabelConfigs,omitempty"`
 	// A label selector to select which PrometheusRules to mount for alerting and
 	// recording.
 	RuleSelector *metav1.LabelSelector `json:"ruleSelector,omitempty"`
@@ -136,6 +177,29 @@ type ThanosRulerSpec struct {
 	PortName string `json:"portName,omitempty"`
 	// Interval between consecutive evaluations.
 	EvaluationInterval string `json:"evaluationInterval,omitempty"`
+	// RuleConcurrentEval is the number of rules which can be evaluated
+	// concurrently. It requires a Thanos image supporting the
+	// `--rule-concurrent-evaluation` flag, which isn't the case of the
+	// default image.
+	// +kubebuilder:validation:Minimum=1
+	RuleConcurrentEval *int32 `json:"ruleConcurrentEval,omitempty"`
+	// ForOutageTolerance is the maximum duration of the outage of
+	// ThanosRuler during which the `for` state of the alerts is restored.
+	// Maps to the '--for-outage-tolerance' CLI arg.
+	ForOutageTolerance string `json:"forOutageTolerance,omitempty"`
+	// ForGracePeriod is the minimum duration between the restart of
+	// ThanosRuler and the firing of an alert whose `for` duration is
+	// larger than the grace period.
+	// Maps to the '--for-grace-period' CLI arg.
+	ForGracePeriod string `json:"forGracePeriod,omitempty"`
+	// ResendDelay is the minimum duration to wait before resending an alert
+	// to Alertmanager.
+	// Maps to the '--resend-delay' CLI arg.
+	ResendDelay string `json:"resendDelay,omitempty"`
+	// RestoreIgnoredLabels are the labels ignored when restoring the alerts
+	// after a restart, e.g. labels whose value differs between replicas.
+	// Maps to the '--restore-ignored-label' CLI args.
+	RestoreIgnoredLabels []string `json:"restoreIgnoredLabels,omitempty"`
 	// Time duration ThanosRuler shall retain data for. Default is '24h',
 	// and must match the regular expression `[0-9]+(ms|s|m|h|d|w|y)` (milliseconds seconds minutes hours days weeks years).
 	Retention string `json:"retention,omitempty"`
@@ -155,6 +219,14 @@ type ThanosRulerSpec struct {
 	// of what the maintainers will support and by doing so, you accept that this behaviour may break
 	// at any time without notice.
 	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// StatefulSetPatch is a strategic merge patch applied to the StatefulSet
+	// generated by the operator for the ThanosRuler, as the final step of its
+	// generation. It allows setting fields which aren't exposed by the ThanosRuler
+	// resource (e.g. new Kubernetes fields). Patching the StatefulSet is entirely
+	// outside the scope of what the maintainers will support and by doing so, you
+	// accept that this behaviour may break at any time without notice.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	StatefulSetPatch *runtime.RawExtension `json:"statefulSetPatch,omitempty"`
 	// TracingConfig configures tracing in Thanos. This is an experimental feature, it may change in any upcoming release in a breaking way.
 	TracingConfig *v1.SecretKeySelector `json:"tracingConfig,omitempty"`
 	// Labels configure the external label pairs to ThanosRuler. If not provided, default replica label
@@ -180,9 +252,26 @@ type ThanosRulerSpec struct {
 	AlertQueryURL string `json:"alertQueryUrl,omitempty"`
 }
 
-// ThanosRulerStatus is the most recent observed status of the ThanosRuler. Read-only. Not
-// included when requesting from the apiserver, only from the Prometheus
-// Operator API itself. More info:
+// ThanosQueryEndpointConfig defines a set of Thanos querier endpoints sharing
+// the same HTTP settings.
+// +k8s:openapi-gen=true
+type ThanosQueryEndpointConfig struct {
+	// Addresses of the Thanos querier endpoints in the `host:port` format.
+	// +kubebuilder:validation:MinItems=1
+	StaticConfigs []string `json:"staticConfigs"`
+	// Scheme used to connect to the endpoints. Defaults to http.
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+	// PathPrefix is the prefix of the HTTP API of the endpoints.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// TLSConfig configures the connections to the endpoints.
+	// Note: Only the CAFile, CertFile, KeyFile, ServerName and
+	// InsecureSkipVerify fields are supported.
+	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
+}
+
+// ThanosRulerStatus is the most recent observed status of the ThanosRuler. Read-only.
+// Updated by the operator through the status subresource. More info:
 // https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 // +k8s:openapi-gen=true
 type ThanosRulerStatus struct {
@@ -200,6 +289,13 @@ type ThanosRulerStatus struct {
 	AvailableReplicas int32 `json:"availableReplicas"`
 	// Total number of unavailable pods targeted by this ThanosRuler deployment.
 	UnavailableReplicas int32 `json:"unavailableReplicas"`
+	// The label selector of the pods targeted by this ThanosRuler deployment,
+	// in string form. Used by the scale subresource.
+	Selector string `json:"selector,omitempty"`
+	// The current state of the ThanosRuler deployment.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
 // DeepCopyObject implements the runtime.Object interface.
@@ -15,7 +15,11 @@
 package v1
 
 import (
+	"strings"
+
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -47,6 +51,14 @@ const (
 	ProbesKind   = "Probe"
 	ProbeName    = "probes"
 	ProbeKindKey = "probe"
+
+	NodeMonitorsKind   = "NodeMonitor"
+	NodeMonitorName    = "nodemonitors"
+	NodeMonitorKindKey = "nodemonitor"
+
+	PrometheusAgentsKind   = "PrometheusAgent"
+	PrometheusAgentName    = "prometheusagents"
+	PrometheusAgentKindKey = "prometheusagent"
 )
 
 // Prometheus defines a Prometheus deployment.
@@ -55,15 +67,15 @@ const (
 // +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".spec.version",description="The version of Prometheus"
 // +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".spec.replicas",description="The desired replicas number of Prometheuses"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
 type Prometheus struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of the desired behavior of the Prometheus cluster. More info:
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 	Spec PrometheusSpec `json:"spec"`
-	// Most recent observed status of the Prometheus cluster. Read-only. Not
-	// included when requesting from the apiserver, only from the Prometheus
-	// Operator API itself. More info:
+	// Most recent observed status of the Prometheus cluster. Read-only. Updated
+	// by the operator through the status subresource. More info:
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 	Status *PrometheusStatus `json:"status,omitempty"`
 }
@@ -103,6 +115,11 @@ type PrometheusSpec struct {
 	ProbeSelector *metav1.LabelSelector `json:"probeSelector,omitempty"`
 	// *Experimental* Namespaces to be selected for Probe discovery. If nil, only check own namespace.
 	ProbeNamespaceSelector *metav1.LabelSelector `json:"probeNamespaceSelector,omitempty"`
+	// *Experimental* NodeMonitors to be selected for target discovery.
+	NodeMonitorSelector *metav1.LabelSelector `json:"nodeMonitorSelector,omitempty"`
+	// *Experimental* Namespaces to be selected for NodeMonitor discovery. If
+	// nil, only check own namespace.
+	NodeMonitorNamespaceSelector *metav1.LabelSelector `json:"nodeMonitorNamespaceSelector,omitempty"`
 	// Version of Prometheus to be deployed.
 	Version string `json:"version,omitempty"`
 	// Tag of Prometheus container image to be deployed. Defaults to the value of `version`.
@@ -159,6 +176,11 @@ type PrometheusSpec struct {
 	ScrapeInterval string `json:"scrapeInterval,omitempty"`
 	// Number of seconds to wait for target to respond before erroring.
 	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// ScrapeProtocols defines the protocols negotiated by Prometheus when
+	// scraping the targets, in order of preference. Monitors can override
+	// it for their endpoints.
+	// Only valid in Prometheus versions 2.49.0 and newer.
+	ScrapeProtocols []ScrapeProtocol `json:"scrapeProtocols,omitempty"`
 	// Interval between consecutive evaluations.
 	EvaluationInterval string `json:"evaluationInterval,omitempty"`
 	// /--rules.*/ command-line arguments.
@@ -202,15 +224,45 @@ type PrometheusSpec struct {
 	// Namespaces to be selected for PrometheusRules discovery. If unspecified, only
 	// the same namespace as the Prometheus object is in is used.
 	RuleNamespaceSelector *metav1.LabelSelector `json:"ruleNamespaceSelector,omitempty"`
+	// RemoteRuleFiles defines rule files maintained outside of the cluster
+	// which are periodically fetched over HTTP(S) by the operator and loaded
+	// in addition to the selected PrometheusRules. The files are validated
+	// and labeled like the PrometheusRules of the Prometheus namespace, the
+	// outcome is reported by the `RemoteRuleFilesSynced` status condition.
+	RemoteRuleFiles *RemoteRuleFiles `json:"remoteRuleFiles,omitempty"`
 	// Define details regarding alerting.
 	Alerting *AlertingSpec `json:"alerting,omitempty"`
 	// Define resources requests and limits for single Pods.
 	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+	// AutoResources adjusts the memory request of the Prometheus container
+	// to the number of series in the TSDB head, within the given bounds. It
+	// overrides the memory request defined by resources. The operator must
+	// run with the --auto-resources-interval flag to compute the
+	// recommendations, otherwise the memory request is only kept within the
+	// bounds.
+	AutoResources *AutoResources `json:"autoResources,omitempty"`
 	// Define which Nodes the Pods are scheduled on.
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 	// ServiceAccountName is the name of the ServiceAccount to use to run the
 	// Prometheus Pods.
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ManageServiceAccount instructs the operator to create the ServiceAccount
+	// used to run the Prometheus Pods and to keep its labels and annotations up
+	// to date. The ServiceAccount is named after serviceAccountName or
+	// defaults to `prometheus-<name>` if empty.
+	ManageServiceAccount bool `json:"manageServiceAccount,omitempty"`
+	// ServiceAccountAnnotations are added to the managed ServiceAccount, for
+	// instance to bind it to a cloud provider identity (e.g. GKE Workload
+	// Identity or EKS IAM roles for service accounts).
+	// Only used when manageServiceAccount is true.
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
+	// ServiceAccountLabels are added to the managed ServiceAccount.
+	// Only used when manageServiceAccount is true.
+	ServiceAccountLabels map[string]string `json:"serviceAccountLabels,omitempty"`
+	// BoundServiceAccountToken projects a short-lived token of the
+	// ServiceAccount into the Pods, rotated by the kubelet. Endpoints with
+	// bearerTokenBoundServiceAccount use it as bearer token.
+	BoundServiceAccountToken *BoundServiceAccountToken `json:"boundServiceAccountToken,omitempty"`
 	// Secrets is a list of Secrets in the same namespace as the Prometheus
 	// object, which shall be mounted into the Prometheus Pods.
 	// The Secrets are mounted into /etc/prometheus/secrets/<secret-name>.
@@ -223,16 +275,75 @@ type PrometheusSpec struct {
 	Affinity *v1.Affinity `json:"affinity,omitempty"`
 	// If specified, the pod's tolerations.
 	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// If specified, the pod's topology spread constraints. The label
+	// selector of a constraint defaults to the labels selecting the Pods of
+	// the Prometheus object.
+	TopologySpreadConstraints []TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
 	// If specified, the remote_write spec. This is an experimental feature, it may change in any upcoming release in a breaking way.
 	RemoteWrite []RemoteWriteSpec `json:"remoteWrite,omitempty"`
 	// If specified, the remote_read spec. This is an experimental feature, it may change in any upcoming release in a breaking way.
 	RemoteRead []RemoteReadSpec `json:"remoteRead,omitempty"`
+	// RemoteReadLimits limits the resources used by Prometheus when serving
+	// remote read requests. Only valid in Prometheus versions 2.5.0 and newer.
+	RemoteReadLimits *RemoteReadLimits `json:"remoteReadLimits,omitempty"`
+	// OTLP enables the OTLP receiver of Prometheus to ingest metrics pushed by
+	// OpenTelemetry collectors on the /api/v1/otlp/v1/metrics endpoint and
+	// defines its settings. Only valid in Prometheus versions 2.47.0 and newer.
+	OTLP *OTLPConfig `json:"otlp,omitempty"`
+	// Exemplars configures the storage of the exemplars. The exemplar
+	// storage must be enabled with the `exemplar-storage` feature for the
+	// settings to be effective. Only valid in Prometheus versions 2.29.0 and
+	// newer.
+	Exemplars *Exemplars `json:"exemplars,omitempty"`
+	// EnableFeatures enables the given Prometheus feature flags, passed to
+	// Prometheus with the `--enable-feature` flag. Feature flags are
+	// experimental and may change or break in any upcoming release of
+	// Prometheus. Only valid in Prometheus versions 2.25.0 and newer.
+	EnableFeatures []string `json:"enableFeatures,omitempty"`
 	// SecurityContext holds pod-level security attributes and common container settings.
 	// This defaults to the default PodSecurityContext.
 	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// ConfigReloaderSecurityContext defines the security context of the
+	// `prometheus-config-reloader` container. It takes precedence over the
+	// settings of the pod security context.
+	ConfigReloaderSecurityContext *v1.SecurityContext `json:"configReloaderSecurityContext,omitempty"`
+	// RulesConfigmapReloaderSecurityContext defines the security context of
+	// the `rules-configmap-reloader` container. It takes precedence over the
+	// settings of the pod security context.
+	RulesConfigmapReloaderSecurityContext *v1.SecurityContext `json:"rulesConfigmapReloaderSecurityContext,omitempty"`
+	// ReloadStrategy defines how the configuration changes are applied to
+	// Prometheus. With `ProcessSignal` (default), the
+	// `prometheus-config-reloader` and `rules-configmap-reloader` sidecars
+	// reload Prometheus when the mounted files change. With `HTTP`, the
+	// sidecars are removed and the operator sends a POST request to the
+	// `/-/reload` endpoint of the Pods through the governing service after
+	// updating the configuration. `HTTP` requires Prometheus 2.27.0 or newer
+	// and can't be used with ListenLocal.
+	// +kubebuilder:validation:Enum=ProcessSignal;HTTP
+	ReloadStrategy *ReloadStrategyType `json:"reloadStrategy,omitempty"`
 	// ListenLocal makes the Prometheus server listen on loopback, so that it
 	// does not bind against the Pod IP.
 	ListenLocal bool `json:"listenLocal,omitempty"`
+	// Web defines the TLS and HTTP settings of the web server. Only valid in
+	// Prometheus versions 2.24.0 and newer. TLS requires the `HTTP` reload
+	// strategy and can't be used with the Thanos sidecar, because the
+	// sidecars only support plain HTTP. The operator verifies the server
+	// certificate against the certificates of `tlsConfig.cert`, for the DNS
+	// names of the Pods behind the governing service. Basic authentication
+	// and required client certificates aren't supported, because neither
+	// the operator nor the sidecars can authenticate.
+	Web *WebSpec `json:"web,omitempty"`
+	// ExportDatasource makes the operator write the `prometheus-<name>-datasource`
+	// ConfigMap describing the Grafana datasource of the Prometheus server, so that
+	// Grafana can provision it. The ConfigMap is deleted when unset.
+	ExportDatasource *DatasourceExportSpec `json:"exportDatasource,omitempty"`
+	// NetworkPolicy makes the operator create a NetworkPolicy for the
+	// Prometheus Pods. It allows the ingress traffic to the web and Thanos
+	// sidecar ports and the egress traffic to the targets of the selected
+	// monitoring objects, the Alertmanagers, the remote storage, the
+	// Kubernetes API server and DNS. The namespaces are selected by their
+	// `kubernetes.io/metadata.name` label, set by Kubernetes 1.21 and newer.
+	NetworkPolicy *PrometheusNetworkPolicy `json:"networkPolicy,omitempty"`
 	// Containers allows injecting additional containers or modifying operator generated
 	// containers. This can be used to allow adding an authentication proxy to a Prometheus pod or
 	// to change the behavior of an operator generated container. Containers described here modify
@@ -249,6 +360,14 @@ type PrometheusSpec struct {
 	// of what the maintainers will support and by doing so, you accept that this behaviour may break
 	// at any time without notice.
 	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// StatefulSetPatch is a strategic merge patch applied to the StatefulSet
+	// generated by the operator for the Prometheus, as the final step of its
+	// generation. It allows setting fields which aren't exposed by the Prometheus
+	// resource (e.g. new Kubernetes fields). Patching the StatefulSet is entirely
+	// outside the scope of what the maintainers will support and by doing so, you
+	// accept that this behaviour may break at any time without notice.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	StatefulSetPatch *runtime.RawExtension `json:"statefulSetPatch,omitempty"`
 	// AdditionalScrapeConfigs allows specifying a key of a Secret containing
 	// additional Prometheus scrape configurations. Scrape configurations
 	// specified are appended to the configurations generated by the Prometheus
@@ -261,6 +380,31 @@ type PrometheusSpec struct {
 	// notes to ensure that no incompatible scrape configs are going to break
 	// Prometheus after the upgrade.
 	AdditionalScrapeConfigs *v1.SecretKeySelector `json:"additionalScrapeConfigs,omitempty"`
+	// AdditionalScrapeConfigsAsFile includes the additional scrape
+	// configurations from a dedicated file referenced by
+	// `scrape_config_files` instead of appending them to the generated
+	// configuration. Changes to the additional scrape configurations then
+	// don't modify the generated configuration.
+	// Only valid in Prometheus versions 2.43.0 and newer.
+	AdditionalScrapeConfigsAsFile bool `json:"additionalScrapeConfigsAsFile,omitempty"`
+	// SplitScrapeConfigs writes the scrape configurations generated from the
+	// selected monitoring objects to dedicated Secrets included via
+	// `scrape_config_files` instead of the configuration Secret. This keeps
+	// the configuration Secret under the maximum Secret size when selecting
+	// a large number of objects.
+	// Only valid in Prometheus versions 2.43.0 and newer.
+	SplitScrapeConfigs bool `json:"splitScrapeConfigs,omitempty"`
+	// ScrapeClasses lists the scrape classes which ServiceMonitors,
+	// PodMonitors and Probes can reference by name to inherit default scrape
+	// settings. The objects which don't reference a scrape class use the
+	// default scrape class, if any.
+	ScrapeClasses []ScrapeClass `json:"scrapeClasses,omitempty"`
+	// Federation lists the Prometheus instances managed by the operator whose
+	// series are scraped from their `/federate` endpoint. Each entry
+	// generates a scrape configuration with `honor_labels: true` which
+	// discovers the Pods of the federated instance through its governing
+	// Service.
+	Federation []FederationSpec `json:"federation,omitempty"`
 	// AdditionalAlertRelabelConfigs allows specifying a key of a Secret containing
 	// additional Prometheus alert relabel configurations. Alert relabel configurations
 	// specified are appended to the configurations generated by the Prometheus
@@ -301,6 +445,9 @@ type PrometheusSpec struct {
 	Thanos *ThanosSpec `json:"thanos,omitempty"`
 	// Priority class assigned to the Pods
 	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// HostAliases are the entries added to the hosts file of the Pods, for
+	// instance to resolve names which aren't resolvable by the cluster DNS.
+	HostAliases []v1.HostAlias `json:"hostAliases,omitempty"`
 	// Port name used for the pods and governing service.
 	// This defaults to web
 	PortName string `json:"portName,omitempty"`
@@ -334,16 +481,135 @@ type PrometheusSpec struct {
 	QueryLogFile string `json:"queryLogFile,omitempty"`
 	// EnforcedSampleLimit defines global limit on number of scraped samples
 	// that will be accepted. This overrides any SampleLimit set per
-	// ServiceMonitor or/and PodMonitor. It is meant to be used by admins to
+	// ServiceMonitor, PodMonitor or/and Probe. It is meant to be used by admins to
 	// enforce the SampleLimit to keep overall number of samples/series under
 	// the desired limit.
 	// Note that if SampleLimit is lower that value will be taken instead.
+	// The `monitoring.coreos.com/enforced-sample-limit` annotation of a
+	// namespace defines a lower limit for the objects of the namespace.
 	EnforcedSampleLimit *uint64 `json:"enforcedSampleLimit,omitempty"`
+	// EnforcedTargetLimit defines a global limit on the number of scraped
+	// targets. This overrides any TargetLimit set per Probe. It is meant to be
+	// used by admins to keep the overall number of targets under the desired
+	// limit. Note that if TargetLimit is lower, that value will be taken
+	// instead. Only valid in Prometheus versions 2.21.0 and newer.
+	// The `monitoring.coreos.com/enforced-target-limit` annotation of a
+	// namespace defines a lower limit for the Probes of the namespace.
+	EnforcedTargetLimit *uint64 `json:"enforcedTargetLimit,omitempty"`
+	// EnforcedLabelLimit defines a global limit on the number of labels per
+	// sample. This overrides any LabelLimit set per ServiceMonitor,
+	// PodMonitor, Probe or NodeMonitor. Note that if LabelLimit is lower,
+	// that value will be taken instead. Only valid in Prometheus versions
+	// 2.27.0 and newer.
+	EnforcedLabelLimit *uint64 `json:"enforcedLabelLimit,omitempty"`
+	// EnforcedLabelNameLengthLimit defines a global limit on the length of
+	// the label names per sample. This overrides any LabelNameLengthLimit
+	// set per ServiceMonitor, PodMonitor, Probe or NodeMonitor. Note that if
+	// LabelNameLengthLimit is lower, that value will be taken instead. Only
+	// valid in Prometheus versions 2.27.0 and newer.
+	EnforcedLabelNameLengthLimit *uint64 `json:"enforcedLabelNameLengthLimit,omitempty"`
+	// EnforcedLabelValueLengthLimit defines a global limit on the length of
+	// the label values per sample. This overrides any LabelValueLengthLimit
+	// set per ServiceMonitor, PodMonitor, Probe or NodeMonitor. Note that if
+	// LabelValueLengthLimit is lower, that value will be taken instead. Only
+	// valid in Prometheus versions 2.27.0 and newer.
+	EnforcedLabelValueLengthLimit *uint64 `json:"enforcedLabelValueLengthLimit,omitempty"`
+	// EnforcedKeepDroppedTargets defines a global limit on the number of
+	// targets dropped by relabeling that are kept in memory. This overrides
+	// any KeepDroppedTargets set per ServiceMonitor, PodMonitor, Probe or
+	// NodeMonitor. Note that if KeepDroppedTargets is lower, that value will
+	// be taken instead. Only valid in Prometheus versions 2.47.0 and newer.
+	EnforcedKeepDroppedTargets *uint64 `json:"enforcedKeepDroppedTargets,omitempty"`
 	// AllowOverlappingBlocks enables vertical compaction and vertical query merge in Prometheus.
 	// This is still experimental in Prometheus so it may change in any upcoming release.
 	AllowOverlappingBlocks bool `json:"allowOverlappingBlocks,omitempty"`
 }
 
+// RemoteRuleFiles defines rule files fetched from remote HTTP(S) endpoints.
+// Files stored in Git repositories can be referenced through the raw file URLs
+// of the hosting service.
+// +k8s:openapi-gen=true
+type RemoteRuleFiles struct {
+	// URLs of the rule files. The file name is the last element of the URL
+	// path and must end with `.yaml` or `.yml`. The file names must be
+	// unique.
+	// +kubebuilder:validation:MinItems=1
+	URLs []string `json:"urls"`
+	// Interval at which the rule files are fetched, as a Go duration (e.g.
+	// `5m`). Defaults to `1m`.
+	Interval string `json:"interval,omitempty"`
+}
+
+// AutoResources defines how the operator sizes the memory request of the
+// Prometheus container from the series in the TSDB head of its pods.
+// +k8s:openapi-gen=true
+type AutoResources struct {
+	// MinMemory is the lowest memory request set by the operator.
+	MinMemory resource.Quantity `json:"minMemory"`
+	// MaxMemory is the highest memory request set by the operator. It must
+	// not exceed the memory limit, if any.
+	MaxMemory resource.Quantity `json:"maxMemory"`
+	// BytesPerSeries is the memory estimated for each series of the TSDB
+	// head. Defaults to 8Ki.
+	BytesPerSeries *resource.Quantity `json:"bytesPerSeries,omitempty"`
+	// HeadroomPercent is the memory added on top of the estimation, as a
+	// percentage of the estimation. Defaults to 25.
+	// +kubebuilder:validation:Minimum=0
+	HeadroomPercent *int32 `json:"headroomPercent,omitempty"`
+}
+
+// PrometheusNetworkPolicy configures the NetworkPolicy generated for the
+// Prometheus Pods.
+// +k8s:openapi-gen=true
+type PrometheusNetworkPolicy struct {
+	// IngressFrom lists the sources allowed to reach the web and Thanos
+	// sidecar ports. If empty, all sources are allowed. The operator must be
+	// allowed when it reaches the Pods, with the HTTP reload strategy or the
+	// targets check.
+	IngressFrom []networkingv1.NetworkPolicyPeer `json:"ingressFrom,omitempty"`
+	// AdditionalEgress lists the egress rules appended to the generated
+	// ones, for instance to reach the object storage of the Thanos sidecar.
+	AdditionalEgress []networkingv1.NetworkPolicyEgressRule `json:"additionalEgress,omitempty"`
+}
+
+// ReloadStrategyType defines how the configuration changes are applied to
+// Prometheus.
+type ReloadStrategyType string
+
+const (
+	// ProcessSignalReloadStrategyType runs the config reloader sidecars in
+	// the Prometheus Pods.
+	ProcessSignalReloadStrategyType ReloadStrategyType = "ProcessSignal"
+	// HTTPReloadStrategyType makes the operator reload Prometheus through
+	// its HTTP API.
+	HTTPReloadStrategyType ReloadStrategyType = "HTTP"
+)
+
+// TopologySpreadConstraint extends the Kubernetes topology spread
+// constraint with label selectors generated by the operator.
+type TopologySpreadConstraint struct {
+	v1.TopologySpreadConstraint `json:",inline"`
+	// AdditionalLabelSelectors adds the labels generated by the operator to
+	// the label selector of the constraint. With `OnResource`, the selector
+	// matches the Pods of the Prometheus object. With `OnShard`, it matches
+	// the Pods of the same shard of the Prometheus object, which are all the
+	// Pods since the operator doesn't shard Prometheus.
+	// +kubebuilder:validation:Enum=OnResource;OnShard
+	AdditionalLabelSelectors *AdditionalLabelSelectors `json:"additionalLabelSelectors,omitempty"`
+}
+
+// AdditionalLabelSelectors defines the labels generated by the operator
+// added to the label selector of a topology spread constraint.
+type AdditionalLabelSelectors string
+
+const (
+	// ResourceNameLabelSelector selects the Pods of the Prometheus object.
+	ResourceNameLabelSelector AdditionalLabelSelectors = "OnResource"
+	// ShardAndResourceNameLabelSelector selects the Pods of the same shard
+	// of the Prometheus object.
+	ShardAndResourceNameLabelSelector AdditionalLabelSelectors = "OnShard"
+)
+
 // PrometheusRuleExcludeConfig enables users to configure excluded PrometheusRule names and their namespaces
 // to be ignored while enforcing namespace label for alerts and metrics.
 type PrometheusRuleExcludeConfig struct {
@@ -365,9 +631,8 @@ type ArbitraryFSAccessThroughSMsConfig struct {
 	Deny bool `json:"deny,omitempty"`
 }
 
-// PrometheusStatus is the most recent observed status of the Prometheus cluster. Read-only. Not
-// included when requesting from the apiserver, only from the Prometheus
-// Operator API itself. More info:
+// PrometheusStatus is the most recent observed status of the Prometheus cluster. Read-only.
+// Updated by the operator through the status subresource. More info:
 // https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 // +k8s:openapi-gen=true
 type PrometheusStatus struct {
@@ -385,6 +650,278 @@ type PrometheusStatus struct {
 	AvailableReplicas int32 `json:"availableReplicas"`
 	// Total number of unavailable pods targeted by this Prometheus deployment.
 	UnavailableReplicas int32 `json:"unavailableReplicas"`
+	// The label selector of the pods targeted by this Prometheus deployment,
+	// in string form.
+	Selector string `json:"selector,omitempty"`
+	// The input hash of the StatefulSet applied by the last successful
+	// reconciliation. It changes whenever the spec, the operator
+	// configuration or the selected rules require a new StatefulSet.
+	InputHash string `json:"inputHash,omitempty"`
+	// The current state of the Prometheus deployment.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// PrometheusAgent defines a Prometheus deployment running in agent mode.
+// Agents only scrape targets and forward the samples to remote storage, they
+// don't evaluate rules nor send alerts.
+// +genclient
+// +k8s:openapi-gen=true
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".spec.version",description="The version of Prometheus"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".spec.replicas",description="The desired replicas number of Prometheus agents"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type PrometheusAgent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the desired behavior of the Prometheus agent. More info:
+	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
+	Spec PrometheusAgentSpec `json:"spec"`
+	// Most recent observed status of the Prometheus agent. Read-only. Not
+	// included when requesting from the apiserver, only from the Prometheus
+	// Operator API itself. More info:
+	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
+	Status *PrometheusStatus `json:"status,omitempty"`
+}
+
+// PrometheusAgentList is a list of PrometheusAgents.
+// +k8s:openapi-gen=true
+type PrometheusAgentList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of PrometheusAgents
+	Items []*PrometheusAgent `json:"items"`
+}
+
+// PrometheusAgentSpec is a specification of the desired behavior of the
+// Prometheus agent. It is a subset of the Prometheus specification without
+// the fields related to the local storage, the rules and the alerting.
+// Prometheus runs with `--enable-feature=agent`, which requires Prometheus
+// 2.32.0 or newer.
+// +k8s:openapi-gen=true
+type PrometheusAgentSpec struct {
+	// PodMetadata configures Labels and Annotations which are propagated to the Prometheus agent pods.
+	PodMetadata *EmbeddedObjectMetadata `json:"podMetadata,omitempty"`
+	// ServiceMonitors to be selected for target discovery. If none of the
+	// monitor selectors are specified, configuration is unmanaged.
+	ServiceMonitorSelector *metav1.LabelSelector `json:"serviceMonitorSelector,omitempty"`
+	// Namespaces to be selected for ServiceMonitor discovery. If nil, only
+	// check own namespace.
+	ServiceMonitorNamespaceSelector *metav1.LabelSelector `json:"serviceMonitorNamespaceSelector,omitempty"`
+	// PodMonitors to be selected for target discovery.
+	PodMonitorSelector *metav1.LabelSelector `json:"podMonitorSelector,omitempty"`
+	// Namespaces to be selected for PodMonitor discovery. If nil, only
+	// check own namespace.
+	PodMonitorNamespaceSelector *metav1.LabelSelector `json:"podMonitorNamespaceSelector,omitempty"`
+	// Probes to be selected for target discovery.
+	ProbeSelector *metav1.LabelSelector `json:"probeSelector,omitempty"`
+	// Namespaces to be selected for Probe discovery. If nil, only check own namespace.
+	ProbeNamespaceSelector *metav1.LabelSelector `json:"probeNamespaceSelector,omitempty"`
+	// NodeMonitors to be selected for target discovery.
+	NodeMonitorSelector *metav1.LabelSelector `json:"nodeMonitorSelector,omitempty"`
+	// Namespaces to be selected for NodeMonitor discovery. If nil, only
+	// check own namespace.
+	NodeMonitorNamespaceSelector *metav1.LabelSelector `json:"nodeMonitorNamespaceSelector,omitempty"`
+	// Version of Prometheus to be deployed. Must be 2.32.0 or newer. Defaults
+	// to v2.32.0.
+	Version string `json:"version,omitempty"`
+	// When a Prometheus agent is paused, no actions except for deletion
+	// will be performed on the underlying objects.
+	Paused bool `json:"paused,omitempty"`
+	// Image if specified has precedence over the default image. Specifying
+	// the version is still necessary to ensure the Prometheus Operator knows
+	// what version of Prometheus is being configured.
+	Image *string `json:"image,omitempty"`
+	// An optional list of references to secrets in the same namespace
+	// to use for pulling the Prometheus image from registries
+	// see http://kubernetes.io/docs/user-guide/images#specifying-imagepullsecrets-on-a-pod
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Number of instances to deploy for a Prometheus agent.
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Name of Prometheus external label used to denote replica name.
+	// Defaults to the value of `prometheus_replica`. External label will
+	// _not_ be added when value is set to empty string (`""`).
+	ReplicaExternalLabelName *string `json:"replicaExternalLabelName,omitempty"`
+	// Name of Prometheus external label used to denote Prometheus agent
+	// name. Defaults to the value of `prometheus`. External label will
+	// _not_ be added when value is set to empty string (`""`).
+	PrometheusExternalLabelName *string `json:"prometheusExternalLabelName,omitempty"`
+	// Log level for Prometheus to be configured with.
+	LogLevel string `json:"logLevel,omitempty"`
+	// Log format for Prometheus to be configured with.
+	LogFormat string `json:"logFormat,omitempty"`
+	// Interval between consecutive scrapes.
+	ScrapeInterval string `json:"scrapeInterval,omitempty"`
+	// Number of seconds to wait for target to respond before erroring.
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// The labels to add to any time series when communicating with the
+	// remote storage.
+	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+	// Storage spec to specify how the write-ahead log of the agent shall be
+	// stored.
+	Storage *StorageSpec `json:"storage,omitempty"`
+	// Volumes allows configuration of additional volumes on the output StatefulSet definition. Volumes specified will
+	// be appended to other volumes that are generated as a result of StorageSpec objects.
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// VolumeMounts allows configuration of additional VolumeMounts on the output StatefulSet definition.
+	// VolumeMounts specified will be appended to other VolumeMounts in the prometheus container,
+	// that are generated as a result of StorageSpec objects.
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+	// Define resources requests and limits for single Pods.
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+	// Define which Nodes the Pods are scheduled on.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// ServiceAccountName is the name of the ServiceAccount to use to run the
+	// Prometheus agent Pods.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ManageServiceAccount instructs the operator to create the ServiceAccount
+	// used to run the Prometheus agent Pods and to keep its labels and
+	// annotations up to date. The ServiceAccount is named after
+	// serviceAccountName or defaults to `prom-agent-<name>` if empty.
+	ManageServiceAccount bool `json:"manageServiceAccount,omitempty"`
+	// ServiceAccountAnnotations are added to the managed ServiceAccount, for
+	// instance to bind it to a cloud provider identity (e.g. GKE Workload
+	// Identity or EKS IAM roles for service accounts).
+	// Only used when manageServiceAccount is true.
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
+	// ServiceAccountLabels are added to the managed ServiceAccount.
+	// Only used when manageServiceAccount is true.
+	ServiceAccountLabels map[string]string `json:"serviceAccountLabels,omitempty"`
+	// BoundServiceAccountToken projects a short-lived token of the
+	// ServiceAccount into the Pods, rotated by the kubelet. Endpoints with
+	// bearerTokenBoundServiceAccount use it as bearer token.
+	BoundServiceAccountToken *BoundServiceAccountToken `json:"boundServiceAccountToken,omitempty"`
+	// Secrets is a list of Secrets in the same namespace as the PrometheusAgent
+	// object, which shall be mounted into the Prometheus agent Pods.
+	// The Secrets are mounted into /etc/prometheus/secrets/<secret-name>.
+	Secrets []string `json:"secrets,omitempty"`
+	// ConfigMaps is a list of ConfigMaps in the same namespace as the PrometheusAgent
+	// object, which shall be mounted into the Prometheus agent Pods.
+	// The ConfigMaps are mounted into /etc/prometheus/configmaps/<configmap-name>.
+	ConfigMaps []string `json:"configMaps,omitempty"`
+	// If specified, the pod's scheduling constraints.
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// If specified, the pod's tolerations.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// The remote_write spec. At least one remote write endpoint is required
+	// since the agent doesn't store the samples locally.
+	// +kubebuilder:validation:MinItems=1
+	RemoteWrite []RemoteWriteSpec `json:"remoteWrite"`
+	// SecurityContext holds pod-level security attributes and common container settings.
+	// This defaults to the default PodSecurityContext.
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// ConfigReloaderSecurityContext defines the security context of the
+	// `prometheus-config-reloader` container. It takes precedence over the
+	// settings of the pod security context.
+	ConfigReloaderSecurityContext *v1.SecurityContext `json:"configReloaderSecurityContext,omitempty"`
+	// ListenLocal makes the Prometheus agent listen on loopback, so that it
+	// does not bind against the Pod IP.
+	ListenLocal bool `json:"listenLocal,omitempty"`
+	// Containers allows injecting additional containers or modifying operator generated
+	// containers. Containers described here modify an operator generated container if they
+	// share the same name and modifications are done via a strategic merge patch. The current
+	// container names are: `prometheus` and `prometheus-config-reloader`. Overriding
+	// containers is entirely outside the scope of what the maintainers will support and by doing
+	// so, you accept that this behaviour may break at any time without notice.
+	Containers []v1.Container `json:"containers,omitempty"`
+	// InitContainers allows adding initContainers to the pod definition.
+	// Using initContainers for any use case other then secret fetching is entirely outside the scope
+	// of what the maintainers will support and by doing so, you accept that this behaviour may break
+	// at any time without notice.
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// StatefulSetPatch is a strategic merge patch applied to the StatefulSet
+	// generated by the operator for the PrometheusAgent, as the final step of
+	// its generation. It allows setting fields which aren't exposed by the
+	// PrometheusAgent resource (e.g. new Kubernetes fields). Patching the
+	// StatefulSet is entirely outside the scope of what the maintainers will
+	// support and by doing so, you accept that this behaviour may break at any
+	// time without notice.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	StatefulSetPatch *runtime.RawExtension `json:"statefulSetPatch,omitempty"`
+	// AdditionalScrapeConfigs allows specifying a key of a Secret containing
+	// additional Prometheus scrape configurations. Scrape configurations
+	// specified are appended to the configurations generated by the Prometheus
+	// Operator. As scrape configs are appended, the user is responsible to make
+	// sure it is valid.
+	AdditionalScrapeConfigs *v1.SecretKeySelector `json:"additionalScrapeConfigs,omitempty"`
+	// ScrapeClasses lists the scrape classes which ServiceMonitors,
+	// PodMonitors and Probes can reference by name to inherit default scrape
+	// settings. The objects which don't reference a scrape class use the
+	// default scrape class, if any.
+	ScrapeClasses []ScrapeClass `json:"scrapeClasses,omitempty"`
+	// APIServerConfig allows specifying a host and auth methods to access apiserver.
+	// If left empty, Prometheus is assumed to run inside of the cluster
+	// and will discover API servers automatically and use the pod's CA certificate
+	// and bearer token file at /var/run/secrets/kubernetes.io/serviceaccount/.
+	APIServerConfig *APIServerConfig `json:"apiserverConfig,omitempty"`
+	// Priority class assigned to the Pods
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// HostAliases are the entries added to the hosts file of the Pods, for
+	// instance to resolve names which aren't resolvable by the cluster DNS.
+	HostAliases []v1.HostAlias `json:"hostAliases,omitempty"`
+	// Port name used for the pods and governing service.
+	// This defaults to web
+	PortName string `json:"portName,omitempty"`
+	// ArbitraryFSAccessThroughSMs configures whether configuration
+	// based on a service monitor can access arbitrary files on the file system
+	// of the Prometheus container e.g. bearer token files.
+	ArbitraryFSAccessThroughSMs ArbitraryFSAccessThroughSMsConfig `json:"arbitraryFSAccessThroughSMs,omitempty"`
+	// OverrideHonorLabels if set to true overrides all user configured honor_labels.
+	// If HonorLabels is set in ServiceMonitor or PodMonitor to true, this overrides honor_labels to false.
+	OverrideHonorLabels bool `json:"overrideHonorLabels,omitempty"`
+	// OverrideHonorTimestamps allows to globally enforce honoring timestamps in all scrape configs.
+	OverrideHonorTimestamps bool `json:"overrideHonorTimestamps,omitempty"`
+	// IgnoreNamespaceSelectors if set to true will ignore NamespaceSelector settings from
+	// the podmonitor and servicemonitor configs, and they will only discover endpoints
+	// within their current namespace.  Defaults to false.
+	IgnoreNamespaceSelectors bool `json:"ignoreNamespaceSelectors,omitempty"`
+	// EnforcedNamespaceLabel enforces adding a namespace label of origin for each
+	// metric that is user created. The label value will always be the namespace of the object that is
+	// being created.
+	EnforcedNamespaceLabel string `json:"enforcedNamespaceLabel,omitempty"`
+	// EnforcedSampleLimit defines global limit on number of scraped samples
+	// that will be accepted. This overrides any SampleLimit set per
+	// ServiceMonitor, PodMonitor or/and Probe.
+	// The `monitoring.coreos.com/enforced-sample-limit` annotation of a
+	// namespace defines a lower limit for the objects of the namespace.
+	EnforcedSampleLimit *uint64 `json:"enforcedSampleLimit,omitempty"`
+	// EnforcedTargetLimit defines a global limit on the number of scraped
+	// targets. This overrides any TargetLimit set per Probe.
+	// The `monitoring.coreos.com/enforced-target-limit` annotation of a
+	// namespace defines a lower limit for the Probes of the namespace.
+	EnforcedTargetLimit *uint64 `json:"enforcedTargetLimit,omitempty"`
+	// EnforcedLabelLimit defines a global limit on the number of labels per
+	// sample. This overrides any LabelLimit set per ServiceMonitor,
+	// PodMonitor, Probe or NodeMonitor.
+	EnforcedLabelLimit *uint64 `json:"enforcedLabelLimit,omitempty"`
+	// EnforcedLabelNameLengthLimit defines a global limit on the length of
+	// the label names per sample. This overrides any LabelNameLengthLimit
+	// set per ServiceMonitor, PodMonitor, Probe or NodeMonitor.
+	EnforcedLabelNameLengthLimit *uint64 `json:"enforcedLabelNameLengthLimit,omitempty"`
+	// EnforcedLabelValueLengthLimit defines a global limit on the length of
+	// the label values per sample. This overrides any LabelValueLengthLimit
+	// set per ServiceMonitor, PodMonitor, Probe or NodeMonitor.
+	EnforcedLabelValueLengthLimit *uint64 `json:"enforcedLabelValueLengthLimit,omitempty"`
+	// EnforcedKeepDroppedTargets defines a global limit on the number of
+	// targets dropped by relabeling that are kept in memory. This overrides
+	// any KeepDroppedTargets set per ServiceMonitor, PodMonitor, Probe or
+	// NodeMonitor.
+	EnforcedKeepDroppedTargets *uint64 `json:"enforcedKeepDroppedTargets,omitempty"`
+}
+
+// BoundServiceAccountToken defines the projected token of the ServiceAccount
+// running the Prometheus Pods.
+// +k8s:openapi-gen=true
+type BoundServiceAccountToken struct {
+	// Audience of the token. The scraped targets must accept it when
+	// reviewing the token. Defaults to the audience of the API server.
+	Audience string `json:"audience,omitempty"`
+	// ExpirationSeconds is the requested validity duration of the token.
+	// The kubelet rotates the token before it expires. Defaults to 3600.
+	// +kubebuilder:validation:Minimum=600
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
 }
 
 // AlertingSpec defines parameters for alerting configuration of Prometheus servers.
@@ -406,8 +943,28 @@ type StorageSpec struct {
 	EmptyDir *v1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
 	// A PVC spec to be used by the Prometheus StatefulSets.
 	VolumeClaimTemplate EmbeddedPersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
+	// ResizePolicy defines how the existing PersistentVolumeClaims are
+	// handled when the storage request of the volumeClaimTemplate grows.
+	// With `None` (default), the existing claims keep their size. With
+	// `Auto`, the operator expands the existing claims and recreates the
+	// StatefulSet without deleting its Pods. `Auto` requires a StorageClass
+	// allowing volume expansion, otherwise the claims keep their size and
+	// the operator logs a warning.
+	// +kubebuilder:validation:Enum=None;Auto
+	ResizePolicy StorageResizePolicy `json:"resizePolicy,omitempty"`
 }
 
+// StorageResizePolicy defines how the PersistentVolumeClaims are resized.
+type StorageResizePolicy string
+
+const (
+	// StorageResizePolicyNone leaves the existing PersistentVolumeClaims
+	// untouched.
+	StorageResizePolicyNone StorageResizePolicy = "None"
+	// StorageResizePolicyAuto expands the existing PersistentVolumeClaims.
+	StorageResizePolicyAuto StorageResizePolicy = "Auto"
+)
+
 // EmbeddedPersistentVolumeClaim is an embedded version of k8s.io/api/core/v1.PersistentVolumeClaim.
 // It contains TypeMeta and a reduced ObjectMeta.
 type EmbeddedPersistentVolumeClaim struct {
@@ -497,6 +1054,14 @@ type ThanosSpec struct {
 	Resources v1.ResourceRequirements `json:"resources,omitempty"`
 	// ObjectStorageConfig configures object storage in Thanos.
 	ObjectStorageConfig *v1.SecretKeySelector `json:"objectStorageConfig,omitempty"`
+	// ObjectStorageConfigValidation defines whether the operator validates the
+	// object storage configuration before rolling out the Thanos sidecar.
+	// With `Strict`, the operator checks that the configuration is valid YAML
+	// with a known provider type and the keys required by that provider, and
+	// doesn't update the Prometheus StatefulSet if the check fails.
+	// Defaults to `None`.
+	// +kubebuilder:validation:Enum=Strict;None
+	ObjectStorageConfigValidation string `json:"objectStorageConfigValidation,omitempty"`
 	// ListenLocal makes the Thanos sidecar listen on loopback, so that it
 	// does not bind against the Pod IP.
 	ListenLocal bool `json:"listenLocal,omitempty"`
@@ -513,8 +1078,93 @@ type ThanosSpec struct {
 	LogFormat string `json:"logFormat,omitempty"`
 	// MinTime for Thanos sidecar to be configured with. Option can be a constant time in RFC3339 format or time duration relative to current time, such as -1d or 2h45m. Valid duration units are ms, s, m, h, d, w, y.
 	MinTime string `json:"minTime,omitempty"`
+	// UploadCompacted makes the Thanos sidecar upload the blocks compacted by
+	// Prometheus too, for instance to backfill historical blocks. It requires
+	// the object storage configuration. Maps to the
+	// '--shipper.upload-compacted' CLI arg.
+	UploadCompacted bool `json:"uploadCompacted,omitempty"`
+	// MinBlockDuration is the minimum duration of the TSDB blocks written by
+	// Prometheus, such as 2h. When the blocks are uploaded to the object
+	// storage, it must be equal to MaxBlockDuration. Defaults to 2h.
+	MinBlockDuration string `json:"minBlockDuration,omitempty"`
+	// MaxBlockDuration is the maximum duration of the TSDB blocks written by
+	// Prometheus, such as 2h. When the blocks are uploaded to the object
+	// storage, it must be equal to MinBlockDuration. Defaults to 2h when the
+	// blocks are uploaded.
+	MaxBlockDuration string `json:"maxBlockDuration,omitempty"`
+	// LivenessProbe overrides the timing parameters of the liveness probe
+	// of the Thanos sidecar which checks the `/-/healthy` endpoint.
+	LivenessProbe *ProbeTiming `json:"livenessProbe,omitempty"`
+	// ReadinessProbe overrides the timing parameters of the readiness probe
+	// of the Thanos sidecar which checks the `/-/ready` endpoint.
+	ReadinessProbe *ProbeTiming `json:"readinessProbe,omitempty"`
+	// SecurityContext defines the security context of the Thanos sidecar
+	// container. It takes precedence over the settings of the pod security
+	// context.
+	SecurityContext *v1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// ProbeTiming defines the timing parameters of a container probe generated
+// by the operator. Unset fields keep the operator's defaults.
+// +k8s:openapi-gen=true
+type ProbeTiming struct {
+	// Number of seconds after the container has started before the probe is
+	// initiated.
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	// How often (in seconds) to perform the probe.
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// Number of seconds after which the probe times out.
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// Minimum consecutive failures for the probe to be considered failed.
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+const (
+	// ObjectStorageConfigValidationStrict enables the validation of the Thanos
+	// object storage configuration.
+	ObjectStorageConfigValidationStrict = "Strict"
+	// ObjectStorageConfigValidationNone disables the validation of the Thanos
+	// object storage configuration.
+	ObjectStorageConfigValidationNone = "None"
+)
+
+// OTLPConfig configures the OTLP receiver of Prometheus.
+// +k8s:openapi-gen=true
+type OTLPConfig struct {
+	// List of OpenTelemetry resource attributes to promote to metric labels.
+	// Only valid in Prometheus versions 2.54.0 and newer.
+	PromoteResourceAttributes []string `json:"promoteResourceAttributes,omitempty"`
+	// Configures how the OTLP metric and attribute names are translated into
+	// Prometheus metric and label names.
+	// Only valid in Prometheus versions 2.55.0 and newer.
+	// +kubebuilder:validation:Enum=NoUTF8EscapingWithSuffixes;UnderscoreEscapingWithSuffixes
+	TranslationStrategy string `json:"translationStrategy,omitempty"`
+}
+
+// Exemplars configures the exemplar storage of Prometheus.
+// +k8s:openapi-gen=true
+type Exemplars struct {
+	// Maximum number of exemplars stored in memory for all the series. A
+	// value of 0 disables the exemplar storage. Defaults to 100000.
+	// +kubebuilder:validation:Minimum=0
+	MaxSize *int64 `json:"maxSize,omitempty"`
 }
 
+// ScrapeProtocol is a protocol used by Prometheus to scrape the metrics.
+// +kubebuilder:validation:Enum=PrometheusProto;OpenMetricsText0.0.1;OpenMetricsText1.0.0;PrometheusText0.0.4
+type ScrapeProtocol string
+
+const (
+	ScrapeProtocolPrometheusProto      ScrapeProtocol = "PrometheusProto"
+	ScrapeProtocolOpenMetricsText0_0_1 ScrapeProtocol = "OpenMetricsText0.0.1"
+	ScrapeProtocolOpenMetricsText1_0_0 ScrapeProtocol = "OpenMetricsText1.0.0"
+	ScrapeProtocolPrometheusText0_0_4  ScrapeProtocol = "PrometheusText0.0.4"
+)
+
 // RemoteWriteSpec defines the remote_write configuration for prometheus.
 // +k8s:openapi-gen=true
 type RemoteWriteSpec struct {
@@ -533,13 +1183,90 @@ type RemoteWriteSpec struct {
 	// File to read bearer token for remote write.
 	BearerToken string `json:"bearerToken,omitempty"`
 	// File to read bearer token for remote write.
+	// Deprecated: use `authorization` instead.
 	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
+	// Authorization section for the URL. Cannot be set at the same time as
+	// basicAuth, bearerToken, bearerTokenFile or sigv4.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
 	// TLS Config to use for remote write.
 	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
 	// Optional ProxyURL
 	ProxyURL string `json:"proxyUrl,omitempty"`
+	// NoProxy is a comma-separated list of IP addresses, CIDR notations and
+	// domain names excluded from proxying. Requires proxyUrl.
+	// Only valid in Prometheus versions 2.43.0 and newer.
+	NoProxy string `json:"noProxy,omitempty"`
+	// ProxyFromEnvironment uses the proxy defined by the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables of the Prometheus
+	// container. It can't be set at the same time as proxyUrl.
+	// Only valid in Prometheus versions 2.43.0 and newer.
+	ProxyFromEnvironment bool `json:"proxyFromEnvironment,omitempty"`
+	// ProxyConnectHeader defines the headers sent to the proxy during the
+	// CONNECT requests, with their values read from Secrets in the namespace
+	// of the Prometheus object. Requires proxyUrl.
+	// Only valid in Prometheus versions 2.43.0 and newer.
+	ProxyConnectHeader map[string]v1.SecretKeySelector `json:"proxyConnectHeader,omitempty"`
 	// QueueConfig allows tuning of the remote write queue parameters.
 	QueueConfig *QueueConfig `json:"queueConfig,omitempty"`
+	// MetadataConfig configures the sending of series metadata to the remote storage.
+	// Only valid in Prometheus versions 2.23.0 and newer.
+	MetadataConfig *MetadataConfig `json:"metadataConfig,omitempty"`
+	// Sigv4 allows to configure AWS's Signature Verification 4 for the URL.
+	// Cannot be set at the same time as basicAuth, bearerToken, bearerTokenFile
+	// or azureAd. Only valid in Prometheus versions 2.26.0 and newer.
+	Sigv4 *Sigv4 `json:"sigv4,omitempty"`
+	// AzureAD for the URL.
+	// Cannot be set at the same time as basicAuth, bearerToken, bearerTokenFile
+	// or sigv4. Only valid in Prometheus versions 2.45.0 and newer.
+	AzureAD *AzureAD `json:"azureAd,omitempty"`
+}
+
+// MetadataConfig configures the sending of series metadata to the remote storage.
+// +k8s:openapi-gen=true
+type MetadataConfig struct {
+	// Whether metric metadata is sent to the remote storage or not.
+	Send bool `json:"send,omitempty"`
+	// How frequently metric metadata is sent to the remote storage.
+	SendInterval string `json:"sendInterval,omitempty"`
+	// MaxSamplesPerSend is the maximum number of metadata samples per send.
+	// Only valid in Prometheus versions 2.29.0 and newer.
+	MaxSamplesPerSend int `json:"maxSamplesPerSend,omitempty"`
+}
+
+// Sigv4 optionally configures AWS's Signature Verification 4 signing process to
+// sign requests.
+// +k8s:openapi-gen=true
+type Sigv4 struct {
+	// Region is the AWS region. If blank, the region from the default credentials chain is used.
+	Region string `json:"region,omitempty"`
+	// AccessKey is the AWS API key. If blank, the environment variable
+	// `AWS_ACCESS_KEY_ID` is used. Must be set together with SecretKey.
+	AccessKey *v1.SecretKeySelector `json:"accessKey,omitempty"`
+	// SecretKey is the AWS API secret. If blank, the environment variable
+	// `AWS_SECRET_ACCESS_KEY` is used. Must be set together with AccessKey.
+	SecretKey *v1.SecretKeySelector `json:"secretKey,omitempty"`
+	// Profile is the named AWS profile used to authenticate.
+	Profile string `json:"profile,omitempty"`
+	// RoleArn is the AWS role ARN used to authenticate.
+	RoleArn string `json:"roleArn,omitempty"`
+}
+
+// AzureAD defines the Azure Active Directory authentication for remote write.
+// +k8s:openapi-gen=true
+type AzureAD struct {
+	// The Azure Cloud. Options are 'AzurePublic', 'AzureChina', or 'AzureGovernment'.
+	// +kubebuilder:validation:Enum=AzureChina;AzureGovernment;AzurePublic
+	Cloud string `json:"cloud,omitempty"`
+	// ManagedIdentity defines the Azure User-assigned Managed identity.
+	ManagedIdentity ManagedIdentity `json:"managedIdentity"`
+}
+
+// ManagedIdentity defines the Azure User-assigned Managed identity.
+// +k8s:openapi-gen=true
+type ManagedIdentity struct {
+	// The client id of the managed identity.
+	ClientID string `json:"clientId"`
 }
 
 // QueueConfig allows the tuning of remote_write queue_config parameters. This object
@@ -586,11 +1313,119 @@ type RemoteReadSpec struct {
 	// bearer token for remote read.
 	BearerToken string `json:"bearerToken,omitempty"`
 	// File to read bearer token for remote read.
+	// Deprecated: use `authorization` instead.
 	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
+	// Authorization section for the URL. Cannot be set at the same time as
+	// basicAuth, bearerToken, bearerTokenFile or oauth2.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
 	// TLS Config to use for remote read.
 	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
 	// Optional ProxyURL
 	ProxyURL string `json:"proxyUrl,omitempty"`
+	// OAuth2 for the URL. Cannot be set at the same time as basicAuth,
+	// bearerToken or bearerTokenFile.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	OAuth2 *OAuth2 `json:"oauth2,omitempty"`
+	// Custom HTTP headers to be sent along with each remote read request.
+	// Headers that are set by Prometheus itself can't be overwritten.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Custom HTTP headers to be sent along with each remote read request,
+	// the values are read from Secrets in the Prometheus namespace. They take
+	// precedence over the headers with the same name.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	HeadersFromSecrets map[string]v1.SecretKeySelector `json:"headersFromSecrets,omitempty"`
+	// Whether to use the external labels as selectors for the remote read
+	// endpoint. Defaults to true.
+	// Only valid in Prometheus versions 2.34.0 and newer.
+	FilterExternalLabels *bool `json:"filterExternalLabels,omitempty"`
+}
+
+// FederationSpec defines the federation of the series of another Prometheus
+// instance managed by the operator.
+// +k8s:openapi-gen=true
+type FederationSpec struct {
+	// Name of the federated Prometheus object.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Namespace of the federated Prometheus object. Defaults to the
+	// namespace of the Prometheus object.
+	Namespace string `json:"namespace,omitempty"`
+	// Match is the list of series selectors sent as `match[]` parameters,
+	// e.g. `{__name__=~"job:.*"}` to federate the recording rules.
+	// +kubebuilder:validation:MinItems=1
+	Match []string `json:"match"`
+	// Name of the port of the governing Service to scrape. Defaults to `web`.
+	Port string `json:"port,omitempty"`
+	// Path of the federation endpoint. Defaults to `/federate`, it has to
+	// be set when the federated instance uses a route prefix.
+	Path string `json:"path,omitempty"`
+	// Interval at which the series are federated.
+	Interval string `json:"interval,omitempty"`
+	// Timeout after which the federation request is ended.
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// HTTP scheme used to federate the series.
+	// +kubebuilder:validation:Enum=http;https
+	Scheme string `json:"scheme,omitempty"`
+	// TLS configuration used to federate the series. The Secrets and
+	// ConfigMaps must be in the namespace of the Prometheus object.
+	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
+	// BasicAuth credentials used to federate the series. The Secrets must be
+	// in the namespace of the Prometheus object.
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// Authorization section used to federate the series. Cannot be set at
+	// the same time as basicAuth. The Secret must be in the namespace of the
+	// Prometheus object.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// MetricRelabelConfigs to apply to the federated series before ingestion.
+	MetricRelabelConfigs []*RelabelConfig `json:"metricRelabelings,omitempty"`
+}
+
+// RemoteReadLimits limits the resources used by Prometheus when serving
+// remote read requests.
+// +k8s:openapi-gen=true
+type RemoteReadLimits struct {
+	// Maximum overall number of samples to return via the remote read
+	// interface, in a single query. 0 means no limit. This limit is ignored
+	// for streamed response types.
+	// +kubebuilder:validation:Minimum=0
+	SampleLimit *int64 `json:"sampleLimit,omitempty"`
+	// Maximum number of concurrent remote read calls. 0 means no limit.
+	// +kubebuilder:validation:Minimum=0
+	ConcurrentLimit *int32 `json:"concurrentLimit,omitempty"`
+	// Maximum number of bytes in a single frame for streaming remote read
+	// response types, before marshalling.
+	// Only valid in Prometheus versions 2.13.0 and newer.
+	// +kubebuilder:validation:Minimum=1
+	MaxBytesInFrame *int64 `json:"maxBytesInFrame,omitempty"`
+}
+
+// ScrapeClass defines the scrape settings applied to the scrape
+// configurations generated from the monitoring objects referencing it.
+// +k8s:openapi-gen=true
+type ScrapeClass struct {
+	// Name of the scrape class.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Default marks the scrape class used by the monitoring objects which
+	// don't reference any scrape class. Only one scrape class can be the
+	// default.
+	Default bool `json:"default,omitempty"`
+	// TLSConfig used by the endpoints which don't define their own TLS
+	// configuration. The Secrets and ConfigMaps need to be in the same
+	// namespace as the Prometheus object.
+	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
+	// Authorization section used by the endpoints which don't define their
+	// own credentials. The Secret needs to be in the same namespace as the
+	// Prometheus object.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// Relabelings applied to the targets before the relabelings of the
+	// monitoring objects.
+	// More info: https://prometheus.io/docs/prometheus/latest/configuration/configuration/#relabel_config
+	Relabelings []*RelabelConfig `json:"relabelings,omitempty"`
 }
 
 // RelabelConfig allows dynamic rewriting of the label set, being applied to samples before ingestion.
@@ -659,8 +1494,29 @@ type AlertmanagerEndpoints struct {
 	APIVersion string `json:"apiVersion,omitempty"`
 	// Timeout is a per-target Alertmanager timeout when pushing alerts.
 	Timeout *string `json:"timeout,omitempty"`
+	// Discovery defines how Prometheus discovers the Alertmanager
+	// instances. Kubernetes (default) uses the Kubernetes service
+	// discovery of the Endpoints object. DNS resolves the Service name
+	// instead: a SRV lookup when the port is named, an A lookup otherwise.
+	// DNS doesn't require access to the Kubernetes API and should be used
+	// with a headless Service to discover every Alertmanager instance.
+	// +kubebuilder:validation:Enum=Kubernetes;DNS
+	Discovery AlertmanagerDiscovery `json:"discovery,omitempty"`
 }
 
+// AlertmanagerDiscovery is the mechanism used by Prometheus to discover
+// Alertmanager instances.
+type AlertmanagerDiscovery string
+
+const (
+	// AlertmanagerDiscoveryKubernetes discovers the Alertmanager instances
+	// from the Endpoints object.
+	AlertmanagerDiscoveryKubernetes AlertmanagerDiscovery = "Kubernetes"
+	// AlertmanagerDiscoveryDNS discovers the Alertmanager instances by
+	// resolving the Service name.
+	AlertmanagerDiscoveryDNS AlertmanagerDiscovery = "DNS"
+)
+
 // ServiceMonitor defines monitoring for a set of services.
 // +genclient
 // +k8s:openapi-gen=true
@@ -689,8 +1545,80 @@ type ServiceMonitorSpec struct {
 	NamespaceSelector NamespaceSelector `json:"namespaceSelector,omitempty"`
 	// SampleLimit defines per-scrape limit on number of scraped samples that will be accepted.
 	SampleLimit uint64 `json:"sampleLimit,omitempty"`
+	// LabelLimit defines the per-scrape limit on the number of labels that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelLimit uint64 `json:"labelLimit,omitempty"`
+	// LabelNameLengthLimit defines the per-scrape limit on the length of the label names that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelNameLengthLimit uint64 `json:"labelNameLengthLimit,omitempty"`
+	// LabelValueLengthLimit defines the per-scrape limit on the length of the label values that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelValueLengthLimit uint64 `json:"labelValueLengthLimit,omitempty"`
+	// KeepDroppedTargets defines the limit on the number of targets dropped by relabeling that will be kept in memory.
+	// Only valid in Prometheus versions 2.47.0 and newer.
+	KeepDroppedTargets uint64 `json:"keepDroppedTargets,omitempty"`
+	// ServiceDiscoveryRole overrides the Kubernetes service discovery role
+	// selected by the --service-discovery-role flag of the operator. The
+	// EndpointSlice role requires Prometheus 2.21.0 or newer and a cluster
+	// serving the EndpointSlice API, otherwise the Endpoints role is used.
+	// +kubebuilder:validation:Enum=Endpoints;EndpointSlice
+	ServiceDiscoveryRole *ServiceDiscoveryRole `json:"serviceDiscoveryRole,omitempty"`
+	// ScrapeClassName is the name of the scrape class of the Prometheus
+	// object applied to the generated scrape configuration. If empty, the
+	// default scrape class is used.
+	ScrapeClassName *string `json:"scrapeClass,omitempty"`
+	// AttachMetadata defines additional metadata which is attached to the
+	// discovered targets.
+	// Only valid in Prometheus versions 2.37.0 and newer.
+	AttachMetadata *AttachMetadata `json:"attachMetadata,omitempty"`
+	// SelectorMechanism defines how the targets are filtered by the
+	// selector. With `RelabelConfig` (default), Prometheus discovers all
+	// the endpoints of the selected namespaces and drops the ones not
+	// matching the selector with relabeling rules. With `RoleSelector`, the
+	// label selector is passed to the Kubernetes service discovery so that
+	// only the endpoints of the matching services are discovered, which
+	// reduces the load on Prometheus and on the API server.
+	// `RoleSelector` is only valid in Prometheus versions 2.17.0 and newer,
+	// older versions fall back to `RelabelConfig`.
+	// +kubebuilder:validation:Enum=RelabelConfig;RoleSelector
+	SelectorMechanism *SelectorMechanism `json:"selectorMechanism,omitempty"`
+}
+
+// AttachMetadata defines additional metadata attached to the discovered
+// targets.
+// +k8s:openapi-gen=true
+type AttachMetadata struct {
+	// Node attaches the labels of the node hosting the target, as
+	// __meta_kubernetes_node_label_* labels. The ServiceAccount of
+	// Prometheus must be allowed to list and watch the Nodes.
+	Node bool `json:"node,omitempty"`
 }
 
+// ServiceDiscoveryRole defines the Kubernetes service discovery role used to
+// discover the targets of a ServiceMonitor.
+type ServiceDiscoveryRole string
+
+const (
+	// EndpointsRole discovers the targets from the Endpoints objects.
+	EndpointsRole ServiceDiscoveryRole = "Endpoints"
+	// EndpointSliceRole discovers the targets from the EndpointSlice
+	// objects, which reduces the load on clusters with large Services.
+	EndpointSliceRole ServiceDiscoveryRole = "EndpointSlice"
+)
+
+// SelectorMechanism defines how the targets of a monitor are filtered by its
+// selector.
+type SelectorMechanism string
+
+const (
+	// SelectorMechanismRelabel filters the discovered targets with
+	// relabeling rules.
+	SelectorMechanismRelabel SelectorMechanism = "RelabelConfig"
+	// SelectorMechanismRole filters the targets in the Kubernetes service
+	// discovery with role selectors.
+	SelectorMechanismRole SelectorMechanism = "RoleSelector"
+)
+
 // Endpoint defines a scrapeable endpoint serving Prometheus metrics.
 // +k8s:openapi-gen=true
 type Endpoint struct {
@@ -711,11 +1639,28 @@ type Endpoint struct {
 	// TLS configuration to use when scraping the endpoint
 	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
 	// File to read bearer token for scraping targets.
+	// Deprecated: use `authorization` instead.
 	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
 	// Secret to mount to read bearer token for scraping targets. The secret
 	// needs to be in the same namespace as the service monitor and accessible by
 	// the Prometheus Operator.
 	BearerTokenSecret v1.SecretKeySelector `json:"bearerTokenSecret,omitempty"`
+	// BearerTokenBoundServiceAccount uses the bound ServiceAccount token of
+	// the Prometheus Pods as bearer token, see boundServiceAccountToken in
+	// the Prometheus spec. Cannot be set at the same time as
+	// bearerTokenFile, bearerTokenSecret, basicAuth, authorization or
+	// oauth2.
+	BearerTokenBoundServiceAccount bool `json:"bearerTokenBoundServiceAccount,omitempty"`
+	// Authorization section for this endpoint. Cannot be set at the same
+	// time as basicAuth, bearerTokenFile, bearerTokenSecret or oauth2.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// OAuth2 for this endpoint, using the client credentials grant. The
+	// secrets need to be in the same namespace as the service monitor.
+	// Cannot be set at the same time as basicAuth, bearerTokenFile or
+	// bearerTokenSecret.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	OAuth2 *OAuth2 `json:"oauth2,omitempty"`
 	// HonorLabels chooses the metric's labels on collisions with target labels.
 	HonorLabels bool `json:"honorLabels,omitempty"`
 	// HonorTimestamps controls whether Prometheus respects the timestamps present in scraped data.
@@ -730,6 +1675,20 @@ type Endpoint struct {
 	RelabelConfigs []*RelabelConfig `json:"relabelings,omitempty"`
 	// ProxyURL eg http://proxyserver:2195 Directs scrapes to proxy through this endpoint.
 	ProxyURL *string `json:"proxyUrl,omitempty"`
+	// ScrapeClassicHistograms instructs Prometheus to scrape the classic
+	// histograms which are also exposed as native histograms.
+	// Only valid in Prometheus versions 2.45.0 and newer.
+	ScrapeClassicHistograms *bool `json:"scrapeClassicHistograms,omitempty"`
+	// NativeHistogramBucketLimit defines the per-scrape limit on the number
+	// of buckets of the native histograms. If exceeded, the resolution of
+	// the histograms is reduced until the limit is met.
+	// Only valid in Prometheus versions 2.45.0 and newer.
+	NativeHistogramBucketLimit *uint64 `json:"nativeHistogramBucketLimit,omitempty"`
+	// ScrapeProtocols defines the protocols negotiated by Prometheus when
+	// scraping the endpoint, in order of preference. It overrides the
+	// protocols of the Prometheus resource.
+	// Only valid in Prometheus versions 2.49.0 and newer.
+	ScrapeProtocols []ScrapeProtocol `json:"scrapeProtocols,omitempty"`
 }
 
 // PodMonitor defines monitoring for a set of pods.
@@ -757,6 +1716,37 @@ type PodMonitorSpec struct {
 	NamespaceSelector NamespaceSelector `json:"namespaceSelector,omitempty"`
 	// SampleLimit defines per-scrape limit on number of scraped samples that will be accepted.
 	SampleLimit uint64 `json:"sampleLimit,omitempty"`
+	// LabelLimit defines the per-scrape limit on the number of labels that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelLimit uint64 `json:"labelLimit,omitempty"`
+	// LabelNameLengthLimit defines the per-scrape limit on the length of the label names that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelNameLengthLimit uint64 `json:"labelNameLengthLimit,omitempty"`
+	// LabelValueLengthLimit defines the per-scrape limit on the length of the label values that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelValueLengthLimit uint64 `json:"labelValueLengthLimit,omitempty"`
+	// KeepDroppedTargets defines the limit on the number of targets dropped by relabeling that will be kept in memory.
+	// Only valid in Prometheus versions 2.47.0 and newer.
+	KeepDroppedTargets uint64 `json:"keepDroppedTargets,omitempty"`
+	// ScrapeClassName is the name of the scrape class of the Prometheus
+	// object applied to the generated scrape configuration. If empty, the
+	// default scrape class is used.
+	ScrapeClassName *string `json:"scrapeClass,omitempty"`
+	// AttachMetadata defines additional metadata which is attached to the
+	// discovered targets.
+	// Only valid in Prometheus versions 2.35.0 and newer.
+	AttachMetadata *AttachMetadata `json:"attachMetadata,omitempty"`
+	// SelectorMechanism defines how the targets are filtered by the
+	// selector. With `RelabelConfig` (default), Prometheus discovers all
+	// the pods of the selected namespaces and drops the ones not matching
+	// the selector with relabeling rules. With `RoleSelector`, the label
+	// selector is passed to the Kubernetes service discovery so that only
+	// the matching pods are discovered, which reduces the load on
+	// Prometheus and on the API server.
+	// `RoleSelector` is only valid in Prometheus versions 2.17.0 and newer,
+	// older versions fall back to `RelabelConfig`.
+	// +kubebuilder:validation:Enum=RelabelConfig;RoleSelector
+	SelectorMechanism *SelectorMechanism `json:"selectorMechanism,omitempty"`
 }
 
 // PodMetricsEndpoint defines a scrapeable endpoint of a Kubernetes Pod serving Prometheus metrics.
@@ -787,6 +1777,122 @@ type PodMetricsEndpoint struct {
 	RelabelConfigs []*RelabelConfig `json:"relabelings,omitempty"`
 	// ProxyURL eg http://proxyserver:2195 Directs scrapes to proxy through this endpoint.
 	ProxyURL *string `json:"proxyUrl,omitempty"`
+	// Authorization section for this endpoint. The secret needs to be in the
+	// same namespace as the pod monitor. Cannot be set at the same time as
+	// oauth2.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// OAuth2 for this endpoint, using the client credentials grant. The
+	// secrets need to be in the same namespace as the pod monitor.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	OAuth2 *OAuth2 `json:"oauth2,omitempty"`
+	// ScrapeClassicHistograms instructs Prometheus to scrape the classic
+	// histograms which are also exposed as native histograms.
+	// Only valid in Prometheus versions 2.45.0 and newer.
+	ScrapeClassicHistograms *bool `json:"scrapeClassicHistograms,omitempty"`
+	// NativeHistogramBucketLimit defines the per-scrape limit on the number
+	// of buckets of the native histograms. If exceeded, the resolution of
+	// the histograms is reduced until the limit is met.
+	// Only valid in Prometheus versions 2.45.0 and newer.
+	NativeHistogramBucketLimit *uint64 `json:"nativeHistogramBucketLimit,omitempty"`
+	// ScrapeProtocols defines the protocols negotiated by Prometheus when
+	// scraping the endpoint, in order of preference. It overrides the
+	// protocols of the Prometheus resource.
+	// Only valid in Prometheus versions 2.49.0 and newer.
+	ScrapeProtocols []ScrapeProtocol `json:"scrapeProtocols,omitempty"`
+}
+
+// NodeMonitor defines monitoring for a set of Kubernetes nodes.
+// +genclient
+// +k8s:openapi-gen=true
+type NodeMonitor struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of desired Node selection for target discovery by Prometheus.
+	Spec NodeMonitorSpec `json:"spec"`
+}
+
+// NodeMonitorSpec contains specification parameters for a NodeMonitor.
+// +k8s:openapi-gen=true
+type NodeMonitorSpec struct {
+	// The label to use to retrieve the job name from.
+	JobLabel string `json:"jobLabel,omitempty"`
+	// NodeTargetLabels transfers labels on the Kubernetes Node onto the target.
+	NodeTargetLabels []string `json:"nodeTargetLabels,omitempty"`
+	// A list of endpoints allowed as part of this NodeMonitor.
+	Endpoints []NodeMetricsEndpoint `json:"endpoints"`
+	// Selector to select Node objects.
+	Selector metav1.LabelSelector `json:"selector"`
+	// SampleLimit defines per-scrape limit on number of scraped samples that will be accepted.
+	SampleLimit uint64 `json:"sampleLimit,omitempty"`
+	// LabelLimit defines the per-scrape limit on the number of labels that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelLimit uint64 `json:"labelLimit,omitempty"`
+	// LabelNameLengthLimit defines the per-scrape limit on the length of the label names that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelNameLengthLimit uint64 `json:"labelNameLengthLimit,omitempty"`
+	// LabelValueLengthLimit defines the per-scrape limit on the length of the label values that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelValueLengthLimit uint64 `json:"labelValueLengthLimit,omitempty"`
+	// KeepDroppedTargets defines the limit on the number of targets dropped by relabeling that will be kept in memory.
+	// Only valid in Prometheus versions 2.47.0 and newer.
+	KeepDroppedTargets uint64 `json:"keepDroppedTargets,omitempty"`
+}
+
+// NodeMetricsEndpoint defines a scrapeable endpoint of a Kubernetes Node serving Prometheus metrics.
+// +k8s:openapi-gen=true
+type NodeMetricsEndpoint struct {
+	// Port number on the node to scrape. If empty, the Kubelet port is used.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port *int32 `json:"port,omitempty"`
+	// Node address type used to reach the target. If empty, the address
+	// chosen by the Prometheus Kubernetes service discovery is used (usually
+	// the InternalIP).
+	// +kubebuilder:validation:Enum=InternalIP;ExternalIP;Hostname;InternalDNS;ExternalDNS
+	AddressType string `json:"addressType,omitempty"`
+	// HTTP path to scrape for metrics.
+	Path string `json:"path,omitempty"`
+	// HTTP scheme to use for scraping.
+	Scheme string `json:"scheme,omitempty"`
+	// Optional HTTP URL parameters
+	Params map[string][]string `json:"params,omitempty"`
+	// Interval at which metrics should be scraped
+	Interval string `json:"interval,omitempty"`
+	// Timeout after which the scrape is ended
+	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// TLS configuration to use when scraping the endpoint
+	TLSConfig *TLSConfig `json:"tlsConfig,omitempty"`
+	// File to read bearer token for scraping targets.
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
+	// Secret to mount to read bearer token for scraping targets. The secret
+	// needs to be in the same namespace as the node monitor and accessible by
+	// the Prometheus Operator.
+	BearerTokenSecret v1.SecretKeySelector `json:"bearerTokenSecret,omitempty"`
+	// BearerTokenBoundServiceAccount uses the bound ServiceAccount token of
+	// the Prometheus Pods as bearer token, see boundServiceAccountToken in
+	// the Prometheus spec. Cannot be set at the same time as
+	// bearerTokenFile, bearerTokenSecret, basicAuth or authorization.
+	BearerTokenBoundServiceAccount bool `json:"bearerTokenBoundServiceAccount,omitempty"`
+	// BasicAuth allow an endpoint to authenticate over basic authentication
+	// More info: https://prometheus.io/docs/operating/configuration/#endpoints
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// Authorization section for this endpoint. The Secret needs to be in the
+	// same namespace as the node monitor. Cannot be set at the same time as
+	// basicAuth, bearerTokenFile or bearerTokenSecret.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// HonorLabels chooses the metric's labels on collisions with target labels.
+	HonorLabels bool `json:"honorLabels,omitempty"`
+	// HonorTimestamps controls whether Prometheus respects the timestamps present in scraped data.
+	HonorTimestamps *bool `json:"honorTimestamps,omitempty"`
+	// MetricRelabelConfigs to apply to samples before ingestion.
+	MetricRelabelConfigs []*RelabelConfig `json:"metricRelabelings,omitempty"`
+	// RelabelConfigs to apply to samples before ingestion.
+	// More info: https://prometheus.io/docs/prometheus/latest/configuration/configuration/#relabel_config
+	RelabelConfigs []*RelabelConfig `json:"relabelings,omitempty"`
+	// ProxyURL eg http://proxyserver:2195 Directs scrapes to proxy through this endpoint.
+	ProxyURL *string `json:"proxyUrl,omitempty"`
 }
 
 // Probe defines monitoring for a set of static targets or ingresses.
@@ -818,6 +1924,31 @@ type ProbeSpec struct {
 	Interval string `json:"interval,omitempty"`
 	// Timeout for scraping metrics from the Prometheus exporter.
 	ScrapeTimeout string `json:"scrapeTimeout,omitempty"`
+	// SampleLimit defines per-scrape limit on number of scraped samples that will be accepted.
+	SampleLimit uint64 `json:"sampleLimit,omitempty"`
+	// TargetLimit defines a limit on the number of scraped targets that will be accepted.
+	// Only valid in Prometheus versions 2.21.0 and newer.
+	TargetLimit uint64 `json:"targetLimit,omitempty"`
+	// LabelLimit defines the per-scrape limit on the number of labels that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelLimit uint64 `json:"labelLimit,omitempty"`
+	// LabelNameLengthLimit defines the per-scrape limit on the length of the label names that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelNameLengthLimit uint64 `json:"labelNameLengthLimit,omitempty"`
+	// LabelValueLengthLimit defines the per-scrape limit on the length of the label values that will be accepted for a sample.
+	// Only valid in Prometheus versions 2.27.0 and newer.
+	LabelValueLengthLimit uint64 `json:"labelValueLengthLimit,omitempty"`
+	// KeepDroppedTargets defines the limit on the number of targets dropped by relabeling that will be kept in memory.
+	// Only valid in Prometheus versions 2.47.0 and newer.
+	KeepDroppedTargets uint64 `json:"keepDroppedTargets,omitempty"`
+	// Authorization section for the prober. The secret needs to be in the
+	// same namespace as the probe.
+	// Only valid in Prometheus versions 2.26.0 and newer.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// ScrapeClassName is the name of the scrape class of the Prometheus
+	// object applied to the generated scrape configuration. If empty, the
+	// default scrape class is used.
+	ScrapeClassName *string `json:"scrapeClass,omitempty"`
 }
 
 // ProbeTargets defines a set of static and dynamically discovered targets for the prober.
@@ -837,6 +1968,27 @@ type ProbeTargetStaticConfig struct {
 	Targets []string `json:"static,omitempty"`
 	// Labels assigned to all metrics scraped from the targets.
 	Labels map[string]string `json:"labels,omitempty"`
+	// StaticTargets is a list of targets with their own labels and prober
+	// parameters, probed in addition to the static targets. It allows a
+	// single Probe to cover targets requiring different prober modules.
+	StaticTargets []ProbeStaticTarget `json:"staticTargets,omitempty"`
+}
+
+// ProbeStaticTarget defines a static target with its own labels and prober
+// parameters.
+// +k8s:openapi-gen=true
+type ProbeStaticTarget struct {
+	// URL to probe using the configured prober.
+	URL string `json:"url"`
+	// Labels assigned to all metrics scraped from the target, in addition
+	// to the labels of the static config.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Module overrides the module of the Probe for the target.
+	// When set, the probed module is exposed by the `module` label.
+	Module string `json:"module,omitempty"`
+	// Params are additional URL parameters sent to the prober for the
+	// target. The `module` and `target` parameters can't be overridden.
+	Params map[string]string `json:"params,omitempty"`
 }
 
 // ProbeTargetIngress defines the set of Ingress objects considered for probing.
@@ -876,6 +2028,154 @@ type BasicAuth struct {
 	Password v1.SecretKeySelector `json:"password,omitempty"`
 }
 
+// WebSpec defines the configuration of the web server, written to the file
+// passed to the --web.config.file flag.
+// The probes use HTTPS when TLS is enabled and TCP checks when basic
+// authentication is enabled. The config reloader and Thanos sidecars don't
+// support TLS nor authentication: the configuration is then only reloaded
+// when the Pods restart, except for Prometheus with TLS and the HTTP reload
+// strategy.
+// More info: https://prometheus.io/docs/prometheus/latest/configuration/https/
+// +k8s:openapi-gen=true
+type WebSpec struct {
+	// TLSConfig enables HTTPS for the web server.
+	TLSConfig *WebTLSConfig `json:"tlsConfig,omitempty"`
+	// HTTPConfig defines the HTTP settings of the web server.
+	HTTPConfig *WebHTTPConfig `json:"httpConfig,omitempty"`
+	// BasicAuthUsers is the list of users allowed to access the web server
+	// with basic authentication. When empty, basic authentication is
+	// disabled.
+	BasicAuthUsers []WebBasicAuthUser `json:"basicAuthUsers,omitempty"`
+}
+
+// WebTLSConfig defines the TLS settings of the web server.
+// +k8s:openapi-gen=true
+type WebTLSConfig struct {
+	// Secret or ConfigMap key containing the TLS certificate of the server.
+	Cert SecretOrConfigMap `json:"cert"`
+	// Secret key containing the TLS private key of the server.
+	KeySecret v1.SecretKeySelector `json:"keySecret"`
+	// Secret or ConfigMap key containing the CA certificate used to verify
+	// the client certificates.
+	ClientCA *SecretOrConfigMap `json:"clientCA,omitempty"`
+	// ClientAuthType is the policy for the client certificates. Defaults to
+	// RequireAndVerifyClientCert when clientCA is set, NoClientCert otherwise.
+	// +kubebuilder:validation:Enum=NoClientCert;RequestClientCert;RequireAnyClientCert;VerifyClientCertIfGiven;RequireAndVerifyClientCert
+	ClientAuthType string `json:"clientAuthType,omitempty"`
+	// MinVersion is the minimum TLS version accepted. Defaults to TLS12.
+	// +kubebuilder:validation:Enum=TLS10;TLS11;TLS12;TLS13
+	MinVersion string `json:"minVersion,omitempty"`
+	// MaxVersion is the maximum TLS version accepted. Defaults to TLS13.
+	// +kubebuilder:validation:Enum=TLS10;TLS11;TLS12;TLS13
+	MaxVersion string `json:"maxVersion,omitempty"`
+	// CipherSuites is the list of cipher suites accepted for TLS versions up
+	// to TLS12, for instance TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Defaults
+	// to the Go default cipher suites.
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+	// PreferServerCipherSuites makes the server select the cipher suite
+	// instead of the client.
+	PreferServerCipherSuites *bool `json:"preferServerCipherSuites,omitempty"`
+	// CurvePreferences is the list of elliptic curves used in an ECDHE
+	// handshake, in preference order, for instance X25519 or CurveP256.
+	CurvePreferences []string `json:"curvePreferences,omitempty"`
+}
+
+// WebHTTPConfig defines the HTTP settings of the web server.
+// +k8s:openapi-gen=true
+type WebHTTPConfig struct {
+	// HTTP2 enables HTTP/2 support. It only applies with TLS. Defaults to
+	// true.
+	HTTP2 *bool `json:"http2,omitempty"`
+}
+
+// WebBasicAuthUser defines a user allowed to access the web server.
+// +k8s:openapi-gen=true
+type WebBasicAuthUser struct {
+	// Username of the user.
+	Username string `json:"username"`
+	// Secret key containing the bcrypt hash of the password of the user.
+	PasswordHash v1.SecretKeySelector `json:"passwordHash"`
+}
+
+// DatasourceExportSpec defines the Grafana datasource exported in a
+// ConfigMap, in the format of the Grafana provisioning files. The ConfigMap
+// can be loaded by the Grafana datasources sidecar or mounted into the
+// `provisioning/datasources` directory of Grafana.
+// More info: https://grafana.com/docs/grafana/latest/administration/provisioning/#data-sources
+// +k8s:openapi-gen=true
+type DatasourceExportSpec struct {
+	// Name of the datasource. Defaults to `<namespace>/<name>` of the object.
+	Name string `json:"name,omitempty"`
+	// URL of the datasource. Defaults to the URL of the governing service,
+	// qualified with the cluster domain of the operator when set, with the
+	// HTTPS scheme when TLS is enabled on the web server.
+	URL string `json:"url,omitempty"`
+	// Labels added to the ConfigMap, for instance to match the label
+	// selector of the Grafana datasources sidecar. Defaults to
+	// `grafana_datasource: "1"`.
+	Labels map[string]string `json:"labels,omitempty"`
+	// HTTPHeaders are sent by Grafana with the requests to the datasource,
+	// for instance the tenant header of a multi-tenant proxy. The values are
+	// stored in clear text in the ConfigMap.
+	HTTPHeaders map[string]string `json:"httpHeaders,omitempty"`
+	// InsecureSkipVerify disables the verification of the server
+	// certificate by Grafana.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// Authorization configures the credentials sent in the Authorization header
+// of the requests.
+// More info: https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+// +k8s:openapi-gen=true
+type Authorization struct {
+	// Type of the authentication, e.g. `Bearer`. Defaults to `Bearer`.
+	// `Basic` isn't supported, use `basicAuth` instead.
+	Type string `json:"type,omitempty"`
+	// The secret containing the credentials of the request.
+	Credentials *v1.SecretKeySelector `json:"credentials,omitempty"`
+}
+
+// AuthorizationValidationError is returned by Authorization.Validate() on
+// semantically invalid configurations.
+// +k8s:openapi-gen=false
+type AuthorizationValidationError struct {
+	err string
+}
+
+func (e *AuthorizationValidationError) Error() string {
+	return e.err
+}
+
+// Validate semantically validates the given Authorization configuration.
+func (a *Authorization) Validate() error {
+	if strings.ToLower(strings.TrimSpace(a.Type)) == "basic" {
+		return &AuthorizationValidationError{"Authorization type cannot be set to \"basic\", use \"basicAuth\" instead"}
+	}
+
+	if a.Credentials == nil || a.Credentials.Name == "" {
+		return &AuthorizationValidationError{"Authorization credentials must be specified"}
+	}
+
+	return nil
+}
+
+// OAuth2 allows an endpoint to authenticate with OAuth2.
+// More info: https://prometheus.io/docs/prometheus/latest/configuration/configuration/#oauth2
+// +k8s:openapi-gen=true
+type OAuth2 struct {
+	// The secret or configmap containing the OAuth2 client id.
+	ClientID SecretOrConfigMap `json:"clientId"`
+	// The secret containing the OAuth2 client secret.
+	ClientSecret v1.SecretKeySelector `json:"clientSecret"`
+	// The URL to fetch the token from.
+	// +kubebuilder:validation:MinLength=1
+	TokenURL string `json:"tokenUrl"`
+	// OAuth2 scopes used for the token request.
+	Scopes []string `json:"scopes,omitempty"`
+	// Parameters to append to the token URL.
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+}
+
 // SecretOrConfigMap allows to specify data as a Secret or ConfigMap. Fields are mutually exclusive.
 type SecretOrConfigMap struct {
 	// Secret containing data to use for the targets.
@@ -904,6 +2204,38 @@ func (c *SecretOrConfigMap) Validate() error {
 	return nil
 }
 
+// OAuth2ValidationError is returned by OAuth2.Validate() on semantically
+// invalid configurations.
+// +k8s:openapi-gen=false
+type OAuth2ValidationError struct {
+	err string
+}
+
+func (e *OAuth2ValidationError) Error() string {
+	return e.err
+}
+
+// Validate semantically validates the given OAuth2 configuration.
+func (o *OAuth2) Validate() error {
+	if o.TokenURL == "" {
+		return &OAuth2ValidationError{"OAuth2 token url must be specified"}
+	}
+
+	if o.ClientID == (SecretOrConfigMap{}) {
+		return &OAuth2ValidationError{"OAuth2 client id must be specified"}
+	}
+
+	if err := o.ClientID.Validate(); err != nil {
+		return &OAuth2ValidationError{"invalid OAuth2 client id: " + err.Error()}
+	}
+
+	if o.ClientSecret.Name == "" {
+		return &OAuth2ValidationError{"OAuth2 client secret must be specified"}
+	}
+
+	return nil
+}
+
 // TLSConfig specifies TLS configuration parameters.
 // +k8s:openapi-gen=true
 type TLSConfig struct {
@@ -922,12 +2254,38 @@ type TLSConfig struct {
 	// Secret containing the client key file for the targets.
 	KeySecret *v1.SecretKeySelector `json:"keySecret,omitempty"`
 
+	// CertManagerRef references a Secret issued by a cert-manager Certificate
+	// providing the CA, client certificate and key for the targets. When the
+	// certificate is renewed, the operator updates the TLS assets and triggers
+	// a configuration reload. Renewals of Secrets outside of the namespace of
+	// the Prometheus object are detected at the next resync of the operator.
+	// Mutually exclusive with the other CA, cert and key fields. Only
+	// supported by scrape classes, ServiceMonitor and NodeMonitor endpoints,
+	// federations and remote write.
+	CertManagerRef *CertManagerReference `json:"certManagerRef,omitempty"`
+
 	// Used to verify the hostname for the targets.
 	ServerName string `json:"serverName,omitempty"`
 	// Disable target certificate validation.
 	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 }
 
+// CertManagerReference references the Secret managed by a cert-manager
+// Certificate. The Secret must be in the same namespace as the object
+// referencing it and follow the `kubernetes.io/tls` layout written by
+// cert-manager (`tls.crt`, `tls.key` and optionally `ca.crt`).
+// More info: https://cert-manager.io/docs/concepts/certificate/
+// +k8s:openapi-gen=true
+type CertManagerReference struct {
+	// Name of the Secret referenced by the `spec.secretName` field of the
+	// cert-manager Certificate.
+	SecretName string `json:"secretName"`
+	// IgnoreCA disables the use of the `ca.crt` key of the Secret to verify
+	// the targets. This is required for issuers not populating the key, such
+	// as ACME issuers.
+	IgnoreCA bool `json:"ignoreCA,omitempty"`
+}
+
 // TLSConfigValidationError is returned by TLSConfig.Validate() on semantically
 // invalid tls configurations.
 // +k8s:openapi-gen=false
@@ -963,6 +2321,17 @@ func (c *TLSConfig) Validate() error {
 		return &TLSConfigValidationError{"tls config can not both specify KeyFile and KeySecret"}
 	}
 
+	if c.CertManagerRef != nil {
+		if c.CertManagerRef.SecretName == "" {
+			return &TLSConfigValidationError{"tls config must specify the secret name of the cert-manager reference"}
+		}
+		if c.CAFile != "" || c.CA != (SecretOrConfigMap{}) ||
+			c.CertFile != "" || c.Cert != (SecretOrConfigMap{}) ||
+			c.KeyFile != "" || c.KeySecret != nil {
+			return &TLSConfigValidationError{"tls config can not both specify CertManagerRef and any of the CA, Cert or Key fields"}
+		}
+	}
+
 	return nil
 }
 
@@ -988,6 +2357,17 @@ type PodMonitorList struct {
 	Items []*PodMonitor `json:"items"`
 }
 
+// NodeMonitorList is a list of NodeMonitors.
+// +k8s:openapi-gen=true
+type NodeMonitorList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of NodeMonitors
+	Items []*NodeMonitor `json:"items"`
+}
+
 // ProbeList is a list of Probes.
 // +k8s:openapi-gen=true
 type ProbeList struct {
@@ -1013,11 +2393,41 @@ type PrometheusRuleList struct {
 // PrometheusRule defines alerting rules for a Prometheus instance
 // +genclient
 // +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
 type PrometheusRule struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of desired alerting rule definitions for Prometheus.
 	Spec PrometheusRuleSpec `json:"spec"`
+	// Most recent observed status of the PrometheusRule. Read-only.
+	// Updated by the operator through the status subresource.
+	Status *PrometheusRuleStatus `json:"status,omitempty"`
+}
+
+// PrometheusRuleStatus is the most recent observed status of the
+// PrometheusRule. Read-only.
+// +k8s:openapi-gen=true
+type PrometheusRuleStatus struct {
+	// The state of the PrometheusRule for each Prometheus instance selecting
+	// it.
+	// +listType=map
+	// +listMapKey=namespace
+	// +listMapKey=name
+	Prometheuses []PrometheusRuleBinding `json:"prometheuses,omitempty"`
+}
+
+// PrometheusRuleBinding is the state of a PrometheusRule for one of the
+// Prometheus instances selecting it.
+// +k8s:openapi-gen=true
+type PrometheusRuleBinding struct {
+	// Namespace of the Prometheus object.
+	Namespace string `json:"namespace"`
+	// Name of the Prometheus object.
+	Name string `json:"name"`
+	// The current state of the PrometheusRule for the Prometheus instance.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
 // PrometheusRuleSpec contains specification parameters for a Rule.
@@ -1059,15 +2469,16 @@ type Rule struct {
 // +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".spec.version",description="The version of Alertmanager"
 // +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".spec.replicas",description="The desired replicas number of Alertmanagers"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 type Alertmanager struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of the desired behavior of the Alertmanager cluster. More info:
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 	Spec AlertmanagerSpec `json:"spec"`
-	// Most recent observed status of the Alertmanager cluster. Read-only. Not
-	// included when requesting from the apiserver, only from the Prometheus
-	// Operator API itself. More info:
+	// Most recent observed status of the Alertmanager cluster. Read-only. Updated
+	// by the operator through the status subresource. More info:
 	// https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 	Status *AlertmanagerStatus `json:"status,omitempty"`
 }
@@ -1116,6 +2527,20 @@ type AlertmanagerSpec struct {
 	// instance. Defaults to 'alertmanager-<alertmanager-name>'
 	// The secret is mounted into /etc/alertmanager/config.
 	ConfigSecret string `json:"configSecret,omitempty"`
+	// AlertmanagerConfiguration defines settings managed by the operator
+	// which are merged with the base configuration from configSecret. When
+	// set, the operator writes the merged configuration to the
+	// `alertmanager-<alertmanager-name>-generated` Secret which is mounted
+	// instead of configSecret.
+	AlertmanagerConfiguration *AlertmanagerConfiguration `json:"alertmanagerConfiguration,omitempty"`
+	// Templates is a list of ConfigMap or Secret keys in the same namespace
+	// as the Alertmanager object containing notification templates. They are
+	// mounted into /etc/alertmanager/templates and added to the `templates`
+	// section of the configuration, which is written to the
+	// `alertmanager-<alertmanager-name>-generated` Secret like with
+	// alertmanagerConfiguration. Alertmanager is reloaded when the templates
+	// change.
+	Templates []SecretOrConfigMap `json:"templates,omitempty"`
 	// Log level for Alertmanager to be configured with.
 	LogLevel string `json:"logLevel,omitempty"`
 	// Log format for Alertmanager to be configured with.
@@ -1161,13 +2586,33 @@ type AlertmanagerSpec struct {
 	// SecurityContext holds pod-level security attributes and common container settings.
 	// This defaults to the default PodSecurityContext.
 	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+	// ConfigReloaderSecurityContext defines the security context of the
+	// `config-reloader` container. It takes precedence over the settings of
+	// the pod security context.
+	ConfigReloaderSecurityContext *v1.SecurityContext `json:"configReloaderSecurityContext,omitempty"`
 	// ServiceAccountName is the name of the ServiceAccount to use to run the
 	// Prometheus Pods.
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ManageServiceAccount instructs the operator to create the ServiceAccount
+	// used to run the Alertmanager Pods and to keep its labels and annotations up
+	// to date. The ServiceAccount is named after serviceAccountName or
+	// defaults to `alertmanager-<name>` if empty.
+	ManageServiceAccount bool `json:"manageServiceAccount,omitempty"`
+	// ServiceAccountAnnotations are added to the managed ServiceAccount, for
+	// instance to bind it to a cloud provider identity (e.g. GKE Workload
+	// Identity or EKS IAM roles for service accounts).
+	// Only used when manageServiceAccount is true.
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
+	// ServiceAccountLabels are added to the managed ServiceAccount.
+	// Only used when manageServiceAccount is true.
+	ServiceAccountLabels map[string]string `json:"serviceAccountLabels,omitempty"`
 	// ListenLocal makes the Alertmanager server listen on loopback, so that it
 	// does not bind against the Pod IP. Note this is only for the Alertmanager
 	// UI, not the gossip communication.
 	ListenLocal bool `json:"listenLocal,omitempty"`
+	// Web defines the TLS, HTTP and basic authentication settings of the
+	// web server. Only valid in Alertmanager versions 0.22.0 and newer.
+	Web *WebSpec `json:"web,omitempty"`
 	// Containers allows injecting additional containers. This is meant to
 	// allow adding an authentication proxy to an Alertmanager pod.
 	Containers []v1.Container `json:"containers,omitempty"`
@@ -1178,14 +2623,51 @@ type AlertmanagerSpec struct {
 	// of what the maintainers will support and by doing so, you accept that this behaviour may break
 	// at any time without notice.
 	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// StatefulSetPatch is a strategic merge patch applied to the StatefulSet
+	// generated by the operator for the Alertmanager, as the final step of its
+	// generation. It allows setting fields which aren't exposed by the Alertmanager
+	// resource (e.g. new Kubernetes fields). Patching the StatefulSet is entirely
+	// outside the scope of what the maintainers will support and by doing so, you
+	// accept that this behaviour may break at any time without notice.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	StatefulSetPatch *runtime.RawExtension `json:"statefulSetPatch,omitempty"`
 	// Priority class assigned to the Pods
 	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// HostAliases are the entries added to the hosts file of the Pods, for
+	// instance to resolve names which aren't resolvable by the cluster DNS.
+	HostAliases []v1.HostAlias `json:"hostAliases,omitempty"`
 	// AdditionalPeers allows injecting a set of additional Alertmanagers to peer with to form a highly available cluster.
 	AdditionalPeers []string `json:"additionalPeers,omitempty"`
+	// ClusterPeers defines Alertmanager peers running outside of this
+	// Alertmanager, e.g. in other Kubernetes clusters, to form an active/active
+	// cluster across regions. Setting it enables the cluster mode even with a
+	// single replica. The gossip traffic between the clusters can be secured
+	// with clusterTLS and the peers usually need clusterAdvertiseAddress to
+	// reach back this Alertmanager.
+	ClusterPeers []ClusterPeer `json:"clusterPeers,omitempty"`
 	// ClusterAdvertiseAddress is the explicit address to advertise in cluster.
 	// Needs to be provided for non RFC1918 [1] (public) addresses.
 	// [1] RFC1918: https://tools.ietf.org/html/rfc1918
 	ClusterAdvertiseAddress string `json:"clusterAdvertiseAddress,omitempty"`
+	// ClusterTLS configures mutual TLS for the gossip traffic between the
+	// Alertmanager replicas. Only valid in Alertmanager versions 0.24.0 and newer.
+	ClusterTLS *ClusterTLSConfig `json:"clusterTLS,omitempty"`
+	// ClusterGossipInterval is the interval between the gossip messages
+	// sent to the peers, as a Go duration (e.g. `200ms`). Lower values
+	// propagate the silences and the notification log faster at the cost of
+	// more traffic. It must be lower than clusterPushPullInterval.
+	// Requires the cluster mode and Alertmanager versions 0.15.0 and newer.
+	ClusterGossipInterval string `json:"clusterGossipInterval,omitempty"`
+	// ClusterPushPullInterval is the interval between the full state
+	// synchronizations with the peers, as a Go duration (e.g. `1m`).
+	// Requires the cluster mode and Alertmanager versions 0.15.0 and newer.
+	ClusterPushPullInterval string `json:"clusterPushPullInterval,omitempty"`
+	// ClusterPeerTimeout is the time a replica waits for the replicas
+	// preceding it in the cluster to send a notification before sending it,
+	// as a Go duration (e.g. `15s`). The last replica waits this timeout
+	// multiplied by the number of replicas minus one.
+	// Requires the cluster mode and Alertmanager versions 0.15.0 and newer.
+	ClusterPeerTimeout string `json:"clusterPeerTimeout,omitempty"`
 	// Port name used for the pods and governing service.
 	// This defaults to web
 	PortName string `json:"portName,omitempty"`
@@ -1194,6 +2676,129 @@ type AlertmanagerSpec struct {
 	ForceEnableClusterMode bool `json:"forceEnableClusterMode,omitempty"`
 }
 
+// AlertmanagerMergeStrategy defines how the operator-managed settings are
+// merged with the base Alertmanager configuration.
+type AlertmanagerMergeStrategy string
+
+const (
+	// AlertmanagerMergeStrategyMerge keeps the values of the base
+	// configuration when they conflict with the operator-managed settings.
+	AlertmanagerMergeStrategyMerge AlertmanagerMergeStrategy = "Merge"
+	// AlertmanagerMergeStrategyOverride replaces the values of the base
+	// configuration with the operator-managed settings.
+	AlertmanagerMergeStrategyOverride AlertmanagerMergeStrategy = "Override"
+)
+
+// AlertmanagerConfiguration defines the Alertmanager configuration settings
+// managed by the operator.
+// +k8s:openapi-gen=true
+type AlertmanagerConfiguration struct {
+	// Global defines the parameters of the `global` section.
+	Global *AlertmanagerGlobalConfig `json:"global,omitempty"`
+	// MergeStrategy defines which value wins when a setting is defined both
+	// in the base configuration and by the operator. Conflicts are reported
+	// by the ConfigurationMerged status condition. Defaults to Merge.
+	// +kubebuilder:validation:Enum=Merge;Override
+	MergeStrategy AlertmanagerMergeStrategy `json:"mergeStrategy,omitempty"`
+}
+
+// AlertmanagerGlobalConfig defines the global parameters of the Alertmanager
+// configuration.
+// See https://prometheus.io/docs/alerting/latest/configuration/#configuration-file
+// +k8s:openapi-gen=true
+type AlertmanagerGlobalConfig struct {
+	// ResolveTimeout is the default time after which an alert is declared
+	// resolved if it hasn't been updated.
+	ResolveTimeout string `json:"resolveTimeout,omitempty"`
+	// SMTPFrom is the default SMTP From header field.
+	SMTPFrom string `json:"smtpFrom,omitempty"`
+	// SMTPSmarthost is the default SMTP smarthost used for sending emails,
+	// including the port number.
+	SMTPSmarthost string `json:"smtpSmarthost,omitempty"`
+	// SMTPHello is the default hostname to identify to the SMTP server.
+	SMTPHello string `json:"smtpHello,omitempty"`
+	// SMTPAuthUsername is the username for SMTP authentication.
+	SMTPAuthUsername string `json:"smtpAuthUsername,omitempty"`
+	// SMTPAuthPassword references the Secret key containing the password
+	// for SMTP authentication.
+	SMTPAuthPassword *v1.SecretKeySelector `json:"smtpAuthPassword,omitempty"`
+	// SMTPRequireTLS defines whether TLS is required by default.
+	SMTPRequireTLS *bool `json:"smtpRequireTLS,omitempty"`
+	// SlackAPIURL references the Secret key containing the default Slack
+	// webhook URL.
+	SlackAPIURL *v1.SecretKeySelector `json:"slackAPIURL,omitempty"`
+	// PagerdutyURL is the default PagerDuty API URL.
+	PagerdutyURL string `json:"pagerdutyURL,omitempty"`
+	// OpsGenieAPIURL is the default OpsGenie API URL.
+	OpsGenieAPIURL string `json:"opsGenieAPIURL,omitempty"`
+}
+
+// ClusterPeer defines an Alertmanager peer running outside of the
+// Alertmanager StatefulSet. Exactly one of address and service must be set.
+// +k8s:openapi-gen=true
+type ClusterPeer struct {
+	// Address of the peer in the host:port form, e.g. an external DNS name
+	// resolving to the Alertmanager replicas of another cluster.
+	Address string `json:"address,omitempty"`
+	// Service references the governing Service of Alertmanager replicas
+	// running in another Kubernetes cluster.
+	Service *ClusterPeerService `json:"service,omitempty"`
+}
+
+// ClusterPeerService references a Service in another Kubernetes cluster.
+// +k8s:openapi-gen=true
+type ClusterPeerService struct {
+	// Name of the Service.
+	Name string `json:"name"`
+	// Namespace of the Service.
+	Namespace string `json:"namespace"`
+	// ClusterDomain is the DNS domain of the other cluster, resolvable from
+	// this cluster.
+	ClusterDomain string `json:"clusterDomain"`
+	// Port of the gossip traffic. Defaults to 9094.
+	Port *int32 `json:"port,omitempty"`
+}
+
+// ClusterTLSConfig defines the mutual TLS configuration of the gossip
+// traffic between the Alertmanager replicas.
+// +k8s:openapi-gen=true
+type ClusterTLSConfig struct {
+	// Server defines the TLS configuration for the incoming connections.
+	Server ClusterTLSServerConfig `json:"server"`
+	// Client defines the TLS configuration for the outgoing connections.
+	Client ClusterTLSClientConfig `json:"client"`
+}
+
+// ClusterTLSServerConfig defines the server side of the cluster TLS
+// configuration.
+// +k8s:openapi-gen=true
+type ClusterTLSServerConfig struct {
+	// Secret key containing the TLS certificate of the server.
+	Cert v1.SecretKeySelector `json:"cert"`
+	// Secret key containing the TLS private key of the server.
+	KeySecret v1.SecretKeySelector `json:"keySecret"`
+	// Secret key containing the CA certificate used to verify the client
+	// certificates. When set, the clients must present a valid certificate.
+	ClientCA *v1.SecretKeySelector `json:"clientCA,omitempty"`
+}
+
+// ClusterTLSClientConfig defines the client side of the cluster TLS
+// configuration.
+// +k8s:openapi-gen=true
+type ClusterTLSClientConfig struct {
+	// Secret key containing the TLS certificate of the client.
+	Cert v1.SecretKeySelector `json:"cert"`
+	// Secret key containing the TLS private key of the client.
+	KeySecret v1.SecretKeySelector `json:"keySecret"`
+	// Secret key containing the CA certificate used to verify the server
+	// certificates.
+	CA *v1.SecretKeySelector `json:"ca,omitempty"`
+	// Used to verify the hostname of the server certificates.
+	ServerName string `json:"serverName,omitempty"`
+	// Disable the verification of the server certificates.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
 // AlertmanagerList is a list of Alertmanagers.
 // +k8s:openapi-gen=true
 type AlertmanagerList struct {
@@ -1205,9 +2810,8 @@ type AlertmanagerList struct {
 	Items []Alertmanager `json:"items"`
 }
 
-// AlertmanagerStatus is the most recent observed status of the Alertmanager cluster. Read-only. Not
-// included when requesting from the apiserver, only from the Prometheus
-// Operator API itself. More info:
+// AlertmanagerStatus is the most recent observed status of the Alertmanager cluster. Read-only.
+// Updated by the operator through the status subresource. More info:
 // https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
 // +k8s:openapi-gen=true
 type AlertmanagerStatus struct {
@@ -1225,6 +2829,94 @@ type AlertmanagerStatus struct {
 	AvailableReplicas int32 `json:"availableReplicas"`
 	// Total number of unavailable pods targeted by this Alertmanager cluster.
 	UnavailableReplicas int32 `json:"unavailableReplicas"`
+	// The label selector of the pods targeted by this Alertmanager cluster,
+	// in string form. Used by the scale subresource.
+	Selector string `json:"selector,omitempty"`
+	// The current state of the Alertmanager cluster.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// ConditionType is the type of a status condition.
+type ConditionType string
+
+const (
+	// Available indicates whether enough pods are ready to provide the
+	// service.
+	// The possible status values for this condition type are:
+	// - True: all pods are running and ready, the service is fully available.
+	// - Degraded: some pods aren't ready, the service is partially available.
+	// - False: no pods are running, the service is totally unavailable.
+	// - Unknown: the operator couldn't determine the condition status.
+	Available ConditionType = "Available"
+	// Reconciled indicates whether the operator has reconciled the state of
+	// the underlying resources with the object's spec.
+	// The possible status values for this condition type are:
+	// - True: the reconciliation was successful.
+	// - False: the reconciliation failed.
+	// - Unknown: the operator couldn't determine the condition status.
+	Reconciled ConditionType = "Reconciled"
+	// ConfigurationMerged indicates whether the settings managed by the
+	// operator were merged with the base Alertmanager configuration without
+	// conflicts. It is only reported when alertmanagerConfiguration is set.
+	// The possible status values for this condition type are:
+	// - True: no conflicting settings.
+	// - False: some settings conflict, the message lists them.
+	ConfigurationMerged ConditionType = "ConfigurationMerged"
+	// ConfigGenerated indicates whether the operator has generated the
+	// Prometheus configuration from the selected monitoring objects.
+	// The possible status values for this condition type are:
+	// - True: the configuration was generated or is managed by the user.
+	// - False: the configuration generation failed.
+	// - Unknown: the reconciliation failed before generating the configuration.
+	ConfigGenerated ConditionType = "ConfigGenerated"
+	// RulesValid indicates whether the expressions of a PrometheusRule are
+	// valid for the version of a Prometheus instance selecting it. Invalid
+	// PrometheusRule objects are excluded from the rule files of the
+	// instance.
+	// The possible status values for this condition type are:
+	// - True: all the expressions are valid.
+	// - False: some expressions are invalid, the message lists the errors.
+	RulesValid ConditionType = "RulesValid"
+	// RemoteRuleFilesSynced indicates whether the operator has fetched and
+	// validated the remote rule files of a Prometheus instance. It is only
+	// reported when remoteRuleFiles is set.
+	// The possible status values for this condition type are:
+	// - True: all the files were fetched and are valid.
+	// - False: some files couldn't be fetched or are invalid, the message
+	//   lists the errors. These files keep their last valid content.
+	// - Unknown: the reconciliation failed before fetching the files.
+	RemoteRuleFilesSynced ConditionType = "RemoteRuleFilesSynced"
+)
+
+// ConditionStatus is the status of a status condition.
+type ConditionStatus string
+
+const (
+	ConditionTrue     ConditionStatus = "True"
+	ConditionDegraded ConditionStatus = "Degraded"
+	ConditionFalse    ConditionStatus = "False"
+	ConditionUnknown  ConditionStatus = "Unknown"
+)
+
+// Condition represents the state of a resource managed by the operator at a
+// certain point.
+// +k8s:openapi-gen=true
+type Condition struct {
+	// Type of the condition being reported.
+	Type ConditionType `json:"type"`
+	// Status of the condition.
+	Status ConditionStatus `json:"status"`
+	// LastTransitionTime is the time of the last update to the current status property.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// Reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
+	// Human-readable message indicating details for the condition's last transition.
+	Message string `json:"message,omitempty"`
+	// ObservedGeneration represents the .metadata.generation that the
+	// condition was set based upon.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 // NamespaceSelector is a selector for selecting either all namespaces or a
@@ -1280,6 +2972,16 @@ func (l *PrometheusList) DeepCopyObject() runtime.Object {
 	return l.DeepCopy()
 }
 
+// DeepCopyObject implements the runtime.Object interface.
+func (l *PrometheusAgent) DeepCopyObject() runtime.Object {
+	return l.DeepCopy()
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *PrometheusAgentList) DeepCopyObject() runtime.Object {
+	return l.DeepCopy()
+}
+
 // DeepCopyObject implements the runtime.Object interface.
 func (l *ServiceMonitor) DeepCopyObject() runtime.Object {
 	return l.DeepCopy()
@@ -1300,6 +3002,16 @@ func (l *PodMonitorList) DeepCopyObject() runtime.Object {
 	return l.DeepCopy()
 }
 
+// DeepCopyObject implements the runtime.Object interface.
+func (l *NodeMonitor) DeepCopyObject() runtime.Object {
+	return l.DeepCopy()
+}
+
+// DeepCopyObject implements the runtime.Object interface.
+func (l *NodeMonitorList) DeepCopyObject() runtime.Object {
+	return l.DeepCopy()
+}
+
 // DeepCopyObject implements the runtime.Object interface.
 func (l *Probe) DeepCopyObject() runtime.Object {
 	return l.DeepCopy()
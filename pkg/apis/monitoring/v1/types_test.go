@@ -70,3 +70,53 @@ func TestValidateSecretOrConfigMap(t *testing.T) {
 		t.Errorf("expected validation of %+v to fail, but not no error", bad)
 	}
 }
+
+func TestValidateTLSConfigCertManagerRef(t *testing.T) {
+	for _, good := range []*TLSConfig{
+		&TLSConfig{CertManagerRef: &CertManagerReference{SecretName: "scrape-tls"}},
+		&TLSConfig{CertManagerRef: &CertManagerReference{SecretName: "scrape-tls", IgnoreCA: true}, ServerName: "example.com"},
+	} {
+		if err := good.Validate(); err != nil {
+			t.Errorf("expected validation of %+v not to fail, err: %s", good, err)
+		}
+	}
+
+	for _, bad := range []*TLSConfig{
+		&TLSConfig{CertManagerRef: &CertManagerReference{}},
+		&TLSConfig{CertManagerRef: &CertManagerReference{SecretName: "scrape-tls"}, CAFile: "/etc/ca.crt"},
+		&TLSConfig{CertManagerRef: &CertManagerReference{SecretName: "scrape-tls"}, KeySecret: &v1.SecretKeySelector{}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected validation of %+v to fail, but got no error", bad)
+		}
+	}
+}
+
+func TestValidateOAuth2(t *testing.T) {
+	clientID := SecretOrConfigMap{Secret: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "oauth2"}, Key: "id"}}
+	clientSecret := v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "oauth2"}, Key: "secret"}
+
+	for _, good := range []*OAuth2{
+		&OAuth2{ClientID: clientID, ClientSecret: clientSecret, TokenURL: "http://example.com"},
+		&OAuth2{ClientID: clientID, ClientSecret: clientSecret, TokenURL: "http://example.com", Scopes: []string{"read"}},
+	} {
+		if err := good.Validate(); err != nil {
+			t.Errorf("expected validation of %+v not to fail, err: %s", good, err)
+		}
+	}
+
+	for _, bad := range []*OAuth2{
+		&OAuth2{ClientID: clientID, ClientSecret: clientSecret},
+		&OAuth2{ClientSecret: clientSecret, TokenURL: "http://example.com"},
+		&OAuth2{ClientID: clientID, TokenURL: "http://example.com"},
+		&OAuth2{
+			ClientID:     SecretOrConfigMap{Secret: clientID.Secret, ConfigMap: &v1.ConfigMapKeySelector{}},
+			ClientSecret: clientSecret,
+			TokenURL:     "http://example.com",
+		},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected validation of %+v to fail, but got no error", bad)
+		}
+	}
+}
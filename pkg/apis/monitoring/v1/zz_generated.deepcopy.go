@@ -20,7 +20,9 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -80,7 +82,7 @@ func (in *Alertmanager) DeepCopyInto(out *Alertmanager) {
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(AlertmanagerStatus)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -94,6 +96,26 @@ func (in *Alertmanager) DeepCopy() *Alertmanager {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertmanagerConfiguration) DeepCopyInto(out *AlertmanagerConfiguration) {
+	*out = *in
+	if in.Global != nil {
+		in, out := &in.Global, &out.Global
+		*out = new(AlertmanagerGlobalConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertmanagerConfiguration.
+func (in *AlertmanagerConfiguration) DeepCopy() *AlertmanagerConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertmanagerConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AlertmanagerEndpoints) DeepCopyInto(out *AlertmanagerEndpoints) {
 	*out = *in
@@ -120,6 +142,36 @@ func (in *AlertmanagerEndpoints) DeepCopy() *AlertmanagerEndpoints {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertmanagerGlobalConfig) DeepCopyInto(out *AlertmanagerGlobalConfig) {
+	*out = *in
+	if in.SMTPAuthPassword != nil {
+		in, out := &in.SMTPAuthPassword, &out.SMTPAuthPassword
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SMTPRequireTLS != nil {
+		in, out := &in.SMTPRequireTLS, &out.SMTPRequireTLS
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SlackAPIURL != nil {
+		in, out := &in.SlackAPIURL, &out.SlackAPIURL
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertmanagerGlobalConfig.
+func (in *AlertmanagerGlobalConfig) DeepCopy() *AlertmanagerGlobalConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertmanagerGlobalConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AlertmanagerList) DeepCopyInto(out *AlertmanagerList) {
 	*out = *in
@@ -172,6 +224,18 @@ func (in *AlertmanagerSpec) DeepCopyInto(out *AlertmanagerSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AlertmanagerConfiguration != nil {
+		in, out := &in.AlertmanagerConfiguration, &out.AlertmanagerConfiguration
+		*out = new(AlertmanagerConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make([]SecretOrConfigMap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -221,6 +285,30 @@ func (in *AlertmanagerSpec) DeepCopyInto(out *AlertmanagerSpec) {
 		*out = new(corev1.PodSecurityContext)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConfigReloaderSecurityContext != nil {
+		in, out := &in.ConfigReloaderSecurityContext, &out.ConfigReloaderSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccountLabels != nil {
+		in, out := &in.ServiceAccountLabels, &out.ServiceAccountLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Web != nil {
+		in, out := &in.Web, &out.Web
+		*out = new(WebSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]corev1.Container, len(*in))
@@ -235,11 +323,35 @@ func (in *AlertmanagerSpec) DeepCopyInto(out *AlertmanagerSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.StatefulSetPatch != nil {
+		in, out := &in.StatefulSetPatch, &out.StatefulSetPatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AdditionalPeers != nil {
 		in, out := &in.AdditionalPeers, &out.AdditionalPeers
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ClusterPeers != nil {
+		in, out := &in.ClusterPeers, &out.ClusterPeers
+		*out = make([]ClusterPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ClusterTLS != nil {
+		in, out := &in.ClusterTLS, &out.ClusterTLS
+		*out = new(ClusterTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertmanagerSpec.
@@ -255,6 +367,13 @@ func (in *AlertmanagerSpec) DeepCopy() *AlertmanagerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AlertmanagerStatus) DeepCopyInto(out *AlertmanagerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertmanagerStatus.
@@ -283,255 +402,896 @@ func (in *ArbitraryFSAccessThroughSMsConfig) DeepCopy() *ArbitraryFSAccessThroug
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BasicAuth) DeepCopyInto(out *BasicAuth) {
+func (in *AttachMetadata) DeepCopyInto(out *AttachMetadata) {
 	*out = *in
-	in.Username.DeepCopyInto(&out.Username)
-	in.Password.DeepCopyInto(&out.Password)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BasicAuth.
-func (in *BasicAuth) DeepCopy() *BasicAuth {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttachMetadata.
+func (in *AttachMetadata) DeepCopy() *AttachMetadata {
 	if in == nil {
 		return nil
 	}
-	out := new(BasicAuth)
+	out := new(AttachMetadata)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EmbeddedObjectMetadata) DeepCopyInto(out *EmbeddedObjectMetadata) {
+func (in *Authorization) DeepCopyInto(out *Authorization) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Annotations != nil {
-		in, out := &in.Annotations, &out.Annotations
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmbeddedObjectMetadata.
-func (in *EmbeddedObjectMetadata) DeepCopy() *EmbeddedObjectMetadata {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Authorization.
+func (in *Authorization) DeepCopy() *Authorization {
 	if in == nil {
 		return nil
 	}
-	out := new(EmbeddedObjectMetadata)
+	out := new(Authorization)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EmbeddedPersistentVolumeClaim) DeepCopyInto(out *EmbeddedPersistentVolumeClaim) {
+func (in *AuthorizationValidationError) DeepCopyInto(out *AuthorizationValidationError) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.EmbeddedObjectMetadata.DeepCopyInto(&out.EmbeddedObjectMetadata)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmbeddedPersistentVolumeClaim.
-func (in *EmbeddedPersistentVolumeClaim) DeepCopy() *EmbeddedPersistentVolumeClaim {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthorizationValidationError.
+func (in *AuthorizationValidationError) DeepCopy() *AuthorizationValidationError {
 	if in == nil {
 		return nil
 	}
-	out := new(EmbeddedPersistentVolumeClaim)
+	out := new(AuthorizationValidationError)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Endpoint) DeepCopyInto(out *Endpoint) {
+func (in *AutoResources) DeepCopyInto(out *AutoResources) {
 	*out = *in
-	if in.TargetPort != nil {
-		in, out := &in.TargetPort, &out.TargetPort
-		*out = new(intstr.IntOrString)
-		**out = **in
-	}
-	if in.Params != nil {
-		in, out := &in.Params, &out.Params
-		*out = make(map[string][]string, len(*in))
-		for key, val := range *in {
-			var outVal []string
-			if val == nil {
-				(*out)[key] = nil
-			} else {
-				in, out := &val, &outVal
-				*out = make([]string, len(*in))
-				copy(*out, *in)
-			}
-			(*out)[key] = outVal
-		}
-	}
-	if in.TLSConfig != nil {
-		in, out := &in.TLSConfig, &out.TLSConfig
-		*out = new(TLSConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	in.BearerTokenSecret.DeepCopyInto(&out.BearerTokenSecret)
-	if in.HonorTimestamps != nil {
-		in, out := &in.HonorTimestamps, &out.HonorTimestamps
-		*out = new(bool)
-		**out = **in
-	}
-	if in.BasicAuth != nil {
-		in, out := &in.BasicAuth, &out.BasicAuth
-		*out = new(BasicAuth)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.MetricRelabelConfigs != nil {
-		in, out := &in.MetricRelabelConfigs, &out.MetricRelabelConfigs
-		*out = make([]*RelabelConfig, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(RelabelConfig)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
-	if in.RelabelConfigs != nil {
-		in, out := &in.RelabelConfigs, &out.RelabelConfigs
-		*out = make([]*RelabelConfig, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(RelabelConfig)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
-	if in.ProxyURL != nil {
-		in, out := &in.ProxyURL, &out.ProxyURL
-		*out = new(string)
+	out.MinMemory = in.MinMemory.DeepCopy()
+	out.MaxMemory = in.MaxMemory.DeepCopy()
+	if in.BytesPerSeries != nil {
+		in, out := &in.BytesPerSeries, &out.BytesPerSeries
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.HeadroomPercent != nil {
+		in, out := &in.HeadroomPercent, &out.HeadroomPercent
+		*out = new(int32)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Endpoint.
-func (in *Endpoint) DeepCopy() *Endpoint {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoResources.
+func (in *AutoResources) DeepCopy() *AutoResources {
 	if in == nil {
 		return nil
 	}
-	out := new(Endpoint)
+	out := new(AutoResources)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NamespaceSelector) DeepCopyInto(out *NamespaceSelector) {
+func (in *AzureAD) DeepCopyInto(out *AzureAD) {
 	*out = *in
-	if in.MatchNames != nil {
-		in, out := &in.MatchNames, &out.MatchNames
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	out.ManagedIdentity = in.ManagedIdentity
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSelector.
-func (in *NamespaceSelector) DeepCopy() *NamespaceSelector {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureAD.
+func (in *AzureAD) DeepCopy() *AzureAD {
 	if in == nil {
 		return nil
 	}
-	out := new(NamespaceSelector)
+	out := new(AzureAD)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PodMetricsEndpoint) DeepCopyInto(out *PodMetricsEndpoint) {
+func (in *BasicAuth) DeepCopyInto(out *BasicAuth) {
 	*out = *in
-	if in.TargetPort != nil {
-		in, out := &in.TargetPort, &out.TargetPort
-		*out = new(intstr.IntOrString)
-		**out = **in
-	}
-	if in.Params != nil {
-		in, out := &in.Params, &out.Params
-		*out = make(map[string][]string, len(*in))
-		for key, val := range *in {
-			var outVal []string
-			if val == nil {
-				(*out)[key] = nil
-			} else {
-				in, out := &val, &outVal
-				*out = make([]string, len(*in))
-				copy(*out, *in)
-			}
-			(*out)[key] = outVal
-		}
-	}
-	if in.HonorTimestamps != nil {
-		in, out := &in.HonorTimestamps, &out.HonorTimestamps
-		*out = new(bool)
-		**out = **in
-	}
-	if in.MetricRelabelConfigs != nil {
-		in, out := &in.MetricRelabelConfigs, &out.MetricRelabelConfigs
-		*out = make([]*RelabelConfig, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(RelabelConfig)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
-	if in.RelabelConfigs != nil {
-		in, out := &in.RelabelConfigs, &out.RelabelConfigs
-		*out = make([]*RelabelConfig, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(RelabelConfig)
-				(*in).DeepCopyInto(*out)
-			}
-		}
+	in.Username.DeepCopyInto(&out.Username)
+	in.Password.DeepCopyInto(&out.Password)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BasicAuth.
+func (in *BasicAuth) DeepCopy() *BasicAuth {
+	if in == nil {
+		return nil
 	}
-	if in.ProxyURL != nil {
-		in, out := &in.ProxyURL, &out.ProxyURL
-		*out = new(string)
+	out := new(BasicAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BoundServiceAccountToken) DeepCopyInto(out *BoundServiceAccountToken) {
+	*out = *in
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMetricsEndpoint.
-func (in *PodMetricsEndpoint) DeepCopy() *PodMetricsEndpoint {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BoundServiceAccountToken.
+func (in *BoundServiceAccountToken) DeepCopy() *BoundServiceAccountToken {
 	if in == nil {
 		return nil
 	}
-	out := new(PodMetricsEndpoint)
+	out := new(BoundServiceAccountToken)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PodMonitor) DeepCopyInto(out *PodMonitor) {
+func (in *CertManagerReference) DeepCopyInto(out *CertManagerReference) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMonitor.
-func (in *PodMonitor) DeepCopy() *PodMonitor {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerReference.
+func (in *CertManagerReference) DeepCopy() *CertManagerReference {
 	if in == nil {
 		return nil
 	}
-	out := new(PodMonitor)
+	out := new(CertManagerReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PodMonitorList) DeepCopyInto(out *PodMonitorList) {
+func (in *ClusterPeer) DeepCopyInto(out *ClusterPeer) {
+	*out = *in
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ClusterPeerService)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPeer.
+func (in *ClusterPeer) DeepCopy() *ClusterPeer {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPeer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPeerService) DeepCopyInto(out *ClusterPeerService) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPeerService.
+func (in *ClusterPeerService) DeepCopy() *ClusterPeerService {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPeerService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterTLSClientConfig) DeepCopyInto(out *ClusterTLSClientConfig) {
+	*out = *in
+	in.Cert.DeepCopyInto(&out.Cert)
+	in.KeySecret.DeepCopyInto(&out.KeySecret)
+	if in.CA != nil {
+		in, out := &in.CA, &out.CA
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterTLSClientConfig.
+func (in *ClusterTLSClientConfig) DeepCopy() *ClusterTLSClientConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterTLSClientConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterTLSConfig) DeepCopyInto(out *ClusterTLSConfig) {
+	*out = *in
+	in.Server.DeepCopyInto(&out.Server)
+	in.Client.DeepCopyInto(&out.Client)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterTLSConfig.
+func (in *ClusterTLSConfig) DeepCopy() *ClusterTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterTLSServerConfig) DeepCopyInto(out *ClusterTLSServerConfig) {
+	*out = *in
+	in.Cert.DeepCopyInto(&out.Cert)
+	in.KeySecret.DeepCopyInto(&out.KeySecret)
+	if in.ClientCA != nil {
+		in, out := &in.ClientCA, &out.ClientCA
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterTLSServerConfig.
+func (in *ClusterTLSServerConfig) DeepCopy() *ClusterTLSServerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterTLSServerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Condition) DeepCopyInto(out *Condition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
+func (in *Condition) DeepCopy() *Condition {
+	if in == nil {
+		return nil
+	}
+	out := new(Condition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasourceExportSpec) DeepCopyInto(out *DatasourceExportSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.HTTPHeaders != nil {
+		in, out := &in.HTTPHeaders, &out.HTTPHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasourceExportSpec.
+func (in *DatasourceExportSpec) DeepCopy() *DatasourceExportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasourceExportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmbeddedObjectMetadata) DeepCopyInto(out *EmbeddedObjectMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmbeddedObjectMetadata.
+func (in *EmbeddedObjectMetadata) DeepCopy() *EmbeddedObjectMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(EmbeddedObjectMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmbeddedPersistentVolumeClaim) DeepCopyInto(out *EmbeddedPersistentVolumeClaim) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.EmbeddedObjectMetadata.DeepCopyInto(&out.EmbeddedObjectMetadata)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmbeddedPersistentVolumeClaim.
+func (in *EmbeddedPersistentVolumeClaim) DeepCopy() *EmbeddedPersistentVolumeClaim {
+	if in == nil {
+		return nil
+	}
+	out := new(EmbeddedPersistentVolumeClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Endpoint) DeepCopyInto(out *Endpoint) {
+	*out = *in
+	if in.TargetPort != nil {
+		in, out := &in.TargetPort, &out.TargetPort
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	in.BearerTokenSecret.DeepCopyInto(&out.BearerTokenSecret)
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(OAuth2)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HonorTimestamps != nil {
+		in, out := &in.HonorTimestamps, &out.HonorTimestamps
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(BasicAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MetricRelabelConfigs != nil {
+		in, out := &in.MetricRelabelConfigs, &out.MetricRelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.RelabelConfigs != nil {
+		in, out := &in.RelabelConfigs, &out.RelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.ProxyURL != nil {
+		in, out := &in.ProxyURL, &out.ProxyURL
+		*out = new(string)
+		**out = **in
+	}
+	if in.ScrapeClassicHistograms != nil {
+		in, out := &in.ScrapeClassicHistograms, &out.ScrapeClassicHistograms
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NativeHistogramBucketLimit != nil {
+		in, out := &in.NativeHistogramBucketLimit, &out.NativeHistogramBucketLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.ScrapeProtocols != nil {
+		in, out := &in.ScrapeProtocols, &out.ScrapeProtocols
+		*out = make([]ScrapeProtocol, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Endpoint.
+func (in *Endpoint) DeepCopy() *Endpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(Endpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Exemplars) DeepCopyInto(out *Exemplars) {
+	*out = *in
+	if in.MaxSize != nil {
+		in, out := &in.MaxSize, &out.MaxSize
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Exemplars.
+func (in *Exemplars) DeepCopy() *Exemplars {
+	if in == nil {
+		return nil
+	}
+	out := new(Exemplars)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederationSpec) DeepCopyInto(out *FederationSpec) {
+	*out = *in
+	if in.Match != nil {
+		in, out := &in.Match, &out.Match
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(BasicAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MetricRelabelConfigs != nil {
+		in, out := &in.MetricRelabelConfigs, &out.MetricRelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederationSpec.
+func (in *FederationSpec) DeepCopy() *FederationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FederationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedIdentity) DeepCopyInto(out *ManagedIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedIdentity.
+func (in *ManagedIdentity) DeepCopy() *ManagedIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataConfig) DeepCopyInto(out *MetadataConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataConfig.
+func (in *MetadataConfig) DeepCopy() *MetadataConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceSelector) DeepCopyInto(out *NamespaceSelector) {
+	*out = *in
+	if in.MatchNames != nil {
+		in, out := &in.MatchNames, &out.MatchNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSelector.
+func (in *NamespaceSelector) DeepCopy() *NamespaceSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMetricsEndpoint) DeepCopyInto(out *NodeMetricsEndpoint) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	in.BearerTokenSecret.DeepCopyInto(&out.BearerTokenSecret)
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(BasicAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HonorTimestamps != nil {
+		in, out := &in.HonorTimestamps, &out.HonorTimestamps
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MetricRelabelConfigs != nil {
+		in, out := &in.MetricRelabelConfigs, &out.MetricRelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.RelabelConfigs != nil {
+		in, out := &in.RelabelConfigs, &out.RelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.ProxyURL != nil {
+		in, out := &in.ProxyURL, &out.ProxyURL
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMetricsEndpoint.
+func (in *NodeMetricsEndpoint) DeepCopy() *NodeMetricsEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeMetricsEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMonitor) DeepCopyInto(out *NodeMonitor) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMonitor.
+func (in *NodeMonitor) DeepCopy() *NodeMonitor {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeMonitor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMonitorList) DeepCopyInto(out *NodeMonitorList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]*NodeMonitor, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(NodeMonitor)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMonitorList.
+func (in *NodeMonitorList) DeepCopy() *NodeMonitorList {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeMonitorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeMonitorSpec) DeepCopyInto(out *NodeMonitorSpec) {
+	*out = *in
+	if in.NodeTargetLabels != nil {
+		in, out := &in.NodeTargetLabels, &out.NodeTargetLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]NodeMetricsEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeMonitorSpec.
+func (in *NodeMonitorSpec) DeepCopy() *NodeMonitorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeMonitorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2) DeepCopyInto(out *OAuth2) {
+	*out = *in
+	in.ClientID.DeepCopyInto(&out.ClientID)
+	in.ClientSecret.DeepCopyInto(&out.ClientSecret)
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EndpointParams != nil {
+		in, out := &in.EndpointParams, &out.EndpointParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2.
+func (in *OAuth2) DeepCopy() *OAuth2 {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OAuth2ValidationError) DeepCopyInto(out *OAuth2ValidationError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OAuth2ValidationError.
+func (in *OAuth2ValidationError) DeepCopy() *OAuth2ValidationError {
+	if in == nil {
+		return nil
+	}
+	out := new(OAuth2ValidationError)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OTLPConfig) DeepCopyInto(out *OTLPConfig) {
+	*out = *in
+	if in.PromoteResourceAttributes != nil {
+		in, out := &in.PromoteResourceAttributes, &out.PromoteResourceAttributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OTLPConfig.
+func (in *OTLPConfig) DeepCopy() *OTLPConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OTLPConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodMetricsEndpoint) DeepCopyInto(out *PodMetricsEndpoint) {
+	*out = *in
+	if in.TargetPort != nil {
+		in, out := &in.TargetPort, &out.TargetPort
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.HonorTimestamps != nil {
+		in, out := &in.HonorTimestamps, &out.HonorTimestamps
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MetricRelabelConfigs != nil {
+		in, out := &in.MetricRelabelConfigs, &out.MetricRelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.RelabelConfigs != nil {
+		in, out := &in.RelabelConfigs, &out.RelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.ProxyURL != nil {
+		in, out := &in.ProxyURL, &out.ProxyURL
+		*out = new(string)
+		**out = **in
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(OAuth2)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScrapeClassicHistograms != nil {
+		in, out := &in.ScrapeClassicHistograms, &out.ScrapeClassicHistograms
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NativeHistogramBucketLimit != nil {
+		in, out := &in.NativeHistogramBucketLimit, &out.NativeHistogramBucketLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.ScrapeProtocols != nil {
+		in, out := &in.ScrapeProtocols, &out.ScrapeProtocols
+		*out = make([]ScrapeProtocol, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMetricsEndpoint.
+func (in *PodMetricsEndpoint) DeepCopy() *PodMetricsEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(PodMetricsEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodMonitor) DeepCopyInto(out *PodMonitor) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMonitor.
+func (in *PodMonitor) DeepCopy() *PodMonitor {
+	if in == nil {
+		return nil
+	}
+	out := new(PodMonitor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodMonitorList) DeepCopyInto(out *PodMonitorList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
@@ -548,268 +1308,722 @@ func (in *PodMonitorList) DeepCopyInto(out *PodMonitorList) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMonitorList.
-func (in *PodMonitorList) DeepCopy() *PodMonitorList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMonitorList.
+func (in *PodMonitorList) DeepCopy() *PodMonitorList {
+	if in == nil {
+		return nil
+	}
+	out := new(PodMonitorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodMonitorSpec) DeepCopyInto(out *PodMonitorSpec) {
+	*out = *in
+	if in.PodTargetLabels != nil {
+		in, out := &in.PodTargetLabels, &out.PodTargetLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PodMetricsEndpoints != nil {
+		in, out := &in.PodMetricsEndpoints, &out.PodMetricsEndpoints
+		*out = make([]PodMetricsEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Selector.DeepCopyInto(&out.Selector)
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.ScrapeClassName != nil {
+		in, out := &in.ScrapeClassName, &out.ScrapeClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.AttachMetadata != nil {
+		in, out := &in.AttachMetadata, &out.AttachMetadata
+		*out = new(AttachMetadata)
+		**out = **in
+	}
+	if in.SelectorMechanism != nil {
+		in, out := &in.SelectorMechanism, &out.SelectorMechanism
+		*out = new(SelectorMechanism)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMonitorSpec.
+func (in *PodMonitorSpec) DeepCopy() *PodMonitorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PodMonitorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Probe) DeepCopyInto(out *Probe) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probe.
+func (in *Probe) DeepCopy() *Probe {
+	if in == nil {
+		return nil
+	}
+	out := new(Probe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeList) DeepCopyInto(out *ProbeList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]*Probe, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(Probe)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeList.
+func (in *ProbeList) DeepCopy() *ProbeList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeSpec) DeepCopyInto(out *ProbeSpec) {
+	*out = *in
+	out.ProberSpec = in.ProberSpec
+	in.Targets.DeepCopyInto(&out.Targets)
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScrapeClassName != nil {
+		in, out := &in.ScrapeClassName, &out.ScrapeClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeSpec.
+func (in *ProbeSpec) DeepCopy() *ProbeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeStaticTarget) DeepCopyInto(out *ProbeStaticTarget) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeStaticTarget.
+func (in *ProbeStaticTarget) DeepCopy() *ProbeStaticTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeStaticTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeTargetIngress) DeepCopyInto(out *ProbeTargetIngress) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.RelabelConfigs != nil {
+		in, out := &in.RelabelConfigs, &out.RelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTargetIngress.
+func (in *ProbeTargetIngress) DeepCopy() *ProbeTargetIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeTargetIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeTargetStaticConfig) DeepCopyInto(out *ProbeTargetStaticConfig) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StaticTargets != nil {
+		in, out := &in.StaticTargets, &out.StaticTargets
+		*out = make([]ProbeStaticTarget, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTargetStaticConfig.
+func (in *ProbeTargetStaticConfig) DeepCopy() *ProbeTargetStaticConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeTargetStaticConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeTargets) DeepCopyInto(out *ProbeTargets) {
+	*out = *in
+	if in.StaticConfig != nil {
+		in, out := &in.StaticConfig, &out.StaticConfig
+		*out = new(ProbeTargetStaticConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(ProbeTargetIngress)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTargets.
+func (in *ProbeTargets) DeepCopy() *ProbeTargets {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeTargets)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeTiming) DeepCopyInto(out *ProbeTiming) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTiming.
+func (in *ProbeTiming) DeepCopy() *ProbeTiming {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeTiming)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProberSpec) DeepCopyInto(out *ProberSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProberSpec.
+func (in *ProberSpec) DeepCopy() *ProberSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PodMonitorList)
+	out := new(ProberSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PodMonitorSpec) DeepCopyInto(out *PodMonitorSpec) {
+func (in *Prometheus) DeepCopyInto(out *Prometheus) {
 	*out = *in
-	if in.PodTargetLabels != nil {
-		in, out := &in.PodTargetLabels, &out.PodTargetLabels
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.PodMetricsEndpoints != nil {
-		in, out := &in.PodMetricsEndpoints, &out.PodMetricsEndpoints
-		*out = make([]PodMetricsEndpoint, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(PrometheusStatus)
+		(*in).DeepCopyInto(*out)
 	}
-	in.Selector.DeepCopyInto(&out.Selector)
-	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodMonitorSpec.
-func (in *PodMonitorSpec) DeepCopy() *PodMonitorSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Prometheus.
+func (in *Prometheus) DeepCopy() *Prometheus {
 	if in == nil {
 		return nil
 	}
-	out := new(PodMonitorSpec)
+	out := new(Prometheus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Probe) DeepCopyInto(out *Probe) {
+func (in *PrometheusAgent) DeepCopyInto(out *PrometheusAgent) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(PrometheusStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probe.
-func (in *Probe) DeepCopy() *Probe {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusAgent.
+func (in *PrometheusAgent) DeepCopy() *PrometheusAgent {
 	if in == nil {
 		return nil
 	}
-	out := new(Probe)
+	out := new(PrometheusAgent)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProbeList) DeepCopyInto(out *ProbeList) {
+func (in *PrometheusAgentList) DeepCopyInto(out *PrometheusAgentList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]*Probe, len(*in))
+		*out = make([]*PrometheusAgent, len(*in))
 		for i := range *in {
 			if (*in)[i] != nil {
 				in, out := &(*in)[i], &(*out)[i]
-				*out = new(Probe)
+				*out = new(PrometheusAgent)
 				(*in).DeepCopyInto(*out)
 			}
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeList.
-func (in *ProbeList) DeepCopy() *ProbeList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusAgentList.
+func (in *PrometheusAgentList) DeepCopy() *PrometheusAgentList {
 	if in == nil {
 		return nil
 	}
-	out := new(ProbeList)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProbeSpec) DeepCopyInto(out *ProbeSpec) {
-	*out = *in
-	out.ProberSpec = in.ProberSpec
-	in.Targets.DeepCopyInto(&out.Targets)
+	out := new(PrometheusAgentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusAgentSpec) DeepCopyInto(out *PrometheusAgentSpec) {
+	*out = *in
+	if in.PodMetadata != nil {
+		in, out := &in.PodMetadata, &out.PodMetadata
+		*out = new(EmbeddedObjectMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceMonitorSelector != nil {
+		in, out := &in.ServiceMonitorSelector, &out.ServiceMonitorSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceMonitorNamespaceSelector != nil {
+		in, out := &in.ServiceMonitorNamespaceSelector, &out.ServiceMonitorNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodMonitorSelector != nil {
+		in, out := &in.PodMonitorSelector, &out.PodMonitorSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodMonitorNamespaceSelector != nil {
+		in, out := &in.PodMonitorNamespaceSelector, &out.PodMonitorNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProbeSelector != nil {
+		in, out := &in.ProbeSelector, &out.ProbeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProbeNamespaceSelector != nil {
+		in, out := &in.ProbeNamespaceSelector, &out.ProbeNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeMonitorSelector != nil {
+		in, out := &in.NodeMonitorSelector, &out.NodeMonitorSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeMonitorNamespaceSelector != nil {
+		in, out := &in.NodeMonitorNamespaceSelector, &out.NodeMonitorNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ReplicaExternalLabelName != nil {
+		in, out := &in.ReplicaExternalLabelName, &out.ReplicaExternalLabelName
+		*out = new(string)
+		**out = **in
+	}
+	if in.PrometheusExternalLabelName != nil {
+		in, out := &in.PrometheusExternalLabelName, &out.PrometheusExternalLabelName
+		*out = new(string)
+		**out = **in
+	}
+	if in.ExternalLabels != nil {
+		in, out := &in.ExternalLabels, &out.ExternalLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(StorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccountLabels != nil {
+		in, out := &in.ServiceAccountLabels, &out.ServiceAccountLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BoundServiceAccountToken != nil {
+		in, out := &in.BoundServiceAccountToken, &out.BoundServiceAccountToken
+		*out = new(BoundServiceAccountToken)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secrets != nil {
+		in, out := &in.Secrets, &out.Secrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConfigMaps != nil {
+		in, out := &in.ConfigMaps, &out.ConfigMaps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RemoteWrite != nil {
+		in, out := &in.RemoteWrite, &out.RemoteWrite
+		*out = make([]RemoteWriteSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigReloaderSecurityContext != nil {
+		in, out := &in.ConfigReloaderSecurityContext, &out.ConfigReloaderSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StatefulSetPatch != nil {
+		in, out := &in.StatefulSetPatch, &out.StatefulSetPatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalScrapeConfigs != nil {
+		in, out := &in.AdditionalScrapeConfigs, &out.AdditionalScrapeConfigs
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScrapeClasses != nil {
+		in, out := &in.ScrapeClasses, &out.ScrapeClasses
+		*out = make([]ScrapeClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.APIServerConfig != nil {
+		in, out := &in.APIServerConfig, &out.APIServerConfig
+		*out = new(APIServerConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.ArbitraryFSAccessThroughSMs = in.ArbitraryFSAccessThroughSMs
+	if in.EnforcedSampleLimit != nil {
+		in, out := &in.EnforcedSampleLimit, &out.EnforcedSampleLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedTargetLimit != nil {
+		in, out := &in.EnforcedTargetLimit, &out.EnforcedTargetLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedLabelLimit != nil {
+		in, out := &in.EnforcedLabelLimit, &out.EnforcedLabelLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedLabelNameLengthLimit != nil {
+		in, out := &in.EnforcedLabelNameLengthLimit, &out.EnforcedLabelNameLengthLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedLabelValueLengthLimit != nil {
+		in, out := &in.EnforcedLabelValueLengthLimit, &out.EnforcedLabelValueLengthLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedKeepDroppedTargets != nil {
+		in, out := &in.EnforcedKeepDroppedTargets, &out.EnforcedKeepDroppedTargets
+		*out = new(uint64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeSpec.
-func (in *ProbeSpec) DeepCopy() *ProbeSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusAgentSpec.
+func (in *PrometheusAgentSpec) DeepCopy() *PrometheusAgentSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ProbeSpec)
+	out := new(PrometheusAgentSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProbeTargetIngress) DeepCopyInto(out *ProbeTargetIngress) {
+func (in *PrometheusList) DeepCopyInto(out *PrometheusList) {
 	*out = *in
-	in.Selector.DeepCopyInto(&out.Selector)
-	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
-	if in.RelabelConfigs != nil {
-		in, out := &in.RelabelConfigs, &out.RelabelConfigs
-		*out = make([]*RelabelConfig, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]*Prometheus, len(*in))
 		for i := range *in {
 			if (*in)[i] != nil {
 				in, out := &(*in)[i], &(*out)[i]
-				*out = new(RelabelConfig)
+				*out = new(Prometheus)
 				(*in).DeepCopyInto(*out)
 			}
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTargetIngress.
-func (in *ProbeTargetIngress) DeepCopy() *ProbeTargetIngress {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusList.
+func (in *PrometheusList) DeepCopy() *PrometheusList {
 	if in == nil {
 		return nil
 	}
-	out := new(ProbeTargetIngress)
+	out := new(PrometheusList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProbeTargetStaticConfig) DeepCopyInto(out *ProbeTargetStaticConfig) {
+func (in *PrometheusNetworkPolicy) DeepCopyInto(out *PrometheusNetworkPolicy) {
 	*out = *in
-	if in.Targets != nil {
-		in, out := &in.Targets, &out.Targets
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+	if in.IngressFrom != nil {
+		in, out := &in.IngressFrom, &out.IngressFrom
+		*out = make([]networkingv1.NetworkPolicyPeer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTargetStaticConfig.
-func (in *ProbeTargetStaticConfig) DeepCopy() *ProbeTargetStaticConfig {
-	if in == nil {
-		return nil
-	}
-	out := new(ProbeTargetStaticConfig)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProbeTargets) DeepCopyInto(out *ProbeTargets) {
-	*out = *in
-	if in.StaticConfig != nil {
-		in, out := &in.StaticConfig, &out.StaticConfig
-		*out = new(ProbeTargetStaticConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Ingress != nil {
-		in, out := &in.Ingress, &out.Ingress
-		*out = new(ProbeTargetIngress)
-		(*in).DeepCopyInto(*out)
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeTargets.
-func (in *ProbeTargets) DeepCopy() *ProbeTargets {
-	if in == nil {
-		return nil
+	if in.AdditionalEgress != nil {
+		in, out := &in.AdditionalEgress, &out.AdditionalEgress
+		*out = make([]networkingv1.NetworkPolicyEgressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	out := new(ProbeTargets)
-	in.DeepCopyInto(out)
-	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ProberSpec) DeepCopyInto(out *ProberSpec) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProberSpec.
-func (in *ProberSpec) DeepCopy() *ProberSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusNetworkPolicy.
+func (in *PrometheusNetworkPolicy) DeepCopy() *PrometheusNetworkPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(ProberSpec)
+	out := new(PrometheusNetworkPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Prometheus) DeepCopyInto(out *Prometheus) {
+func (in *PrometheusRule) DeepCopyInto(out *PrometheusRule) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
-		*out = new(PrometheusStatus)
-		**out = **in
+		*out = new(PrometheusRuleStatus)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Prometheus.
-func (in *Prometheus) DeepCopy() *Prometheus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRule.
+func (in *PrometheusRule) DeepCopy() *PrometheusRule {
 	if in == nil {
 		return nil
 	}
-	out := new(Prometheus)
+	out := new(PrometheusRule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusList) DeepCopyInto(out *PrometheusList) {
+func (in *PrometheusRuleBinding) DeepCopyInto(out *PrometheusRuleBinding) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]*Prometheus, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
 		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(Prometheus)
-				(*in).DeepCopyInto(*out)
-			}
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusList.
-func (in *PrometheusList) DeepCopy() *PrometheusList {
-	if in == nil {
-		return nil
-	}
-	out := new(PrometheusList)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusRule) DeepCopyInto(out *PrometheusRule) {
-	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRule.
-func (in *PrometheusRule) DeepCopy() *PrometheusRule {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRuleBinding.
+func (in *PrometheusRuleBinding) DeepCopy() *PrometheusRuleBinding {
 	if in == nil {
 		return nil
 	}
-	out := new(PrometheusRule)
+	out := new(PrometheusRuleBinding)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -879,6 +2093,28 @@ func (in *PrometheusRuleSpec) DeepCopy() *PrometheusRuleSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusRuleStatus) DeepCopyInto(out *PrometheusRuleStatus) {
+	*out = *in
+	if in.Prometheuses != nil {
+		in, out := &in.Prometheuses, &out.Prometheuses
+		*out = make([]PrometheusRuleBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRuleStatus.
+func (in *PrometheusRuleStatus) DeepCopy() *PrometheusRuleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusRuleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 	*out = *in
@@ -917,6 +2153,16 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 		*out = new(metav1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.NodeMonitorSelector != nil {
+		in, out := &in.NodeMonitorSelector, &out.NodeMonitorSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeMonitorNamespaceSelector != nil {
+		in, out := &in.NodeMonitorNamespaceSelector, &out.NodeMonitorNamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Image != nil {
 		in, out := &in.Image, &out.Image
 		*out = new(string)
@@ -947,6 +2193,11 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.ScrapeProtocols != nil {
+		in, out := &in.ScrapeProtocols, &out.ScrapeProtocols
+		*out = make([]ScrapeProtocol, len(*in))
+		copy(*out, *in)
+	}
 	out.Rules = in.Rules
 	if in.ExternalLabels != nil {
 		in, out := &in.ExternalLabels, &out.ExternalLabels
@@ -989,12 +2240,22 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 		*out = new(metav1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RemoteRuleFiles != nil {
+		in, out := &in.RemoteRuleFiles, &out.RemoteRuleFiles
+		*out = new(RemoteRuleFiles)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Alerting != nil {
 		in, out := &in.Alerting, &out.Alerting
 		*out = new(AlertingSpec)
 		(*in).DeepCopyInto(*out)
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.AutoResources != nil {
+		in, out := &in.AutoResources, &out.AutoResources
+		*out = new(AutoResources)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.NodeSelector != nil {
 		in, out := &in.NodeSelector, &out.NodeSelector
 		*out = make(map[string]string, len(*in))
@@ -1002,6 +2263,25 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccountLabels != nil {
+		in, out := &in.ServiceAccountLabels, &out.ServiceAccountLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BoundServiceAccountToken != nil {
+		in, out := &in.BoundServiceAccountToken, &out.BoundServiceAccountToken
+		*out = new(BoundServiceAccountToken)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Secrets != nil {
 		in, out := &in.Secrets, &out.Secrets
 		*out = make([]string, len(*in))
@@ -1024,6 +2304,13 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.RemoteWrite != nil {
 		in, out := &in.RemoteWrite, &out.RemoteWrite
 		*out = make([]RemoteWriteSpec, len(*in))
@@ -1038,11 +2325,61 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RemoteReadLimits != nil {
+		in, out := &in.RemoteReadLimits, &out.RemoteReadLimits
+		*out = new(RemoteReadLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OTLP != nil {
+		in, out := &in.OTLP, &out.OTLP
+		*out = new(OTLPConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Exemplars != nil {
+		in, out := &in.Exemplars, &out.Exemplars
+		*out = new(Exemplars)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnableFeatures != nil {
+		in, out := &in.EnableFeatures, &out.EnableFeatures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.SecurityContext != nil {
 		in, out := &in.SecurityContext, &out.SecurityContext
 		*out = new(corev1.PodSecurityContext)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConfigReloaderSecurityContext != nil {
+		in, out := &in.ConfigReloaderSecurityContext, &out.ConfigReloaderSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RulesConfigmapReloaderSecurityContext != nil {
+		in, out := &in.RulesConfigmapReloaderSecurityContext, &out.RulesConfigmapReloaderSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReloadStrategy != nil {
+		in, out := &in.ReloadStrategy, &out.ReloadStrategy
+		*out = new(ReloadStrategyType)
+		**out = **in
+	}
+	if in.Web != nil {
+		in, out := &in.Web, &out.Web
+		*out = new(WebSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExportDatasource != nil {
+		in, out := &in.ExportDatasource, &out.ExportDatasource
+		*out = new(DatasourceExportSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkPolicy != nil {
+		in, out := &in.NetworkPolicy, &out.NetworkPolicy
+		*out = new(PrometheusNetworkPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]corev1.Container, len(*in))
@@ -1057,11 +2394,30 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.StatefulSetPatch != nil {
+		in, out := &in.StatefulSetPatch, &out.StatefulSetPatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.AdditionalScrapeConfigs != nil {
 		in, out := &in.AdditionalScrapeConfigs, &out.AdditionalScrapeConfigs
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ScrapeClasses != nil {
+		in, out := &in.ScrapeClasses, &out.ScrapeClasses
+		*out = make([]ScrapeClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Federation != nil {
+		in, out := &in.Federation, &out.Federation
+		*out = make([]FederationSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AdditionalAlertRelabelConfigs != nil {
 		in, out := &in.AdditionalAlertRelabelConfigs, &out.AdditionalAlertRelabelConfigs
 		*out = new(corev1.SecretKeySelector)
@@ -1082,6 +2438,13 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 		*out = new(ThanosSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.ArbitraryFSAccessThroughSMs = in.ArbitraryFSAccessThroughSMs
 	if in.PrometheusRulesExcludedFromEnforce != nil {
 		in, out := &in.PrometheusRulesExcludedFromEnforce, &out.PrometheusRulesExcludedFromEnforce
@@ -1093,6 +2456,31 @@ func (in *PrometheusSpec) DeepCopyInto(out *PrometheusSpec) {
 		*out = new(uint64)
 		**out = **in
 	}
+	if in.EnforcedTargetLimit != nil {
+		in, out := &in.EnforcedTargetLimit, &out.EnforcedTargetLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedLabelLimit != nil {
+		in, out := &in.EnforcedLabelLimit, &out.EnforcedLabelLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedLabelNameLengthLimit != nil {
+		in, out := &in.EnforcedLabelNameLengthLimit, &out.EnforcedLabelNameLengthLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedLabelValueLengthLimit != nil {
+		in, out := &in.EnforcedLabelValueLengthLimit, &out.EnforcedLabelValueLengthLimit
+		*out = new(uint64)
+		**out = **in
+	}
+	if in.EnforcedKeepDroppedTargets != nil {
+		in, out := &in.EnforcedKeepDroppedTargets, &out.EnforcedKeepDroppedTargets
+		*out = new(uint64)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusSpec.
@@ -1108,6 +2496,13 @@ func (in *PrometheusSpec) DeepCopy() *PrometheusSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrometheusStatus) DeepCopyInto(out *PrometheusStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusStatus.
@@ -1180,12 +2575,42 @@ func (in *RelabelConfig) DeepCopyInto(out *RelabelConfig) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelabelConfig.
-func (in *RelabelConfig) DeepCopy() *RelabelConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RelabelConfig.
+func (in *RelabelConfig) DeepCopy() *RelabelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RelabelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteReadLimits) DeepCopyInto(out *RemoteReadLimits) {
+	*out = *in
+	if in.SampleLimit != nil {
+		in, out := &in.SampleLimit, &out.SampleLimit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ConcurrentLimit != nil {
+		in, out := &in.ConcurrentLimit, &out.ConcurrentLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxBytesInFrame != nil {
+		in, out := &in.MaxBytesInFrame, &out.MaxBytesInFrame
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteReadLimits.
+func (in *RemoteReadLimits) DeepCopy() *RemoteReadLimits {
 	if in == nil {
 		return nil
 	}
-	out := new(RelabelConfig)
+	out := new(RemoteReadLimits)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1205,11 +2630,40 @@ func (in *RemoteReadSpec) DeepCopyInto(out *RemoteReadSpec) {
 		*out = new(BasicAuth)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.TLSConfig != nil {
 		in, out := &in.TLSConfig, &out.TLSConfig
 		*out = new(TLSConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.OAuth2 != nil {
+		in, out := &in.OAuth2, &out.OAuth2
+		*out = new(OAuth2)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.HeadersFromSecrets != nil {
+		in, out := &in.HeadersFromSecrets, &out.HeadersFromSecrets
+		*out = make(map[string]corev1.SecretKeySelector, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.FilterExternalLabels != nil {
+		in, out := &in.FilterExternalLabels, &out.FilterExternalLabels
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteReadSpec.
@@ -1222,6 +2676,27 @@ func (in *RemoteReadSpec) DeepCopy() *RemoteReadSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteRuleFiles) DeepCopyInto(out *RemoteRuleFiles) {
+	*out = *in
+	if in.URLs != nil {
+		in, out := &in.URLs, &out.URLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteRuleFiles.
+func (in *RemoteRuleFiles) DeepCopy() *RemoteRuleFiles {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteRuleFiles)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RemoteWriteSpec) DeepCopyInto(out *RemoteWriteSpec) {
 	*out = *in
@@ -1237,16 +2712,43 @@ func (in *RemoteWriteSpec) DeepCopyInto(out *RemoteWriteSpec) {
 		*out = new(BasicAuth)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.TLSConfig != nil {
 		in, out := &in.TLSConfig, &out.TLSConfig
 		*out = new(TLSConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ProxyConnectHeader != nil {
+		in, out := &in.ProxyConnectHeader, &out.ProxyConnectHeader
+		*out = make(map[string]corev1.SecretKeySelector, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.QueueConfig != nil {
 		in, out := &in.QueueConfig, &out.QueueConfig
 		*out = new(QueueConfig)
 		**out = **in
 	}
+	if in.MetadataConfig != nil {
+		in, out := &in.MetadataConfig, &out.MetadataConfig
+		*out = new(MetadataConfig)
+		**out = **in
+	}
+	if in.Sigv4 != nil {
+		in, out := &in.Sigv4, &out.Sigv4
+		*out = new(Sigv4)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AzureAD != nil {
+		in, out := &in.AzureAD, &out.AzureAD
+		*out = new(AzureAD)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteWriteSpec.
@@ -1342,6 +2844,42 @@ func (in *RulesAlert) DeepCopy() *RulesAlert {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScrapeClass) DeepCopyInto(out *ScrapeClass) {
+	*out = *in
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(Authorization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Relabelings != nil {
+		in, out := &in.Relabelings, &out.Relabelings
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScrapeClass.
+func (in *ScrapeClass) DeepCopy() *ScrapeClass {
+	if in == nil {
+		return nil
+	}
+	out := new(ScrapeClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretOrConfigMap) DeepCopyInto(out *SecretOrConfigMap) {
 	*out = *in
@@ -1450,6 +2988,26 @@ func (in *ServiceMonitorSpec) DeepCopyInto(out *ServiceMonitorSpec) {
 	}
 	in.Selector.DeepCopyInto(&out.Selector)
 	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.ServiceDiscoveryRole != nil {
+		in, out := &in.ServiceDiscoveryRole, &out.ServiceDiscoveryRole
+		*out = new(ServiceDiscoveryRole)
+		**out = **in
+	}
+	if in.ScrapeClassName != nil {
+		in, out := &in.ScrapeClassName, &out.ScrapeClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.AttachMetadata != nil {
+		in, out := &in.AttachMetadata, &out.AttachMetadata
+		*out = new(AttachMetadata)
+		**out = **in
+	}
+	if in.SelectorMechanism != nil {
+		in, out := &in.SelectorMechanism, &out.SelectorMechanism
+		*out = new(SelectorMechanism)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceMonitorSpec.
@@ -1462,6 +3020,31 @@ func (in *ServiceMonitorSpec) DeepCopy() *ServiceMonitorSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Sigv4) DeepCopyInto(out *Sigv4) {
+	*out = *in
+	if in.AccessKey != nil {
+		in, out := &in.AccessKey, &out.AccessKey
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKey != nil {
+		in, out := &in.SecretKey, &out.SecretKey
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Sigv4.
+func (in *Sigv4) DeepCopy() *Sigv4 {
+	if in == nil {
+		return nil
+	}
+	out := new(Sigv4)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageSpec) DeepCopyInto(out *StorageSpec) {
 	*out = *in
@@ -1493,6 +3076,11 @@ func (in *TLSConfig) DeepCopyInto(out *TLSConfig) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.CertManagerRef != nil {
+		in, out := &in.CertManagerRef, &out.CertManagerRef
+		*out = new(CertManagerReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSConfig.
@@ -1520,6 +3108,31 @@ func (in *TLSConfigValidationError) DeepCopy() *TLSConfigValidationError {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThanosQueryEndpointConfig) DeepCopyInto(out *ThanosQueryEndpointConfig) {
+	*out = *in
+	if in.StaticConfigs != nil {
+		in, out := &in.StaticConfigs, &out.StaticConfigs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThanosQueryEndpointConfig.
+func (in *ThanosQueryEndpointConfig) DeepCopy() *ThanosQueryEndpointConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ThanosQueryEndpointConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ThanosRuler) DeepCopyInto(out *ThanosRuler) {
 	*out = *in
@@ -1529,7 +3142,7 @@ func (in *ThanosRuler) DeepCopyInto(out *ThanosRuler) {
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(ThanosRulerStatus)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -1614,6 +3227,32 @@ func (in *ThanosRulerSpec) DeepCopyInto(out *ThanosRulerSpec) {
 		*out = new(corev1.PodSecurityContext)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RulesConfigmapReloaderSecurityContext != nil {
+		in, out := &in.RulesConfigmapReloaderSecurityContext, &out.RulesConfigmapReloaderSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]corev1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServiceAccountLabels != nil {
+		in, out := &in.ServiceAccountLabels, &out.ServiceAccountLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Storage != nil {
 		in, out := &in.Storage, &out.Storage
 		*out = new(StorageSpec)
@@ -1631,6 +3270,16 @@ func (in *ThanosRulerSpec) DeepCopyInto(out *ThanosRulerSpec) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Web != nil {
+		in, out := &in.Web, &out.Web
+		*out = new(WebSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExportDatasource != nil {
+		in, out := &in.ExportDatasource, &out.ExportDatasource
+		*out = new(DatasourceExportSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.QueryEndpoints != nil {
 		in, out := &in.QueryEndpoints, &out.QueryEndpoints
 		*out = make([]string, len(*in))
@@ -1641,6 +3290,13 @@ func (in *ThanosRulerSpec) DeepCopyInto(out *ThanosRulerSpec) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.QueryEndpointConfigs != nil {
+		in, out := &in.QueryEndpointConfigs, &out.QueryEndpointConfigs
+		*out = make([]ThanosQueryEndpointConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AlertManagersURL != nil {
 		in, out := &in.AlertManagersURL, &out.AlertManagersURL
 		*out = make([]string, len(*in))
@@ -1651,6 +3307,17 @@ func (in *ThanosRulerSpec) DeepCopyInto(out *ThanosRulerSpec) {
 		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AlertRelabelConfigs != nil {
+		in, out := &in.AlertRelabelConfigs, &out.AlertRelabelConfigs
+		*out = make([]*RelabelConfig, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RelabelConfig)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
 	if in.RuleSelector != nil {
 		in, out := &in.RuleSelector, &out.RuleSelector
 		*out = new(metav1.LabelSelector)
@@ -1666,6 +3333,16 @@ func (in *ThanosRulerSpec) DeepCopyInto(out *ThanosRulerSpec) {
 		*out = make([]PrometheusRuleExcludeConfig, len(*in))
 		copy(*out, *in)
 	}
+	if in.RuleConcurrentEval != nil {
+		in, out := &in.RuleConcurrentEval, &out.RuleConcurrentEval
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RestoreIgnoredLabels != nil {
+		in, out := &in.RestoreIgnoredLabels, &out.RestoreIgnoredLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]corev1.Container, len(*in))
@@ -1680,6 +3357,11 @@ func (in *ThanosRulerSpec) DeepCopyInto(out *ThanosRulerSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.StatefulSetPatch != nil {
+		in, out := &in.StatefulSetPatch, &out.StatefulSetPatch
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.TracingConfig != nil {
 		in, out := &in.TracingConfig, &out.TracingConfig
 		*out = new(corev1.SecretKeySelector)
@@ -1717,6 +3399,13 @@ func (in *ThanosRulerSpec) DeepCopy() *ThanosRulerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ThanosRulerStatus) DeepCopyInto(out *ThanosRulerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThanosRulerStatus.
@@ -1773,6 +3462,21 @@ func (in *ThanosSpec) DeepCopyInto(out *ThanosSpec) {
 		*out = new(TLSConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(ProbeTiming)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(ProbeTiming)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThanosSpec.
@@ -1784,3 +3488,129 @@ func (in *ThanosSpec) DeepCopy() *ThanosSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologySpreadConstraint) DeepCopyInto(out *TopologySpreadConstraint) {
+	*out = *in
+	in.TopologySpreadConstraint.DeepCopyInto(&out.TopologySpreadConstraint)
+	if in.AdditionalLabelSelectors != nil {
+		in, out := &in.AdditionalLabelSelectors, &out.AdditionalLabelSelectors
+		*out = new(AdditionalLabelSelectors)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologySpreadConstraint.
+func (in *TopologySpreadConstraint) DeepCopy() *TopologySpreadConstraint {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologySpreadConstraint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebBasicAuthUser) DeepCopyInto(out *WebBasicAuthUser) {
+	*out = *in
+	in.PasswordHash.DeepCopyInto(&out.PasswordHash)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebBasicAuthUser.
+func (in *WebBasicAuthUser) DeepCopy() *WebBasicAuthUser {
+	if in == nil {
+		return nil
+	}
+	out := new(WebBasicAuthUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebHTTPConfig) DeepCopyInto(out *WebHTTPConfig) {
+	*out = *in
+	if in.HTTP2 != nil {
+		in, out := &in.HTTP2, &out.HTTP2
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebHTTPConfig.
+func (in *WebHTTPConfig) DeepCopy() *WebHTTPConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebHTTPConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebSpec) DeepCopyInto(out *WebSpec) {
+	*out = *in
+	if in.TLSConfig != nil {
+		in, out := &in.TLSConfig, &out.TLSConfig
+		*out = new(WebTLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTPConfig != nil {
+		in, out := &in.HTTPConfig, &out.HTTPConfig
+		*out = new(WebHTTPConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BasicAuthUsers != nil {
+		in, out := &in.BasicAuthUsers, &out.BasicAuthUsers
+		*out = make([]WebBasicAuthUser, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebSpec.
+func (in *WebSpec) DeepCopy() *WebSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WebSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebTLSConfig) DeepCopyInto(out *WebTLSConfig) {
+	*out = *in
+	in.Cert.DeepCopyInto(&out.Cert)
+	in.KeySecret.DeepCopyInto(&out.KeySecret)
+	if in.ClientCA != nil {
+		in, out := &in.ClientCA, &out.ClientCA
+		*out = new(SecretOrConfigMap)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CipherSuites != nil {
+		in, out := &in.CipherSuites, &out.CipherSuites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PreferServerCipherSuites != nil {
+		in, out := &in.PreferServerCipherSuites, &out.PreferServerCipherSuites
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CurvePreferences != nil {
+		in, out := &in.CurvePreferences, &out.CurvePreferences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebTLSConfig.
+func (in *WebTLSConfig) DeepCopy() *WebTLSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WebTLSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
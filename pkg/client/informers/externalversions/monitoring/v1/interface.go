@@ -24,12 +24,16 @@ import (
 type Interface interface {
 	// Alertmanagers returns a AlertmanagerInformer.
 	Alertmanagers() AlertmanagerInformer
+	// NodeMonitors returns a NodeMonitorInformer.
+	NodeMonitors() NodeMonitorInformer
 	// PodMonitors returns a PodMonitorInformer.
 	PodMonitors() PodMonitorInformer
 	// Probes returns a ProbeInformer.
 	Probes() ProbeInformer
 	// Prometheuses returns a PrometheusInformer.
 	Prometheuses() PrometheusInformer
+	// PrometheusAgents returns a PrometheusAgentInformer.
+	PrometheusAgents() PrometheusAgentInformer
 	// PrometheusRules returns a PrometheusRuleInformer.
 	PrometheusRules() PrometheusRuleInformer
 	// ServiceMonitors returns a ServiceMonitorInformer.
@@ -54,6 +58,11 @@ func (v *version) Alertmanagers() AlertmanagerInformer {
 	return &alertmanagerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// NodeMonitors returns a NodeMonitorInformer.
+func (v *version) NodeMonitors() NodeMonitorInformer {
+	return &nodeMonitorInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // PodMonitors returns a PodMonitorInformer.
 func (v *version) PodMonitors() PodMonitorInformer {
 	return &podMonitorInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -69,6 +78,11 @@ func (v *version) Prometheuses() PrometheusInformer {
 	return &prometheusInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// PrometheusAgents returns a PrometheusAgentInformer.
+func (v *version) PrometheusAgents() PrometheusAgentInformer {
+	return &prometheusAgentInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // PrometheusRules returns a PrometheusRuleInformer.
 func (v *version) PrometheusRules() PrometheusRuleInformer {
 	return &prometheusRuleInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
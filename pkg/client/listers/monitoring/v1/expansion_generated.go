@@ -24,6 +24,14 @@ type AlertmanagerListerExpansion interface{}
 // AlertmanagerNamespaceLister.
 type AlertmanagerNamespaceListerExpansion interface{}
 
+// NodeMonitorListerExpansion allows custom methods to be added to
+// NodeMonitorLister.
+type NodeMonitorListerExpansion interface{}
+
+// NodeMonitorNamespaceListerExpansion allows custom methods to be added to
+// NodeMonitorNamespaceLister.
+type NodeMonitorNamespaceListerExpansion interface{}
+
 // PodMonitorListerExpansion allows custom methods to be added to
 // PodMonitorLister.
 type PodMonitorListerExpansion interface{}
@@ -48,6 +56,14 @@ type PrometheusListerExpansion interface{}
 // PrometheusNamespaceLister.
 type PrometheusNamespaceListerExpansion interface{}
 
+// PrometheusAgentListerExpansion allows custom methods to be added to
+// PrometheusAgentLister.
+type PrometheusAgentListerExpansion interface{}
+
+// PrometheusAgentNamespaceListerExpansion allows custom methods to be added to
+// PrometheusAgentNamespaceLister.
+type PrometheusAgentNamespaceListerExpansion interface{}
+
 // PrometheusRuleListerExpansion allows custom methods to be added to
 // PrometheusRuleLister.
 type PrometheusRuleListerExpansion interface{}
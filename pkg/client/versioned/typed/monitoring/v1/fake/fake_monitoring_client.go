@@ -30,6 +30,10 @@ func (c *FakeMonitoringV1) Alertmanagers(namespace string) v1.AlertmanagerInterf
 	return &FakeAlertmanagers{c, namespace}
 }
 
+func (c *FakeMonitoringV1) NodeMonitors(namespace string) v1.NodeMonitorInterface {
+	return &FakeNodeMonitors{c, namespace}
+}
+
 func (c *FakeMonitoringV1) PodMonitors(namespace string) v1.PodMonitorInterface {
 	return &FakePodMonitors{c, namespace}
 }
@@ -42,6 +46,10 @@ func (c *FakeMonitoringV1) Prometheuses(namespace string) v1.PrometheusInterface
 	return &FakePrometheuses{c, namespace}
 }
 
+func (c *FakeMonitoringV1) PrometheusAgents(namespace string) v1.PrometheusAgentInterface {
+	return &FakePrometheusAgents{c, namespace}
+}
+
 func (c *FakeMonitoringV1) PrometheusRules(namespace string) v1.PrometheusRuleInterface {
 	return &FakePrometheusRules{c, namespace}
 }
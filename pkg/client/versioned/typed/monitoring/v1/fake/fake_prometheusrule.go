@@ -100,6 +100,18 @@ func (c *FakePrometheusRules) Update(ctx context.Context, prometheusRule *monito
 	return obj.(*monitoringv1.PrometheusRule), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakePrometheusRules) UpdateStatus(ctx context.Context, prometheusRule *monitoringv1.PrometheusRule, opts v1.UpdateOptions) (*monitoringv1.PrometheusRule, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(prometheusrulesResource, "status", c.ns, prometheusRule), &monitoringv1.PrometheusRule{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*monitoringv1.PrometheusRule), err
+}
+
 // Delete takes name of the prometheusRule and deletes it. Returns an error if one occurs.
 func (c *FakePrometheusRules) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
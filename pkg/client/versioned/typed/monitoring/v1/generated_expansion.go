@@ -18,12 +18,16 @@ package v1
 
 type AlertmanagerExpansion interface{}
 
+type NodeMonitorExpansion interface{}
+
 type PodMonitorExpansion interface{}
 
 type ProbeExpansion interface{}
 
 type PrometheusExpansion interface{}
 
+type PrometheusAgentExpansion interface{}
+
 type PrometheusRuleExpansion interface{}
 
 type ServiceMonitorExpansion interface{}
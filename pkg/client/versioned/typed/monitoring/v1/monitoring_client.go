@@ -25,9 +25,11 @@ import (
 type MonitoringV1Interface interface {
 	RESTClient() rest.Interface
 	AlertmanagersGetter
+	NodeMonitorsGetter
 	PodMonitorsGetter
 	ProbesGetter
 	PrometheusesGetter
+	PrometheusAgentsGetter
 	PrometheusRulesGetter
 	ServiceMonitorsGetter
 	ThanosRulersGetter
@@ -42,6 +44,10 @@ func (c *MonitoringV1Client) Alertmanagers(namespace string) AlertmanagerInterfa
 	return newAlertmanagers(c, namespace)
 }
 
+func (c *MonitoringV1Client) NodeMonitors(namespace string) NodeMonitorInterface {
+	return newNodeMonitors(c, namespace)
+}
+
 func (c *MonitoringV1Client) PodMonitors(namespace string) PodMonitorInterface {
 	return newPodMonitors(c, namespace)
 }
@@ -54,6 +60,10 @@ func (c *MonitoringV1Client) Prometheuses(namespace string) PrometheusInterface
 	return newPrometheuses(c, namespace)
 }
 
+func (c *MonitoringV1Client) PrometheusAgents(namespace string) PrometheusAgentInterface {
+	return newPrometheusAgents(c, namespace)
+}
+
 func (c *MonitoringV1Client) PrometheusRules(namespace string) PrometheusRuleInterface {
 	return newPrometheusRules(c, namespace)
 }
@@ -38,6 +38,7 @@ type PrometheusRulesGetter interface {
 type PrometheusRuleInterface interface {
 	Create(ctx context.Context, prometheusRule *v1.PrometheusRule, opts metav1.CreateOptions) (*v1.PrometheusRule, error)
 	Update(ctx context.Context, prometheusRule *v1.PrometheusRule, opts metav1.UpdateOptions) (*v1.PrometheusRule, error)
+	UpdateStatus(ctx context.Context, prometheusRule *v1.PrometheusRule, opts metav1.UpdateOptions) (*v1.PrometheusRule, error)
 	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
 	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.PrometheusRule, error)
@@ -133,6 +134,22 @@ func (c *prometheusRules) Update(ctx context.Context, prometheusRule *v1.Prometh
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *prometheusRules) UpdateStatus(ctx context.Context, prometheusRule *v1.PrometheusRule, opts metav1.UpdateOptions) (result *v1.PrometheusRule, err error) {
+	result = &v1.PrometheusRule{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("prometheusrules").
+		Name(prometheusRule.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(prometheusRule).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the prometheusRule and deletes it. Returns an error if one occurs.
 func (c *prometheusRules) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
 	return c.client.Delete().
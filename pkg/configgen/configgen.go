@@ -0,0 +1,255 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configgen renders the configuration of a Prometheus resource from
+// monitoring.coreos.com objects without a Kubernetes cluster. It uses the
+// same generation logic as the operator so that other controllers and CI
+// tools can verify the configuration produced for a set of objects.
+package configgen
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/prometheus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Input holds the objects from which the configuration is generated.
+type Input struct {
+	// Prometheus is the resource to generate the configuration for.
+	Prometheus *monitoringv1.Prometheus
+
+	// ServiceMonitors, PodMonitors, Probes and NodeMonitors are the
+	// candidate monitoring objects. Only the objects matching the selectors
+	// of the Prometheus resource are included in the configuration.
+	ServiceMonitors []*monitoringv1.ServiceMonitor
+	PodMonitors     []*monitoringv1.PodMonitor
+	Probes          []*monitoringv1.Probe
+	NodeMonitors    []*monitoringv1.NodeMonitor
+
+	// Namespaces are used to evaluate the namespace selectors of the
+	// Prometheus resource.
+	Namespaces []*v1.Namespace
+
+	// Secrets and ConfigMaps hold the credentials, TLS assets and
+	// additional configurations referenced by the objects.
+	Secrets    []*v1.Secret
+	ConfigMaps []*v1.ConfigMap
+
+	// RuleConfigMapNames are the names of the ConfigMaps holding the
+	// Prometheus rules.
+	RuleConfigMapNames []string
+
+	// DefaultExternalLabels are the external labels added to the
+	// configuration unless the Prometheus resource defines them, like the
+	// operator's --default-external-labels flag.
+	DefaultExternalLabels map[string]string
+}
+
+// Generate returns the Prometheus configuration for the given input.
+func Generate(ctx context.Context, logger log.Logger, in Input) ([]byte, error) {
+	p := in.Prometheus
+	if p == nil {
+		return nil, errors.New("a Prometheus resource is required")
+	}
+
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	selected, err := Select(in)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]runtime.Object, 0, len(in.Secrets)+len(in.ConfigMaps))
+	for _, s := range in.Secrets {
+		objs = append(objs, s)
+	}
+	for _, cm := range in.ConfigMaps {
+		objs = append(objs, cm)
+	}
+
+	res := prometheus.ConfigResources{
+		ServiceMonitors:       map[string]*monitoringv1.ServiceMonitor{},
+		PodMonitors:           map[string]*monitoringv1.PodMonitor{},
+		Probes:                map[string]*monitoringv1.Probe{},
+		NodeMonitors:          map[string]*monitoringv1.NodeMonitor{},
+		RuleConfigMapNames:    in.RuleConfigMapNames,
+		DefaultExternalLabels: in.DefaultExternalLabels,
+	}
+
+	for _, sm := range selected.ServiceMonitors {
+		res.ServiceMonitors[key(sm.ObjectMeta)] = sm
+	}
+	for _, pm := range selected.PodMonitors {
+		res.PodMonitors[key(pm.ObjectMeta)] = pm
+	}
+	for _, probe := range selected.Probes {
+		res.Probes[key(probe.ObjectMeta)] = probe
+	}
+	for _, nm := range selected.NodeMonitors {
+		res.NodeMonitors[key(nm.ObjectMeta)] = nm
+	}
+
+	if res.AdditionalScrapeConfigs, err = secretKey(p.Namespace, in.Secrets, p.Spec.AdditionalScrapeConfigs); err != nil {
+		return nil, errors.Wrap(err, "loading additional scrape configs failed")
+	}
+	if res.AdditionalAlertRelabelConfigs, err = secretKey(p.Namespace, in.Secrets, p.Spec.AdditionalAlertRelabelConfigs); err != nil {
+		return nil, errors.Wrap(err, "loading additional alert relabel configs failed")
+	}
+	if res.AdditionalAlertManagerConfigs, err = secretKey(p.Namespace, in.Secrets, p.Spec.AdditionalAlertManagerConfigs); err != nil {
+		return nil, errors.Wrap(err, "loading additional alert manager configs failed")
+	}
+
+	return prometheus.GenerateConfig(ctx, logger, fake.NewSimpleClientset(objs...), p, res)
+}
+
+// Select returns a copy of the input holding only the monitoring objects
+// selected by the Prometheus resource.
+func Select(in Input) (Input, error) {
+	p := in.Prometheus
+	if p == nil {
+		return Input{}, errors.New("a Prometheus resource is required")
+	}
+
+	out := in
+	out.ServiceMonitors = nil
+	out.PodMonitors = nil
+	out.Probes = nil
+	out.NodeMonitors = nil
+
+	sel, err := newSelector(p.Namespace, in.Namespaces, p.Spec.ServiceMonitorSelector, p.Spec.ServiceMonitorNamespaceSelector)
+	if err != nil {
+		return Input{}, errors.Wrap(err, "invalid ServiceMonitor selectors")
+	}
+	for _, sm := range in.ServiceMonitors {
+		if sel.matches(sm.ObjectMeta) {
+			out.ServiceMonitors = append(out.ServiceMonitors, sm)
+		}
+	}
+
+	sel, err = newSelector(p.Namespace, in.Namespaces, p.Spec.PodMonitorSelector, p.Spec.PodMonitorNamespaceSelector)
+	if err != nil {
+		return Input{}, errors.Wrap(err, "invalid PodMonitor selectors")
+	}
+	for _, pm := range in.PodMonitors {
+		if sel.matches(pm.ObjectMeta) {
+			out.PodMonitors = append(out.PodMonitors, pm)
+		}
+	}
+
+	sel, err = newSelector(p.Namespace, in.Namespaces, p.Spec.ProbeSelector, p.Spec.ProbeNamespaceSelector)
+	if err != nil {
+		return Input{}, errors.Wrap(err, "invalid Probe selectors")
+	}
+	for _, probe := range in.Probes {
+		if sel.matches(probe.ObjectMeta) {
+			out.Probes = append(out.Probes, probe)
+		}
+	}
+
+	sel, err = newSelector(p.Namespace, in.Namespaces, p.Spec.NodeMonitorSelector, p.Spec.NodeMonitorNamespaceSelector)
+	if err != nil {
+		return Input{}, errors.Wrap(err, "invalid NodeMonitor selectors")
+	}
+	for _, nm := range in.NodeMonitors {
+		if sel.matches(nm.ObjectMeta) {
+			out.NodeMonitors = append(out.NodeMonitors, nm)
+		}
+	}
+
+	return out, nil
+}
+
+func key(m metav1.ObjectMeta) string {
+	return m.Namespace + "/" + m.Name
+}
+
+// selector matches objects the same way as the operator: a nil label
+// selector matches nothing and a nil namespace selector matches only the
+// namespace of the Prometheus resource.
+type selector struct {
+	labels     labels.Selector
+	namespaces map[string]struct{}
+}
+
+func newSelector(ns string, namespaces []*v1.Namespace, ls, nsls *metav1.LabelSelector) (*selector, error) {
+	lsel, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &selector{
+		labels:     lsel,
+		namespaces: map[string]struct{}{},
+	}
+
+	if nsls == nil {
+		sel.namespaces[ns] = struct{}{}
+		return sel, nil
+	}
+
+	nssel, err := metav1.LabelSelectorAsSelector(nsls)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range namespaces {
+		if nssel.Matches(labels.Set(n.Labels)) {
+			sel.namespaces[n.Name] = struct{}{}
+		}
+	}
+
+	return sel, nil
+}
+
+func (s *selector) matches(m metav1.ObjectMeta) bool {
+	if _, found := s.namespaces[m.Namespace]; !found {
+		return false
+	}
+	return s.labels.Matches(labels.Set(m.Labels))
+}
+
+// secretKey returns the value of the selected key from the Secrets in the
+// given namespace.
+func secretKey(ns string, secrets []*v1.Secret, sel *v1.SecretKeySelector) ([]byte, error) {
+	if sel == nil {
+		return nil, nil
+	}
+
+	for _, s := range secrets {
+		if s.Namespace != ns || s.Name != sel.Name {
+			continue
+		}
+
+		if b, found := s.Data[sel.Key]; found {
+			return b, nil
+		}
+		return nil, errors.Errorf("key %v could not be found in Secret %v", sel.Key, sel.Name)
+	}
+
+	if sel.Optional == nil || !*sel.Optional {
+		return nil, errors.Errorf("secret %v could not be found", sel.Name)
+	}
+
+	return nil, nil
+}
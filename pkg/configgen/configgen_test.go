@@ -0,0 +1,161 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configgen
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerate(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			ServiceMonitorSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "a"},
+			},
+			ServiceMonitorNamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"monitoring": "true"},
+			},
+			AdditionalScrapeConfigs: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "additional"},
+				Key:                  "scrape.yaml",
+			},
+		},
+	}
+
+	newServiceMonitor := func(ns, name string, lbls map[string]string) *monitoringv1.ServiceMonitor {
+		return &monitoringv1.ServiceMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+				Labels:    lbls,
+			},
+			Spec: monitoringv1.ServiceMonitorSpec{
+				Endpoints: []monitoringv1.Endpoint{
+					{
+						Port: "web",
+						BasicAuth: &monitoringv1.BasicAuth{
+							Username: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "auth"},
+								Key:                  "user",
+							},
+							Password: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "auth"},
+								Key:                  "password",
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	cfg, err := Generate(context.Background(), nil, Input{
+		Prometheus: p,
+		ServiceMonitors: []*monitoringv1.ServiceMonitor{
+			newServiceMonitor("monitored", "selected", map[string]string{"team": "a"}),
+			newServiceMonitor("monitored", "wrong-labels", map[string]string{"team": "b"}),
+			newServiceMonitor("default", "wrong-namespace", map[string]string{"team": "a"}),
+		},
+		Namespaces: []*v1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "monitored", Labels: map[string]string{"monitoring": "true"}}},
+		},
+		Secrets: []*v1.Secret{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "auth", Namespace: "monitored"},
+				Data: map[string][]byte{
+					"user":     []byte("admin"),
+					"password": []byte("secret"),
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "additional", Namespace: "default"},
+				Data: map[string][]byte{
+					"scrape.yaml": []byte("- job_name: extra\n"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result struct {
+		ScrapeConfigs []struct {
+			JobName   string `yaml:"job_name"`
+			BasicAuth struct {
+				Username string `yaml:"username"`
+				Password string `yaml:"password"`
+			} `yaml:"basic_auth"`
+		} `yaml:"scrape_configs"`
+	}
+	if err := yaml.Unmarshal(cfg, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.ScrapeConfigs) != 2 {
+		t.Fatalf("expected 2 scrape configs, got %d:\n%s", len(result.ScrapeConfigs), cfg)
+	}
+
+	sc := result.ScrapeConfigs[0]
+	if !strings.HasPrefix(sc.JobName, "monitored/selected/") {
+		t.Fatalf("unexpected job name %q", sc.JobName)
+	}
+	if sc.BasicAuth.Username != "admin" || sc.BasicAuth.Password != "secret" {
+		t.Fatalf("unexpected basic auth %+v", sc.BasicAuth)
+	}
+
+	if result.ScrapeConfigs[1].JobName != "extra" {
+		t.Fatalf("unexpected job name %q", result.ScrapeConfigs[1].JobName)
+	}
+}
+
+func TestGenerateMissingSecret(t *testing.T) {
+	_, err := Generate(context.Background(), nil, Input{
+		Prometheus: &monitoringv1.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PrometheusSpec{
+				RemoteWrite: []monitoringv1.RemoteWriteSpec{
+					{
+						URL: "http://example.com",
+						BasicAuth: &monitoringv1.BasicAuth{
+							Username: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "missing"},
+								Key:                  "user",
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Fatal("expecting error, got no error")
+	}
+}
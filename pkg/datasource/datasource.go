@@ -0,0 +1,111 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package datasource generates the Grafana datasource provisioning files
+// describing the Prometheus and Thanos Ruler instances managed by the
+// operator.
+// See https://grafana.com/docs/grafana/latest/administration/provisioning/#data-sources
+package datasource
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigMapKey is the key of the provisioning file in the ConfigMap.
+const ConfigMapKey = "datasource.yaml"
+
+// defaultLabels match the default label selector of the Grafana datasources
+// sidecar.
+var defaultLabels = map[string]string{"grafana_datasource": "1"}
+
+type provisioningFile struct {
+	APIVersion  int          `yaml:"apiVersion"`
+	Datasources []datasource `yaml:"datasources"`
+}
+
+type datasource struct {
+	Name           string            `yaml:"name"`
+	Type           string            `yaml:"type"`
+	Access         string            `yaml:"access"`
+	URL            string            `yaml:"url"`
+	JSONData       yaml.MapSlice     `yaml:"jsonData,omitempty"`
+	SecureJSONData map[string]string `yaml:"secureJsonData,omitempty"`
+}
+
+// Labels returns the labels of the ConfigMap.
+func Labels(spec *monitoringv1.DatasourceExportSpec) map[string]string {
+	if len(spec.Labels) == 0 {
+		return defaultLabels
+	}
+
+	return spec.Labels
+}
+
+// MakeConfigMapData returns the data of the ConfigMap describing the
+// datasource. The name and URL are used when the spec doesn't set them.
+func MakeConfigMapData(spec *monitoringv1.DatasourceExportSpec, name, defaultURL string) (map[string]string, error) {
+	ds := datasource{
+		Name:   name,
+		Type:   "prometheus",
+		Access: "proxy",
+		URL:    defaultURL,
+	}
+
+	if spec.Name != "" {
+		ds.Name = spec.Name
+	}
+
+	if spec.URL != "" {
+		if _, err := url.Parse(spec.URL); err != nil {
+			return nil, errors.Wrapf(err, "invalid datasource URL %q", spec.URL)
+		}
+		ds.URL = spec.URL
+	}
+
+	if spec.InsecureSkipVerify {
+		ds.JSONData = append(ds.JSONData, yaml.MapItem{Key: "tlsSkipVerify", Value: true})
+	}
+
+	// Grafana expects the headers as numbered pairs of fields, the values
+	// being in the secure fields.
+	headers := make([]string, 0, len(spec.HTTPHeaders))
+	for h := range spec.HTTPHeaders {
+		if h == "" {
+			return nil, errors.New("HTTP header names must not be empty")
+		}
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+
+	for i, h := range headers {
+		ds.JSONData = append(ds.JSONData, yaml.MapItem{Key: fmt.Sprintf("httpHeaderName%d", i+1), Value: h})
+		if ds.SecureJSONData == nil {
+			ds.SecureJSONData = map[string]string{}
+		}
+		ds.SecureJSONData[fmt.Sprintf("httpHeaderValue%d", i+1)] = spec.HTTPHeaders[h]
+	}
+
+	b, err := yaml.Marshal(provisioningFile{APIVersion: 1, Datasources: []datasource{ds}})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling datasource failed")
+	}
+
+	return map[string]string{ConfigMapKey: string(b)}, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeConfigMapData(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     *monitoringv1.DatasourceExportSpec
+		expected string
+		err      bool
+	}{
+		{
+			name: "defaults",
+			spec: &monitoringv1.DatasourceExportSpec{},
+			expected: `apiVersion: 1
+datasources:
+- name: ns/test
+  type: prometheus
+  access: proxy
+  url: http://prometheus-test-0.prometheus-operated.ns.svc:9090
+`,
+		},
+		{
+			name: "name and url",
+			spec: &monitoringv1.DatasourceExportSpec{
+				Name: "Prometheus",
+				URL:  "https://prometheus.example.com",
+			},
+			expected: `apiVersion: 1
+datasources:
+- name: Prometheus
+  type: prometheus
+  access: proxy
+  url: https://prometheus.example.com
+`,
+		},
+		{
+			name: "tls and headers",
+			spec: &monitoringv1.DatasourceExportSpec{
+				InsecureSkipVerify: true,
+				HTTPHeaders: map[string]string{
+					"X-Scope-OrgID": "team-a",
+					"X-Custom":      "value",
+				},
+			},
+			expected: `apiVersion: 1
+datasources:
+- name: ns/test
+  type: prometheus
+  access: proxy
+  url: http://prometheus-test-0.prometheus-operated.ns.svc:9090
+  jsonData:
+    tlsSkipVerify: true
+    httpHeaderName1: X-Custom
+    httpHeaderName2: X-Scope-OrgID
+  secureJsonData:
+    httpHeaderValue1: value
+    httpHeaderValue2: team-a
+`,
+		},
+		{
+			name: "invalid url",
+			spec: &monitoringv1.DatasourceExportSpec{
+				URL: "http://[::1",
+			},
+			err: true,
+		},
+		{
+			name: "empty header name",
+			spec: &monitoringv1.DatasourceExportSpec{
+				HTTPHeaders: map[string]string{"": "value"},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := MakeConfigMapData(tc.spec, "ns/test", "http://prometheus-test-0.prometheus-operated.ns.svc:9090")
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{ConfigMapKey: tc.expected}, data)
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	require.Equal(t, map[string]string{"grafana_datasource": "1"}, Labels(&monitoringv1.DatasourceExportSpec{}))
+	require.Equal(t, map[string]string{"team": "a"}, Labels(&monitoringv1.DatasourceExportSpec{Labels: map[string]string{"team": "a"}}))
+}
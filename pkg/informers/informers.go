@@ -15,6 +15,9 @@
 package informers
 
 import (
+	"sort"
+	"sync"
+
 	"github.com/pkg/errors"
 	"github.com/prometheus-operator/prometheus-operator/pkg/listwatch"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -38,12 +41,26 @@ type InformLister interface {
 type FactoriesForNamespaces interface {
 	ForResource(namespace string, resource schema.GroupVersionResource) (InformLister, error)
 	Namespaces() sets.String
+	// RemoveNamespace drops the factory of the namespace so that new
+	// informers are created if the namespace is added again.
+	RemoveNamespace(namespace string)
 }
 
 // ForResource contains a slice of InformLister for a concrete resource type,
-// one per namespace.
+// one per namespace. Namespaces can be added and removed at runtime with
+// SetNamespaces.
 type ForResource struct {
-	informers []InformLister
+	factories FactoriesForNamespaces
+	resource  schema.GroupVersionResource
+
+	mtx        sync.RWMutex
+	namespaces []string
+	informers  []InformLister
+	handlers   []cache.ResourceEventHandler
+	// stopCh is the stop channel given to Start, nil until then.
+	stopCh <-chan struct{}
+	// nsStopChs stops the informers of the removed namespaces.
+	nsStopChs map[string]chan struct{}
 }
 
 // NewInformersForResource returns a composite informer exposing the most basic set of operations
@@ -65,24 +82,139 @@ func NewInformersForResource(ifs FactoriesForNamespaces, resource schema.GroupVe
 	}
 
 	return &ForResource{
-		informers: informers,
+		factories:  ifs,
+		resource:   resource,
+		namespaces: namespaces,
+		informers:  informers,
+		nsStopChs:  map[string]chan struct{}{},
 	}, nil
 }
 
 // Start starts all underlying informers, passing the given stop channel to each of them.
 func (w *ForResource) Start(stopCh <-chan struct{}) {
-	for _, i := range w.informers {
-		go i.Informer().Run(stopCh)
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.stopCh = stopCh
+	for i, ns := range w.namespaces {
+		w.run(ns, w.informers[i])
+	}
+}
+
+// run starts the informer of the namespace until the ForResource is stopped
+// or the namespace is removed. The caller must hold the lock.
+func (w *ForResource) run(ns string, inf InformLister) {
+	nsStopCh := make(chan struct{})
+	w.nsStopChs[ns] = nsStopCh
+
+	stopCh := make(chan struct{})
+	go func(parent <-chan struct{}) {
+		defer close(stopCh)
+		select {
+		case <-parent:
+		case <-nsStopCh:
+		}
+	}(w.stopCh)
+
+	go inf.Informer().Run(stopCh)
+}
+
+// SetNamespaces updates the watched namespaces, creating the informers of
+// the added namespaces and stopping the informers of the removed ones. It
+// waits for the caches of the new informers to be synced when the
+// ForResource is started. It returns whether the namespaces changed.
+// SetNamespaces is a no-op if all the namespaces are watched.
+func (w *ForResource) SetNamespaces(namespaces sets.String) (bool, error) {
+	w.mtx.Lock()
+
+	if len(w.namespaces) == 1 && w.namespaces[0] == v1.NamespaceAll {
+		w.mtx.Unlock()
+		return false, nil
+	}
+
+	var (
+		changed   bool
+		nsList    []string
+		infList   []InformLister
+		newSynced []cache.InformerSynced
+		current   = sets.NewString(w.namespaces...)
+		addErr    error
+	)
+	for i, ns := range w.namespaces {
+		if namespaces.Has(ns) {
+			nsList = append(nsList, ns)
+			infList = append(infList, w.informers[i])
+			continue
+		}
+
+		changed = true
+		if stopCh, ok := w.nsStopChs[ns]; ok {
+			close(stopCh)
+			delete(w.nsStopChs, ns)
+		}
+		w.factories.RemoveNamespace(ns)
 	}
+
+	for _, ns := range namespaces.Difference(current).List() {
+		inf, err := w.factories.ForResource(ns, w.resource)
+		if err != nil {
+			addErr = errors.Wrapf(err, "error getting informer in namespace %q for resource %v", ns, w.resource)
+			continue
+		}
+
+		changed = true
+		for _, h := range w.handlers {
+			inf.Informer().AddEventHandler(h)
+		}
+		if w.stopCh != nil {
+			w.run(ns, inf)
+			newSynced = append(newSynced, inf.Informer().HasSynced)
+		}
+		nsList = append(nsList, ns)
+		infList = append(infList, inf)
+	}
+
+	// Keep the informers sorted by namespace like at creation time.
+	sort.Sort(byNamespace{namespaces: nsList, informers: infList})
+	w.namespaces = nsList
+	w.informers = infList
+	stopCh := w.stopCh
+	w.mtx.Unlock()
+
+	if len(newSynced) > 0 && !cache.WaitForCacheSync(stopCh, newSynced...) {
+		return changed, errors.Errorf("failed to sync the caches of the new namespaces for resource %v", w.resource)
+	}
+
+	return changed, addErr
+}
+
+type byNamespace struct {
+	namespaces []string
+	informers  []InformLister
+}
+
+func (b byNamespace) Len() int           { return len(b.namespaces) }
+func (b byNamespace) Less(i, j int) bool { return b.namespaces[i] < b.namespaces[j] }
+func (b byNamespace) Swap(i, j int) {
+	b.namespaces[i], b.namespaces[j] = b.namespaces[j], b.namespaces[i]
+	b.informers[i], b.informers[j] = b.informers[j], b.informers[i]
 }
 
 // GetInformers returns all wrapped informers.
 func (w *ForResource) GetInformers() []InformLister {
-	return w.informers
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	return append([]InformLister(nil), w.informers...)
 }
 
-// AddEventHandler registers the given handler to all wrapped informers.
+// AddEventHandler registers the given handler to all wrapped informers,
+// including the informers of the namespaces added later.
 func (w *ForResource) AddEventHandler(handler cache.ResourceEventHandler) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.handlers = append(w.handlers, handler)
 	for _, i := range w.informers {
 		i.Informer().AddEventHandler(handler)
 	}
@@ -90,7 +222,7 @@ func (w *ForResource) AddEventHandler(handler cache.ResourceEventHandler) {
 
 // HasSynced returns true if all underlying informers have synced, else false.
 func (w *ForResource) HasSynced() bool {
-	for _, i := range w.informers {
+	for _, i := range w.GetInformers() {
 		if !i.Informer().HasSynced() {
 			return false
 		}
@@ -104,7 +236,7 @@ func (w *ForResource) HasSynced() bool {
 func (w *ForResource) List(selector labels.Selector) ([]runtime.Object, error) {
 	var ret []runtime.Object
 
-	for _, inf := range w.informers {
+	for _, inf := range w.GetInformers() {
 		objs, err := inf.Lister().List(selector)
 		if err != nil {
 			return nil, err
@@ -119,7 +251,7 @@ func (w *ForResource) List(selector labels.Selector) ([]runtime.Object, error) {
 // While wrapped informers are usually namespace aware, it is still important to iterate over all of them
 // as some informers might wrap k8s.io/apimachinery/pkg/apis/meta/v1.NamespaceAll.
 func (w *ForResource) ListAllByNamespace(namespace string, selector labels.Selector, appendFn cache.AppendFunc) error {
-	for _, inf := range w.informers {
+	for _, inf := range w.GetInformers() {
 		err := cache.ListAllByNamespace(inf.Informer().GetIndexer(), namespace, selector, appendFn)
 		if err != nil {
 			return err
@@ -134,7 +266,7 @@ func (w *ForResource) ListAllByNamespace(namespace string, selector labels.Selec
 func (w *ForResource) Get(name string) (runtime.Object, error) {
 	var err error
 
-	for _, inf := range w.informers {
+	for _, inf := range w.GetInformers() {
 		var ret runtime.Object
 		ret, err = inf.Lister().Get(name)
 		if apierrors.IsNotFound(err) {
@@ -69,6 +69,10 @@ func (m *mockFactory) Namespaces() sets.String {
 	return m.namespaces
 }
 
+func (m *mockFactory) RemoveNamespace(namespace string) {
+	m.namespaces.Delete(namespace)
+}
+
 func TestInformers(t *testing.T) {
 	t.Run("TestGet", func(t *testing.T) {
 		ifs, err := NewInformersForResource(
@@ -103,6 +107,53 @@ func TestInformers(t *testing.T) {
 	})
 }
 
+func TestSetNamespaces(t *testing.T) {
+	ifs, err := NewInformersForResource(
+		&mockFactory{
+			namespaces: sets.NewString("foo", "bar"),
+		},
+		schema.GroupVersionResource{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		namespaces sets.String
+		changed    bool
+	}{
+		{
+			namespaces: sets.NewString("bar", "foo"),
+			changed:    false,
+		},
+		{
+			namespaces: sets.NewString("bar", "baz"),
+			changed:    true,
+		},
+		{
+			namespaces: sets.NewString(),
+			changed:    true,
+		},
+	} {
+		changed, err := ifs.SetNamespaces(tc.namespaces)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if changed != tc.changed {
+			t.Errorf("expected changed to be %v, got %v", tc.changed, changed)
+		}
+
+		if !sets.NewString(ifs.namespaces...).Equal(tc.namespaces) {
+			t.Errorf("expected namespaces %v, got %v", tc.namespaces.List(), ifs.namespaces)
+		}
+
+		if len(ifs.GetInformers()) != tc.namespaces.Len() {
+			t.Errorf("expected %d informers, got %d", tc.namespaces.Len(), len(ifs.GetInformers()))
+		}
+	}
+}
+
 func TestNewInformerOptions(t *testing.T) {
 	for _, tc := range []struct {
 		name                                string
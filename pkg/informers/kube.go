@@ -15,6 +15,7 @@
 package informers
 
 import (
+	"sync"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -37,23 +38,62 @@ func NewKubeInformerFactories(
 		allowNamespaces, denyNamespaces, tweakListOptions,
 	)
 
-	opts := []informers.SharedInformerOption{informers.WithTweakListOptions(tweaks)}
-
-	ret := kubeInformersForNamespaces{}
+	ret := &kubeInformersForNamespaces{
+		client:        kubeClient,
+		defaultResync: defaultResync,
+		tweaks:        tweaks,
+		factories:     map[string]informers.SharedInformerFactory{},
+	}
 	for _, namespace := range namespaces {
-		opts = append(opts, informers.WithNamespace(namespace))
-		ret[namespace] = informers.NewSharedInformerFactoryWithOptions(kubeClient, defaultResync, opts...)
+		ret.factories[namespace] = ret.newFactory(namespace)
 	}
 
 	return ret
 }
 
-type kubeInformersForNamespaces map[string]informers.SharedInformerFactory
+type kubeInformersForNamespaces struct {
+	client        kubernetes.Interface
+	defaultResync time.Duration
+	tweaks        func(*metav1.ListOptions)
+
+	mtx       sync.Mutex
+	factories map[string]informers.SharedInformerFactory
+}
+
+func (i *kubeInformersForNamespaces) newFactory(namespace string) informers.SharedInformerFactory {
+	return informers.NewSharedInformerFactoryWithOptions(
+		i.client,
+		i.defaultResync,
+		informers.WithTweakListOptions(i.tweaks),
+		informers.WithNamespace(namespace),
+	)
+}
+
+func (i *kubeInformersForNamespaces) Namespaces() sets.String {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
 
-func (i kubeInformersForNamespaces) Namespaces() sets.String {
-	return sets.StringKeySet(i)
+	return sets.StringKeySet(i.factories)
 }
 
-func (i kubeInformersForNamespaces) ForResource(namespace string, resource schema.GroupVersionResource) (InformLister, error) {
-	return i[namespace].ForResource(resource)
+// ForResource returns the informer of the resource in the namespace,
+// creating the factory of the namespace if it doesn't exist yet.
+func (i *kubeInformersForNamespaces) ForResource(namespace string, resource schema.GroupVersionResource) (InformLister, error) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	f, ok := i.factories[namespace]
+	if !ok {
+		f = i.newFactory(namespace)
+		i.factories[namespace] = f
+	}
+
+	return f.ForResource(resource)
+}
+
+func (i *kubeInformersForNamespaces) RemoveNamespace(namespace string) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	delete(i.factories, namespace)
 }
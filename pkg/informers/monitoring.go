@@ -15,6 +15,7 @@
 package informers
 
 import (
+	"sync"
 	"time"
 
 	informers "github.com/prometheus-operator/prometheus-operator/pkg/client/informers/externalversions"
@@ -37,23 +38,62 @@ func NewMonitoringInformerFactories(
 		allowNamespaces, denyNamespaces, tweakListOptions,
 	)
 
-	opts := []informers.SharedInformerOption{informers.WithTweakListOptions(tweaks)}
-
-	ret := monitoringInformersForNamespaces{}
+	ret := &monitoringInformersForNamespaces{
+		client:        monitoringClient,
+		defaultResync: defaultResync,
+		tweaks:        tweaks,
+		factories:     map[string]informers.SharedInformerFactory{},
+	}
 	for _, namespace := range namespaces {
-		opts = append(opts, informers.WithNamespace(namespace))
-		ret[namespace] = informers.NewSharedInformerFactoryWithOptions(monitoringClient, defaultResync, opts...)
+		ret.factories[namespace] = ret.newFactory(namespace)
 	}
 
 	return ret
 }
 
-type monitoringInformersForNamespaces map[string]informers.SharedInformerFactory
+type monitoringInformersForNamespaces struct {
+	client        monitoring.Interface
+	defaultResync time.Duration
+	tweaks        func(*metav1.ListOptions)
+
+	mtx       sync.Mutex
+	factories map[string]informers.SharedInformerFactory
+}
+
+func (i *monitoringInformersForNamespaces) newFactory(namespace string) informers.SharedInformerFactory {
+	return informers.NewSharedInformerFactoryWithOptions(
+		i.client,
+		i.defaultResync,
+		informers.WithTweakListOptions(i.tweaks),
+		informers.WithNamespace(namespace),
+	)
+}
+
+func (i *monitoringInformersForNamespaces) Namespaces() sets.String {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
 
-func (i monitoringInformersForNamespaces) Namespaces() sets.String {
-	return sets.StringKeySet(i)
+	return sets.StringKeySet(i.factories)
 }
 
-func (i monitoringInformersForNamespaces) ForResource(namespace string, resource schema.GroupVersionResource) (InformLister, error) {
-	return i[namespace].ForResource(resource)
+// ForResource returns the informer of the resource in the namespace,
+// creating the factory of the namespace if it doesn't exist yet.
+func (i *monitoringInformersForNamespaces) ForResource(namespace string, resource schema.GroupVersionResource) (InformLister, error) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	f, ok := i.factories[namespace]
+	if !ok {
+		f = i.newFactory(namespace)
+		i.factories[namespace] = f
+	}
+
+	return f.ForResource(resource)
+}
+
+func (i *monitoringInformersForNamespaces) RemoveNamespace(namespace string) {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	delete(i.factories, namespace)
 }
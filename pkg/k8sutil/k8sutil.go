@@ -20,17 +20,20 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
 
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/discovery"
 	clientv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	networkingv1client "k8s.io/client-go/kubernetes/typed/networking/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -128,6 +131,41 @@ func CreateOrUpdateService(ctx context.Context, sclient clientv1.ServiceInterfac
 	return nil
 }
 
+// CreateOrUpdateServiceAccount creates the given ServiceAccount or updates
+// the labels, annotations and owner references of the existing one. Other
+// labels and annotations as well as the secrets of the existing
+// ServiceAccount are preserved.
+func CreateOrUpdateServiceAccount(ctx context.Context, sclient clientv1.ServiceAccountInterface, sa *v1.ServiceAccount) error {
+	existing, err := sclient.Get(ctx, sa.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving service account object failed")
+	}
+
+	if apierrors.IsNotFound(err) {
+		_, err = sclient.Create(ctx, sa, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "creating service account object failed")
+		}
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.SetLabels(mergeStringMaps(existing.GetLabels(), sa.GetLabels()))
+	updated.SetAnnotations(mergeStringMaps(existing.GetAnnotations(), sa.GetAnnotations()))
+	updated.SetOwnerReferences(mergeOwnerReferences(existing.GetOwnerReferences(), sa.GetOwnerReferences()))
+
+	if reflect.DeepEqual(existing.ObjectMeta, updated.ObjectMeta) {
+		return nil
+	}
+
+	_, err = sclient.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "updating service account object failed")
+	}
+
+	return nil
+}
+
 func CreateOrUpdateEndpoints(ctx context.Context, eclient clientv1.EndpointsInterface, eps *v1.Endpoints) error {
 	endpoints, err := eclient.Get(ctx, eps.Name, metav1.GetOptions{})
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -165,6 +203,26 @@ func GetMinorVersion(dclient discovery.DiscoveryInterface) (int, error) {
 	return ver.Segments()[1], nil
 }
 
+// IsAPIGroupVersionResourceSupported returns whether the API server serves
+// the resource for the given group version.
+func IsAPIGroupVersionResourceSupported(dclient discovery.DiscoveryInterface, groupVersion string, resource string) (bool, error) {
+	list, err := dclient.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, r := range list.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 // SanitizeVolumeName ensures that the given volume name is a valid DNS-1123 label
 // accepted by Kubernetes.
 func SanitizeVolumeName(name string) string {
@@ -176,6 +234,35 @@ func SanitizeVolumeName(name string) string {
 	return strings.Trim(name, "-")
 }
 
+// ServiceHost returns the DNS name of a service in the given namespace. The
+// name can be prefixed by a Pod name for the Pods of a headless service. When
+// the cluster domain is empty, the DNS search path of the caller completes the
+// name.
+func ServiceHost(name, namespace, clusterDomain string) string {
+	host := fmt.Sprintf("%s.%s.svc", name, namespace)
+	if clusterDomain != "" {
+		host += "." + strings.Trim(clusterDomain, ".")
+	}
+	return host
+}
+
+// mergeStringMaps returns a new map containing the keys of both maps. The
+// values of the second map take precedence.
+func mergeStringMaps(old, new map[string]string) map[string]string {
+	if len(old) == 0 && len(new) == 0 {
+		return old
+	}
+
+	res := make(map[string]string, len(old)+len(new))
+	for k, v := range old {
+		res[k] = v
+	}
+	for k, v := range new {
+		res[k] = v
+	}
+	return res
+}
+
 func mergeOwnerReferences(old []metav1.OwnerReference, new []metav1.OwnerReference) []metav1.OwnerReference {
 	existing := make(map[metav1.OwnerReference]bool)
 	for _, ownerRef := range old {
@@ -188,3 +275,102 @@ func mergeOwnerReferences(old []metav1.OwnerReference, new []metav1.OwnerReferen
 	}
 	return old
 }
+
+// CreateOrUpdateSecret creates the given Secret or replaces the labels,
+// owner references and data of the existing one.
+func CreateOrUpdateSecret(ctx context.Context, sClient clientv1.SecretInterface, s *v1.Secret) error {
+	existing, err := sClient.Get(ctx, s.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving secret object failed")
+	}
+
+	if apierrors.IsNotFound(err) {
+		_, err = sClient.Create(ctx, s, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "creating secret object failed")
+		}
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.SetLabels(mergeStringMaps(existing.GetLabels(), s.GetLabels()))
+	updated.SetOwnerReferences(mergeOwnerReferences(existing.GetOwnerReferences(), s.GetOwnerReferences()))
+	updated.Data = s.Data
+
+	if reflect.DeepEqual(existing, updated) {
+		return nil
+	}
+
+	_, err = sClient.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "updating secret object failed")
+	}
+
+	return nil
+}
+
+// CreateOrUpdateConfigMap creates the given ConfigMap or replaces the
+// labels, owner references and data of the existing one.
+func CreateOrUpdateConfigMap(ctx context.Context, cmClient clientv1.ConfigMapInterface, cm *v1.ConfigMap) error {
+	existing, err := cmClient.Get(ctx, cm.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving configmap object failed")
+	}
+
+	if apierrors.IsNotFound(err) {
+		_, err = cmClient.Create(ctx, cm, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "creating configmap object failed")
+		}
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.SetLabels(mergeStringMaps(existing.GetLabels(), cm.GetLabels()))
+	updated.SetOwnerReferences(mergeOwnerReferences(existing.GetOwnerReferences(), cm.GetOwnerReferences()))
+	updated.Data = cm.Data
+
+	if reflect.DeepEqual(existing, updated) {
+		return nil
+	}
+
+	_, err = cmClient.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "updating configmap object failed")
+	}
+
+	return nil
+}
+
+// CreateOrUpdateNetworkPolicy creates the given NetworkPolicy or replaces
+// the labels, owner references and spec of the existing one.
+func CreateOrUpdateNetworkPolicy(ctx context.Context, npClient networkingv1client.NetworkPolicyInterface, np *networkingv1.NetworkPolicy) error {
+	existing, err := npClient.Get(ctx, np.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving network policy object failed")
+	}
+
+	if apierrors.IsNotFound(err) {
+		_, err = npClient.Create(ctx, np, metav1.CreateOptions{})
+		if err != nil {
+			return errors.Wrap(err, "creating network policy object failed")
+		}
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.SetLabels(mergeStringMaps(existing.GetLabels(), np.GetLabels()))
+	updated.SetOwnerReferences(mergeOwnerReferences(existing.GetOwnerReferences(), np.GetOwnerReferences()))
+	updated.Spec = np.Spec
+
+	if reflect.DeepEqual(existing, updated) {
+		return nil
+	}
+
+	_, err = npClient.Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "updating network policy object failed")
+	}
+
+	return nil
+}
@@ -15,10 +15,15 @@
 package k8sutil
 
 import (
+	"context"
+	"reflect"
 	"strings"
 	"testing"
 
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func Test_SanitizeVolumeName(t *testing.T) {
@@ -59,3 +64,145 @@ func Test_SanitizeVolumeName(t *testing.T) {
 		}
 	}
 }
+
+func TestServiceHost(t *testing.T) {
+	for _, c := range []struct {
+		clusterDomain string
+		expected      string
+	}{
+		{
+			expected: "prometheus-k8s-0.prometheus-operated.monitoring.svc",
+		},
+		{
+			clusterDomain: "cluster.local",
+			expected:      "prometheus-k8s-0.prometheus-operated.monitoring.svc.cluster.local",
+		},
+		{
+			clusterDomain: "example.org.",
+			expected:      "prometheus-k8s-0.prometheus-operated.monitoring.svc.example.org",
+		},
+	} {
+		out := ServiceHost("prometheus-k8s-0.prometheus-operated", "monitoring", c.clusterDomain)
+		if c.expected != out {
+			t.Errorf("expected %q for cluster domain %q but got %q", c.expected, c.clusterDomain, out)
+		}
+	}
+}
+
+func TestCreateOrUpdateServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewSimpleClientset(
+		&v1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "existing",
+				Namespace: "ns1",
+				Labels: map[string]string{
+					"foo": "bar",
+				},
+				Annotations: map[string]string{
+					"iam.gke.io/gcp-service-account": "old@example.iam.gserviceaccount.com",
+				},
+			},
+			Secrets: []v1.ObjectReference{{Name: "existing-token"}},
+		},
+	)
+	client := c.CoreV1().ServiceAccounts("ns1")
+
+	for _, tc := range []struct {
+		name                string
+		sa                  *v1.ServiceAccount
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+		expectedSecrets     []v1.ObjectReference
+	}{
+		{
+			name: "create",
+			sa: &v1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "new",
+					Annotations: map[string]string{
+						"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/prometheus",
+					},
+				},
+			},
+			expectedAnnotations: map[string]string{
+				"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/prometheus",
+			},
+		},
+		{
+			name: "update",
+			sa: &v1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "existing",
+					Labels: map[string]string{
+						"team": "monitoring",
+					},
+					Annotations: map[string]string{
+						"iam.gke.io/gcp-service-account": "new@example.iam.gserviceaccount.com",
+					},
+				},
+			},
+			expectedLabels: map[string]string{
+				"foo":  "bar",
+				"team": "monitoring",
+			},
+			expectedAnnotations: map[string]string{
+				"iam.gke.io/gcp-service-account": "new@example.iam.gserviceaccount.com",
+			},
+			expectedSecrets: []v1.ObjectReference{{Name: "existing-token"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := CreateOrUpdateServiceAccount(ctx, client, tc.sa); err != nil {
+				t.Fatal(err)
+			}
+
+			sa, err := client.Get(ctx, tc.sa.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(sa.Labels, tc.expectedLabels) {
+				t.Fatalf("expected labels %v, got %v", tc.expectedLabels, sa.Labels)
+			}
+			if !reflect.DeepEqual(sa.Annotations, tc.expectedAnnotations) {
+				t.Fatalf("expected annotations %v, got %v", tc.expectedAnnotations, sa.Annotations)
+			}
+			if !reflect.DeepEqual(sa.Secrets, tc.expectedSecrets) {
+				t.Fatalf("expected secrets %v, got %v", tc.expectedSecrets, sa.Secrets)
+			}
+		})
+	}
+}
+
+func TestIsAPIGroupVersionResourceSupported(t *testing.T) {
+	c := fake.NewSimpleClientset()
+	c.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "discovery.k8s.io/v1beta1",
+			APIResources: []metav1.APIResource{{Name: "endpointslices"}},
+		},
+		{
+			GroupVersion: "discovery.k8s.io/v1",
+			APIResources: []metav1.APIResource{},
+		},
+	}
+
+	for _, tc := range []struct {
+		groupVersion string
+		expected     bool
+	}{
+		{groupVersion: "discovery.k8s.io/v1beta1", expected: true},
+		{groupVersion: "discovery.k8s.io/v1", expected: false},
+	} {
+		t.Run(tc.groupVersion, func(t *testing.T) {
+			supported, err := IsAPIGroupVersionResourceSupported(c.Discovery(), tc.groupVersion, "endpointslices")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if supported != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, supported)
+			}
+		})
+	}
+}
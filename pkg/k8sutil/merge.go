@@ -18,7 +18,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 )
 
@@ -73,3 +75,28 @@ func MergePatchContainers(base, patches []v1.Container) ([]v1.Container, error)
 
 	return out, nil
 }
+
+// PatchStatefulSet applies patch to sset using a strategic merge patch and
+// returns the result. The StatefulSet is returned unchanged if patch is empty.
+func PatchStatefulSet(sset *appsv1.StatefulSet, patch *runtime.RawExtension) (*appsv1.StatefulSet, error) {
+	if patch == nil || len(patch.Raw) == 0 {
+		return sset, nil
+	}
+
+	ssetBytes, err := json.Marshal(sset)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to marshal json for statefulset %s", sset.Name))
+	}
+
+	jsonResult, err := strategicpatch.StrategicMergePatch(ssetBytes, patch.Raw, appsv1.StatefulSet{})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to apply patch to statefulset %s", sset.Name))
+	}
+
+	var patchResult appsv1.StatefulSet
+	if err := json.Unmarshal(jsonResult, &patchResult); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to unmarshal patched statefulset %s", sset.Name))
+	}
+
+	return &patchResult, nil
+}
@@ -17,7 +17,10 @@ package k8sutil
 import (
 	"github.com/kylelemons/godebug/pretty"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"testing"
 )
 
@@ -117,3 +120,43 @@ func TestPodLabelsAnnotations(t *testing.T) {
 		}
 	}
 }
+
+func TestPatchStatefulSet(t *testing.T) {
+	sset := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test",
+			Labels: map[string]string{"app": "test"},
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "a", Image: "image-a"},
+						{Name: "b", Image: "image-b"},
+					},
+				},
+			},
+		},
+	}
+
+	// A nil patch leaves the StatefulSet unchanged.
+	res, err := PatchStatefulSet(sset, nil)
+	require.NoError(t, err)
+	require.Equal(t, sset, res)
+
+	patch := &runtime.RawExtension{
+		Raw: []byte(`{"metadata":{"labels":{"extra":"label"}},"spec":{"template":{"spec":{"priorityClassName":"high","containers":[{"name":"b","image":"image-c"}]}}}}`),
+	}
+	res, err = PatchStatefulSet(sset, patch)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"app": "test", "extra": "label"}, res.Labels)
+	require.Equal(t, "high", res.Spec.Template.Spec.PriorityClassName)
+	require.Equal(t, []v1.Container{
+		{Name: "a", Image: "image-a"},
+		{Name: "b", Image: "image-c"},
+	}, res.Spec.Template.Spec.Containers)
+
+	_, err = PatchStatefulSet(sset, &runtime.RawExtension{Raw: []byte(`not json`)})
+	require.Error(t, err)
+}
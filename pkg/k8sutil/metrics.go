@@ -20,6 +20,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/util/workqueue"
 )
 
 type clientGoHTTPMetricAdapter struct {
@@ -85,3 +86,106 @@ func (a *clientGoHTTPMetricAdapter) Observe(verb string, u url.URL, latency time
 func (a *clientGoRateLimiterMetricAdapter) Observe(verb string, u url.URL, latency time.Duration) {
 	a.duration.WithLabelValues(u.EscapedPath()).Observe(latency.Seconds())
 }
+
+type workqueueMetricsProvider struct {
+	depth                   *prometheus.GaugeVec
+	adds                    *prometheus.CounterVec
+	latency                 *prometheus.HistogramVec
+	workDuration            *prometheus.HistogramVec
+	unfinishedWork          *prometheus.GaugeVec
+	longestRunningProcessor *prometheus.GaugeVec
+	retries                 *prometheus.CounterVec
+}
+
+// MustRegisterWorkqueueMetrics registers the metrics of the
+// k8s.io/client-go work queues. It must be called before the queues are
+// created and it panics if it encounters an error (e.g. metrics already
+// registered).
+func MustRegisterWorkqueueMetrics(registerer prometheus.Registerer) {
+	buckets := prometheus.ExponentialBuckets(0.001, 4, 10)
+	p := &workqueueMetricsProvider{
+		depth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "prometheus_operator_workqueue_depth",
+				Help: "Current number of items waiting in the work queue.",
+			},
+			[]string{"queue"},
+		),
+		adds: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "prometheus_operator_workqueue_adds_total",
+				Help: "Total number of items added to the work queue.",
+			},
+			[]string{"queue"},
+		),
+		latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "prometheus_operator_workqueue_queue_duration_seconds",
+				Help:    "How long in seconds an item stays in the work queue before being processed.",
+				Buckets: buckets,
+			},
+			[]string{"queue"},
+		),
+		workDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "prometheus_operator_workqueue_work_duration_seconds",
+				Help:    "How long in seconds processing an item from the work queue takes.",
+				Buckets: buckets,
+			},
+			[]string{"queue"},
+		),
+		unfinishedWork: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "prometheus_operator_workqueue_unfinished_work_seconds",
+				Help: "Sum in seconds of the duration of the items being processed.",
+			},
+			[]string{"queue"},
+		),
+		longestRunningProcessor: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "prometheus_operator_workqueue_longest_running_processor_seconds",
+				Help: "Duration in seconds of the longest running item being processed.",
+			},
+			[]string{"queue"},
+		),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "prometheus_operator_workqueue_retries_total",
+				Help: "Total number of retries handled by the work queue.",
+			},
+			[]string{"queue"},
+		),
+	}
+
+	workqueue.SetProvider(p)
+
+	registerer.MustRegister(p.depth, p.adds, p.latency, p.workDuration, p.unfinishedWork, p.longestRunningProcessor, p.retries)
+}
+
+func (p *workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return p.depth.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return p.adds.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return p.latency.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return p.workDuration.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return p.unfinishedWork.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return p.longestRunningProcessor.WithLabelValues(name)
+}
+
+func (p *workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return p.retries.WithLabelValues(name)
+}
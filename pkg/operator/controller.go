@@ -0,0 +1,106 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// ModeFull is the mode in which the operator generates the
+	// configuration and manages the workloads of the resources.
+	ModeFull = "full"
+	// ModeConfigOnly is the mode in which the operator only generates the
+	// configuration of the resources. The workloads are managed externally.
+	ModeConfigOnly = "config-only"
+)
+
+// ControllerConfig defines how a controller resyncs its objects and
+// processes its work queue.
+type ControllerConfig struct {
+	// ResyncPeriod is the period at which the informers of the controller
+	// resync all the objects.
+	ResyncPeriod time.Duration
+	// Workers is the number of objects synced concurrently.
+	Workers int
+	// RateLimiter defines how the keys are requeued.
+	RateLimiter RateLimiterConfig
+}
+
+// RateLimiterConfig defines the rate limiter of a work queue. A key is
+// requeued after the maximum of its exponential per-item backoff and of the
+// delay imposed by the overall token bucket.
+type RateLimiterConfig struct {
+	// BaseDelay is the delay before requeuing a key after its first failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential backoff of a failing key.
+	MaxDelay time.Duration
+	// QPS is the overall rate at which keys are requeued.
+	QPS float64
+	// Burst is the number of keys requeued without delay above QPS.
+	Burst int
+}
+
+// DefaultControllerConfig returns the settings matching the defaults of
+// client-go.
+func DefaultControllerConfig() ControllerConfig {
+	return ControllerConfig{
+		ResyncPeriod: 5 * time.Minute,
+		Workers:      1,
+		RateLimiter: RateLimiterConfig{
+			BaseDelay: 5 * time.Millisecond,
+			MaxDelay:  1000 * time.Second,
+			QPS:       10,
+			Burst:     100,
+		},
+	}
+}
+
+// Validate returns an error if the settings are invalid.
+func (c ControllerConfig) Validate() error {
+	if c.ResyncPeriod < 0 {
+		return errors.New("resync period must not be negative")
+	}
+	if c.Workers < 1 {
+		return errors.Errorf("number of workers must be at least 1, got %d", c.Workers)
+	}
+
+	rl := c.RateLimiter
+	if rl.BaseDelay <= 0 || rl.MaxDelay < rl.BaseDelay {
+		return errors.Errorf("work queue delays must satisfy 0 < base delay (%s) <= max delay (%s)", rl.BaseDelay, rl.MaxDelay)
+	}
+	if rl.QPS <= 0 || rl.Burst < 1 {
+		return errors.Errorf("work queue QPS (%v) and burst (%d) must be positive", rl.QPS, rl.Burst)
+	}
+
+	return nil
+}
+
+// NewRateLimitingQueue returns a named work queue using the rate limiter
+// settings.
+func (c ControllerConfig) NewRateLimitingQueue(name string) workqueue.RateLimitingInterface {
+	rl := c.RateLimiter
+	return workqueue.NewNamedRateLimitingQueue(
+		workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(rl.BaseDelay, rl.MaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(rl.QPS), rl.Burst)},
+		),
+		name,
+	)
+}
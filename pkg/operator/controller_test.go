@@ -0,0 +1,65 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestControllerConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(*ControllerConfig)
+		err    bool
+	}{
+		{
+			name:   "defaults",
+			modify: func(*ControllerConfig) {},
+		},
+		{
+			name:   "resyncs disabled",
+			modify: func(c *ControllerConfig) { c.ResyncPeriod = 0 },
+		},
+		{
+			name:   "no worker",
+			modify: func(c *ControllerConfig) { c.Workers = 0 },
+			err:    true,
+		},
+		{
+			name:   "max delay lower than base delay",
+			modify: func(c *ControllerConfig) { c.RateLimiter.MaxDelay = time.Millisecond },
+			err:    true,
+		},
+		{
+			name:   "zero QPS",
+			modify: func(c *ControllerConfig) { c.RateLimiter.QPS = 0 },
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := DefaultControllerConfig()
+			tc.modify(&c)
+
+			err := c.Validate()
+			if tc.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus-operator/prometheus-operator/pkg/listwatch"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ManagedByOperatorSelector selects the objects generated by the operator.
+const ManagedByOperatorSelector = "managed-by=prometheus-operator"
+
+// OrphanCollector deletes the Secrets and ConfigMaps generated by a
+// controller once their owner doesn't exist anymore. Kubernetes normally
+// takes care of it through the owner references but objects can be left
+// behind, for instance when they were created without owner reference or
+// when the owner was deleted with the orphan propagation policy.
+//
+// The owner of an object is identified by the label of its owner kind and,
+// for the objects created before the label was introduced, by its controller
+// reference. Objects without any of them are never deleted.
+type OrphanCollector struct {
+	Logger     log.Logger
+	KubeClient kubernetes.Interface
+	Metrics    *Metrics
+
+	// AllowList and DenyList are the namespaces watched by the controller.
+	AllowList, DenyList map[string]struct{}
+	// NamespaceWatcher restricts AllowList to the namespaces matching the
+	// namespace selector. It may be nil.
+	NamespaceWatcher *NamespaceWatcher
+
+	// Owners are the kinds of custom resources owning the objects.
+	Owners []OrphanOwner
+}
+
+// OrphanOwner describes a kind of custom resource owning generated objects.
+type OrphanOwner struct {
+	// Kind is the kind of the custom resource.
+	Kind string
+	// Label is the label holding the name of the owner.
+	Label string
+	// Exists returns whether the owner exists. It should query the API
+	// server rather than an informer because the informers don't see the
+	// resources filtered out by the instance selectors.
+	Exists func(ctx context.Context, namespace, name string) (bool, error)
+}
+
+// Run performs a collection at every interval until the context is canceled.
+func (gc *OrphanCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := gc.Collect(ctx); err != nil {
+			level.Warn(gc.Logger).Log("msg", "garbage collection of orphaned objects failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect deletes the orphaned Secrets and ConfigMaps.
+func (gc *OrphanCollector) Collect(ctx context.Context) error {
+	for ns := range gc.NamespaceWatcher.Selected(gc.AllowList) {
+		opts := metav1.ListOptions{LabelSelector: ManagedByOperatorSelector}
+		if ns == v1.NamespaceAll {
+			listwatch.DenyTweak(&opts, "metadata.namespace", gc.DenyList)
+		}
+
+		secrets, err := gc.KubeClient.CoreV1().Secrets(ns).List(ctx, opts)
+		if err != nil {
+			return errors.Wrap(err, "failed to list secrets")
+		}
+		for i := range secrets.Items {
+			s := &secrets.Items[i]
+			err := gc.collect(ctx, "secret", s, func(opts metav1.DeleteOptions) error {
+				return gc.KubeClient.CoreV1().Secrets(s.Namespace).Delete(ctx, s.Name, opts)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		cms, err := gc.KubeClient.CoreV1().ConfigMaps(ns).List(ctx, opts)
+		if err != nil {
+			return errors.Wrap(err, "failed to list configmaps")
+		}
+		for i := range cms.Items {
+			cm := &cms.Items[i]
+			err := gc.collect(ctx, "configmap", cm, func(opts metav1.DeleteOptions) error {
+				return gc.KubeClient.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, opts)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (gc *OrphanCollector) collect(ctx context.Context, resource string, obj metav1.Object, del func(metav1.DeleteOptions) error) error {
+	if _, denied := gc.DenyList[obj.GetNamespace()]; denied {
+		return nil
+	}
+
+	kind, owner := gc.owner(obj)
+	if kind == nil {
+		return nil
+	}
+
+	exists, err := kind.Exists(ctx, obj.GetNamespace(), owner)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s %s/%s", kind.Kind, obj.GetNamespace(), owner)
+	}
+	if exists {
+		return nil
+	}
+
+	// The UID precondition ensures that an object recreated in the meantime
+	// isn't deleted.
+	uid := obj.GetUID()
+	err = del(metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return errors.Wrapf(err, "failed to delete %s %s/%s", resource, obj.GetNamespace(), obj.GetName())
+	}
+	if err == nil {
+		level.Info(gc.Logger).Log("msg", "deleted orphaned object", "resource", resource, "namespace", obj.GetNamespace(), "name", obj.GetName(), "kind", kind.Kind, "owner", owner)
+		gc.Metrics.GarbageCollectedCounter(resource).Inc()
+	}
+
+	return nil
+}
+
+// owner returns the kind and the name of the owner of the object or nil if
+// the object isn't owned by any of the Owners resources.
+func (gc *OrphanCollector) owner(obj metav1.Object) (*OrphanOwner, string) {
+	for i := range gc.Owners {
+		if name := obj.GetLabels()[gc.Owners[i].Label]; name != "" {
+			return &gc.Owners[i], name
+		}
+	}
+
+	if ref := metav1.GetControllerOf(obj); ref != nil {
+		for i := range gc.Owners {
+			if ref.Kind == gc.Owners[i].Kind {
+				return &gc.Owners[i], ref.Name
+			}
+		}
+	}
+
+	return nil, ""
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestOrphanCollector(t *testing.T) {
+	boolTrue := true
+	managed := map[string]string{"managed-by": "prometheus-operator"}
+	withOwner := func(name string) map[string]string {
+		return map[string]string{"managed-by": "prometheus-operator", "prometheus-name": name}
+	}
+	withAgentOwner := func(name string) map[string]string {
+		return map[string]string{"managed-by": "prometheus-operator", "prometheus-agent-name": name}
+	}
+	controllerRef := func(kind, name string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &boolTrue}}
+	}
+
+	kclient := fake.NewSimpleClientset(
+		// Owned by an existing Prometheus.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-live", Namespace: "default", Labels: withOwner("live")}},
+		// Owned by a deleted Prometheus.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-gone", Namespace: "default", Labels: withOwner("gone")}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-gone-rulefiles-0", Namespace: "default", Labels: withOwner("gone")}},
+		// Owner identified by the controller reference only.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-legacy", Namespace: "default", Labels: managed, OwnerReferences: controllerRef("Prometheus", "legacy")}},
+		// Owned by an existing and a deleted PrometheusAgent.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prom-agent-live", Namespace: "default", Labels: withAgentOwner("live")}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prom-agent-gone", Namespace: "default", Labels: withAgentOwner("gone")}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prom-agent-legacy", Namespace: "default", Labels: managed, OwnerReferences: controllerRef("PrometheusAgent", "legacy")}},
+		// Owned by another kind of resource.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "alertmanager-gone", Namespace: "default", Labels: managed, OwnerReferences: controllerRef("Alertmanager", "gone")}},
+		// Without owner.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unknown", Namespace: "default", Labels: managed}},
+		// Not generated by the operator.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "default", Labels: map[string]string{"prometheus-name": "gone"}}},
+		// In a denied namespace.
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-gone", Namespace: "denied", Labels: withOwner("gone")}},
+	)
+
+	exists := func(_ context.Context, _, name string) (bool, error) {
+		return name == "live", nil
+	}
+
+	metrics := NewMetrics("test", prometheus.NewRegistry())
+	gc := &OrphanCollector{
+		Logger:     log.NewNopLogger(),
+		KubeClient: kclient,
+		Metrics:    metrics,
+		AllowList:  map[string]struct{}{v1.NamespaceAll: {}},
+		DenyList:   map[string]struct{}{"denied": {}},
+		Owners: []OrphanOwner{
+			{Kind: "Prometheus", Label: "prometheus-name", Exists: exists},
+			{Kind: "PrometheusAgent", Label: "prometheus-agent-name", Exists: exists},
+		},
+	}
+
+	if err := gc.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"default/alertmanager-gone",
+		"default/prom-agent-live",
+		"default/prometheus-live",
+		"default/unknown",
+		"default/user",
+		"denied/prometheus-gone",
+	}
+	if remaining := remainingObjects(t, kclient); !reflect.DeepEqual(remaining, expected) {
+		t.Fatalf("expected %v, got %v", expected, remaining)
+	}
+
+	if n := testutil.ToFloat64(metrics.GarbageCollectedCounter("secret")); n != 4 {
+		t.Fatalf("expected 4 deleted secrets, got %v", n)
+	}
+	if n := testutil.ToFloat64(metrics.GarbageCollectedCounter("configmap")); n != 1 {
+		t.Fatalf("expected 1 deleted configmap, got %v", n)
+	}
+}
+
+func TestOrphanCollectorNamespaceSelector(t *testing.T) {
+	labels := map[string]string{"managed-by": "prometheus-operator", "prometheus-name": "gone"}
+	kclient := fake.NewSimpleClientset(
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-gone", Namespace: "selected", Labels: labels}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-gone", Namespace: "unselected", Labels: labels}},
+	)
+
+	w := &NamespaceWatcher{
+		inf: cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Namespace{}, 0, cache.Indexers{}),
+	}
+	if err := w.inf.GetStore().Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "selected"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	gc := &OrphanCollector{
+		Logger:           log.NewNopLogger(),
+		KubeClient:       kclient,
+		Metrics:          NewMetrics("test", prometheus.NewRegistry()),
+		AllowList:        map[string]struct{}{v1.NamespaceAll: {}},
+		NamespaceWatcher: w,
+		Owners: []OrphanOwner{{
+			Kind:  "Prometheus",
+			Label: "prometheus-name",
+			Exists: func(context.Context, string, string) (bool, error) {
+				return false, nil
+			},
+		}},
+	}
+
+	if err := gc.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"unselected/prometheus-gone"}
+	if remaining := remainingObjects(t, kclient); !reflect.DeepEqual(remaining, expected) {
+		t.Fatalf("expected %v, got %v", expected, remaining)
+	}
+}
+
+// remainingObjects returns the sorted namespace/name keys of the Secrets and
+// ConfigMaps.
+func remainingObjects(t *testing.T, kclient kubernetes.Interface) []string {
+	t.Helper()
+
+	var remaining []string
+	secrets, err := kclient.CoreV1().Secrets(v1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range secrets.Items {
+		remaining = append(remaining, s.Namespace+"/"+s.Name)
+	}
+	cms, err := kclient.CoreV1().ConfigMaps(v1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cm := range cms.Items {
+		remaining = append(remaining, cm.Namespace+"/"+cm.Name)
+	}
+	sort.Strings(remaining)
+
+	return remaining
+}
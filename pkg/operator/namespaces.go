@@ -0,0 +1,191 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus-operator/prometheus-operator/pkg/informers"
+	"github.com/prometheus-operator/prometheus-operator/pkg/listwatch"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceWatcher keeps the namespaces of informers in sync with the
+// namespaces matching a label selector, so that namespaces can be added and
+// removed without restarting the operator.
+//
+// All methods are safe to call on a nil NamespaceWatcher, in which case the
+// informers keep their static namespaces.
+type NamespaceWatcher struct {
+	logger   log.Logger
+	inf      cache.SharedIndexInformer
+	infs     []*informers.ForResource
+	onChange func()
+	// syncCh is notified when a matching namespace is added or removed.
+	syncCh chan struct{}
+}
+
+// NewNamespaceWatcher returns a NamespaceWatcher for the namespaces matching
+// the given label selector. onChange is called after the namespaces of the
+// informers have changed.
+func NewNamespaceWatcher(logger log.Logger, client kubernetes.Interface, selector string, resync time.Duration, onChange func()) (*NamespaceWatcher, error) {
+	if _, err := labels.Parse(selector); err != nil {
+		return nil, errors.Wrap(err, "can not parse namespace selector value")
+	}
+
+	w := &NamespaceWatcher{
+		logger:   logger,
+		onChange: onChange,
+		syncCh:   make(chan struct{}, 1),
+	}
+
+	w.inf = cache.NewSharedIndexInformer(
+		cache.NewFilteredListWatchFromClient(
+			client.CoreV1().RESTClient(),
+			"namespaces",
+			metav1.NamespaceAll,
+			func(options *metav1.ListOptions) {
+				options.LabelSelector = selector
+			},
+		),
+		&v1.Namespace{}, resync, cache.Indexers{},
+	)
+	w.inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.notify() },
+		DeleteFunc: func(interface{}) { w.notify() },
+	})
+
+	return w, nil
+}
+
+// AllowList returns the allowed namespaces to create informers with. When
+// the given namespaces are all the namespaces, the informers start without
+// any namespace and are expected to be registered with Watch.
+func (w *NamespaceWatcher) AllowList(namespaces map[string]struct{}) map[string]struct{} {
+	if w == nil || !listwatch.IsAllNamespaces(namespaces) {
+		return namespaces
+	}
+
+	return map[string]struct{}{}
+}
+
+// Selected returns the namespaces watched by the informers created with
+// AllowList(namespaces): the namespaces currently matching the selector when
+// the given namespaces are all the namespaces, the given namespaces otherwise.
+func (w *NamespaceWatcher) Selected(namespaces map[string]struct{}) map[string]struct{} {
+	if w == nil || !listwatch.IsAllNamespaces(namespaces) {
+		return namespaces
+	}
+
+	selected := map[string]struct{}{}
+	for ns := range w.Namespaces() {
+		selected[ns] = struct{}{}
+	}
+
+	return selected
+}
+
+// Watch registers the informers created with AllowList(namespaces). It is a
+// no-op if the namespaces aren't all the namespaces.
+func (w *NamespaceWatcher) Watch(namespaces map[string]struct{}, infs ...*informers.ForResource) {
+	if w == nil || !listwatch.IsAllNamespaces(namespaces) {
+		return
+	}
+
+	w.infs = append(w.infs, infs...)
+}
+
+// Init waits for the matching namespaces to be listed and sets them to the
+// registered informers. It must be called before the informers are started.
+func (w *NamespaceWatcher) Init(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+
+	go w.inf.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), w.inf.HasSynced) {
+		return errors.New("failed to sync the namespace selector cache")
+	}
+
+	w.sync()
+	return nil
+}
+
+// Run updates the namespaces of the registered informers whenever a
+// matching namespace is added or removed, until the context is canceled.
+func (w *NamespaceWatcher) Run(ctx context.Context) {
+	if w == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.syncCh:
+			if w.sync() && w.onChange != nil {
+				w.onChange()
+			}
+		}
+	}
+}
+
+func (w *NamespaceWatcher) notify() {
+	select {
+	case w.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+// Namespaces returns the namespaces matching the selector.
+func (w *NamespaceWatcher) Namespaces() sets.String {
+	namespaces := sets.NewString()
+	for _, obj := range w.inf.GetStore().List() {
+		namespaces.Insert(obj.(*v1.Namespace).Name)
+	}
+
+	return namespaces
+}
+
+// sync sets the matching namespaces to the registered informers and returns
+// whether any informer changed.
+func (w *NamespaceWatcher) sync() bool {
+	namespaces := w.Namespaces()
+
+	var changed bool
+	for _, inf := range w.infs {
+		ok, err := inf.SetNamespaces(namespaces)
+		if err != nil {
+			level.Warn(w.logger).Log("msg", "failed to update the watched namespaces", "err", err)
+		}
+		changed = changed || ok
+	}
+
+	if changed {
+		level.Info(w.logger).Log("msg", "watched namespaces updated", "namespaces", fmt.Sprint(namespaces.List()))
+	}
+
+	return changed
+}
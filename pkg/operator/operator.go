@@ -15,6 +15,8 @@
 package operator
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +25,18 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
+const (
+	// OperationCreate is the operation of a generated object which didn't
+	// exist.
+	OperationCreate = "create"
+	// OperationUpdate is the operation of a generated object which
+	// changed.
+	OperationUpdate = "update"
+	// OperationUnchanged is the operation of a generated object which was
+	// already up-to-date.
+	OperationUnchanged = "unchanged"
+)
+
 // Metrics represents metrics associated to an operator.
 type Metrics struct {
 	reg prometheus.Registerer
@@ -39,6 +53,15 @@ type Metrics struct {
 	// objects. It is split in the dimensions of Kubernetes objects and
 	// corresponding actions (add, delete, update).
 	triggerByCounter *prometheus.CounterVec
+	// gcDeletedCounter counts the orphaned objects deleted by the garbage
+	// collection, per resource.
+	gcDeletedCounter *prometheus.CounterVec
+	// reconcileDurationHistogram tracks the duration of the reconcile
+	// operations, per result (success or error).
+	reconcileDurationHistogram *prometheus.HistogramVec
+	// generatedObjectCounter counts the reconciliations of the generated
+	// objects, per resource and operation (create, update or unchanged).
+	generatedObjectCounter *prometheus.CounterVec
 }
 
 // NewMetrics initializes operator metrics and registers them with the given registerer.
@@ -80,6 +103,19 @@ func NewMetrics(name string, r prometheus.Registerer) *Metrics {
 			Name: "prometheus_operator_watch_operations_failed_total",
 			Help: "Total number of watch operations that failed",
 		}),
+		gcDeletedCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_operator_garbage_collected_objects_total",
+			Help: "Number of orphaned generated objects deleted by the garbage collection",
+		}, []string{"resource"}),
+		reconcileDurationHistogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "prometheus_operator_reconcile_duration_seconds",
+			Help:    "Duration of reconcile operations by result",
+			Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"result"}),
+		generatedObjectCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_operator_generated_objects_total",
+			Help: "Number of times a generated object was reconciled by resource and operation (create, update or unchanged)",
+		}, []string{"resource", "operation"}),
 	}
 	m.reg.MustRegister(
 		m.reconcileCounter,
@@ -90,6 +126,9 @@ func NewMetrics(name string, r prometheus.Registerer) *Metrics {
 		m.listFailedCounter,
 		m.watchCounter,
 		m.watchFailedCounter,
+		m.gcDeletedCounter,
+		m.reconcileDurationHistogram,
+		m.generatedObjectCounter,
 	)
 	return &m
 }
@@ -114,6 +153,29 @@ func (m *Metrics) TriggerByCounter(triggered_by, action string) prometheus.Count
 	return m.triggerByCounter.WithLabelValues(triggered_by, action)
 }
 
+// GarbageCollectedCounter returns a counter to track the orphaned objects
+// deleted by the garbage collection.
+func (m *Metrics) GarbageCollectedCounter(resource string) prometheus.Counter {
+	return m.gcDeletedCounter.WithLabelValues(resource)
+}
+
+// ObserveReconcile records the duration of a reconcile operation given its
+// error.
+func (m *Metrics) ObserveReconcile(d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.reconcileDurationHistogram.WithLabelValues(result).Observe(d.Seconds())
+}
+
+// GeneratedObjectCounter returns a counter to track the reconciliations of
+// the generated objects of the resource by operation (OperationCreate,
+// OperationUpdate or OperationUnchanged).
+func (m *Metrics) GeneratedObjectCounter(resource, operation string) prometheus.Counter {
+	return m.generatedObjectCounter.WithLabelValues(resource, operation)
+}
+
 // MustRegister registers metrics with the Metrics registerer.
 func (m *Metrics) MustRegister(metrics ...prometheus.Collector) {
 	m.reg.MustRegister(metrics...)
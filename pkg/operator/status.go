@@ -0,0 +1,67 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MakeConditions returns the Available and Reconciled conditions of a
+// workload given its desired and available replicas and the outcome of the
+// last reconciliation. The transition times of the current conditions are
+// preserved when their status doesn't change.
+func MakeConditions(generation int64, desired, availableReplicas int32, reconcileErr error, current []monitoringv1.Condition) []monitoringv1.Condition {
+	available := monitoringv1.Condition{
+		Type:               monitoringv1.Available,
+		Status:             monitoringv1.ConditionTrue,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case availableReplicas == 0 && desired > 0:
+		available.Status = monitoringv1.ConditionFalse
+		available.Reason = "NoPodReady"
+	case availableReplicas < desired:
+		available.Status = monitoringv1.ConditionDegraded
+		available.Reason = "SomePodsNotReady"
+		available.Message = fmt.Sprintf("%d/%d pods are ready", availableReplicas, desired)
+	}
+
+	reconciled := monitoringv1.Condition{
+		Type:               monitoringv1.Reconciled,
+		Status:             monitoringv1.ConditionTrue,
+		ObservedGeneration: generation,
+	}
+	if reconcileErr != nil {
+		reconciled.Status = monitoringv1.ConditionFalse
+		reconciled.Reason = "ReconciliationFailed"
+		reconciled.Message = reconcileErr.Error()
+	}
+
+	now := metav1.Now()
+	conditions := []monitoringv1.Condition{available, reconciled}
+	for i := range conditions {
+		conditions[i].LastTransitionTime = now
+		for _, c := range current {
+			if c.Type == conditions[i].Type && c.Status == conditions[i].Status {
+				conditions[i].LastTransitionTime = c.LastTransitionTime
+			}
+		}
+	}
+
+	return conditions
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMakeConditions(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		desired      int32
+		available    int32
+		reconcileErr error
+
+		expectedAvailable  monitoringv1.ConditionStatus
+		expectedReconciled monitoringv1.ConditionStatus
+	}{
+		{
+			name:               "all replicas available",
+			desired:            3,
+			available:          3,
+			expectedAvailable:  monitoringv1.ConditionTrue,
+			expectedReconciled: monitoringv1.ConditionTrue,
+		},
+		{
+			name:               "some replicas available",
+			desired:            3,
+			available:          1,
+			expectedAvailable:  monitoringv1.ConditionDegraded,
+			expectedReconciled: monitoringv1.ConditionTrue,
+		},
+		{
+			name:               "no replica available",
+			desired:            3,
+			available:          0,
+			expectedAvailable:  monitoringv1.ConditionFalse,
+			expectedReconciled: monitoringv1.ConditionTrue,
+		},
+		{
+			name:               "scaled to zero",
+			desired:            0,
+			available:          0,
+			expectedAvailable:  monitoringv1.ConditionTrue,
+			expectedReconciled: monitoringv1.ConditionTrue,
+		},
+		{
+			name:               "reconciliation failed",
+			desired:            1,
+			available:          1,
+			reconcileErr:       errors.New("boom"),
+			expectedAvailable:  monitoringv1.ConditionTrue,
+			expectedReconciled: monitoringv1.ConditionFalse,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conditions := MakeConditions(2, tc.desired, tc.available, tc.reconcileErr, nil)
+
+			if len(conditions) != 2 {
+				t.Fatalf("expected 2 conditions, got %d", len(conditions))
+			}
+
+			for _, c := range conditions {
+				var expected monitoringv1.ConditionStatus
+				switch c.Type {
+				case monitoringv1.Available:
+					expected = tc.expectedAvailable
+				case monitoringv1.Reconciled:
+					expected = tc.expectedReconciled
+				default:
+					t.Fatalf("unexpected condition type %q", c.Type)
+				}
+
+				if c.Status != expected {
+					t.Fatalf("expected condition %q to be %q, got %q", c.Type, expected, c.Status)
+				}
+				if c.ObservedGeneration != 2 {
+					t.Fatalf("expected observed generation 2, got %d", c.ObservedGeneration)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeConditionsPreservesTransitionTime(t *testing.T) {
+	before := metav1.NewTime(time.Now().Add(-time.Hour))
+	current := []monitoringv1.Condition{
+		{
+			Type:               monitoringv1.Available,
+			Status:             monitoringv1.ConditionTrue,
+			LastTransitionTime: before,
+		},
+		{
+			Type:               monitoringv1.Reconciled,
+			Status:             monitoringv1.ConditionTrue,
+			LastTransitionTime: before,
+		},
+	}
+
+	conditions := MakeConditions(1, 2, 1, nil, current)
+
+	for _, c := range conditions {
+		switch c.Type {
+		case monitoringv1.Available:
+			if c.LastTransitionTime.Equal(&before) {
+				t.Fatalf("expected the transition time of the %q condition to be updated", c.Type)
+			}
+		case monitoringv1.Reconciled:
+			if !c.LastTransitionTime.Equal(&before) {
+				t.Fatalf("expected the transition time of the %q condition to be preserved", c.Type)
+			}
+		}
+	}
+}
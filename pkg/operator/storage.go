@@ -0,0 +1,168 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// VolumeResizer expands the PersistentVolumeClaims of a StatefulSet when the
+// storage requests of its volume claim templates grow. Since the volume
+// claim templates of a StatefulSet are immutable, the StatefulSet is then
+// deleted without deleting its Pods and the next reconciliation recreates it
+// with the new templates.
+type VolumeResizer struct {
+	Logger     log.Logger
+	KubeClient kubernetes.Interface
+	Metrics    *Metrics
+}
+
+// Resize expands the claims of the current StatefulSet to the storage
+// requests of the desired one when the resize policy is Auto. It returns
+// true when the claims were expanded and the current StatefulSet deleted.
+// When a claim can't be expanded (no storage class or a storage class not
+// allowing the expansion), the resize is skipped with a warning so that the
+// reconciliation goes on with the current claims.
+func (r *VolumeResizer) Resize(ctx context.Context, storage *monitoringv1.StorageSpec, current, desired *appsv1.StatefulSet) (bool, error) {
+	if storage == nil || storage.ResizePolicy != monitoringv1.StorageResizePolicyAuto {
+		return false, nil
+	}
+
+	grown := grownVolumeClaimTemplates(current, desired)
+	if len(grown) == 0 {
+		return false, nil
+	}
+
+	pvcClient := r.KubeClient.CoreV1().PersistentVolumeClaims(current.Namespace)
+	list, err := pvcClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, errors.Wrap(err, "listing persistent volume claims failed")
+	}
+
+	// All the storage classes must allow the expansion before any claim is
+	// modified.
+	var claims []v1.PersistentVolumeClaim
+	expandable := map[string]bool{}
+	for _, pvc := range list.Items {
+		template, ok := volumeClaimTemplateName(pvc.Name, current.Name, grown)
+		if !ok {
+			continue
+		}
+
+		size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+		if size.Cmp(grown[template]) >= 0 {
+			continue
+		}
+
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+			r.skipResize(current, fmt.Sprintf("persistent volume claim %q has no storage class", pvc.Name))
+			return false, nil
+		}
+
+		class := *pvc.Spec.StorageClassName
+		if _, found := expandable[class]; !found {
+			sc, err := r.KubeClient.StorageV1().StorageClasses().Get(ctx, class, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return false, errors.Wrapf(err, "retrieving storage class %q failed", class)
+			}
+			expandable[class] = err == nil && sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+		}
+		if !expandable[class] {
+			r.skipResize(current, fmt.Sprintf("storage class %q of persistent volume claim %q doesn't exist or doesn't allow volume expansion", class, pvc.Name))
+			return false, nil
+		}
+
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = grown[template]
+		claims = append(claims, pvc)
+	}
+
+	for i := range claims {
+		level.Info(r.Logger).Log("msg", "expanding persistent volume claim", "namespace", claims[i].Namespace, "pvc", claims[i].Name, "size", claims[i].Spec.Resources.Requests.Storage().String())
+		if _, err := pvcClient.Update(ctx, &claims[i], metav1.UpdateOptions{}); err != nil {
+			return false, errors.Wrapf(err, "expanding persistent volume claim %q failed", claims[i].Name)
+		}
+	}
+
+	level.Info(r.Logger).Log("msg", "deleting StatefulSet without its Pods to update the volume claim templates", "namespace", current.Namespace, "statefulset", current.Name)
+	if r.Metrics != nil {
+		r.Metrics.StsDeleteCreateCounter().Inc()
+	}
+	propagationPolicy := metav1.DeletePropagationOrphan
+	if err := r.KubeClient.AppsV1().StatefulSets(current.Namespace).Delete(ctx, current.Name, metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+		return false, errors.Wrap(err, "failed to delete StatefulSet to update the volume claim templates")
+	}
+
+	return true, nil
+}
+
+// skipResize logs why the claims of the StatefulSet aren't expanded.
+func (r *VolumeResizer) skipResize(sset *appsv1.StatefulSet, reason string) {
+	level.Warn(r.Logger).Log("msg", "skipping the expansion of the persistent volume claims", "reason", reason, "namespace", sset.Namespace, "statefulset", sset.Name)
+}
+
+// grownVolumeClaimTemplates returns the storage requests of the desired
+// volume claim templates which are larger than the current ones, indexed by
+// template name.
+func grownVolumeClaimTemplates(current, desired *appsv1.StatefulSet) map[string]resource.Quantity {
+	currentSizes := map[string]resource.Quantity{}
+	for _, t := range current.Spec.VolumeClaimTemplates {
+		currentSizes[t.Name] = t.Spec.Resources.Requests[v1.ResourceStorage]
+	}
+
+	grown := map[string]resource.Quantity{}
+	for _, t := range desired.Spec.VolumeClaimTemplates {
+		size, found := t.Spec.Resources.Requests[v1.ResourceStorage]
+		if !found {
+			continue
+		}
+		cur, found := currentSizes[t.Name]
+		if !found || size.Cmp(cur) <= 0 {
+			continue
+		}
+		grown[t.Name] = size
+	}
+
+	return grown
+}
+
+// volumeClaimTemplateName returns the template of the given templates from
+// which the claim was created by the StatefulSet. The claims are named
+// <template>-<statefulset>-<ordinal>.
+func volumeClaimTemplateName(pvc, sset string, templates map[string]resource.Quantity) (string, bool) {
+	for t := range templates {
+		prefix := t + "-" + sset + "-"
+		if !strings.HasPrefix(pvc, prefix) {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimPrefix(pvc, prefix)); err == nil {
+			return t, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVolumeResizer(t *testing.T) {
+	makeSSet := func(size string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "prometheus-test", Namespace: "default"},
+			Spec: appsv1.StatefulSetSpec{
+				VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "prometheus-test-db"},
+						Spec: v1.PersistentVolumeClaimSpec{
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	makePVC := func(name, class string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: &class,
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+				},
+			},
+		}
+	}
+	allowExpansion := true
+
+	for _, tc := range []struct {
+		name    string
+		policy  monitoringv1.StorageResizePolicy
+		size    string
+		class   string
+		resized bool
+	}{
+		{
+			name:   "policy None",
+			policy: monitoringv1.StorageResizePolicyNone,
+			size:   "20Gi",
+			class:  "expandable",
+		},
+		{
+			name:   "storage not grown",
+			policy: monitoringv1.StorageResizePolicyAuto,
+			size:   "5Gi",
+			class:  "expandable",
+		},
+		{
+			name:    "storage grown",
+			policy:  monitoringv1.StorageResizePolicyAuto,
+			size:    "20Gi",
+			class:   "expandable",
+			resized: true,
+		},
+		{
+			name:   "storage class not expandable",
+			policy: monitoringv1.StorageResizePolicyAuto,
+			size:   "20Gi",
+			class:  "standard",
+		},
+		{
+			name:   "storage class missing",
+			policy: monitoringv1.StorageResizePolicyAuto,
+			size:   "20Gi",
+			class:  "missing",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			current := makeSSet("10Gi")
+			kclient := fake.NewSimpleClientset(
+				current,
+				makePVC("prometheus-test-db-prometheus-test-0", tc.class),
+				makePVC("other-db-prometheus-test-0", tc.class),
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "expandable"}, AllowVolumeExpansion: &allowExpansion},
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}},
+			)
+
+			r := &VolumeResizer{Logger: log.NewNopLogger(), KubeClient: kclient}
+			resized, err := r.Resize(context.Background(), &monitoringv1.StorageSpec{ResizePolicy: tc.policy}, current, makeSSet(tc.size))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resized != tc.resized {
+				t.Fatalf("expected resized to be %v, got %v", tc.resized, resized)
+			}
+
+			expectedSize := resource.MustParse("10Gi")
+			if tc.resized {
+				expectedSize = resource.MustParse(tc.size)
+			}
+			pvc, err := kclient.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), "prometheus-test-db-prometheus-test-0", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := pvc.Spec.Resources.Requests[v1.ResourceStorage]; size.Cmp(expectedSize) != 0 {
+				t.Fatalf("expected the claim size to be %s, got %s", expectedSize.String(), size.String())
+			}
+
+			other, err := kclient.CoreV1().PersistentVolumeClaims("default").Get(context.Background(), "other-db-prometheus-test-0", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := other.Spec.Resources.Requests[v1.ResourceStorage]; size.Cmp(resource.MustParse("10Gi")) != 0 {
+				t.Fatalf("expected the unrelated claim to be untouched, got %s", size.String())
+			}
+
+			_, err = kclient.AppsV1().StatefulSets("default").Get(context.Background(), "prometheus-test", metav1.GetOptions{})
+			if tc.resized != apierrors.IsNotFound(err) {
+				t.Fatalf("expected the StatefulSet to be deleted: %v, got %v", tc.resized, err)
+			}
+		})
+	}
+}
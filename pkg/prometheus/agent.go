@@ -0,0 +1,423 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+)
+
+func (c *Operator) handlePrometheusAgentAdd(obj interface{}) {
+	key, ok := c.keyFunc(obj)
+	if !ok {
+		return
+	}
+
+	level.Debug(c.logger).Log("msg", "PrometheusAgent added", "key", key)
+	c.metrics.TriggerByCounter(monitoringv1.PrometheusAgentsKind, "add").Inc()
+	c.enqueueAgent(key)
+}
+
+func (c *Operator) handlePrometheusAgentDelete(obj interface{}) {
+	key, ok := c.keyFunc(obj)
+	if !ok {
+		return
+	}
+
+	level.Debug(c.logger).Log("msg", "PrometheusAgent deleted", "key", key)
+	c.metrics.TriggerByCounter(monitoringv1.PrometheusAgentsKind, "delete").Inc()
+	c.enqueueAgent(key)
+}
+
+func (c *Operator) handlePrometheusAgentUpdate(old, cur interface{}) {
+	if old.(*monitoringv1.PrometheusAgent).ResourceVersion == cur.(*monitoringv1.PrometheusAgent).ResourceVersion {
+		return
+	}
+
+	key, ok := c.keyFunc(cur)
+	if !ok {
+		return
+	}
+
+	level.Debug(c.logger).Log("msg", "PrometheusAgent updated", "key", key)
+	c.metrics.TriggerByCounter(monitoringv1.PrometheusAgentsKind, "update").Inc()
+	c.enqueueAgent(key)
+}
+
+// enqueueAgent adds a key to the PrometheusAgent queue. If obj is a key
+// already it gets added directly. Otherwise, the key is extracted via keyFunc.
+func (c *Operator) enqueueAgent(obj interface{}) {
+	if obj == nil {
+		return
+	}
+
+	key, ok := obj.(string)
+	if !ok {
+		key, ok = c.keyFunc(obj)
+		if !ok {
+			return
+		}
+	}
+
+	c.agentQueue.Add(key)
+}
+
+// enqueueAgentsForNamespace enqueues all PrometheusAgent object keys that
+// belong to the given namespace or select objects in the given namespace.
+func (c *Operator) enqueueAgentsForNamespace(store cache.Store, nsName string) {
+	nsObject, exists, err := store.GetByKey(nsName)
+	if err != nil {
+		level.Error(c.logger).Log(
+			"msg", "get namespace to enqueue PrometheusAgent instances failed",
+			"err", err,
+		)
+		return
+	}
+	if !exists {
+		level.Error(c.logger).Log(
+			"msg", fmt.Sprintf("get namespace to enqueue PrometheusAgent instances failed: namespace %q does not exist", nsName),
+		)
+		return
+	}
+	ns := nsObject.(*v1.Namespace)
+
+	objs, err := c.agentInfs.List(labels.Everything())
+	if err != nil {
+		level.Error(c.logger).Log(
+			"msg", "listing all PrometheusAgent instances from cache failed",
+			"err", err,
+		)
+		return
+	}
+
+	for _, obj := range objs {
+		a := obj.(*monitoringv1.PrometheusAgent)
+		if a.Namespace == nsName {
+			c.enqueueAgent(a)
+			continue
+		}
+
+		for name, sel := range map[string]*metav1.LabelSelector{
+			"ServiceMonitorNamespaceSelector": a.Spec.ServiceMonitorNamespaceSelector,
+			"PodMonitorNamespaceSelector":     a.Spec.PodMonitorNamespaceSelector,
+			"ProbeNamespaceSelector":          a.Spec.ProbeNamespaceSelector,
+			"NodeMonitorNamespaceSelector":    a.Spec.NodeMonitorNamespaceSelector,
+		} {
+			selector, err := metav1.LabelSelectorAsSelector(sel)
+			if err != nil {
+				level.Error(c.logger).Log(
+					"msg", fmt.Sprintf("failed to convert %s of %q to selector", name, a.Name),
+					"err", err,
+				)
+				continue
+			}
+
+			if selector.Matches(labels.Set(ns.Labels)) {
+				c.enqueueAgent(a)
+				break
+			}
+		}
+	}
+}
+
+func (c *Operator) agentForStatefulSet(sset interface{}) *monitoringv1.PrometheusAgent {
+	key, ok := c.keyFunc(sset)
+	if !ok {
+		return nil
+	}
+
+	keyParts := strings.Split(key, "/")
+	if !strings.HasPrefix(keyParts[1], agentPrefixedName("")) {
+		return nil
+	}
+	agentKey := keyParts[0] + "/" + strings.TrimPrefix(keyParts[1], agentPrefixedName(""))
+
+	a, err := c.agentInfs.Get(agentKey)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		level.Error(c.logger).Log("msg", "PrometheusAgent lookup failed", "err", err)
+		return nil
+	}
+
+	return a.(*monitoringv1.PrometheusAgent)
+}
+
+// agentWorker runs a worker thread that dequeues PrometheusAgent keys,
+// processes them, and marks them done.
+func (c *Operator) agentWorker(ctx context.Context) {
+	for c.processNextAgentWorkItem(ctx) {
+	}
+}
+
+func (c *Operator) processNextAgentWorkItem(ctx context.Context) bool {
+	key, quit := c.agentQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.agentQueue.Done(key)
+
+	c.metrics.ReconcileCounter().Inc()
+	start := time.Now()
+	err := c.syncAgent(ctx, key.(string))
+	c.metrics.ObserveReconcile(time.Since(start), err)
+	if err == nil {
+		c.agentQueue.Forget(key)
+		return true
+	}
+
+	c.metrics.ReconcileErrorsCounter().Inc()
+	utilruntime.HandleError(errors.Wrap(err, fmt.Sprintf("Sync %q failed", key)))
+	c.agentQueue.AddRateLimited(key)
+
+	return true
+}
+
+func (c *Operator) syncAgent(ctx context.Context, key string) error {
+	aobj, err := c.agentInfs.Get(key)
+	if apierrors.IsNotFound(err) {
+		// Dependent resources are cleaned up by K8s via OwnerReferences
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	a := aobj.(*monitoringv1.PrometheusAgent)
+	a = a.DeepCopy()
+	a.APIVersion = monitoringv1.SchemeGroupVersion.String()
+	a.Kind = monitoringv1.PrometheusAgentsKind
+
+	if a.Spec.Paused {
+		return nil
+	}
+
+	level.Info(c.logger).Log("msg", "sync prometheus agent", "key", key)
+
+	// The configuration generation and the selection of the monitoring
+	// objects are shared with the Prometheus resources.
+	p := agentAsPrometheus(a)
+	assetStore := newAssetStore(c.kclient.CoreV1(), c.kclient.CoreV1())
+
+	if err := c.createOrUpdateAgentConfigurationSecret(ctx, a, p, assetStore); err != nil {
+		return errors.Wrap(err, "creating config failed")
+	}
+
+	if err := c.createOrUpdateTLSAssetSecret(ctx, p, makeAgentTLSAssetSecret(a, c.config), assetStore); err != nil {
+		return errors.Wrap(err, "creating tls asset secret failed")
+	}
+
+	if c.config.Mode == operator.ModeConfigOnly {
+		return nil
+	}
+
+	// Create governing service if it doesn't exist.
+	svcClient := c.kclient.CoreV1().Services(a.Namespace)
+	if err := k8sutil.CreateOrUpdateService(ctx, svcClient, makeAgentStatefulSetService(a, c.config)); err != nil {
+		return errors.Wrap(err, "synchronizing governing service failed")
+	}
+
+	if a.Spec.ManageServiceAccount {
+		saClient := c.kclient.CoreV1().ServiceAccounts(a.Namespace)
+		if err := k8sutil.CreateOrUpdateServiceAccount(ctx, saClient, makeAgentServiceAccount(a, c.config)); err != nil {
+			return errors.Wrap(err, "synchronizing service account failed")
+		}
+	}
+
+	ssetClient := c.kclient.AppsV1().StatefulSets(a.Namespace)
+	obj, err := c.ssetInfs.Get(a.Namespace + "/" + agentPrefixedName(a.Name))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "retrieving statefulset failed")
+	}
+
+	exists := !apierrors.IsNotFound(err)
+
+	spec := appsv1.StatefulSetSpec{}
+	if obj != nil {
+		spec = obj.(*appsv1.StatefulSet).Spec
+	}
+	newSSetInputHash, err := createAgentSSetInputHash(*a, c.config, spec)
+	if err != nil {
+		return err
+	}
+
+	sset, err := makeAgentStatefulSet(*a, &c.config, newSSetInputHash)
+	if err != nil {
+		return errors.Wrap(err, "making statefulset failed")
+	}
+	operator.SanitizeSTS(sset)
+
+	if !exists {
+		level.Debug(c.logger).Log("msg", "creating PrometheusAgent statefulset")
+		if _, err := ssetClient.Create(ctx, sset, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "creating statefulset failed")
+		}
+		c.metrics.GeneratedObjectCounter("statefulset", operator.OperationCreate).Inc()
+		return nil
+	}
+
+	oldSSetInputHash := obj.(*appsv1.StatefulSet).ObjectMeta.Annotations[sSetInputHashName]
+	if newSSetInputHash == oldSSetInputHash {
+		level.Debug(c.logger).Log("msg", "new statefulset generation inputs match current, skipping any actions")
+		c.metrics.GeneratedObjectCounter("statefulset", operator.OperationUnchanged).Inc()
+		return nil
+	}
+
+	level.Debug(c.logger).Log("msg", "updating current PrometheusAgent statefulset")
+	c.metrics.GeneratedObjectCounter("statefulset", operator.OperationUpdate).Inc()
+
+	if resized, err := c.volumeResizer().Resize(ctx, a.Spec.Storage, obj.(*appsv1.StatefulSet), sset); err != nil || resized {
+		return err
+	}
+
+	return c.updateStatefulSet(ctx, a.Namespace, sset)
+}
+
+func (c *Operator) createOrUpdateAgentConfigurationSecret(ctx context.Context, a *monitoringv1.PrometheusAgent, p *monitoringv1.Prometheus, store *assetStore) error {
+	// If no selectors are configured, the user wants to manage the
+	// configuration themselves.
+	if p.Spec.ServiceMonitorSelector == nil && p.Spec.PodMonitorSelector == nil &&
+		p.Spec.ProbeSelector == nil && p.Spec.NodeMonitorSelector == nil {
+		level.Debug(c.logger).Log("msg", "neither ServiceMonitor, PodMonitor, Probe nor NodeMonitor selector specified, leaving configuration unmanaged", "prometheusagent", a.Name, "namespace", a.Namespace)
+
+		s := makeAgentConfigSecret(a, c.config)
+		s.ObjectMeta.Annotations = map[string]string{
+			"empty": "true",
+		}
+
+		return c.createEmptyConfigurationSecret(ctx, a.Namespace, s)
+	}
+
+	smons, err := c.selectServiceMonitors(ctx, p, store)
+	if err != nil {
+		return errors.Wrap(err, "selecting ServiceMonitors failed")
+	}
+
+	pmons, err := c.selectPodMonitors(ctx, p, store)
+	if err != nil {
+		return errors.Wrap(err, "selecting PodMonitors failed")
+	}
+
+	bmons, err := c.selectProbes(ctx, p, store)
+	if err != nil {
+		return errors.Wrap(err, "selecting Probes failed")
+	}
+
+	nmons, err := c.selectNodeMonitors(ctx, p, store)
+	if err != nil {
+		return errors.Wrap(err, "selecting NodeMonitors failed")
+	}
+
+	c.applyNamespaceLimits(smons, pmons, bmons, nmons)
+
+	if err := addPrometheusAssets(ctx, p, store); err != nil {
+		return err
+	}
+
+	secrets, err := c.kclient.CoreV1().Secrets(a.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	additionalScrapeConfigs, err := c.loadAdditionalScrapeConfigsSecret(a.Spec.AdditionalScrapeConfigs, secrets)
+	if err != nil {
+		return errors.Wrap(err, "loading additional scrape configs from Secret failed")
+	}
+
+	conf, err := c.configGenerator.generateAgentConfig(
+		p,
+		smons,
+		pmons,
+		bmons,
+		nmons,
+		store.basicAuthAssets,
+		store.bearerTokenAssets,
+		store.sigv4Assets,
+		store.oauth2Assets,
+		store.authorizationAssets,
+		store.headerAssets,
+		additionalScrapeConfigs,
+	)
+	if err != nil {
+		return errors.Wrap(err, "generating config failed")
+	}
+
+	return c.writeConfigurationSecret(ctx, p, makeAgentConfigSecret(a, c.config), conf)
+}
+
+// agentAsPrometheus converts the PrometheusAgent object into the equivalent
+// Prometheus object for the functions shared by both resources. The object
+// metadata are preserved so that the generated objects are owned by the
+// PrometheusAgent object.
+func agentAsPrometheus(a *monitoringv1.PrometheusAgent) *monitoringv1.Prometheus {
+	return &monitoringv1.Prometheus{
+		TypeMeta:   a.TypeMeta,
+		ObjectMeta: a.ObjectMeta,
+		Spec: monitoringv1.PrometheusSpec{
+			ServiceMonitorSelector:          a.Spec.ServiceMonitorSelector,
+			ServiceMonitorNamespaceSelector: a.Spec.ServiceMonitorNamespaceSelector,
+			PodMonitorSelector:              a.Spec.PodMonitorSelector,
+			PodMonitorNamespaceSelector:     a.Spec.PodMonitorNamespaceSelector,
+			ProbeSelector:                   a.Spec.ProbeSelector,
+			ProbeNamespaceSelector:          a.Spec.ProbeNamespaceSelector,
+			NodeMonitorSelector:             a.Spec.NodeMonitorSelector,
+			NodeMonitorNamespaceSelector:    a.Spec.NodeMonitorNamespaceSelector,
+			Version:                         operator.StringValOrDefault(a.Spec.Version, defaultAgentVersion),
+			Replicas:                        a.Spec.Replicas,
+			ReplicaExternalLabelName:        a.Spec.ReplicaExternalLabelName,
+			PrometheusExternalLabelName:     a.Spec.PrometheusExternalLabelName,
+			ScrapeInterval:                  a.Spec.ScrapeInterval,
+			ScrapeTimeout:                   a.Spec.ScrapeTimeout,
+			ExternalLabels:                  a.Spec.ExternalLabels,
+			Secrets:                         a.Spec.Secrets,
+			ConfigMaps:                      a.Spec.ConfigMaps,
+			RemoteWrite:                     a.Spec.RemoteWrite,
+			AdditionalScrapeConfigs:         a.Spec.AdditionalScrapeConfigs,
+			ScrapeClasses:                   a.Spec.ScrapeClasses,
+			ServiceAccountName:              agentServiceAccountName(a),
+			BoundServiceAccountToken:        a.Spec.BoundServiceAccountToken,
+			APIServerConfig:                 a.Spec.APIServerConfig,
+			ArbitraryFSAccessThroughSMs:     a.Spec.ArbitraryFSAccessThroughSMs,
+			OverrideHonorLabels:             a.Spec.OverrideHonorLabels,
+			OverrideHonorTimestamps:         a.Spec.OverrideHonorTimestamps,
+			IgnoreNamespaceSelectors:        a.Spec.IgnoreNamespaceSelectors,
+			EnforcedNamespaceLabel:          a.Spec.EnforcedNamespaceLabel,
+			EnforcedSampleLimit:             a.Spec.EnforcedSampleLimit,
+			EnforcedTargetLimit:             a.Spec.EnforcedTargetLimit,
+			EnforcedLabelLimit:              a.Spec.EnforcedLabelLimit,
+			EnforcedLabelNameLengthLimit:    a.Spec.EnforcedLabelNameLengthLimit,
+			EnforcedLabelValueLengthLimit:   a.Spec.EnforcedLabelValueLengthLimit,
+			EnforcedKeepDroppedTargets:      a.Spec.EnforcedKeepDroppedTargets,
+		},
+	}
+}
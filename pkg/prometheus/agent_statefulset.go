@@ -0,0 +1,511 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/mitchellh/hashstructure"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+)
+
+const (
+	agentGoverningServiceName = "prometheus-agent-operated"
+	labelPrometheusAgentName  = "prometheus-agent-name"
+)
+
+const defaultAgentVersion = "v2.32.0"
+
+// minAgentVersion is the first Prometheus version supporting the agent mode.
+var minAgentVersion = semver.MustParse("2.32.0")
+
+func makeAgentStatefulSet(a monitoringv1.PrometheusAgent, config *Config, inputHash string) (*appsv1.StatefulSet, error) {
+	// a is passed in by value, not by reference. But a contains references
+	// like to annotation map, that do not get copied on function invocation.
+	// Ensure to prevent side effects before editing a by creating a deep copy.
+	a = *a.DeepCopy()
+
+	promVersion := operator.StringValOrDefault(a.Spec.Version, defaultAgentVersion)
+	version, err := semver.ParseTolerant(promVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse prometheus version")
+	}
+	if version.LT(minAgentVersion) {
+		return nil, errors.Errorf("the agent mode requires Prometheus %s or newer, got %s", minAgentVersion, version)
+	}
+
+	if a.Spec.PortName == "" {
+		a.Spec.PortName = defaultPortName
+	}
+
+	if a.Spec.Replicas == nil {
+		a.Spec.Replicas = &minReplicas
+	}
+	intZero := int32(0)
+	if *a.Spec.Replicas < 0 {
+		a.Spec.Replicas = &intZero
+	}
+
+	spec, err := makeAgentStatefulSetSpec(a, config, promVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "make StatefulSet spec")
+	}
+
+	// do not transfer kubectl annotations to the statefulset so it is not
+	// pruned by kubectl
+	annotations := make(map[string]string)
+	for key, value := range a.ObjectMeta.Annotations {
+		if !strings.HasPrefix(key, "kubectl.kubernetes.io/") {
+			annotations[key] = value
+		}
+	}
+	annotations[sSetInputHashName] = inputHash
+
+	statefulset := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            agentPrefixedName(a.Name),
+			Labels:          config.Labels.Merge(a.ObjectMeta.Labels),
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{agentOwnerReference(&a)},
+		},
+		Spec: *spec,
+	}
+
+	if len(a.Spec.ImagePullSecrets) > 0 {
+		statefulset.Spec.Template.Spec.ImagePullSecrets = a.Spec.ImagePullSecrets
+	}
+
+	addStorageVolume(statefulset, a.Spec.Storage, agentVolumeName(a.Name))
+	statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, a.Spec.Volumes...)
+
+	statefulset, err = k8sutil.PatchStatefulSet(statefulset, a.Spec.StatefulSetPatch)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to apply statefulset patch")
+	}
+
+	return statefulset, nil
+}
+
+func makeAgentStatefulSetSpec(a monitoringv1.PrometheusAgent, c *Config, promVersion string) (*appsv1.StatefulSetSpec, error) {
+	terminationGracePeriod := int64(600)
+
+	image, err := operator.BuildImagePath(operator.DefaultPrometheusBaseImage, promVersion, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if a.Spec.Image != nil && strings.TrimSpace(*a.Spec.Image) != "" {
+		image = *a.Spec.Image
+	}
+
+	promArgs := []string{
+		"--enable-feature=agent",
+		fmt.Sprintf("--config.file=%s", path.Join(confOutDir, configEnvsubstFilename)),
+		fmt.Sprintf("--storage.agent.path=%s", storageDir),
+		"--web.enable-lifecycle",
+		"--web.route-prefix=/",
+	}
+
+	if a.Spec.LogLevel != "" && a.Spec.LogLevel != "info" {
+		promArgs = append(promArgs, fmt.Sprintf("--log.level=%s", a.Spec.LogLevel))
+	}
+	if a.Spec.LogFormat != "" && a.Spec.LogFormat != "logfmt" {
+		promArgs = append(promArgs, fmt.Sprintf("--log.format=%s", a.Spec.LogFormat))
+	}
+
+	var ports []v1.ContainerPort
+	if a.Spec.ListenLocal {
+		promArgs = append(promArgs, "--web.listen-address=127.0.0.1:9090")
+	} else {
+		ports = []v1.ContainerPort{
+			{
+				Name:          a.Spec.PortName,
+				ContainerPort: 9090,
+				Protocol:      v1.ProtocolTCP,
+			},
+		}
+	}
+
+	localReloadURL := &url.URL{
+		Scheme: "http",
+		Host:   c.LocalHost + ":9090",
+		Path:   "/-/reload",
+	}
+
+	volumes := []v1.Volume{
+		{
+			Name: "config",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: agentConfigSecretName(a.Name),
+				},
+			},
+		},
+		{
+			Name: "tls-assets",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: agentTLSAssetsSecretName(a.Name),
+				},
+			},
+		},
+		{
+			Name: "config-out",
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+
+	volName := agentVolumeName(a.Name)
+	if a.Spec.Storage != nil && a.Spec.Storage.VolumeClaimTemplate.Name != "" {
+		volName = a.Spec.Storage.VolumeClaimTemplate.Name
+	}
+
+	promVolumeMounts := []v1.VolumeMount{
+		{
+			Name:      "config-out",
+			ReadOnly:  true,
+			MountPath: confOutDir,
+		},
+		{
+			Name:      "tls-assets",
+			ReadOnly:  true,
+			MountPath: tlsAssetsDir,
+		},
+		{
+			Name:      volName,
+			MountPath: storageDir,
+			SubPath:   subPathForStorage(a.Spec.Storage),
+		},
+	}
+	promVolumeMounts = append(promVolumeMounts, a.Spec.VolumeMounts...)
+
+	for _, s := range a.Spec.Secrets {
+		volumes = append(volumes, v1.Volume{
+			Name: k8sutil.SanitizeVolumeName("secret-" + s),
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: s,
+				},
+			},
+		})
+		promVolumeMounts = append(promVolumeMounts, v1.VolumeMount{
+			Name:      k8sutil.SanitizeVolumeName("secret-" + s),
+			ReadOnly:  true,
+			MountPath: secretsDir + s,
+		})
+	}
+
+	for _, cm := range a.Spec.ConfigMaps {
+		volumes = append(volumes, v1.Volume{
+			Name: k8sutil.SanitizeVolumeName("configmap-" + cm),
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: cm,
+					},
+				},
+			},
+		})
+		promVolumeMounts = append(promVolumeMounts, v1.VolumeMount{
+			Name:      k8sutil.SanitizeVolumeName("configmap-" + cm),
+			ReadOnly:  true,
+			MountPath: configmapsDir + cm,
+		})
+	}
+
+	if a.Spec.BoundServiceAccountToken != nil {
+		vol, mount := makeBoundTokenVolume(a.Spec.BoundServiceAccountToken)
+		volumes = append(volumes, vol)
+		promVolumeMounts = append(promVolumeMounts, mount)
+	}
+
+	probeHandler := func(probePath string) v1.Handler {
+		if a.Spec.ListenLocal {
+			localPath := fmt.Sprintf("http://localhost:9090%s", probePath)
+			return v1.Handler{
+				Exec: &v1.ExecAction{
+					Command: []string{
+						"sh",
+						"-c",
+						fmt.Sprintf(localProbe, localPath, localPath),
+					},
+				},
+			}
+		}
+
+		return v1.Handler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: probePath,
+				Port: intstr.FromString(a.Spec.PortName),
+			},
+		}
+	}
+
+	podAnnotations := map[string]string{}
+	podLabels := map[string]string{}
+	podSelectorLabels := map[string]string{
+		"app":              "prometheus-agent",
+		"prometheus-agent": a.Name,
+	}
+	if a.Spec.PodMetadata != nil {
+		for k, v := range a.Spec.PodMetadata.Labels {
+			podLabels[k] = v
+		}
+		for k, v := range a.Spec.PodMetadata.Annotations {
+			podAnnotations[k] = v
+		}
+	}
+	for k, v := range podSelectorLabels {
+		podLabels[k] = v
+	}
+
+	operatorContainers := []v1.Container{
+		{
+			Name:         "prometheus",
+			Image:        image,
+			Ports:        ports,
+			Args:         promArgs,
+			VolumeMounts: promVolumeMounts,
+			LivenessProbe: &v1.Probe{
+				Handler:          probeHandler("/-/healthy"),
+				PeriodSeconds:    5,
+				TimeoutSeconds:   probeTimeoutSeconds,
+				FailureThreshold: 6,
+			},
+			ReadinessProbe: &v1.Probe{
+				Handler:          probeHandler("/-/ready"),
+				PeriodSeconds:    5,
+				TimeoutSeconds:   probeTimeoutSeconds,
+				FailureThreshold: 120, // Allow up to 10m on startup for the WAL replay.
+			},
+			Resources:                a.Spec.Resources,
+			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
+		}, {
+			Name:                     "prometheus-config-reloader",
+			Image:                    c.PrometheusConfigReloaderImage,
+			SecurityContext:          a.Spec.ConfigReloaderSecurityContext,
+			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
+			Env: []v1.EnvVar{
+				{
+					Name: "POD_NAME",
+					ValueFrom: &v1.EnvVarSource{
+						FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
+					},
+				},
+			},
+			Command: []string{"/bin/prometheus-config-reloader"},
+			Args: []string{
+				fmt.Sprintf("--log-format=%s", c.LogFormat),
+				fmt.Sprintf("--reload-url=%s", localReloadURL),
+				fmt.Sprintf("--config-file=%s", path.Join(confDir, configFilename)),
+				fmt.Sprintf("--config-envsubst-file=%s", path.Join(confOutDir, configEnvsubstFilename)),
+			},
+			VolumeMounts: []v1.VolumeMount{
+				{
+					Name:      "config",
+					MountPath: confDir,
+				},
+				{
+					Name:      "config-out",
+					MountPath: confOutDir,
+				},
+			},
+			Resources: configReloaderResources(c),
+		},
+	}
+
+	containers, err := k8sutil.MergePatchContainers(operatorContainers, a.Spec.Containers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to merge containers spec")
+	}
+
+	return &appsv1.StatefulSetSpec{
+		ServiceName:         agentGoverningServiceName,
+		Replicas:            a.Spec.Replicas,
+		PodManagementPolicy: appsv1.ParallelPodManagement,
+		UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+			Type: appsv1.RollingUpdateStatefulSetStrategyType,
+		},
+		Selector: &metav1.LabelSelector{
+			MatchLabels: c.Labels.Merge(podSelectorLabels),
+		},
+		Template: v1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      c.Labels.Merge(podLabels),
+				Annotations: podAnnotations,
+			},
+			Spec: v1.PodSpec{
+				Containers:                    containers,
+				InitContainers:                a.Spec.InitContainers,
+				SecurityContext:               a.Spec.SecurityContext,
+				ServiceAccountName:            agentServiceAccountName(&a),
+				NodeSelector:                  a.Spec.NodeSelector,
+				PriorityClassName:             a.Spec.PriorityClassName,
+				HostAliases:                   a.Spec.HostAliases,
+				TerminationGracePeriodSeconds: &terminationGracePeriod,
+				Volumes:                       volumes,
+				Tolerations:                   a.Spec.Tolerations,
+				Affinity:                      a.Spec.Affinity,
+			},
+		},
+	}, nil
+}
+
+func makeAgentStatefulSetService(a *monitoringv1.PrometheusAgent, config Config) *v1.Service {
+	portName := a.Spec.PortName
+	if portName == "" {
+		portName = defaultPortName
+	}
+
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: agentGoverningServiceName,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Name:       a.GetName(),
+					Kind:       a.Kind,
+					APIVersion: a.APIVersion,
+					UID:        a.GetUID(),
+				},
+			},
+			Labels: config.Labels.Merge(map[string]string{
+				"operated-prometheus-agent": "true",
+			}),
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: "None",
+			Ports: []v1.ServicePort{
+				{
+					Name:       portName,
+					Port:       9090,
+					TargetPort: intstr.FromString(portName),
+				},
+			},
+			Selector: map[string]string{
+				"app": "prometheus-agent",
+			},
+		},
+	}
+}
+
+// agentServiceAccountName returns the name of the ServiceAccount used to run
+// the Prometheus agent Pods.
+func agentServiceAccountName(a *monitoringv1.PrometheusAgent) string {
+	if a.Spec.ManageServiceAccount && a.Spec.ServiceAccountName == "" {
+		return agentPrefixedName(a.Name)
+	}
+	return a.Spec.ServiceAccountName
+}
+
+func makeAgentServiceAccount(a *monitoringv1.PrometheusAgent, config Config) *v1.ServiceAccount {
+	return &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            agentServiceAccountName(a),
+			Labels:          config.Labels.Merge(a.Spec.ServiceAccountLabels),
+			Annotations:     a.Spec.ServiceAccountAnnotations,
+			OwnerReferences: []metav1.OwnerReference{agentOwnerReference(a)},
+		},
+	}
+}
+
+func makeAgentConfigSecret(a *monitoringv1.PrometheusAgent, config Config) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            agentConfigSecretName(a.Name),
+			Labels:          config.Labels.Merge(agentManagedLabels(a)),
+			OwnerReferences: []metav1.OwnerReference{agentOwnerReference(a)},
+		},
+		Data: map[string][]byte{
+			configFilename: {},
+		},
+	}
+}
+
+func makeAgentTLSAssetSecret(a *monitoringv1.PrometheusAgent, config Config) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            agentTLSAssetsSecretName(a.Name),
+			Labels:          config.Labels.Merge(agentManagedLabels(a)),
+			OwnerReferences: []metav1.OwnerReference{agentOwnerReference(a)},
+		},
+		Data: map[string][]byte{},
+	}
+}
+
+// agentManagedLabels returns the labels of the objects generated for the
+// PrometheusAgent object.
+func agentManagedLabels(a *monitoringv1.PrometheusAgent) map[string]string {
+	labels := map[string]string{labelPrometheusAgentName: a.Name}
+	for k, v := range managedByOperatorLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+func agentOwnerReference(a *monitoringv1.PrometheusAgent) metav1.OwnerReference {
+	boolTrue := true
+	return metav1.OwnerReference{
+		APIVersion:         a.APIVersion,
+		BlockOwnerDeletion: &boolTrue,
+		Controller:         &boolTrue,
+		Kind:               a.Kind,
+		Name:               a.Name,
+		UID:                a.UID,
+	}
+}
+
+func createAgentSSetInputHash(a monitoringv1.PrometheusAgent, c Config, ss interface{}) (string, error) {
+	hash, err := hashstructure.Hash(struct {
+		A monitoringv1.PrometheusAgent
+		C Config
+		S interface{}
+	}{a, c, ss},
+		nil,
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to calculate combined hash of PrometheusAgent StatefulSet, PrometheusAgent CRD and config")
+	}
+
+	return fmt.Sprintf("%d", hash), nil
+}
+
+func agentConfigSecretName(name string) string {
+	return agentPrefixedName(name)
+}
+
+func agentTLSAssetsSecretName(name string) string {
+	return fmt.Sprintf("%s-tls-assets", agentPrefixedName(name))
+}
+
+func agentVolumeName(name string) string {
+	return fmt.Sprintf("%s-wal", agentPrefixedName(name))
+}
+
+func agentPrefixedName(name string) string {
+	return fmt.Sprintf("prom-agent-%s", name)
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAgentStatefulSet(t *testing.T) {
+	sset, err := makeAgentStatefulSet(monitoringv1.PrometheusAgent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+	}, defaultTestConfig, "")
+	require.NoError(t, err)
+
+	require.Equal(t, "prom-agent-test", sset.Name)
+	require.Equal(t, agentGoverningServiceName, sset.Spec.ServiceName)
+	require.Equal(t, "quay.io/prometheus/prometheus:"+defaultAgentVersion, sset.Spec.Template.Spec.Containers[0].Image)
+	require.Contains(t, sset.Spec.Template.Spec.Containers[0].Args, "--enable-feature=agent")
+	require.Contains(t, sset.Spec.Template.Spec.Containers[0].Args, "--storage.agent.path=/prometheus")
+	require.Equal(t, "prometheus-agent", sset.Spec.Selector.MatchLabels["app"])
+	require.Equal(t, "test", sset.Spec.Selector.MatchLabels["prometheus-agent"])
+}
+
+func TestAgentStatefulSetUnsupportedVersion(t *testing.T) {
+	_, err := makeAgentStatefulSet(monitoringv1.PrometheusAgent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.PrometheusAgentSpec{
+			Version: "v2.31.0",
+		},
+	}, defaultTestConfig, "")
+	require.Error(t, err)
+}
+
+func TestAgentStatefulSetPatch(t *testing.T) {
+	sset, err := makeAgentStatefulSet(monitoringv1.PrometheusAgent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.PrometheusAgentSpec{
+			StatefulSetPatch: &runtime.RawExtension{
+				Raw: []byte(`{"spec":{"revisionHistoryLimit":3}}`),
+			},
+		},
+	}, defaultTestConfig, "")
+	require.NoError(t, err)
+
+	require.NotNil(t, sset.Spec.RevisionHistoryLimit)
+	require.Equal(t, int32(3), *sset.Spec.RevisionHistoryLimit)
+	require.Equal(t, "prometheus-agent", sset.Spec.Selector.MatchLabels["app"])
+}
+
+func TestAgentServiceAccountName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     monitoringv1.PrometheusAgentSpec
+		expected string
+	}{
+		{
+			name:     "not managed",
+			expected: "",
+		},
+		{
+			name: "managed",
+			spec: monitoringv1.PrometheusAgentSpec{
+				ManageServiceAccount: true,
+			},
+			expected: "prom-agent-test",
+		},
+		{
+			name: "managed with name",
+			spec: monitoringv1.PrometheusAgentSpec{
+				ManageServiceAccount: true,
+				ServiceAccountName:   "custom",
+			},
+			expected: "custom",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeAgentStatefulSet(monitoringv1.PrometheusAgent{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: tc.spec,
+			}, defaultTestConfig, "")
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, sset.Spec.Template.Spec.ServiceAccountName)
+		})
+	}
+}
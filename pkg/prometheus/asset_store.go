@@ -27,6 +27,10 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
+// certManagerCAKey is the key of the CA certificate in Secrets managed by
+// cert-manager.
+const certManagerCAKey = "ca.crt"
+
 // tlsAssetKey is a key for a TLS asset.
 type tlsAssetKey struct {
 	from string
@@ -68,6 +72,24 @@ func (k tlsAssetKey) String() string {
 	return fmt.Sprintf("%s_%s_%s_%s", k.from, k.ns, k.name, k.key)
 }
 
+// certManagerSelectors returns the selectors for the CA, certificate and key
+// stored in the Secret managed by a cert-manager Certificate. The CA selector
+// is nil if the CA should be ignored.
+func certManagerSelectors(ref *monitoringv1.CertManagerReference) (ca, cert, key *v1.SecretKeySelector) {
+	newSelector := func(key string) *v1.SecretKeySelector {
+		return &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: ref.SecretName},
+			Key:                  key,
+		}
+	}
+
+	if !ref.IgnoreCA {
+		ca = newSelector(certManagerCAKey)
+	}
+
+	return ca, newSelector(v1.TLSCertKey), newSelector(v1.TLSPrivateKeyKey)
+}
+
 // assetStore is a store that fetches and caches TLS materials, bearer tokens
 // and auth credentials from configmaps and secrets.
 // Data can be referenced directly from a Prometheus object or indirectly (for
@@ -80,20 +102,28 @@ type assetStore struct {
 	sClient  corev1client.SecretsGetter
 	objStore cache.Store
 
-	tlsAssets         map[tlsAssetKey]TLSAsset
-	bearerTokenAssets map[string]BearerToken
-	basicAuthAssets   map[string]BasicAuthCredentials
+	tlsAssets           map[tlsAssetKey]TLSAsset
+	bearerTokenAssets   map[string]BearerToken
+	basicAuthAssets     map[string]BasicAuthCredentials
+	sigv4Assets         map[string]SigV4Credentials
+	oauth2Assets        map[string]OAuth2Credentials
+	headerAssets        map[string]map[string]string
+	authorizationAssets map[string]string
 }
 
 // newAssetStore returns an empty assetStore.
 func newAssetStore(cmClient corev1client.ConfigMapsGetter, sClient corev1client.SecretsGetter) *assetStore {
 	return &assetStore{
-		cmClient:          cmClient,
-		sClient:           sClient,
-		tlsAssets:         make(map[tlsAssetKey]TLSAsset),
-		bearerTokenAssets: make(map[string]BearerToken),
-		basicAuthAssets:   make(map[string]BasicAuthCredentials),
-		objStore:          cache.NewStore(assetKeyFunc),
+		cmClient:            cmClient,
+		sClient:             sClient,
+		tlsAssets:           make(map[tlsAssetKey]TLSAsset),
+		bearerTokenAssets:   make(map[string]BearerToken),
+		basicAuthAssets:     make(map[string]BasicAuthCredentials),
+		sigv4Assets:         make(map[string]SigV4Credentials),
+		oauth2Assets:        make(map[string]OAuth2Credentials),
+		headerAssets:        make(map[string]map[string]string),
+		authorizationAssets: make(map[string]string),
+		objStore:            cache.NewStore(assetKeyFunc),
 	}
 }
 
@@ -163,6 +193,21 @@ func (a *assetStore) addTLSConfig(ctx context.Context, ns string, tlsConfig *mon
 		a.tlsAssets[tlsAssetKeyFromSelector(ns, monitoringv1.SecretOrConfigMap{Secret: tlsConfig.KeySecret})] = TLSAsset(key)
 	}
 
+	if tlsConfig.CertManagerRef != nil {
+		ca, cert, key := certManagerSelectors(tlsConfig.CertManagerRef)
+		for _, sel := range []*v1.SecretKeySelector{ca, cert, key} {
+			if sel == nil {
+				continue
+			}
+
+			asset, err := a.getSecretKey(ctx, ns, *sel)
+			if err != nil {
+				return errors.Wrap(err, "failed to get cert-manager certificate")
+			}
+			a.tlsAssets[tlsAssetKeyFromSecretSelector(ns, sel)] = TLSAsset(asset)
+		}
+	}
+
 	return nil
 }
 
@@ -190,6 +235,110 @@ func (a *assetStore) addBasicAuth(ctx context.Context, ns string, ba *monitoring
 	return nil
 }
 
+// addSigV4 processes the given *Sigv4 and adds the referenced credentials to the store.
+func (a *assetStore) addSigV4(ctx context.Context, ns string, sigv4 *monitoringv1.Sigv4, key string) error {
+	if sigv4 == nil || (sigv4.AccessKey == nil && sigv4.SecretKey == nil) {
+		return nil
+	}
+
+	if sigv4.AccessKey == nil || sigv4.SecretKey == nil {
+		return errors.New("sigv4 access key and secret key must be set together")
+	}
+
+	accessKey, err := a.getSecretKey(ctx, ns, *sigv4.AccessKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to get sigv4 access key")
+	}
+
+	secretKey, err := a.getSecretKey(ctx, ns, *sigv4.SecretKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to get sigv4 secret key")
+	}
+
+	a.sigv4Assets[key] = SigV4Credentials{
+		accessKeyID: accessKey,
+		secretKeyID: secretKey,
+	}
+
+	return nil
+}
+
+// addOAuth2 processes the given *OAuth2 and adds the referenced credentials to the store.
+func (a *assetStore) addOAuth2(ctx context.Context, ns string, oauth2 *monitoringv1.OAuth2, key string) error {
+	if oauth2 == nil {
+		return nil
+	}
+
+	if err := oauth2.Validate(); err != nil {
+		return err
+	}
+
+	var (
+		clientID string
+		err      error
+	)
+	if oauth2.ClientID.Secret != nil {
+		clientID, err = a.getSecretKey(ctx, ns, *oauth2.ClientID.Secret)
+	} else {
+		clientID, err = a.getConfigMapKey(ctx, ns, *oauth2.ClientID.ConfigMap)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get oauth2 client id")
+	}
+
+	clientSecret, err := a.getSecretKey(ctx, ns, oauth2.ClientSecret)
+	if err != nil {
+		return errors.Wrap(err, "failed to get oauth2 client secret")
+	}
+
+	a.oauth2Assets[key] = OAuth2Credentials{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+	}
+
+	return nil
+}
+
+// addHeaders processes the given header selectors and adds the referenced values to the store.
+func (a *assetStore) addHeaders(ctx context.Context, ns string, headers map[string]v1.SecretKeySelector, key string) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	values := make(map[string]string, len(headers))
+	for name, sel := range headers {
+		v, err := a.getSecretKey(ctx, ns, sel)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get value of header %q", name)
+		}
+		values[name] = v
+	}
+
+	a.headerAssets[key] = values
+
+	return nil
+}
+
+// addAuthorization processes the given *Authorization and adds the referenced credentials to the store.
+func (a *assetStore) addAuthorization(ctx context.Context, ns string, auth *monitoringv1.Authorization, key string) error {
+	if auth == nil {
+		return nil
+	}
+
+	if err := auth.Validate(); err != nil {
+		return err
+	}
+
+	credentials, err := a.getSecretKey(ctx, ns, *auth.Credentials)
+	if err != nil {
+		return errors.Wrap(err, "failed to get authorization credentials")
+	}
+
+	a.authorizationAssets[key] = credentials
+
+	return nil
+}
+
 // addTLSConfig processes the given SecretKeySelector and adds the referenced data to the store.
 func (a *assetStore) addBearerToken(ctx context.Context, ns string, sel v1.SecretKeySelector, key string) error {
 	if sel.Name == "" {
@@ -250,6 +250,91 @@ func TestAddBasicAuth(t *testing.T) {
 	}
 }
 
+func TestAddAuthorization(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"key1": []byte("val1"),
+			},
+		},
+	)
+
+	for _, tc := range []struct {
+		name string
+		auth *monitoringv1.Authorization
+
+		err      bool
+		expected string
+	}{
+		{
+			name: "bearer credentials",
+			auth: &monitoringv1.Authorization{
+				Credentials: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+					Key:                  "key1",
+				},
+			},
+			expected: "val1",
+		},
+		{
+			name: "wrong key",
+			auth: &monitoringv1.Authorization{
+				Credentials: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+					Key:                  "key2",
+				},
+			},
+			err: true,
+		},
+		{
+			name: "missing credentials",
+			auth: &monitoringv1.Authorization{Type: "Bearer"},
+			err:  true,
+		},
+		{
+			name: "basic type",
+			auth: &monitoringv1.Authorization{
+				Type: "Basic",
+				Credentials: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+					Key:                  "key1",
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newAssetStore(c.CoreV1(), c.CoreV1())
+
+			err := store.addAuthorization(context.Background(), "ns1", tc.auth, "authorization")
+
+			if tc.err {
+				if err == nil {
+					t.Fatal("expecting error, got no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+
+			s, found := store.authorizationAssets["authorization"]
+			if !found {
+				t.Fatal("expecting to find the credentials but got nothing")
+			}
+
+			if s != tc.expected {
+				t.Fatalf("expecting %q, got %q", tc.expected, s)
+			}
+		})
+	}
+}
+
 func TestAddTLSConfig(t *testing.T) {
 	c := fake.NewSimpleClientset(
 		&v1.ConfigMap{
@@ -610,3 +695,394 @@ func TestAddTLSConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestAddTLSConfigCertManagerRef(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "scrape-tls",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"ca.crt":  []byte("ca"),
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "scrape-tls-no-ca",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+		},
+	)
+
+	for _, tc := range []struct {
+		ns  string
+		ref *monitoringv1.CertManagerReference
+
+		err      bool
+		expected map[string]string
+	}{
+		{
+			ns:  "ns1",
+			ref: &monitoringv1.CertManagerReference{SecretName: "scrape-tls"},
+
+			expected: map[string]string{
+				"ca.crt":  "ca",
+				"tls.crt": "cert",
+				"tls.key": "key",
+			},
+		},
+		{
+			ns:  "ns1",
+			ref: &monitoringv1.CertManagerReference{SecretName: "scrape-tls-no-ca", IgnoreCA: true},
+
+			expected: map[string]string{
+				"tls.crt": "cert",
+				"tls.key": "key",
+			},
+		},
+		{
+			// Missing CA.
+			ns:  "ns1",
+			ref: &monitoringv1.CertManagerReference{SecretName: "scrape-tls-no-ca"},
+
+			err: true,
+		},
+		{
+			// Wrong namespace.
+			ns:  "ns2",
+			ref: &monitoringv1.CertManagerReference{SecretName: "scrape-tls"},
+
+			err: true,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			store := newAssetStore(c.CoreV1(), c.CoreV1())
+
+			err := store.addTLSConfig(context.Background(), tc.ns, &monitoringv1.TLSConfig{CertManagerRef: tc.ref})
+
+			if tc.err {
+				if err == nil {
+					t.Fatal("expecting error, got no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+
+			if len(store.tlsAssets) != len(tc.expected) {
+				t.Fatalf("expecting %d TLS assets, got %d", len(tc.expected), len(store.tlsAssets))
+			}
+
+			for k, v := range tc.expected {
+				key := tlsAssetKeyFromSecretSelector(tc.ns, &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: tc.ref.SecretName},
+					Key:                  k,
+				})
+
+				asset, found := store.tlsAssets[key]
+				if !found {
+					t.Fatalf("expecting to find key %q but got nothing", key)
+				}
+				if string(asset) != v {
+					t.Fatalf("expecting %q for key %q, got %q", v, key, asset)
+				}
+			}
+		})
+	}
+}
+
+func TestAddSigV4(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"key1": []byte("val1"),
+				"key2": []byte("val2"),
+			},
+		},
+	)
+
+	selector := func(key string) *v1.SecretKeySelector {
+		return &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{
+				Name: "secret",
+			},
+			Key: key,
+		}
+	}
+
+	for i, tc := range []struct {
+		ns    string
+		sigv4 *monitoringv1.Sigv4
+
+		err               bool
+		expectedAccessKey string
+		expectedSecretKey string
+	}{
+		{
+			ns: "ns1",
+			sigv4: &monitoringv1.Sigv4{
+				AccessKey: selector("key1"),
+				SecretKey: selector("key2"),
+			},
+
+			expectedAccessKey: "val1",
+			expectedSecretKey: "val2",
+		},
+		// No credentials.
+		{
+			ns: "ns1",
+			sigv4: &monitoringv1.Sigv4{
+				Region: "us-east-1",
+			},
+		},
+		// Wrong namespace.
+		{
+			ns: "ns2",
+			sigv4: &monitoringv1.Sigv4{
+				AccessKey: selector("key1"),
+				SecretKey: selector("key2"),
+			},
+
+			err: true,
+		},
+		// Wrong key for access key selector.
+		{
+			ns: "ns1",
+			sigv4: &monitoringv1.Sigv4{
+				AccessKey: selector("key3"),
+				SecretKey: selector("key2"),
+			},
+
+			err: true,
+		},
+		// Missing secret key selector.
+		{
+			ns: "ns1",
+			sigv4: &monitoringv1.Sigv4{
+				AccessKey: selector("key1"),
+			},
+
+			err: true,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			store := newAssetStore(c.CoreV1(), c.CoreV1())
+
+			key := fmt.Sprintf("remoteWrite/%d", i)
+			err := store.addSigV4(context.Background(), tc.ns, tc.sigv4, key)
+
+			if tc.err {
+				if err == nil {
+					t.Fatal("expecting error, got no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+
+			s, found := store.sigv4Assets[key]
+
+			if tc.sigv4.AccessKey == nil {
+				if found {
+					t.Fatalf("expecting no credentials for key %q, got %v", key, s)
+				}
+				return
+			}
+
+			if !found {
+				t.Fatalf("expecting to find key %q but got nothing", key)
+			}
+
+			if s.accessKeyID != tc.expectedAccessKey {
+				t.Fatalf("expecting access key %q, got %q", tc.expectedAccessKey, s.accessKeyID)
+			}
+			if s.secretKeyID != tc.expectedSecretKey {
+				t.Fatalf("expecting secret key %q, got %q", tc.expectedSecretKey, s.secretKeyID)
+			}
+		})
+	}
+}
+
+func TestAddOAuth2(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"client-id":     []byte("secret-client-id"),
+				"client-secret": []byte("client-secret"),
+			},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cm",
+				Namespace: "ns1",
+			},
+			Data: map[string]string{
+				"client-id": "cm-client-id",
+			},
+		},
+	)
+
+	secretSelector := func(key string) v1.SecretKeySelector {
+		return v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+			Key:                  key,
+		}
+	}
+	clientIDSelector := secretSelector("client-id")
+
+	for _, tc := range []struct {
+		name   string
+		ns     string
+		oauth2 *monitoringv1.OAuth2
+
+		err                  bool
+		expectedClientID     string
+		expectedClientSecret string
+	}{
+		{
+			name: "client id from secret",
+			ns:   "ns1",
+			oauth2: &monitoringv1.OAuth2{
+				ClientID: monitoringv1.SecretOrConfigMap{
+					Secret: &clientIDSelector,
+				},
+				ClientSecret: secretSelector("client-secret"),
+				TokenURL:     "http://example.com",
+			},
+
+			expectedClientID:     "secret-client-id",
+			expectedClientSecret: "client-secret",
+		},
+		{
+			name: "client id from configmap",
+			ns:   "ns1",
+			oauth2: &monitoringv1.OAuth2{
+				ClientID: monitoringv1.SecretOrConfigMap{
+					ConfigMap: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "cm"},
+						Key:                  "client-id",
+					},
+				},
+				ClientSecret: secretSelector("client-secret"),
+				TokenURL:     "http://example.com",
+			},
+
+			expectedClientID:     "cm-client-id",
+			expectedClientSecret: "client-secret",
+		},
+		{
+			name: "wrong namespace",
+			ns:   "ns2",
+			oauth2: &monitoringv1.OAuth2{
+				ClientID: monitoringv1.SecretOrConfigMap{
+					Secret: &clientIDSelector,
+				},
+				ClientSecret: secretSelector("client-secret"),
+				TokenURL:     "http://example.com",
+			},
+
+			err: true,
+		},
+		{
+			name: "missing token url",
+			ns:   "ns1",
+			oauth2: &monitoringv1.OAuth2{
+				ClientID: monitoringv1.SecretOrConfigMap{
+					Secret: &clientIDSelector,
+				},
+				ClientSecret: secretSelector("client-secret"),
+			},
+
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newAssetStore(c.CoreV1(), c.CoreV1())
+
+			err := store.addOAuth2(context.Background(), tc.ns, tc.oauth2, "remoteRead/0")
+
+			if tc.err {
+				if err == nil {
+					t.Fatal("expecting error, got no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+
+			s, found := store.oauth2Assets["remoteRead/0"]
+			if !found {
+				t.Fatal("expecting to find credentials but got nothing")
+			}
+
+			if s.clientID != tc.expectedClientID {
+				t.Fatalf("expecting client id %q, got %q", tc.expectedClientID, s.clientID)
+			}
+
+			if s.clientSecret != tc.expectedClientSecret {
+				t.Fatalf("expecting client secret %q, got %q", tc.expectedClientSecret, s.clientSecret)
+			}
+		})
+	}
+}
+
+func TestAddHeaders(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"tenant": []byte("tenant-a"),
+			},
+		},
+	)
+
+	store := newAssetStore(c.CoreV1(), c.CoreV1())
+
+	err := store.addHeaders(context.Background(), "ns1", map[string]v1.SecretKeySelector{
+		"X-Scope-OrgID": {
+			LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+			Key:                  "tenant",
+		},
+	}, "remoteRead/0")
+	if err != nil {
+		t.Fatalf("expecting no error, got %q", err)
+	}
+
+	if v := store.headerAssets["remoteRead/0"]["X-Scope-OrgID"]; v != "tenant-a" {
+		t.Fatalf("expecting header value %q, got %q", "tenant-a", v)
+	}
+
+	err = store.addHeaders(context.Background(), "ns1", map[string]v1.SecretKeySelector{
+		"X-Scope-OrgID": {
+			LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+			Key:                  "missing",
+		},
+	}, "remoteRead/1")
+	if err == nil {
+		t.Fatal("expecting error, got no error")
+	}
+}
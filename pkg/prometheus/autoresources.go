@@ -0,0 +1,352 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	headSeriesMetric       = "prometheus_tsdb_head_series"
+	defaultBytesPerSeries  = 8 * 1024
+	defaultHeadroomPercent = 25
+	// autoResourcesTolerance is the relative difference between the
+	// recommendation and the current memory request below which the
+	// StatefulSet isn't updated, to avoid rolling the pods for small
+	// variations of the series.
+	autoResourcesTolerance = 0.1
+)
+
+// checkAutoResources returns an error if the autoResources bounds are
+// invalid.
+func checkAutoResources(p *monitoringv1.Prometheus) error {
+	ar := p.Spec.AutoResources
+	if ar == nil {
+		return nil
+	}
+
+	if ar.MinMemory.Sign() <= 0 {
+		return errors.New("minMemory must be positive")
+	}
+	if ar.MinMemory.Cmp(ar.MaxMemory) > 0 {
+		return errors.Errorf("minMemory %s is greater than maxMemory %s", ar.MinMemory.String(), ar.MaxMemory.String())
+	}
+	if limit, ok := p.Spec.Resources.Limits[v1.ResourceMemory]; ok && ar.MaxMemory.Cmp(limit) > 0 {
+		return errors.Errorf("maxMemory %s is greater than the memory limit %s", ar.MaxMemory.String(), limit.String())
+	}
+	if ar.BytesPerSeries != nil && ar.BytesPerSeries.Sign() <= 0 {
+		return errors.New("bytesPerSeries must be positive")
+	}
+	if ar.HeadroomPercent != nil && *ar.HeadroomPercent < 0 {
+		return errors.New("headroomPercent must not be negative")
+	}
+
+	return nil
+}
+
+// memoryRecommendation returns the memory request for the given number of
+// head series, within the autoResources bounds.
+func memoryRecommendation(series float64, ar *monitoringv1.AutoResources) resource.Quantity {
+	bytesPerSeries := float64(defaultBytesPerSeries)
+	if ar.BytesPerSeries != nil {
+		bytesPerSeries = float64(ar.BytesPerSeries.Value())
+	}
+	headroom := float64(defaultHeadroomPercent)
+	if ar.HeadroomPercent != nil {
+		headroom = float64(*ar.HeadroomPercent)
+	}
+
+	// Round up to the mebibyte to keep the values readable.
+	const mib = 1024 * 1024
+	bytes := int64(math.Ceil(series*bytesPerSeries*(1+headroom/100)/mib)) * mib
+
+	return clampMemory(*resource.NewQuantity(bytes, resource.BinarySI), ar)
+}
+
+func clampMemory(q resource.Quantity, ar *monitoringv1.AutoResources) resource.Quantity {
+	switch {
+	case q.Cmp(ar.MinMemory) < 0:
+		return ar.MinMemory.DeepCopy()
+	case q.Cmp(ar.MaxMemory) > 0:
+		return ar.MaxMemory.DeepCopy()
+	}
+	return q
+}
+
+// autoMemoryRequest returns the memory request of the Prometheus container.
+// The current request of the StatefulSet is kept when no recommendation is
+// available (e.g. after a restart of the operator) or when the
+// recommendation is close to it, so that the pods aren't rolled needlessly.
+func autoMemoryRequest(p *monitoringv1.Prometheus, recommendation *resource.Quantity, current *appsv1.StatefulSet) resource.Quantity {
+	ar := p.Spec.AutoResources
+
+	var currentRequest *resource.Quantity
+	if current != nil {
+		for _, c := range current.Spec.Template.Spec.Containers {
+			if c.Name != "prometheus" {
+				continue
+			}
+			if q, ok := c.Resources.Requests[v1.ResourceMemory]; ok && q.Cmp(ar.MinMemory) >= 0 && q.Cmp(ar.MaxMemory) <= 0 {
+				currentRequest = &q
+			}
+		}
+	}
+
+	switch {
+	case recommendation == nil && currentRequest != nil:
+		return *currentRequest
+	case recommendation == nil:
+		if q, ok := p.Spec.Resources.Requests[v1.ResourceMemory]; ok {
+			return clampMemory(q, ar)
+		}
+		return ar.MinMemory.DeepCopy()
+	case currentRequest != nil:
+		cur := float64(currentRequest.Value())
+		if math.Abs(float64(recommendation.Value())-cur) < cur*autoResourcesTolerance {
+			return *currentRequest
+		}
+	}
+
+	return clampMemory(*recommendation, ar)
+}
+
+// applyAutoResources sets the memory request of the Prometheus object.
+func applyAutoResources(p *monitoringv1.Prometheus, recommendation *resource.Quantity, current *appsv1.StatefulSet) {
+	if p.Spec.AutoResources == nil {
+		return
+	}
+
+	requests := v1.ResourceList{}
+	for k, v := range p.Spec.Resources.Requests {
+		requests[k] = v
+	}
+	requests[v1.ResourceMemory] = autoMemoryRequest(p, recommendation, current)
+	p.Spec.Resources.Requests = requests
+}
+
+// resourceRecommender periodically scrapes the TSDB head series of the
+// Prometheus pods with autoResources and computes their memory requests.
+type resourceRecommender struct {
+	logger        log.Logger
+	kclient       kubernetes.Interface
+	client        *http.Client
+	clusterDomain string
+
+	mtx             sync.Mutex
+	recommendations map[string]resource.Quantity
+}
+
+func newResourceRecommender(logger log.Logger, kclient kubernetes.Interface, clusterDomain string) *resourceRecommender {
+	return &resourceRecommender{
+		logger:          logger,
+		kclient:         kclient,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		clusterDomain:   clusterDomain,
+		recommendations: map[string]resource.Quantity{},
+	}
+}
+
+// recommendation returns the last memory recommendation of the Prometheus
+// instance, nil if there is none.
+func (r *resourceRecommender) recommendation(key string) *resource.Quantity {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	q, ok := r.recommendations[key]
+	if !ok {
+		return nil
+	}
+	return &q
+}
+
+// forget drops the recommendation of a deleted Prometheus instance.
+func (r *resourceRecommender) forget(key string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.recommendations, key)
+}
+
+// Run recomputes the memory recommendations of the Prometheus instances
+// returned by list at every interval until the context is canceled. The
+// instances whose recommendation changed are passed to enqueue.
+func (r *resourceRecommender) Run(ctx context.Context, interval time.Duration, list func() []*monitoringv1.Prometheus, enqueue func(interface{})) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, p := range list() {
+			changed, err := r.recommend(ctx, p)
+			if err != nil {
+				level.Debug(r.logger).Log("msg", "computing the memory recommendation failed", "prometheus", p.Name, "namespace", p.Namespace, "err", err)
+				continue
+			}
+			if changed {
+				enqueue(p)
+			}
+		}
+	}
+}
+
+// recommend updates the memory recommendation of the Prometheus instance
+// and returns whether it changed.
+func (r *resourceRecommender) recommend(ctx context.Context, p *monitoringv1.Prometheus) (bool, error) {
+	key := fmt.Sprintf("%s/%s", p.Namespace, p.Name)
+
+	// Loopback-only instances can't be reached by the operator.
+	if p.Spec.AutoResources == nil || p.Spec.Paused || p.Spec.ListenLocal {
+		r.forget(key)
+		return false, nil
+	}
+	if err := checkAutoResources(p); err != nil {
+		return false, err
+	}
+	if err := checkWeb(p); err != nil {
+		return false, err
+	}
+
+	series, err := r.headSeries(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	q := memoryRecommendation(series, p.Spec.AutoResources)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if old, ok := r.recommendations[key]; ok && old.Cmp(q) == 0 {
+		return false, nil
+	}
+	r.recommendations[key] = q
+	level.Debug(r.logger).Log("msg", "memory recommendation updated", "prometheus", p.Name, "namespace", p.Namespace, "series", series, "memory", q.String())
+
+	return true, nil
+}
+
+// headSeries returns the highest number of head series among the ready
+// Prometheus pods.
+func (r *resourceRecommender) headSeries(ctx context.Context, p *monitoringv1.Prometheus) (float64, error) {
+	pods, err := r.kclient.CoreV1().Pods(p.Namespace).List(ctx, ListOptions(p.Name))
+	if err != nil {
+		return 0, errors.Wrap(err, "listing pods failed")
+	}
+
+	tlsConfig, err := webconfig.ClientTLSConfig(ctx, r.kclient.CoreV1(), p.Namespace, p.Spec.Web)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid web settings")
+	}
+
+	routePrefix := "/"
+	if p.Spec.RoutePrefix != "" {
+		routePrefix = p.Spec.RoutePrefix
+	}
+
+	var (
+		series float64
+		found  bool
+	)
+	for _, pod := range pods.Items {
+		if ready, err := k8sutil.PodRunningAndReady(pod); err != nil || !ready || pod.Status.PodIP == "" {
+			continue
+		}
+
+		u := fmt.Sprintf("%s://%s:9090%s", webconfig.Scheme(p.Spec.Web), pod.Status.PodIP, path.Join(routePrefix, "/metrics"))
+		v, err := r.scrape(ctx, webClient(r.client, tlsConfig, podHost(pod.Name, p.Namespace, r.clusterDomain)), u)
+		if err != nil {
+			level.Debug(r.logger).Log("msg", "scraping Prometheus failed", "pod", pod.Name, "namespace", p.Namespace, "err", err)
+			continue
+		}
+		if !found || v > series {
+			series = v
+		}
+		found = true
+	}
+
+	if !found {
+		return 0, errors.New("no ready pod could be scraped")
+	}
+
+	return series, nil
+}
+
+func (r *resourceRecommender) scrape(ctx context.Context, client *http.Client, u string) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errors.Wrap(err, "request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return parseMetricValue(res.Body, headSeriesMetric)
+}
+
+// parseMetricValue returns the value of an unlabeled metric from a payload
+// in the Prometheus text format.
+func parseMetricValue(r io.Reader, name string) (float64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != name {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid value for %s", name)
+		}
+		return v, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, errors.Wrap(err, "reading metrics failed")
+	}
+
+	return 0, errors.Errorf("metric %s not found", name)
+}
@@ -0,0 +1,337 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testAutoResources() *monitoringv1.AutoResources {
+	return &monitoringv1.AutoResources{
+		MinMemory: resource.MustParse("1Gi"),
+		MaxMemory: resource.MustParse("8Gi"),
+	}
+}
+
+func TestCheckAutoResources(t *testing.T) {
+	negative := int32(-1)
+	for _, tc := range []struct {
+		name     string
+		ar       *monitoringv1.AutoResources
+		limits   v1.ResourceList
+		expected bool
+	}{
+		{
+			name: "no autoResources",
+		},
+		{
+			name: "valid bounds",
+			ar:   testAutoResources(),
+		},
+		{
+			name: "valid bounds with limit",
+			ar:   testAutoResources(),
+			limits: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		},
+		{
+			name: "max greater than limit",
+			ar:   testAutoResources(),
+			limits: v1.ResourceList{
+				v1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+			expected: true,
+		},
+		{
+			name: "min greater than max",
+			ar: &monitoringv1.AutoResources{
+				MinMemory: resource.MustParse("8Gi"),
+				MaxMemory: resource.MustParse("1Gi"),
+			},
+			expected: true,
+		},
+		{
+			name: "zero min",
+			ar: &monitoringv1.AutoResources{
+				MaxMemory: resource.MustParse("1Gi"),
+			},
+			expected: true,
+		},
+		{
+			name: "negative headroom",
+			ar: &monitoringv1.AutoResources{
+				MinMemory:       resource.MustParse("1Gi"),
+				MaxMemory:       resource.MustParse("8Gi"),
+				HeadroomPercent: &negative,
+			},
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				Spec: monitoringv1.PrometheusSpec{
+					AutoResources: tc.ar,
+					Resources:     v1.ResourceRequirements{Limits: tc.limits},
+				},
+			}
+			err := checkAutoResources(p)
+			if tc.expected != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestMemoryRecommendation(t *testing.T) {
+	bytesPerSeries := resource.MustParse("4Ki")
+	headroom := int32(0)
+
+	for _, tc := range []struct {
+		name     string
+		series   float64
+		ar       func(*monitoringv1.AutoResources)
+		expected string
+	}{
+		{
+			name:     "defaults",
+			series:   400000,
+			expected: "3907Mi",
+		},
+		{
+			name:   "custom bytes per series and headroom",
+			series: 400000,
+			ar: func(ar *monitoringv1.AutoResources) {
+				ar.BytesPerSeries = &bytesPerSeries
+				ar.HeadroomPercent = &headroom
+			},
+			expected: "1563Mi",
+		},
+		{
+			name:     "lower bound",
+			series:   1000,
+			expected: "1Gi",
+		},
+		{
+			name:     "upper bound",
+			series:   10000000,
+			expected: "8Gi",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ar := testAutoResources()
+			if tc.ar != nil {
+				tc.ar(ar)
+			}
+			q := memoryRecommendation(tc.series, ar)
+			if q.Cmp(resource.MustParse(tc.expected)) != 0 {
+				t.Fatalf("expected %s, got %s", tc.expected, q.String())
+			}
+		})
+	}
+}
+
+func TestAutoMemoryRequest(t *testing.T) {
+	sset := func(memory string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			Spec: appsv1.StatefulSetSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{
+							Name: "prometheus",
+							Resources: v1.ResourceRequirements{
+								Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse(memory)},
+							},
+						}},
+					},
+				},
+			},
+		}
+	}
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+
+	for _, tc := range []struct {
+		name           string
+		request        string
+		recommendation *resource.Quantity
+		current        *appsv1.StatefulSet
+		expected       string
+	}{
+		{
+			name:     "no recommendation nor statefulset",
+			expected: "1Gi",
+		},
+		{
+			name:     "no recommendation with request",
+			request:  "16Gi",
+			expected: "8Gi",
+		},
+		{
+			name:     "no recommendation keeps the current request",
+			request:  "2Gi",
+			current:  sset("3Gi"),
+			expected: "3Gi",
+		},
+		{
+			name:     "no recommendation with out of bounds current request",
+			request:  "2Gi",
+			current:  sset("512Mi"),
+			expected: "2Gi",
+		},
+		{
+			name:           "recommendation",
+			recommendation: quantity("4Gi"),
+			current:        sset("3Gi"),
+			expected:       "4Gi",
+		},
+		{
+			name:           "recommendation within the tolerance",
+			recommendation: quantity("3200Mi"),
+			current:        sset("3Gi"),
+			expected:       "3Gi",
+		},
+		{
+			name:           "recommendation without statefulset",
+			recommendation: quantity("3200Mi"),
+			expected:       "3200Mi",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				Spec: monitoringv1.PrometheusSpec{
+					AutoResources: testAutoResources(),
+				},
+			}
+			if tc.request != "" {
+				p.Spec.Resources.Requests = v1.ResourceList{v1.ResourceMemory: resource.MustParse(tc.request)}
+			}
+
+			applyAutoResources(p, tc.recommendation, tc.current)
+			q := p.Spec.Resources.Requests[v1.ResourceMemory]
+			if q.Cmp(resource.MustParse(tc.expected)) != 0 {
+				t.Fatalf("expected %s, got %s", tc.expected, q.String())
+			}
+		})
+	}
+}
+
+func TestParseMetricValue(t *testing.T) {
+	payload := `# HELP prometheus_tsdb_head_series_created_total Total number of series created in the head
+# TYPE prometheus_tsdb_head_series_created_total counter
+prometheus_tsdb_head_series_created_total 1.234567e+06
+# HELP prometheus_tsdb_head_series Total number of series in the head block.
+# TYPE prometheus_tsdb_head_series gauge
+prometheus_tsdb_head_series 123456
+`
+	v, err := parseMetricValue(strings.NewReader(payload), headSeriesMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 123456 {
+		t.Fatalf("expected 123456, got %v", v)
+	}
+
+	if _, err := parseMetricValue(strings.NewReader("up 1\n"), headSeriesMetric); err == nil {
+		t.Fatal("expected error for missing metric")
+	}
+}
+
+func TestResourceRecommenderRecommend(t *testing.T) {
+	series := 400000
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "%s %d\n", headSeriesMetric, series)
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			AutoResources: testAutoResources(),
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-test-0",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "prometheus", "prometheus": "test"},
+		},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			PodIP:      "10.0.0.1",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+
+	r := newResourceRecommender(log.NewNopLogger(), fake.NewSimpleClientset(pod), "")
+	r.client = &http.Client{Transport: &redirectTransport{target: target}}
+
+	recommend := func(expectedChanged bool, expected string) {
+		t.Helper()
+		changed, err := r.recommend(context.Background(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != expectedChanged {
+			t.Fatalf("expected changed %v, got %v", expectedChanged, changed)
+		}
+		q := r.recommendation("default/test")
+		if q == nil || q.Cmp(resource.MustParse(expected)) != 0 {
+			t.Fatalf("expected recommendation %s, got %v", expected, q)
+		}
+	}
+
+	recommend(true, "3907Mi")
+	recommend(false, "3907Mi")
+
+	series = 600000
+	recommend(true, "5860Mi")
+
+	// The recommendation is dropped when autoResources is removed.
+	p.Spec.AutoResources = nil
+	if _, err := r.recommend(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	if q := r.recommendation("default/test"); q != nil {
+		t.Fatalf("expected no recommendation, got %s", q.String())
+	}
+}
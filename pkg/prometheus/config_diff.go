@@ -0,0 +1,154 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	configChangedReason = "ConfigurationChanged"
+	redactedValue       = "<redacted>"
+)
+
+// secretConfigKeys are the keys of the Prometheus configuration holding
+// values read from Secrets.
+var secretConfigKeys = map[string]struct{}{
+	"password":      {},
+	"bearer_token":  {},
+	"credentials":   {},
+	"client_secret": {},
+	"access_key":    {},
+	"secret_key":    {},
+	// The values of the headers may come from Secrets.
+	"headers": {},
+}
+
+// configDiff returns the unified diff between the current and the generated
+// configurations, gzipped unless the HTTP reload strategy is used. The values
+// read from Secrets are redacted.
+func configDiff(cur, generated []byte) (string, error) {
+	var texts [2][]byte
+	for i, conf := range [][]byte{cur, generated} {
+		var err error
+		b := conf
+		if isGzipped(conf) {
+			if b, err = gunzipConfig(conf); err != nil {
+				return "", err
+			}
+		}
+
+		if texts[i], err = redactConfig(b); err != nil {
+			return "", err
+		}
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(texts[0])),
+		B:        difflib.SplitLines(string(texts[1])),
+		FromFile: "a/" + strings.TrimSuffix(configFilename, ".gz"),
+		ToFile:   "b/" + strings.TrimSuffix(configFilename, ".gz"),
+		Context:  3,
+	})
+}
+
+// configDiffSummary returns the number of added and removed lines of a
+// unified diff.
+func configDiffSummary(diff string) string {
+	var added, removed int
+	for _, l := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"):
+		case strings.HasPrefix(l, "+"):
+			added++
+		case strings.HasPrefix(l, "-"):
+			removed++
+		}
+	}
+
+	return fmt.Sprintf("%d lines added, %d lines removed", added, removed)
+}
+
+// isGzipped returns whether b starts with the gzip magic number.
+func isGzipped(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+func gunzipConfig(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress configuration")
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// redactConfig replaces the values read from Secrets in the configuration.
+func redactConfig(b []byte) ([]byte, error) {
+	var conf yaml.MapSlice
+	if err := yaml.Unmarshal(b, &conf); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal configuration")
+	}
+
+	return yaml.Marshal(redactValue(conf))
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for i := range v {
+			key, _ := v[i].Key.(string)
+			if _, ok := secretConfigKeys[key]; ok {
+				v[i].Value = redactLeaves(v[i].Value)
+				continue
+			}
+			v[i].Value = redactValue(v[i].Value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+
+	return v
+}
+
+// redactLeaves redacts the scalar values, keeping the keys of maps such as
+// the header names.
+func redactLeaves(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for i := range v {
+			v[i].Value = redactLeaves(v[i].Value)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactLeaves(v[i])
+		}
+		return v
+	}
+
+	return redactedValue
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfigDiff(t *testing.T) {
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		if err := gzipConfig(&buf, []byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	cur := gzipped(`global:
+  scrape_interval: 30s
+scrape_configs:
+- job_name: default/app/0
+  bearer_token: old-token
+  basic_auth:
+    username: admin
+    password: old-password
+`)
+	generated := gzipped(`global:
+  scrape_interval: 1m
+scrape_configs:
+- job_name: default/app/0
+  bearer_token: new-token
+  basic_auth:
+    username: admin
+    password: new-password
+remote_read:
+- url: http://example.com
+  headers:
+    X-Token: header-secret
+`)
+
+	diff, err := configDiff(cur, generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"old-token", "new-token", "old-password", "new-password", "header-secret"} {
+		if strings.Contains(diff, s) {
+			t.Fatalf("expected %q to be redacted:\n%s", s, diff)
+		}
+	}
+
+	for _, s := range []string{
+		"--- a/prometheus.yaml\n",
+		"+++ b/prometheus.yaml\n",
+		"-  scrape_interval: 30s\n",
+		"+  scrape_interval: 1m\n",
+		"+    X-Token: <redacted>\n",
+	} {
+		if !strings.Contains(diff, s) {
+			t.Fatalf("expected %q in the diff:\n%s", s, diff)
+		}
+	}
+
+	// The redacted credentials don't show up as changes.
+	if strings.Contains(diff, "-  bearer_token") {
+		t.Fatalf("unexpected bearer token change:\n%s", diff)
+	}
+
+	if summary := configDiffSummary(diff); summary != "5 lines added, 1 lines removed" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+}
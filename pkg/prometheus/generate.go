@@ -0,0 +1,124 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ConfigResources holds the objects from which the configuration of a
+// Prometheus resource is generated. The monitoring objects are keyed by
+// "<namespace>/<name>" and are expected to be already selected.
+type ConfigResources struct {
+	ServiceMonitors map[string]*monitoringv1.ServiceMonitor
+	PodMonitors     map[string]*monitoringv1.PodMonitor
+	Probes          map[string]*monitoringv1.Probe
+	NodeMonitors    map[string]*monitoringv1.NodeMonitor
+
+	// AdditionalScrapeConfigs, AdditionalAlertRelabelConfigs and
+	// AdditionalAlertManagerConfigs are the raw contents of the Secret keys
+	// referenced by the Prometheus spec.
+	AdditionalScrapeConfigs       []byte
+	AdditionalAlertRelabelConfigs []byte
+	AdditionalAlertManagerConfigs []byte
+
+	// RuleConfigMapNames are the names of the ConfigMaps holding the
+	// Prometheus rules.
+	RuleConfigMapNames []string
+
+	// DefaultExternalLabels are the operator-level external labels, see the
+	// --default-external-labels flag.
+	DefaultExternalLabels map[string]string
+}
+
+// GenerateConfig renders the Prometheus configuration with the same logic
+// as the operator. The Secrets and ConfigMaps referenced by the objects are
+// retrieved with the given client. Unlike the operator which skips invalid
+// monitoring objects, GenerateConfig returns an error.
+func GenerateConfig(ctx context.Context, logger log.Logger, kclient kubernetes.Interface, p *monitoringv1.Prometheus, res ConfigResources) ([]byte, error) {
+	store := newAssetStore(kclient.CoreV1(), kclient.CoreV1())
+
+	for k, sm := range res.ServiceMonitors {
+		if _, err := scrapeClass(p, sm.Spec.ScrapeClassName); err != nil {
+			return nil, errors.Wrapf(err, "servicemonitor %s", k)
+		}
+		if err := checkServiceMonitorFSAccess(p, sm); err != nil {
+			return nil, errors.Wrapf(err, "servicemonitor %s", k)
+		}
+		if err := checkServiceMonitorBoundToken(p, sm); err != nil {
+			return nil, errors.Wrapf(err, "servicemonitor %s", k)
+		}
+		if err := addServiceMonitorAssets(ctx, sm, store); err != nil {
+			return nil, errors.Wrapf(err, "servicemonitor %s", k)
+		}
+	}
+
+	for k, pm := range res.PodMonitors {
+		if _, err := scrapeClass(p, pm.Spec.ScrapeClassName); err != nil {
+			return nil, errors.Wrapf(err, "podmonitor %s", k)
+		}
+		if err := addPodMonitorAssets(ctx, pm, store); err != nil {
+			return nil, errors.Wrapf(err, "podmonitor %s", k)
+		}
+	}
+
+	for k, probe := range res.Probes {
+		if _, err := scrapeClass(p, probe.Spec.ScrapeClassName); err != nil {
+			return nil, errors.Wrapf(err, "probe %s", k)
+		}
+		if err := addProbeAssets(ctx, probe, store); err != nil {
+			return nil, errors.Wrapf(err, "probe %s", k)
+		}
+	}
+
+	for k, nm := range res.NodeMonitors {
+		if err := checkNodeMonitorFSAccess(p, nm); err != nil {
+			return nil, errors.Wrapf(err, "nodemonitor %s", k)
+		}
+		if err := checkNodeMonitorBoundToken(p, nm); err != nil {
+			return nil, errors.Wrapf(err, "nodemonitor %s", k)
+		}
+		if err := addNodeMonitorAssets(ctx, nm, store); err != nil {
+			return nil, errors.Wrapf(err, "nodemonitor %s", k)
+		}
+	}
+
+	if err := addPrometheusAssets(ctx, p, store); err != nil {
+		return nil, err
+	}
+
+	return newConfigGenerator(logger, res.DefaultExternalLabels).generateConfig(
+		p,
+		res.ServiceMonitors,
+		res.PodMonitors,
+		res.Probes,
+		res.NodeMonitors,
+		store.basicAuthAssets,
+		store.bearerTokenAssets,
+		store.sigv4Assets,
+		store.oauth2Assets,
+		store.headerAssets,
+		store.authorizationAssets,
+		res.AdditionalScrapeConfigs,
+		res.AdditionalAlertRelabelConfigs,
+		res.AdditionalAlertManagerConfigs,
+		res.RuleConfigMapNames,
+	)
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"strconv"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// namespaceSampleLimitAnnotation is the Namespace annotation defining
+	// the sample limit enforced for the monitoring objects of the
+	// namespace.
+	namespaceSampleLimitAnnotation = "monitoring.coreos.com/enforced-sample-limit"
+	// namespaceTargetLimitAnnotation is the Namespace annotation defining
+	// the target limit enforced for the Probes of the namespace.
+	namespaceTargetLimitAnnotation = "monitoring.coreos.com/enforced-target-limit"
+)
+
+// namespaceLimits holds the scrape limits enforced for the monitoring
+// objects of a namespace. 0 means no limit.
+type namespaceLimits struct {
+	sampleLimit uint64
+	targetLimit uint64
+}
+
+// namespaceLimitsFromAnnotations returns the scrape limits defined by the
+// annotations of the namespace.
+func namespaceLimitsFromAnnotations(ns *v1.Namespace) (namespaceLimits, error) {
+	var (
+		limits namespaceLimits
+		err    error
+	)
+
+	for annotation, limit := range map[string]*uint64{
+		namespaceSampleLimitAnnotation: &limits.sampleLimit,
+		namespaceTargetLimitAnnotation: &limits.targetLimit,
+	} {
+		s, found := ns.Annotations[annotation]
+		if !found {
+			continue
+		}
+		if *limit, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return namespaceLimits{}, errors.Wrapf(err, "invalid %s annotation", annotation)
+		}
+	}
+
+	return limits, nil
+}
+
+// namespaceLimitsChanged returns whether the scrape limit annotations differ
+// between the two versions of the namespace.
+func namespaceLimitsChanged(old, cur *v1.Namespace) bool {
+	for _, annotation := range []string{namespaceSampleLimitAnnotation, namespaceTargetLimitAnnotation} {
+		if old.Annotations[annotation] != cur.Annotations[annotation] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namespaceScrapeLimits returns the scrape limits of the namespaces which
+// define them, indexed by namespace.
+func (c *Operator) namespaceScrapeLimits(namespaces map[string]struct{}) map[string]namespaceLimits {
+	res := map[string]namespaceLimits{}
+	for ns := range namespaces {
+		obj, exists, err := c.nsMonInf.GetStore().GetByKey(ns)
+		if err != nil || !exists {
+			continue
+		}
+
+		limits, err := namespaceLimitsFromAnnotations(obj.(*v1.Namespace))
+		if err != nil {
+			level.Warn(c.logger).Log("msg", "ignoring the scrape limits of the namespace", "err", err, "namespace", ns)
+			continue
+		}
+		if limits != (namespaceLimits{}) {
+			res[ns] = limits
+		}
+	}
+
+	return res
+}
+
+// applyNamespaceLimits lowers the scrape limits of the selected monitoring
+// objects to the limits of their namespaces. The objects are copied before
+// being modified. The enforced limits of the Prometheus still apply on top
+// of the namespace limits.
+func (c *Operator) applyNamespaceLimits(
+	smons map[string]*monitoringv1.ServiceMonitor,
+	pmons map[string]*monitoringv1.PodMonitor,
+	probes map[string]*monitoringv1.Probe,
+	nmons map[string]*monitoringv1.NodeMonitor,
+) {
+	namespaces := map[string]struct{}{}
+	for _, m := range smons {
+		namespaces[m.Namespace] = struct{}{}
+	}
+	for _, m := range pmons {
+		namespaces[m.Namespace] = struct{}{}
+	}
+	for _, m := range probes {
+		namespaces[m.Namespace] = struct{}{}
+	}
+	for _, m := range nmons {
+		namespaces[m.Namespace] = struct{}{}
+	}
+
+	enforceNamespaceLimits(c.namespaceScrapeLimits(namespaces), smons, pmons, probes, nmons)
+}
+
+// enforceNamespaceLimits lowers the scrape limits of the monitoring objects
+// to the given namespace limits.
+func enforceNamespaceLimits(
+	limits map[string]namespaceLimits,
+	smons map[string]*monitoringv1.ServiceMonitor,
+	pmons map[string]*monitoringv1.PodMonitor,
+	probes map[string]*monitoringv1.Probe,
+	nmons map[string]*monitoringv1.NodeMonitor,
+) {
+	if len(limits) == 0 {
+		return
+	}
+
+	// namespaceLimit returns the limit of the object lowered to the
+	// namespace limit, 0 meaning no limit.
+	namespaceLimit := func(limit, nsLimit uint64) uint64 {
+		if nsLimit == 0 {
+			return limit
+		}
+		return getLimit(limit, &nsLimit)
+	}
+
+	for k, m := range smons {
+		sampleLimit := namespaceLimit(m.Spec.SampleLimit, limits[m.Namespace].sampleLimit)
+		if sampleLimit == m.Spec.SampleLimit {
+			continue
+		}
+		m = m.DeepCopy()
+		m.Spec.SampleLimit = sampleLimit
+		smons[k] = m
+	}
+
+	for k, m := range pmons {
+		sampleLimit := namespaceLimit(m.Spec.SampleLimit, limits[m.Namespace].sampleLimit)
+		if sampleLimit == m.Spec.SampleLimit {
+			continue
+		}
+		m = m.DeepCopy()
+		m.Spec.SampleLimit = sampleLimit
+		pmons[k] = m
+	}
+
+	for k, m := range probes {
+		l := limits[m.Namespace]
+		sampleLimit := namespaceLimit(m.Spec.SampleLimit, l.sampleLimit)
+		targetLimit := namespaceLimit(m.Spec.TargetLimit, l.targetLimit)
+		if sampleLimit == m.Spec.SampleLimit && targetLimit == m.Spec.TargetLimit {
+			continue
+		}
+		m = m.DeepCopy()
+		m.Spec.SampleLimit = sampleLimit
+		m.Spec.TargetLimit = targetLimit
+		probes[k] = m
+	}
+
+	for k, m := range nmons {
+		sampleLimit := namespaceLimit(m.Spec.SampleLimit, limits[m.Namespace].sampleLimit)
+		if sampleLimit == m.Spec.SampleLimit {
+			continue
+		}
+		m = m.DeepCopy()
+		m.Spec.SampleLimit = sampleLimit
+		nmons[k] = m
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringfake "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/fake"
+	"github.com/prometheus-operator/prometheus-operator/pkg/informers"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestNamespaceLimitsFromAnnotations(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		expected    namespaceLimits
+		err         bool
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name: "sample and target limits",
+			annotations: map[string]string{
+				namespaceSampleLimitAnnotation: "1000",
+				namespaceTargetLimitAnnotation: "10",
+			},
+			expected: namespaceLimits{sampleLimit: 1000, targetLimit: 10},
+		},
+		{
+			name: "invalid limit",
+			annotations: map[string]string{
+				namespaceSampleLimitAnnotation: "-1",
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limits, err := namespaceLimitsFromAnnotations(&v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+			})
+			if tc.err != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if limits != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, limits)
+			}
+		})
+	}
+}
+
+func TestEnforceNamespaceLimits(t *testing.T) {
+	smon := func(ns string, sampleLimit uint64) *monitoringv1.ServiceMonitor {
+		return &monitoringv1.ServiceMonitor{
+			ObjectMeta: metav1.ObjectMeta{Name: "smon", Namespace: ns},
+			Spec:       monitoringv1.ServiceMonitorSpec{SampleLimit: sampleLimit},
+		}
+	}
+	original := smon("tenant-a", 5000)
+	smons := map[string]*monitoringv1.ServiceMonitor{
+		"tenant-a/smon":  original,
+		"tenant-a/other": smon("tenant-a", 0),
+		"tenant-b/smon":  smon("tenant-b", 100),
+		"tenant-c/smon":  smon("tenant-c", 5000),
+	}
+	probes := map[string]*monitoringv1.Probe{
+		"tenant-a/probe": {
+			ObjectMeta: metav1.ObjectMeta{Name: "probe", Namespace: "tenant-a"},
+			Spec:       monitoringv1.ProbeSpec{TargetLimit: 50},
+		},
+	}
+
+	enforceNamespaceLimits(
+		map[string]namespaceLimits{
+			"tenant-a": {sampleLimit: 1000, targetLimit: 10},
+			"tenant-b": {sampleLimit: 1000},
+		},
+		smons,
+		map[string]*monitoringv1.PodMonitor{},
+		probes,
+		map[string]*monitoringv1.NodeMonitor{},
+	)
+
+	for k, expected := range map[string]uint64{
+		"tenant-a/smon":  1000,
+		"tenant-a/other": 1000,
+		"tenant-b/smon":  100,
+		"tenant-c/smon":  5000,
+	} {
+		if got := smons[k].Spec.SampleLimit; got != expected {
+			t.Fatalf("%s: expected sample limit %d, got %d", k, expected, got)
+		}
+	}
+
+	if original.Spec.SampleLimit != 5000 {
+		t.Fatalf("expected the original object to be left unmodified, got sample limit %d", original.Spec.SampleLimit)
+	}
+
+	p := probes["tenant-a/probe"].Spec
+	if p.SampleLimit != 1000 || p.TargetLimit != 10 {
+		t.Fatalf("expected sample limit 1000 and target limit 10, got %d and %d", p.SampleLimit, p.TargetLimit)
+	}
+}
+
+func TestHandleMonitorNamespaceUpdate(t *testing.T) {
+	newInformers := func(resource string) *informers.ForResource {
+		infs, err := informers.NewInformersForResource(
+			informers.NewMonitoringInformerFactories(
+				map[string]struct{}{v1.NamespaceAll: {}},
+				nil,
+				monitoringfake.NewSimpleClientset(),
+				0,
+				nil,
+			),
+			monitoringv1.SchemeGroupVersion.WithResource(resource),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return infs
+	}
+
+	c := &Operator{
+		logger:     log.NewNopLogger(),
+		metrics:    operator.NewMetrics("prometheus", prometheus.NewRegistry()),
+		promInfs:   newInformers(monitoringv1.PrometheusName),
+		agentInfs:  newInformers(monitoringv1.PrometheusAgentName),
+		nsMonInf:   cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Namespace{}, 0, cache.Indexers{}),
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		agentQueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	defer c.queue.ShutDown()
+	defer c.agentQueue.ShutDown()
+
+	// The Prometheus instance selects the ServiceMonitors of all namespaces.
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "monitoring"},
+		Spec: monitoringv1.PrometheusSpec{
+			ServiceMonitorNamespaceSelector: &metav1.LabelSelector{},
+		},
+	}
+	if err := c.promInfs.GetInformers()[0].Informer().GetStore().Add(p); err != nil {
+		t.Fatal(err)
+	}
+
+	old := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-a",
+			Annotations: map[string]string{namespaceSampleLimitAnnotation: "1000"},
+		},
+	}
+	for _, tc := range []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		enqueued    bool
+	}{
+		{
+			name:        "unrelated change",
+			labels:      map[string]string{"team": "a"},
+			annotations: map[string]string{namespaceSampleLimitAnnotation: "1000"},
+		},
+		{
+			name:        "sample limit changed",
+			annotations: map[string]string{namespaceSampleLimitAnnotation: "500"},
+			enqueued:    true,
+		},
+		{
+			name:     "sample limit removed",
+			enqueued: true,
+		},
+		{
+			name: "target limit added",
+			annotations: map[string]string{
+				namespaceSampleLimitAnnotation: "1000",
+				namespaceTargetLimitAnnotation: "10",
+			},
+			enqueued: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cur := old.DeepCopy()
+			cur.Labels = tc.labels
+			cur.Annotations = tc.annotations
+			if err := c.nsMonInf.GetStore().Update(cur); err != nil {
+				t.Fatal(err)
+			}
+
+			c.handleMonitorNamespaceUpdate(old, cur)
+
+			if enqueued := c.queue.Len() == 1; enqueued != tc.enqueued {
+				t.Fatalf("expected Prometheus to be enqueued %v, got queue length %d", tc.enqueued, c.queue.Len())
+			}
+			if c.queue.Len() > 0 {
+				key, _ := c.queue.Get()
+				if key != "monitoring/test" {
+					t.Fatalf("expected key monitoring/test, got %v", key)
+				}
+				c.queue.Forget(key)
+				c.queue.Done(key)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// namespaceNameLabel is set by Kubernetes on every namespace.
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	defaultKubeletPort = 10250
+)
+
+// selectedMonitors are the monitoring objects selected by a Prometheus
+// object.
+type selectedMonitors struct {
+	smons  map[string]*monitoringv1.ServiceMonitor
+	pmons  map[string]*monitoringv1.PodMonitor
+	probes map[string]*monitoringv1.Probe
+	nmons  map[string]*monitoringv1.NodeMonitor
+}
+
+// createOrUpdateNetworkPolicy synchronizes the NetworkPolicy of the
+// Prometheus Pods, deleting it when the Prometheus object doesn't request
+// it anymore.
+func (c *Operator) createOrUpdateNetworkPolicy(ctx context.Context, p *monitoringv1.Prometheus, mons selectedMonitors) error {
+	npClient := c.kclient.NetworkingV1().NetworkPolicies(p.Namespace)
+
+	if p.Spec.NetworkPolicy == nil {
+		// Without the permissions on the NetworkPolicies, the operator
+		// can't have created one either.
+		err := npClient.Delete(ctx, prefixedName(p.Name), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsForbidden(err) {
+			return errors.Wrap(err, "deleting network policy failed")
+		}
+		return nil
+	}
+
+	apiServerRules, err := c.apiServerEgressRules(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	alertmanagerRules, err := c.alertmanagerEgressRules(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	np := makeNetworkPolicy(p, c.config, mons, append(apiServerRules, alertmanagerRules...))
+	return k8sutil.CreateOrUpdateNetworkPolicy(ctx, npClient, np)
+}
+
+// apiServerEgressRules returns the egress rules to the Kubernetes API
+// server used by the service discovery. The in-cluster API server is
+// resolved from the endpoints of the `default/kubernetes` Service.
+func (c *Operator) apiServerEgressRules(ctx context.Context, p *monitoringv1.Prometheus) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	if p.Spec.APIServerConfig != nil {
+		port, err := urlPort(p.Spec.APIServerConfig.Host, "https")
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid API server host")
+		}
+		return []networkingv1.NetworkPolicyEgressRule{{Ports: numberedPorts(port)}}, nil
+	}
+
+	eps, err := c.kclient.CoreV1().Endpoints(metav1.NamespaceDefault).Get(ctx, "kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving API server endpoints failed")
+	}
+
+	var rules []networkingv1.NetworkPolicyEgressRule
+	for _, subset := range eps.Subsets {
+		var rule networkingv1.NetworkPolicyEgressRule
+		for _, addr := range subset.Addresses {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: hostCIDR(addr.IP)}})
+		}
+		for _, port := range subset.Ports {
+			rule.Ports = append(rule.Ports, numberedPorts(port.Port)...)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// alertmanagerEgressRules returns the egress rules to the Alertmanagers.
+// The named ports refer to the Endpoints objects and are resolved to the
+// port numbers of the Pods.
+func (c *Operator) alertmanagerEgressRules(ctx context.Context, p *monitoringv1.Prometheus) ([]networkingv1.NetworkPolicyEgressRule, error) {
+	if p.Spec.Alerting == nil {
+		return nil, nil
+	}
+
+	var rules []networkingv1.NetworkPolicyEgressRule
+	for _, am := range p.Spec.Alerting.Alertmanagers {
+		rule := networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{namespacePeer([]string{am.Namespace}, nil)},
+		}
+
+		switch {
+		case am.Port.Type == intstr.Int && am.Port.IntVal != 0:
+			rule.Ports = numberedPorts(am.Port.IntVal)
+		case am.Port.StrVal != "":
+			eps, err := c.kclient.CoreV1().Endpoints(am.Namespace).Get(ctx, am.Name, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, errors.Wrapf(err, "retrieving endpoints %s/%s failed", am.Namespace, am.Name)
+			}
+			// Without endpoints, all the ports of the namespace are allowed
+			// until the next reconciliation.
+			if eps != nil {
+				for _, subset := range eps.Subsets {
+					for _, port := range subset.Ports {
+						if port.Name == am.Port.StrVal {
+							rule.Ports = append(rule.Ports, numberedPorts(port.Port)...)
+						}
+					}
+				}
+			}
+		}
+
+		rules = appendEgressRule(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// makeNetworkPolicy returns the NetworkPolicy of the Prometheus Pods. The
+// rules resolved from the Kubernetes API are passed as resolvedRules.
+func makeNetworkPolicy(p *monitoringv1.Prometheus, config Config, mons selectedMonitors, resolvedRules []networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+	boolTrue := true
+
+	ingressPorts := numberedPorts(9090)
+	if p.Spec.Thanos != nil {
+		ingressPorts = append(ingressPorts, numberedPorts(10901, 10902)...)
+	}
+
+	// DNS is needed to resolve the targets and the remote endpoints.
+	udp := v1.ProtocolUDP
+	egress := []networkingv1.NetworkPolicyEgressRule{{
+		Ports: append([]networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: intOrStringPtr(intstr.FromInt(53))}}, numberedPorts(53)...),
+	}}
+	for _, rule := range resolvedRules {
+		egress = appendEgressRule(egress, rule)
+	}
+	for _, rule := range monitorEgressRules(p, mons) {
+		egress = appendEgressRule(egress, rule)
+	}
+
+	for _, fed := range p.Spec.Federation {
+		ns := fed.Namespace
+		if ns == "" {
+			ns = p.Namespace
+		}
+		port := fed.Port
+		if port == "" {
+			port = defaultPortName
+		}
+		egress = appendEgressRule(egress, networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{namespacePeer([]string{ns}, &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "prometheus", "prometheus": fed.Name},
+			})},
+			Ports: namedPorts(port),
+		})
+	}
+
+	var remoteURLs []string
+	// The proxied connections go to the proxy instead of the remote endpoint.
+	for _, rw := range p.Spec.RemoteWrite {
+		if rw.ProxyURL != "" {
+			remoteURLs = append(remoteURLs, rw.ProxyURL)
+			continue
+		}
+		remoteURLs = append(remoteURLs, rw.URL)
+	}
+	for _, rr := range p.Spec.RemoteRead {
+		if rr.ProxyURL != "" {
+			remoteURLs = append(remoteURLs, rr.ProxyURL)
+			continue
+		}
+		remoteURLs = append(remoteURLs, rr.URL)
+	}
+	for _, u := range remoteURLs {
+		// The URLs are validated when generating the configuration.
+		if port, err := urlPort(u, "http"); err == nil {
+			egress = appendEgressRule(egress, networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(port)})
+		}
+	}
+
+	for _, rule := range p.Spec.NetworkPolicy.AdditionalEgress {
+		egress = appendEgressRule(egress, rule)
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prefixedName(p.Name),
+			Namespace: p.Namespace,
+			Labels:    config.Labels.Merge(managedLabels(p)),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         p.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               p.Kind,
+					Name:               p.Name,
+					UID:                p.UID,
+				},
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "prometheus", "prometheus": p.Name},
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From:  p.Spec.NetworkPolicy.IngressFrom,
+				Ports: ingressPorts,
+			}},
+			Egress:      egress,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}
+}
+
+// monitorEgressRules returns the egress rules to the targets of the
+// monitoring objects. The ports of the ServiceMonitors name Service ports
+// which can't be expressed in a NetworkPolicy: unless the target port is
+// set, all the ports of the namespaces are allowed.
+func monitorEgressRules(p *monitoringv1.Prometheus, mons selectedMonitors) []networkingv1.NetworkPolicyEgressRule {
+	var rules []networkingv1.NetworkPolicyEgressRule
+
+	var keys []string
+	for k := range mons.smons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sm := mons.smons[k]
+		namespaces := getNamespacesFromNamespaceSelector(&sm.Spec.NamespaceSelector, sm.Namespace, p.Spec.IgnoreNamespaceSelectors)
+		for _, ep := range sm.Spec.Endpoints {
+			rule := networkingv1.NetworkPolicyEgressRule{
+				To: []networkingv1.NetworkPolicyPeer{namespacePeer(namespaces, nil)},
+			}
+			if ep.TargetPort != nil {
+				rule.Ports = []networkingv1.NetworkPolicyPort{tcpPort(*ep.TargetPort)}
+			}
+			rules = appendEgressRule(rules, rule)
+		}
+	}
+
+	keys = keys[:0]
+	for k := range mons.pmons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pm := mons.pmons[k]
+		namespaces := getNamespacesFromNamespaceSelector(&pm.Spec.NamespaceSelector, pm.Namespace, p.Spec.IgnoreNamespaceSelectors)
+		selector := pm.Spec.Selector.DeepCopy()
+		for _, ep := range pm.Spec.PodMetricsEndpoints {
+			rule := networkingv1.NetworkPolicyEgressRule{
+				To: []networkingv1.NetworkPolicyPeer{namespacePeer(namespaces, selector)},
+			}
+			switch {
+			case ep.Port != "":
+				rule.Ports = namedPorts(ep.Port)
+			case ep.TargetPort != nil:
+				rule.Ports = []networkingv1.NetworkPolicyPort{tcpPort(*ep.TargetPort)}
+			}
+			rules = appendEgressRule(rules, rule)
+		}
+	}
+
+	keys = keys[:0]
+	for k := range mons.probes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		probe := mons.probes[k]
+		scheme := probe.Spec.ProberSpec.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		// The prober URL is validated when selecting the Probes.
+		if port, err := urlPort(probe.Spec.ProberSpec.URL, scheme); err == nil {
+			rules = appendEgressRule(rules, networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(port)})
+		}
+	}
+
+	// The nodes are reached through their host network.
+	keys = keys[:0]
+	for k := range mons.nmons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, ep := range mons.nmons[k].Spec.Endpoints {
+			port := int32(defaultKubeletPort)
+			if ep.Port != nil {
+				port = *ep.Port
+			}
+			rules = appendEgressRule(rules, networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(port)})
+		}
+	}
+
+	return rules
+}
+
+// appendEgressRule appends the rule unless it is already allowed.
+func appendEgressRule(rules []networkingv1.NetworkPolicyEgressRule, rule networkingv1.NetworkPolicyEgressRule) []networkingv1.NetworkPolicyEgressRule {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return rules
+		}
+	}
+	return append(rules, rule)
+}
+
+// namespacePeer returns the peer selecting the Pods of the namespaces, all
+// the namespaces if empty.
+func namespacePeer(namespaces []string, podSelector *metav1.LabelSelector) networkingv1.NetworkPolicyPeer {
+	nsSelector := &metav1.LabelSelector{}
+	if len(namespaces) > 0 {
+		values := append([]string(nil), namespaces...)
+		sort.Strings(values)
+		nsSelector.MatchExpressions = []metav1.LabelSelectorRequirement{{
+			Key:      namespaceNameLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   values,
+		}}
+	}
+
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: nsSelector,
+		PodSelector:       podSelector,
+	}
+}
+
+func tcpPort(port intstr.IntOrString) networkingv1.NetworkPolicyPort {
+	tcp := v1.ProtocolTCP
+	return networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &port}
+}
+
+func numberedPorts(ports ...int32) []networkingv1.NetworkPolicyPort {
+	var res []networkingv1.NetworkPolicyPort
+	for _, p := range ports {
+		res = append(res, tcpPort(intstr.FromInt(int(p))))
+	}
+	return res
+}
+
+func namedPorts(names ...string) []networkingv1.NetworkPolicyPort {
+	var res []networkingv1.NetworkPolicyPort
+	for _, n := range names {
+		res = append(res, tcpPort(intstr.FromString(n)))
+	}
+	return res
+}
+
+func intOrStringPtr(v intstr.IntOrString) *intstr.IntOrString {
+	return &v
+}
+
+// hostCIDR returns the CIDR block of a single IP address.
+func hostCIDR(ip string) string {
+	if net.ParseIP(ip).To4() == nil {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+// urlPort returns the port of a URL, or the default port of the scheme. The
+// scheme is optional in the URL.
+func urlPort(s string, defaultScheme string) (int32, error) {
+	if !strings.Contains(s, "://") {
+		s = defaultScheme + "://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if port := u.Port(); port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid port %q", port)
+		}
+		return int32(n), nil
+	}
+
+	switch u.Scheme {
+	case "http":
+		return 80, nil
+	case "https":
+		return 443, nil
+	}
+
+	return 0, errors.Errorf("no default port for scheme %q", u.Scheme)
+}
@@ -0,0 +1,285 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestURLPort(t *testing.T) {
+	for _, tc := range []struct {
+		url           string
+		defaultScheme string
+		expected      int32
+		err           bool
+	}{
+		{url: "http://remote:9201/write", defaultScheme: "http", expected: 9201},
+		{url: "https://remote/write", defaultScheme: "http", expected: 443},
+		{url: "blackbox-exporter:9115", defaultScheme: "http", expected: 9115},
+		{url: "kubernetes.default.svc", defaultScheme: "https", expected: 443},
+		{url: "ftp://remote", defaultScheme: "http", err: true},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			port, err := urlPort(tc.url, tc.defaultScheme)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if port != tc.expected {
+				t.Fatalf("expected port %d, got %d", tc.expected, port)
+			}
+		})
+	}
+}
+
+func hasEgressRule(rules []networkingv1.NetworkPolicyEgressRule, rule networkingv1.NetworkPolicyEgressRule) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMakeNetworkPolicy(t *testing.T) {
+	targetPort := intstr.FromInt(8080)
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "monitoring",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Thanos: &monitoringv1.ThanosSpec{},
+			RemoteWrite: []monitoringv1.RemoteWriteSpec{
+				{URL: "http://remote:9201/write"},
+				{URL: "https://remote.example.com/write", ProxyURL: "http://proxy:3128"},
+			},
+			Federation: []monitoringv1.FederationSpec{{Name: "other", Namespace: "team", Match: []string{"up"}}},
+			NetworkPolicy: &monitoringv1.PrometheusNetworkPolicy{
+				IngressFrom: []networkingv1.NetworkPolicyPeer{namespacePeer([]string{"grafana"}, nil)},
+				AdditionalEgress: []networkingv1.NetworkPolicyEgressRule{
+					{Ports: numberedPorts(443)},
+				},
+			},
+		},
+	}
+	mons := selectedMonitors{
+		smons: map[string]*monitoringv1.ServiceMonitor{
+			"sm": {
+				ObjectMeta: metav1.ObjectMeta{Name: "sm", Namespace: "default"},
+				Spec: monitoringv1.ServiceMonitorSpec{
+					NamespaceSelector: monitoringv1.NamespaceSelector{MatchNames: []string{"b", "a"}},
+					Endpoints: []monitoringv1.Endpoint{
+						{Port: "web"},
+						{TargetPort: &targetPort},
+					},
+				},
+			},
+		},
+		pmons: map[string]*monitoringv1.PodMonitor{
+			"pm": {
+				ObjectMeta: metav1.ObjectMeta{Name: "pm", Namespace: "default"},
+				Spec: monitoringv1.PodMonitorSpec{
+					Selector:            metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+					PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{{Port: "metrics"}},
+				},
+			},
+		},
+		probes: map[string]*monitoringv1.Probe{
+			"probe": {
+				ObjectMeta: metav1.ObjectMeta{Name: "probe", Namespace: "default"},
+				Spec: monitoringv1.ProbeSpec{
+					ProberSpec: monitoringv1.ProberSpec{URL: "blackbox-exporter:9115"},
+				},
+			},
+		},
+		nmons: map[string]*monitoringv1.NodeMonitor{
+			"nm": {
+				ObjectMeta: metav1.ObjectMeta{Name: "nm", Namespace: "default"},
+				Spec: monitoringv1.NodeMonitorSpec{
+					Endpoints: []monitoringv1.NodeMetricsEndpoint{{}},
+				},
+			},
+		},
+	}
+	resolved := []networkingv1.NetworkPolicyEgressRule{{Ports: numberedPorts(6443)}}
+
+	np := makeNetworkPolicy(p, *defaultTestConfig, mons, resolved)
+
+	if np.Name != "prometheus-test" || np.Namespace != "monitoring" {
+		t.Fatalf("unexpected name %s/%s", np.Namespace, np.Name)
+	}
+	if !reflect.DeepEqual(np.Spec.PodSelector.MatchLabels, map[string]string{"app": "prometheus", "prometheus": "test"}) {
+		t.Fatalf("unexpected pod selector %v", np.Spec.PodSelector)
+	}
+
+	expectedIngress := []networkingv1.NetworkPolicyIngressRule{{
+		From:  p.Spec.NetworkPolicy.IngressFrom,
+		Ports: numberedPorts(9090, 10901, 10902),
+	}}
+	if !reflect.DeepEqual(np.Spec.Ingress, expectedIngress) {
+		t.Fatalf("expected ingress %v, got %v", expectedIngress, np.Spec.Ingress)
+	}
+
+	for _, tc := range []struct {
+		name string
+		rule networkingv1.NetworkPolicyEgressRule
+	}{
+		{
+			name: "resolved rule",
+			rule: resolved[0],
+		},
+		{
+			name: "servicemonitor port name",
+			rule: networkingv1.NetworkPolicyEgressRule{
+				To: []networkingv1.NetworkPolicyPeer{namespacePeer([]string{"a", "b"}, nil)},
+			},
+		},
+		{
+			name: "servicemonitor target port",
+			rule: networkingv1.NetworkPolicyEgressRule{
+				To:    []networkingv1.NetworkPolicyPeer{namespacePeer([]string{"a", "b"}, nil)},
+				Ports: numberedPorts(8080),
+			},
+		},
+		{
+			name: "podmonitor",
+			rule: networkingv1.NetworkPolicyEgressRule{
+				To: []networkingv1.NetworkPolicyPeer{namespacePeer([]string{"default"}, &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "foo"},
+				})},
+				Ports: namedPorts("metrics"),
+			},
+		},
+		{
+			name: "probe",
+			rule: networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(9115)},
+		},
+		{
+			name: "nodemonitor",
+			rule: networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(defaultKubeletPort)},
+		},
+		{
+			name: "federation",
+			rule: networkingv1.NetworkPolicyEgressRule{
+				To: []networkingv1.NetworkPolicyPeer{namespacePeer([]string{"team"}, &metav1.LabelSelector{
+					MatchLabels: map[string]string{"app": "prometheus", "prometheus": "other"},
+				})},
+				Ports: namedPorts("web"),
+			},
+		},
+		{
+			name: "remote write",
+			rule: networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(9201)},
+		},
+		{
+			name: "remote write proxy",
+			rule: networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(3128)},
+		},
+		{
+			name: "additional egress",
+			rule: networkingv1.NetworkPolicyEgressRule{Ports: numberedPorts(443)},
+		},
+	} {
+		if !hasEgressRule(np.Spec.Egress, tc.rule) {
+			t.Errorf("%s: expected egress rule %v", tc.name, tc.rule)
+		}
+	}
+}
+
+func TestCreateOrUpdateNetworkPolicy(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "monitoring",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Alerting: &monitoringv1.AlertingSpec{
+				Alertmanagers: []monitoringv1.AlertmanagerEndpoints{
+					{Namespace: "monitoring", Name: "alertmanager", Port: intstr.FromString("web")},
+				},
+			},
+			NetworkPolicy: &monitoringv1.PrometheusNetworkPolicy{},
+		},
+	}
+
+	c := &Operator{
+		kclient: fake.NewSimpleClientset(
+			&v1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+				Subsets: []v1.EndpointSubset{{
+					Addresses: []v1.EndpointAddress{{IP: "10.0.0.1"}},
+					Ports:     []v1.EndpointPort{{Name: "https", Port: 6443}},
+				}},
+			},
+			&v1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "alertmanager", Namespace: "monitoring"},
+				Subsets: []v1.EndpointSubset{{
+					Ports: []v1.EndpointPort{{Name: "web", Port: 9093}},
+				}},
+			},
+		),
+		config: *defaultTestConfig,
+	}
+
+	if err := c.createOrUpdateNetworkPolicy(context.Background(), p, selectedMonitors{}); err != nil {
+		t.Fatal(err)
+	}
+
+	np, err := c.kclient.NetworkingV1().NetworkPolicies("monitoring").Get(context.Background(), "prometheus-test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rule := range []networkingv1.NetworkPolicyEgressRule{
+		{
+			To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.1/32"}}},
+			Ports: numberedPorts(6443),
+		},
+		{
+			To:    []networkingv1.NetworkPolicyPeer{namespacePeer([]string{"monitoring"}, nil)},
+			Ports: numberedPorts(9093),
+		},
+	} {
+		if !hasEgressRule(np.Spec.Egress, rule) {
+			t.Fatalf("expected egress rule %v, got %v", rule, np.Spec.Egress)
+		}
+	}
+
+	// The NetworkPolicy is deleted when it isn't requested anymore.
+	p.Spec.NetworkPolicy = nil
+	if err := c.createOrUpdateNetworkPolicy(context.Background(), p, selectedMonitors{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.kclient.NetworkingV1().NetworkPolicies("monitoring").Get(context.Background(), "prometheus-test", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the network policy to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+// nodeAccessReview checks whether the ServiceAccount of a Prometheus object
+// can list and watch the Nodes, which is required by Prometheus to attach
+// the node metadata to the discovered targets. The access is reviewed at
+// most once.
+type nodeAccessReview struct {
+	kclient kubernetes.Interface
+	logger  log.Logger
+	p       *monitoringv1.Prometheus
+
+	done bool
+	err  error
+}
+
+func newNodeAccessReview(kclient kubernetes.Interface, logger log.Logger, p *monitoringv1.Prometheus) *nodeAccessReview {
+	return &nodeAccessReview{kclient: kclient, logger: logger, p: p}
+}
+
+// check returns an error if the node metadata is attached while the
+// ServiceAccount of Prometheus isn't allowed to list and watch the Nodes.
+func (r *nodeAccessReview) check(ctx context.Context, am *monitoringv1.AttachMetadata) error {
+	if am == nil || !am.Node {
+		return nil
+	}
+
+	if !r.done {
+		r.err = r.review(ctx)
+		r.done = true
+	}
+
+	return r.err
+}
+
+func (r *nodeAccessReview) review(ctx context.Context) error {
+	sa := serviceAccountName(r.p)
+	if sa == "" {
+		sa = "default"
+	}
+
+	for _, verb := range []string{"list", "watch"} {
+		sar := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User: fmt.Sprintf("system:serviceaccount:%s:%s", r.p.Namespace, sa),
+				Groups: []string{
+					"system:serviceaccounts",
+					"system:serviceaccounts:" + r.p.Namespace,
+					"system:authenticated",
+				},
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:     verb,
+					Resource: "nodes",
+				},
+			},
+		}
+
+		res, err := r.kclient.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+		if err != nil {
+			// The operator might not be allowed to review the access, in
+			// which case the check is skipped.
+			level.Debug(r.logger).Log("msg", "reviewing the access to the nodes failed, skipping the check", "err", err, "namespace", r.p.Namespace, "prometheus", r.p.Name)
+			return nil
+		}
+
+		if !res.Status.Allowed {
+			return errors.Errorf("attachMetadata.node requires the %q ServiceAccount to %s nodes", sa, verb)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+)
+
+func TestNodeAccessReview(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "monitoring",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			ServiceAccountName: "prometheus",
+		},
+	}
+
+	for _, tc := range []struct {
+		name           string
+		attachMetadata *monitoringv1.AttachMetadata
+		allowed        bool
+		reviews        int
+		err            bool
+	}{
+		{
+			name:    "no attachMetadata",
+			reviews: 0,
+		},
+		{
+			name:           "node metadata disabled",
+			attachMetadata: &monitoringv1.AttachMetadata{},
+			reviews:        0,
+		},
+		{
+			name:           "allowed",
+			attachMetadata: &monitoringv1.AttachMetadata{Node: true},
+			allowed:        true,
+			reviews:        2,
+		},
+		{
+			name:           "denied",
+			attachMetadata: &monitoringv1.AttachMetadata{Node: true},
+			reviews:        1,
+			err:            true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := fake.NewSimpleClientset()
+
+			var reviews int
+			c.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				reviews++
+				sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				if sar.Spec.User != "system:serviceaccount:monitoring:prometheus" {
+					t.Fatalf("unexpected user %q", sar.Spec.User)
+				}
+				sar.Status.Allowed = tc.allowed
+				return true, sar, nil
+			})
+
+			r := newNodeAccessReview(c, log.NewNopLogger(), p)
+			// The second check must reuse the result of the first one.
+			for i := 0; i < 2; i++ {
+				err := r.check(context.Background(), tc.attachMetadata)
+				if tc.err && err == nil {
+					t.Fatal("expected an error")
+				}
+				if !tc.err && err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if reviews != tc.reviews {
+				t.Fatalf("expected %d access reviews, got %d", tc.reviews, reviews)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// objStoreRequiredKeys maps the object storage providers supported by Thanos
+// to the configuration keys that they require.
+var objStoreRequiredKeys = map[string][]string{
+	"GCS":        {"bucket"},
+	"S3":         {"bucket", "endpoint"},
+	"AZURE":      {"storage_account", "container"},
+	"SWIFT":      {"container_name"},
+	"COS":        {"bucket"},
+	"ALIYUNOSS":  {"endpoint", "bucket"},
+	"FILESYSTEM": {"directory"},
+	"BOS":        {"bucket", "endpoint"},
+}
+
+// validateObjectStorageConfig checks that the given Thanos object storage
+// configuration has a known provider type and defines the keys required by
+// this provider.
+func validateObjectStorageConfig(content string) error {
+	var cfg struct {
+		Type   string                 `yaml:"type"`
+		Config map[string]interface{} `yaml:"config"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &cfg); err != nil {
+		return errors.Wrap(err, "failed to parse object storage configuration")
+	}
+
+	if cfg.Type == "" {
+		return errors.New("object storage type is missing")
+	}
+
+	required, found := objStoreRequiredKeys[strings.ToUpper(cfg.Type)]
+	if !found {
+		providers := make([]string, 0, len(objStoreRequiredKeys))
+		for p := range objStoreRequiredKeys {
+			providers = append(providers, p)
+		}
+		sort.Strings(providers)
+		return errors.Errorf("unknown object storage type %q, expected one of %s", cfg.Type, strings.Join(providers, ", "))
+	}
+
+	for _, k := range required {
+		if v, found := cfg.Config[k]; !found || v == nil || v == "" {
+			return errors.Errorf("object storage type %q requires the %q key", cfg.Type, k)
+		}
+	}
+
+	return nil
+}
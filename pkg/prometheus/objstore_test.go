@@ -0,0 +1,154 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateObjectStorageConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     bool
+	}{
+		{
+			name: "valid s3",
+			content: `type: S3
+config:
+  bucket: thanos
+  endpoint: s3.amazonaws.com
+`,
+		},
+		{
+			name: "lower case type",
+			content: `type: gcs
+config:
+  bucket: thanos
+`,
+		},
+		{
+			name:    "invalid yaml",
+			content: `type: [S3`,
+			err:     true,
+		},
+		{
+			name: "missing type",
+			content: `config:
+  bucket: thanos
+`,
+			err: true,
+		},
+		{
+			name: "unknown type",
+			content: `type: FOO
+config:
+  bucket: thanos
+`,
+			err: true,
+		},
+		{
+			name: "missing required key",
+			content: `type: S3
+config:
+  bucket: thanos
+`,
+			err: true,
+		},
+		{
+			name: "empty required key",
+			content: `type: FILESYSTEM
+config:
+  directory: ""
+`,
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateObjectStorageConfig(tc.content)
+			if tc.err && err == nil {
+				t.Fatal("expecting error, got no error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+		})
+	}
+}
+
+func TestCheckObjectStorageConfig(t *testing.T) {
+	c := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "thanos-objstore",
+				Namespace: "ns1",
+			},
+			Data: map[string][]byte{
+				"objstore.yml": []byte("type: S3\nconfig:\n  bucket: thanos\n"),
+			},
+		},
+	)
+
+	for _, tc := range []struct {
+		name       string
+		validation string
+		err        bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:       "none",
+			validation: monitoringv1.ObjectStorageConfigValidationNone,
+		},
+		{
+			name:       "strict",
+			validation: monitoringv1.ObjectStorageConfigValidationStrict,
+			err:        true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "ns1",
+				},
+				Spec: monitoringv1.PrometheusSpec{
+					Thanos: &monitoringv1.ThanosSpec{
+						ObjectStorageConfig: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "thanos-objstore"},
+							Key:                  "objstore.yml",
+						},
+						ObjectStorageConfigValidation: tc.validation,
+					},
+				},
+			}
+
+			err := checkObjectStorageConfig(context.Background(), p, newAssetStore(c.CoreV1(), c.CoreV1()))
+			if tc.err && err == nil {
+				t.Fatal("expecting error, got no error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("expecting no error, got %q", err)
+			}
+		})
+	}
+}
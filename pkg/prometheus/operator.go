@@ -19,16 +19,21 @@ import (
 	"compress/gzip"
 	"context"
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
 	"strings"
 	"time"
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	monitoringclient "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned"
+	monitoringscheme "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/scheme"
+	"github.com/prometheus-operator/prometheus-operator/pkg/datasource"
 	"github.com/prometheus-operator/prometheus-operator/pkg/informers"
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/listwatch"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -39,6 +44,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
@@ -46,13 +52,10 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
-const (
-	resyncPeriod = 5 * time.Minute
-)
-
 // Operator manages life cycle of Prometheus deployments and
 // monitoring configurations.
 type Operator struct {
@@ -63,16 +66,24 @@ type Operator struct {
 	nsPromInf cache.SharedIndexInformer
 	nsMonInf  cache.SharedIndexInformer
 
+	// nsWatcher updates the namespaces of the informers when a namespace
+	// selector is configured.
+	nsWatcher *operator.NamespaceWatcher
+
 	promInfs  *informers.ForResource
+	agentInfs *informers.ForResource
 	smonInfs  *informers.ForResource
 	pmonInfs  *informers.ForResource
 	probeInfs *informers.ForResource
+	nmonInfs  *informers.ForResource
 	ruleInfs  *informers.ForResource
 	cmapInfs  *informers.ForResource
 	secrInfs  *informers.ForResource
 	ssetInfs  *informers.ForResource
 
 	queue workqueue.RateLimitingInterface
+	// agentQueue holds the keys of the PrometheusAgent objects.
+	agentQueue workqueue.RateLimitingInterface
 
 	metrics *operator.Metrics
 
@@ -87,6 +98,13 @@ type Operator struct {
 	config                 Config
 
 	configGenerator *configGenerator
+	targetsChecker  *targetsChecker
+	recommender     *resourceRecommender
+	httpReloader    *httpReloader
+	remoteRules     *remoteRulesFetcher
+	// eventRecorder records the Events of the Prometheus and monitoring
+	// objects.
+	eventRecorder record.EventRecorder
 }
 
 type Labels struct {
@@ -146,6 +164,7 @@ type Config struct {
 	ThanosDefaultBaseImage        string
 	Namespaces                    Namespaces
 	Labels                        Labels
+	DefaultExternalLabels         Labels
 	LocalHost                     string
 	LogLevel                      string
 	LogFormat                     string
@@ -153,6 +172,34 @@ type Config struct {
 	AlertManagerSelector          string
 	ThanosRulerSelector           string
 	SecretListWatchSelector       string
+	// TargetsCheckInterval is the interval at which the targets of the
+	// Prometheus instances are checked. Zero disables the checks.
+	TargetsCheckInterval time.Duration
+	// AutoResourcesInterval is the interval at which the memory requests of
+	// the Prometheus instances with autoResources are recomputed. Zero
+	// disables the recommendations.
+	AutoResourcesInterval time.Duration
+	// ServiceDiscoveryRole is the discovery role of the ServiceMonitors
+	// which don't define one.
+	ServiceDiscoveryRole string
+	// GCInterval is the interval at which the generated Secrets and
+	// ConfigMaps without owner are garbage collected. Zero disables the
+	// garbage collection.
+	GCInterval time.Duration
+	// EnableConfigDiff enables the logging of the changes of the generated
+	// configurations.
+	EnableConfigDiff bool
+	// Mode is either operator.ModeFull or operator.ModeConfigOnly. In
+	// config-only mode, the controllers generate the configuration Secrets
+	// and ConfigMaps but no governing Service, ServiceAccount or
+	// StatefulSet.
+	Mode string
+	// PrometheusController, AlertmanagerController and
+	// ThanosRulerController define the resync periods and the work queues
+	// of the controllers.
+	PrometheusController   operator.ControllerConfig
+	AlertmanagerController operator.ControllerConfig
+	ThanosRulerController  operator.ControllerConfig
 }
 
 type Namespaces struct {
@@ -160,6 +207,10 @@ type Namespaces struct {
 	AllowList, DenyList map[string]struct{}
 	// allow list for prometheus/alertmanager custom resources
 	PrometheusAllowList, AlertmanagerAllowList, ThanosRulerAllowList map[string]struct{}
+	// Selector is a label selector of the Namespace objects. When set, the
+	// allow lists watching all namespaces follow the matching namespaces
+	// instead.
+	Selector string
 }
 
 // BasicAuthCredentials represents a username password pair to be used with
@@ -169,6 +220,20 @@ type BasicAuthCredentials struct {
 	password string
 }
 
+// SigV4Credentials represents the AWS access and secret keys used for
+// Signature Verification 4.
+type SigV4Credentials struct {
+	accessKeyID string
+	secretKeyID string
+}
+
+// OAuth2Credentials represents the client id and secret used for OAuth2
+// authentication.
+type OAuth2Credentials struct {
+	clientID     string
+	clientSecret string
+}
+
 // BearerToken represents a bearer token, see
 // https://tools.ietf.org/html/rfc6750.
 type BearerToken string
@@ -209,6 +274,11 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 
 	kubeletObjectName := ""
 	kubeletObjectNamespace := ""
+	if err := conf.PrometheusController.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid controller configuration")
+	}
+	resyncPeriod := conf.PrometheusController.ResyncPeriod
+
 	kubeletSyncEnabled := false
 
 	if conf.KubeletObject != "" {
@@ -225,13 +295,14 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 		kclient:                client,
 		mclient:                mclient,
 		logger:                 logger,
-		queue:                  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "prometheus"),
+		queue:                  conf.PrometheusController.NewRateLimitingQueue("prometheus"),
+		agentQueue:             conf.PrometheusController.NewRateLimitingQueue("prometheusagent"),
 		host:                   cfg.Host,
 		kubeletObjectName:      kubeletObjectName,
 		kubeletObjectNamespace: kubeletObjectNamespace,
 		kubeletSyncEnabled:     kubeletSyncEnabled,
 		config:                 conf,
-		configGenerator:        newConfigGenerator(logger),
+		configGenerator:        newConfigGenerator(logger, conf.DefaultExternalLabels.LabelsMap),
 		metrics:                operator.NewMetrics("prometheus", r),
 		nodeAddressLookupErrors: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "prometheus_operator_node_address_lookup_errors_total",
@@ -248,9 +319,26 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 	}
 	c.metrics.MustRegister(c.nodeAddressLookupErrors, c.nodeEndpointSyncs, c.nodeEndpointSyncErrors)
 
+	c.eventRecorder = newEventRecorder(client, monitoringscheme.Scheme)
+	c.httpReloader = newHTTPReloader(logger, client, conf.ClusterDomain)
+	c.remoteRules = newRemoteRulesFetcher(logger)
+	if conf.TargetsCheckInterval > 0 {
+		c.targetsChecker = newTargetsChecker(logger, client, c.eventRecorder, conf.ClusterDomain)
+	}
+	if conf.AutoResourcesInterval > 0 {
+		c.recommender = newResourceRecommender(logger, client, conf.ClusterDomain)
+	}
+
+	if conf.Namespaces.Selector != "" {
+		c.nsWatcher, err = operator.NewNamespaceWatcher(logger, client, conf.Namespaces.Selector, resyncPeriod, c.enqueueAll)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	c.promInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			c.config.Namespaces.PrometheusAllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.PrometheusAllowList),
 			c.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -270,9 +358,25 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 	}
 	c.metrics.MustRegister(NewPrometheusCollectorForStores(promStores...))
 
+	c.agentInfs, err = informers.NewInformersForResource(
+		informers.NewMonitoringInformerFactories(
+			c.nsWatcher.AllowList(c.config.Namespaces.PrometheusAllowList),
+			c.config.Namespaces.DenyList,
+			mclient,
+			resyncPeriod,
+			func(options *metav1.ListOptions) {
+				options.LabelSelector = c.config.PromSelector
+			},
+		),
+		monitoringv1.SchemeGroupVersion.WithResource(monitoringv1.PrometheusAgentName),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating prometheusagent informers")
+	}
+
 	c.smonInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			c.config.Namespaces.AllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.AllowList),
 			c.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -286,7 +390,7 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 
 	c.pmonInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			c.config.Namespaces.AllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.AllowList),
 			c.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -300,7 +404,7 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 
 	c.probeInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			c.config.Namespaces.AllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.AllowList),
 			c.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -312,9 +416,23 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 		return nil, errors.Wrap(err, "error creating probe informers")
 	}
 
+	c.nmonInfs, err = informers.NewInformersForResource(
+		informers.NewMonitoringInformerFactories(
+			c.nsWatcher.AllowList(c.config.Namespaces.AllowList),
+			c.config.Namespaces.DenyList,
+			mclient,
+			resyncPeriod,
+			nil,
+		),
+		monitoringv1.SchemeGroupVersion.WithResource(monitoringv1.NodeMonitorName),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating nodemonitor informers")
+	}
+
 	c.ruleInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			c.config.Namespaces.AllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.AllowList),
 			c.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -328,7 +446,7 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 
 	c.cmapInfs, err = informers.NewInformersForResource(
 		informers.NewKubeInformerFactories(
-			c.config.Namespaces.AllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.AllowList),
 			c.config.Namespaces.DenyList,
 			c.kclient,
 			resyncPeriod,
@@ -344,7 +462,7 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 
 	c.secrInfs, err = informers.NewInformersForResource(
 		informers.NewKubeInformerFactories(
-			c.config.Namespaces.PrometheusAllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.PrometheusAllowList),
 			c.config.Namespaces.DenyList,
 			c.kclient,
 			resyncPeriod,
@@ -360,7 +478,7 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 
 	c.ssetInfs, err = informers.NewInformersForResource(
 		informers.NewKubeInformerFactories(
-			c.config.Namespaces.PrometheusAllowList,
+			c.nsWatcher.AllowList(c.config.Namespaces.PrometheusAllowList),
 			c.config.Namespaces.DenyList,
 			c.kclient,
 			resyncPeriod,
@@ -372,6 +490,9 @@ func New(ctx context.Context, conf Config, logger log.Logger, r prometheus.Regis
 		return nil, errors.Wrap(err, "error creating statefulset informers")
 	}
 
+	c.nsWatcher.Watch(c.config.Namespaces.PrometheusAllowList, c.promInfs, c.agentInfs, c.secrInfs, c.ssetInfs)
+	c.nsWatcher.Watch(c.config.Namespaces.AllowList, c.smonInfs, c.pmonInfs, c.probeInfs, c.nmonInfs, c.ruleInfs, c.cmapInfs)
+
 	newNamespaceInformer := func(o *Operator, allowList map[string]struct{}) cache.SharedIndexInformer {
 		// nsResyncPeriod is used to control how often the namespace informer
 		// should resync. If the unprivileged ListerWatcher is used, then the
@@ -412,10 +533,12 @@ func (c *Operator) waitForCacheSync(ctx context.Context) error {
 		informersForResource *informers.ForResource
 	}{
 		{"Prometheus", c.promInfs},
+		{"PrometheusAgent", c.agentInfs},
 		{"ServiceMonitor", c.smonInfs},
 		{"PodMonitor", c.pmonInfs},
 		{"PrometheusRule", c.ruleInfs},
 		{"Probe", c.probeInfs},
+		{"NodeMonitor", c.nmonInfs},
 		{"ConfigMap", c.cmapInfs},
 		{"Secret", c.secrInfs},
 		{"StatefulSet", c.ssetInfs},
@@ -460,6 +583,12 @@ func (c *Operator) addHandlers() {
 		UpdateFunc: c.handlePrometheusUpdate,
 	})
 
+	c.agentInfs.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handlePrometheusAgentAdd,
+		DeleteFunc: c.handlePrometheusAgentDelete,
+		UpdateFunc: c.handlePrometheusAgentUpdate,
+	})
+
 	c.smonInfs.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.handleSmonAdd,
 		DeleteFunc: c.handleSmonDelete,
@@ -476,6 +605,11 @@ func (c *Operator) addHandlers() {
 		UpdateFunc: c.handleBmonUpdate,
 		DeleteFunc: c.handleBmonDelete,
 	})
+	c.nmonInfs.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.handleNmonAdd,
+		UpdateFunc: c.handleNmonUpdate,
+		DeleteFunc: c.handleNmonDelete,
+	})
 	c.ruleInfs.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.handleRuleAdd,
 		DeleteFunc: c.handleRuleDelete,
@@ -496,11 +630,45 @@ func (c *Operator) addHandlers() {
 		DeleteFunc: c.handleStatefulSetDelete,
 		UpdateFunc: c.handleStatefulSetUpdate,
 	})
+	// The scrape limits of the monitoring objects depend on the annotations
+	// of their namespace.
+	c.nsMonInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.handleMonitorNamespaceUpdate,
+	})
+}
+
+// configureServiceDiscoveryRole sets the default discovery role of the
+// ServiceMonitors and detects whether the cluster serves the EndpointSlice
+// API. It must be called before the workers are started.
+func (c *Operator) configureServiceDiscoveryRole() error {
+	if c.config.ServiceDiscoveryRole != "" {
+		c.configGenerator.serviceDiscoveryRole = monitoringv1.ServiceDiscoveryRole(c.config.ServiceDiscoveryRole)
+	}
+
+	supported := false
+	for _, gv := range []string{"discovery.k8s.io/v1", "discovery.k8s.io/v1beta1"} {
+		ok, err := k8sutil.IsAPIGroupVersionResourceSupported(c.kclient.Discovery(), gv, "endpointslices")
+		if err != nil {
+			return errors.Wrap(err, "failed to check the EndpointSlice API")
+		}
+		if ok {
+			supported = true
+			break
+		}
+	}
+	c.configGenerator.endpointSliceSupported = supported
+
+	if !supported && c.configGenerator.serviceDiscoveryRole == monitoringv1.EndpointSliceRole {
+		level.Warn(c.logger).Log("msg", "the EndpointSlice API isn't available, falling back to the Endpoints service discovery role")
+	}
+
+	return nil
 }
 
 // Run the controller.
 func (c *Operator) Run(ctx context.Context) error {
 	defer c.queue.ShutDown()
+	defer c.agentQueue.ShutDown()
 
 	errChan := make(chan error)
 	go func() {
@@ -523,12 +691,25 @@ func (c *Operator) Run(ctx context.Context) error {
 		return nil
 	}
 
-	go c.worker(ctx)
+	if err := c.configureServiceDiscoveryRole(); err != nil {
+		return err
+	}
+
+	for i := 0; i < c.config.PrometheusController.Workers; i++ {
+		go c.worker(ctx)
+		go c.agentWorker(ctx)
+	}
+
+	if err := c.nsWatcher.Init(ctx); err != nil {
+		return err
+	}
 
 	go c.promInfs.Start(ctx.Done())
+	go c.agentInfs.Start(ctx.Done())
 	go c.smonInfs.Start(ctx.Done())
 	go c.pmonInfs.Start(ctx.Done())
 	go c.probeInfs.Start(ctx.Done())
+	go c.nmonInfs.Start(ctx.Done())
 	go c.ruleInfs.Start(ctx.Done())
 	go c.cmapInfs.Start(ctx.Done())
 	go c.secrInfs.Start(ctx.Done())
@@ -541,15 +722,76 @@ func (c *Operator) Run(ctx context.Context) error {
 		return err
 	}
 	c.addHandlers()
+	go c.nsWatcher.Run(ctx)
 
 	if c.kubeletSyncEnabled {
 		go c.reconcileNodeEndpoints(ctx)
 	}
 
+	if c.targetsChecker != nil {
+		go c.targetsChecker.Run(ctx, c.config.TargetsCheckInterval, c.listPrometheuses)
+	}
+
+	if c.recommender != nil {
+		go c.recommender.Run(ctx, c.config.AutoResourcesInterval, c.listPrometheuses, c.enqueue)
+	}
+
+	if c.config.GCInterval > 0 {
+		go c.orphanCollector().Run(ctx, c.config.GCInterval)
+	}
+
 	<-ctx.Done()
 	return nil
 }
 
+// orphanCollector returns the garbage collector of the Secrets and
+// ConfigMaps generated for Prometheus and PrometheusAgent resources which
+// don't exist anymore.
+func (c *Operator) orphanCollector() *operator.OrphanCollector {
+	return &operator.OrphanCollector{
+		Logger:           log.With(c.logger, "component", "gc"),
+		KubeClient:       c.kclient,
+		Metrics:          c.metrics,
+		AllowList:        c.config.Namespaces.PrometheusAllowList,
+		DenyList:         c.config.Namespaces.DenyList,
+		NamespaceWatcher: c.nsWatcher,
+		Owners: []operator.OrphanOwner{
+			{
+				Kind:  monitoringv1.PrometheusesKind,
+				Label: labelPrometheusName,
+				Exists: func(ctx context.Context, namespace, name string) (bool, error) {
+					_, err := c.mclient.MonitoringV1().Prometheuses(namespace).Get(ctx, name, metav1.GetOptions{})
+					if apierrors.IsNotFound(err) {
+						return false, nil
+					}
+					return err == nil, err
+				},
+			},
+			{
+				Kind:  monitoringv1.PrometheusAgentsKind,
+				Label: labelPrometheusAgentName,
+				Exists: func(ctx context.Context, namespace, name string) (bool, error) {
+					_, err := c.mclient.MonitoringV1().PrometheusAgents(namespace).Get(ctx, name, metav1.GetOptions{})
+					if apierrors.IsNotFound(err) {
+						return false, nil
+					}
+					return err == nil, err
+				},
+			},
+		},
+	}
+}
+
+// volumeResizer returns the resizer of the volumes of the Prometheus and
+// PrometheusAgent StatefulSets.
+func (c *Operator) volumeResizer() *operator.VolumeResizer {
+	return &operator.VolumeResizer{
+		Logger:     c.logger,
+		KubeClient: c.kclient,
+		Metrics:    c.metrics,
+	}
+}
+
 func (c *Operator) keyFunc(obj interface{}) (string, bool) {
 	k, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
@@ -612,6 +854,37 @@ func (c *Operator) reconcileNodeEndpoints(ctx context.Context) {
 	}
 }
 
+// listPrometheuses returns the Prometheus objects from the cache.
+func (c *Operator) listPrometheuses() []*monitoringv1.Prometheus {
+	objs, err := c.promInfs.List(labels.Everything())
+	if err != nil {
+		level.Error(c.logger).Log("msg", "listing all Prometheus instances from cache failed", "err", err)
+		return nil
+	}
+
+	res := make([]*monitoringv1.Prometheus, 0, len(objs))
+	for _, obj := range objs {
+		res = append(res, obj.(*monitoringv1.Prometheus))
+	}
+	return res
+}
+
+// enqueueAll enqueues all the Prometheus and PrometheusAgent objects.
+func (c *Operator) enqueueAll() {
+	for _, p := range c.listPrometheuses() {
+		c.enqueue(p)
+	}
+
+	objs, err := c.agentInfs.List(labels.Everything())
+	if err != nil {
+		level.Error(c.logger).Log("msg", "listing all PrometheusAgent instances from cache failed", "err", err)
+		return
+	}
+	for _, obj := range objs {
+		c.enqueueAgent(obj)
+	}
+}
+
 // nodeAddresses returns the provided node's address, based on the priority:
 // 1. NodeInternalIP
 // 2. NodeExternalIP
@@ -851,6 +1124,54 @@ func (c *Operator) handleBmonDelete(obj interface{}) {
 	}
 }
 
+// TODO: Don't enque just for the namespace
+func (c *Operator) handleNmonAdd(obj interface{}) {
+	if o, ok := c.getObject(obj); ok {
+		level.Debug(c.logger).Log("msg", "NodeMonitor added")
+		c.metrics.TriggerByCounter(monitoringv1.NodeMonitorsKind, "add").Inc()
+		c.enqueueForMonitorNamespace(o.GetNamespace())
+	}
+}
+
+// TODO: Don't enque just for the namespace
+func (c *Operator) handleNmonUpdate(old, cur interface{}) {
+	if old.(*monitoringv1.NodeMonitor).ResourceVersion == cur.(*monitoringv1.NodeMonitor).ResourceVersion {
+		return
+	}
+
+	if o, ok := c.getObject(cur); ok {
+		level.Debug(c.logger).Log("msg", "NodeMonitor updated")
+		c.metrics.TriggerByCounter(monitoringv1.NodeMonitorsKind, "update").Inc()
+		c.enqueueForMonitorNamespace(o.GetNamespace())
+	}
+}
+
+// TODO: Don't enque just for the namespace
+func (c *Operator) handleNmonDelete(obj interface{}) {
+	if o, ok := c.getObject(obj); ok {
+		level.Debug(c.logger).Log("msg", "NodeMonitor delete")
+		c.metrics.TriggerByCounter(monitoringv1.NodeMonitorsKind, "delete").Inc()
+		c.enqueueForMonitorNamespace(o.GetNamespace())
+	}
+}
+
+// handleMonitorNamespaceUpdate enqueues the Prometheus instances selecting
+// monitoring objects in the namespace when its scrape limits change.
+func (c *Operator) handleMonitorNamespaceUpdate(old, cur interface{}) {
+	oldNs, ok := old.(*v1.Namespace)
+	if !ok {
+		return
+	}
+	curNs, ok := cur.(*v1.Namespace)
+	if !ok || !namespaceLimitsChanged(oldNs, curNs) {
+		return
+	}
+
+	level.Debug(c.logger).Log("msg", "Namespace scrape limits updated", "namespace", curNs.Name)
+	c.metrics.TriggerByCounter("Namespace", "update").Inc()
+	c.enqueueForMonitorNamespace(curNs.Name)
+}
+
 // TODO: Don't enque just for the namespace
 func (c *Operator) handleRuleAdd(obj interface{}) {
 	o, ok := c.getObject(obj)
@@ -864,7 +1185,13 @@ func (c *Operator) handleRuleAdd(obj interface{}) {
 
 // TODO: Don't enque just for the namespace
 func (c *Operator) handleRuleUpdate(old, cur interface{}) {
-	if old.(*monitoringv1.PrometheusRule).ResourceVersion == cur.(*monitoringv1.PrometheusRule).ResourceVersion {
+	oldRule, curRule := old.(*monitoringv1.PrometheusRule), cur.(*monitoringv1.PrometheusRule)
+	if oldRule.ResourceVersion == curRule.ResourceVersion {
+		return
+	}
+
+	// The status updated by the operator doesn't change the rule files.
+	if oldRule.Generation == curRule.Generation && reflect.DeepEqual(oldRule.Labels, curRule.Labels) && !reflect.DeepEqual(oldRule.Status, curRule.Status) {
 		return
 	}
 
@@ -992,10 +1319,12 @@ func (c *Operator) enqueue(obj interface{}) {
 
 func (c *Operator) enqueueForPrometheusNamespace(nsName string) {
 	c.enqueueForNamespace(c.nsPromInf.GetStore(), nsName)
+	c.enqueueAgentsForNamespace(c.nsPromInf.GetStore(), nsName)
 }
 
 func (c *Operator) enqueueForMonitorNamespace(nsName string) {
 	c.enqueueForNamespace(c.nsMonInf.GetStore(), nsName)
+	c.enqueueAgentsForNamespace(c.nsMonInf.GetStore(), nsName)
 }
 
 // enqueueForNamespace enqueues all Prometheus object keys that belong to the
@@ -1080,6 +1409,21 @@ func (c *Operator) enqueueForNamespace(store cache.Store, nsName string) {
 			return
 		}
 
+		// Check for Prometheus instances selecting NodeMonitors in the NS.
+		nmNSSelector, err := metav1.LabelSelectorAsSelector(p.Spec.NodeMonitorNamespaceSelector)
+		if err != nil {
+			level.Error(c.logger).Log(
+				"msg", fmt.Sprintf("failed to convert NodeMonitorNamespaceSelector of %q to selector", p.Name),
+				"err", err,
+			)
+			return
+		}
+
+		if nmNSSelector.Matches(labels.Set(ns.Labels)) {
+			c.enqueue(p)
+			return
+		}
+
 		// Check for Prometheus instances selecting PrometheusRules in
 		// the NS.
 		ruleNSSelector, err := metav1.LabelSelectorAsSelector(p.Spec.RuleNamespaceSelector)
@@ -1114,7 +1458,9 @@ func (c *Operator) processNextWorkItem(ctx context.Context) bool {
 	defer c.queue.Done(key)
 
 	c.metrics.ReconcileCounter().Inc()
+	start := time.Now()
 	err := c.sync(ctx, key.(string))
+	c.metrics.ObserveReconcile(time.Since(start), err)
 	if err == nil {
 		c.queue.Forget(key)
 		return true
@@ -1172,6 +1518,14 @@ func (c *Operator) handleStatefulSetDelete(obj interface{}) {
 		c.metrics.TriggerByCounter("StatefulSet", "delete").Inc()
 
 		c.enqueue(ps)
+		return
+	}
+
+	if a := c.agentForStatefulSet(obj); a != nil {
+		level.Debug(c.logger).Log("msg", "StatefulSet delete")
+		c.metrics.TriggerByCounter("StatefulSet", "delete").Inc()
+
+		c.enqueueAgent(a)
 	}
 }
 
@@ -1181,6 +1535,14 @@ func (c *Operator) handleStatefulSetAdd(obj interface{}) {
 		c.metrics.TriggerByCounter("StatefulSet", "add").Inc()
 
 		c.enqueue(ps)
+		return
+	}
+
+	if a := c.agentForStatefulSet(obj); a != nil {
+		level.Debug(c.logger).Log("msg", "StatefulSet added")
+		c.metrics.TriggerByCounter("StatefulSet", "add").Inc()
+
+		c.enqueueAgent(a)
 	}
 }
 
@@ -1202,6 +1564,14 @@ func (c *Operator) handleStatefulSetUpdate(oldo, curo interface{}) {
 		c.metrics.TriggerByCounter("StatefulSet", "update").Inc()
 
 		c.enqueue(ps)
+		return
+	}
+
+	if a := c.agentForStatefulSet(cur); a != nil {
+		level.Debug(c.logger).Log("msg", "StatefulSet updated")
+		c.metrics.TriggerByCounter("StatefulSet", "update").Inc()
+
+		c.enqueueAgent(a)
 	}
 }
 
@@ -1209,6 +1579,17 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 	pobj, err := c.promInfs.Get(key)
 
 	if apierrors.IsNotFound(err) {
+		if c.targetsChecker != nil {
+			c.targetsChecker.forget(key)
+		}
+		if c.recommender != nil {
+			c.recommender.forget(key)
+		}
+		c.httpReloader.forget(key)
+		c.remoteRules.forget(key)
+		if ns, name, err := cache.SplitMetaNamespaceKey(key); err == nil {
+			c.pruneRuleStatus(ctx, ns, name, nil)
+		}
 		// Dependent resources are cleaned up by K8s via OwnerReferences
 		return nil
 	}
@@ -1226,27 +1607,141 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 	}
 
 	level.Info(c.logger).Log("msg", "sync prometheus", "key", key)
+
+	res := syncResult{configErr: errConfigNotGenerated, remoteRulesErr: errRemoteRulesNotSynced}
+	err = c.syncPrometheus(ctx, key, p, &res)
+	if res.requeue > 0 {
+		c.queue.AddAfter(key, res.requeue)
+	}
+	if err == nil {
+		err = c.reloadPrometheus(ctx, key, p)
+	}
+	if sErr := c.updateStatus(ctx, p, res, err); sErr != nil {
+		level.Warn(c.logger).Log("msg", "failed to update prometheus status", "key", key, "err", sErr)
+	}
+
+	return err
+}
+
+// reloadPrometheus reloads the Prometheus Pods through their HTTP API when
+// the config reloader sidecars don't run.
+func (c *Operator) reloadPrometheus(ctx context.Context, key string, p *monitoringv1.Prometheus) error {
+	if !httpReload(p) {
+		c.httpReloader.forget(key)
+		return nil
+	}
+
+	requeue, err := c.httpReloader.sync(ctx, key, p)
+	if err != nil {
+		return errors.Wrap(err, "reloading Prometheus failed")
+	}
+	if requeue > 0 {
+		c.queue.AddAfter(key, requeue)
+	}
+
+	return nil
+}
+
+// errConfigNotGenerated is the configuration error of a reconciliation which
+// failed before generating the configuration.
+var errConfigNotGenerated = errors.New("configuration not generated")
+
+// errRemoteRulesNotSynced is the remote rule files error of a reconciliation
+// which failed before fetching them.
+var errRemoteRulesNotSynced = errors.New("remote rule files not synced")
+
+// syncResult is the outcome of the reconciliation of a Prometheus object
+// reported in its status.
+type syncResult struct {
+	// configErr is the error of the configuration generation.
+	configErr error
+	// remoteRulesErr lists the remote rule files which couldn't be fetched
+	// or are invalid.
+	remoteRulesErr error
+	// requeue is the delay after which the object has to be reconciled
+	// again, zero if none.
+	requeue time.Duration
+	// inputHash is the input hash of the applied StatefulSet.
+	inputHash string
+}
+
+// syncPrometheus ensures that the configuration, the governing service and
+// the StatefulSet of the Prometheus object are up to date.
+func (c *Operator) syncPrometheus(ctx context.Context, key string, p *monitoringv1.Prometheus, res *syncResult) error {
+	if err := checkWeb(p); err != nil {
+		return errors.Wrap(err, "invalid web settings")
+	}
+
+	if err := checkReloadStrategy(p); err != nil {
+		return errors.Wrap(err, "invalid reload strategy")
+	}
+
+	if err := checkAutoResources(p); err != nil {
+		return errors.Wrap(err, "invalid autoResources")
+	}
+
+	if err := checkRemoteRuleFiles(p); err != nil {
+		return errors.Wrap(err, "invalid remoteRuleFiles")
+	}
+
 	ruleConfigMapNames, err := c.createOrUpdateRuleConfigMaps(ctx, p)
 	if err != nil {
 		return err
 	}
 
+	if err := c.createOrUpdateRemoteRulesConfigMap(ctx, key, p, res); err != nil {
+		return errors.Wrap(err, "synchronizing remote rule files failed")
+	}
+
 	assetStore := newAssetStore(c.kclient.CoreV1(), c.kclient.CoreV1())
 
-	if err := c.createOrUpdateConfigurationSecret(ctx, p, ruleConfigMapNames, assetStore); err != nil {
-		return errors.Wrap(err, "creating config failed")
+	var mons selectedMonitors
+	mons, res.configErr = c.createOrUpdateConfigurationSecret(ctx, p, ruleConfigMapNames, assetStore)
+	if res.configErr != nil {
+		return errors.Wrap(res.configErr, "creating config failed")
 	}
 
-	if err := c.createOrUpdateTLSAssetSecret(ctx, p, assetStore); err != nil {
+	if err := c.createOrUpdateTLSAssetSecret(ctx, p, makeTLSAssetSecret(p, c.config), assetStore); err != nil {
 		return errors.Wrap(err, "creating tls asset secret failed")
 	}
 
+	if err := c.createOrUpdateWebConfigSecret(ctx, p); err != nil {
+		return errors.Wrap(err, "synchronizing web config secret failed")
+	}
+
+	if err := c.createOrUpdateNetworkPolicy(ctx, p, mons); err != nil {
+		return errors.Wrap(err, "synchronizing network policy failed")
+	}
+
+	if err := c.createOrUpdateDatasourceConfigMap(ctx, p); err != nil {
+		return errors.Wrap(err, "synchronizing datasource configmap failed")
+	}
+
+	if err := checkObjectStorageConfig(ctx, p, assetStore); err != nil {
+		return errors.Wrap(err, "invalid thanos object storage configuration")
+	}
+
+	if err := checkThanosBlockDurations(p); err != nil {
+		return errors.Wrap(err, "invalid thanos block durations")
+	}
+
+	if c.config.Mode == operator.ModeConfigOnly {
+		return nil
+	}
+
 	// Create governing service if it doesn't exist.
 	svcClient := c.kclient.CoreV1().Services(p.Namespace)
 	if err := k8sutil.CreateOrUpdateService(ctx, svcClient, makeStatefulSetService(p, c.config)); err != nil {
 		return errors.Wrap(err, "synchronizing governing service failed")
 	}
 
+	if p.Spec.ManageServiceAccount {
+		saClient := c.kclient.CoreV1().ServiceAccounts(p.Namespace)
+		if err := k8sutil.CreateOrUpdateServiceAccount(ctx, saClient, makeServiceAccount(p, c.config)); err != nil {
+			return errors.Wrap(err, "synchronizing service account failed")
+		}
+	}
+
 	ssetClient := c.kclient.AppsV1().StatefulSets(p.Namespace)
 	// Ensure we have a StatefulSet running Prometheus deployed.
 	obj, err := c.ssetInfs.Get(prometheusKeyToStatefulSetKey(key))
@@ -1258,10 +1753,18 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 	exists := !apierrors.IsNotFound(err)
 
 	spec := appsv1.StatefulSetSpec{}
+	var current *appsv1.StatefulSet
 	if obj != nil {
-		ss := obj.(*appsv1.StatefulSet)
-		spec = ss.Spec
+		current = obj.(*appsv1.StatefulSet)
+		spec = current.Spec
+	}
+
+	var recommendation *resource.Quantity
+	if c.recommender != nil {
+		recommendation = c.recommender.recommendation(key)
 	}
+	applyAutoResources(p, recommendation, current)
+
 	newSSetInputHash, err := createSSetInputHash(*p, c.config, ruleConfigMapNames, spec)
 	if err != nil {
 		return err
@@ -1279,48 +1782,186 @@ func (c *Operator) sync(ctx context.Context, key string) error {
 		if _, err := ssetClient.Create(ctx, sset, metav1.CreateOptions{}); err != nil {
 			return errors.Wrap(err, "creating statefulset failed")
 		}
+		c.metrics.GeneratedObjectCounter("statefulset", operator.OperationCreate).Inc()
+		res.inputHash = newSSetInputHash
 		return nil
 	}
 
 	oldSSetInputHash := obj.(*appsv1.StatefulSet).ObjectMeta.Annotations[sSetInputHashName]
 	if newSSetInputHash == oldSSetInputHash {
 		level.Debug(c.logger).Log("msg", "new statefulset generation inputs match current, skipping any actions")
+		c.metrics.GeneratedObjectCounter("statefulset", operator.OperationUnchanged).Inc()
+		res.inputHash = newSSetInputHash
 		return nil
 	}
 
 	level.Debug(c.logger).Log("msg", "updating current Prometheus statefulset")
+	c.metrics.GeneratedObjectCounter("statefulset", operator.OperationUpdate).Inc()
 
-	_, err = ssetClient.Update(ctx, sset, metav1.UpdateOptions{})
-	sErr, ok := err.(*apierrors.StatusError)
+	// The StatefulSet is recreated by the next reconciliation once the
+	// volumes are resized.
+	if resized, err := c.volumeResizer().Resize(ctx, p.Spec.Storage, current, sset); err != nil || resized {
+		return err
+	}
 
-	if ok && sErr.ErrStatus.Code == 422 && sErr.ErrStatus.Reason == metav1.StatusReasonInvalid {
-		c.metrics.StsDeleteCreateCounter().Inc()
-		level.Info(c.logger).Log("msg", "resolving illegal update of Prometheus StatefulSet", "details", sErr.ErrStatus.Details)
-		propagationPolicy := metav1.DeletePropagationForeground
-		if err := ssetClient.Delete(ctx, sset.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
-			return errors.Wrap(err, "failed to delete StatefulSet to avoid forbidden action")
+	if err := c.updateStatefulSet(ctx, p.Namespace, sset); err != nil {
+		return err
+	}
+	res.inputHash = newSSetInputHash
+
+	return nil
+}
+
+// updateStatus updates the status subresource of the Prometheus object with
+// the current state of its pods and the outcome of the last reconciliation.
+func (c *Operator) updateStatus(ctx context.Context, p *monitoringv1.Prometheus, res syncResult, reconcileErr error) error {
+	status, _, err := PrometheusStatus(ctx, c.kclient, p)
+	if err != nil {
+		if !apierrors.IsNotFound(errors.Cause(err)) {
+			return err
 		}
+		// The StatefulSet doesn't exist when the reconciliation failed
+		// before creating it.
+		status = &monitoringv1.PrometheusStatus{Paused: p.Spec.Paused}
+	}
+
+	desired := minReplicas
+	if p.Spec.Replicas != nil {
+		desired = *p.Spec.Replicas
+	}
+	// The operator doesn't deploy any pod in config-only mode.
+	if c.config.Mode == operator.ModeConfigOnly {
+		desired = 0
+	}
+
+	var current []monitoringv1.Condition
+	if p.Status != nil {
+		current = p.Status.Conditions
+		status.InputHash = p.Status.InputHash
+	}
+	if res.inputHash != "" {
+		status.InputHash = res.inputHash
+	}
+	status.Selector = ListOptions(p.Name).LabelSelector
+	status.Conditions = operator.MakeConditions(p.Generation, desired, status.AvailableReplicas, reconcileErr, current)
+	status.Conditions = append(status.Conditions, makeConfigGeneratedCondition(p.Generation, res.configErr, current))
+	if remoteRuleFiles(p) {
+		status.Conditions = append(status.Conditions, makeRemoteRuleFilesSyncedCondition(p.Generation, res.remoteRulesErr, current))
+	}
+
+	// Skip the update when nothing changed to avoid triggering another
+	// reconciliation.
+	if reflect.DeepEqual(p.Status, status) {
 		return nil
 	}
 
-	if err != nil {
-		return errors.Wrap(err, "updating StatefulSet failed")
+	p.Status = status
+	if _, err := c.mclient.MonitoringV1().Prometheuses(p.Namespace).UpdateStatus(ctx, p, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update status subresource")
 	}
 
 	return nil
 }
 
-//checkPrometheusSpecDeprecation checks for deprecated fields in the prometheus spec and logs a warning if applicable
-func checkPrometheusSpecDeprecation(key string, p *monitoringv1.Prometheus, logger log.Logger) {
-	deprecationWarningf := "prometheus key=%v, field %v is deprecated, '%v' field should be used instead"
-	if p.Spec.BaseImage != "" {
-		level.Warn(logger).Log("msg", fmt.Sprintf(deprecationWarningf, key, "spec.baseImage", "spec.image"))
+// makeConfigGeneratedCondition returns the ConfigGenerated condition given
+// the error of the configuration generation. The transition time of the
+// current condition is preserved when its status doesn't change.
+func makeConfigGeneratedCondition(generation int64, configErr error, current []monitoringv1.Condition) monitoringv1.Condition {
+	cond := monitoringv1.Condition{
+		Type:               monitoringv1.ConfigGenerated,
+		Status:             monitoringv1.ConditionTrue,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
 	}
-	if p.Spec.Tag != "" {
-		level.Warn(logger).Log("msg", fmt.Sprintf(deprecationWarningf, key, "spec.tag", "spec.image"))
+
+	switch {
+	case configErr == errConfigNotGenerated:
+		cond.Status = monitoringv1.ConditionUnknown
+		cond.Reason = "NotGenerated"
+		cond.Message = "the reconciliation failed before generating the configuration"
+	case configErr != nil:
+		cond.Status = monitoringv1.ConditionFalse
+		cond.Reason = "GenerationFailed"
+		cond.Message = configErr.Error()
 	}
-	if p.Spec.SHA != "" {
-		level.Warn(logger).Log("msg", fmt.Sprintf(deprecationWarningf, key, "spec.sha", "spec.image"))
+
+	for _, c := range current {
+		if c.Type == cond.Type && c.Status == cond.Status {
+			cond.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	return cond
+}
+
+// makeRemoteRuleFilesSyncedCondition returns the RemoteRuleFilesSynced
+// condition given the error of the remote rule files. The transition time of
+// the current condition is preserved when its status doesn't change.
+func makeRemoteRuleFilesSyncedCondition(generation int64, remoteRulesErr error, current []monitoringv1.Condition) monitoringv1.Condition {
+	cond := monitoringv1.Condition{
+		Type:               monitoringv1.RemoteRuleFilesSynced,
+		Status:             monitoringv1.ConditionTrue,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	switch {
+	case remoteRulesErr == errRemoteRulesNotSynced:
+		cond.Status = monitoringv1.ConditionUnknown
+		cond.Reason = "NotSynced"
+		cond.Message = "the reconciliation failed before fetching the remote rule files"
+	case remoteRulesErr != nil:
+		cond.Status = monitoringv1.ConditionFalse
+		cond.Reason = "SyncFailed"
+		cond.Message = remoteRulesErr.Error()
+	}
+
+	for _, c := range current {
+		if c.Type == cond.Type && c.Status == cond.Status {
+			cond.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	return cond
+}
+
+// updateStatefulSet updates the StatefulSet. When the update is forbidden
+// (e.g. because an immutable field changed), the StatefulSet is deleted and
+// it is recreated by the next reconciliation.
+func (c *Operator) updateStatefulSet(ctx context.Context, namespace string, sset *appsv1.StatefulSet) error {
+	ssetClient := c.kclient.AppsV1().StatefulSets(namespace)
+
+	_, err := ssetClient.Update(ctx, sset, metav1.UpdateOptions{})
+	sErr, ok := err.(*apierrors.StatusError)
+
+	if ok && sErr.ErrStatus.Code == 422 && sErr.ErrStatus.Reason == metav1.StatusReasonInvalid {
+		c.metrics.StsDeleteCreateCounter().Inc()
+		level.Info(c.logger).Log("msg", "resolving illegal update of Prometheus StatefulSet", "details", sErr.ErrStatus.Details)
+		propagationPolicy := metav1.DeletePropagationForeground
+		if err := ssetClient.Delete(ctx, sset.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+			return errors.Wrap(err, "failed to delete StatefulSet to avoid forbidden action")
+		}
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "updating StatefulSet failed")
+	}
+
+	return nil
+}
+
+//checkPrometheusSpecDeprecation checks for deprecated fields in the prometheus spec and logs a warning if applicable
+func checkPrometheusSpecDeprecation(key string, p *monitoringv1.Prometheus, logger log.Logger) {
+	deprecationWarningf := "prometheus key=%v, field %v is deprecated, '%v' field should be used instead"
+	if p.Spec.BaseImage != "" {
+		level.Warn(logger).Log("msg", fmt.Sprintf(deprecationWarningf, key, "spec.baseImage", "spec.image"))
+	}
+	if p.Spec.Tag != "" {
+		level.Warn(logger).Log("msg", fmt.Sprintf(deprecationWarningf, key, "spec.tag", "spec.image"))
+	}
+	if p.Spec.SHA != "" {
+		level.Warn(logger).Log("msg", fmt.Sprintf(deprecationWarningf, key, "spec.sha", "spec.image"))
 	}
 	if p.Spec.Thanos != nil {
 		if p.Spec.BaseImage != "" {
@@ -1334,7 +1975,7 @@ func checkPrometheusSpecDeprecation(key string, p *monitoringv1.Prometheus, logg
 		}
 	}
 
-	if p.Spec.ServiceMonitorSelector == nil && p.Spec.PodMonitorSelector == nil && p.Spec.ProbeSelector == nil {
+	if p.Spec.ServiceMonitorSelector == nil && p.Spec.PodMonitorSelector == nil && p.Spec.ProbeSelector == nil && p.Spec.NodeMonitorSelector == nil {
 		level.Warn(logger).Log("msg", "neither serviceMonitorSelector nor podMonitorSelector, nor probeSelector specified. Custom configuration is deprecated, use additionalScrapeConfigs instead")
 	}
 }
@@ -1368,6 +2009,64 @@ func ListOptions(name string) metav1.ListOptions {
 	}
 }
 
+// checkObjectStorageConfig validates the Thanos object storage configuration
+// when the strict validation is enabled.
+func checkObjectStorageConfig(ctx context.Context, p *monitoringv1.Prometheus, store *assetStore) error {
+	if p.Spec.Thanos == nil || p.Spec.Thanos.ObjectStorageConfig == nil {
+		return nil
+	}
+
+	if p.Spec.Thanos.ObjectStorageConfigValidation != monitoringv1.ObjectStorageConfigValidationStrict {
+		return nil
+	}
+
+	content, err := store.getSecretKey(ctx, p.GetNamespace(), *p.Spec.Thanos.ObjectStorageConfig)
+	if err != nil {
+		return err
+	}
+
+	return validateObjectStorageConfig(content)
+}
+
+// checkThanosBlockDurations checks the TSDB block durations of the Thanos
+// sidecar. The sidecar refuses to upload blocks when Prometheus compacts
+// them, so both durations must match when the object storage is configured.
+func checkThanosBlockDurations(p *monitoringv1.Prometheus) error {
+	if p.Spec.Thanos == nil {
+		return nil
+	}
+
+	parse := func(name, d string) (time.Duration, error) {
+		if d == "" {
+			d = "2h"
+		}
+		parsed, err := time.ParseDuration(d)
+		return parsed, errors.Wrapf(err, "failed to parse %s", name)
+	}
+
+	minDuration, err := parse("minBlockDuration", p.Spec.Thanos.MinBlockDuration)
+	if err != nil {
+		return err
+	}
+	maxDuration, err := parse("maxBlockDuration", p.Spec.Thanos.MaxBlockDuration)
+	if err != nil {
+		return err
+	}
+
+	if p.Spec.Thanos.ObjectStorageConfig == nil {
+		if p.Spec.Thanos.UploadCompacted {
+			return errors.New("uploadCompacted requires objectStorageConfig")
+		}
+		return nil
+	}
+
+	if minDuration != maxDuration {
+		return errors.Errorf("minBlockDuration (%s) and maxBlockDuration (%s) must be equal when the blocks are uploaded", minDuration, maxDuration)
+	}
+
+	return nil
+}
+
 // PrometheusStatus evaluates the current status of a Prometheus deployment with
 // respect to its specified resource object. It return the status and a list of
 // pods that are not updated.
@@ -1452,82 +2151,76 @@ func gzipConfig(buf *bytes.Buffer, conf []byte) error {
 	return nil
 }
 
-func (c *Operator) createOrUpdateConfigurationSecret(ctx context.Context, p *monitoringv1.Prometheus, ruleConfigMapNames []string, store *assetStore) error {
+func (c *Operator) createOrUpdateConfigurationSecret(ctx context.Context, p *monitoringv1.Prometheus, ruleConfigMapNames []string, store *assetStore) (selectedMonitors, error) {
+	var mons selectedMonitors
+
 	// If no service or pod monitor selectors are configured, the user wants to
 	// manage configuration themselves. Do create an empty Secret if it doesn't
 	// exist.
 	if p.Spec.ServiceMonitorSelector == nil && p.Spec.PodMonitorSelector == nil &&
-		p.Spec.ProbeSelector == nil {
-		level.Debug(c.logger).Log("msg", "neither ServiceMonitor nor PodMonitor, nor Probe selector specified, leaving configuration unmanaged", "prometheus", p.Name, "namespace", p.Namespace)
+		p.Spec.ProbeSelector == nil && p.Spec.NodeMonitorSelector == nil {
+		level.Debug(c.logger).Log("msg", "neither ServiceMonitor, PodMonitor, Probe nor NodeMonitor selector specified, leaving configuration unmanaged", "prometheus", p.Name, "namespace", p.Namespace)
 
 		s, err := makeEmptyConfigurationSecret(p, c.config)
 		if err != nil {
-			return errors.Wrap(err, "generating empty config secret failed")
-		}
-		sClient := c.kclient.CoreV1().Secrets(p.Namespace)
-		_, err = sClient.Get(ctx, s.Name, metav1.GetOptions{})
-		if apierrors.IsNotFound(err) {
-			if _, err := c.kclient.CoreV1().Secrets(p.Namespace).Create(ctx, s, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
-				return errors.Wrap(err, "creating empty config file failed")
-			}
-		}
-		if !apierrors.IsNotFound(err) && err != nil {
-			return err
+			return mons, errors.Wrap(err, "generating empty config secret failed")
 		}
 
-		return nil
+		return mons, c.createEmptyConfigurationSecret(ctx, p.Namespace, s)
 	}
 
 	smons, err := c.selectServiceMonitors(ctx, p, store)
 	if err != nil {
-		return errors.Wrap(err, "selecting ServiceMonitors failed")
+		return mons, errors.Wrap(err, "selecting ServiceMonitors failed")
 	}
 
-	pmons, err := c.selectPodMonitors(p)
+	pmons, err := c.selectPodMonitors(ctx, p, store)
 	if err != nil {
-		return errors.Wrap(err, "selecting PodMonitors failed")
+		return mons, errors.Wrap(err, "selecting PodMonitors failed")
 	}
 
-	bmons, err := c.selectProbes(p)
+	bmons, err := c.selectProbes(ctx, p, store)
 	if err != nil {
-		return errors.Wrap(err, "selecting Probes failed")
+		return mons, errors.Wrap(err, "selecting Probes failed")
 	}
 
-	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
-	SecretsInPromNS, err := sClient.List(ctx, metav1.ListOptions{})
+	nmons, err := c.selectNodeMonitors(ctx, p, store)
 	if err != nil {
-		return err
+		return mons, errors.Wrap(err, "selecting NodeMonitors failed")
 	}
 
-	for i, remote := range p.Spec.RemoteRead {
-		if err := store.addBasicAuth(ctx, p.GetNamespace(), remote.BasicAuth, fmt.Sprintf("remoteRead/%d", i)); err != nil {
-			return errors.Wrapf(err, "remote read %d", i)
-		}
+	c.applyNamespaceLimits(smons, pmons, bmons, nmons)
+
+	if c.targetsChecker != nil {
+		c.targetsChecker.setMonitors(fmt.Sprintf("%s/%s", p.Namespace, p.Name), smons, pmons, nmons)
 	}
 
-	for i, remote := range p.Spec.RemoteWrite {
-		if err := store.addBasicAuth(ctx, p.GetNamespace(), remote.BasicAuth, fmt.Sprintf("remoteWrite/%d", i)); err != nil {
-			return errors.Wrapf(err, "remote write %d", i)
-		}
+	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
+	SecretsInPromNS, err := sClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return mons, err
 	}
 
-	if p.Spec.APIServerConfig != nil {
-		if err := store.addBasicAuth(ctx, p.GetNamespace(), p.Spec.APIServerConfig.BasicAuth, "apiserver"); err != nil {
-			return errors.Wrap(err, "apiserver config")
-		}
+	if err := addPrometheusAssets(ctx, p, store); err != nil {
+		return mons, err
 	}
 
 	additionalScrapeConfigs, err := c.loadAdditionalScrapeConfigsSecret(p.Spec.AdditionalScrapeConfigs, SecretsInPromNS)
 	if err != nil {
-		return errors.Wrap(err, "loading additional scrape configs from Secret failed")
+		return mons, errors.Wrap(err, "loading additional scrape configs from Secret failed")
+	}
+	if additionalScrapeConfigsAsFile(p) {
+		if err := c.createOrUpdateAdditionalScrapeConfigsSecret(ctx, p, additionalScrapeConfigs); err != nil {
+			return mons, errors.Wrap(err, "creating additional scrape configs Secret failed")
+		}
 	}
 	additionalAlertRelabelConfigs, err := c.loadAdditionalScrapeConfigsSecret(p.Spec.AdditionalAlertRelabelConfigs, SecretsInPromNS)
 	if err != nil {
-		return errors.Wrap(err, "loading additional alert relabel configs from Secret failed")
+		return mons, errors.Wrap(err, "loading additional alert relabel configs from Secret failed")
 	}
 	additionalAlertManagerConfigs, err := c.loadAdditionalScrapeConfigsSecret(p.Spec.AdditionalAlertManagerConfigs, SecretsInPromNS)
 	if err != nil {
-		return errors.Wrap(err, "loading additional alert manager configs from Secret failed")
+		return mons, errors.Wrap(err, "loading additional alert manager configs from Secret failed")
 	}
 
 	// Update secret based on the most recent configuration.
@@ -1536,63 +2229,137 @@ func (c *Operator) createOrUpdateConfigurationSecret(ctx context.Context, p *mon
 		smons,
 		pmons,
 		bmons,
+		nmons,
 		store.basicAuthAssets,
 		store.bearerTokenAssets,
+		store.sigv4Assets,
+		store.oauth2Assets,
+		store.headerAssets,
+		store.authorizationAssets,
 		additionalScrapeConfigs,
 		additionalAlertRelabelConfigs,
 		additionalAlertManagerConfigs,
 		ruleConfigMapNames,
 	)
 	if err != nil {
-		return errors.Wrap(err, "generating config failed")
+		return mons, errors.Wrap(err, "generating config failed")
+	}
+
+	var scrapeConfigFiles [][]byte
+	if splitScrapeConfigs(p) {
+		conf, scrapeConfigFiles, err = splitConfig(conf)
+		if err != nil {
+			return mons, errors.Wrap(err, "splitting scrape configs failed")
+		}
+	}
+	if err := c.createOrUpdateScrapeConfigsSecrets(ctx, p, scrapeConfigFiles); err != nil {
+		return mons, errors.Wrap(err, "creating scrape configs Secrets failed")
+	}
+
+	mons = selectedMonitors{smons: smons, pmons: pmons, probes: bmons, nmons: nmons}
+	return mons, c.writeConfigurationSecret(ctx, p, makeConfigSecret(p, c.config), conf)
+}
+
+// createEmptyConfigurationSecret creates the empty configuration Secret of
+// an unmanaged configuration if it doesn't exist.
+func (c *Operator) createEmptyConfigurationSecret(ctx context.Context, namespace string, s *v1.Secret) error {
+	sClient := c.kclient.CoreV1().Secrets(namespace)
+	_, err := sClient.Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := sClient.Create(ctx, s, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "creating empty config file failed")
+		}
+	}
+	if !apierrors.IsNotFound(err) && err != nil {
+		return err
 	}
 
-	s := makeConfigSecret(p, c.config)
+	return nil
+}
+
+// writeConfigurationSecret stores the compressed configuration in the
+// Secret s. The Secret isn't updated when the configuration didn't change.
+func (c *Operator) writeConfigurationSecret(ctx context.Context, p *monitoringv1.Prometheus, s *v1.Secret, conf []byte) error {
+	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
 	s.ObjectMeta.Annotations = map[string]string{
 		"generated": "true",
 	}
 
-	// Compress config to avoid 1mb secret limit for a while
-	var buf bytes.Buffer
-	if err = gzipConfig(&buf, conf); err != nil {
-		return errors.Wrap(err, "couldnt gzip config")
+	// Compress config to avoid 1mb secret limit for a while. Without the
+	// config reloader, Prometheus reads the configuration from the Secret
+	// so it is stored uncompressed, and only once.
+	key, generatedConf := configFilename, conf
+	if httpReload(p) {
+		key = configRawFilename
+	} else {
+		var buf bytes.Buffer
+		if err := gzipConfig(&buf, conf); err != nil {
+			return errors.Wrap(err, "couldnt gzip config")
+		}
+		generatedConf = buf.Bytes()
 	}
-	s.Data[configFilename] = buf.Bytes()
+	s.Data[key] = generatedConf
 
 	curSecret, err := sClient.Get(ctx, s.Name, metav1.GetOptions{})
 	if apierrors.IsNotFound(err) {
 		level.Debug(c.logger).Log("msg", "creating configuration")
+		c.metrics.GeneratedObjectCounter("secret", operator.OperationCreate).Inc()
 		_, err = sClient.Create(ctx, s, metav1.CreateOptions{})
 		return err
 	}
 
-	var (
-		generatedConf             = s.Data[configFilename]
-		curConfig, curConfigFound = curSecret.Data[configFilename]
-	)
+	curConfig, curConfigFound := curSecret.Data[key]
 	if curConfigFound {
-		if bytes.Equal(curConfig, generatedConf) {
+		if bytes.Equal(curConfig, generatedConf) && len(curSecret.Data) == len(s.Data) {
 			level.Debug(c.logger).Log("msg", "updating Prometheus configuration secret skipped, no configuration change")
+			c.metrics.GeneratedObjectCounter("secret", operator.OperationUnchanged).Inc()
 			return nil
 		}
 		level.Debug(c.logger).Log("msg", "current Prometheus configuration has changed")
+		if c.config.EnableConfigDiff {
+			c.logConfigDiff(p, curConfig, generatedConf)
+		}
 	} else {
 		level.Debug(c.logger).Log("msg", "no current Prometheus configuration secret found", "currentConfigFound", curConfigFound)
 	}
 
 	level.Debug(c.logger).Log("msg", "updating Prometheus configuration secret")
+	c.metrics.GeneratedObjectCounter("secret", operator.OperationUpdate).Inc()
 	_, err = sClient.Update(ctx, s, metav1.UpdateOptions{})
 	return err
 }
 
-func (c *Operator) createOrUpdateTLSAssetSecret(ctx context.Context, p *monitoringv1.Prometheus, store *assetStore) error {
+// logConfigDiff logs the redacted diff between the current and the generated
+// configurations and records an Event summarizing it.
+func (c *Operator) logConfigDiff(p *monitoringv1.Prometheus, cur, generated []byte) {
+	diff, err := configDiff(cur, generated)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to compute the configuration diff", "err", err, "namespace", p.Namespace, "prometheus", p.Name)
+		return
+	}
+
+	level.Info(c.logger).Log("msg", "Prometheus configuration changed", "namespace", p.Namespace, "prometheus", p.Name, "diff", diff)
+	if c.eventRecorder != nil {
+		c.eventRecorder.Event(p, v1.EventTypeNormal, configChangedReason, "Configuration changed: "+configDiffSummary(diff))
+	}
+}
+
+// createOrUpdateAdditionalScrapeConfigsSecret writes the additional scrape
+// configurations to the Secret mounted as the file referenced by
+// `scrape_config_files`.
+func (c *Operator) createOrUpdateAdditionalScrapeConfigsSecret(ctx context.Context, p *monitoringv1.Prometheus, additionalScrapeConfigs []byte) error {
 	boolTrue := true
 	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
 
-	tlsAssetsSecret := &v1.Secret{
+	content, err := generateAdditionalScrapeConfigsFile(additionalScrapeConfigs)
+	if err != nil {
+		return err
+	}
+
+	s := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   tlsAssetsSecretName(p.Name),
-			Labels: c.config.Labels.Merge(managedByOperatorLabels),
+			Name:   additionalScrapeConfigsSecretName(p.Name),
+			Labels: c.config.Labels.Merge(managedLabels(p)),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         p.APIVersion,
@@ -1604,9 +2371,188 @@ func (c *Operator) createOrUpdateTLSAssetSecret(ctx context.Context, p *monitori
 				},
 			},
 		},
-		Data: map[string][]byte{},
+		Data: map[string][]byte{
+			additionalScrapeConfigsFilename: content,
+		},
+	}
+
+	curSecret, err := sClient.Get(ctx, s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		level.Debug(c.logger).Log("msg", "creating additional scrape configs secret")
+		_, err = sClient.Create(ctx, s, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(curSecret.Data[additionalScrapeConfigsFilename], content) {
+		level.Debug(c.logger).Log("msg", "updating additional scrape configs secret skipped, no configuration change")
+		return nil
+	}
+
+	level.Debug(c.logger).Log("msg", "updating additional scrape configs secret")
+	_, err = sClient.Update(ctx, s, metav1.UpdateOptions{})
+	return err
+}
+
+// createOrUpdateScrapeConfigsSecrets writes the split scrape configurations
+// to one Secret per file and deletes the Secrets of the files which don't
+// exist anymore.
+func (c *Operator) createOrUpdateScrapeConfigsSecrets(ctx context.Context, p *monitoringv1.Prometheus, files [][]byte) error {
+	boolTrue := true
+	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
+
+	for i, content := range files {
+		s := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   scrapeConfigsSecretName(p.Name, i),
+				Labels: c.config.Labels.Merge(managedLabels(p)),
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion:         p.APIVersion,
+						BlockOwnerDeletion: &boolTrue,
+						Controller:         &boolTrue,
+						Kind:               p.Kind,
+						Name:               p.Name,
+						UID:                p.UID,
+					},
+				},
+			},
+			Data: map[string][]byte{
+				scrapeConfigsFilename: content,
+			},
+		}
+
+		curSecret, err := sClient.Get(ctx, s.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			level.Debug(c.logger).Log("msg", "creating scrape configs secret", "secret", s.Name)
+			if _, err := sClient.Create(ctx, s, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(curSecret.Data[scrapeConfigsFilename], content) {
+			continue
+		}
+
+		level.Debug(c.logger).Log("msg", "updating scrape configs secret", "secret", s.Name)
+		if _, err := sClient.Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	for i := len(files); i < maxScrapeConfigsSecrets; i++ {
+		name := scrapeConfigsSecretName(p.Name, i)
+		err := sClient.Delete(ctx, name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			// The Secrets are created with consecutive indices.
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete obsolete Secret %q", name)
+		}
+		level.Debug(c.logger).Log("msg", "deleted obsolete scrape configs secret", "secret", name)
+	}
+
+	return nil
+}
+
+// createOrUpdateWebConfigSecret writes the web configuration file and the
+// TLS assets it references to the Secret mounted in the Prometheus
+// container.
+func (c *Operator) createOrUpdateWebConfigSecret(ctx context.Context, p *monitoringv1.Prometheus) error {
+	if p.Spec.Web == nil {
+		return nil
 	}
 
+	data, err := webconfig.MakeSecretData(ctx, c.kclient.CoreV1(), p.Namespace, webConfigDir, p.Spec.Web)
+	if err != nil {
+		return errors.Wrap(err, "invalid web configuration")
+	}
+
+	boolTrue := true
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   webConfigSecretName(p.Name),
+			Labels: c.config.Labels.Merge(managedLabels(p)),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         p.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               p.Kind,
+					Name:               p.Name,
+					UID:                p.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+
+	return k8sutil.CreateOrUpdateSecret(ctx, c.kclient.CoreV1().Secrets(p.Namespace), s)
+}
+
+// createOrUpdateDatasourceConfigMap synchronizes the ConfigMap describing
+// the Grafana datasource of the Prometheus server, deleting it when the
+// Prometheus object doesn't request it anymore.
+func (c *Operator) createOrUpdateDatasourceConfigMap(ctx context.Context, p *monitoringv1.Prometheus) error {
+	cmClient := c.kclient.CoreV1().ConfigMaps(p.Namespace)
+
+	if p.Spec.ExportDatasource == nil {
+		err := cmClient.Delete(ctx, datasourceConfigMapName(p.Name), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, "deleting datasource configmap failed")
+		}
+		return nil
+	}
+
+	u := fmt.Sprintf("%s://%s:9090", webconfig.Scheme(p.Spec.Web), k8sutil.ServiceHost(governingServiceName, p.Namespace, c.config.ClusterDomain))
+	if p.Spec.RoutePrefix != "" {
+		u += path.Clean("/" + p.Spec.RoutePrefix)
+	}
+
+	data, err := datasource.MakeConfigMapData(p.Spec.ExportDatasource, path.Join(p.Namespace, p.Name), u)
+	if err != nil {
+		return errors.Wrap(err, "invalid datasource")
+	}
+
+	cmLabels := managedLabels(p)
+	for k, v := range datasource.Labels(p.Spec.ExportDatasource) {
+		cmLabels[k] = v
+	}
+
+	boolTrue := true
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   datasourceConfigMapName(p.Name),
+			Labels: c.config.Labels.Merge(cmLabels),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         p.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               p.Kind,
+					Name:               p.Name,
+					UID:                p.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+
+	return k8sutil.CreateOrUpdateConfigMap(ctx, cmClient, cm)
+}
+
+// createOrUpdateTLSAssetSecret stores the TLS assets of the remote write
+// endpoints and of the selected monitoring objects in tlsAssetsSecret.
+func (c *Operator) createOrUpdateTLSAssetSecret(ctx context.Context, p *monitoringv1.Prometheus, tlsAssetsSecret *v1.Secret, store *assetStore) error {
+	sClient := c.kclient.CoreV1().Secrets(p.Namespace)
+
 	for i, rw := range p.Spec.RemoteWrite {
 		if err := store.addTLSConfig(ctx, p.GetNamespace(), rw.TLSConfig); err != nil {
 			return errors.Wrapf(err, "remote write %d", i)
@@ -1678,32 +2624,23 @@ func (c *Operator) selectServiceMonitors(ctx context.Context, p *monitoringv1.Pr
 		})
 	}
 
+	nodeAccess := newNodeAccessReview(c.kclient, c.logger, p)
 	res := make(map[string]*monitoringv1.ServiceMonitor, len(serviceMonitors))
 	for namespaceAndName, sm := range serviceMonitors {
-		var err error
-
-		for i, endpoint := range sm.Spec.Endpoints {
-			// If denied by Prometheus spec, filter out all service monitors that access
-			// the file system.
-			if p.Spec.ArbitraryFSAccessThroughSMs.Deny {
-				if err = testForArbitraryFSAccess(endpoint); err != nil {
-					break
-				}
-			}
-
-			smKey := fmt.Sprintf("serviceMonitor/%s/%s/%d", sm.GetNamespace(), sm.GetName(), i)
-
-			if err = store.addBearerToken(ctx, sm.GetNamespace(), endpoint.BearerTokenSecret, smKey); err != nil {
-				break
-			}
-
-			if err = store.addBasicAuth(ctx, sm.GetNamespace(), endpoint.BasicAuth, smKey); err != nil {
-				break
-			}
-
-			if err = store.addTLSConfig(ctx, sm.GetNamespace(), endpoint.TLSConfig); err != nil {
-				break
-			}
+		// If denied by Prometheus spec, filter out all service monitors that access
+		// the file system.
+		err := checkServiceMonitorFSAccess(p, sm)
+		if err == nil {
+			err = checkServiceMonitorBoundToken(p, sm)
+		}
+		if err == nil {
+			err = nodeAccess.check(ctx, sm.Spec.AttachMetadata)
+		}
+		if err == nil {
+			_, err = scrapeClass(p, sm.Spec.ScrapeClassName)
+		}
+		if err == nil {
+			err = addServiceMonitorAssets(ctx, sm, store)
 		}
 
 		if err != nil {
@@ -1729,10 +2666,10 @@ func (c *Operator) selectServiceMonitors(ctx context.Context, p *monitoringv1.Pr
 	return res, nil
 }
 
-func (c *Operator) selectPodMonitors(p *monitoringv1.Prometheus) (map[string]*monitoringv1.PodMonitor, error) {
+func (c *Operator) selectPodMonitors(ctx context.Context, p *monitoringv1.Prometheus, store *assetStore) (map[string]*monitoringv1.PodMonitor, error) {
 	namespaces := []string{}
 	// Selectors might overlap. Deduplicate them along the keyFunc.
-	res := make(map[string]*monitoringv1.PodMonitor)
+	podMonitors := make(map[string]*monitoringv1.PodMonitor)
 
 	podMonSelector, err := metav1.LabelSelectorAsSelector(p.Spec.PodMonitorSelector)
 	if err != nil {
@@ -1756,26 +2693,53 @@ func (c *Operator) selectPodMonitors(p *monitoringv1.Prometheus) (map[string]*mo
 
 	level.Debug(c.logger).Log("msg", "filtering namespaces to select PodMonitors from", "namespaces", strings.Join(namespaces, ","), "namespace", p.Namespace, "prometheus", p.Name)
 
-	podMonitors := []string{}
 	for _, ns := range namespaces {
 		c.pmonInfs.ListAllByNamespace(ns, podMonSelector, func(obj interface{}) {
 			k, ok := c.keyFunc(obj)
 			if ok {
-				res[k] = obj.(*monitoringv1.PodMonitor)
-				podMonitors = append(podMonitors, k)
+				podMonitors[k] = obj.(*monitoringv1.PodMonitor)
 			}
 		})
 	}
 
-	level.Debug(c.logger).Log("msg", "selected PodMonitors", "podmonitors", strings.Join(podMonitors, ","), "namespace", p.Namespace, "prometheus", p.Name)
+	nodeAccess := newNodeAccessReview(c.kclient, c.logger, p)
+	res := make(map[string]*monitoringv1.PodMonitor, len(podMonitors))
+	for namespaceAndName, pm := range podMonitors {
+		_, err := scrapeClass(p, pm.Spec.ScrapeClassName)
+		if err == nil {
+			err = nodeAccess.check(ctx, pm.Spec.AttachMetadata)
+		}
+		if err == nil {
+			err = addPodMonitorAssets(ctx, pm, store)
+		}
+
+		if err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "skipping podmonitor",
+				"error", err.Error(),
+				"podmonitor", namespaceAndName,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+			continue
+		}
+
+		res[namespaceAndName] = pm
+	}
+
+	pmKeys := []string{}
+	for k := range res {
+		pmKeys = append(pmKeys, k)
+	}
+	level.Debug(c.logger).Log("msg", "selected PodMonitors", "podmonitors", strings.Join(pmKeys, ","), "namespace", p.Namespace, "prometheus", p.Name)
 
 	return res, nil
 }
 
-func (c *Operator) selectProbes(p *monitoringv1.Prometheus) (map[string]*monitoringv1.Probe, error) {
+func (c *Operator) selectProbes(ctx context.Context, p *monitoringv1.Prometheus, store *assetStore) (map[string]*monitoringv1.Probe, error) {
 	namespaces := []string{}
 	// Selectors might overlap. Deduplicate them along the keyFunc.
-	res := make(map[string]*monitoringv1.Probe)
+	probes := make(map[string]*monitoringv1.Probe)
 
 	bMonSelector, err := metav1.LabelSelectorAsSelector(p.Spec.ProbeSelector)
 	if err != nil {
@@ -1799,17 +2763,114 @@ func (c *Operator) selectProbes(p *monitoringv1.Prometheus) (map[string]*monitor
 
 	level.Debug(c.logger).Log("msg", "filtering namespaces to select Probes from", "namespaces", strings.Join(namespaces, ","), "namespace", p.Namespace, "prometheus", p.Name)
 
-	probes := make([]string, 0)
 	for _, ns := range namespaces {
 		c.probeInfs.ListAllByNamespace(ns, bMonSelector, func(obj interface{}) {
 			if k, ok := c.keyFunc(obj); ok {
-				res[k] = obj.(*monitoringv1.Probe)
-				probes = append(probes, k)
+				probes[k] = obj.(*monitoringv1.Probe)
 			}
 		})
 	}
 
-	level.Debug(c.logger).Log("msg", "selected Probes", "probes", strings.Join(probes, ","), "namespace", p.Namespace, "prometheus", p.Name)
+	res := make(map[string]*monitoringv1.Probe, len(probes))
+	for namespaceAndName, probe := range probes {
+		_, err := scrapeClass(p, probe.Spec.ScrapeClassName)
+		if err == nil {
+			err = checkProbeStaticTargets(probe)
+		}
+		if err == nil {
+			err = addProbeAssets(ctx, probe, store)
+		}
+
+		if err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "skipping probe",
+				"error", err.Error(),
+				"probe", namespaceAndName,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+			continue
+		}
+
+		res[namespaceAndName] = probe
+	}
+
+	probeKeys := make([]string, 0, len(res))
+	for k := range res {
+		probeKeys = append(probeKeys, k)
+	}
+	level.Debug(c.logger).Log("msg", "selected Probes", "probes", strings.Join(probeKeys, ","), "namespace", p.Namespace, "prometheus", p.Name)
+
+	return res, nil
+}
+
+func (c *Operator) selectNodeMonitors(ctx context.Context, p *monitoringv1.Prometheus, store *assetStore) (map[string]*monitoringv1.NodeMonitor, error) {
+	namespaces := []string{}
+	// Selectors (<namespace>/<name>) might overlap. Deduplicate them along the keyFunc.
+	nodeMonitors := make(map[string]*monitoringv1.NodeMonitor)
+
+	nodeMonSelector, err := metav1.LabelSelectorAsSelector(p.Spec.NodeMonitorSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	// If 'NodeMonitorNamespaceSelector' is nil only check own namespace.
+	if p.Spec.NodeMonitorNamespaceSelector == nil {
+		namespaces = append(namespaces, p.Namespace)
+	} else {
+		nodeMonNSSelector, err := metav1.LabelSelectorAsSelector(p.Spec.NodeMonitorNamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		namespaces, err = c.listMatchingNamespaces(nodeMonNSSelector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	level.Debug(c.logger).Log("msg", "filtering namespaces to select NodeMonitors from", "namespaces", strings.Join(namespaces, ","), "namespace", p.Namespace, "prometheus", p.Name)
+
+	for _, ns := range namespaces {
+		c.nmonInfs.ListAllByNamespace(ns, nodeMonSelector, func(obj interface{}) {
+			k, ok := c.keyFunc(obj)
+			if ok {
+				nodeMonitors[k] = obj.(*monitoringv1.NodeMonitor)
+			}
+		})
+	}
+
+	res := make(map[string]*monitoringv1.NodeMonitor, len(nodeMonitors))
+	for namespaceAndName, nm := range nodeMonitors {
+		// If denied by Prometheus spec, filter out all node monitors that access
+		// the file system.
+		err := checkNodeMonitorFSAccess(p, nm)
+		if err == nil {
+			err = checkNodeMonitorBoundToken(p, nm)
+		}
+		if err == nil {
+			err = addNodeMonitorAssets(ctx, nm, store)
+		}
+
+		if err != nil {
+			level.Warn(c.logger).Log(
+				"msg", "skipping nodemonitor",
+				"error", err.Error(),
+				"nodemonitor", namespaceAndName,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+			continue
+		}
+
+		res[namespaceAndName] = nm
+	}
+
+	nmKeys := []string{}
+	for k := range res {
+		nmKeys = append(nmKeys, k)
+	}
+	level.Debug(c.logger).Log("msg", "selected NodeMonitors", "nodemonitors", strings.Join(nmKeys, ","), "namespace", p.Namespace, "prometheus", p.Name)
 
 	return res, nil
 }
@@ -1835,6 +2896,328 @@ func testForArbitraryFSAccess(e monitoringv1.Endpoint) error {
 	return nil
 }
 
+// checkServiceMonitorFSAccess returns an error if the Prometheus spec denies
+// arbitrary file system access and one of the ServiceMonitor's endpoints
+// accesses the file system.
+func checkServiceMonitorFSAccess(p *monitoringv1.Prometheus, sm *monitoringv1.ServiceMonitor) error {
+	if !p.Spec.ArbitraryFSAccessThroughSMs.Deny {
+		return nil
+	}
+
+	for _, endpoint := range sm.Spec.Endpoints {
+		if err := testForArbitraryFSAccess(endpoint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkNodeMonitorFSAccess is the NodeMonitor counterpart of
+// checkServiceMonitorFSAccess.
+func checkNodeMonitorFSAccess(p *monitoringv1.Prometheus, nm *monitoringv1.NodeMonitor) error {
+	if !p.Spec.ArbitraryFSAccessThroughSMs.Deny {
+		return nil
+	}
+
+	for _, endpoint := range nm.Spec.Endpoints {
+		if err := testForArbitraryFSAccess(monitoringv1.Endpoint{
+			BearerTokenFile: endpoint.BearerTokenFile,
+			TLSConfig:       endpoint.TLSConfig,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkBoundServiceAccountToken returns an error if an endpoint uses the
+// bound ServiceAccount token while the Prometheus spec doesn't project it.
+func checkBoundServiceAccountToken(p *monitoringv1.Prometheus, i int, enabled bool) error {
+	if enabled && p.Spec.BoundServiceAccountToken == nil {
+		return errors.Errorf("endpoint %d: bearerTokenBoundServiceAccount requires boundServiceAccountToken in the Prometheus specification", i)
+	}
+
+	return nil
+}
+
+// checkServiceMonitorBoundToken returns an error if one of the
+// ServiceMonitor's endpoints uses the bound ServiceAccount token while the
+// Prometheus spec doesn't project it.
+func checkServiceMonitorBoundToken(p *monitoringv1.Prometheus, sm *monitoringv1.ServiceMonitor) error {
+	for i, endpoint := range sm.Spec.Endpoints {
+		if err := checkBoundServiceAccountToken(p, i, endpoint.BearerTokenBoundServiceAccount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkNodeMonitorBoundToken is the NodeMonitor counterpart of
+// checkServiceMonitorBoundToken.
+func checkNodeMonitorBoundToken(p *monitoringv1.Prometheus, nm *monitoringv1.NodeMonitor) error {
+	for i, endpoint := range nm.Spec.Endpoints {
+		if err := checkBoundServiceAccountToken(p, i, endpoint.BearerTokenBoundServiceAccount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addServiceMonitorAssets loads the credentials and TLS assets referenced
+// by the ServiceMonitor's endpoints into the store.
+func addServiceMonitorAssets(ctx context.Context, sm *monitoringv1.ServiceMonitor, store *assetStore) error {
+	for i, endpoint := range sm.Spec.Endpoints {
+		smKey := fmt.Sprintf("serviceMonitor/%s/%s/%d", sm.GetNamespace(), sm.GetName(), i)
+
+		if endpoint.Authorization != nil && (endpoint.BasicAuth != nil || endpoint.BearerTokenFile != "" || endpoint.BearerTokenSecret.Name != "") {
+			return errors.Errorf("endpoint %d: authorization can't be set at the same time as basicAuth, bearerTokenFile or bearerTokenSecret", i)
+		}
+
+		if endpoint.OAuth2 != nil && (endpoint.Authorization != nil || endpoint.BasicAuth != nil || endpoint.BearerTokenFile != "" || endpoint.BearerTokenSecret.Name != "") {
+			return errors.Errorf("endpoint %d: oauth2 can't be set at the same time as authorization, basicAuth, bearerTokenFile or bearerTokenSecret", i)
+		}
+
+		if endpoint.BearerTokenBoundServiceAccount && (endpoint.Authorization != nil || endpoint.BasicAuth != nil || endpoint.BearerTokenFile != "" || endpoint.BearerTokenSecret.Name != "" || endpoint.OAuth2 != nil) {
+			return errors.Errorf("endpoint %d: bearerTokenBoundServiceAccount can't be set at the same time as authorization, basicAuth, bearerTokenFile, bearerTokenSecret or oauth2", i)
+		}
+
+		if err := store.addBearerToken(ctx, sm.GetNamespace(), endpoint.BearerTokenSecret, smKey); err != nil {
+			return err
+		}
+
+		if err := store.addBasicAuth(ctx, sm.GetNamespace(), endpoint.BasicAuth, smKey); err != nil {
+			return err
+		}
+
+		if err := store.addAuthorization(ctx, sm.GetNamespace(), endpoint.Authorization, smKey); err != nil {
+			return err
+		}
+
+		if err := store.addOAuth2(ctx, sm.GetNamespace(), endpoint.OAuth2, smKey); err != nil {
+			return err
+		}
+
+		if err := store.addTLSConfig(ctx, sm.GetNamespace(), endpoint.TLSConfig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPodMonitorAssets loads the credentials referenced by the PodMonitor's
+// endpoints into the store.
+func addPodMonitorAssets(ctx context.Context, pm *monitoringv1.PodMonitor, store *assetStore) error {
+	for i, endpoint := range pm.Spec.PodMetricsEndpoints {
+		pmKey := fmt.Sprintf("podMonitor/%s/%s/%d", pm.GetNamespace(), pm.GetName(), i)
+
+		if endpoint.OAuth2 != nil && endpoint.Authorization != nil {
+			return errors.Errorf("endpoint %d: oauth2 can't be set at the same time as authorization", i)
+		}
+
+		if err := store.addAuthorization(ctx, pm.GetNamespace(), endpoint.Authorization, pmKey); err != nil {
+			return err
+		}
+
+		if err := store.addOAuth2(ctx, pm.GetNamespace(), endpoint.OAuth2, pmKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addProbeAssets loads the credentials referenced by the Probe into the
+// store.
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// checkProbeStaticTargets validates the labels and the prober parameters of
+// the Probe's static targets.
+func checkProbeStaticTargets(probe *monitoringv1.Probe) error {
+	if probe.Spec.Targets.StaticConfig == nil {
+		return nil
+	}
+
+	for i, t := range probe.Spec.Targets.StaticConfig.StaticTargets {
+		if t.URL == "" {
+			return errors.Errorf("staticTargets[%d]: url is required", i)
+		}
+		for k := range t.Labels {
+			if !labelNameRE.MatchString(k) || strings.HasPrefix(k, "__") {
+				return errors.Errorf("staticTargets[%d]: invalid label name %q", i, k)
+			}
+		}
+		for k := range t.Params {
+			if !labelNameRE.MatchString("__param_" + k) {
+				return errors.Errorf("staticTargets[%d]: invalid parameter name %q", i, k)
+			}
+			if k == "module" || k == "target" {
+				return errors.Errorf("staticTargets[%d]: the %q parameter can't be overridden", i, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+func addProbeAssets(ctx context.Context, probe *monitoringv1.Probe, store *assetStore) error {
+	probeKey := fmt.Sprintf("probe/%s/%s", probe.GetNamespace(), probe.GetName())
+
+	return store.addAuthorization(ctx, probe.GetNamespace(), probe.Spec.Authorization, probeKey)
+}
+
+// addNodeMonitorAssets loads the credentials and TLS assets referenced by
+// the NodeMonitor's endpoints into the store.
+func addNodeMonitorAssets(ctx context.Context, nm *monitoringv1.NodeMonitor, store *assetStore) error {
+	for i, endpoint := range nm.Spec.Endpoints {
+		nmKey := fmt.Sprintf("nodeMonitor/%s/%s/%d", nm.GetNamespace(), nm.GetName(), i)
+
+		if endpoint.Authorization != nil && (endpoint.BasicAuth != nil || endpoint.BearerTokenFile != "" || endpoint.BearerTokenSecret.Name != "") {
+			return errors.Errorf("endpoint %d: authorization can't be set at the same time as basicAuth, bearerTokenFile or bearerTokenSecret", i)
+		}
+
+		if endpoint.BearerTokenBoundServiceAccount && (endpoint.Authorization != nil || endpoint.BasicAuth != nil || endpoint.BearerTokenFile != "" || endpoint.BearerTokenSecret.Name != "") {
+			return errors.Errorf("endpoint %d: bearerTokenBoundServiceAccount can't be set at the same time as authorization, basicAuth, bearerTokenFile or bearerTokenSecret", i)
+		}
+
+		if err := store.addBearerToken(ctx, nm.GetNamespace(), endpoint.BearerTokenSecret, nmKey); err != nil {
+			return err
+		}
+
+		if err := store.addBasicAuth(ctx, nm.GetNamespace(), endpoint.BasicAuth, nmKey); err != nil {
+			return err
+		}
+
+		if err := store.addAuthorization(ctx, nm.GetNamespace(), endpoint.Authorization, nmKey); err != nil {
+			return err
+		}
+
+		if err := store.addTLSConfig(ctx, nm.GetNamespace(), endpoint.TLSConfig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPrometheusAssets loads the credentials referenced by the remote read,
+// remote write, federation, API server and scrape class configurations of
+// the Prometheus spec into the store.
+func addPrometheusAssets(ctx context.Context, p *monitoringv1.Prometheus, store *assetStore) error {
+	// The TLS assets of the Alertmanager endpoints, the remote read endpoints
+	// and the API server aren't mounted so cert-manager certificates can't
+	// be used there.
+	if p.Spec.Alerting != nil {
+		for i, am := range p.Spec.Alerting.Alertmanagers {
+			if am.TLSConfig != nil && am.TLSConfig.CertManagerRef != nil {
+				return errors.Errorf("alertmanager %d: certManagerRef isn't supported", i)
+			}
+		}
+	}
+	if p.Spec.APIServerConfig != nil && p.Spec.APIServerConfig.TLSConfig != nil && p.Spec.APIServerConfig.TLSConfig.CertManagerRef != nil {
+		return errors.New("apiserver config: certManagerRef isn't supported")
+	}
+
+	for i, remote := range p.Spec.RemoteRead {
+		if remote.TLSConfig != nil && remote.TLSConfig.CertManagerRef != nil {
+			return errors.Errorf("remote read %d: certManagerRef isn't supported", i)
+		}
+		if remote.OAuth2 != nil && (remote.BasicAuth != nil || remote.BearerToken != "" || remote.BearerTokenFile != "") {
+			return errors.Errorf("remote read %d: oauth2 can't be set at the same time as basicAuth, bearerToken or bearerTokenFile", i)
+		}
+		if remote.Authorization != nil && (remote.BasicAuth != nil || remote.BearerToken != "" || remote.BearerTokenFile != "" || remote.OAuth2 != nil) {
+			return errors.Errorf("remote read %d: authorization can't be set at the same time as basicAuth, bearerToken, bearerTokenFile or oauth2", i)
+		}
+		if err := store.addBasicAuth(ctx, p.GetNamespace(), remote.BasicAuth, fmt.Sprintf("remoteRead/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote read %d", i)
+		}
+		if err := store.addOAuth2(ctx, p.GetNamespace(), remote.OAuth2, fmt.Sprintf("remoteRead/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote read %d", i)
+		}
+		if err := store.addHeaders(ctx, p.GetNamespace(), remote.HeadersFromSecrets, fmt.Sprintf("remoteRead/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote read %d", i)
+		}
+		if err := store.addAuthorization(ctx, p.GetNamespace(), remote.Authorization, fmt.Sprintf("remoteRead/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote read %d", i)
+		}
+	}
+
+	for i, remote := range p.Spec.RemoteWrite {
+		if remote.Authorization != nil && (remote.BasicAuth != nil || remote.BearerToken != "" || remote.BearerTokenFile != "" || remote.Sigv4 != nil) {
+			return errors.Errorf("remote write %d: authorization can't be set at the same time as basicAuth, bearerToken, bearerTokenFile or sigv4", i)
+		}
+		if err := store.addBasicAuth(ctx, p.GetNamespace(), remote.BasicAuth, fmt.Sprintf("remoteWrite/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote write %d", i)
+		}
+		if err := store.addSigV4(ctx, p.GetNamespace(), remote.Sigv4, fmt.Sprintf("remoteWrite/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote write %d", i)
+		}
+		if err := store.addAuthorization(ctx, p.GetNamespace(), remote.Authorization, fmt.Sprintf("remoteWrite/%d", i)); err != nil {
+			return errors.Wrapf(err, "remote write %d", i)
+		}
+		if remote.ProxyURL != "" && remote.ProxyFromEnvironment {
+			return errors.Errorf("remote write %d: proxyUrl can't be set at the same time as proxyFromEnvironment", i)
+		}
+		if remote.ProxyURL == "" && (remote.NoProxy != "" || len(remote.ProxyConnectHeader) > 0) {
+			return errors.Errorf("remote write %d: noProxy and proxyConnectHeader require proxyUrl", i)
+		}
+		if err := store.addHeaders(ctx, p.GetNamespace(), remote.ProxyConnectHeader, fmt.Sprintf("remoteWrite/%d/proxyConnectHeader", i)); err != nil {
+			return errors.Wrapf(err, "remote write %d", i)
+		}
+	}
+
+	for i, fed := range p.Spec.Federation {
+		if fed.Authorization != nil && fed.BasicAuth != nil {
+			return errors.Errorf("federation %d: authorization can't be set at the same time as basicAuth", i)
+		}
+		if err := store.addBasicAuth(ctx, p.GetNamespace(), fed.BasicAuth, fmt.Sprintf("federation/%d", i)); err != nil {
+			return errors.Wrapf(err, "federation %d", i)
+		}
+		if err := store.addAuthorization(ctx, p.GetNamespace(), fed.Authorization, fmt.Sprintf("federation/%d", i)); err != nil {
+			return errors.Wrapf(err, "federation %d", i)
+		}
+		if err := store.addTLSConfig(ctx, p.GetNamespace(), fed.TLSConfig); err != nil {
+			return errors.Wrapf(err, "federation %d", i)
+		}
+	}
+
+	if p.Spec.APIServerConfig != nil {
+		if err := store.addBasicAuth(ctx, p.GetNamespace(), p.Spec.APIServerConfig.BasicAuth, "apiserver"); err != nil {
+			return errors.Wrap(err, "apiserver config")
+		}
+	}
+
+	scrapeClasses := make(map[string]struct{}, len(p.Spec.ScrapeClasses))
+	defaultScrapeClass := ""
+	for i := range p.Spec.ScrapeClasses {
+		sc := &p.Spec.ScrapeClasses[i]
+		if _, ok := scrapeClasses[sc.Name]; ok {
+			return errors.Errorf("scrape class %q: duplicate name", sc.Name)
+		}
+		scrapeClasses[sc.Name] = struct{}{}
+
+		if sc.Default {
+			if defaultScrapeClass != "" {
+				return errors.Errorf("scrape class %q: %q is already the default scrape class", sc.Name, defaultScrapeClass)
+			}
+			defaultScrapeClass = sc.Name
+		}
+
+		if err := store.addAuthorization(ctx, p.GetNamespace(), sc.Authorization, scrapeClassKey(sc)); err != nil {
+			return errors.Wrapf(err, "scrape class %q", sc.Name)
+		}
+		if err := store.addTLSConfig(ctx, p.GetNamespace(), sc.TLSConfig); err != nil {
+			return errors.Wrapf(err, "scrape class %q", sc.Name)
+		}
+	}
+
+	return nil
+}
+
 // listMatchingNamespaces lists all the namespaces that match the provided
 // selector.
 func (c *Operator) listMatchingNamespaces(selector labels.Selector) ([]string, error) {
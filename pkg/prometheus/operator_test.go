@@ -15,12 +15,26 @@
 package prometheus
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/kit/log"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringfake "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/fake"
+	"github.com/prometheus-operator/prometheus-operator/pkg/informers"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -137,3 +151,838 @@ func TestGetNodeAddresses(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizationExclusiveWithOtherCredentials(t *testing.T) {
+	c := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("value")},
+	})
+	auth := &monitoringv1.Authorization{
+		Credentials: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+			Key:                  "token",
+		},
+	}
+
+	sm := &monitoringv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Endpoints: []monitoringv1.Endpoint{
+				{Port: "web", Authorization: auth, BearerTokenFile: "/var/run/token"},
+			},
+		},
+	}
+	if err := addServiceMonitorAssets(context.Background(), sm, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the service monitor")
+	}
+
+	nm := &monitoringv1.NodeMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.NodeMonitorSpec{
+			Endpoints: []monitoringv1.NodeMetricsEndpoint{
+				{Authorization: auth, BearerTokenBoundServiceAccount: true},
+			},
+		},
+	}
+	if err := addNodeMonitorAssets(context.Background(), nm, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the node monitor")
+	}
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.PrometheusSpec{
+			RemoteWrite: []monitoringv1.RemoteWriteSpec{
+				{URL: "http://example.com", Authorization: auth, BearerToken: "token"},
+			},
+		},
+	}
+	if err := addPrometheusAssets(context.Background(), p, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the remote write")
+	}
+
+	sm.Spec.Endpoints[0].BearerTokenFile = ""
+	store := newAssetStore(c.CoreV1(), c.CoreV1())
+	if err := addServiceMonitorAssets(context.Background(), sm, store); err != nil {
+		t.Fatal(err)
+	}
+	if store.authorizationAssets["serviceMonitor/default/test/0"] != "value" {
+		t.Fatalf("expected the credentials in the store, got %v", store.authorizationAssets)
+	}
+
+	nm.Spec.Endpoints[0].BearerTokenBoundServiceAccount = false
+	if err := addNodeMonitorAssets(context.Background(), nm, store); err != nil {
+		t.Fatal(err)
+	}
+	if store.authorizationAssets["nodeMonitor/default/test/0"] != "value" {
+		t.Fatalf("expected the credentials in the store, got %v", store.authorizationAssets)
+	}
+}
+
+func TestOAuth2ExclusiveWithOtherCredentials(t *testing.T) {
+	c := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "oauth2", Namespace: "default"},
+		Data: map[string][]byte{
+			"id":     []byte("client-id"),
+			"secret": []byte("client-secret"),
+		},
+	})
+	oauth2 := &monitoringv1.OAuth2{
+		ClientID: monitoringv1.SecretOrConfigMap{
+			Secret: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "oauth2"},
+				Key:                  "id",
+			},
+		},
+		ClientSecret: v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "oauth2"},
+			Key:                  "secret",
+		},
+		TokenURL: "https://auth.example.com/token",
+	}
+
+	sm := &monitoringv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Endpoints: []monitoringv1.Endpoint{
+				{Port: "web", OAuth2: oauth2, BearerTokenFile: "/var/run/token"},
+			},
+		},
+	}
+	if err := addServiceMonitorAssets(context.Background(), sm, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the service monitor")
+	}
+
+	sm.Spec.Endpoints[0].BearerTokenFile = ""
+	store := newAssetStore(c.CoreV1(), c.CoreV1())
+	if err := addServiceMonitorAssets(context.Background(), sm, store); err != nil {
+		t.Fatal(err)
+	}
+	expected := OAuth2Credentials{clientID: "client-id", clientSecret: "client-secret"}
+	if store.oauth2Assets["serviceMonitor/default/test/0"] != expected {
+		t.Fatalf("expected the credentials in the store, got %v", store.oauth2Assets)
+	}
+
+	pm := &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.PodMonitorSpec{
+			PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{
+				{Port: "web", OAuth2: oauth2},
+			},
+		},
+	}
+	if err := addPodMonitorAssets(context.Background(), pm, store); err != nil {
+		t.Fatal(err)
+	}
+	if store.oauth2Assets["podMonitor/default/test/0"] != expected {
+		t.Fatalf("expected the credentials in the store, got %v", store.oauth2Assets)
+	}
+
+	pm.Spec.PodMetricsEndpoints[0].OAuth2 = &monitoringv1.OAuth2{TokenURL: "https://auth.example.com/token"}
+	if err := addPodMonitorAssets(context.Background(), pm, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the invalid oauth2 configuration")
+	}
+}
+
+func TestBoundServiceAccountTokenValidation(t *testing.T) {
+	sm := &monitoringv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Endpoints: []monitoringv1.Endpoint{
+				{Port: "web", BearerTokenBoundServiceAccount: true},
+			},
+		},
+	}
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+	}
+	if err := checkServiceMonitorBoundToken(p, sm); err == nil {
+		t.Fatal("expected an error when the token isn't projected")
+	}
+
+	p.Spec.BoundServiceAccountToken = &monitoringv1.BoundServiceAccountToken{}
+	if err := checkServiceMonitorBoundToken(p, sm); err != nil {
+		t.Fatal(err)
+	}
+
+	c := fake.NewSimpleClientset()
+	if err := addServiceMonitorAssets(context.Background(), sm, newAssetStore(c.CoreV1(), c.CoreV1())); err != nil {
+		t.Fatal(err)
+	}
+
+	sm.Spec.Endpoints[0].BearerTokenFile = "/var/run/token"
+	if err := addServiceMonitorAssets(context.Background(), sm, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the service monitor")
+	}
+
+	nm := &monitoringv1.NodeMonitor{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.NodeMonitorSpec{
+			Endpoints: []monitoringv1.NodeMetricsEndpoint{
+				{BearerTokenBoundServiceAccount: true, BearerTokenFile: "/var/run/token"},
+			},
+		},
+	}
+	if err := addNodeMonitorAssets(context.Background(), nm, newAssetStore(c.CoreV1(), c.CoreV1())); err == nil {
+		t.Fatal("expected an error for the node monitor")
+	}
+}
+
+func TestAddRemoteWriteProxyAssets(t *testing.T) {
+	c := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "proxy", Namespace: "default"},
+		Data:       map[string][]byte{"auth": []byte("Basic dXNlcjpwYXNz")},
+	})
+	connectHeader := map[string]v1.SecretKeySelector{
+		"Proxy-Authorization": {
+			LocalObjectReference: v1.LocalObjectReference{Name: "proxy"},
+			Key:                  "auth",
+		},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		spec        monitoringv1.RemoteWriteSpec
+		expectedErr bool
+	}{
+		{
+			name: "connect header",
+			spec: monitoringv1.RemoteWriteSpec{URL: "http://example.com", ProxyURL: "http://proxy:3128", ProxyConnectHeader: connectHeader},
+		},
+		{
+			name:        "connect header without proxy url",
+			spec:        monitoringv1.RemoteWriteSpec{URL: "http://example.com", ProxyConnectHeader: connectHeader},
+			expectedErr: true,
+		},
+		{
+			name:        "no proxy without proxy url",
+			spec:        monitoringv1.RemoteWriteSpec{URL: "http://example.com", NoProxy: ".svc"},
+			expectedErr: true,
+		},
+		{
+			name:        "proxy url and proxy from environment",
+			spec:        monitoringv1.RemoteWriteSpec{URL: "http://example.com", ProxyURL: "http://proxy:3128", ProxyFromEnvironment: true},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: monitoringv1.PrometheusSpec{
+					RemoteWrite: []monitoringv1.RemoteWriteSpec{tc.spec},
+				},
+			}
+
+			store := newAssetStore(c.CoreV1(), c.CoreV1())
+			err := addPrometheusAssets(context.Background(), p, store)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v := store.headerAssets["remoteWrite/0/proxyConnectHeader"]["Proxy-Authorization"]; v != "Basic dXNlcjpwYXNz" {
+				t.Fatalf("expected the header in the store, got %v", store.headerAssets)
+			}
+		})
+	}
+}
+
+func TestAddScrapeClassAssets(t *testing.T) {
+	c := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("value")},
+	})
+	auth := &monitoringv1.Authorization{
+		Credentials: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+			Key:                  "token",
+		},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		scrapeClasses []monitoringv1.ScrapeClass
+
+		expectedErr bool
+	}{
+		{
+			name: "valid",
+			scrapeClasses: []monitoringv1.ScrapeClass{
+				{Name: "default", Default: true},
+				{Name: "token", Authorization: auth},
+			},
+		},
+		{
+			name: "duplicate name",
+			scrapeClasses: []monitoringv1.ScrapeClass{
+				{Name: "token"},
+				{Name: "token", Authorization: auth},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "multiple defaults",
+			scrapeClasses: []monitoringv1.ScrapeClass{
+				{Name: "a", Default: true},
+				{Name: "b", Default: true},
+			},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec:       monitoringv1.PrometheusSpec{ScrapeClasses: tc.scrapeClasses},
+			}
+
+			store := newAssetStore(c.CoreV1(), c.CoreV1())
+			err := addPrometheusAssets(context.Background(), p, store)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if store.authorizationAssets["scrapeClass/token"] != "value" {
+				t.Fatalf("expected the credentials in the store, got %v", store.authorizationAssets)
+			}
+		})
+	}
+}
+
+func TestAddPrometheusAssetsCertManagerRef(t *testing.T) {
+	tlsConfig := &monitoringv1.TLSConfig{CertManagerRef: &monitoringv1.CertManagerReference{SecretName: "tls"}}
+
+	for _, tc := range []struct {
+		name string
+		spec monitoringv1.PrometheusSpec
+	}{
+		{
+			name: "alertmanager",
+			spec: monitoringv1.PrometheusSpec{
+				Alerting: &monitoringv1.AlertingSpec{
+					Alertmanagers: []monitoringv1.AlertmanagerEndpoints{{Namespace: "default", Name: "main", TLSConfig: tlsConfig}},
+				},
+			},
+		},
+		{
+			name: "remote read",
+			spec: monitoringv1.PrometheusSpec{
+				RemoteRead: []monitoringv1.RemoteReadSpec{{URL: "http://example.com", TLSConfig: tlsConfig}},
+			},
+		},
+		{
+			name: "apiserver config",
+			spec: monitoringv1.PrometheusSpec{
+				APIServerConfig: &monitoringv1.APIServerConfig{Host: "https://example.com", TLSConfig: tlsConfig},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec:       tc.spec,
+			}
+
+			store := newAssetStore(fake.NewSimpleClientset().CoreV1(), fake.NewSimpleClientset().CoreV1())
+			if err := addPrometheusAssets(context.Background(), p, store); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestMakeConfigGeneratedCondition(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		configErr error
+
+		expected monitoringv1.ConditionStatus
+	}{
+		{
+			name:     "generated",
+			expected: monitoringv1.ConditionTrue,
+		},
+		{
+			name:      "generation failed",
+			configErr: errors.New("boom"),
+			expected:  monitoringv1.ConditionFalse,
+		},
+		{
+			name:      "not generated",
+			configErr: errConfigNotGenerated,
+			expected:  monitoringv1.ConditionUnknown,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cond := makeConfigGeneratedCondition(2, tc.configErr, nil)
+			if cond.Type != monitoringv1.ConfigGenerated {
+				t.Fatalf("expected condition type %q, got %q", monitoringv1.ConfigGenerated, cond.Type)
+			}
+			if cond.Status != tc.expected {
+				t.Fatalf("expected status %q, got %q", tc.expected, cond.Status)
+			}
+			if cond.ObservedGeneration != 2 {
+				t.Fatalf("expected observed generation 2, got %d", cond.ObservedGeneration)
+			}
+		})
+	}
+
+	// The transition time is preserved when the status doesn't change.
+	ts := metav1.NewTime(time.Now().Add(-time.Hour))
+	cond := makeConfigGeneratedCondition(3, nil, []monitoringv1.Condition{
+		{
+			Type:               monitoringv1.ConfigGenerated,
+			Status:             monitoringv1.ConditionTrue,
+			LastTransitionTime: ts,
+		},
+	})
+	if !cond.LastTransitionTime.Equal(&ts) {
+		t.Fatalf("expected transition time %v, got %v", ts, cond.LastTransitionTime)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "default",
+			Generation: 1,
+		},
+	}
+
+	c := &Operator{
+		kclient: fake.NewSimpleClientset(),
+		mclient: monitoringfake.NewSimpleClientset(p.DeepCopy()),
+	}
+
+	// The StatefulSet doesn't exist yet when the reconciliation fails early.
+	reconcileErr := errors.New("boom")
+	if err := c.updateStatus(context.Background(), p, syncResult{configErr: errConfigNotGenerated}, reconcileErr); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.mclient.MonitoringV1().Prometheuses("default").Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status == nil {
+		t.Fatal("expected status to be set")
+	}
+	// The order of the requirements in the selector isn't stable.
+	selector, err := labels.ConvertSelectorToLabelsMap(got.Status.Selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selector, labels.Set{"app": "prometheus", "prometheus": "test"}) {
+		t.Fatalf("unexpected selector %q", got.Status.Selector)
+	}
+
+	expected := map[monitoringv1.ConditionType]monitoringv1.ConditionStatus{
+		monitoringv1.Available:       monitoringv1.ConditionFalse,
+		monitoringv1.Reconciled:      monitoringv1.ConditionFalse,
+		monitoringv1.ConfigGenerated: monitoringv1.ConditionUnknown,
+	}
+	if len(got.Status.Conditions) != len(expected) {
+		t.Fatalf("expected %d conditions, got %d", len(expected), len(got.Status.Conditions))
+	}
+	for _, cond := range got.Status.Conditions {
+		if cond.Status != expected[cond.Type] {
+			t.Fatalf("expected condition %q to be %q, got %q", cond.Type, expected[cond.Type], cond.Status)
+		}
+	}
+
+	// The input hash of the last successful reconciliation is kept.
+	p = got.DeepCopy()
+	p.Status.InputHash = "1234"
+	if err := c.updateStatus(context.Background(), p, syncResult{configErr: errConfigNotGenerated}, reconcileErr); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status.InputHash != "1234" {
+		t.Fatalf("expected input hash %q, got %q", "1234", p.Status.InputHash)
+	}
+}
+
+func TestSyncPrometheusConfigOnly(t *testing.T) {
+	rule := &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{{
+				Name:  "test",
+				Rules: []monitoringv1.Rule{{Alert: "Down", Expr: intstr.FromString("up == 0")}},
+			}},
+		},
+	}
+	mclient := monitoringfake.NewSimpleClientset(rule.DeepCopy())
+
+	ruleInfs, err := informers.NewInformersForResource(
+		informers.NewMonitoringInformerFactories(map[string]struct{}{v1.NamespaceAll: {}}, nil, mclient, 0, nil),
+		monitoringv1.SchemeGroupVersion.WithResource(monitoringv1.PrometheusRuleName),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ruleInfs.GetInformers()[0].Informer().GetStore().Add(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Operator{
+		kclient:       fake.NewSimpleClientset(),
+		mclient:       mclient,
+		logger:        log.NewNopLogger(),
+		metrics:       operator.NewMetrics("prometheus", prometheus.NewRegistry()),
+		eventRecorder: record.NewFakeRecorder(10),
+		remoteRules:   newRemoteRulesFetcher(log.NewNopLogger()),
+		ruleInfs:      ruleInfs,
+		config:        *defaultTestConfig,
+	}
+	c.config.Mode = operator.ModeConfigOnly
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.PrometheusSpec{
+			RuleSelector: &metav1.LabelSelector{},
+		},
+	}
+
+	res := syncResult{configErr: errConfigNotGenerated, remoteRulesErr: errRemoteRulesNotSynced}
+	if err := c.syncPrometheus(context.Background(), "default/test", p, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.configErr != nil {
+		t.Fatalf("expected the configuration to be generated, got %v", res.configErr)
+	}
+
+	if _, err := c.kclient.CoreV1().Secrets("default").Get(context.Background(), configSecretName(p.Name), metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected the configuration secret, got %v", err)
+	}
+
+	cms, err := c.kclient.CoreV1().ConfigMaps("default").List(context.Background(), prometheusRulesConfigMapSelector(p.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ruleFiles int
+	for _, cm := range cms.Items {
+		ruleFiles += len(cm.Data)
+	}
+	if ruleFiles != 1 {
+		t.Fatalf("expected 1 rule file in the rule configmaps, got %d", ruleFiles)
+	}
+
+	svcs, err := c.kclient.CoreV1().Services("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs.Items) != 0 {
+		t.Fatalf("expected no service, got %d", len(svcs.Items))
+	}
+
+	ssets, err := c.kclient.AppsV1().StatefulSets("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ssets.Items) != 0 {
+		t.Fatalf("expected no statefulset, got %d", len(ssets.Items))
+	}
+}
+
+func TestUpdateStatusConfigOnly(t *testing.T) {
+	replicas := int32(2)
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: monitoringv1.PrometheusSpec{Replicas: &replicas},
+	}
+
+	c := &Operator{
+		kclient: fake.NewSimpleClientset(),
+		mclient: monitoringfake.NewSimpleClientset(p.DeepCopy()),
+		config:  Config{Mode: operator.ModeConfigOnly},
+	}
+
+	// No Pod runs in config-only mode, which isn't a failure.
+	if err := c.updateStatus(context.Background(), p, syncResult{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.mclient.MonitoringV1().Prometheuses("default").Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status == nil {
+		t.Fatal("expected status to be set")
+	}
+	if got.Status.AvailableReplicas != 0 {
+		t.Fatalf("expected no available replica, got %d", got.Status.AvailableReplicas)
+	}
+
+	expected := map[monitoringv1.ConditionType]monitoringv1.ConditionStatus{
+		monitoringv1.Available:       monitoringv1.ConditionTrue,
+		monitoringv1.Reconciled:      monitoringv1.ConditionTrue,
+		monitoringv1.ConfigGenerated: monitoringv1.ConditionTrue,
+	}
+	if len(got.Status.Conditions) != len(expected) {
+		t.Fatalf("expected %d conditions, got %d", len(expected), len(got.Status.Conditions))
+	}
+	for _, cond := range got.Status.Conditions {
+		if cond.Status != expected[cond.Type] {
+			t.Fatalf("expected condition %q to be %q, got %q", cond.Type, expected[cond.Type], cond.Status)
+		}
+	}
+}
+
+func TestCheckProbeStaticTargets(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target monitoringv1.ProbeStaticTarget
+		err    bool
+	}{
+		{
+			name: "valid target",
+			target: monitoringv1.ProbeStaticTarget{
+				URL:    "prometheus.io",
+				Labels: map[string]string{"team": "web"},
+				Module: "dns_soa",
+				Params: map[string]string{"hostname": "prometheus.io"},
+			},
+		},
+		{
+			name:   "missing url",
+			target: monitoringv1.ProbeStaticTarget{},
+			err:    true,
+		},
+		{
+			name: "invalid label name",
+			target: monitoringv1.ProbeStaticTarget{
+				URL:    "prometheus.io",
+				Labels: map[string]string{"team-name": "web"},
+			},
+			err: true,
+		},
+		{
+			name: "reserved label name",
+			target: monitoringv1.ProbeStaticTarget{
+				URL:    "prometheus.io",
+				Labels: map[string]string{"__address__": "foo"},
+			},
+			err: true,
+		},
+		{
+			name: "invalid parameter name",
+			target: monitoringv1.ProbeStaticTarget{
+				URL:    "prometheus.io",
+				Params: map[string]string{"host.name": "prometheus.io"},
+			},
+			err: true,
+		},
+		{
+			name: "target parameter",
+			target: monitoringv1.ProbeStaticTarget{
+				URL:    "prometheus.io",
+				Params: map[string]string{"target": "promcon.io"},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			probe := &monitoringv1.Probe{
+				Spec: monitoringv1.ProbeSpec{
+					Targets: monitoringv1.ProbeTargets{
+						StaticConfig: &monitoringv1.ProbeTargetStaticConfig{
+							StaticTargets: []monitoringv1.ProbeStaticTarget{tc.target},
+						},
+					},
+				},
+			}
+			err := checkProbeStaticTargets(probe)
+			if tc.err != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckThanosBlockDurations(t *testing.T) {
+	objStorage := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "thanos"},
+		Key:                  "config.yaml",
+	}
+
+	for _, tc := range []struct {
+		name   string
+		thanos *monitoringv1.ThanosSpec
+		err    bool
+	}{
+		{
+			name: "no thanos",
+		},
+		{
+			name:   "defaults with object storage",
+			thanos: &monitoringv1.ThanosSpec{ObjectStorageConfig: objStorage},
+		},
+		{
+			name: "equal durations with object storage",
+			thanos: &monitoringv1.ThanosSpec{
+				ObjectStorageConfig: objStorage,
+				MinBlockDuration:    "1h",
+				MaxBlockDuration:    "60m",
+				UploadCompacted:     true,
+			},
+		},
+		{
+			name: "different durations with object storage",
+			thanos: &monitoringv1.ThanosSpec{
+				ObjectStorageConfig: objStorage,
+				MaxBlockDuration:    "6h",
+			},
+			err: true,
+		},
+		{
+			name:   "different durations without object storage",
+			thanos: &monitoringv1.ThanosSpec{MaxBlockDuration: "6h"},
+		},
+		{
+			name:   "invalid duration",
+			thanos: &monitoringv1.ThanosSpec{MinBlockDuration: "2 hours"},
+			err:    true,
+		},
+		{
+			name:   "upload compacted without object storage",
+			thanos: &monitoringv1.ThanosSpec{UploadCompacted: true},
+			err:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				Spec: monitoringv1.PrometheusSpec{Thanos: tc.thanos},
+			}
+
+			err := checkThanosBlockDurations(p)
+			if tc.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateOrUpdateDatasourceConfigMap(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "monitoring",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			RoutePrefix:      "/prometheus/",
+			Web:              &monitoringv1.WebSpec{TLSConfig: &monitoringv1.WebTLSConfig{}},
+			ExportDatasource: &monitoringv1.DatasourceExportSpec{},
+		},
+	}
+
+	c := &Operator{
+		kclient: fake.NewSimpleClientset(),
+		config:  *defaultTestConfig,
+	}
+	c.config.ClusterDomain = "cluster.local"
+
+	if err := c.createOrUpdateDatasourceConfigMap(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := c.kclient.CoreV1().ConfigMaps("monitoring").Get(context.Background(), "prometheus-test-datasource", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cm.Labels["grafana_datasource"] != "1" || cm.Labels[labelPrometheusName] != "test" {
+		t.Fatalf("unexpected labels %v", cm.Labels)
+	}
+
+	expectedURL := "url: https://prometheus-operated.monitoring.svc.cluster.local:9090/prometheus\n"
+	if !strings.Contains(cm.Data["datasource.yaml"], expectedURL) {
+		t.Fatalf("expected %q in datasource, got %q", expectedURL, cm.Data["datasource.yaml"])
+	}
+
+	// The ConfigMap is deleted when it isn't requested anymore.
+	p.Spec.ExportDatasource = nil
+	if err := c.createOrUpdateDatasourceConfigMap(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.kclient.CoreV1().ConfigMaps("monitoring").Get(context.Background(), "prometheus-test-datasource", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the datasource configmap to be deleted, got %v", err)
+	}
+}
+
+func TestWriteConfigurationSecret(t *testing.T) {
+	httpStrategy := monitoringv1.HTTPReloadStrategyType
+	conf := []byte("global:\n  scrape_interval: 30s\n")
+
+	for _, tc := range []struct {
+		name           string
+		reloadStrategy *monitoringv1.ReloadStrategyType
+		expectedKey    string
+	}{
+		{
+			name:        "config reloader",
+			expectedKey: configFilename,
+		},
+		{
+			name:           "http reload",
+			reloadStrategy: &httpStrategy,
+			expectedKey:    configRawFilename,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "monitoring"},
+				Spec:       monitoringv1.PrometheusSpec{ReloadStrategy: tc.reloadStrategy},
+			}
+
+			c := &Operator{
+				kclient: fake.NewSimpleClientset(),
+				config:  *defaultTestConfig,
+				logger:  log.NewNopLogger(),
+				metrics: operator.NewMetrics("prometheus", prometheus.NewRegistry()),
+			}
+
+			if err := c.writeConfigurationSecret(context.Background(), p, makeConfigSecret(p, c.config), conf); err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := c.kclient.CoreV1().Secrets("monitoring").Get(context.Background(), configSecretName(p.Name), metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The configuration is stored only once.
+			if len(s.Data) != 1 {
+				t.Fatalf("expected only the %q key, got %v", tc.expectedKey, s.Data)
+			}
+
+			b := s.Data[tc.expectedKey]
+			if isGzipped(b) {
+				if b, err = gunzipConfig(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(b) != string(conf) {
+				t.Fatalf("expected configuration %q, got %q", conf, b)
+			}
+		})
+	}
+}
@@ -27,15 +27,19 @@ import (
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	v1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
 )
 
 const (
-	kubernetesSDRoleEndpoint = "endpoints"
-	kubernetesSDRolePod      = "pod"
-	kubernetesSDRoleIngress  = "ingress"
+	kubernetesSDRoleEndpoint      = "endpoints"
+	kubernetesSDRoleEndpointSlice = "endpointslice"
+	kubernetesSDRolePod           = "pod"
+	kubernetesSDRoleIngress       = "ingress"
+	kubernetesSDRoleNode          = "node"
+	kubernetesSDRoleService       = "service"
 )
 
 var (
@@ -44,15 +48,107 @@ var (
 
 type configGenerator struct {
 	logger log.Logger
+	// defaultExternalLabels are added to the external labels of every
+	// Prometheus unless the Prometheus spec defines them.
+	defaultExternalLabels map[string]string
+	// serviceDiscoveryRole is the discovery role of the ServiceMonitors
+	// which don't define one.
+	serviceDiscoveryRole v1.ServiceDiscoveryRole
+	// endpointSliceSupported is false when the cluster doesn't serve the
+	// EndpointSlice API.
+	endpointSliceSupported bool
 }
 
-func newConfigGenerator(logger log.Logger) *configGenerator {
+func newConfigGenerator(logger log.Logger, defaultExternalLabels map[string]string) *configGenerator {
 	cg := &configGenerator{
-		logger: logger,
+		logger:                 logger,
+		defaultExternalLabels:  defaultExternalLabels,
+		serviceDiscoveryRole:   v1.EndpointsRole,
+		endpointSliceSupported: true,
 	}
 	return cg
 }
 
+// serviceMonitorSDRole returns the Kubernetes discovery role for the
+// ServiceMonitor, falling back to the endpoints role when the EndpointSlice
+// role isn't supported by Prometheus or by the cluster.
+func (cg *configGenerator) serviceMonitorSDRole(version semver.Version, m *v1.ServiceMonitor) string {
+	role := cg.serviceDiscoveryRole
+	if m.Spec.ServiceDiscoveryRole != nil {
+		role = *m.Spec.ServiceDiscoveryRole
+	}
+
+	if role != v1.EndpointSliceRole {
+		return kubernetesSDRoleEndpoint
+	}
+
+	if !version.GTE(semver.MustParse("2.21.0")) {
+		level.Debug(cg.logger).Log("msg", "the EndpointSlice role requires Prometheus >= 2.21.0, falling back to the Endpoints role", "servicemonitor", m.Namespace+"/"+m.Name, "version", version)
+		return kubernetesSDRoleEndpoint
+	}
+
+	if !cg.endpointSliceSupported {
+		level.Debug(cg.logger).Log("msg", "the EndpointSlice API isn't available, falling back to the Endpoints role", "servicemonitor", m.Namespace+"/"+m.Name)
+		return kubernetesSDRoleEndpoint
+	}
+
+	return kubernetesSDRoleEndpointSlice
+}
+
+// roleSelectors returns the Kubernetes discovery selectors filtering the
+// objects of the roles with the label selector of the monitor, or nil if the
+// targets are filtered with relabeling rules.
+func (cg *configGenerator) roleSelectors(version semver.Version, mechanism *v1.SelectorMechanism, selector metav1.LabelSelector, roles ...string) []yaml.MapSlice {
+	if mechanism == nil || *mechanism != v1.SelectorMechanismRole {
+		return nil
+	}
+
+	if !version.GTE(semver.MustParse("2.17.0")) {
+		level.Debug(cg.logger).Log("msg", "the role selectors require Prometheus >= 2.17.0, falling back to relabeling", "version", version)
+		return nil
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		level.Warn(cg.logger).Log("msg", "invalid label selector, falling back to relabeling", "err", err)
+		return nil
+	}
+
+	if s.Empty() {
+		return nil
+	}
+
+	selectors := make([]yaml.MapSlice, 0, len(roles))
+	for _, role := range roles {
+		selectors = append(selectors, yaml.MapSlice{
+			{Key: "role", Value: role},
+			{Key: "label", Value: s.String()},
+		})
+	}
+
+	return selectors
+}
+
+// attachMetadata returns the metadata attached to the targets discovered
+// with the role, or nil if the Prometheus version doesn't support it.
+func (cg *configGenerator) attachMetadata(version semver.Version, role string, am *v1.AttachMetadata) *v1.AttachMetadata {
+	if am == nil {
+		return nil
+	}
+
+	minVersion := "2.37.0"
+	if role == kubernetesSDRolePod {
+		minVersion = "2.35.0"
+	}
+
+	if !version.GTE(semver.MustParse(minVersion)) {
+		level.Warn(cg.logger).Log("msg", fmt.Sprintf("attachMetadata with the %s role requires Prometheus >= %s, ignoring it", role, minVersion), "version", version)
+		return nil
+	}
+
+	return am
+}
+
 func sanitizeLabelName(name string) string {
 	return invalidLabelCharRE.ReplaceAllString(name, "_")
 }
@@ -73,6 +169,56 @@ func stringMapToMapSlice(m map[string]string) yaml.MapSlice {
 	return res
 }
 
+// addAuthorizationToYaml appends the authorization section to the given
+// configuration when the credentials have been loaded into the store.
+func addAuthorizationToYaml(cfg yaml.MapSlice, version semver.Version, auth *v1.Authorization, authorizationSecrets map[string]string, key string) yaml.MapSlice {
+	if auth == nil || !version.GTE(semver.MustParse("2.26.0")) {
+		return cfg
+	}
+
+	credentials, ok := authorizationSecrets[key]
+	if !ok {
+		return cfg
+	}
+
+	authCfg := yaml.MapSlice{}
+	if auth.Type != "" {
+		authCfg = append(authCfg, yaml.MapItem{Key: "type", Value: strings.TrimSpace(auth.Type)})
+	}
+	authCfg = append(authCfg, yaml.MapItem{Key: "credentials", Value: credentials})
+
+	return append(cfg, yaml.MapItem{Key: "authorization", Value: authCfg})
+}
+
+// addOAuth2ToYaml appends the oauth2 section to the given configuration
+// when the client credentials have been loaded into the store.
+func addOAuth2ToYaml(cfg yaml.MapSlice, version semver.Version, oauth2 *v1.OAuth2, oauth2Secrets map[string]OAuth2Credentials, key string) yaml.MapSlice {
+	if oauth2 == nil || !version.GTE(semver.MustParse("2.27.0")) {
+		return cfg
+	}
+
+	s, ok := oauth2Secrets[key]
+	if !ok {
+		return cfg
+	}
+
+	oauth2Cfg := yaml.MapSlice{
+		{Key: "client_id", Value: s.clientID},
+		{Key: "client_secret", Value: s.clientSecret},
+		{Key: "token_url", Value: oauth2.TokenURL},
+	}
+
+	if len(oauth2.Scopes) > 0 {
+		oauth2Cfg = append(oauth2Cfg, yaml.MapItem{Key: "scopes", Value: oauth2.Scopes})
+	}
+
+	if len(oauth2.EndpointParams) > 0 {
+		oauth2Cfg = append(oauth2Cfg, yaml.MapItem{Key: "endpoint_params", Value: stringMapToMapSlice(oauth2.EndpointParams)})
+	}
+
+	return append(cfg, yaml.MapItem{Key: "oauth2", Value: oauth2Cfg})
+}
+
 func addTLStoYaml(cfg yaml.MapSlice, namespace string, tls *v1.TLSConfig) yaml.MapSlice {
 	pathForSelector := func(sel v1.SecretOrConfigMap) string {
 		return path.Join(tlsAssetsDir, tlsAssetKeyFromSelector(namespace, sel).String())
@@ -99,6 +245,14 @@ func addTLStoYaml(cfg yaml.MapSlice, namespace string, tls *v1.TLSConfig) yaml.M
 		if tls.KeySecret != nil {
 			tlsConfig = append(tlsConfig, yaml.MapItem{Key: "key_file", Value: pathForSelector(v1.SecretOrConfigMap{Secret: tls.KeySecret})})
 		}
+		if tls.CertManagerRef != nil {
+			ca, cert, key := certManagerSelectors(tls.CertManagerRef)
+			if ca != nil {
+				tlsConfig = append(tlsConfig, yaml.MapItem{Key: "ca_file", Value: pathForSelector(v1.SecretOrConfigMap{Secret: ca})})
+			}
+			tlsConfig = append(tlsConfig, yaml.MapItem{Key: "cert_file", Value: pathForSelector(v1.SecretOrConfigMap{Secret: cert})})
+			tlsConfig = append(tlsConfig, yaml.MapItem{Key: "key_file", Value: pathForSelector(v1.SecretOrConfigMap{Secret: key})})
+		}
 		if tls.ServerName != "" {
 			tlsConfig = append(tlsConfig, yaml.MapItem{Key: "server_name", Value: tls.ServerName})
 		}
@@ -107,9 +261,13 @@ func addTLStoYaml(cfg yaml.MapSlice, namespace string, tls *v1.TLSConfig) yaml.M
 	return cfg
 }
 
-func buildExternalLabels(p *v1.Prometheus) yaml.MapSlice {
+func buildExternalLabels(p *v1.Prometheus, defaultExternalLabels map[string]string) yaml.MapSlice {
 	m := map[string]string{}
 
+	for n, v := range defaultExternalLabels {
+		m[n] = v
+	}
+
 	// Use "prometheus" external label name by default if field is missing.
 	// Do not add external label if field is set to empty string.
 	prometheusExternalLabelName := "prometheus"
@@ -137,7 +295,12 @@ func buildExternalLabels(p *v1.Prometheus) yaml.MapSlice {
 	}
 
 	if replicaExternalLabelName != "" {
+		// Prometheus expands the environment variables itself when the
+		// config reloader doesn't run.
 		m[replicaExternalLabelName] = "$(POD_NAME)"
+		if httpReload(p) {
+			m[replicaExternalLabelName] = "${POD_NAME}"
+		}
 	}
 
 	for n, v := range p.Spec.ExternalLabels {
@@ -146,13 +309,208 @@ func buildExternalLabels(p *v1.Prometheus) yaml.MapSlice {
 	return stringMapToMapSlice(m)
 }
 
+// additionalScrapeConfigsAsFile returns whether the additional scrape
+// configurations are included via `scrape_config_files` rather than appended
+// to the generated configuration.
+func additionalScrapeConfigsAsFile(p *v1.Prometheus) bool {
+	if p.Spec.AdditionalScrapeConfigs == nil || !p.Spec.AdditionalScrapeConfigsAsFile {
+		return false
+	}
+
+	versionStr := p.Spec.Version
+	if versionStr == "" {
+		versionStr = operator.DefaultPrometheusVersion
+	}
+
+	version, err := semver.ParseTolerant(versionStr)
+	if err != nil {
+		return false
+	}
+
+	return version.GTE(semver.MustParse("2.43.0"))
+}
+
+// generateAdditionalScrapeConfigsFile returns the content of the file
+// included via `scrape_config_files` from the list of additional scrape
+// configurations.
+func generateAdditionalScrapeConfigsFile(additionalScrapeConfigs []byte) ([]byte, error) {
+	var additionalScrapeConfigsYaml []yaml.MapSlice
+	if err := yaml.Unmarshal(additionalScrapeConfigs, &additionalScrapeConfigsYaml); err != nil {
+		return nil, errors.Wrap(err, "unmarshalling additional scrape configs failed")
+	}
+
+	if additionalScrapeConfigsYaml == nil {
+		additionalScrapeConfigsYaml = []yaml.MapSlice{}
+	}
+
+	return yaml.Marshal(yaml.MapSlice{
+		{Key: "scrape_configs", Value: additionalScrapeConfigsYaml},
+	})
+}
+
+// splitScrapeConfigs returns whether the generated scrape configurations are
+// written to dedicated Secrets included via `scrape_config_files`.
+func splitScrapeConfigs(p *v1.Prometheus) bool {
+	if !p.Spec.SplitScrapeConfigs {
+		return false
+	}
+
+	versionStr := p.Spec.Version
+	if versionStr == "" {
+		versionStr = operator.DefaultPrometheusVersion
+	}
+
+	version, err := semver.ParseTolerant(versionStr)
+	if err != nil {
+		return false
+	}
+
+	return version.GTE(semver.MustParse("2.43.0"))
+}
+
+// scrapeClass returns the scrape class of the Prometheus object referenced
+// by name, or the default scrape class if name is nil. It returns nil when
+// name is nil and there is no default scrape class.
+func scrapeClass(p *v1.Prometheus, name *string) (*v1.ScrapeClass, error) {
+	for i := range p.Spec.ScrapeClasses {
+		sc := &p.Spec.ScrapeClasses[i]
+		if (name == nil && sc.Default) || (name != nil && sc.Name == *name) {
+			return sc, nil
+		}
+	}
+
+	if name != nil {
+		return nil, errors.Errorf("scrape class %q not found", *name)
+	}
+
+	return nil, nil
+}
+
+// scrapeClassKey returns the key of the credentials of the scrape class in
+// the asset store.
+func scrapeClassKey(sc *v1.ScrapeClass) string {
+	return "scrapeClass/" + sc.Name
+}
+
+// scrapeClassRelabelings returns the relabelings of the scrape class, if
+// any.
+func scrapeClassRelabelings(sc *v1.ScrapeClass) []yaml.MapSlice {
+	if sc == nil {
+		return nil
+	}
+
+	relabelings := make([]yaml.MapSlice, 0, len(sc.Relabelings))
+	for _, c := range sc.Relabelings {
+		relabelings = append(relabelings, generateRelabelConfig(c))
+	}
+
+	return relabelings
+}
+
+// splitConfig moves the scrape configurations of the generated configuration
+// to files of at most maxScrapeConfigsSecretDataSize bytes each and includes
+// them via `scrape_config_files`. It returns the updated configuration and
+// the content of the files.
+func splitConfig(conf []byte) ([]byte, [][]byte, error) {
+	var cfg yaml.MapSlice
+	if err := yaml.Unmarshal(conf, &cfg); err != nil {
+		return nil, nil, errors.Wrap(err, "unmarshalling configuration failed")
+	}
+
+	var (
+		scrapeConfigs    []interface{}
+		scrapeConfigsIdx = -1
+		filesIdx         = -1
+	)
+	for i, item := range cfg {
+		switch item.Key {
+		case "scrape_configs":
+			scrapeConfigs, _ = item.Value.([]interface{})
+			scrapeConfigsIdx = i
+		case "scrape_config_files":
+			filesIdx = i
+		}
+	}
+
+	var (
+		buckets    = [][]interface{}{{}}
+		bucketSize = 0
+	)
+	for _, sc := range scrapeConfigs {
+		b, err := yaml.Marshal([]interface{}{sc})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "marshalling scrape config failed")
+		}
+		if len(b) > maxScrapeConfigsSecretDataSize {
+			return nil, nil, errors.Errorf("scrape config of %d bytes exceeds the maximum size of %d bytes", len(b), maxScrapeConfigsSecretDataSize)
+		}
+
+		if bucketSize+len(b) > maxScrapeConfigsSecretDataSize {
+			buckets = append(buckets, []interface{}{})
+			bucketSize = 0
+		}
+		buckets[len(buckets)-1] = append(buckets[len(buckets)-1], sc)
+		bucketSize += len(b)
+	}
+
+	if len(buckets) > maxScrapeConfigsSecrets {
+		return nil, nil, errors.Errorf("scrape configs require %d Secrets, more than the maximum of %d", len(buckets), maxScrapeConfigsSecrets)
+	}
+
+	files := make([][]byte, 0, len(buckets))
+	for _, bucket := range buckets {
+		b, err := yaml.Marshal(yaml.MapSlice{
+			{Key: "scrape_configs", Value: bucket},
+		})
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "marshalling scrape configs failed")
+		}
+		files = append(files, b)
+	}
+
+	scrapeConfigFiles := []interface{}{}
+	if filesIdx >= 0 {
+		if existing, ok := cfg[filesIdx].Value.([]interface{}); ok {
+			scrapeConfigFiles = existing
+		}
+	}
+	scrapeConfigFiles = append(scrapeConfigFiles, path.Join(scrapeConfigsDir, "*.yaml"))
+
+	split := yaml.MapSlice{}
+	for i, item := range cfg {
+		switch i {
+		case filesIdx:
+			continue
+		case scrapeConfigsIdx:
+			split = append(split, yaml.MapItem{Key: "scrape_config_files", Value: scrapeConfigFiles})
+			continue
+		}
+		split = append(split, item)
+	}
+	if scrapeConfigsIdx < 0 {
+		split = append(split, yaml.MapItem{Key: "scrape_config_files", Value: scrapeConfigFiles})
+	}
+
+	b, err := yaml.Marshal(split)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "marshalling configuration failed")
+	}
+
+	return b, files, nil
+}
+
 func (cg *configGenerator) generateConfig(
 	p *v1.Prometheus,
 	sMons map[string]*v1.ServiceMonitor,
 	pMons map[string]*v1.PodMonitor,
 	probes map[string]*v1.Probe,
+	nMons map[string]*v1.NodeMonitor,
 	basicAuthSecrets map[string]BasicAuthCredentials,
 	bearerTokens map[string]BearerToken,
+	sigv4Secrets map[string]SigV4Credentials,
+	oauth2Secrets map[string]OAuth2Credentials,
+	headerSecrets map[string]map[string]string,
+	authorizationSecrets map[string]string,
 	additionalScrapeConfigs []byte,
 	additionalAlertRelabelConfigs []byte,
 	additionalAlertManagerConfigs []byte,
@@ -183,7 +541,7 @@ func (cg *configGenerator) generateConfig(
 	globalItems := yaml.MapSlice{
 		{Key: "evaluation_interval", Value: evaluationInterval},
 		{Key: "scrape_interval", Value: scrapeInterval},
-		{Key: "external_labels", Value: buildExternalLabels(p)},
+		{Key: "external_labels", Value: buildExternalLabels(p, cg.defaultExternalLabels)},
 	}
 
 	if p.Spec.ScrapeTimeout != "" {
@@ -192,6 +550,16 @@ func (cg *configGenerator) generateConfig(
 		})
 	}
 
+	if len(p.Spec.ScrapeProtocols) > 0 {
+		if version.GTE(semver.MustParse("2.49.0")) {
+			globalItems = append(globalItems, yaml.MapItem{
+				Key: "scrape_protocols", Value: p.Spec.ScrapeProtocols,
+			})
+		} else {
+			level.Warn(cg.logger).Log("msg", "ignoring the scrape protocols not supported by Prometheus", "version", version.String(), "minimum_version", "2.49.0")
+		}
+	}
+
 	if version.GTE(semver.MustParse("2.16.0")) && p.Spec.QueryLogFile != "" {
 		globalItems = append(globalItems, yaml.MapItem{
 			Key: "query_log_file", Value: p.Spec.QueryLogFile,
@@ -200,15 +568,281 @@ func (cg *configGenerator) generateConfig(
 
 	cfg = append(cfg, yaml.MapItem{Key: "global", Value: globalItems})
 
+	if otlp := p.Spec.OTLP; otlp != nil && version.GTE(semver.MustParse("2.54.0")) {
+		otlpItems := yaml.MapSlice{}
+		if len(otlp.PromoteResourceAttributes) > 0 {
+			otlpItems = append(otlpItems, yaml.MapItem{
+				Key: "promote_resource_attributes", Value: otlp.PromoteResourceAttributes,
+			})
+		}
+		if otlp.TranslationStrategy != "" && version.GTE(semver.MustParse("2.55.0")) {
+			otlpItems = append(otlpItems, yaml.MapItem{
+				Key: "translation_strategy", Value: otlp.TranslationStrategy,
+			})
+		}
+		if len(otlpItems) > 0 {
+			cfg = append(cfg, yaml.MapItem{Key: "otlp", Value: otlpItems})
+		}
+	}
+
+	if exemplars := p.Spec.Exemplars; exemplars != nil && exemplars.MaxSize != nil {
+		if version.GTE(semver.MustParse("2.29.0")) {
+			cfg = append(cfg, yaml.MapItem{
+				Key: "storage",
+				Value: yaml.MapSlice{
+					{
+						Key:   "exemplars",
+						Value: yaml.MapSlice{{Key: "max_exemplars", Value: *exemplars.MaxSize}},
+					},
+				},
+			})
+		} else {
+			level.Warn(cg.logger).Log("msg", "ignoring the exemplars settings not supported by Prometheus", "version", version.String(), "minimum_version", "2.29.0")
+		}
+	}
+
 	ruleFilePaths := []string{}
-	for _, name := range ruleConfigMapNames {
-		ruleFilePaths = append(ruleFilePaths, rulesDir+"/"+name+"/*.yaml")
+	if len(ruleConfigMapNames) != 0 {
+		ruleFilePaths = append(ruleFilePaths, rulesDir+"/*.yaml")
+	}
+	if remoteRuleFiles(p) {
+		ruleFilePaths = append(ruleFilePaths, remoteRuleFilePaths()...)
 	}
 	cfg = append(cfg, yaml.MapItem{
 		Key:   "rule_files",
 		Value: ruleFilePaths,
 	})
 
+	scrapeConfigs := cg.generateScrapeConfigs(
+		version,
+		p,
+		sMons,
+		pMons,
+		probes,
+		nMons,
+		basicAuthSecrets,
+		bearerTokens,
+		authorizationSecrets,
+		oauth2Secrets,
+	)
+
+	apiserverConfig := p.Spec.APIServerConfig
+	var alertmanagerConfigs []yaml.MapSlice
+	if p.Spec.Alerting != nil {
+		for _, am := range p.Spec.Alerting.Alertmanagers {
+			alertmanagerConfigs = append(alertmanagerConfigs, cg.generateAlertmanagerConfig(version, am, apiserverConfig, basicAuthSecrets))
+		}
+	}
+
+	if additionalScrapeConfigsAsFile(p) {
+		cfg = append(cfg, yaml.MapItem{
+			Key:   "scrape_config_files",
+			Value: []string{path.Join(additionalScrapeConfigsDir, additionalScrapeConfigsFilename)},
+		})
+		cfg = append(cfg, yaml.MapItem{
+			Key:   "scrape_configs",
+			Value: scrapeConfigs,
+		})
+	} else {
+		var additionalScrapeConfigsYaml []yaml.MapSlice
+		err = yaml.Unmarshal([]byte(additionalScrapeConfigs), &additionalScrapeConfigsYaml)
+		if err != nil {
+			return nil, errors.Wrap(err, "unmarshalling additional scrape configs failed")
+		}
+
+		cfg = append(cfg, yaml.MapItem{
+			Key:   "scrape_configs",
+			Value: append(scrapeConfigs, additionalScrapeConfigsYaml...),
+		})
+	}
+
+	var additionalAlertManagerConfigsYaml []yaml.MapSlice
+	err = yaml.Unmarshal([]byte(additionalAlertManagerConfigs), &additionalAlertManagerConfigsYaml)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshalling additional alert manager configs failed")
+	}
+
+	alertmanagerConfigs = append(alertmanagerConfigs, additionalAlertManagerConfigsYaml...)
+
+	var alertRelabelConfigs []yaml.MapSlice
+
+	// Use defaultReplicaExternalLabelName constant by default if field is missing.
+	// Do not add external label if field is set to empty string.
+	replicaExternalLabelName := defaultReplicaExternalLabelName
+	if p.Spec.ReplicaExternalLabelName != nil {
+		if *p.Spec.ReplicaExternalLabelName != "" {
+			replicaExternalLabelName = *p.Spec.ReplicaExternalLabelName
+		} else {
+			replicaExternalLabelName = ""
+		}
+	}
+
+	// action 'labeldrop' is not supported <= v1.4.1
+	if replicaExternalLabelName != "" && version.GT(semver.MustParse("1.4.1")) {
+		// Drop replica label, to make alerts from multiple Prometheus replicas alike
+		alertRelabelConfigs = append(alertRelabelConfigs, yaml.MapSlice{
+			{Key: "action", Value: "labeldrop"},
+			{Key: "regex", Value: regexp.QuoteMeta(replicaExternalLabelName)},
+		})
+	}
+
+	var additionalAlertRelabelConfigsYaml []yaml.MapSlice
+	err = yaml.Unmarshal([]byte(additionalAlertRelabelConfigs), &additionalAlertRelabelConfigsYaml)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshalling additional alerting relabel configs failed")
+	}
+
+	cfg = append(cfg, yaml.MapItem{
+		Key: "alerting",
+		Value: yaml.MapSlice{
+			{
+				Key:   "alert_relabel_configs",
+				Value: append(alertRelabelConfigs, additionalAlertRelabelConfigsYaml...),
+			},
+			{
+				Key:   "alertmanagers",
+				Value: alertmanagerConfigs,
+			},
+		},
+	})
+
+	if len(p.Spec.RemoteWrite) > 0 && version.Major >= 2 {
+		cfg = append(cfg, cg.generateRemoteWriteConfig(version, p, basicAuthSecrets, sigv4Secrets, authorizationSecrets, headerSecrets))
+	}
+
+	if len(p.Spec.RemoteRead) > 0 && version.Major >= 2 {
+		cfg = append(cfg, cg.generateRemoteReadConfig(version, p.Spec.RemoteRead, basicAuthSecrets, oauth2Secrets, headerSecrets, authorizationSecrets))
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+// addProxyOptions adds the proxy options of the remote write configuration
+// which are supported since Prometheus 2.43.0.
+func (cg *configGenerator) addProxyOptions(cfg yaml.MapSlice, version semver.Version, spec v1.RemoteWriteSpec, connectHeaders map[string]string) yaml.MapSlice {
+	if spec.NoProxy == "" && !spec.ProxyFromEnvironment && len(spec.ProxyConnectHeader) == 0 {
+		return cfg
+	}
+
+	if version.LT(semver.MustParse("2.43.0")) {
+		level.Warn(cg.logger).Log("msg", "ignoring the proxy options of the remote write configuration not supported by Prometheus", "url", spec.URL, "version", version.String(), "minimum_version", "2.43.0")
+		return cfg
+	}
+
+	if spec.NoProxy != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "no_proxy", Value: spec.NoProxy})
+	}
+
+	if spec.ProxyFromEnvironment {
+		cfg = append(cfg, yaml.MapItem{Key: "proxy_from_environment", Value: true})
+	}
+
+	if len(spec.ProxyConnectHeader) > 0 {
+		names := make([]string, 0, len(spec.ProxyConnectHeader))
+		for k := range spec.ProxyConnectHeader {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		headers := make(yaml.MapSlice, 0, len(names))
+		for _, k := range names {
+			headers = append(headers, yaml.MapItem{Key: k, Value: []string{connectHeaders[k]}})
+		}
+		cfg = append(cfg, yaml.MapItem{Key: "proxy_connect_header", Value: headers})
+	}
+
+	return cfg
+}
+
+// generateAgentConfig generates the configuration of a Prometheus agent. The
+// agent mode doesn't support the rules, the alerting and the remote read
+// sections of the configuration.
+func (cg *configGenerator) generateAgentConfig(
+	p *v1.Prometheus,
+	sMons map[string]*v1.ServiceMonitor,
+	pMons map[string]*v1.PodMonitor,
+	probes map[string]*v1.Probe,
+	nMons map[string]*v1.NodeMonitor,
+	basicAuthSecrets map[string]BasicAuthCredentials,
+	bearerTokens map[string]BearerToken,
+	sigv4Secrets map[string]SigV4Credentials,
+	oauth2Secrets map[string]OAuth2Credentials,
+	authorizationSecrets map[string]string,
+	headerSecrets map[string]map[string]string,
+	additionalScrapeConfigs []byte,
+) ([]byte, error) {
+	versionStr := p.Spec.Version
+	if versionStr == "" {
+		versionStr = operator.DefaultPrometheusVersion
+	}
+
+	version, err := semver.ParseTolerant(versionStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse version")
+	}
+
+	scrapeInterval := "30s"
+	if p.Spec.ScrapeInterval != "" {
+		scrapeInterval = p.Spec.ScrapeInterval
+	}
+
+	globalItems := yaml.MapSlice{
+		{Key: "scrape_interval", Value: scrapeInterval},
+		{Key: "external_labels", Value: buildExternalLabels(p, cg.defaultExternalLabels)},
+	}
+
+	if p.Spec.ScrapeTimeout != "" {
+		globalItems = append(globalItems, yaml.MapItem{
+			Key: "scrape_timeout", Value: p.Spec.ScrapeTimeout,
+		})
+	}
+
+	cfg := yaml.MapSlice{{Key: "global", Value: globalItems}}
+
+	scrapeConfigs := cg.generateScrapeConfigs(
+		version,
+		p,
+		sMons,
+		pMons,
+		probes,
+		nMons,
+		basicAuthSecrets,
+		bearerTokens,
+		authorizationSecrets,
+		oauth2Secrets,
+	)
+
+	var additionalScrapeConfigsYaml []yaml.MapSlice
+	if err := yaml.Unmarshal(additionalScrapeConfigs, &additionalScrapeConfigsYaml); err != nil {
+		return nil, errors.Wrap(err, "unmarshalling additional scrape configs failed")
+	}
+
+	cfg = append(cfg, yaml.MapItem{
+		Key:   "scrape_configs",
+		Value: append(scrapeConfigs, additionalScrapeConfigsYaml...),
+	})
+
+	if len(p.Spec.RemoteWrite) > 0 {
+		cfg = append(cfg, cg.generateRemoteWriteConfig(version, p, basicAuthSecrets, sigv4Secrets, authorizationSecrets, headerSecrets))
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+// generateScrapeConfigs generates the scrape configurations of the selected
+// monitoring objects and of the federated Prometheus instances.
+func (cg *configGenerator) generateScrapeConfigs(
+	version semver.Version,
+	p *v1.Prometheus,
+	sMons map[string]*v1.ServiceMonitor,
+	pMons map[string]*v1.PodMonitor,
+	probes map[string]*v1.Probe,
+	nMons map[string]*v1.NodeMonitor,
+	basicAuthSecrets map[string]BasicAuthCredentials,
+	bearerTokens map[string]BearerToken,
+	authorizationSecrets map[string]string,
+	oauth2Secrets map[string]OAuth2Credentials,
+) []yaml.MapSlice {
 	sMonIdentifiers := make([]string, len(sMons))
 	i := 0
 	for k := range sMons {
@@ -239,181 +873,436 @@ func (cg *configGenerator) generateConfig(
 	// Sorting ensures, that we always generate the config in the same order.
 	sort.Strings(probeIdentifiers)
 
+	nMonIdentifiers := make([]string, len(nMons))
+	i = 0
+	for k := range nMons {
+		nMonIdentifiers[i] = k
+		i++
+	}
+
+	// Sorting ensures, that we always generate the config in the same order.
+	sort.Strings(nMonIdentifiers)
+
 	apiserverConfig := p.Spec.APIServerConfig
 
 	var scrapeConfigs []yaml.MapSlice
 	for _, identifier := range sMonIdentifiers {
+		// Unknown scrape classes have been rejected when selecting the
+		// monitoring objects.
+		sc, _ := scrapeClass(p, sMons[identifier].Spec.ScrapeClassName)
 		for i, ep := range sMons[identifier].Spec.Endpoints {
 			scrapeConfigs = append(scrapeConfigs,
 				cg.generateServiceMonitorConfig(
 					version,
 					sMons[identifier],
 					ep, i,
+					sc, p.Namespace,
 					apiserverConfig,
 					basicAuthSecrets,
 					bearerTokens,
+					authorizationSecrets,
+					oauth2Secrets,
 					p.Spec.OverrideHonorLabels,
 					p.Spec.OverrideHonorTimestamps,
 					p.Spec.IgnoreNamespaceSelectors,
 					p.Spec.EnforcedNamespaceLabel,
-					p.Spec.EnforcedSampleLimit))
+					p.Spec.EnforcedSampleLimit,
+					p.Spec.EnforcedLabelLimit,
+					p.Spec.EnforcedLabelNameLengthLimit,
+					p.Spec.EnforcedLabelValueLengthLimit,
+					p.Spec.EnforcedKeepDroppedTargets))
 		}
 	}
 	for _, identifier := range pMonIdentifiers {
+		sc, _ := scrapeClass(p, pMons[identifier].Spec.ScrapeClassName)
 		for i, ep := range pMons[identifier].Spec.PodMetricsEndpoints {
 			scrapeConfigs = append(scrapeConfigs,
 				cg.generatePodMonitorConfig(
 					version,
 					pMons[identifier], ep, i,
+					sc, p.Namespace,
 					apiserverConfig,
 					basicAuthSecrets,
+					authorizationSecrets,
+					oauth2Secrets,
 					p.Spec.OverrideHonorLabels,
 					p.Spec.OverrideHonorTimestamps,
 					p.Spec.IgnoreNamespaceSelectors,
 					p.Spec.EnforcedNamespaceLabel,
-					p.Spec.EnforcedSampleLimit))
+					p.Spec.EnforcedSampleLimit,
+					p.Spec.EnforcedLabelLimit,
+					p.Spec.EnforcedLabelNameLengthLimit,
+					p.Spec.EnforcedLabelValueLengthLimit,
+					p.Spec.EnforcedKeepDroppedTargets))
 		}
 	}
 
 	for _, identifier := range probeIdentifiers {
+		sc, _ := scrapeClass(p, probes[identifier].Spec.ScrapeClassName)
 		scrapeConfigs = append(scrapeConfigs,
 			cg.generateProbeConfig(
 				version,
 				probes[identifier],
+				sc, p.Namespace,
 				apiserverConfig,
 				basicAuthSecrets,
+				authorizationSecrets,
 				p.Spec.OverrideHonorLabels,
 				p.Spec.OverrideHonorTimestamps,
 				p.Spec.IgnoreNamespaceSelectors,
 				p.Spec.EnforcedNamespaceLabel,
+				p.Spec.EnforcedSampleLimit,
+				p.Spec.EnforcedTargetLimit,
+				p.Spec.EnforcedLabelLimit,
+				p.Spec.EnforcedLabelNameLengthLimit,
+				p.Spec.EnforcedLabelValueLengthLimit,
+				p.Spec.EnforcedKeepDroppedTargets,
 			),
 		)
 	}
 
-	var alertmanagerConfigs []yaml.MapSlice
-	if p.Spec.Alerting != nil {
-		for _, am := range p.Spec.Alerting.Alertmanagers {
-			alertmanagerConfigs = append(alertmanagerConfigs, cg.generateAlertmanagerConfig(version, am, apiserverConfig, basicAuthSecrets))
+	for _, identifier := range nMonIdentifiers {
+		for i, ep := range nMons[identifier].Spec.Endpoints {
+			scrapeConfigs = append(scrapeConfigs,
+				cg.generateNodeMonitorConfig(
+					version,
+					nMons[identifier], ep, i,
+					apiserverConfig,
+					basicAuthSecrets,
+					bearerTokens,
+					authorizationSecrets,
+					p.Spec.OverrideHonorLabels,
+					p.Spec.OverrideHonorTimestamps,
+					p.Spec.EnforcedNamespaceLabel,
+					p.Spec.EnforcedSampleLimit,
+					p.Spec.EnforcedLabelLimit,
+					p.Spec.EnforcedLabelNameLengthLimit,
+					p.Spec.EnforcedLabelValueLengthLimit,
+					p.Spec.EnforcedKeepDroppedTargets))
 		}
 	}
 
-	var additionalScrapeConfigsYaml []yaml.MapSlice
-	err = yaml.Unmarshal([]byte(additionalScrapeConfigs), &additionalScrapeConfigsYaml)
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshalling additional scrape configs failed")
+	for i, fed := range p.Spec.Federation {
+		scrapeConfigs = append(scrapeConfigs,
+			cg.generateFederationConfig(
+				version,
+				p, fed, i,
+				apiserverConfig,
+				basicAuthSecrets,
+				authorizationSecrets))
+	}
+
+	return scrapeConfigs
+}
+
+// honorLabels determines the value of honor_labels.
+// if overrideHonorLabels is true and user tries to set the
+// value to true, we want to set honor_labels to false.
+func honorLabels(userHonorLabels, overrideHonorLabels bool) bool {
+	if userHonorLabels && overrideHonorLabels {
+		return false
+	}
+	return userHonorLabels
+}
+
+// honorTimestamps adds option to enforce honor_timestamps option in scrape_config.
+// We want to disable honoring timestamps when user specified it or when global
+// override is set. For backwards compatibility with prometheus <2.9.0 we don't
+// set honor_timestamps when that option wasn't specified anywhere
+func honorTimestamps(cfg yaml.MapSlice, userHonorTimestamps *bool, overrideHonorTimestamps bool) yaml.MapSlice {
+	// Ensuring backwards compatibility by checking if user set any option
+	if userHonorTimestamps == nil && !overrideHonorTimestamps {
+		return cfg
+	}
+
+	honor := false
+	if userHonorTimestamps != nil {
+		honor = *userHonorTimestamps
+	}
+
+	return append(cfg, yaml.MapItem{Key: "honor_timestamps", Value: honor && !overrideHonorTimestamps})
+}
+
+func (cg *configGenerator) generatePodMonitorConfig(
+	version semver.Version,
+	m *v1.PodMonitor,
+	ep v1.PodMetricsEndpoint,
+	i int,
+	sc *v1.ScrapeClass,
+	promNamespace string,
+	apiserverConfig *v1.APIServerConfig,
+	basicAuthSecrets map[string]BasicAuthCredentials,
+	authorizationSecrets map[string]string,
+	oauth2Secrets map[string]OAuth2Credentials,
+	ignoreHonorLabels bool,
+	overrideHonorTimestamps bool,
+	ignoreNamespaceSelectors bool,
+	enforcedNamespaceLabel string,
+	enforcedSampleLimit *uint64,
+	enforcedLabelLimit *uint64,
+	enforcedLabelNameLengthLimit *uint64,
+	enforcedLabelValueLengthLimit *uint64,
+	enforcedKeepDroppedTargets *uint64) yaml.MapSlice {
+
+	hl := honorLabels(ep.HonorLabels, ignoreHonorLabels)
+	cfg := yaml.MapSlice{
+		{
+			Key:   "job_name",
+			Value: fmt.Sprintf("%s/%s/%d", m.Namespace, m.Name, i),
+		},
+		{
+			Key:   "honor_labels",
+			Value: hl,
+		},
+	}
+	if version.Major == 2 && version.Minor >= 9 {
+		cfg = honorTimestamps(cfg, ep.HonorTimestamps, overrideHonorTimestamps)
+	}
+
+	var roleSelectors []yaml.MapSlice
+	if version.Major == 1 && version.Minor < 7 {
+		if apiserverConfig != nil {
+			level.Info(cg.logger).Log("msg", "custom apiserver config is set but it will not take effect because prometheus version is < 1.7")
+		}
+		cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRolePod, nil, nil))
+	} else {
+		selectedNamespaces := getNamespacesFromNamespaceSelector(&m.Spec.NamespaceSelector, m.Namespace, ignoreNamespaceSelectors)
+		roleSelectors = cg.roleSelectors(version, m.Spec.SelectorMechanism, m.Spec.Selector, kubernetesSDRolePod)
+		cfg = append(cfg, cg.generateK8SSDConfig(selectedNamespaces, apiserverConfig, basicAuthSecrets, kubernetesSDRolePod, cg.attachMetadata(version, kubernetesSDRolePod, m.Spec.AttachMetadata), roleSelectors))
+	}
+
+	if ep.Interval != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "scrape_interval", Value: ep.Interval})
+	}
+	if ep.ScrapeTimeout != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "scrape_timeout", Value: ep.ScrapeTimeout})
+	}
+	if ep.Path != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "metrics_path", Value: ep.Path})
+	}
+	if ep.ProxyURL != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "proxy_url", Value: ep.ProxyURL})
+	}
+	if ep.Params != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "params", Value: ep.Params})
+	}
+	if ep.Scheme != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "scheme", Value: ep.Scheme})
+	}
+
+	if sc != nil {
+		cfg = addTLStoYaml(cfg, promNamespace, sc.TLSConfig)
+	}
+
+	if ep.Authorization != nil {
+		cfg = addAuthorizationToYaml(cfg, version, ep.Authorization, authorizationSecrets, fmt.Sprintf("podMonitor/%s/%s/%d", m.Namespace, m.Name, i))
+	} else if sc != nil && ep.OAuth2 == nil {
+		cfg = addAuthorizationToYaml(cfg, version, sc.Authorization, authorizationSecrets, scrapeClassKey(sc))
+	}
+
+	cfg = addOAuth2ToYaml(cfg, version, ep.OAuth2, oauth2Secrets, fmt.Sprintf("podMonitor/%s/%s/%d", m.Namespace, m.Name, i))
+
+	var (
+		relabelings []yaml.MapSlice
+		labelKeys   []string
+	)
+	// Filter targets by pods selected by the monitor, unless the service
+	// discovery already does it.
+	if roleSelectors == nil {
+		// Exact label matches.
+		for k := range m.Spec.Selector.MatchLabels {
+			labelKeys = append(labelKeys, k)
+		}
+		sort.Strings(labelKeys)
+
+		for _, k := range labelKeys {
+			relabelings = append(relabelings, yaml.MapSlice{
+				{Key: "action", Value: "keep"},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(k)}},
+				{Key: "regex", Value: m.Spec.Selector.MatchLabels[k]},
+			})
+		}
+		// Set based label matching. We have to map the valid relations
+		// `In`, `NotIn`, `Exists`, and `DoesNotExist`, into relabeling rules.
+		for _, exp := range m.Spec.Selector.MatchExpressions {
+			switch exp.Operator {
+			case metav1.LabelSelectorOpIn:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "keep"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: strings.Join(exp.Values, "|")},
+				})
+			case metav1.LabelSelectorOpNotIn:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "drop"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: strings.Join(exp.Values, "|")},
+				})
+			case metav1.LabelSelectorOpExists:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "keep"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: ".+"},
+				})
+			case metav1.LabelSelectorOpDoesNotExist:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "drop"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: ".+"},
+				})
+			}
+		}
+	}
+
+	if version.Major == 1 && version.Minor < 7 {
+		relabelings = appendPre17RelabelConfig(relabelings, m.Namespace, m.Spec.NamespaceSelector, ignoreNamespaceSelectors)
+	}
+
+	// Filter targets based on correct port for the endpoint.
+	if ep.Port != "" {
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "action", Value: "keep"},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_port_name"}},
+			{Key: "regex", Value: ep.Port},
+		})
+	} else if ep.TargetPort != nil {
+		level.Warn(cg.logger).Log("msg", "PodMonitor 'targetPort' is deprecated, use 'port' instead.",
+			"podMonitor", m.Name)
+		if ep.TargetPort.StrVal != "" {
+			relabelings = append(relabelings, yaml.MapSlice{
+				{Key: "action", Value: "keep"},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_port_name"}},
+				{Key: "regex", Value: ep.TargetPort.String()},
+			})
+		} else if ep.TargetPort.IntVal != 0 {
+			relabelings = append(relabelings, yaml.MapSlice{
+				{Key: "action", Value: "keep"},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_port_number"}},
+				{Key: "regex", Value: ep.TargetPort.String()},
+			})
+		}
 	}
 
-	cfg = append(cfg, yaml.MapItem{
-		Key:   "scrape_configs",
-		Value: append(scrapeConfigs, additionalScrapeConfigsYaml...),
-	})
+	// Relabel namespace and pod and service labels into proper labels.
+	relabelings = append(relabelings, []yaml.MapSlice{
+		{
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_namespace"}},
+			{Key: "target_label", Value: "namespace"},
+		},
+		{
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_name"}},
+			{Key: "target_label", Value: "container"},
+		},
+		{
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_name"}},
+			{Key: "target_label", Value: "pod"},
+		},
+	}...)
 
-	var additionalAlertManagerConfigsYaml []yaml.MapSlice
-	err = yaml.Unmarshal([]byte(additionalAlertManagerConfigs), &additionalAlertManagerConfigsYaml)
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshalling additional alert manager configs failed")
+	// Relabel targetLabels from Pod onto target.
+	for _, l := range m.Spec.PodTargetLabels {
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(l)}},
+			{Key: "target_label", Value: sanitizeLabelName(l)},
+			{Key: "regex", Value: "(.+)"},
+			{Key: "replacement", Value: "${1}"},
+		})
 	}
 
-	alertmanagerConfigs = append(alertmanagerConfigs, additionalAlertManagerConfigsYaml...)
-
-	var alertRelabelConfigs []yaml.MapSlice
-
-	// Use defaultReplicaExternalLabelName constant by default if field is missing.
-	// Do not add external label if field is set to empty string.
-	replicaExternalLabelName := defaultReplicaExternalLabelName
-	if p.Spec.ReplicaExternalLabelName != nil {
-		if *p.Spec.ReplicaExternalLabelName != "" {
-			replicaExternalLabelName = *p.Spec.ReplicaExternalLabelName
-		} else {
-			replicaExternalLabelName = ""
-		}
-	}
+	// By default, generate a safe job name from the PodMonitor. We also keep
+	// this around if a jobLabel is set in case the targets don't actually have a
+	// value for it. A single pod may potentially have multiple metrics
+	// endpoints, therefore the endpoints labels is filled with the ports name or
+	// as a fallback the port number.
 
-	// action 'labeldrop' is not supported <= v1.4.1
-	if replicaExternalLabelName != "" && version.GT(semver.MustParse("1.4.1")) {
-		// Drop replica label, to make alerts from multiple Prometheus replicas alike
-		alertRelabelConfigs = append(alertRelabelConfigs, yaml.MapSlice{
-			{Key: "action", Value: "labeldrop"},
-			{Key: "regex", Value: regexp.QuoteMeta(replicaExternalLabelName)},
+	relabelings = append(relabelings, yaml.MapSlice{
+		{Key: "target_label", Value: "job"},
+		{Key: "replacement", Value: fmt.Sprintf("%s/%s", m.GetNamespace(), m.GetName())},
+	})
+	if m.Spec.JobLabel != "" {
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(m.Spec.JobLabel)}},
+			{Key: "target_label", Value: "job"},
+			{Key: "regex", Value: "(.+)"},
+			{Key: "replacement", Value: "${1}"},
 		})
 	}
 
-	var additionalAlertRelabelConfigsYaml []yaml.MapSlice
-	err = yaml.Unmarshal([]byte(additionalAlertRelabelConfigs), &additionalAlertRelabelConfigsYaml)
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshalling additional alerting relabel configs failed")
+	if ep.Port != "" {
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "target_label", Value: "endpoint"},
+			{Key: "replacement", Value: ep.Port},
+		})
+	} else if ep.TargetPort != nil && ep.TargetPort.String() != "" {
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "target_label", Value: "endpoint"},
+			{Key: "replacement", Value: ep.TargetPort.String()},
+		})
 	}
 
-	cfg = append(cfg, yaml.MapItem{
-		Key: "alerting",
-		Value: yaml.MapSlice{
-			{
-				Key:   "alert_relabel_configs",
-				Value: append(alertRelabelConfigs, additionalAlertRelabelConfigsYaml...),
-			},
-			{
-				Key:   "alertmanagers",
-				Value: alertmanagerConfigs,
-			},
-		},
-	})
+	relabelings = append(relabelings, scrapeClassRelabelings(sc)...)
 
-	if len(p.Spec.RemoteWrite) > 0 && version.Major >= 2 {
-		cfg = append(cfg, cg.generateRemoteWriteConfig(version, p, basicAuthSecrets))
+	if ep.RelabelConfigs != nil {
+		for _, c := range ep.RelabelConfigs {
+			relabelings = append(relabelings, generateRelabelConfig(c))
+		}
 	}
+	// Because of security risks, whenever enforcedNamespaceLabel is set, we want to append it to the
+	// relabel_configs as the last relabeling, to ensure it overrides any other relabelings.
+	relabelings = enforceNamespaceLabel(relabelings, m.Namespace, enforcedNamespaceLabel)
+	cfg = append(cfg, yaml.MapItem{Key: "relabel_configs", Value: relabelings})
 
-	if len(p.Spec.RemoteRead) > 0 && version.Major >= 2 {
-		cfg = append(cfg, cg.generateRemoteReadConfig(version, p.Spec.RemoteRead, basicAuthSecrets))
+	if m.Spec.SampleLimit > 0 || enforcedSampleLimit != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "sample_limit", Value: getLimit(m.Spec.SampleLimit, enforcedSampleLimit)})
 	}
 
-	return yaml.Marshal(cfg)
-}
-
-// honorLabels determines the value of honor_labels.
-// if overrideHonorLabels is true and user tries to set the
-// value to true, we want to set honor_labels to false.
-func honorLabels(userHonorLabels, overrideHonorLabels bool) bool {
-	if userHonorLabels && overrideHonorLabels {
-		return false
-	}
-	return userHonorLabels
-}
+	cfg = addLabelLimits(cfg, version,
+		m.Spec.LabelLimit, enforcedLabelLimit,
+		m.Spec.LabelNameLengthLimit, enforcedLabelNameLengthLimit,
+		m.Spec.LabelValueLengthLimit, enforcedLabelValueLengthLimit,
+	)
+	cfg = addKeepDroppedTargets(cfg, version, m.Spec.KeepDroppedTargets, enforcedKeepDroppedTargets)
+	cfg = addNativeHistogramConfig(cfg, version, ep.ScrapeClassicHistograms, ep.NativeHistogramBucketLimit)
+	cfg = addScrapeProtocols(cfg, version, ep.ScrapeProtocols)
 
-// honorTimestamps adds option to enforce honor_timestamps option in scrape_config.
-// We want to disable honoring timestamps when user specified it or when global
-// override is set. For backwards compatibility with prometheus <2.9.0 we don't
-// set honor_timestamps when that option wasn't specified anywhere
-func honorTimestamps(cfg yaml.MapSlice, userHonorTimestamps *bool, overrideHonorTimestamps bool) yaml.MapSlice {
-	// Ensuring backwards compatibility by checking if user set any option
-	if userHonorTimestamps == nil && !overrideHonorTimestamps {
-		return cfg
-	}
+	if ep.MetricRelabelConfigs != nil {
+		var metricRelabelings []yaml.MapSlice
+		for _, c := range ep.MetricRelabelConfigs {
+			if c.TargetLabel != "" && enforcedNamespaceLabel != "" && c.TargetLabel == enforcedNamespaceLabel {
+				continue
+			}
+			relabeling := generateRelabelConfig(c)
 
-	honor := false
-	if userHonorTimestamps != nil {
-		honor = *userHonorTimestamps
+			metricRelabelings = append(metricRelabelings, relabeling)
+		}
+		cfg = append(cfg, yaml.MapItem{Key: "metric_relabel_configs", Value: metricRelabelings})
 	}
 
-	return append(cfg, yaml.MapItem{Key: "honor_timestamps", Value: honor && !overrideHonorTimestamps})
+	return cfg
 }
 
-func (cg *configGenerator) generatePodMonitorConfig(
+func (cg *configGenerator) generateNodeMonitorConfig(
 	version semver.Version,
-	m *v1.PodMonitor,
-	ep v1.PodMetricsEndpoint,
+	m *v1.NodeMonitor,
+	ep v1.NodeMetricsEndpoint,
 	i int, apiserverConfig *v1.APIServerConfig,
 	basicAuthSecrets map[string]BasicAuthCredentials,
+	bearerTokens map[string]BearerToken,
+	authorizationSecrets map[string]string,
 	ignoreHonorLabels bool,
 	overrideHonorTimestamps bool,
-	ignoreNamespaceSelectors bool,
 	enforcedNamespaceLabel string,
-	enforcedSampleLimit *uint64) yaml.MapSlice {
+	enforcedSampleLimit *uint64,
+	enforcedLabelLimit *uint64,
+	enforcedLabelNameLengthLimit *uint64,
+	enforcedLabelValueLengthLimit *uint64,
+	enforcedKeepDroppedTargets *uint64) yaml.MapSlice {
 
 	hl := honorLabels(ep.HonorLabels, ignoreHonorLabels)
 	cfg := yaml.MapSlice{
 		{
 			Key:   "job_name",
-			Value: fmt.Sprintf("%s/%s/%d", m.Namespace, m.Name, i),
+			Value: fmt.Sprintf("nodeMonitor/%s/%s/%d", m.Namespace, m.Name, i),
 		},
 		{
 			Key:   "honor_labels",
@@ -424,15 +1313,9 @@ func (cg *configGenerator) generatePodMonitorConfig(
 		cfg = honorTimestamps(cfg, ep.HonorTimestamps, overrideHonorTimestamps)
 	}
 
-	if version.Major == 1 && version.Minor < 7 {
-		if apiserverConfig != nil {
-			level.Info(cg.logger).Log("msg", "custom apiserver config is set but it will not take effect because prometheus version is < 1.7")
-		}
-		cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRolePod))
-	} else {
-		selectedNamespaces := getNamespacesFromNamespaceSelector(&m.Spec.NamespaceSelector, m.Namespace, ignoreNamespaceSelectors)
-		cfg = append(cfg, cg.generateK8SSDConfig(selectedNamespaces, apiserverConfig, basicAuthSecrets, kubernetesSDRolePod))
-	}
+	// Nodes aren't namespaced resources hence the service discovery doesn't
+	// need to be restricted to any namespace.
+	cfg = append(cfg, cg.generateK8SSDConfig(nil, apiserverConfig, basicAuthSecrets, kubernetesSDRoleNode, nil, nil))
 
 	if ep.Interval != "" {
 		cfg = append(cfg, yaml.MapItem{Key: "scrape_interval", Value: ep.Interval})
@@ -453,11 +1336,40 @@ func (cg *configGenerator) generatePodMonitorConfig(
 		cfg = append(cfg, yaml.MapItem{Key: "scheme", Value: ep.Scheme})
 	}
 
+	cfg = addTLStoYaml(cfg, m.Namespace, ep.TLSConfig)
+
+	if ep.BearerTokenFile != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "bearer_token_file", Value: ep.BearerTokenFile})
+	}
+
+	if ep.BearerTokenBoundServiceAccount {
+		cfg = append(cfg, yaml.MapItem{Key: "bearer_token_file", Value: path.Join(boundTokenDir, boundTokenFilename)})
+	}
+
+	if ep.BearerTokenSecret.Name != "" {
+		if s, ok := bearerTokens[fmt.Sprintf("nodeMonitor/%s/%s/%d", m.Namespace, m.Name, i)]; ok {
+			cfg = append(cfg, yaml.MapItem{Key: "bearer_token", Value: s})
+		}
+	}
+
+	if ep.BasicAuth != nil {
+		if s, ok := basicAuthSecrets[fmt.Sprintf("nodeMonitor/%s/%s/%d", m.Namespace, m.Name, i)]; ok {
+			cfg = append(cfg, yaml.MapItem{
+				Key: "basic_auth", Value: yaml.MapSlice{
+					{Key: "username", Value: s.username},
+					{Key: "password", Value: s.password},
+				},
+			})
+		}
+	}
+
+	cfg = addAuthorizationToYaml(cfg, version, ep.Authorization, authorizationSecrets, fmt.Sprintf("nodeMonitor/%s/%s/%d", m.Namespace, m.Name, i))
+
 	var (
 		relabelings []yaml.MapSlice
 		labelKeys   []string
 	)
-	// Filter targets by pods selected by the monitor.
+	// Filter targets by nodes selected by the monitor.
 	// Exact label matches.
 	for k := range m.Spec.Selector.MatchLabels {
 		labelKeys = append(labelKeys, k)
@@ -467,7 +1379,7 @@ func (cg *configGenerator) generatePodMonitorConfig(
 	for _, k := range labelKeys {
 		relabelings = append(relabelings, yaml.MapSlice{
 			{Key: "action", Value: "keep"},
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(k)}},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(k)}},
 			{Key: "regex", Value: m.Spec.Selector.MatchLabels[k]},
 		})
 	}
@@ -478,113 +1390,93 @@ func (cg *configGenerator) generatePodMonitorConfig(
 		case metav1.LabelSelectorOpIn:
 			relabelings = append(relabelings, yaml.MapSlice{
 				{Key: "action", Value: "keep"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(exp.Key)}},
 				{Key: "regex", Value: strings.Join(exp.Values, "|")},
 			})
 		case metav1.LabelSelectorOpNotIn:
 			relabelings = append(relabelings, yaml.MapSlice{
 				{Key: "action", Value: "drop"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(exp.Key)}},
 				{Key: "regex", Value: strings.Join(exp.Values, "|")},
 			})
 		case metav1.LabelSelectorOpExists:
 			relabelings = append(relabelings, yaml.MapSlice{
 				{Key: "action", Value: "keep"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(exp.Key)}},
 				{Key: "regex", Value: ".+"},
 			})
 		case metav1.LabelSelectorOpDoesNotExist:
 			relabelings = append(relabelings, yaml.MapSlice{
 				{Key: "action", Value: "drop"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(exp.Key)}},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(exp.Key)}},
 				{Key: "regex", Value: ".+"},
 			})
 		}
 	}
 
-	if version.Major == 1 && version.Minor < 7 {
-		relabelings = appendPre17RelabelConfig(relabelings, m.Namespace, m.Spec.NamespaceSelector, ignoreNamespaceSelectors)
-	}
-
-	// Filter targets based on correct port for the endpoint.
-	if ep.Port != "" {
+	// The service discovery sets the target address to the first node
+	// address and the Kubelet port. Rewrite it when the endpoint requests a
+	// specific address type and/or port.
+	switch {
+	case ep.AddressType != "" && ep.Port != nil:
 		relabelings = append(relabelings, yaml.MapSlice{
-			{Key: "action", Value: "keep"},
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_port_name"}},
-			{Key: "regex", Value: ep.Port},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_node_address_" + ep.AddressType}},
+			{Key: "target_label", Value: "__address__"},
+			{Key: "regex", Value: "(.+)"},
+			{Key: "replacement", Value: fmt.Sprintf("${1}:%d", *ep.Port)},
+		})
+	case ep.AddressType != "":
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_node_address_" + ep.AddressType, "__address__"}},
+			{Key: "target_label", Value: "__address__"},
+			{Key: "regex", Value: `(.+);.*:(\d+)`},
+			{Key: "replacement", Value: "${1}:${2}"},
+		})
+	case ep.Port != nil:
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "source_labels", Value: []string{"__address__"}},
+			{Key: "target_label", Value: "__address__"},
+			{Key: "regex", Value: `(.+):\d+`},
+			{Key: "replacement", Value: fmt.Sprintf("${1}:%d", *ep.Port)},
 		})
-	} else if ep.TargetPort != nil {
-		level.Warn(cg.logger).Log("msg", "PodMonitor 'targetPort' is deprecated, use 'port' instead.",
-			"podMonitor", m.Name)
-		if ep.TargetPort.StrVal != "" {
-			relabelings = append(relabelings, yaml.MapSlice{
-				{Key: "action", Value: "keep"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_port_name"}},
-				{Key: "regex", Value: ep.TargetPort.String()},
-			})
-		} else if ep.TargetPort.IntVal != 0 {
-			relabelings = append(relabelings, yaml.MapSlice{
-				{Key: "action", Value: "keep"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_port_number"}},
-				{Key: "regex", Value: ep.TargetPort.String()},
-			})
-		}
 	}
 
-	// Relabel namespace and pod and service labels into proper labels.
-	relabelings = append(relabelings, []yaml.MapSlice{
-		{
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_namespace"}},
-			{Key: "target_label", Value: "namespace"},
-		},
-		{
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_container_name"}},
-			{Key: "target_label", Value: "container"},
-		},
-		{
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_name"}},
-			{Key: "target_label", Value: "pod"},
-		},
-	}...)
+	// Relabel node name into a proper label.
+	relabelings = append(relabelings, yaml.MapSlice{
+		{Key: "source_labels", Value: []string{"__meta_kubernetes_node_name"}},
+		{Key: "target_label", Value: "node"},
+	})
 
-	// Relabel targetLabels from Pod onto target.
-	for _, l := range m.Spec.PodTargetLabels {
+	// Relabel targetLabels from Node onto target.
+	for _, l := range m.Spec.NodeTargetLabels {
 		relabelings = append(relabelings, yaml.MapSlice{
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(l)}},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(l)}},
 			{Key: "target_label", Value: sanitizeLabelName(l)},
 			{Key: "regex", Value: "(.+)"},
 			{Key: "replacement", Value: "${1}"},
 		})
 	}
 
-	// By default, generate a safe job name from the PodMonitor. We also keep
+	// By default, generate a safe job name from the NodeMonitor. We also keep
 	// this around if a jobLabel is set in case the targets don't actually have a
-	// value for it. A single pod may potentially have multiple metrics
-	// endpoints, therefore the endpoints labels is filled with the ports name or
-	// as a fallback the port number.
-
+	// value for it.
 	relabelings = append(relabelings, yaml.MapSlice{
 		{Key: "target_label", Value: "job"},
 		{Key: "replacement", Value: fmt.Sprintf("%s/%s", m.GetNamespace(), m.GetName())},
 	})
 	if m.Spec.JobLabel != "" {
 		relabelings = append(relabelings, yaml.MapSlice{
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_" + sanitizeLabelName(m.Spec.JobLabel)}},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_node_label_" + sanitizeLabelName(m.Spec.JobLabel)}},
 			{Key: "target_label", Value: "job"},
 			{Key: "regex", Value: "(.+)"},
 			{Key: "replacement", Value: "${1}"},
 		})
 	}
 
-	if ep.Port != "" {
-		relabelings = append(relabelings, yaml.MapSlice{
-			{Key: "target_label", Value: "endpoint"},
-			{Key: "replacement", Value: ep.Port},
-		})
-	} else if ep.TargetPort != nil && ep.TargetPort.String() != "" {
+	if ep.Port != nil {
 		relabelings = append(relabelings, yaml.MapSlice{
 			{Key: "target_label", Value: "endpoint"},
-			{Key: "replacement", Value: ep.TargetPort.String()},
+			{Key: "replacement", Value: fmt.Sprintf("%d", *ep.Port)},
 		})
 	}
 
@@ -599,9 +1491,16 @@ func (cg *configGenerator) generatePodMonitorConfig(
 	cfg = append(cfg, yaml.MapItem{Key: "relabel_configs", Value: relabelings})
 
 	if m.Spec.SampleLimit > 0 || enforcedSampleLimit != nil {
-		cfg = append(cfg, yaml.MapItem{Key: "sample_limit", Value: getSampleLimit(m.Spec.SampleLimit, enforcedSampleLimit)})
+		cfg = append(cfg, yaml.MapItem{Key: "sample_limit", Value: getLimit(m.Spec.SampleLimit, enforcedSampleLimit)})
 	}
 
+	cfg = addLabelLimits(cfg, version,
+		m.Spec.LabelLimit, enforcedLabelLimit,
+		m.Spec.LabelNameLengthLimit, enforcedLabelNameLengthLimit,
+		m.Spec.LabelValueLengthLimit, enforcedLabelValueLengthLimit,
+	)
+	cfg = addKeepDroppedTargets(cfg, version, m.Spec.KeepDroppedTargets, enforcedKeepDroppedTargets)
+
 	if ep.MetricRelabelConfigs != nil {
 		var metricRelabelings []yaml.MapSlice
 		for _, c := range ep.MetricRelabelConfigs {
@@ -621,12 +1520,21 @@ func (cg *configGenerator) generatePodMonitorConfig(
 func (cg *configGenerator) generateProbeConfig(
 	version semver.Version,
 	m *v1.Probe,
+	sc *v1.ScrapeClass,
+	promNamespace string,
 	apiserverConfig *v1.APIServerConfig,
 	basicAuthSecrets map[string]BasicAuthCredentials,
+	authorizationSecrets map[string]string,
 	ignoreHonorLabels bool,
 	overrideHonorTimestamps bool,
 	ignoreNamespaceSelectors bool,
-	enforcedNamespaceLabel string) yaml.MapSlice {
+	enforcedNamespaceLabel string,
+	enforcedSampleLimit *uint64,
+	enforcedTargetLimit *uint64,
+	enforcedLabelLimit *uint64,
+	enforcedLabelNameLengthLimit *uint64,
+	enforcedLabelValueLengthLimit *uint64,
+	enforcedKeepDroppedTargets *uint64) yaml.MapSlice {
 
 	jobName := fmt.Sprintf("%s/%s", m.Namespace, m.Name)
 	if m.Spec.JobName != "" {
@@ -658,6 +1566,16 @@ func (cg *configGenerator) generateProbeConfig(
 		cfg = append(cfg, yaml.MapItem{Key: "scheme", Value: m.Spec.ProberSpec.Scheme})
 	}
 
+	if sc != nil {
+		cfg = addTLStoYaml(cfg, promNamespace, sc.TLSConfig)
+	}
+
+	if m.Spec.Authorization != nil {
+		cfg = addAuthorizationToYaml(cfg, version, m.Spec.Authorization, authorizationSecrets, fmt.Sprintf("probe/%s/%s", m.Namespace, m.Name))
+	} else if sc != nil {
+		cfg = addAuthorizationToYaml(cfg, version, sc.Authorization, authorizationSecrets, scrapeClassKey(sc))
+	}
+
 	cfg = append(cfg, yaml.MapItem{Key: "params", Value: yaml.MapSlice{
 		{Key: "module", Value: []string{m.Spec.Module}},
 	}})
@@ -666,19 +1584,49 @@ func (cg *configGenerator) generateProbeConfig(
 
 	// Generate static_config section.
 	if m.Spec.Targets.StaticConfig != nil {
-		staticConfig := yaml.MapSlice{
-			{Key: "targets", Value: m.Spec.Targets.StaticConfig.Targets},
+		var staticConfigs []yaml.MapSlice
+		if len(m.Spec.Targets.StaticConfig.Targets) > 0 || len(m.Spec.Targets.StaticConfig.StaticTargets) == 0 {
+			staticConfig := yaml.MapSlice{
+				{Key: "targets", Value: m.Spec.Targets.StaticConfig.Targets},
+			}
+
+			if m.Spec.Targets.StaticConfig.Labels != nil {
+				staticConfig = append(staticConfig, yaml.MapSlice{
+					{Key: "labels", Value: m.Spec.Targets.StaticConfig.Labels},
+				}...)
+			}
+
+			staticConfigs = append(staticConfigs, staticConfig)
 		}
 
-		if m.Spec.Targets.StaticConfig.Labels != nil {
-			staticConfig = append(staticConfig, yaml.MapSlice{
-				{Key: "labels", Value: m.Spec.Targets.StaticConfig.Labels},
-			}...)
+		// Each target with its own labels and parameters gets a separate
+		// group. The __param_<name> labels override the parameters of the job.
+		var moduleOverride bool
+		for _, t := range m.Spec.Targets.StaticConfig.StaticTargets {
+			labels := make(map[string]string, len(m.Spec.Targets.StaticConfig.Labels)+len(t.Labels)+len(t.Params)+1)
+			for k, v := range m.Spec.Targets.StaticConfig.Labels {
+				labels[k] = v
+			}
+			for k, v := range t.Labels {
+				labels[k] = v
+			}
+			for k, v := range t.Params {
+				labels["__param_"+k] = v
+			}
+			if t.Module != "" {
+				labels["__param_module"] = t.Module
+				moduleOverride = true
+			}
+
+			staticConfigs = append(staticConfigs, yaml.MapSlice{
+				{Key: "targets", Value: []string{t.URL}},
+				{Key: "labels", Value: labels},
+			})
 		}
 
 		cfg = append(cfg, yaml.MapItem{
 			Key:   "static_configs",
-			Value: []yaml.MapSlice{staticConfig},
+			Value: staticConfigs,
 		})
 
 		// Relabelings for prober.
@@ -691,12 +1639,23 @@ func (cg *configGenerator) generateProbeConfig(
 				{Key: "source_labels", Value: []string{"__param_target"}},
 				{Key: "target_label", Value: "instance"},
 			},
-			{
-				{Key: "target_label", Value: "__address__"},
-				{Key: "replacement", Value: m.Spec.ProberSpec.URL},
-			},
 		}...)
 
+		// The same target may be probed with different modules.
+		if moduleOverride {
+			relabelings = append(relabelings, yaml.MapSlice{
+				{Key: "source_labels", Value: []string{"__param_module"}},
+				{Key: "target_label", Value: "module"},
+			})
+		}
+
+		relabelings = append(relabelings, yaml.MapSlice{
+			{Key: "target_label", Value: "__address__"},
+			{Key: "replacement", Value: m.Spec.ProberSpec.URL},
+		})
+
+		relabelings = append(relabelings, scrapeClassRelabelings(sc)...)
+
 		cfg = append(cfg, yaml.MapItem{Key: "relabel_configs", Value: enforceNamespaceLabel(relabelings, m.Namespace, enforcedNamespaceLabel)})
 	}
 
@@ -754,10 +1713,10 @@ func (cg *configGenerator) generateProbeConfig(
 			if apiserverConfig != nil {
 				level.Info(cg.logger).Log("msg", "custom apiserver config is set but it will not take effect because prometheus version is < 1.7")
 			}
-			cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRoleIngress))
+			cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRoleIngress, nil, nil))
 		} else {
 			selectedNamespaces := getNamespacesFromNamespaceSelector(&m.Spec.Targets.Ingress.NamespaceSelector, m.Namespace, ignoreNamespaceSelectors)
-			cfg = append(cfg, cg.generateK8SSDConfig(selectedNamespaces, apiserverConfig, basicAuthSecrets, kubernetesSDRoleIngress))
+			cfg = append(cfg, cg.generateK8SSDConfig(selectedNamespaces, apiserverConfig, basicAuthSecrets, kubernetesSDRoleIngress, nil, nil))
 		}
 
 		// Relabelings for ingress SD.
@@ -792,6 +1751,8 @@ func (cg *configGenerator) generateProbeConfig(
 			},
 		}...)
 
+		relabelings = append(relabelings, scrapeClassRelabelings(sc)...)
+
 		// Add configured relabelings.
 		if m.Spec.Targets.Ingress.RelabelConfigs != nil {
 			for _, r := range m.Spec.Targets.Ingress.RelabelConfigs {
@@ -804,6 +1765,21 @@ func (cg *configGenerator) generateProbeConfig(
 
 	}
 
+	if m.Spec.SampleLimit > 0 || enforcedSampleLimit != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "sample_limit", Value: getLimit(m.Spec.SampleLimit, enforcedSampleLimit)})
+	}
+
+	if version.GTE(semver.MustParse("2.21.0")) && (m.Spec.TargetLimit > 0 || enforcedTargetLimit != nil) {
+		cfg = append(cfg, yaml.MapItem{Key: "target_limit", Value: getLimit(m.Spec.TargetLimit, enforcedTargetLimit)})
+	}
+
+	cfg = addLabelLimits(cfg, version,
+		m.Spec.LabelLimit, enforcedLabelLimit,
+		m.Spec.LabelNameLengthLimit, enforcedLabelNameLengthLimit,
+		m.Spec.LabelValueLengthLimit, enforcedLabelValueLengthLimit,
+	)
+	cfg = addKeepDroppedTargets(cfg, version, m.Spec.KeepDroppedTargets, enforcedKeepDroppedTargets)
+
 	return cfg
 }
 
@@ -812,16 +1788,33 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 	m *v1.ServiceMonitor,
 	ep v1.Endpoint,
 	i int,
+	sc *v1.ScrapeClass,
+	promNamespace string,
 	apiserverConfig *v1.APIServerConfig,
 	basicAuthSecrets map[string]BasicAuthCredentials,
 	bearerTokens map[string]BearerToken,
+	authorizationSecrets map[string]string,
+	oauth2Secrets map[string]OAuth2Credentials,
 	overrideHonorLabels bool,
 	overrideHonorTimestamps bool,
 	ignoreNamespaceSelectors bool,
 	enforcedNamespaceLabel string,
-	enforcedSampleLimit *uint64) yaml.MapSlice {
+	enforcedSampleLimit *uint64,
+	enforcedLabelLimit *uint64,
+	enforcedLabelNameLengthLimit *uint64,
+	enforcedLabelValueLengthLimit *uint64,
+	enforcedKeepDroppedTargets *uint64) yaml.MapSlice {
 
 	hl := honorLabels(ep.HonorLabels, overrideHonorLabels)
+
+	role := cg.serviceMonitorSDRole(version, m)
+	// The endpointslice role exposes the same meta labels as the endpoints
+	// role under a different prefix.
+	endpointMetaLabelPrefix := "__meta_kubernetes_endpoint_"
+	if role == kubernetesSDRoleEndpointSlice {
+		endpointMetaLabelPrefix = "__meta_kubernetes_endpointslice_"
+	}
+
 	cfg := yaml.MapSlice{
 		{
 			Key:   "job_name",
@@ -836,14 +1829,18 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 		cfg = honorTimestamps(cfg, ep.HonorTimestamps, overrideHonorTimestamps)
 	}
 
+	var roleSelectors []yaml.MapSlice
 	if version.Major == 1 && version.Minor < 7 {
 		if apiserverConfig != nil {
 			level.Info(cg.logger).Log("msg", "custom apiserver config is set but it will not take effect because prometheus version is < 1.7")
 		}
-		cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRoleEndpoint))
+		cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRoleEndpoint, nil, nil))
 	} else {
 		selectedNamespaces := getNamespacesFromNamespaceSelector(&m.Spec.NamespaceSelector, m.Namespace, ignoreNamespaceSelectors)
-		cfg = append(cfg, cg.generateK8SSDConfig(selectedNamespaces, apiserverConfig, basicAuthSecrets, kubernetesSDRoleEndpoint))
+		// The Endpoints and EndpointSlice objects carry the labels of their
+		// Service.
+		roleSelectors = cg.roleSelectors(version, m.Spec.SelectorMechanism, m.Spec.Selector, role, kubernetesSDRoleService)
+		cfg = append(cfg, cg.generateK8SSDConfig(selectedNamespaces, apiserverConfig, basicAuthSecrets, role, cg.attachMetadata(version, role, m.Spec.AttachMetadata), roleSelectors))
 	}
 
 	if ep.Interval != "" {
@@ -865,12 +1862,20 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 		cfg = append(cfg, yaml.MapItem{Key: "scheme", Value: ep.Scheme})
 	}
 
-	cfg = addTLStoYaml(cfg, m.Namespace, ep.TLSConfig)
+	if ep.TLSConfig != nil {
+		cfg = addTLStoYaml(cfg, m.Namespace, ep.TLSConfig)
+	} else if sc != nil {
+		cfg = addTLStoYaml(cfg, promNamespace, sc.TLSConfig)
+	}
 
 	if ep.BearerTokenFile != "" {
 		cfg = append(cfg, yaml.MapItem{Key: "bearer_token_file", Value: ep.BearerTokenFile})
 	}
 
+	if ep.BearerTokenBoundServiceAccount {
+		cfg = append(cfg, yaml.MapItem{Key: "bearer_token_file", Value: path.Join(boundTokenDir, boundTokenFilename)})
+	}
+
 	if ep.BearerTokenSecret.Name != "" {
 		if s, ok := bearerTokens[fmt.Sprintf("serviceMonitor/%s/%s/%d", m.Namespace, m.Name, i)]; ok {
 			cfg = append(cfg, yaml.MapItem{Key: "bearer_token", Value: s})
@@ -888,53 +1893,63 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 		}
 	}
 
-	var relabelings []yaml.MapSlice
+	if ep.Authorization != nil {
+		cfg = addAuthorizationToYaml(cfg, version, ep.Authorization, authorizationSecrets, fmt.Sprintf("serviceMonitor/%s/%s/%d", m.Namespace, m.Name, i))
+	} else if sc != nil && ep.BasicAuth == nil && ep.BearerTokenFile == "" && ep.BearerTokenSecret.Name == "" && !ep.BearerTokenBoundServiceAccount && ep.OAuth2 == nil {
+		cfg = addAuthorizationToYaml(cfg, version, sc.Authorization, authorizationSecrets, scrapeClassKey(sc))
+	}
 
-	// Filter targets by services selected by the monitor.
+	cfg = addOAuth2ToYaml(cfg, version, ep.OAuth2, oauth2Secrets, fmt.Sprintf("serviceMonitor/%s/%s/%d", m.Namespace, m.Name, i))
 
-	// Exact label matches.
-	var labelKeys []string
-	for k := range m.Spec.Selector.MatchLabels {
-		labelKeys = append(labelKeys, k)
-	}
-	sort.Strings(labelKeys)
+	var relabelings []yaml.MapSlice
 
-	for _, k := range labelKeys {
-		relabelings = append(relabelings, yaml.MapSlice{
-			{Key: "action", Value: "keep"},
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(k)}},
-			{Key: "regex", Value: m.Spec.Selector.MatchLabels[k]},
-		})
-	}
-	// Set based label matching. We have to map the valid relations
-	// `In`, `NotIn`, `Exists`, and `DoesNotExist`, into relabeling rules.
-	for _, exp := range m.Spec.Selector.MatchExpressions {
-		switch exp.Operator {
-		case metav1.LabelSelectorOpIn:
-			relabelings = append(relabelings, yaml.MapSlice{
-				{Key: "action", Value: "keep"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
-				{Key: "regex", Value: strings.Join(exp.Values, "|")},
-			})
-		case metav1.LabelSelectorOpNotIn:
-			relabelings = append(relabelings, yaml.MapSlice{
-				{Key: "action", Value: "drop"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
-				{Key: "regex", Value: strings.Join(exp.Values, "|")},
-			})
-		case metav1.LabelSelectorOpExists:
+	// Filter targets by services selected by the monitor, unless the
+	// service discovery already does it.
+	if roleSelectors == nil {
+		// Exact label matches.
+		var labelKeys []string
+		for k := range m.Spec.Selector.MatchLabels {
+			labelKeys = append(labelKeys, k)
+		}
+		sort.Strings(labelKeys)
+
+		for _, k := range labelKeys {
 			relabelings = append(relabelings, yaml.MapSlice{
 				{Key: "action", Value: "keep"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
-				{Key: "regex", Value: ".+"},
-			})
-		case metav1.LabelSelectorOpDoesNotExist:
-			relabelings = append(relabelings, yaml.MapSlice{
-				{Key: "action", Value: "drop"},
-				{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
-				{Key: "regex", Value: ".+"},
+				{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(k)}},
+				{Key: "regex", Value: m.Spec.Selector.MatchLabels[k]},
 			})
 		}
+		// Set based label matching. We have to map the valid relations
+		// `In`, `NotIn`, `Exists`, and `DoesNotExist`, into relabeling rules.
+		for _, exp := range m.Spec.Selector.MatchExpressions {
+			switch exp.Operator {
+			case metav1.LabelSelectorOpIn:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "keep"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: strings.Join(exp.Values, "|")},
+				})
+			case metav1.LabelSelectorOpNotIn:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "drop"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: strings.Join(exp.Values, "|")},
+				})
+			case metav1.LabelSelectorOpExists:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "keep"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: ".+"},
+				})
+			case metav1.LabelSelectorOpDoesNotExist:
+				relabelings = append(relabelings, yaml.MapSlice{
+					{Key: "action", Value: "drop"},
+					{Key: "source_labels", Value: []string{"__meta_kubernetes_service_label_" + sanitizeLabelName(exp.Key)}},
+					{Key: "regex", Value: ".+"},
+				})
+			}
+		}
 	}
 
 	if version.Major == 1 && version.Minor < 7 {
@@ -945,7 +1960,7 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 	if ep.Port != "" {
 		relabelings = append(relabelings, yaml.MapSlice{
 			{Key: "action", Value: "keep"},
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_endpoint_port_name"}},
+			{Key: "source_labels", Value: []string{endpointMetaLabelPrefix + "port_name"}},
 			{Key: "regex", Value: ep.Port},
 		})
 	} else if ep.TargetPort != nil {
@@ -967,14 +1982,14 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 	// Relabel namespace and pod and service labels into proper labels.
 	relabelings = append(relabelings, []yaml.MapSlice{
 		{ // Relabel node labels for pre v2.3 meta labels
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_endpoint_address_target_kind", "__meta_kubernetes_endpoint_address_target_name"}},
+			{Key: "source_labels", Value: []string{endpointMetaLabelPrefix + "address_target_kind", endpointMetaLabelPrefix + "address_target_name"}},
 			{Key: "separator", Value: ";"},
 			{Key: "regex", Value: "Node;(.*)"},
 			{Key: "replacement", Value: "${1}"},
 			{Key: "target_label", Value: "node"},
 		},
 		{ // Relabel pod labels for >=v2.3 meta labels
-			{Key: "source_labels", Value: []string{"__meta_kubernetes_endpoint_address_target_kind", "__meta_kubernetes_endpoint_address_target_name"}},
+			{Key: "source_labels", Value: []string{endpointMetaLabelPrefix + "address_target_kind", endpointMetaLabelPrefix + "address_target_name"}},
 			{Key: "separator", Value: ";"},
 			{Key: "regex", Value: "Pod;(.*)"},
 			{Key: "replacement", Value: "${1}"},
@@ -1049,6 +2064,8 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 		})
 	}
 
+	relabelings = append(relabelings, scrapeClassRelabelings(sc)...)
+
 	if ep.RelabelConfigs != nil {
 		for _, c := range ep.RelabelConfigs {
 			relabelings = append(relabelings, generateRelabelConfig(c))
@@ -1060,9 +2077,18 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 	cfg = append(cfg, yaml.MapItem{Key: "relabel_configs", Value: relabelings})
 
 	if m.Spec.SampleLimit > 0 || enforcedSampleLimit != nil {
-		cfg = append(cfg, yaml.MapItem{Key: "sample_limit", Value: getSampleLimit(m.Spec.SampleLimit, enforcedSampleLimit)})
+		cfg = append(cfg, yaml.MapItem{Key: "sample_limit", Value: getLimit(m.Spec.SampleLimit, enforcedSampleLimit)})
 	}
 
+	cfg = addLabelLimits(cfg, version,
+		m.Spec.LabelLimit, enforcedLabelLimit,
+		m.Spec.LabelNameLengthLimit, enforcedLabelNameLengthLimit,
+		m.Spec.LabelValueLengthLimit, enforcedLabelValueLengthLimit,
+	)
+	cfg = addKeepDroppedTargets(cfg, version, m.Spec.KeepDroppedTargets, enforcedKeepDroppedTargets)
+	cfg = addNativeHistogramConfig(cfg, version, ep.ScrapeClassicHistograms, ep.NativeHistogramBucketLimit)
+	cfg = addScrapeProtocols(cfg, version, ep.ScrapeProtocols)
+
 	if ep.MetricRelabelConfigs != nil {
 		var metricRelabelings []yaml.MapSlice
 		for _, c := range ep.MetricRelabelConfigs {
@@ -1079,7 +2105,80 @@ func (cg *configGenerator) generateServiceMonitorConfig(
 	return cfg
 }
 
-func getSampleLimit(user uint64, enforced *uint64) uint64 {
+// addLabelLimits appends the limits on the labels of the scraped samples,
+// clamped to the enforced limits, for Prometheus versions supporting them.
+func addLabelLimits(
+	cfg yaml.MapSlice,
+	version semver.Version,
+	labelLimit uint64, enforcedLabelLimit *uint64,
+	labelNameLengthLimit uint64, enforcedLabelNameLengthLimit *uint64,
+	labelValueLengthLimit uint64, enforcedLabelValueLengthLimit *uint64,
+) yaml.MapSlice {
+	if !version.GTE(semver.MustParse("2.27.0")) {
+		return cfg
+	}
+
+	if labelLimit > 0 || enforcedLabelLimit != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "label_limit", Value: getLimit(labelLimit, enforcedLabelLimit)})
+	}
+
+	if labelNameLengthLimit > 0 || enforcedLabelNameLengthLimit != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "label_name_length_limit", Value: getLimit(labelNameLengthLimit, enforcedLabelNameLengthLimit)})
+	}
+
+	if labelValueLengthLimit > 0 || enforcedLabelValueLengthLimit != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "label_value_length_limit", Value: getLimit(labelValueLengthLimit, enforcedLabelValueLengthLimit)})
+	}
+
+	return cfg
+}
+
+// addKeepDroppedTargets appends the limit on the targets dropped by
+// relabeling kept in memory, clamped to the enforced limit, for Prometheus
+// versions supporting it.
+func addKeepDroppedTargets(cfg yaml.MapSlice, version semver.Version, keepDroppedTargets uint64, enforced *uint64) yaml.MapSlice {
+	if !version.GTE(semver.MustParse("2.47.0")) {
+		return cfg
+	}
+
+	if keepDroppedTargets > 0 || enforced != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "keep_dropped_targets", Value: getLimit(keepDroppedTargets, enforced)})
+	}
+
+	return cfg
+}
+
+// addNativeHistogramConfig appends the native histogram scrape options for
+// Prometheus versions supporting them.
+func addNativeHistogramConfig(cfg yaml.MapSlice, version semver.Version, scrapeClassicHistograms *bool, bucketLimit *uint64) yaml.MapSlice {
+	if !version.GTE(semver.MustParse("2.45.0")) {
+		return cfg
+	}
+
+	if scrapeClassicHistograms != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "scrape_classic_histograms", Value: *scrapeClassicHistograms})
+	}
+
+	if bucketLimit != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "native_histogram_bucket_limit", Value: *bucketLimit})
+	}
+
+	return cfg
+}
+
+// addScrapeProtocols appends the protocols negotiated when scraping the
+// targets for Prometheus versions supporting them.
+func addScrapeProtocols(cfg yaml.MapSlice, version semver.Version, protocols []v1.ScrapeProtocol) yaml.MapSlice {
+	if len(protocols) == 0 || !version.GTE(semver.MustParse("2.49.0")) {
+		return cfg
+	}
+
+	return append(cfg, yaml.MapItem{Key: "scrape_protocols", Value: protocols})
+}
+
+// getLimit returns the limit set by the user, clamped to the enforced limit
+// if any.
+func getLimit(user uint64, enforced *uint64) uint64 {
 	if enforced != nil {
 		if user < *enforced && user != 0 || *enforced == 0 {
 			return user
@@ -1180,7 +2279,105 @@ func getNamespacesFromNamespaceSelector(nsel *v1.NamespaceSelector, namespace st
 	return nsel.MatchNames
 }
 
-func (cg *configGenerator) generateK8SSDConfig(namespaces []string, apiserverConfig *v1.APIServerConfig, basicAuthSecrets map[string]BasicAuthCredentials, role string) yaml.MapItem {
+// generateFederationConfig returns the scrape configuration federating the
+// series of another Prometheus instance. The Pods of the instance are
+// discovered through the governing Service of its namespace.
+func (cg *configGenerator) generateFederationConfig(
+	version semver.Version,
+	p *v1.Prometheus,
+	fed v1.FederationSpec,
+	i int,
+	apiserverConfig *v1.APIServerConfig,
+	basicAuthSecrets map[string]BasicAuthCredentials,
+	authorizationSecrets map[string]string) yaml.MapSlice {
+
+	namespace := fed.Namespace
+	if namespace == "" {
+		namespace = p.Namespace
+	}
+
+	cfg := yaml.MapSlice{
+		{
+			Key:   "job_name",
+			Value: fmt.Sprintf("federate/%s/%s/%d", namespace, fed.Name, i),
+		},
+		{
+			Key:   "honor_labels",
+			Value: true,
+		},
+	}
+
+	if fed.Interval != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "scrape_interval", Value: fed.Interval})
+	}
+	if fed.ScrapeTimeout != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "scrape_timeout", Value: fed.ScrapeTimeout})
+	}
+
+	path := fed.Path
+	if path == "" {
+		path = "/federate"
+	}
+	cfg = append(cfg, yaml.MapItem{Key: "metrics_path", Value: path})
+	cfg = append(cfg, yaml.MapItem{Key: "params", Value: yaml.MapSlice{
+		{Key: "match[]", Value: fed.Match},
+	}})
+
+	if fed.Scheme != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "scheme", Value: fed.Scheme})
+	}
+
+	cfg = addTLStoYaml(cfg, p.Namespace, fed.TLSConfig)
+
+	if fed.BasicAuth != nil {
+		if s, ok := basicAuthSecrets[fmt.Sprintf("federation/%d", i)]; ok {
+			cfg = append(cfg, yaml.MapItem{
+				Key: "basic_auth", Value: yaml.MapSlice{
+					{Key: "username", Value: s.username},
+					{Key: "password", Value: s.password},
+				},
+			})
+		}
+	}
+
+	cfg = addAuthorizationToYaml(cfg, version, fed.Authorization, authorizationSecrets, fmt.Sprintf("federation/%d", i))
+
+	cfg = append(cfg, cg.generateK8SSDConfig([]string{namespace}, apiserverConfig, basicAuthSecrets, kubernetesSDRoleEndpoint, nil, nil))
+
+	port := fed.Port
+	if port == "" {
+		port = defaultPortName
+	}
+	cfg = append(cfg, yaml.MapItem{Key: "relabel_configs", Value: []yaml.MapSlice{
+		{
+			{Key: "action", Value: "keep"},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_service_name"}},
+			{Key: "regex", Value: governingServiceName},
+		},
+		{
+			{Key: "action", Value: "keep"},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_pod_label_prometheus"}},
+			{Key: "regex", Value: fed.Name},
+		},
+		{
+			{Key: "action", Value: "keep"},
+			{Key: "source_labels", Value: []string{"__meta_kubernetes_endpoint_port_name"}},
+			{Key: "regex", Value: port},
+		},
+	}})
+
+	if len(fed.MetricRelabelConfigs) > 0 {
+		var metricRelabelings []yaml.MapSlice
+		for _, c := range fed.MetricRelabelConfigs {
+			metricRelabelings = append(metricRelabelings, generateRelabelConfig(c))
+		}
+		cfg = append(cfg, yaml.MapItem{Key: "metric_relabel_configs", Value: metricRelabelings})
+	}
+
+	return cfg
+}
+
+func (cg *configGenerator) generateK8SSDConfig(namespaces []string, apiserverConfig *v1.APIServerConfig, basicAuthSecrets map[string]BasicAuthCredentials, role string, attachMetadata *v1.AttachMetadata, selectors []yaml.MapSlice) yaml.MapItem {
 	k8sSDConfig := yaml.MapSlice{
 		{
 			Key:   "role",
@@ -1229,6 +2426,19 @@ func (cg *configGenerator) generateK8SSDConfig(namespaces []string, apiserverCon
 		k8sSDConfig = addTLStoYaml(k8sSDConfig, "", apiserverConfig.TLSConfig)
 	}
 
+	if attachMetadata != nil {
+		k8sSDConfig = append(k8sSDConfig, yaml.MapItem{
+			Key: "attach_metadata",
+			Value: yaml.MapSlice{
+				{Key: "node", Value: attachMetadata.Node},
+			},
+		})
+	}
+
+	if len(selectors) > 0 {
+		k8sSDConfig = append(k8sSDConfig, yaml.MapItem{Key: "selectors", Value: selectors})
+	}
+
 	return yaml.MapItem{
 		Key: "kubernetes_sd_configs",
 		Value: []yaml.MapSlice{
@@ -1259,18 +2469,21 @@ func (cg *configGenerator) generateAlertmanagerConfig(version semver.Version, am
 	// config as well, make sure to path the right namespace here.
 	cfg = addTLStoYaml(cfg, "", am.TLSConfig)
 
-	switch version.Major {
-	case 1:
+	dnsDiscovery := am.Discovery == v1.AlertmanagerDiscoveryDNS
+	switch {
+	case dnsDiscovery:
+		cfg = append(cfg, generateAlertmanagerDNSSDConfig(am))
+	case version.Major == 1:
 		if version.Minor < 7 {
 			if apiserverConfig != nil {
 				level.Info(cg.logger).Log("msg", "custom apiserver config is set but it will not take effect because prometheus version is < 1.7")
 			}
-			cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRoleEndpoint))
+			cfg = append(cfg, cg.generateK8SSDConfig(nil, nil, nil, kubernetesSDRoleEndpoint, nil, nil))
 		} else {
-			cfg = append(cfg, cg.generateK8SSDConfig([]string{am.Namespace}, apiserverConfig, basicAuthSecrets, kubernetesSDRoleEndpoint))
+			cfg = append(cfg, cg.generateK8SSDConfig([]string{am.Namespace}, apiserverConfig, basicAuthSecrets, kubernetesSDRoleEndpoint, nil, nil))
 		}
-	case 2:
-		cfg = append(cfg, cg.generateK8SSDConfig([]string{am.Namespace}, apiserverConfig, basicAuthSecrets, kubernetesSDRoleEndpoint))
+	case version.Major == 2:
+		cfg = append(cfg, cg.generateK8SSDConfig([]string{am.Namespace}, apiserverConfig, basicAuthSecrets, kubernetesSDRoleEndpoint, nil, nil))
 	}
 
 	if am.BearerTokenFile != "" {
@@ -1283,6 +2496,12 @@ func (cg *configGenerator) generateAlertmanagerConfig(version semver.Version, am
 		}
 	}
 
+	// The DNS lookup returns only the addresses of the Alertmanager
+	// Service, there's nothing to filter.
+	if dnsDiscovery {
+		return cfg
+	}
+
 	var relabelings []yaml.MapSlice
 
 	relabelings = append(relabelings, yaml.MapSlice{
@@ -1318,7 +2537,40 @@ func (cg *configGenerator) generateAlertmanagerConfig(version semver.Version, am
 	return cfg
 }
 
-func (cg *configGenerator) generateRemoteReadConfig(version semver.Version, specs []v1.RemoteReadSpec, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapItem {
+// generateAlertmanagerDNSSDConfig returns the DNS service discovery of the
+// Alertmanager Service. Kubernetes publishes SRV records for the named
+// ports of the Services, the numbered ports are resolved with A records.
+func generateAlertmanagerDNSSDConfig(am v1.AlertmanagerEndpoints) yaml.MapItem {
+	host := fmt.Sprintf("%s.%s.svc", am.Name, am.Namespace)
+
+	var sdConfig yaml.MapSlice
+	if am.Port.Type == intstr.String {
+		sdConfig = yaml.MapSlice{
+			{Key: "names", Value: []string{fmt.Sprintf("_%s._tcp.%s", am.Port.StrVal, host)}},
+			{Key: "type", Value: "SRV"},
+		}
+	} else {
+		sdConfig = yaml.MapSlice{
+			{Key: "names", Value: []string{host}},
+			{Key: "type", Value: "A"},
+			{Key: "port", Value: am.Port.IntVal},
+		}
+	}
+
+	return yaml.MapItem{
+		Key:   "dns_sd_configs",
+		Value: []yaml.MapSlice{sdConfig},
+	}
+}
+
+func (cg *configGenerator) generateRemoteReadConfig(
+	version semver.Version,
+	specs []v1.RemoteReadSpec,
+	basicAuthSecrets map[string]BasicAuthCredentials,
+	oauth2Secrets map[string]OAuth2Credentials,
+	headerSecrets map[string]map[string]string,
+	authorizationSecrets map[string]string,
+) yaml.MapItem {
 
 	cfgs := []yaml.MapSlice{}
 
@@ -1337,6 +2589,21 @@ func (cg *configGenerator) generateRemoteReadConfig(version semver.Version, spec
 			cfg = append(cfg, yaml.MapItem{Key: "name", Value: spec.Name})
 		}
 
+		if version.GTE(semver.MustParse("2.26.0")) {
+			headers := map[string]string{}
+			for k, v := range spec.Headers {
+				headers[k] = v
+			}
+			// Values from secrets take precedence over the plain values.
+			for k, v := range headerSecrets[fmt.Sprintf("remoteRead/%d", i)] {
+				headers[k] = v
+			}
+
+			if len(headers) > 0 {
+				cfg = append(cfg, yaml.MapItem{Key: "headers", Value: stringMapToMapSlice(headers)})
+			}
+		}
+
 		if len(spec.RequiredMatchers) > 0 {
 			cfg = append(cfg, yaml.MapItem{Key: "required_matchers", Value: stringMapToMapSlice(spec.RequiredMatchers)})
 		}
@@ -1345,6 +2612,10 @@ func (cg *configGenerator) generateRemoteReadConfig(version semver.Version, spec
 			cfg = append(cfg, yaml.MapItem{Key: "read_recent", Value: spec.ReadRecent})
 		}
 
+		if spec.FilterExternalLabels != nil && version.GTE(semver.MustParse("2.34.0")) {
+			cfg = append(cfg, yaml.MapItem{Key: "filter_external_labels", Value: *spec.FilterExternalLabels})
+		}
+
 		if spec.BasicAuth != nil {
 			if s, ok := basicAuthSecrets[fmt.Sprintf("remoteRead/%d", i)]; ok {
 				cfg = append(cfg, yaml.MapItem{
@@ -1364,6 +2635,10 @@ func (cg *configGenerator) generateRemoteReadConfig(version semver.Version, spec
 			cfg = append(cfg, yaml.MapItem{Key: "bearer_token_file", Value: spec.BearerTokenFile})
 		}
 
+		cfg = addAuthorizationToYaml(cfg, version, spec.Authorization, authorizationSecrets, fmt.Sprintf("remoteRead/%d", i))
+
+		cfg = addOAuth2ToYaml(cfg, version, spec.OAuth2, oauth2Secrets, fmt.Sprintf("remoteRead/%d", i))
+
 		// TODO: If we want to support secret refs for remote read tls
 		// config as well, make sure to path the right namespace here.
 		cfg = addTLStoYaml(cfg, "", spec.TLSConfig)
@@ -1382,7 +2657,7 @@ func (cg *configGenerator) generateRemoteReadConfig(version semver.Version, spec
 	}
 }
 
-func (cg *configGenerator) generateRemoteWriteConfig(version semver.Version, p *v1.Prometheus, basicAuthSecrets map[string]BasicAuthCredentials) yaml.MapItem {
+func (cg *configGenerator) generateRemoteWriteConfig(version semver.Version, p *v1.Prometheus, basicAuthSecrets map[string]BasicAuthCredentials, sigv4Secrets map[string]SigV4Credentials, authorizationSecrets map[string]string, headerSecrets map[string]map[string]string) yaml.MapItem {
 
 	cfgs := []yaml.MapSlice{}
 
@@ -1459,12 +2734,16 @@ func (cg *configGenerator) generateRemoteWriteConfig(version semver.Version, p *
 			cfg = append(cfg, yaml.MapItem{Key: "bearer_token_file", Value: spec.BearerTokenFile})
 		}
 
+		cfg = addAuthorizationToYaml(cfg, version, spec.Authorization, authorizationSecrets, fmt.Sprintf("remoteWrite/%d", i))
+
 		cfg = addTLStoYaml(cfg, p.ObjectMeta.Namespace, spec.TLSConfig)
 
 		if spec.ProxyURL != "" {
 			cfg = append(cfg, yaml.MapItem{Key: "proxy_url", Value: spec.ProxyURL})
 		}
 
+		cfg = cg.addProxyOptions(cfg, version, spec, headerSecrets[fmt.Sprintf("remoteWrite/%d/proxyConnectHeader", i)])
+
 		if spec.QueueConfig != nil {
 			queueConfig := yaml.MapSlice{}
 
@@ -1505,6 +2784,61 @@ func (cg *configGenerator) generateRemoteWriteConfig(version semver.Version, p *
 			cfg = append(cfg, yaml.MapItem{Key: "queue_config", Value: queueConfig})
 		}
 
+		if spec.MetadataConfig != nil && version.GTE(semver.MustParse("2.23.0")) {
+			metadataConfig := yaml.MapSlice{
+				{Key: "send", Value: spec.MetadataConfig.Send},
+			}
+
+			if spec.MetadataConfig.SendInterval != "" {
+				metadataConfig = append(metadataConfig, yaml.MapItem{Key: "send_interval", Value: spec.MetadataConfig.SendInterval})
+			}
+
+			if spec.MetadataConfig.MaxSamplesPerSend != int(0) && version.GTE(semver.MustParse("2.29.0")) {
+				metadataConfig = append(metadataConfig, yaml.MapItem{Key: "max_samples_per_send", Value: spec.MetadataConfig.MaxSamplesPerSend})
+			}
+
+			cfg = append(cfg, yaml.MapItem{Key: "metadata_config", Value: metadataConfig})
+		}
+
+		if spec.Sigv4 != nil && version.GTE(semver.MustParse("2.26.0")) {
+			sigV4 := yaml.MapSlice{}
+
+			if spec.Sigv4.Region != "" {
+				sigV4 = append(sigV4, yaml.MapItem{Key: "region", Value: spec.Sigv4.Region})
+			}
+
+			if s, ok := sigv4Secrets[fmt.Sprintf("remoteWrite/%d", i)]; ok {
+				sigV4 = append(sigV4,
+					yaml.MapItem{Key: "access_key", Value: s.accessKeyID},
+					yaml.MapItem{Key: "secret_key", Value: s.secretKeyID},
+				)
+			}
+
+			if spec.Sigv4.Profile != "" {
+				sigV4 = append(sigV4, yaml.MapItem{Key: "profile", Value: spec.Sigv4.Profile})
+			}
+
+			if spec.Sigv4.RoleArn != "" {
+				sigV4 = append(sigV4, yaml.MapItem{Key: "role_arn", Value: spec.Sigv4.RoleArn})
+			}
+
+			cfg = append(cfg, yaml.MapItem{Key: "sigv4", Value: sigV4})
+		}
+
+		if spec.AzureAD != nil && version.GTE(semver.MustParse("2.45.0")) {
+			azureAd := yaml.MapSlice{
+				{Key: "managed_identity", Value: yaml.MapSlice{
+					{Key: "client_id", Value: spec.AzureAD.ManagedIdentity.ClientID},
+				}},
+			}
+
+			if spec.AzureAD.Cloud != "" {
+				azureAd = append(azureAd, yaml.MapItem{Key: "cloud", Value: spec.AzureAD.Cloud})
+			}
+
+			cfg = append(cfg, yaml.MapItem{Key: "azuread", Value: azureAd})
+		}
+
 		cfgs = append(cfgs, cfg)
 	}
 
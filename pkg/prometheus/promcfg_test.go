@@ -17,8 +17,13 @@ package prometheus
 import (
 	"bytes"
 	"fmt"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/blang/semver"
+	"github.com/go-kit/kit/log"
 	"github.com/go-openapi/swag"
 	"github.com/kylelemons/godebug/pretty"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
@@ -204,12 +209,17 @@ alerting:
 			map[string]*monitoringv1.ServiceMonitor{},
 			nil,
 			nil,
+			nil,
 			map[string]BasicAuthCredentials{},
 			map[string]BearerToken{},
 			nil,
 			nil,
 			nil,
 			nil,
+			nil,
+			nil,
+			nil,
+			nil,
 		)
 		if err != nil {
 			t.Fatal(err)
@@ -328,7 +338,7 @@ func TestNamespaceSetCorrectly(t *testing.T) {
 
 	for _, tc := range testcases {
 		selectedNamespaces := getNamespacesFromNamespaceSelector(&tc.ServiceMonitor.Spec.NamespaceSelector, tc.ServiceMonitor.Namespace, tc.IgnoreNamespaceSelectors)
-		c := cg.generateK8SSDConfig(selectedNamespaces, nil, nil, kubernetesSDRoleEndpoint)
+		c := cg.generateK8SSDConfig(selectedNamespaces, nil, nil, kubernetesSDRoleEndpoint, nil, nil)
 		s, err := yaml.Marshal(yaml.MapSlice{c})
 		if err != nil {
 			t.Fatal(err)
@@ -357,7 +367,7 @@ func TestNamespaceSetCorrectlyForPodMonitor(t *testing.T) {
 
 	cg := &configGenerator{}
 	selectedNamespaces := getNamespacesFromNamespaceSelector(&pm.Spec.NamespaceSelector, pm.Namespace, false)
-	c := cg.generateK8SSDConfig(selectedNamespaces, nil, nil, kubernetesSDRolePod)
+	c := cg.generateK8SSDConfig(selectedNamespaces, nil, nil, kubernetesSDRolePod, nil, nil)
 	s, err := yaml.Marshal(yaml.MapSlice{c})
 	if err != nil {
 		t.Fatal(err)
@@ -424,12 +434,17 @@ func TestProbeStaticTargetsConfigGeneration(t *testing.T) {
 				},
 			},
 		},
+		nil,
 		map[string]BasicAuthCredentials{},
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	if err != nil {
@@ -528,12 +543,17 @@ func TestProbeStaticTargetsConfigGenerationWithLabelEnforce(t *testing.T) {
 				},
 			},
 		},
+		nil,
 		map[string]BasicAuthCredentials{},
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	if err != nil {
@@ -634,12 +654,17 @@ func TestProbeStaticTargetsConfigGenerationWithJobName(t *testing.T) {
 				},
 			},
 		},
+		nil,
 		map[string]BasicAuthCredentials{},
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	if err != nil {
@@ -689,6 +714,131 @@ alerting:
 	}
 }
 
+func TestProbeStaticTargetsWithOverridesConfigGeneration(t *testing.T) {
+	cg := &configGenerator{}
+	cfg, err := cg.generateConfig(
+		&monitoringv1.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PrometheusSpec{
+				ProbeSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"group": "group1",
+					},
+				},
+			},
+		},
+		nil,
+		nil,
+		map[string]*monitoringv1.Probe{
+			"probe1": &monitoringv1.Probe{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testprobe1",
+					Namespace: "default",
+					Labels: map[string]string{
+						"group": "group1",
+					},
+				},
+				Spec: monitoringv1.ProbeSpec{
+					ProberSpec: monitoringv1.ProberSpec{
+						URL: "blackbox.exporter.io",
+					},
+					Module: "http_2xx",
+					Targets: monitoringv1.ProbeTargets{
+						StaticConfig: &monitoringv1.ProbeTargetStaticConfig{
+							Labels: map[string]string{
+								"static": "label",
+							},
+							StaticTargets: []monitoringv1.ProbeStaticTarget{
+								{
+									URL: "prometheus.io",
+									Labels: map[string]string{
+										"team": "web",
+									},
+								},
+								{
+									URL:    "dns.prometheus.io",
+									Module: "dns_soa",
+									Params: map[string]string{
+										"hostname": "prometheus.io",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		nil,
+		map[string]BasicAuthCredentials{},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `global:
+  evaluation_interval: 30s
+  scrape_interval: 30s
+  external_labels:
+    prometheus: default/test
+    prometheus_replica: $(POD_NAME)
+rule_files: []
+scrape_configs:
+- job_name: default/testprobe1
+  honor_timestamps: true
+  metrics_path: /probe
+  params:
+    module:
+    - http_2xx
+  static_configs:
+  - targets:
+    - prometheus.io
+    labels:
+      static: label
+      team: web
+  - targets:
+    - dns.prometheus.io
+    labels:
+      __param_hostname: prometheus.io
+      __param_module: dns_soa
+      static: label
+  relabel_configs:
+  - source_labels:
+    - __address__
+    target_label: __param_target
+  - source_labels:
+    - __param_target
+    target_label: instance
+  - source_labels:
+    - __param_module
+    target_label: module
+  - target_label: __address__
+    replacement: blackbox.exporter.io
+alerting:
+  alert_relabel_configs:
+  - action: labeldrop
+    regex: prometheus_replica
+  alertmanagers: []
+`
+
+	result := string(cfg)
+	if expected != result {
+		t.Fatalf("Unexpected result.\n\nGot:\n\n%s\n\nExpected:\n\n%s\n\n", result, expected)
+	}
+}
+
 func TestProbeIngressSDConfigGeneration(t *testing.T) {
 	cg := &configGenerator{}
 	cfg, err := cg.generateConfig(
@@ -745,12 +895,17 @@ func TestProbeIngressSDConfigGeneration(t *testing.T) {
 				},
 			},
 		},
+		nil,
 		map[string]BasicAuthCredentials{},
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	if err != nil {
@@ -872,12 +1027,17 @@ func TestProbeIngressSDConfigGenerationWithLabelEnforce(t *testing.T) {
 				},
 			},
 		},
+		nil,
 		map[string]BasicAuthCredentials{},
 		nil,
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	if err != nil {
@@ -1012,6 +1172,8 @@ func TestK8SSDConfigGeneration(t *testing.T) {
 			tc.apiserverConfig,
 			tc.basicAuthSecrets,
 			kubernetesSDRoleEndpoint,
+			nil,
+			nil,
 		)
 		s, err := yaml.Marshal(yaml.MapSlice{c})
 		if err != nil {
@@ -1049,12 +1211,17 @@ func TestAlertmanagerBearerToken(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -1128,12 +1295,17 @@ func TestAlertmanagerAPIVersion(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -1208,12 +1380,17 @@ func TestAlertmanagerTimeoutConfig(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -1263,6 +1440,67 @@ alerting:
 	}
 }
 
+func TestAlertmanagerDNSDiscovery(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		port     intstr.IntOrString
+		expected string
+	}{
+		{
+			name: "named port",
+			port: intstr.FromString("web"),
+			expected: `alertmanagers:
+- path_prefix: /
+  scheme: http
+  dns_sd_configs:
+  - names:
+    - _web._tcp.alertmanager-operated.monitoring.svc
+    type: SRV
+  api_version: v2
+`,
+		},
+		{
+			name: "numbered port",
+			port: intstr.FromInt(9093),
+			expected: `alertmanagers:
+- path_prefix: /
+  scheme: http
+  dns_sd_configs:
+  - names:
+    - alertmanager-operated.monitoring.svc
+    type: A
+    port: 9093
+  api_version: v2
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := &configGenerator{}
+			am := cg.generateAlertmanagerConfig(
+				semver.MustParse("2.20.0"),
+				monitoringv1.AlertmanagerEndpoints{
+					Name:       "alertmanager-operated",
+					Namespace:  "monitoring",
+					Port:       tc.port,
+					APIVersion: "v2",
+					Discovery:  monitoringv1.AlertmanagerDiscoveryDNS,
+				},
+				nil,
+				nil,
+			)
+
+			b, err := yaml.Marshal(yaml.MapSlice{{Key: "alertmanagers", Value: []yaml.MapSlice{am}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != string(b) {
+				t.Fatalf("expected configuration:\n%s\ngot:\n%s", tc.expected, string(b))
+			}
+		})
+	}
+}
+
 func TestAdditionalAlertRelabelConfigs(t *testing.T) {
 	cg := &configGenerator{}
 	cfg, err := cg.generateConfig(
@@ -1286,9 +1524,14 @@ func TestAdditionalAlertRelabelConfigs(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 		[]byte(`- action: drop
   source_labels: [__meta_kubernetes_node_name]
   regex: spot-(.+)
@@ -1399,12 +1642,17 @@ func TestNoEnforcedNamespaceLabelServiceMonitor(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -1544,12 +1792,17 @@ func TestEnforcedNamespaceLabelPodMonitor(t *testing.T) {
 			},
 		},
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -1677,12 +1930,17 @@ func TestEnforcedNamespaceLabelServiceMonitor(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -1790,10 +2048,15 @@ func TestAdditionalAlertmanagers(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 		[]byte(`- static_configs:
   - targets:
     - localhost
@@ -1886,12 +2149,17 @@ func TestSettingHonorTimestampsInServiceMonitor(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2016,12 +2284,17 @@ func TestSettingHonorTimestampsInPodMonitor(t *testing.T) {
 			},
 		},
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2128,12 +2401,17 @@ func TestHonorTimestampsOverriding(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2257,12 +2535,17 @@ func TestSettingHonorLabels(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2385,12 +2668,17 @@ func TestHonorLabelsOverriding(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2512,12 +2800,17 @@ func TestTargetLabels(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2639,12 +2932,17 @@ func TestPodTargetLabels(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2766,12 +3064,17 @@ func TestPodTargetLabelsFromPodMonitor(t *testing.T) {
 			},
 		},
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -2866,12 +3169,17 @@ func TestEmptyEndointPorts(t *testing.T) {
 		},
 		nil,
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -3000,12 +3308,17 @@ func generateTestConfig(version string) ([]byte, error) {
 		makeServiceMonitors(),
 		makePodMonitors(),
 		nil,
+		nil,
 		map[string]BasicAuthCredentials{},
 		map[string]BearerToken{},
 		nil,
 		nil,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 }
 
@@ -3435,10 +3748,2335 @@ func TestGetSampleLimit(t *testing.T) {
 	}
 
 	for _, tc := range tcs {
-		actual := getSampleLimit(tc.User, &tc.Enforced)
+		actual := getLimit(tc.User, &tc.Enforced)
 		if actual != tc.Expected {
 			t.Fatalf("Got %d, Expected: %d, Enforced: %d, User: %d", actual, tc.Expected, tc.Enforced, tc.User)
 		}
 	}
 
 }
+
+func TestTLSConfigCertManagerRef(t *testing.T) {
+	for _, tc := range []struct {
+		ref      *monitoringv1.CertManagerReference
+		expected string
+	}{
+		{
+			ref: &monitoringv1.CertManagerReference{SecretName: "scrape-tls"},
+			expected: `tls_config:
+  insecure_skip_verify: false
+  ca_file: /etc/prometheus/certs/secret_default_scrape-tls_ca.crt
+  cert_file: /etc/prometheus/certs/secret_default_scrape-tls_tls.crt
+  key_file: /etc/prometheus/certs/secret_default_scrape-tls_tls.key
+`,
+		},
+		{
+			ref: &monitoringv1.CertManagerReference{SecretName: "scrape-tls", IgnoreCA: true},
+			expected: `tls_config:
+  insecure_skip_verify: false
+  cert_file: /etc/prometheus/certs/secret_default_scrape-tls_tls.crt
+  key_file: /etc/prometheus/certs/secret_default_scrape-tls_tls.key
+`,
+		},
+	} {
+		cfg := addTLStoYaml(yaml.MapSlice{}, "default", &monitoringv1.TLSConfig{CertManagerRef: tc.ref})
+
+		result, err := yaml.Marshal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tc.expected != string(result) {
+			t.Fatalf("Unexpected result.\n\nGot:\n\n%s\n\nExpected:\n\n%s\n\n", string(result), tc.expected)
+		}
+	}
+}
+
+func TestRemoteWriteConfig(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		spec     monitoringv1.RemoteWriteSpec
+		expected string
+	}{
+		{
+			version: "v2.23.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				MetadataConfig: &monitoringv1.MetadataConfig{
+					Send:              true,
+					SendInterval:      "1m",
+					MaxSamplesPerSend: 500,
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  metadata_config:
+    send: true
+    send_interval: 1m
+`,
+		},
+		{
+			version: "v2.22.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				MetadataConfig: &monitoringv1.MetadataConfig{
+					Send: false,
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+`,
+		},
+		{
+			version: "v2.29.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				MetadataConfig: &monitoringv1.MetadataConfig{
+					Send:              true,
+					MaxSamplesPerSend: 500,
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  metadata_config:
+    send: true
+    max_samples_per_send: 500
+`,
+		},
+		{
+			version: "v2.26.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				Sigv4: &monitoringv1.Sigv4{
+					Region: "us-central-0",
+					AccessKey: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "sigv4-secret"},
+						Key:                  "access-key",
+					},
+					SecretKey: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "sigv4-secret"},
+						Key:                  "secret-key",
+					},
+					Profile: "profilename",
+					RoleArn: "arn:aws:iam::123456789012:instance-profile/prometheus",
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  sigv4:
+    region: us-central-0
+    access_key: access-key
+    secret_key: secret-key
+    profile: profilename
+    role_arn: arn:aws:iam::123456789012:instance-profile/prometheus
+`,
+		},
+		{
+			version: "v2.25.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				Sigv4: &monitoringv1.Sigv4{
+					Region: "us-central-0",
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+`,
+		},
+		{
+			version: "v2.45.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				AzureAD: &monitoringv1.AzureAD{
+					Cloud: "AzurePublic",
+					ManagedIdentity: monitoringv1.ManagedIdentity{
+						ClientID: "00000000-a12b-3cd4-e56f-000000000000",
+					},
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  azuread:
+    managed_identity:
+      client_id: 00000000-a12b-3cd4-e56f-000000000000
+    cloud: AzurePublic
+`,
+		},
+		{
+			version: "v2.44.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL: "http://example.com",
+				AzureAD: &monitoringv1.AzureAD{
+					ManagedIdentity: monitoringv1.ManagedIdentity{
+						ClientID: "00000000-a12b-3cd4-e56f-000000000000",
+					},
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+`,
+		},
+		{
+			version: "v2.43.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL:      "http://example.com",
+				ProxyURL: "http://proxy.example.com:3128",
+				NoProxy:  "10.0.0.0/8,.svc",
+				ProxyConnectHeader: map[string]v1.SecretKeySelector{
+					"Proxy-Authorization": {},
+				},
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  proxy_url: http://proxy.example.com:3128
+  no_proxy: 10.0.0.0/8,.svc
+  proxy_connect_header:
+    Proxy-Authorization:
+    - Basic dXNlcjpwYXNz
+`,
+		},
+		{
+			version: "v2.43.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL:                  "http://example.com",
+				ProxyFromEnvironment: true,
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  proxy_from_environment: true
+`,
+		},
+		{
+			version: "v2.42.0",
+			spec: monitoringv1.RemoteWriteSpec{
+				URL:      "http://example.com",
+				ProxyURL: "http://proxy.example.com:3128",
+				NoProxy:  "10.0.0.0/8,.svc",
+			},
+			expected: `remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+  proxy_url: http://proxy.example.com:3128
+`,
+		},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: monitoringv1.PrometheusSpec{
+					RemoteWrite: []monitoringv1.RemoteWriteSpec{tc.spec},
+				},
+			}
+			sigv4Secrets := map[string]SigV4Credentials{
+				"remoteWrite/0": {
+					accessKeyID: "access-key",
+					secretKeyID: "secret-key",
+				},
+			}
+
+			version, err := semver.ParseTolerant(tc.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headerSecrets := map[string]map[string]string{
+				"remoteWrite/0/proxyConnectHeader": {
+					"Proxy-Authorization": "Basic dXNlcjpwYXNz",
+				},
+			}
+
+			cg := &configGenerator{logger: log.NewNopLogger()}
+			cfg := cg.generateRemoteWriteConfig(version, p, nil, sigv4Secrets, nil, headerSecrets)
+
+			result, err := yaml.Marshal(yaml.MapSlice{cfg})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != string(result) {
+				t.Fatalf("Unexpected result.\n\nGot:\n\n%s\n\nExpected:\n\n%s\n\n", string(result), tc.expected)
+			}
+		})
+	}
+}
+
+func TestNodeMonitorConfigGeneration(t *testing.T) {
+	port := int32(9100)
+	cg := &configGenerator{}
+	cfg, err := cg.generateConfig(
+		&monitoringv1.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PrometheusSpec{
+				NodeMonitorSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"group": "group1",
+					},
+				},
+			},
+		},
+		nil,
+		nil,
+		nil,
+		map[string]*monitoringv1.NodeMonitor{
+			"default/testnodemonitor1": {
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testnodemonitor1",
+					Namespace: "default",
+				},
+				Spec: monitoringv1.NodeMonitorSpec{
+					JobLabel:         "app",
+					NodeTargetLabels: []string{"topology.kubernetes.io/zone"},
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"kubernetes.io/os": "linux",
+						},
+					},
+					Endpoints: []monitoringv1.NodeMetricsEndpoint{
+						{
+							Port:        &port,
+							AddressType: "InternalIP",
+						},
+						{
+							Scheme:      "https",
+							Path:        "/metrics/cadvisor",
+							AddressType: "Hostname",
+							BearerTokenSecret: v1.SecretKeySelector{
+								LocalObjectReference: v1.LocalObjectReference{Name: "token"},
+								Key:                  "token",
+							},
+						},
+						{
+							Port: &port,
+						},
+					},
+				},
+			},
+		},
+		map[string]BasicAuthCredentials{},
+		map[string]BearerToken{
+			"nodeMonitor/default/testnodemonitor1/1": "secret",
+		},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `global:
+  evaluation_interval: 30s
+  scrape_interval: 30s
+  external_labels:
+    prometheus: default/test
+    prometheus_replica: $(POD_NAME)
+rule_files: []
+scrape_configs:
+- job_name: nodeMonitor/default/testnodemonitor1/0
+  honor_labels: false
+  kubernetes_sd_configs:
+  - role: node
+  relabel_configs:
+  - action: keep
+    source_labels:
+    - __meta_kubernetes_node_label_kubernetes_io_os
+    regex: linux
+  - source_labels:
+    - __meta_kubernetes_node_address_InternalIP
+    target_label: __address__
+    regex: (.+)
+    replacement: ${1}:9100
+  - source_labels:
+    - __meta_kubernetes_node_name
+    target_label: node
+  - source_labels:
+    - __meta_kubernetes_node_label_topology_kubernetes_io_zone
+    target_label: topology_kubernetes_io_zone
+    regex: (.+)
+    replacement: ${1}
+  - target_label: job
+    replacement: default/testnodemonitor1
+  - source_labels:
+    - __meta_kubernetes_node_label_app
+    target_label: job
+    regex: (.+)
+    replacement: ${1}
+  - target_label: endpoint
+    replacement: "9100"
+- job_name: nodeMonitor/default/testnodemonitor1/1
+  honor_labels: false
+  kubernetes_sd_configs:
+  - role: node
+  metrics_path: /metrics/cadvisor
+  scheme: https
+  bearer_token: secret
+  relabel_configs:
+  - action: keep
+    source_labels:
+    - __meta_kubernetes_node_label_kubernetes_io_os
+    regex: linux
+  - source_labels:
+    - __meta_kubernetes_node_address_Hostname
+    - __address__
+    target_label: __address__
+    regex: (.+);.*:(\d+)
+    replacement: ${1}:${2}
+  - source_labels:
+    - __meta_kubernetes_node_name
+    target_label: node
+  - source_labels:
+    - __meta_kubernetes_node_label_topology_kubernetes_io_zone
+    target_label: topology_kubernetes_io_zone
+    regex: (.+)
+    replacement: ${1}
+  - target_label: job
+    replacement: default/testnodemonitor1
+  - source_labels:
+    - __meta_kubernetes_node_label_app
+    target_label: job
+    regex: (.+)
+    replacement: ${1}
+- job_name: nodeMonitor/default/testnodemonitor1/2
+  honor_labels: false
+  kubernetes_sd_configs:
+  - role: node
+  relabel_configs:
+  - action: keep
+    source_labels:
+    - __meta_kubernetes_node_label_kubernetes_io_os
+    regex: linux
+  - source_labels:
+    - __address__
+    target_label: __address__
+    regex: (.+):\d+
+    replacement: ${1}:9100
+  - source_labels:
+    - __meta_kubernetes_node_name
+    target_label: node
+  - source_labels:
+    - __meta_kubernetes_node_label_topology_kubernetes_io_zone
+    target_label: topology_kubernetes_io_zone
+    regex: (.+)
+    replacement: ${1}
+  - target_label: job
+    replacement: default/testnodemonitor1
+  - source_labels:
+    - __meta_kubernetes_node_label_app
+    target_label: job
+    regex: (.+)
+    replacement: ${1}
+  - target_label: endpoint
+    replacement: "9100"
+alerting:
+  alert_relabel_configs:
+  - action: labeldrop
+    regex: prometheus_replica
+  alertmanagers: []
+`
+
+	result := string(cfg)
+
+	if expected != result {
+		fmt.Println(pretty.Compare(expected, result))
+		t.Fatal("expected Prometheus configuration and actual configuration do not match")
+	}
+}
+
+func TestRemoteReadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		spec     monitoringv1.RemoteReadSpec
+		expected string
+	}{
+		{
+			version: "v2.34.0",
+			spec: monitoringv1.RemoteReadSpec{
+				URL:                  "http://example.com",
+				FilterExternalLabels: pointer.BoolPtr(false),
+			},
+			expected: `remote_read:
+- url: http://example.com
+  remote_timeout: 30s
+  filter_external_labels: false
+`,
+		},
+		{
+			version: "v2.33.0",
+			spec: monitoringv1.RemoteReadSpec{
+				URL:                  "http://example.com",
+				FilterExternalLabels: pointer.BoolPtr(false),
+			},
+			expected: `remote_read:
+- url: http://example.com
+  remote_timeout: 30s
+`,
+		},
+		{
+			version: "v2.26.0",
+			spec: monitoringv1.RemoteReadSpec{
+				URL: "http://example.com",
+				Headers: map[string]string{
+					"X-Scope-OrgID": "plain",
+					"X-Custom":      "custom",
+				},
+				HeadersFromSecrets: map[string]v1.SecretKeySelector{
+					"X-Scope-OrgID": {
+						LocalObjectReference: v1.LocalObjectReference{Name: "headers"},
+						Key:                  "tenant",
+					},
+				},
+			},
+			expected: `remote_read:
+- url: http://example.com
+  remote_timeout: 30s
+  headers:
+    X-Custom: custom
+    X-Scope-OrgID: tenant-a
+`,
+		},
+		{
+			version: "v2.25.0",
+			spec: monitoringv1.RemoteReadSpec{
+				URL: "http://example.com",
+				Headers: map[string]string{
+					"X-Custom": "custom",
+				},
+			},
+			expected: `remote_read:
+- url: http://example.com
+  remote_timeout: 30s
+`,
+		},
+		{
+			version: "v2.27.0",
+			spec: monitoringv1.RemoteReadSpec{
+				URL: "http://example.com",
+				OAuth2: &monitoringv1.OAuth2{
+					TokenURL: "http://token.example.com",
+					Scopes:   []string{"read"},
+					EndpointParams: map[string]string{
+						"audience": "thanos",
+					},
+				},
+			},
+			expected: `remote_read:
+- url: http://example.com
+  remote_timeout: 30s
+  oauth2:
+    client_id: client-id
+    client_secret: client-secret
+    token_url: http://token.example.com
+    scopes:
+    - read
+    endpoint_params:
+      audience: thanos
+`,
+		},
+		{
+			version: "v2.26.0",
+			spec: monitoringv1.RemoteReadSpec{
+				URL: "http://example.com",
+				OAuth2: &monitoringv1.OAuth2{
+					TokenURL: "http://token.example.com",
+				},
+			},
+			expected: `remote_read:
+- url: http://example.com
+  remote_timeout: 30s
+`,
+		},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			oauth2Secrets := map[string]OAuth2Credentials{
+				"remoteRead/0": {
+					clientID:     "client-id",
+					clientSecret: "client-secret",
+				},
+			}
+			headerSecrets := map[string]map[string]string{
+				"remoteRead/0": {
+					"X-Scope-OrgID": "tenant-a",
+				},
+			}
+			if tc.spec.HeadersFromSecrets == nil {
+				headerSecrets = nil
+			}
+
+			version, err := semver.ParseTolerant(tc.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cg := &configGenerator{}
+			cfg := cg.generateRemoteReadConfig(version, []monitoringv1.RemoteReadSpec{tc.spec}, nil, oauth2Secrets, headerSecrets, nil)
+
+			result, err := yaml.Marshal(yaml.MapSlice{cfg})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != string(result) {
+				t.Fatalf("Unexpected result.\n\nGot:\n\n%s\n\nExpected:\n\n%s\n\n", string(result), tc.expected)
+			}
+		})
+	}
+}
+
+func TestAdditionalScrapeConfigsAsFile(t *testing.T) {
+	additionalScrapeConfigs := []byte(`- job_name: extra
+  static_configs:
+  - targets: ["localhost:9090"]
+`)
+
+	for _, tc := range []struct {
+		name     string
+		version  string
+		asFile   bool
+		expected bool
+	}{
+		{
+			name:     "as file",
+			version:  "v2.43.0",
+			asFile:   true,
+			expected: true,
+		},
+		{
+			name:    "unsupported version",
+			version: "v2.42.0",
+			asFile:  true,
+		},
+		{
+			name:    "inline",
+			version: "v2.43.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := &configGenerator{}
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+						AdditionalScrapeConfigs: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "additional-scrape-configs"},
+							Key:                  "scrape-configs.yaml",
+						},
+						AdditionalScrapeConfigsAsFile: tc.asFile,
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				additionalScrapeConfigs,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				ScrapeConfigFiles []string        `yaml:"scrape_config_files"`
+				ScrapeConfigs     []yaml.MapSlice `yaml:"scrape_configs"`
+			}
+			if err := yaml.Unmarshal(cfg, &result); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected {
+				if len(result.ScrapeConfigFiles) != 1 || result.ScrapeConfigFiles[0] != "/etc/prometheus/additional-scrape-configs/additional-scrape-configs.yaml" {
+					t.Fatalf("unexpected scrape_config_files: %v", result.ScrapeConfigFiles)
+				}
+				if len(result.ScrapeConfigs) != 0 {
+					t.Fatalf("expected no inline scrape configs, got %d", len(result.ScrapeConfigs))
+				}
+				return
+			}
+
+			if len(result.ScrapeConfigFiles) != 0 {
+				t.Fatalf("expected no scrape_config_files, got %v", result.ScrapeConfigFiles)
+			}
+			if len(result.ScrapeConfigs) != 1 {
+				t.Fatalf("expected 1 inline scrape config, got %d", len(result.ScrapeConfigs))
+			}
+		})
+	}
+}
+
+func TestSplitConfig(t *testing.T) {
+	defer func(size int) { maxScrapeConfigsSecretDataSize = size }(maxScrapeConfigsSecretDataSize)
+	maxScrapeConfigsSecretDataSize = 120
+
+	conf := []byte(`global:
+  scrape_interval: 30s
+scrape_config_files:
+- /etc/prometheus/additional-scrape-configs/additional-scrape-configs.yaml
+scrape_configs:
+- job_name: a
+  static_configs:
+  - targets:
+    - a:9090
+- job_name: b
+  static_configs:
+  - targets:
+    - b:9090
+- job_name: c
+  static_configs:
+  - targets:
+    - c:9090
+rule_files: []
+`)
+
+	split, files, err := splitConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `global:
+  scrape_interval: 30s
+scrape_config_files:
+- /etc/prometheus/additional-scrape-configs/additional-scrape-configs.yaml
+- /etc/prometheus/scrape-configs/*.yaml
+rule_files: []
+`
+	if string(split) != expected {
+		t.Fatalf("unexpected configuration:\n%s", pretty.Compare(expected, string(split)))
+	}
+
+	expectedFiles := []string{
+		`scrape_configs:
+- job_name: a
+  static_configs:
+  - targets:
+    - a:9090
+- job_name: b
+  static_configs:
+  - targets:
+    - b:9090
+`,
+		`scrape_configs:
+- job_name: c
+  static_configs:
+  - targets:
+    - c:9090
+`,
+	}
+	if len(files) != len(expectedFiles) {
+		t.Fatalf("expected %d files, got %d", len(expectedFiles), len(files))
+	}
+	for i := range files {
+		if string(files[i]) != expectedFiles[i] {
+			t.Fatalf("unexpected file %d:\n%s", i, pretty.Compare(expectedFiles[i], string(files[i])))
+		}
+	}
+
+	maxScrapeConfigsSecretDataSize = 10
+	if _, _, err := splitConfig(conf); err == nil {
+		t.Fatal("expected error for a scrape config larger than the maximum size")
+	}
+}
+
+func TestGenerateAdditionalScrapeConfigsFile(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{
+			name:     "empty",
+			expected: "scrape_configs: []\n",
+		},
+		{
+			name: "scrape configs",
+			input: []byte(`- job_name: extra
+  static_configs:
+  - targets: ["localhost:9090"]
+`),
+			expected: `scrape_configs:
+- job_name: extra
+  static_configs:
+  - targets:
+    - localhost:9090
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := generateAdditionalScrapeConfigsFile(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != string(result) {
+				t.Fatalf("Unexpected result.\n\nGot:\n\n%s\n\nExpected:\n\n%s\n\n", string(result), tc.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultExternalLabels(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		defaults map[string]string
+		labels   map[string]string
+		expected yaml.MapSlice
+	}{
+		{
+			name: "no default",
+			expected: yaml.MapSlice{
+				{Key: "prometheus", Value: "default/test"},
+				{Key: "prometheus_replica", Value: "$(POD_NAME)"},
+			},
+		},
+		{
+			name: "defaults",
+			defaults: map[string]string{
+				"cluster":     "prod-eu1",
+				"environment": "production",
+			},
+			expected: yaml.MapSlice{
+				{Key: "cluster", Value: "prod-eu1"},
+				{Key: "environment", Value: "production"},
+				{Key: "prometheus", Value: "default/test"},
+				{Key: "prometheus_replica", Value: "$(POD_NAME)"},
+			},
+		},
+		{
+			name: "overridden by the Prometheus spec",
+			defaults: map[string]string{
+				"cluster":     "prod-eu1",
+				"environment": "production",
+			},
+			labels: map[string]string{
+				"cluster": "prod-eu2",
+			},
+			expected: yaml.MapSlice{
+				{Key: "cluster", Value: "prod-eu2"},
+				{Key: "environment", Value: "production"},
+				{Key: "prometheus", Value: "default/test"},
+				{Key: "prometheus_replica", Value: "$(POD_NAME)"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := buildExternalLabels(&monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: monitoringv1.PrometheusSpec{
+					ExternalLabels: tc.labels,
+				},
+			}, tc.defaults)
+
+			if diff := pretty.Compare(tc.expected, result); diff != "" {
+				t.Fatalf("unexpected external labels:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOTLPConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		otlp     *monitoringv1.OTLPConfig
+		expected map[string]interface{}
+	}{
+		{
+			name:    "not configured",
+			version: "v2.55.0",
+		},
+		{
+			name:    "promote resource attributes",
+			version: "v2.54.0",
+			otlp: &monitoringv1.OTLPConfig{
+				PromoteResourceAttributes: []string{"service.instance.id", "k8s.pod.name"},
+				TranslationStrategy:       "NoUTF8EscapingWithSuffixes",
+			},
+			expected: map[string]interface{}{
+				"promote_resource_attributes": []interface{}{"service.instance.id", "k8s.pod.name"},
+			},
+		},
+		{
+			name:    "translation strategy",
+			version: "v2.55.0",
+			otlp: &monitoringv1.OTLPConfig{
+				PromoteResourceAttributes: []string{"service.instance.id"},
+				TranslationStrategy:       "NoUTF8EscapingWithSuffixes",
+			},
+			expected: map[string]interface{}{
+				"promote_resource_attributes": []interface{}{"service.instance.id"},
+				"translation_strategy":        "NoUTF8EscapingWithSuffixes",
+			},
+		},
+		{
+			name:    "unsupported version",
+			version: "v2.53.0",
+			otlp: &monitoringv1.OTLPConfig{
+				PromoteResourceAttributes: []string{"service.instance.id"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := &configGenerator{}
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+						OTLP:    tc.otlp,
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				OTLP map[string]interface{} `yaml:"otlp"`
+			}
+			if err := yaml.Unmarshal(cfg, &result); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.expected, result.OTLP) {
+				t.Fatalf("expected otlp config %v, got %v", tc.expected, result.OTLP)
+			}
+		})
+	}
+}
+
+func TestExemplarsConfig(t *testing.T) {
+	maxSize := int64(5000)
+
+	for _, tc := range []struct {
+		name      string
+		version   string
+		exemplars *monitoringv1.Exemplars
+		expected  map[string]interface{}
+	}{
+		{
+			name:    "not configured",
+			version: "v2.29.0",
+		},
+		{
+			name:      "max size",
+			version:   "v2.29.0",
+			exemplars: &monitoringv1.Exemplars{MaxSize: &maxSize},
+			expected: map[string]interface{}{
+				"exemplars": map[interface{}]interface{}{"max_exemplars": 5000},
+			},
+		},
+		{
+			name:      "unsupported version",
+			version:   "v2.28.0",
+			exemplars: &monitoringv1.Exemplars{MaxSize: &maxSize},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := newConfigGenerator(log.NewNopLogger(), nil)
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version:   tc.version,
+						Exemplars: tc.exemplars,
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				Storage map[string]interface{} `yaml:"storage"`
+			}
+			if err := yaml.Unmarshal(cfg, &result); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.expected, result.Storage) {
+				t.Fatalf("expected storage config %v, got %v", tc.expected, result.Storage)
+			}
+		})
+	}
+}
+
+func TestNativeHistogramConfig(t *testing.T) {
+	scrapeClassic := true
+	bucketLimit := uint64(160)
+
+	for _, tc := range []struct {
+		name     string
+		version  string
+		expected bool
+	}{
+		{
+			name:     "supported version",
+			version:  "v2.45.0",
+			expected: true,
+		},
+		{
+			name:    "unsupported version",
+			version: "v2.44.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := newConfigGenerator(log.NewNopLogger(), nil)
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+					},
+				},
+				map[string]*monitoringv1.ServiceMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test",
+							Namespace: "default",
+						},
+						Spec: monitoringv1.ServiceMonitorSpec{
+							Endpoints: []monitoringv1.Endpoint{
+								{
+									Port:                       "web",
+									ScrapeClassicHistograms:    &scrapeClassic,
+									NativeHistogramBucketLimit: &bucketLimit,
+								},
+							},
+						},
+					},
+				},
+				map[string]*monitoringv1.PodMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test",
+							Namespace: "default",
+						},
+						Spec: monitoringv1.PodMonitorSpec{
+							PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{
+								{
+									Port:                       "web",
+									ScrapeClassicHistograms:    &scrapeClassic,
+									NativeHistogramBucketLimit: &bucketLimit,
+								},
+							},
+						},
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Both the ServiceMonitor and the PodMonitor jobs have the options.
+			expected := 0
+			if tc.expected {
+				expected = 2
+			}
+			for _, s := range []string{
+				"scrape_classic_histograms: true\n",
+				"native_histogram_bucket_limit: 160\n",
+			} {
+				if n := strings.Count(string(cfg), s); n != expected {
+					t.Fatalf("expected %q %d times in the configuration, got %d:\n%s", s, expected, n, cfg)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeLimits(t *testing.T) {
+	uint64Ptr := func(i uint64) *uint64 { return &i }
+
+	for _, tc := range []struct {
+		name                string
+		version             string
+		spec                monitoringv1.ProbeSpec
+		enforcedSampleLimit *uint64
+		enforcedTargetLimit *uint64
+		enforcedLabelLimit  *uint64
+		expected            map[string]int
+	}{
+		{
+			name:     "no limits",
+			version:  "v2.27.0",
+			expected: map[string]int{},
+		},
+		{
+			name:    "probe limits",
+			version: "v2.27.0",
+			spec: monitoringv1.ProbeSpec{
+				SampleLimit: 1000,
+				TargetLimit: 50,
+				LabelLimit:  30,
+			},
+			expected: map[string]int{
+				"sample_limit": 1000,
+				"target_limit": 50,
+				"label_limit":  30,
+			},
+		},
+		{
+			name:    "unsupported versions",
+			version: "v2.20.0",
+			spec: monitoringv1.ProbeSpec{
+				SampleLimit: 1000,
+				TargetLimit: 50,
+				LabelLimit:  30,
+			},
+			expected: map[string]int{
+				"sample_limit": 1000,
+			},
+		},
+		{
+			name:    "enforced limits",
+			version: "v2.27.0",
+			spec: monitoringv1.ProbeSpec{
+				SampleLimit: 1000,
+				TargetLimit: 50,
+			},
+			enforcedSampleLimit: uint64Ptr(500),
+			enforcedTargetLimit: uint64Ptr(100),
+			enforcedLabelLimit:  uint64Ptr(20),
+			expected: map[string]int{
+				"sample_limit": 500,
+				"target_limit": 50,
+				"label_limit":  20,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.spec.ProberSpec = monitoringv1.ProberSpec{URL: "blackbox.exporter.io"}
+			tc.spec.Module = "http_2xx"
+			tc.spec.Targets = monitoringv1.ProbeTargets{
+				StaticConfig: &monitoringv1.ProbeTargetStaticConfig{
+					Targets: []string{"prometheus.io"},
+				},
+			}
+
+			cg := &configGenerator{}
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version:             tc.version,
+						EnforcedSampleLimit: tc.enforcedSampleLimit,
+						EnforcedTargetLimit: tc.enforcedTargetLimit,
+						EnforcedLabelLimit:  tc.enforcedLabelLimit,
+					},
+				},
+				nil,
+				nil,
+				map[string]*monitoringv1.Probe{
+					"probe": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testprobe1",
+							Namespace: "default",
+						},
+						Spec: tc.spec,
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				ScrapeConfigs []map[string]interface{} `yaml:"scrape_configs"`
+			}
+			if err := yaml.Unmarshal(cfg, &result); err != nil {
+				t.Fatal(err)
+			}
+			if len(result.ScrapeConfigs) != 1 {
+				t.Fatalf("expected 1 scrape config, got %d", len(result.ScrapeConfigs))
+			}
+
+			limits := map[string]int{}
+			for _, k := range []string{"sample_limit", "target_limit", "label_limit"} {
+				if v, ok := result.ScrapeConfigs[0][k]; ok {
+					limits[k] = v.(int)
+				}
+			}
+
+			if !reflect.DeepEqual(tc.expected, limits) {
+				t.Fatalf("expected limits %v, got %v", tc.expected, limits)
+			}
+		})
+	}
+}
+
+func TestServiceMonitorLabelLimits(t *testing.T) {
+	uint64Ptr := func(i uint64) *uint64 { return &i }
+
+	for _, tc := range []struct {
+		name                          string
+		version                       string
+		spec                          monitoringv1.ServiceMonitorSpec
+		enforcedLabelLimit            *uint64
+		enforcedLabelNameLengthLimit  *uint64
+		enforcedLabelValueLengthLimit *uint64
+		enforcedKeepDroppedTargets    *uint64
+		expected                      map[string]int
+	}{
+		{
+			name:     "no limits",
+			version:  "v2.47.0",
+			expected: map[string]int{},
+		},
+		{
+			name:    "servicemonitor limits",
+			version: "v2.47.0",
+			spec: monitoringv1.ServiceMonitorSpec{
+				LabelLimit:            30,
+				LabelNameLengthLimit:  50,
+				LabelValueLengthLimit: 100,
+				KeepDroppedTargets:    10,
+			},
+			expected: map[string]int{
+				"label_limit":              30,
+				"label_name_length_limit":  50,
+				"label_value_length_limit": 100,
+				"keep_dropped_targets":     10,
+			},
+		},
+		{
+			name:    "unsupported keep dropped targets",
+			version: "v2.27.0",
+			spec: monitoringv1.ServiceMonitorSpec{
+				LabelLimit:         30,
+				KeepDroppedTargets: 10,
+			},
+			expected: map[string]int{
+				"label_limit": 30,
+			},
+		},
+		{
+			name:    "unsupported versions",
+			version: "v2.26.0",
+			spec: monitoringv1.ServiceMonitorSpec{
+				LabelLimit:            30,
+				LabelNameLengthLimit:  50,
+				LabelValueLengthLimit: 100,
+				KeepDroppedTargets:    10,
+			},
+			expected: map[string]int{},
+		},
+		{
+			name:    "enforced limits",
+			version: "v2.47.0",
+			spec: monitoringv1.ServiceMonitorSpec{
+				LabelLimit:            30,
+				LabelNameLengthLimit:  50,
+				LabelValueLengthLimit: 100,
+			},
+			enforcedLabelLimit:            uint64Ptr(20),
+			enforcedLabelNameLengthLimit:  uint64Ptr(100),
+			enforcedLabelValueLengthLimit: uint64Ptr(50),
+			enforcedKeepDroppedTargets:    uint64Ptr(5),
+			expected: map[string]int{
+				"label_limit":              20,
+				"label_name_length_limit":  50,
+				"label_value_length_limit": 50,
+				"keep_dropped_targets":     5,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.spec.Endpoints = []monitoringv1.Endpoint{{Port: "web"}}
+
+			cg := &configGenerator{}
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version:                       tc.version,
+						EnforcedLabelLimit:            tc.enforcedLabelLimit,
+						EnforcedLabelNameLengthLimit:  tc.enforcedLabelNameLengthLimit,
+						EnforcedLabelValueLengthLimit: tc.enforcedLabelValueLengthLimit,
+						EnforcedKeepDroppedTargets:    tc.enforcedKeepDroppedTargets,
+					},
+				},
+				map[string]*monitoringv1.ServiceMonitor{
+					"servicemonitor": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "testservicemonitor1",
+							Namespace: "default",
+						},
+						Spec: tc.spec,
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				ScrapeConfigs []map[string]interface{} `yaml:"scrape_configs"`
+			}
+			if err := yaml.Unmarshal(cfg, &result); err != nil {
+				t.Fatal(err)
+			}
+			if len(result.ScrapeConfigs) != 1 {
+				t.Fatalf("expected 1 scrape config, got %d", len(result.ScrapeConfigs))
+			}
+
+			limits := map[string]int{}
+			for _, k := range []string{"label_limit", "label_name_length_limit", "label_value_length_limit", "keep_dropped_targets"} {
+				if v, ok := result.ScrapeConfigs[0][k]; ok {
+					limits[k] = v.(int)
+				}
+			}
+
+			if !reflect.DeepEqual(tc.expected, limits) {
+				t.Fatalf("expected limits %v, got %v", tc.expected, limits)
+			}
+		})
+	}
+}
+
+func TestServiceMonitorSDRole(t *testing.T) {
+	endpoints := monitoringv1.EndpointsRole
+	endpointSlice := monitoringv1.EndpointSliceRole
+
+	for _, tc := range []struct {
+		name        string
+		version     string
+		defaultRole monitoringv1.ServiceDiscoveryRole
+		override    *monitoringv1.ServiceDiscoveryRole
+		unsupported bool
+		expected    string
+	}{
+		{
+			name:     "default",
+			version:  "2.21.0",
+			expected: kubernetesSDRoleEndpoint,
+		},
+		{
+			name:        "operator default",
+			version:     "2.21.0",
+			defaultRole: monitoringv1.EndpointSliceRole,
+			expected:    kubernetesSDRoleEndpointSlice,
+		},
+		{
+			name:     "monitor override",
+			version:  "2.21.0",
+			override: &endpointSlice,
+			expected: kubernetesSDRoleEndpointSlice,
+		},
+		{
+			name:        "monitor override of the operator default",
+			version:     "2.21.0",
+			defaultRole: monitoringv1.EndpointSliceRole,
+			override:    &endpoints,
+			expected:    kubernetesSDRoleEndpoint,
+		},
+		{
+			name:     "unsupported Prometheus version",
+			version:  "2.20.0",
+			override: &endpointSlice,
+			expected: kubernetesSDRoleEndpoint,
+		},
+		{
+			name:        "unsupported by the cluster",
+			version:     "2.21.0",
+			override:    &endpointSlice,
+			unsupported: true,
+			expected:    kubernetesSDRoleEndpoint,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := newConfigGenerator(log.NewNopLogger(), nil)
+			if tc.defaultRole != "" {
+				cg.serviceDiscoveryRole = tc.defaultRole
+			}
+			cg.endpointSliceSupported = !tc.unsupported
+
+			sm := &monitoringv1.ServiceMonitor{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: monitoringv1.ServiceMonitorSpec{
+					ServiceDiscoveryRole: tc.override,
+				},
+			}
+
+			role := cg.serviceMonitorSDRole(semver.MustParse(tc.version), sm)
+			if role != tc.expected {
+				t.Fatalf("expected role %q, got %q", tc.expected, role)
+			}
+		})
+	}
+}
+
+func TestAttachMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		role     string
+		expected string
+	}{
+		{
+			name:    "pod role",
+			version: "2.35.0",
+			role:    kubernetesSDRolePod,
+			expected: `kubernetes_sd_configs:
+- role: pod
+  namespaces:
+    names:
+    - default
+  attach_metadata:
+    node: true
+`,
+		},
+		{
+			name:    "endpoints role",
+			version: "2.37.0",
+			role:    kubernetesSDRoleEndpoint,
+			expected: `kubernetes_sd_configs:
+- role: endpoints
+  namespaces:
+    names:
+    - default
+  attach_metadata:
+    node: true
+`,
+		},
+		{
+			name:    "unsupported Prometheus version",
+			version: "2.36.0",
+			role:    kubernetesSDRoleEndpoint,
+			expected: `kubernetes_sd_configs:
+- role: endpoints
+  namespaces:
+    names:
+    - default
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := newConfigGenerator(log.NewNopLogger(), nil)
+			am := cg.attachMetadata(semver.MustParse(tc.version), tc.role, &monitoringv1.AttachMetadata{Node: true})
+
+			b, err := yaml.Marshal(yaml.MapSlice{cg.generateK8SSDConfig([]string{"default"}, nil, nil, tc.role, am, nil)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != string(b) {
+				t.Fatalf("expected configuration:\n%s\ngot:\n%s", tc.expected, string(b))
+			}
+		})
+	}
+}
+
+func TestServiceMonitorEndpointSliceRelabelings(t *testing.T) {
+	endpointSlice := monitoringv1.EndpointSliceRole
+
+	cg := newConfigGenerator(log.NewNopLogger(), nil)
+	cfg, err := cg.generateConfig(
+		&monitoringv1.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PrometheusSpec{
+				Version: "v2.21.0",
+			},
+		},
+		map[string]*monitoringv1.ServiceMonitor{
+			"default/test": {
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: monitoringv1.ServiceMonitorSpec{
+					ServiceDiscoveryRole: &endpointSlice,
+					Endpoints: []monitoringv1.Endpoint{
+						{Port: "web"},
+					},
+				},
+			},
+		},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"role: endpointslice",
+		"- __meta_kubernetes_endpointslice_port_name",
+		"- __meta_kubernetes_endpointslice_address_target_kind",
+	} {
+		if !strings.Contains(string(cfg), s) {
+			t.Fatalf("expected %q in the configuration:\n%s", s, cfg)
+		}
+	}
+	if strings.Contains(string(cfg), "__meta_kubernetes_endpoint_") {
+		t.Fatalf("unexpected endpoints meta labels in the configuration:\n%s", cfg)
+	}
+}
+
+func TestAuthorizationConfig(t *testing.T) {
+	credentials := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+		Key:                  "token",
+	}
+	authorizationSecrets := map[string]string{
+		"serviceMonitor/default/test/0": "sm-token",
+		"podMonitor/default/test/0":     "pm-token",
+		"probe/default/test":            "probe-token",
+		"nodeMonitor/default/test/0":    "nm-token",
+		"remoteWrite/0":                 "rw-token",
+		"remoteRead/0":                  "rr-token",
+	}
+
+	for _, tc := range []struct {
+		version  string
+		expected []string
+	}{
+		{
+			version: "v2.26.0",
+			expected: []string{
+				"authorization:\n    type: Bearer\n    credentials: sm-token\n",
+				"authorization:\n    credentials: pm-token\n",
+				"authorization:\n    credentials: probe-token\n",
+				"authorization:\n    credentials: nm-token\n",
+				"authorization:\n    credentials: rw-token\n",
+				"authorization:\n    credentials: rr-token\n",
+			},
+		},
+		{
+			version: "v2.25.0",
+		},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			cg := &configGenerator{}
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+						RemoteWrite: []monitoringv1.RemoteWriteSpec{
+							{URL: "http://example.com", Authorization: &monitoringv1.Authorization{Credentials: credentials}},
+						},
+						RemoteRead: []monitoringv1.RemoteReadSpec{
+							{URL: "http://example.com", Authorization: &monitoringv1.Authorization{Credentials: credentials}},
+						},
+					},
+				},
+				map[string]*monitoringv1.ServiceMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+						Spec: monitoringv1.ServiceMonitorSpec{
+							Endpoints: []monitoringv1.Endpoint{
+								{Port: "web", Authorization: &monitoringv1.Authorization{Type: "Bearer", Credentials: credentials}},
+							},
+						},
+					},
+				},
+				map[string]*monitoringv1.PodMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+						Spec: monitoringv1.PodMonitorSpec{
+							PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{
+								{Port: "web", Authorization: &monitoringv1.Authorization{Credentials: credentials}},
+							},
+						},
+					},
+				},
+				map[string]*monitoringv1.Probe{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+						Spec: monitoringv1.ProbeSpec{
+							ProberSpec:    monitoringv1.ProberSpec{URL: "blackbox-exporter:9115"},
+							Targets:       monitoringv1.ProbeTargets{StaticConfig: &monitoringv1.ProbeTargetStaticConfig{Targets: []string{"example.com"}}},
+							Authorization: &monitoringv1.Authorization{Credentials: credentials},
+						},
+					},
+				},
+				map[string]*monitoringv1.NodeMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+						Spec: monitoringv1.NodeMonitorSpec{
+							Endpoints: []monitoringv1.NodeMetricsEndpoint{
+								{Authorization: &monitoringv1.Authorization{Credentials: credentials}},
+							},
+						},
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				authorizationSecrets,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range tc.expected {
+				if !strings.Contains(string(cfg), s) {
+					t.Fatalf("expected %q in the configuration:\n%s", s, cfg)
+				}
+			}
+			if len(tc.expected) == 0 && strings.Contains(string(cfg), "authorization:") {
+				t.Fatalf("unexpected authorization section in the configuration:\n%s", cfg)
+			}
+		})
+	}
+}
+
+func TestMonitorOAuth2Config(t *testing.T) {
+	oauth2 := &monitoringv1.OAuth2{
+		ClientID: monitoringv1.SecretOrConfigMap{
+			Secret: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "oauth2"},
+				Key:                  "id",
+			},
+		},
+		ClientSecret: v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "oauth2"},
+			Key:                  "secret",
+		},
+		TokenURL:       "https://auth.example.com/token",
+		Scopes:         []string{"metrics"},
+		EndpointParams: map[string]string{"audience": "api"},
+	}
+	oauth2Secrets := map[string]OAuth2Credentials{
+		"serviceMonitor/default/test/0": {clientID: "sm-id", clientSecret: "sm-secret"},
+		"podMonitor/default/test/0":     {clientID: "pm-id", clientSecret: "pm-secret"},
+	}
+
+	for _, tc := range []struct {
+		version  string
+		expected []string
+	}{
+		{
+			version: "v2.27.0",
+			expected: []string{
+				"oauth2:\n    client_id: sm-id\n    client_secret: sm-secret\n    token_url: https://auth.example.com/token\n    scopes:\n    - metrics\n    endpoint_params:\n      audience: api\n",
+				"oauth2:\n    client_id: pm-id\n    client_secret: pm-secret\n    token_url: https://auth.example.com/token\n",
+			},
+		},
+		{
+			version: "v2.26.0",
+		},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			cg := &configGenerator{}
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+					},
+				},
+				map[string]*monitoringv1.ServiceMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+						Spec: monitoringv1.ServiceMonitorSpec{
+							Endpoints: []monitoringv1.Endpoint{
+								{Port: "web", OAuth2: oauth2},
+							},
+						},
+					},
+				},
+				map[string]*monitoringv1.PodMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+						Spec: monitoringv1.PodMonitorSpec{
+							PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{
+								{Port: "web", OAuth2: &monitoringv1.OAuth2{ClientID: oauth2.ClientID, ClientSecret: oauth2.ClientSecret, TokenURL: oauth2.TokenURL}},
+							},
+						},
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				oauth2Secrets,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range tc.expected {
+				if !strings.Contains(string(cfg), s) {
+					t.Fatalf("expected %q in the configuration:\n%s", s, cfg)
+				}
+			}
+			if len(tc.expected) == 0 && strings.Contains(string(cfg), "oauth2:") {
+				t.Fatalf("unexpected oauth2 section in the configuration:\n%s", cfg)
+			}
+		})
+	}
+}
+
+func TestFederationConfig(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "global",
+			Namespace: "monitoring",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Version: "v2.26.0",
+			Federation: []monitoringv1.FederationSpec{
+				{
+					Name:      "leaf",
+					Namespace: "team-a",
+					Match:     []string{`{__name__=~"job:.*"}`},
+					Interval:  "1m",
+					Scheme:    "https",
+					TLSConfig: &monitoringv1.TLSConfig{CAFile: "/etc/ca.crt"},
+					Authorization: &monitoringv1.Authorization{
+						Credentials: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "federation"},
+							Key:                  "token",
+						},
+					},
+					MetricRelabelConfigs: []*monitoringv1.RelabelConfig{
+						{Action: "labeldrop", Regex: "prometheus_replica"},
+					},
+				},
+			},
+		},
+	}
+
+	cg := &configGenerator{}
+	cfg, err := yaml.Marshal(cg.generateFederationConfig(
+		semver.MustParse("2.26.0"),
+		p, p.Spec.Federation[0], 0,
+		nil,
+		nil,
+		map[string]string{"federation/0": "secret-token"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `job_name: federate/team-a/leaf/0
+honor_labels: true
+scrape_interval: 1m
+metrics_path: /federate
+params:
+  match[]:
+  - '{__name__=~"job:.*"}'
+scheme: https
+tls_config:
+  insecure_skip_verify: false
+  ca_file: /etc/ca.crt
+authorization:
+  credentials: secret-token
+kubernetes_sd_configs:
+- role: endpoints
+  namespaces:
+    names:
+    - team-a
+relabel_configs:
+- action: keep
+  source_labels:
+  - __meta_kubernetes_service_name
+  regex: prometheus-operated
+- action: keep
+  source_labels:
+  - __meta_kubernetes_pod_label_prometheus
+  regex: leaf
+- action: keep
+  source_labels:
+  - __meta_kubernetes_endpoint_port_name
+  regex: web
+metric_relabel_configs:
+- regex: prometheus_replica
+  action: labeldrop
+`
+	if string(cfg) != expected {
+		t.Fatalf("unexpected configuration:\n%s", pretty.Compare(expected, string(cfg)))
+	}
+}
+
+func TestAgentConfig(t *testing.T) {
+	cg := &configGenerator{}
+	cfg, err := cg.generateAgentConfig(
+		agentAsPrometheus(&monitoringv1.PrometheusAgent{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: monitoringv1.PrometheusAgentSpec{
+				Version:        "v2.32.1",
+				ScrapeInterval: "15s",
+				RemoteWrite: []monitoringv1.RemoteWriteSpec{
+					{
+						URL: "http://example.com",
+					},
+				},
+			},
+		}),
+		nil,
+		nil,
+		nil,
+		nil,
+		map[string]BasicAuthCredentials{},
+		map[string]BearerToken{},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `global:
+  scrape_interval: 15s
+  external_labels:
+    prometheus: default/test
+    prometheus_replica: $(POD_NAME)
+scrape_configs: []
+remote_write:
+- url: http://example.com
+  remote_timeout: 30s
+`
+	if string(cfg) != expected {
+		t.Fatalf("unexpected configuration:\n%s", pretty.Compare(expected, string(cfg)))
+	}
+}
+
+func TestScrapeClasses(t *testing.T) {
+	ca := monitoringv1.SecretOrConfigMap{
+		Secret: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "ca"},
+			Key:                  "ca.crt",
+		},
+	}
+	credentials := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "secret"},
+		Key:                  "token",
+	}
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "monitoring"},
+		Spec: monitoringv1.PrometheusSpec{
+			Version: "v2.26.0",
+			ScrapeClasses: []monitoringv1.ScrapeClass{
+				{
+					Name:      "mtls",
+					Default:   true,
+					TLSConfig: &monitoringv1.TLSConfig{CA: ca},
+					Relabelings: []*monitoringv1.RelabelConfig{
+						{TargetLabel: "cluster", Replacement: "prod"},
+					},
+				},
+				{
+					Name:          "token",
+					Authorization: &monitoringv1.Authorization{Credentials: credentials},
+				},
+			},
+		},
+	}
+
+	cg := &configGenerator{}
+	cfg, err := cg.generateConfig(
+		p,
+		map[string]*monitoringv1.ServiceMonitor{
+			"default/default-class": {
+				ObjectMeta: metav1.ObjectMeta{Name: "default-class", Namespace: "default"},
+				Spec: monitoringv1.ServiceMonitorSpec{
+					Endpoints: []monitoringv1.Endpoint{
+						{
+							Port: "web",
+							RelabelConfigs: []*monitoringv1.RelabelConfig{
+								{TargetLabel: "team", Replacement: "a"},
+							},
+						},
+					},
+				},
+			},
+			"default/own-tls": {
+				ObjectMeta: metav1.ObjectMeta{Name: "own-tls", Namespace: "default"},
+				Spec: monitoringv1.ServiceMonitorSpec{
+					Endpoints: []monitoringv1.Endpoint{
+						{Port: "web", TLSConfig: &monitoringv1.TLSConfig{CAFile: "/etc/ca.crt"}},
+					},
+				},
+			},
+		},
+		map[string]*monitoringv1.PodMonitor{
+			"default/token-class": {
+				ObjectMeta: metav1.ObjectMeta{Name: "token-class", Namespace: "default"},
+				Spec: monitoringv1.PodMonitorSpec{
+					ScrapeClassName:     pointer.StringPtr("token"),
+					PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{{Port: "web"}},
+				},
+			},
+		},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		map[string]string{"scrapeClass/token": "class-token"},
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conf struct {
+		ScrapeConfigs []struct {
+			JobName        string                   `yaml:"job_name"`
+			TLSConfig      map[string]string        `yaml:"tls_config"`
+			Authorization  map[string]string        `yaml:"authorization"`
+			RelabelConfigs []map[string]interface{} `yaml:"relabel_configs"`
+		} `yaml:"scrape_configs"`
+	}
+	if err := yaml.Unmarshal(cfg, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.ScrapeConfigs) != 3 {
+		t.Fatalf("expected 3 scrape configs, got %d", len(conf.ScrapeConfigs))
+	}
+
+	classCAFile := path.Join(tlsAssetsDir, tlsAssetKeyFromSelector("monitoring", ca).String())
+	for _, sc := range conf.ScrapeConfigs {
+		var relabelTargets []string
+		for _, r := range sc.RelabelConfigs {
+			if l, _ := r["target_label"].(string); l == "cluster" || l == "team" {
+				relabelTargets = append(relabelTargets, l)
+			}
+		}
+
+		switch sc.JobName {
+		case "default/default-class/0":
+			if sc.TLSConfig["ca_file"] != classCAFile {
+				t.Fatalf("%s: expected the CA file of the default scrape class, got %v", sc.JobName, sc.TLSConfig)
+			}
+			if !reflect.DeepEqual(relabelTargets, []string{"cluster", "team"}) {
+				t.Fatalf("%s: expected the scrape class relabelings before the endpoint relabelings, got %v", sc.JobName, relabelTargets)
+			}
+		case "default/own-tls/0":
+			if sc.TLSConfig["ca_file"] != "/etc/ca.crt" {
+				t.Fatalf("%s: expected the CA file of the endpoint, got %v", sc.JobName, sc.TLSConfig)
+			}
+		case "default/token-class/0":
+			if sc.TLSConfig != nil {
+				t.Fatalf("%s: unexpected TLS configuration %v", sc.JobName, sc.TLSConfig)
+			}
+			if sc.Authorization["credentials"] != "class-token" {
+				t.Fatalf("%s: expected the credentials of the scrape class, got %v", sc.JobName, sc.Authorization)
+			}
+			if len(relabelTargets) != 0 {
+				t.Fatalf("%s: unexpected relabelings %v", sc.JobName, relabelTargets)
+			}
+		default:
+			t.Fatalf("unexpected job %q", sc.JobName)
+		}
+	}
+}
+
+func TestBoundServiceAccountTokenConfig(t *testing.T) {
+	cg := &configGenerator{}
+	cfg, err := cg.generateConfig(
+		&monitoringv1.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: monitoringv1.PrometheusSpec{
+				BoundServiceAccountToken: &monitoringv1.BoundServiceAccountToken{Audience: "metrics"},
+			},
+		},
+		map[string]*monitoringv1.ServiceMonitor{
+			"default/test": {
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: monitoringv1.ServiceMonitorSpec{
+					Endpoints: []monitoringv1.Endpoint{
+						{Port: "web", BearerTokenBoundServiceAccount: true},
+					},
+				},
+			},
+		},
+		nil,
+		nil,
+		map[string]*monitoringv1.NodeMonitor{
+			"default/test": {
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: monitoringv1.NodeMonitorSpec{
+					Endpoints: []monitoringv1.NodeMetricsEndpoint{
+						{BearerTokenBoundServiceAccount: true},
+					},
+				},
+			},
+		},
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "bearer_token_file: " + path.Join(boundTokenDir, boundTokenFilename) + "\n"
+	if n := strings.Count(string(cfg), expected); n != 2 {
+		t.Fatalf("expected %q twice in the configuration, got %d:\n%s", expected, n, cfg)
+	}
+}
+
+func TestScrapeProtocols(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		expected bool
+	}{
+		{
+			name:     "supported version",
+			version:  "v2.49.0",
+			expected: true,
+		},
+		{
+			name:    "unsupported version",
+			version: "v2.48.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := newConfigGenerator(log.NewNopLogger(), nil)
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+						ScrapeProtocols: []monitoringv1.ScrapeProtocol{
+							monitoringv1.ScrapeProtocolPrometheusProto,
+							monitoringv1.ScrapeProtocolOpenMetricsText1_0_0,
+						},
+					},
+				},
+				map[string]*monitoringv1.ServiceMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test",
+							Namespace: "default",
+						},
+						Spec: monitoringv1.ServiceMonitorSpec{
+							Endpoints: []monitoringv1.Endpoint{
+								{
+									Port:            "web",
+									ScrapeProtocols: []monitoringv1.ScrapeProtocol{monitoringv1.ScrapeProtocolOpenMetricsText1_0_0},
+								},
+							},
+						},
+					},
+				},
+				map[string]*monitoringv1.PodMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test",
+							Namespace: "default",
+						},
+						Spec: monitoringv1.PodMonitorSpec{
+							PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{
+								{
+									Port: "web",
+								},
+							},
+						},
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The global section and the ServiceMonitor job have the
+			// protocols, the PodMonitor job inherits the global ones.
+			expected := map[string]int{
+				"scrape_protocols:\n":      0,
+				"- PrometheusProto\n":      0,
+				"- OpenMetricsText1.0.0\n": 0,
+			}
+			if tc.expected {
+				expected = map[string]int{
+					"scrape_protocols:\n":      2,
+					"- PrometheusProto\n":      1,
+					"- OpenMetricsText1.0.0\n": 2,
+				}
+			}
+			for s, n := range expected {
+				if got := strings.Count(string(cfg), s); got != n {
+					t.Fatalf("expected %q %d times in the configuration, got %d:\n%s", s, n, got, cfg)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectorMechanism(t *testing.T) {
+	roleSelector := monitoringv1.SelectorMechanismRole
+	selector := metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "web"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"frontend", "backend"}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		version  string
+		expected map[string]int
+	}{
+		{
+			name:    "role selectors",
+			version: "v2.17.0",
+			expected: map[string]int{
+				// The roles are also listed by the discovery configurations.
+				"selectors:\n":        2,
+				"- role: endpoints\n": 2,
+				"- role: service\n":   1,
+				"- role: pod\n":       2,
+				"label: app=web,tier in (backend,frontend)\n": 3,
+				"__meta_kubernetes_service_label_app":         0,
+				"__meta_kubernetes_pod_label_app":             0,
+			},
+		},
+		{
+			name:    "unsupported version",
+			version: "v2.16.0",
+			expected: map[string]int{
+				"selectors:\n":                        0,
+				"__meta_kubernetes_service_label_app": 1,
+				"__meta_kubernetes_pod_label_app":     1,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cg := newConfigGenerator(log.NewNopLogger(), nil)
+			cfg, err := cg.generateConfig(
+				&monitoringv1.Prometheus{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test",
+						Namespace: "default",
+					},
+					Spec: monitoringv1.PrometheusSpec{
+						Version: tc.version,
+					},
+				},
+				map[string]*monitoringv1.ServiceMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test",
+							Namespace: "default",
+						},
+						Spec: monitoringv1.ServiceMonitorSpec{
+							Selector:          selector,
+							SelectorMechanism: &roleSelector,
+							Endpoints:         []monitoringv1.Endpoint{{Port: "web"}},
+						},
+					},
+				},
+				map[string]*monitoringv1.PodMonitor{
+					"default/test": {
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test",
+							Namespace: "default",
+						},
+						Spec: monitoringv1.PodMonitorSpec{
+							Selector:            selector,
+							SelectorMechanism:   &roleSelector,
+							PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{{Port: "web"}},
+						},
+					},
+				},
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for s, n := range tc.expected {
+				if got := strings.Count(string(cfg), s); got != n {
+					t.Fatalf("expected %q %d times in the configuration, got %d:\n%s", s, n, got, cfg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// reloadPropagationDelay covers the kubelet sync period and the cache TTL
+// after which the updated Secrets and ConfigMaps are visible in the Pods.
+const reloadPropagationDelay = 2 * time.Minute
+
+// httpReloadVersion is the first Prometheus version expanding the
+// environment variables of the external labels, which replaces the
+// substitution done by the config reloader.
+var httpReloadVersion = semver.MustParse("2.27.0")
+
+// httpReload returns whether the operator reloads Prometheus through its
+// HTTP API instead of the config reloader sidecars.
+func httpReload(p *monitoringv1.Prometheus) bool {
+	return p.Spec.ReloadStrategy != nil && *p.Spec.ReloadStrategy == monitoringv1.HTTPReloadStrategyType
+}
+
+// checkReloadStrategy returns an error if the reload strategy can't be used
+// with the Prometheus object.
+func checkReloadStrategy(p *monitoringv1.Prometheus) error {
+	if !httpReload(p) {
+		return nil
+	}
+
+	version, err := semver.ParseTolerant(operator.StringValOrDefault(p.Spec.Version, operator.DefaultPrometheusVersion))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Prometheus version")
+	}
+	if version.LT(httpReloadVersion) {
+		return errors.Errorf("the %s reload strategy requires Prometheus %s or newer", monitoringv1.HTTPReloadStrategyType, httpReloadVersion)
+	}
+
+	// Loopback-only instances can't be reached by the operator.
+	if p.Spec.ListenLocal {
+		return errors.Errorf("the %s reload strategy can't be used with listenLocal", monitoringv1.HTTPReloadStrategyType)
+	}
+
+	return nil
+}
+
+// reloadState tracks the reloads of a Prometheus instance for a given state
+// of its generated Secrets and ConfigMaps.
+type reloadState struct {
+	inputs  string
+	changed time.Time
+	done    bool
+}
+
+// httpReloader reloads the Prometheus Pods through their HTTP API when the
+// generated Secrets and ConfigMaps change. Since the kubelet updates the
+// mounted files with a delay, the Pods are reloaded right away and once
+// more after reloadPropagationDelay.
+type httpReloader struct {
+	logger        log.Logger
+	kclient       kubernetes.Interface
+	client        *http.Client
+	clusterDomain string
+	now           func() time.Time
+
+	mtx   sync.Mutex
+	state map[string]reloadState
+}
+
+func newHTTPReloader(logger log.Logger, kclient kubernetes.Interface, clusterDomain string) *httpReloader {
+	return &httpReloader{
+		logger:        logger,
+		kclient:       kclient,
+		clusterDomain: clusterDomain,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		now:           time.Now,
+		state:         map[string]reloadState{},
+	}
+}
+
+// forget drops the state of a deleted Prometheus instance.
+func (r *httpReloader) forget(key string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	delete(r.state, key)
+}
+
+// sync reloads the Prometheus Pods if needed and returns the delay after
+// which it has to be called again, zero if the reloads are done.
+func (r *httpReloader) sync(ctx context.Context, key string, p *monitoringv1.Prometheus) (time.Duration, error) {
+	inputs, err := r.reloadInputs(ctx, p)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mtx.Lock()
+	st, ok := r.state[key]
+	r.mtx.Unlock()
+
+	now := r.now()
+	switch {
+	case !ok, st.inputs != inputs:
+		// The Pods may run with an older configuration after a restart of
+		// the operator too.
+		st = reloadState{inputs: inputs, changed: now}
+	case st.done:
+		return 0, nil
+	}
+
+	if err := r.reload(ctx, p); err != nil {
+		r.mtx.Lock()
+		r.state[key] = st
+		r.mtx.Unlock()
+		return 0, err
+	}
+
+	elapsed := now.Sub(st.changed)
+	st.done = elapsed >= reloadPropagationDelay
+
+	r.mtx.Lock()
+	r.state[key] = st
+	r.mtx.Unlock()
+
+	if st.done {
+		return 0, nil
+	}
+	return reloadPropagationDelay - elapsed, nil
+}
+
+// reloadInputs returns a hash of the versions of the Secrets and ConfigMaps
+// generated for the Prometheus object.
+func (r *httpReloader) reloadInputs(ctx context.Context, p *monitoringv1.Prometheus) (string, error) {
+	opts := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", labelPrometheusName, p.Name)}
+
+	var versions []string
+	secrets, err := r.kclient.CoreV1().Secrets(p.Namespace).List(ctx, opts)
+	if err != nil {
+		return "", errors.Wrap(err, "listing secrets failed")
+	}
+	for _, s := range secrets.Items {
+		versions = append(versions, "secret/"+s.Name+"/"+s.ResourceVersion)
+	}
+
+	cms, err := r.kclient.CoreV1().ConfigMaps(p.Namespace).List(ctx, opts)
+	if err != nil {
+		return "", errors.Wrap(err, "listing configmaps failed")
+	}
+	for _, cm := range cms.Items {
+		versions = append(versions, "configmap/"+cm.Name+"/"+cm.ResourceVersion)
+	}
+
+	sort.Strings(versions)
+	h := sha256.New()
+	for _, v := range versions {
+		fmt.Fprintln(h, v)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// reload sends a reload request to the ready Pods of the Prometheus
+// instance. The Pods which aren't ready load the current configuration
+// when starting.
+func (r *httpReloader) reload(ctx context.Context, p *monitoringv1.Prometheus) error {
+	pods, err := r.kclient.CoreV1().Pods(p.Namespace).List(ctx, ListOptions(p.Name))
+	if err != nil {
+		return errors.Wrap(err, "listing pods failed")
+	}
+
+	tlsConfig, err := webconfig.ClientTLSConfig(ctx, r.kclient.CoreV1(), p.Namespace, p.Spec.Web)
+	if err != nil {
+		return errors.Wrap(err, "invalid web settings")
+	}
+
+	routePrefix := "/"
+	if p.Spec.RoutePrefix != "" {
+		routePrefix = p.Spec.RoutePrefix
+	}
+
+	var failed []string
+	for _, pod := range pods.Items {
+		if ready, err := k8sutil.PodRunningAndReady(pod); err != nil || !ready {
+			continue
+		}
+
+		host := podHost(pod.Name, p.Namespace, r.clusterDomain)
+		u := fmt.Sprintf("%s://%s:9090%s", webconfig.Scheme(p.Spec.Web), host, path.Join(routePrefix, "/-/reload"))
+		if err := r.post(ctx, webClient(r.client, tlsConfig, host), u); err != nil {
+			level.Warn(r.logger).Log("msg", "reloading Prometheus failed", "pod", pod.Name, "namespace", p.Namespace, "err", err)
+			failed = append(failed, pod.Name)
+			continue
+		}
+		level.Debug(r.logger).Log("msg", "Prometheus reloaded", "pod", pod.Name, "namespace", p.Namespace)
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("reloading pods %v failed", failed)
+	}
+
+	return nil
+}
+
+func (r *httpReloader) post(ctx context.Context, client *http.Client, u string) error {
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return nil
+}
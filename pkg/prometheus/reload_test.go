@@ -0,0 +1,172 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckReloadStrategy(t *testing.T) {
+	httpStrategy := monitoringv1.HTTPReloadStrategyType
+	signalStrategy := monitoringv1.ProcessSignalReloadStrategyType
+
+	for _, tc := range []struct {
+		name     string
+		spec     monitoringv1.PrometheusSpec
+		expected bool
+	}{
+		{
+			name: "default strategy",
+			spec: monitoringv1.PrometheusSpec{Version: "v2.20.0"},
+		},
+		{
+			name: "process signal strategy",
+			spec: monitoringv1.PrometheusSpec{Version: "v2.20.0", ReloadStrategy: &signalStrategy},
+		},
+		{
+			name: "http strategy",
+			spec: monitoringv1.PrometheusSpec{Version: "v2.27.0", ReloadStrategy: &httpStrategy},
+		},
+		{
+			name:     "http strategy with old version",
+			spec:     monitoringv1.PrometheusSpec{Version: "v2.26.0", ReloadStrategy: &httpStrategy},
+			expected: true,
+		},
+		{
+			name:     "http strategy with listenLocal",
+			spec:     monitoringv1.PrometheusSpec{Version: "v2.27.0", ReloadStrategy: &httpStrategy, ListenLocal: true},
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkReloadStrategy(&monitoringv1.Prometheus{Spec: tc.spec})
+			if tc.expected != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+// redirectTransport sends all the requests to the test server and records
+// the original hosts.
+type redirectTransport struct {
+	target *url.URL
+	hosts  []string
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.hosts = append(rt.hosts, req.URL.Host)
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPReloaderSync(t *testing.T) {
+	var reloads int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/-/reload" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reloads++
+	}))
+	defer srv.Close()
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            configSecretName(p.Name),
+			Namespace:       "default",
+			Labels:          managedLabels(p),
+			ResourceVersion: "1",
+		},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "prometheus-test-0",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "prometheus", "prometheus": "test"},
+		},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+	kclient := fake.NewSimpleClientset(secret, pod)
+
+	now := time.Now()
+	rt := &redirectTransport{target: target}
+	r := newHTTPReloader(log.NewNopLogger(), kclient, "cluster.local")
+	r.client = &http.Client{Transport: rt}
+	r.now = func() time.Time { return now }
+
+	sync := func(expectedReloads int, expectedRequeue time.Duration) {
+		t.Helper()
+		requeue, err := r.sync(context.Background(), "default/test", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requeue != expectedRequeue {
+			t.Fatalf("expected requeue after %v, got %v", expectedRequeue, requeue)
+		}
+		if reloads != expectedReloads {
+			t.Fatalf("expected %d reloads, got %d", expectedReloads, reloads)
+		}
+	}
+
+	// The pods are reloaded right away and once more after the propagation
+	// delay.
+	sync(1, reloadPropagationDelay)
+	if expected := "prometheus-test-0.prometheus-operated.default.svc.cluster.local:9090"; rt.hosts[0] != expected {
+		t.Fatalf("expected request to %q, got %q", expected, rt.hosts[0])
+	}
+	now = now.Add(reloadPropagationDelay)
+	sync(2, 0)
+	sync(2, 0)
+
+	// Updating the generated objects triggers new reloads.
+	secret.ResourceVersion = "2"
+	if _, err := kclient.CoreV1().Secrets("default").Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	sync(3, reloadPropagationDelay)
+	now = now.Add(time.Minute)
+	sync(4, reloadPropagationDelay-time.Minute)
+	now = now.Add(time.Minute)
+	sync(5, 0)
+
+	r.forget("default/test")
+	sync(6, reloadPropagationDelay)
+}
@@ -0,0 +1,409 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/ghodss/yaml"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	namespacelabeler "github.com/prometheus-operator/prometheus-operator/pkg/namespace-labeler"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+)
+
+const (
+	remoteRulesDir        = "/etc/prometheus/remote-rules"
+	remoteRulesVolumeName = "remote-rules"
+
+	defaultRemoteRulesInterval = time.Minute
+)
+
+// remoteRuleFiles returns whether rule files are fetched from remote
+// endpoints for the Prometheus object.
+func remoteRuleFiles(p *monitoringv1.Prometheus) bool {
+	return p.Spec.RemoteRuleFiles != nil && len(p.Spec.RemoteRuleFiles.URLs) > 0
+}
+
+// remoteRulesInterval returns the interval at which the remote rule files of
+// the Prometheus object are fetched.
+func remoteRulesInterval(p *monitoringv1.Prometheus) time.Duration {
+	d, err := time.ParseDuration(p.Spec.RemoteRuleFiles.Interval)
+	if err != nil || d <= 0 {
+		return defaultRemoteRulesInterval
+	}
+	return d
+}
+
+// checkRemoteRuleFiles returns an error if the remote rule files can't be
+// fetched.
+func checkRemoteRuleFiles(p *monitoringv1.Prometheus) error {
+	if !remoteRuleFiles(p) {
+		return nil
+	}
+
+	if interval := p.Spec.RemoteRuleFiles.Interval; interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return errors.Wrapf(err, "invalid interval %q", interval)
+		}
+		if d <= 0 {
+			return errors.Errorf("interval %q must be positive", interval)
+		}
+	}
+
+	// The rule files are named after the last element of the URL path.
+	files := map[string]string{}
+	for _, s := range p.Spec.RemoteRuleFiles.URLs {
+		u, err := url.Parse(s)
+		if err != nil {
+			return errors.Wrapf(err, "invalid URL %q", s)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.Errorf("URL %q: scheme must be http or https", s)
+		}
+
+		name := path.Base(u.Path)
+		if !strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml") {
+			return errors.Errorf("URL %q: the file name must end with .yaml or .yml", s)
+		}
+		if prev, found := files[name]; found {
+			return errors.Errorf("URLs %q and %q resolve to the same file name %q", prev, s, name)
+		}
+		files[name] = s
+	}
+
+	return nil
+}
+
+// remoteRuleFilePaths returns the patterns of the rule files fetched from
+// remote endpoints.
+func remoteRuleFilePaths() []string {
+	return []string{
+		path.Join(remoteRulesDir, "*.yaml"),
+		path.Join(remoteRulesDir, "*.yml"),
+	}
+}
+
+func remoteRulesConfigMapName(name string) string {
+	return prefixedName(name) + "-remote-rules"
+}
+
+// remoteRulesVolume returns the volume of the ConfigMap holding the remote
+// rule files.
+func remoteRulesVolume(name string) v1.Volume {
+	return v1.Volume{
+		Name: remoteRulesVolumeName,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: remoteRulesConfigMapName(name),
+				},
+			},
+		},
+	}
+}
+
+// remoteRulesState holds the remote rule files of a Prometheus instance.
+type remoteRulesState struct {
+	fetched time.Time
+	// content and errs map the URLs to the content of the last successful
+	// fetch and to the error of the last failed one.
+	content map[string][]byte
+	errs    map[string]error
+}
+
+// remoteRulesFetcher fetches the remote rule files of the Prometheus
+// instances at the interval defined by their spec.
+type remoteRulesFetcher struct {
+	logger log.Logger
+	client *http.Client
+	now    func() time.Time
+
+	mtx   sync.Mutex
+	state map[string]remoteRulesState
+}
+
+func newRemoteRulesFetcher(logger log.Logger) *remoteRulesFetcher {
+	return &remoteRulesFetcher{
+		logger: logger,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+		state:  map[string]remoteRulesState{},
+	}
+}
+
+// forget drops the state of a deleted Prometheus instance.
+func (f *remoteRulesFetcher) forget(key string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	delete(f.state, key)
+}
+
+// sync fetches the remote rule files when the interval elapsed or the URLs
+// changed. It returns the state of the files and the delay after which it
+// has to be called again.
+func (f *remoteRulesFetcher) sync(ctx context.Context, key string, p *monitoringv1.Prometheus) (remoteRulesState, time.Duration) {
+	f.mtx.Lock()
+	st, ok := f.state[key]
+	f.mtx.Unlock()
+
+	now := f.now()
+	interval := remoteRulesInterval(p)
+	if ok && now.Sub(st.fetched) < interval && st.covers(p.Spec.RemoteRuleFiles.URLs) {
+		return st, st.fetched.Add(interval).Sub(now)
+	}
+
+	next := remoteRulesState{
+		fetched: now,
+		content: map[string][]byte{},
+		errs:    map[string]error{},
+	}
+	for _, u := range p.Spec.RemoteRuleFiles.URLs {
+		b, err := f.fetch(ctx, u)
+		if err != nil {
+			level.Warn(f.logger).Log("msg", "failed to fetch remote rule file", "url", u, "err", err, "namespace", p.Namespace, "prometheus", p.Name)
+			next.errs[u] = err
+			continue
+		}
+		next.content[u] = b
+	}
+
+	f.mtx.Lock()
+	f.state[key] = next
+	f.mtx.Unlock()
+
+	return next, interval
+}
+
+// covers returns whether the state holds the outcome of fetching all URLs.
+func (st remoteRulesState) covers(urls []string) bool {
+	for _, u := range urls {
+		_, fetched := st.content[u]
+		_, failed := st.errs[u]
+		if !fetched && !failed {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *remoteRulesFetcher) fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response body")
+	}
+	return b, nil
+}
+
+// createOrUpdateRemoteRulesConfigMap writes the remote rule files of the
+// Prometheus instance to a ConfigMap mounted by the Pods. The files are
+// validated and labeled like the PrometheusRule objects. The files which
+// can't be fetched or are invalid keep their last valid content, the errors
+// are stored in the result to be reported in the status.
+func (c *Operator) createOrUpdateRemoteRulesConfigMap(ctx context.Context, key string, p *monitoringv1.Prometheus, res *syncResult) error {
+	if !remoteRuleFiles(p) {
+		// The ConfigMap is deleted with the other obsolete rule ConfigMaps.
+		c.remoteRules.forget(key)
+		return nil
+	}
+
+	cClient := c.kclient.CoreV1().ConfigMaps(p.Namespace)
+	current, err := cClient.Get(ctx, remoteRulesConfigMapName(p.Name), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get remote rules ConfigMap")
+	}
+	exists := err == nil
+
+	version, err := semver.ParseTolerant(operator.StringValOrDefault(p.Spec.Version, operator.DefaultPrometheusVersion))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Prometheus version")
+	}
+
+	st, requeue := c.remoteRules.sync(ctx, key, p)
+	res.requeue = requeue
+
+	var currentData map[string]string
+	if exists {
+		currentData = current.Data
+	}
+	data, index, fileErrs := makeRemoteRuleFiles(p, version, st, currentData)
+
+	if size := bucketSize(data); size > maxConfigMapDataSize {
+		res.remoteRulesErr = errors.Errorf("the remote rule files are too large for a single Kubernetes ConfigMap (%d bytes)", size)
+		return res.remoteRulesErr
+	}
+
+	res.remoteRulesErr = nil
+	if len(fileErrs) > 0 {
+		res.remoteRulesErr = errors.New(strings.Join(fileErrs, "; "))
+	}
+
+	cm, err := makeRemoteRulesConfigMap(p, data, index)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := cClient.Create(ctx, cm, metav1.CreateOptions{})
+		return errors.Wrap(err, "failed to create remote rules ConfigMap")
+	}
+
+	if (len(cm.Data) == 0 && len(current.Data) == 0 || reflect.DeepEqual(cm.Data, current.Data)) &&
+		cm.Annotations[ruleFilesIndexAnnotation] == current.Annotations[ruleFilesIndexAnnotation] {
+		return nil
+	}
+	cm.ResourceVersion = current.ResourceVersion
+	_, err = cClient.Update(ctx, cm, metav1.UpdateOptions{})
+	return errors.Wrap(err, "failed to update remote rules ConfigMap")
+}
+
+// makeRemoteRuleFiles returns the content of the remote rule files and the
+// URLs they are fetched from, indexed by file name, and the errors of the
+// files which can't be fetched or are invalid. These files keep their
+// current content, if any.
+func makeRemoteRuleFiles(p *monitoringv1.Prometheus, version semver.Version, st remoteRulesState, current map[string]string) (map[string]string, map[string]string, []string) {
+	nsLabeler := namespacelabeler.New(
+		p.Spec.EnforcedNamespaceLabel,
+		p.Spec.PrometheusRulesExcludedFromEnforce,
+		true,
+	)
+
+	data := map[string]string{}
+	index := map[string]string{}
+	var errs []string
+	for _, s := range p.Spec.RemoteRuleFiles.URLs {
+		u, err := url.Parse(s)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s, err))
+			continue
+		}
+		name := path.Base(u.Path)
+
+		content, err := makeRemoteRuleFile(p, version, nsLabeler, name, st.content[s], st.errs[s])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s, err))
+			var ok bool
+			if content, ok = current[name]; !ok {
+				continue
+			}
+		}
+		data[name] = content
+		index[name] = s
+	}
+
+	return data, index, errs
+}
+
+// makeRemoteRuleFile returns the content of a remote rule file given the
+// outcome of its last fetch.
+func makeRemoteRuleFile(p *monitoringv1.Prometheus, version semver.Version, nsLabeler *namespacelabeler.Labeler, name string, b []byte, fetchErr error) (string, error) {
+	if fetchErr != nil {
+		return "", fetchErr
+	}
+
+	// The rule files are checked like the PrometheusRule objects of the
+	// Prometheus namespace, named after the file.
+	rule := &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: p.Namespace,
+			Name:      strings.TrimSuffix(name, path.Ext(name)),
+		},
+	}
+	if err := yaml.Unmarshal(b, &rule.Spec); err != nil {
+		return "", errors.Wrap(err, "invalid rule file")
+	}
+
+	ruleErrs, _ := validateRules(version, p.Spec.EnableFeatures, rule.Spec)
+	if len(ruleErrs) > 0 {
+		return "", errors.New(strings.Join(ruleErrs, "; "))
+	}
+	if err := nsLabeler.EnforceNamespaceLabel(rule); err != nil {
+		return "", err
+	}
+
+	return generateContent(rule.Spec)
+}
+
+func makeRemoteRulesConfigMap(p *monitoringv1.Prometheus, data map[string]string, index map[string]string) (*v1.ConfigMap, error) {
+	boolTrue := true
+
+	labels := map[string]string{
+		labelPrometheusName: p.Name,
+	}
+	for k, v := range managedByOperatorLabels {
+		labels[k] = v
+	}
+
+	// The index maps the rule files to the URLs they are fetched from.
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal rule files index")
+	}
+
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   remoteRulesConfigMapName(p.Name),
+			Labels: labels,
+			Annotations: map[string]string{
+				ruleFilesIndexAnnotation: string(indexJSON),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         p.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               p.Kind,
+					Name:               p.Name,
+					UID:                p.UID,
+				},
+			},
+		},
+		Data: data,
+	}, nil
+}
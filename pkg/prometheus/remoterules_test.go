@@ -0,0 +1,238 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckRemoteRuleFiles(t *testing.T) {
+	httpReloadStrategy := monitoringv1.HTTPReloadStrategyType
+
+	for _, tc := range []struct {
+		name           string
+		rrf            *monitoringv1.RemoteRuleFiles
+		reloadStrategy *monitoringv1.ReloadStrategyType
+		expected       bool
+	}{
+		{
+			name: "no remoteRuleFiles",
+		},
+		{
+			name: "valid URLs",
+			rrf: &monitoringv1.RemoteRuleFiles{
+				URLs: []string{
+					"https://raw.githubusercontent.com/example/rules/main/a.yaml",
+					"http://rules.example.com/b.yml",
+				},
+				Interval: "5m",
+			},
+		},
+		{
+			name: "invalid scheme",
+			rrf: &monitoringv1.RemoteRuleFiles{
+				URLs: []string{"git://example.com/rules/a.yaml"},
+			},
+			expected: true,
+		},
+		{
+			name: "invalid file extension",
+			rrf: &monitoringv1.RemoteRuleFiles{
+				URLs: []string{"https://example.com/rules/a.json"},
+			},
+			expected: true,
+		},
+		{
+			name: "duplicate file names",
+			rrf: &monitoringv1.RemoteRuleFiles{
+				URLs: []string{
+					"https://example.com/team-a/rules.yaml",
+					"https://example.com/team-b/rules.yaml",
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "invalid interval",
+			rrf: &monitoringv1.RemoteRuleFiles{
+				URLs:     []string{"https://example.com/rules/a.yaml"},
+				Interval: "5",
+			},
+			expected: true,
+		},
+		{
+			name: "HTTP reload strategy",
+			rrf: &monitoringv1.RemoteRuleFiles{
+				URLs: []string{"https://example.com/rules/a.yaml"},
+			},
+			reloadStrategy: &httpReloadStrategy,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &monitoringv1.Prometheus{
+				Spec: monitoringv1.PrometheusSpec{
+					RemoteRuleFiles: tc.rrf,
+					ReloadStrategy:  tc.reloadStrategy,
+				},
+			}
+			err := checkRemoteRuleFiles(p)
+			if tc.expected != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestRemoteRuleFilesStatefulSet(t *testing.T) {
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: monitoringv1.PrometheusSpec{
+			RemoteRuleFiles: &monitoringv1.RemoteRuleFiles{
+				URLs:     []string{"https://example.com/rules/a.yaml"},
+				Interval: "5m",
+			},
+		},
+	}, defaultTestConfig, []string{RuleConfigMapName("test", 0)}, "")
+	require.NoError(t, err)
+
+	found := false
+	for _, v := range sset.Spec.Template.Spec.Volumes {
+		if v.Name == remoteRulesVolumeName && v.ConfigMap != nil && v.ConfigMap.Name == "prometheus-test-remote-rules" {
+			found = true
+		}
+	}
+	require.True(t, found, "remote rules volume not found")
+
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		mounted := false
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == remoteRulesVolumeName && vm.MountPath == remoteRulesDir {
+				mounted = true
+				require.Equal(t, c.Name == "prometheus", vm.ReadOnly, "unexpected read-only mount in container %q", c.Name)
+			}
+		}
+
+		switch c.Name {
+		case "prometheus":
+			require.True(t, mounted, "remote rules volume not mounted in the prometheus container")
+		case "rules-configmap-reloader":
+			require.True(t, mounted, "remote rules volume not mounted in the rules-configmap-reloader container")
+			require.Contains(t, c.Args, "--volume-dir="+remoteRulesDir)
+		default:
+			require.False(t, mounted, "unexpected remote rules volume in container %q", c.Name)
+		}
+	}
+}
+
+func TestCreateOrUpdateRemoteRulesConfigMap(t *testing.T) {
+	files := map[string]string{
+		"/valid.yaml":   "groups:\n- name: test\n  rules:\n  - alert: Down\n    expr: up == 0\n",
+		"/invalid.yaml": "groups:\n- name: test\n  rules:\n  - alert: Down\n    expr: up ==\n",
+	}
+	var mtx sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	fetcher := newRemoteRulesFetcher(log.NewNopLogger())
+	fetcher.now = func() time.Time { return now }
+	c := &Operator{
+		kclient:     fake.NewSimpleClientset(),
+		logger:      log.NewNopLogger(),
+		remoteRules: fetcher,
+	}
+
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: monitoringv1.PrometheusSpec{
+			EnforcedNamespaceLabel: "namespace",
+			RemoteRuleFiles: &monitoringv1.RemoteRuleFiles{
+				URLs: []string{
+					srv.URL + "/valid.yaml",
+					srv.URL + "/invalid.yaml",
+					srv.URL + "/missing.yaml",
+				},
+				Interval: "5m",
+			},
+		},
+	}
+	get := func() *v1.ConfigMap {
+		cm, err := c.kclient.CoreV1().ConfigMaps("default").Get(context.Background(), "prometheus-test-remote-rules", metav1.GetOptions{})
+		require.NoError(t, err)
+		return cm
+	}
+
+	res := syncResult{remoteRulesErr: errRemoteRulesNotSynced}
+	require.NoError(t, c.createOrUpdateRemoteRulesConfigMap(context.Background(), "default/test", p, &res))
+	require.Equal(t, 5*time.Minute, res.requeue)
+	require.Error(t, res.remoteRulesErr)
+	require.Contains(t, res.remoteRulesErr.Error(), "invalid.yaml")
+	require.Contains(t, res.remoteRulesErr.Error(), "missing.yaml")
+	require.NotContains(t, res.remoteRulesErr.Error(), "/valid.yaml:")
+
+	cm := get()
+	require.Len(t, cm.Data, 1)
+	require.Contains(t, cm.Data["valid.yaml"], `up{namespace="default"} == 0`)
+	require.Equal(t, `{"valid.yaml":"`+srv.URL+`/valid.yaml"}`, cm.Annotations[ruleFilesIndexAnnotation])
+
+	// The files aren't fetched again before the interval elapses.
+	mtx.Lock()
+	files["/valid.yaml"] = files["/invalid.yaml"]
+	mtx.Unlock()
+	now = now.Add(time.Minute)
+	res = syncResult{}
+	require.NoError(t, c.createOrUpdateRemoteRulesConfigMap(context.Background(), "default/test", p, &res))
+	require.Equal(t, 4*time.Minute, res.requeue)
+	require.NotContains(t, res.remoteRulesErr.Error(), "/valid.yaml:")
+
+	// An invalid update keeps the last valid content.
+	now = now.Add(5 * time.Minute)
+	res = syncResult{}
+	require.NoError(t, c.createOrUpdateRemoteRulesConfigMap(context.Background(), "default/test", p, &res))
+	require.Contains(t, res.remoteRulesErr.Error(), "/valid.yaml:")
+	require.Contains(t, get().Data["valid.yaml"], `up{namespace="default"} == 0`)
+
+	// All files are valid.
+	p.Spec.RemoteRuleFiles.URLs = p.Spec.RemoteRuleFiles.URLs[:1]
+	mtx.Lock()
+	files["/valid.yaml"] = "groups: []\n"
+	mtx.Unlock()
+	now = now.Add(5 * time.Minute)
+	res = syncResult{}
+	require.NoError(t, c.createOrUpdateRemoteRulesConfigMap(context.Background(), "default/test", p, &res))
+	require.NoError(t, res.remoteRulesErr)
+	require.Equal(t, "{}\n", get().Data["valid.yaml"])
+}
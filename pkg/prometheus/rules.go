@@ -16,6 +16,7 @@ package prometheus
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
@@ -24,16 +25,85 @@ import (
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	namespacelabeler "github.com/prometheus-operator/prometheus-operator/pkg/namespace-labeler"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
 
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
+	"github.com/blang/semver"
 	"github.com/ghodss/yaml"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql/parser"
 )
 
-const labelPrometheusName = "prometheus-name"
+const (
+	labelPrometheusName = "prometheus-name"
+	labelRuleNamespace  = "rule-namespace"
+
+	// ruleFilesIndexAnnotation lists the PrometheusRule objects from which
+	// the rule files of a ConfigMap are generated.
+	ruleFilesIndexAnnotation = "prometheus-operator-rule-files-index"
+
+	invalidRulesReason = "InvalidRules"
+
+	// unknownFunctionPrefix starts the message of the parse errors for the
+	// functions unknown to the parser.
+	unknownFunctionPrefix = "unknown function with name "
+
+	// minRuleConfigMapSlots is the minimum number of pre-allocated rule
+	// ConfigMaps.
+	minRuleConfigMapSlots = 4
+)
+
+var (
+	// parserVersion is the Prometheus version of the PromQL parser used by
+	// the operator.
+	parserVersion = semver.MustParse("2.21.0")
+	// atModifierFeatureVersion is the first Prometheus version evaluating
+	// the `@` modifier with the promql-at-modifier feature flag.
+	atModifierFeatureVersion = semver.MustParse("2.25.0")
+	// atModifierVersion is the first Prometheus version evaluating the `@`
+	// modifier without the promql-at-modifier feature flag.
+	atModifierVersion = semver.MustParse("2.33.0")
+	// negativeOffsetFeatureVersion is the first Prometheus version
+	// evaluating negative offsets with the promql-negative-offset feature
+	// flag.
+	negativeOffsetFeatureVersion = semver.MustParse("2.26.0")
+	// negativeOffsetVersion is the first Prometheus version evaluating
+	// negative offsets without the promql-negative-offset feature flag.
+	negativeOffsetVersion = semver.MustParse("2.33.0")
+
+	// newFunctions maps the PromQL functions unknown to the parser of the
+	// operator to the first Prometheus version supporting them.
+	newFunctions = map[string]semver.Version{
+		"acos":               semver.MustParse("2.26.0"),
+		"acosh":              semver.MustParse("2.26.0"),
+		"asin":               semver.MustParse("2.26.0"),
+		"asinh":              semver.MustParse("2.26.0"),
+		"atan":               semver.MustParse("2.26.0"),
+		"atanh":              semver.MustParse("2.26.0"),
+		"clamp":              semver.MustParse("2.26.0"),
+		"cos":                semver.MustParse("2.26.0"),
+		"cosh":               semver.MustParse("2.26.0"),
+		"deg":                semver.MustParse("2.26.0"),
+		"last_over_time":     semver.MustParse("2.26.0"),
+		"pi":                 semver.MustParse("2.26.0"),
+		"rad":                semver.MustParse("2.26.0"),
+		"sgn":                semver.MustParse("2.26.0"),
+		"sin":                semver.MustParse("2.26.0"),
+		"sinh":               semver.MustParse("2.26.0"),
+		"tan":                semver.MustParse("2.26.0"),
+		"tanh":               semver.MustParse("2.26.0"),
+		"present_over_time":  semver.MustParse("2.29.0"),
+		"histogram_count":    semver.MustParse("2.40.0"),
+		"histogram_fraction": semver.MustParse("2.40.0"),
+		"histogram_sum":      semver.MustParse("2.40.0"),
+	}
+)
 
 // The maximum `Data` size of a ConfigMap seems to differ between
 // environments. This is probably due to different meta data sizes which count
@@ -41,6 +111,9 @@ const labelPrometheusName = "prometheus-name"
 // large buffer.
 var maxConfigMapDataSize = int(float64(v1.MaxSecretSize) * 0.5)
 
+// createOrUpdateRuleConfigMaps writes the rule files selected by the
+// Prometheus instance to the pre-allocated rule ConfigMaps and returns their
+// names. Only the ConfigMaps whose content changed are updated.
 func (c *Operator) createOrUpdateRuleConfigMaps(ctx context.Context, p *monitoringv1.Prometheus) ([]string, error) {
 	cClient := c.kclient.CoreV1().ConfigMaps(p.Namespace)
 
@@ -49,7 +122,7 @@ func (c *Operator) createOrUpdateRuleConfigMaps(ctx context.Context, p *monitori
 		return nil, err
 	}
 
-	newRules, err := c.selectRules(p, namespaces)
+	newRules, err := c.selectRules(ctx, p, namespaces)
 	if err != nil {
 		return nil, err
 	}
@@ -58,78 +131,138 @@ func (c *Operator) createOrUpdateRuleConfigMaps(ctx context.Context, p *monitori
 	if err != nil {
 		return nil, err
 	}
-	currentConfigMaps := currentConfigMapList.Items
-
-	currentRules := map[string]string{}
-	for _, cm := range currentConfigMaps {
-		for ruleFileName, ruleFile := range cm.Data {
-			currentRules[ruleFileName] = ruleFile
+	currentConfigMaps := map[string]v1.ConfigMap{}
+	assigned := map[string]string{}
+	for _, cm := range currentConfigMapList.Items {
+		currentConfigMaps[cm.Name] = cm
+		if ns, ok := cm.Labels[labelRuleNamespace]; ok {
+			assigned[cm.Name] = ns
 		}
 	}
 
-	equal := reflect.DeepEqual(newRules, currentRules)
-	if equal && len(currentConfigMaps) != 0 {
-		level.Debug(c.logger).Log(
-			"msg", "no PrometheusRule changes",
-			"namespace", p.Namespace,
-			"prometheus", p.Name,
-		)
-		currentConfigMapNames := []string{}
-		for _, cm := range currentConfigMaps {
-			currentConfigMapNames = append(currentConfigMapNames, cm.Name)
-		}
-		return currentConfigMapNames, nil
-	}
-
-	newConfigMaps, err := makeRulesConfigMaps(p, newRules)
+	newConfigMaps, err := makeRulesConfigMaps(p, newRules, assigned)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to make rules ConfigMaps")
 	}
 
 	newConfigMapNames := []string{}
 	for _, cm := range newConfigMaps {
+		cm := cm
 		newConfigMapNames = append(newConfigMapNames, cm.Name)
-	}
 
-	if len(currentConfigMaps) == 0 {
+		current, ok := currentConfigMaps[cm.Name]
+		delete(currentConfigMaps, cm.Name)
+
+		if !ok {
+			level.Debug(c.logger).Log(
+				"msg", "creating PrometheusRule configmap",
+				"configmap", cm.Name,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+			if _, err := cClient.Create(ctx, &cm, metav1.CreateOptions{}); err != nil {
+				return nil, errors.Wrapf(err, "failed to create ConfigMap '%v'", cm.Name)
+			}
+			continue
+		}
+
+		// The API server returns no data for the empty ConfigMaps.
+		if (len(cm.Data) == 0 && len(current.Data) == 0 || reflect.DeepEqual(cm.Data, current.Data)) &&
+			reflect.DeepEqual(cm.Labels, current.Labels) &&
+			cm.Annotations[ruleFilesIndexAnnotation] == current.Annotations[ruleFilesIndexAnnotation] {
+			continue
+		}
+
 		level.Debug(c.logger).Log(
-			"msg", "no PrometheusRule configmap found, creating new one",
+			"msg", "updating PrometheusRule configmap",
+			"configmap", cm.Name,
 			"namespace", p.Namespace,
 			"prometheus", p.Name,
 		)
-		for _, cm := range newConfigMaps {
-			_, err = cClient.Create(ctx, &cm, metav1.CreateOptions{})
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to create ConfigMap '%v'", cm.Name)
-			}
+		cm.ResourceVersion = current.ResourceVersion
+		if _, err := cClient.Update(ctx, &cm, metav1.UpdateOptions{}); err != nil {
+			return nil, errors.Wrapf(err, "failed to update ConfigMap '%v'", cm.Name)
 		}
+	}
+
+	// The remote rule files have their own ConfigMap.
+	if remoteRuleFiles(p) {
+		delete(currentConfigMaps, remoteRulesConfigMapName(p.Name))
+	}
+	if len(currentConfigMaps) == 0 {
 		return newConfigMapNames, nil
 	}
 
-	// Simply deleting old ConfigMaps and creating new ones for now. Could be
-	// replaced by logic that only deletes obsolete ConfigMaps in the future.
+	// The remaining ConfigMaps were generated by previous versions of the
+	// operator, before the number of rule ConfigMaps changed or for remote
+	// rule files which aren't defined anymore. The Pods
+	// fail to start when a mounted ConfigMap is missing, so they are only
+	// deleted once the StatefulSet doesn't mount them anymore.
+	var sset *appsv1.StatefulSet
+	obj, err := c.ssetInfs.Get(prometheusKeyToStatefulSetKey(p.Namespace + "/" + p.Name))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "retrieving statefulset failed")
+	}
+	if obj != nil {
+		sset = obj.(*appsv1.StatefulSet)
+	}
+
 	for _, cm := range currentConfigMaps {
-		err := cClient.Delete(ctx, cm.Name, metav1.DeleteOptions{})
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to delete current ConfigMap '%v'", cm.Name)
+		if configMapInUse(sset, cm.Name) {
+			level.Debug(c.logger).Log(
+				"msg", "keeping obsolete PrometheusRule configmap until the statefulset is rolled out",
+				"configmap", cm.Name,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+			continue
 		}
-	}
 
-	level.Debug(c.logger).Log(
-		"msg", "updating PrometheusRule",
-		"namespace", p.Namespace,
-		"prometheus", p.Name,
-	)
-	for _, cm := range newConfigMaps {
-		_, err = cClient.Create(ctx, &cm, metav1.CreateOptions{})
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to create new ConfigMap '%v'", cm.Name)
+		level.Debug(c.logger).Log(
+			"msg", "deleting obsolete PrometheusRule configmap",
+			"configmap", cm.Name,
+			"namespace", p.Namespace,
+			"prometheus", p.Name,
+		)
+		if err := cClient.Delete(ctx, cm.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to delete obsolete ConfigMap '%v'", cm.Name)
 		}
 	}
 
 	return newConfigMapNames, nil
 }
 
+// configMapInUse returns whether the Pods of the StatefulSet may mount the
+// ConfigMap. While the StatefulSet is rolled out, the Pods of the previous
+// revision may mount any ConfigMap.
+func configMapInUse(sset *appsv1.StatefulSet, name string) bool {
+	if sset == nil {
+		return false
+	}
+
+	if sset.Status.ObservedGeneration < sset.Generation ||
+		sset.Status.CurrentRevision != sset.Status.UpdateRevision ||
+		sset.Status.UpdatedReplicas < sset.Status.Replicas {
+		return true
+	}
+
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.ConfigMap != nil && vol.ConfigMap.Name == name {
+			return true
+		}
+		if vol.Projected == nil {
+			continue
+		}
+		for _, src := range vol.Projected.Sources {
+			if src.ConfigMap != nil && src.ConfigMap.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func prometheusRulesConfigMapSelector(prometheusName string) metav1.ListOptions {
 	return metav1.ListOptions{LabelSelector: fmt.Sprintf("%v=%v", labelPrometheusName, prometheusName)}
 }
@@ -162,48 +295,94 @@ func (c *Operator) selectRuleNamespaces(p *monitoringv1.Prometheus) ([]string, e
 	return namespaces, nil
 }
 
-func (c *Operator) selectRules(p *monitoringv1.Prometheus, namespaces []string) (map[string]string, error) {
-	rules := map[string]string{}
+// selectRules returns the rule files selected by the Prometheus instance,
+// indexed by namespace and file name. The PrometheusRule objects with
+// expressions invalid for the Prometheus version are skipped and reported in
+// their status. The expressions which can't be checked are only logged.
+func (c *Operator) selectRules(ctx context.Context, p *monitoringv1.Prometheus, namespaces []string) (map[string]map[string]string, error) {
+	rules := map[string]map[string]string{}
 
 	ruleSelector, err := metav1.LabelSelectorAsSelector(p.Spec.RuleSelector)
 	if err != nil {
 		return rules, errors.Wrap(err, "convert rule label selector to selector")
 	}
 
+	version, err := semver.ParseTolerant(operator.StringValOrDefault(p.Spec.Version, operator.DefaultPrometheusVersion))
+	if err != nil {
+		return rules, errors.Wrap(err, "failed to parse Prometheus version")
+	}
+
 	nsLabeler := namespacelabeler.New(
 		p.Spec.EnforcedNamespaceLabel,
 		p.Spec.PrometheusRulesExcludedFromEnforce,
 		true,
 	)
 
+	var selected []*monitoringv1.PrometheusRule
 	for _, ns := range namespaces {
-		var marshalErr error
 		err := c.ruleInfs.ListAllByNamespace(ns, ruleSelector, func(obj interface{}) {
-			promRule := obj.(*monitoringv1.PrometheusRule).DeepCopy()
+			selected = append(selected, obj.(*monitoringv1.PrometheusRule))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	for _, rule := range selected {
+		promRule := rule.DeepCopy()
+
+		ruleErrs, ruleWarnings := validateRules(version, p.Spec.EnableFeatures, promRule.Spec)
+		if len(ruleWarnings) > 0 {
+			level.Warn(c.logger).Log(
+				"msg", "prometheusrule can't be fully validated",
+				"warning", strings.Join(ruleWarnings, "; "),
+				"prometheusrule", rule.Namespace+"/"+rule.Name,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+		}
+		if len(ruleErrs) == 0 {
 			if err := nsLabeler.EnforceNamespaceLabel(promRule); err != nil {
-				marshalErr = err
-				return
+				ruleErrs = append(ruleErrs, err.Error())
 			}
+		}
 
-			content, err := generateContent(promRule.Spec)
-			if err != nil {
-				marshalErr = err
-				return
-			}
-			rules[fmt.Sprintf("%v-%v.yaml", promRule.Namespace, promRule.Name)] = content
-		})
+		c.updateRuleStatus(ctx, rule, p, version, ruleErrs)
+
+		if len(ruleErrs) > 0 {
+			level.Warn(c.logger).Log(
+				"msg", "skipping prometheusrule",
+				"error", strings.Join(ruleErrs, "; "),
+				"prometheusrule", rule.Namespace+"/"+rule.Name,
+				"namespace", p.Namespace,
+				"prometheus", p.Name,
+			)
+			continue
+		}
+
+		content, err := generateContent(promRule.Spec)
 		if err != nil {
 			return nil, err
 		}
-		if marshalErr != nil {
-			return nil, marshalErr
+		if _, ok := rules[promRule.Namespace]; !ok {
+			rules[promRule.Namespace] = map[string]string{}
 		}
+		rules[promRule.Namespace][ruleFileName(promRule.Namespace, promRule.Name)] = content
+	}
+
+	// The PrometheusRule objects which aren't selected anymore drop the
+	// state of the instance from their status.
+	keep := make(map[string]struct{}, len(selected))
+	for _, rule := range selected {
+		keep[rule.Namespace+"/"+rule.Name] = struct{}{}
 	}
+	c.pruneRuleStatus(ctx, p.Namespace, p.Name, keep)
 
 	ruleNames := []string{}
-	for name := range rules {
-		ruleNames = append(ruleNames, name)
+	for _, files := range rules {
+		for name := range files {
+			ruleNames = append(ruleNames, name)
+		}
 	}
 
 	level.Debug(c.logger).Log(
@@ -216,6 +395,237 @@ func (c *Operator) selectRules(p *monitoringv1.Prometheus, namespaces []string)
 	return rules, nil
 }
 
+// validateRules parses the expressions of the rules for the given
+// Prometheus version and feature flags. It returns one error per invalid rule
+// and one warning per rule which can't be checked. The syntax errors are
+// always reported as errors. The parser of the operator predates some
+// constructs: the expressions using the `@` modifier or negative offsets are
+// accepted if the Prometheus version supports them, and the expressions
+// calling functions unknown to the parser are reported as warnings if the
+// Prometheus version may support them.
+func validateRules(version semver.Version, features []string, spec monitoringv1.PrometheusRuleSpec) ([]string, []string) {
+	atModifier := version.GTE(atModifierVersion)
+	negativeOffset := version.GTE(negativeOffsetVersion)
+	for _, f := range features {
+		switch f {
+		case "promql-at-modifier":
+			atModifier = atModifier || version.GTE(atModifierFeatureVersion)
+		case "promql-negative-offset":
+			negativeOffset = negativeOffset || version.GTE(negativeOffsetFeatureVersion)
+		}
+	}
+
+	var errs, warnings []string
+	for _, g := range spec.Groups {
+		for i, r := range g.Rules {
+			expr := r.Expr.String()
+			_, err := parser.ParseExpr(expr)
+			if err == nil {
+				continue
+			}
+
+			name := fmt.Sprintf("%d", i)
+			switch {
+			case r.Alert != "":
+				name = fmt.Sprintf("%q", r.Alert)
+			case r.Record != "":
+				name = fmt.Sprintf("%q", r.Record)
+			}
+
+			switch {
+			case usesAtModifier(expr):
+				if atModifier {
+					continue
+				}
+				err = errors.Errorf("the @ modifier requires Prometheus %s or newer, or the promql-at-modifier feature flag with Prometheus %s or newer", atModifierVersion, atModifierFeatureVersion)
+			case usesNegativeOffset(expr):
+				if negativeOffset {
+					continue
+				}
+				err = errors.Errorf("negative offsets require Prometheus %s or newer, or the promql-negative-offset feature flag with Prometheus %s or newer", negativeOffsetVersion, negativeOffsetFeatureVersion)
+			default:
+				unknown, ferr := unknownFunctions(version, err)
+				if ferr != nil {
+					err = ferr
+					break
+				}
+				if len(unknown) > 0 {
+					warnings = append(warnings, fmt.Sprintf("group %q, rule %s: expression not validated, the parser of Prometheus %s doesn't know the functions %s", g.Name, name, parserVersion, strings.Join(unknown, ", ")))
+					continue
+				}
+			}
+
+			errs = append(errs, fmt.Sprintf("group %q, rule %s: %v", g.Name, name, err))
+		}
+	}
+
+	return errs, warnings
+}
+
+// unknownFunctions returns the functions unknown to the parser which the
+// Prometheus version may support, if they are the only reason why the parser
+// rejects the expression. It returns nothing if the parser rejects the
+// expression for another reason, and an error if one of the functions is
+// too recent for the Prometheus version.
+func unknownFunctions(version semver.Version, err error) ([]string, error) {
+	parseErrs, ok := err.(parser.ParseErrors)
+	if !ok {
+		return nil, nil
+	}
+
+	var unknown []string
+	for _, parseErr := range parseErrs {
+		msg := parseErr.Err.Error()
+		if !strings.HasPrefix(msg, unknownFunctionPrefix) {
+			return nil, nil
+		}
+		fn, uerr := strconv.Unquote(strings.TrimPrefix(msg, unknownFunctionPrefix))
+		if uerr != nil {
+			return nil, nil
+		}
+
+		since, ok := newFunctions[fn]
+		switch {
+		case ok && version.LT(since):
+			return nil, errors.Errorf("function %q requires Prometheus %s or newer", fn, since)
+		case !ok && version.LTE(parserVersion):
+			return nil, nil
+		}
+		unknown = append(unknown, strconv.Quote(fn))
+	}
+
+	return unknown, nil
+}
+
+// usesAtModifier returns whether the PromQL lexer stops at an `@`
+// character outside of the label matchers, which starts the `@` modifier.
+func usesAtModifier(expr string) bool {
+	l := parser.Lex(expr)
+	var item parser.Item
+	for {
+		l.NextItem(&item)
+		switch item.Typ {
+		case parser.EOF:
+			return false
+		case parser.ERROR:
+			return item.Val == "unexpected character: '@'"
+		}
+	}
+}
+
+// usesNegativeOffset returns whether an offset modifier of the expression
+// is followed by a minus sign.
+func usesNegativeOffset(expr string) bool {
+	l := parser.Lex(expr)
+	var item, prev parser.Item
+	for {
+		l.NextItem(&item)
+		switch item.Typ {
+		case parser.EOF, parser.ERROR:
+			return false
+		case parser.SUB:
+			if prev.Typ == parser.OFFSET {
+				return true
+			}
+		}
+		prev = item
+	}
+}
+
+// updateRuleStatus reports the validation errors of the PrometheusRule for
+// the Prometheus instance in its status and records an Event when they
+// change. Failures are logged since the status isn't required to generate
+// the rule files.
+func (c *Operator) updateRuleStatus(ctx context.Context, rule *monitoringv1.PrometheusRule, p *monitoringv1.Prometheus, version semver.Version, ruleErrs []string) {
+	cond := monitoringv1.Condition{
+		Type:               monitoringv1.RulesValid,
+		Status:             monitoringv1.ConditionTrue,
+		ObservedGeneration: rule.Generation,
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(ruleErrs) > 0 {
+		cond.Status = monitoringv1.ConditionFalse
+		cond.Reason = invalidRulesReason
+		cond.Message = fmt.Sprintf("invalid rules for Prometheus %s/%s (version %s): %s", p.Namespace, p.Name, version, strings.Join(ruleErrs, "; "))
+	}
+
+	status := &monitoringv1.PrometheusRuleStatus{}
+	if rule.Status != nil {
+		status = rule.Status.DeepCopy()
+	}
+
+	i := ruleBindingIndex(status, p.Namespace, p.Name)
+	if i < 0 {
+		status.Prometheuses = append(status.Prometheuses, monitoringv1.PrometheusRuleBinding{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+		})
+		i = len(status.Prometheuses) - 1
+	}
+	for _, cur := range status.Prometheuses[i].Conditions {
+		if cur.Type == cond.Type && cur.Status == cond.Status {
+			cond.LastTransitionTime = cur.LastTransitionTime
+		}
+	}
+	status.Prometheuses[i].Conditions = []monitoringv1.Condition{cond}
+
+	if reflect.DeepEqual(rule.Status, status) {
+		return
+	}
+
+	rule = rule.DeepCopy()
+	rule.Status = status
+	if _, err := c.mclient.MonitoringV1().PrometheusRules(rule.Namespace).UpdateStatus(ctx, rule, metav1.UpdateOptions{}); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to update prometheusrule status", "err", err, "prometheusrule", rule.Namespace+"/"+rule.Name)
+	}
+
+	if len(ruleErrs) > 0 && c.eventRecorder != nil {
+		c.eventRecorder.Event(rule, v1.EventTypeWarning, invalidRulesReason, cond.Message)
+	}
+}
+
+// pruneRuleStatus removes the state of the Prometheus instance from the
+// status of the PrometheusRule objects which aren't in keep, of all of them
+// when keep is nil.
+func (c *Operator) pruneRuleStatus(ctx context.Context, namespace, name string, keep map[string]struct{}) {
+	objs, err := c.ruleInfs.List(labels.Everything())
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to list prometheusrules", "err", err)
+		return
+	}
+
+	for _, obj := range objs {
+		rule := obj.(*monitoringv1.PrometheusRule)
+		if _, ok := keep[rule.Namespace+"/"+rule.Name]; ok {
+			continue
+		}
+		i := ruleBindingIndex(rule.Status, namespace, name)
+		if i < 0 {
+			continue
+		}
+
+		rule = rule.DeepCopy()
+		rule.Status.Prometheuses = append(rule.Status.Prometheuses[:i], rule.Status.Prometheuses[i+1:]...)
+		if _, err := c.mclient.MonitoringV1().PrometheusRules(rule.Namespace).UpdateStatus(ctx, rule, metav1.UpdateOptions{}); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to update prometheusrule status", "err", err, "prometheusrule", rule.Namespace+"/"+rule.Name)
+		}
+	}
+}
+
+// ruleBindingIndex returns the index of the state of the Prometheus instance
+// in the status of a PrometheusRule, -1 if there is none.
+func ruleBindingIndex(status *monitoringv1.PrometheusRuleStatus, namespace, name string) int {
+	if status == nil {
+		return -1
+	}
+	for i, b := range status.Prometheuses {
+		if b.Namespace == namespace && b.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func generateContent(promRule monitoringv1.PrometheusRuleSpec) (string, error) {
 
 	content, err := yaml.Marshal(promRule)
@@ -225,15 +635,123 @@ func generateContent(promRule monitoringv1.PrometheusRuleSpec) (string, error) {
 	return string(content), nil
 }
 
-// makeRulesConfigMaps takes a Prometheus configuration and rule files and
-// returns a list of Kubernetes ConfigMaps to be later on mounted into the
-// Prometheus instance.
-// If the total size of rule files exceeds the Kubernetes ConfigMap limit,
-// they are split up via the simple first-fit [1] bin packing algorithm. In the
-// future this can be replaced by a more sophisticated algorithm, but for now
-// simplicity should be sufficient.
+func ruleFileName(namespace, name string) string {
+	return fmt.Sprintf("%v-%v.yaml", namespace, name)
+}
+
+// makeRulesConfigMaps takes a Prometheus configuration and rule files indexed
+// by namespace and returns a list of Kubernetes ConfigMaps to be later on
+// mounted into the Prometheus instance.
+// Each ConfigMap holds the rule files of a single namespace, so that the
+// rules contributed by a namespace can be audited and changes only touch the
+// ConfigMaps of the namespace. assigned maps the names of the current
+// ConfigMaps to their namespace, which keeps them.
+// The ConfigMaps are pre-allocated: their number is a power of two, at least
+// minRuleConfigMapSlots, and the unused ones are empty. Thereby adding or
+// removing rules doesn't change the StatefulSet definition and restart
+// Prometheus, unless the number of ConfigMaps crosses a power of two. The
+// ConfigMaps are named by index rather than by namespace for this reason, the
+// namespace is given by the rule-namespace label. A namespace keeps its
+// ConfigMaps when the number grows or shrinks, so the changes of a namespace
+// never touch the ConfigMaps of the others.
+// If the total size of the rule files of a namespace exceeds the Kubernetes
+// ConfigMap limit, they are split up via the simple first-fit [1] bin packing
+// algorithm.
 // [1] https://en.wikipedia.org/wiki/Bin_packing_problem#First-fit_algorithm
-func makeRulesConfigMaps(p *monitoringv1.Prometheus, ruleFiles map[string]string) ([]v1.ConfigMap, error) {
+func makeRulesConfigMaps(p *monitoringv1.Prometheus, ruleFiles map[string]map[string]string, assigned map[string]string) ([]v1.ConfigMap, error) {
+	namespaces := make([]string, 0, len(ruleFiles))
+	for ns := range ruleFiles {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	buckets := map[string][]map[string]string{}
+	total := 0
+	for _, ns := range namespaces {
+		b, err := packRuleFiles(ruleFiles[ns])
+		if err != nil {
+			return nil, err
+		}
+		buckets[ns] = b
+		total += len(b)
+	}
+
+	// The number of ConfigMaps doesn't shrink below the ConfigMaps kept by
+	// the namespaces, which would move their rule files.
+	kept := keptRuleConfigMaps(p.Name, buckets, assigned)
+	n := total
+	if len(kept) > 0 && kept[len(kept)-1]+1 > n {
+		n = kept[len(kept)-1] + 1
+	}
+
+	names := ruleConfigMapNames(p.Name, n)
+	slots := make([]*ruleConfigMapSlot, len(names))
+	placed := map[string]int{}
+
+	// The namespaces keep their current ConfigMaps...
+	for _, i := range kept {
+		ns := assigned[names[i]]
+		slots[i] = &ruleConfigMapSlot{namespace: ns, ruleFiles: buckets[ns][placed[ns]]}
+		placed[ns]++
+	}
+
+	// ...and take the first free ones for their remaining rule files.
+	free := 0
+	for _, ns := range namespaces {
+		for ; placed[ns] < len(buckets[ns]); placed[ns]++ {
+			for slots[free] != nil {
+				free++
+			}
+			slots[free] = &ruleConfigMapSlot{namespace: ns, ruleFiles: buckets[ns][placed[ns]]}
+		}
+	}
+
+	ruleFileConfigMaps := make([]v1.ConfigMap, 0, len(names))
+	for i, name := range names {
+		cm, err := makeRulesConfigMap(p, name, slots[i])
+		if err != nil {
+			return nil, err
+		}
+		ruleFileConfigMaps = append(ruleFileConfigMaps, cm)
+	}
+
+	return ruleFileConfigMaps, nil
+}
+
+// keptRuleConfigMaps returns the sorted indexes of the assigned ConfigMaps
+// which their namespace keeps, at most one per bucket of the namespace.
+func keptRuleConfigMaps(prometheusName string, buckets map[string][]map[string]string, assigned map[string]string) []int {
+	var indexes []int
+	for name := range assigned {
+		i, ok := ruleConfigMapIndex(prometheusName, name)
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+
+	kept := []int{}
+	count := map[string]int{}
+	for _, i := range indexes {
+		ns := assigned[RuleConfigMapName(prometheusName, i)]
+		if count[ns] >= len(buckets[ns]) {
+			continue
+		}
+		kept = append(kept, i)
+		count[ns]++
+	}
+	return kept
+}
+
+// ruleConfigMapSlot holds rule files of a namespace.
+type ruleConfigMapSlot struct {
+	namespace string
+	ruleFiles map[string]string
+}
+
+// packRuleFiles splits the rule files into buckets fitting in a ConfigMap. It
+// returns at least one bucket.
+func packRuleFiles(ruleFiles map[string]string) ([]map[string]string, error) {
 	//check if none of the rule files is too large for a single ConfigMap
 	for filename, file := range ruleFiles {
 		if len(file) > maxConfigMapDataSize {
@@ -266,14 +784,7 @@ func makeRulesConfigMaps(p *monitoringv1.Prometheus, ruleFiles map[string]string
 		buckets[currBucketIndex][filename] = ruleFiles[filename]
 	}
 
-	ruleFileConfigMaps := []v1.ConfigMap{}
-	for i, bucket := range buckets {
-		cm := makeRulesConfigMap(p, bucket)
-		cm.Name = cm.Name + "-" + strconv.Itoa(i)
-		ruleFileConfigMaps = append(ruleFileConfigMaps, cm)
-	}
-
-	return ruleFileConfigMaps, nil
+	return buckets, nil
 }
 
 func bucketSize(bucket map[string]string) int {
@@ -285,18 +796,42 @@ func bucketSize(bucket map[string]string) int {
 	return totalSize
 }
 
-func makeRulesConfigMap(p *monitoringv1.Prometheus, ruleFiles map[string]string) v1.ConfigMap {
+// makeRulesConfigMap returns the ConfigMap holding the rule files of the
+// slot, an empty ConfigMap if the slot is nil.
+func makeRulesConfigMap(p *monitoringv1.Prometheus, name string, slot *ruleConfigMapSlot) (v1.ConfigMap, error) {
 	boolTrue := true
 
-	labels := map[string]string{labelPrometheusName: p.Name}
+	labels := map[string]string{
+		labelPrometheusName: p.Name,
+	}
 	for k, v := range managedByOperatorLabels {
 		labels[k] = v
 	}
 
+	// The index maps the rule files to the PrometheusRule objects they are
+	// generated from.
+	var ruleFiles map[string]string
+	ruleFilesIndex := map[string]string{}
+	if slot != nil {
+		labels[labelRuleNamespace] = slot.namespace
+		ruleFiles = slot.ruleFiles
+		for filename := range ruleFiles {
+			name := strings.TrimSuffix(strings.TrimPrefix(filename, slot.namespace+"-"), ".yaml")
+			ruleFilesIndex[filename] = slot.namespace + "/" + name
+		}
+	}
+	indexJSON, err := json.Marshal(ruleFilesIndex)
+	if err != nil {
+		return v1.ConfigMap{}, errors.Wrap(err, "failed to marshal rule files index")
+	}
+
 	return v1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   prometheusRuleConfigMapName(p.Name),
+			Name:   name,
 			Labels: labels,
+			Annotations: map[string]string{
+				ruleFilesIndexAnnotation: string(indexJSON),
+			},
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         p.APIVersion,
@@ -309,9 +844,64 @@ func makeRulesConfigMap(p *monitoringv1.Prometheus, ruleFiles map[string]string)
 			},
 		},
 		Data: ruleFiles,
+	}, nil
+}
+
+// ruleConfigMapNames returns the names of the pre-allocated ConfigMaps
+// holding n ConfigMaps worth of rule files for the Prometheus instance.
+func ruleConfigMapNames(prometheusName string, n int) []string {
+	slots := minRuleConfigMapSlots
+	for slots < n {
+		slots *= 2
+	}
+
+	names := make([]string, 0, slots)
+	for i := 0; i < slots; i++ {
+		names = append(names, RuleConfigMapName(prometheusName, i))
+	}
+	return names
+}
+
+// rulesVolume returns the volume projecting the rule ConfigMaps in a single
+// directory. The rule file names are unique across ConfigMaps since they are
+// prefixed with the namespace of the PrometheusRule.
+func rulesVolume(ruleConfigMapNames []string) v1.Volume {
+	sources := make([]v1.VolumeProjection, 0, len(ruleConfigMapNames))
+	for _, name := range ruleConfigMapNames {
+		sources = append(sources, v1.VolumeProjection{
+			ConfigMap: &v1.ConfigMapProjection{
+				LocalObjectReference: v1.LocalObjectReference{Name: name},
+			},
+		})
+	}
+
+	return v1.Volume{
+		Name: rulesVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{Sources: sources},
+		},
+	}
+}
+
+// ruleConfigMapIndex returns the index of the pre-allocated ConfigMap with
+// the given name, false if the name isn't one of a pre-allocated ConfigMap.
+func ruleConfigMapIndex(prometheusName, name string) (int, bool) {
+	prefix := strings.TrimSuffix(RuleConfigMapName(prometheusName, 0), "0")
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil || i < 0 || RuleConfigMapName(prometheusName, i) != name {
+		return 0, false
 	}
+	return i, true
 }
 
-func prometheusRuleConfigMapName(prometheusName string) string {
-	return "prometheus-" + prometheusName + "-rulefiles"
+// RuleConfigMapName returns the name of the index-th pre-allocated ConfigMap
+// holding rule files for the Prometheus instance. The names differ from the
+// prometheus-<name>-rulefiles-<index> ConfigMaps of previous versions, which
+// the Pods keep mounting until the StatefulSet is rolled out.
+func RuleConfigMapName(prometheusName string, index int) string {
+	return fmt.Sprintf("prometheus-%s-rules-%d", prometheusName, index)
 }
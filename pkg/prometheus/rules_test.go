@@ -15,45 +15,66 @@
 package prometheus
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/blang/semver"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	monitoringfake "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned/fake"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 )
 
 func TestMakeRulesConfigMaps(t *testing.T) {
-	t.Run("ShouldReturnAtLeastOneConfigMap", shouldReturnAtLeastOneConfigMap)
+	t.Run("ShouldReturnPreAllocatedConfigMaps", shouldReturnPreAllocatedConfigMaps)
 	t.Run("ShouldErrorOnTooLargeRuleFile", shouldErrorOnTooLargeRuleFile)
 	t.Run("ShouldSplitUpLargeSmallIntoTwo", shouldSplitUpLargeSmallIntoTwo)
+	t.Run("ShouldPartitionByNamespace", shouldPartitionByNamespace)
+	t.Run("ShouldKeepAssignedConfigMaps", shouldKeepAssignedConfigMaps)
+	t.Run("ShouldGrowByPowersOfTwo", shouldGrowByPowersOfTwo)
+	t.Run("ShouldKeepOtherNamespacesUnchanged", shouldKeepOtherNamespacesUnchanged)
 }
 
-// makeRulesConfigMaps should return at least one ConfigMap even if it is empty
-// when there are no rules. Otherwise adding a rule to a Prometheus without rules
-// would change the statefulset definition and thereby force Prometheus to
-// restart.
-func shouldReturnAtLeastOneConfigMap(t *testing.T) {
+// makeRulesConfigMaps should return the pre-allocated ConfigMaps even if they
+// are empty when there are no rules. Otherwise adding a rule to a Prometheus
+// without rules would change the statefulset definition and thereby force
+// Prometheus to restart.
+func shouldReturnPreAllocatedConfigMaps(t *testing.T) {
 	p := &monitoringv1.Prometheus{}
-	ruleFiles := map[string]string{}
+	ruleFiles := map[string]map[string]string{}
 
-	configMaps, err := makeRulesConfigMaps(p, ruleFiles)
+	configMaps, err := makeRulesConfigMaps(p, ruleFiles, nil)
 	if err != nil {
 		t.Fatalf("expected no error but got: %v", err.Error())
 	}
 
-	if len(configMaps) != 1 {
-		t.Fatalf("expected one ConfigMaps but got %v", len(configMaps))
+	if len(configMaps) != minRuleConfigMapSlots {
+		t.Fatalf("expected %d ConfigMaps but got %v", minRuleConfigMapSlots, len(configMaps))
+	}
+	for _, cm := range configMaps {
+		if len(cm.Data) != 0 {
+			t.Fatalf("expected ConfigMap %q to be empty", cm.Name)
+		}
+		if _, ok := cm.Labels[labelRuleNamespace]; ok {
+			t.Fatalf("expected ConfigMap %q to have no namespace label", cm.Name)
+		}
 	}
 }
 
 func shouldErrorOnTooLargeRuleFile(t *testing.T) {
 	expectedError := "rule file 'my-rule-file' is too large for a single Kubernetes ConfigMap"
 	p := &monitoringv1.Prometheus{}
-	ruleFiles := map[string]string{}
+	ruleFiles := map[string]map[string]string{"": {}}
 
-	ruleFiles["my-rule-file"] = strings.Repeat("a", v1.MaxSecretSize+1)
+	ruleFiles[""]["my-rule-file"] = strings.Repeat("a", v1.MaxSecretSize+1)
 
-	_, err := makeRulesConfigMaps(p, ruleFiles)
+	_, err := makeRulesConfigMaps(p, ruleFiles, nil)
 	if err == nil || err.Error() != expectedError {
 		t.Fatalf("expected makeRulesConfigMaps to return error '%v' but got '%v'", expectedError, err)
 	}
@@ -61,21 +82,428 @@ func shouldErrorOnTooLargeRuleFile(t *testing.T) {
 
 func shouldSplitUpLargeSmallIntoTwo(t *testing.T) {
 	p := &monitoringv1.Prometheus{}
-	ruleFiles := map[string]string{}
+	ruleFiles := map[string]map[string]string{"": {}}
 
-	ruleFiles["first"] = strings.Repeat("a", maxConfigMapDataSize)
-	ruleFiles["second"] = "a"
+	ruleFiles[""]["first"] = strings.Repeat("a", maxConfigMapDataSize)
+	ruleFiles[""]["second"] = "a"
 
-	configMaps, err := makeRulesConfigMaps(p, ruleFiles)
+	configMaps, err := makeRulesConfigMaps(p, ruleFiles, nil)
 	if err != nil {
 		t.Fatalf("expected no error but got: %v", err)
 	}
 
-	if len(configMaps) != 2 {
-		t.Fatalf("expected rule files to be split up into two ConfigMaps, but got '%v' instead", len(configMaps))
+	if len(configMaps[2].Data) != 0 {
+		t.Fatalf("expected rule files to be split up into two ConfigMaps, but got '%v' instead", configMaps)
 	}
 
-	if configMaps[0].Data["first"] != ruleFiles["first"] || configMaps[1].Data["second"] != ruleFiles["second"] {
+	if configMaps[0].Data["first"] != ruleFiles[""]["first"] || configMaps[1].Data["second"] != ruleFiles[""]["second"] {
 		t.Fatal("expected ConfigMap data to match rule file content")
 	}
 }
+
+func shouldPartitionByNamespace(t *testing.T) {
+	p := &monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "monitoring",
+		},
+	}
+	ruleFiles := map[string]map[string]string{
+		"team-b": {"team-b-rules.yaml": "b"},
+		"team-a": {
+			"team-a-alerts.yaml":    "a",
+			"team-a-recording.yaml": "a",
+		},
+	}
+
+	configMaps, err := makeRulesConfigMaps(p, ruleFiles, nil)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	// The namespaces take the first ConfigMaps in order, the other
+	// ConfigMaps are empty.
+	expected := []struct {
+		name      string
+		namespace string
+		index     string
+	}{
+		{"prometheus-test-rules-0", "team-a", `{"team-a-alerts.yaml":"team-a/alerts","team-a-recording.yaml":"team-a/recording"}`},
+		{"prometheus-test-rules-1", "team-b", `{"team-b-rules.yaml":"team-b/rules"}`},
+		{"prometheus-test-rules-2", "", `{}`},
+		{"prometheus-test-rules-3", "", `{}`},
+	}
+	if len(configMaps) != len(expected) {
+		t.Fatalf("expected %d ConfigMaps but got %d", len(expected), len(configMaps))
+	}
+	for i, e := range expected {
+		cm := configMaps[i]
+		if cm.Name != e.name {
+			t.Fatalf("expected ConfigMap name %q but got %q", e.name, cm.Name)
+		}
+		if cm.Labels[labelRuleNamespace] != e.namespace {
+			t.Fatalf("expected ConfigMap %q to be labeled with namespace %q but got %q", cm.Name, e.namespace, cm.Labels[labelRuleNamespace])
+		}
+		if cm.Annotations[ruleFilesIndexAnnotation] != e.index {
+			t.Fatalf("expected ConfigMap %q to have index %s but got %s", cm.Name, e.index, cm.Annotations[ruleFilesIndexAnnotation])
+		}
+	}
+}
+
+// Adding a namespace shouldn't move the rule files of the other namespaces
+// to other ConfigMaps.
+func shouldKeepAssignedConfigMaps(t *testing.T) {
+	p := &monitoringv1.Prometheus{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	ruleFiles := map[string]map[string]string{
+		"team-a": {"team-a-rules.yaml": "a"},
+		"team-b": {"team-b-rules.yaml": "b"},
+		"team-c": {"team-c-rules.yaml": "c"},
+	}
+	assigned := map[string]string{
+		"prometheus-test-rules-0": "team-c",
+		"prometheus-test-rules-2": "team-a",
+		// The ConfigMaps of previous versions aren't reused.
+		"prometheus-test-rulefiles-0": "team-b",
+	}
+
+	configMaps, err := makeRulesConfigMaps(p, ruleFiles, assigned)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+
+	for i, ns := range []string{"team-c", "team-b", "team-a", ""} {
+		if got := configMaps[i].Labels[labelRuleNamespace]; got != ns {
+			t.Fatalf("expected ConfigMap %q to be labeled with namespace %q but got %q", configMaps[i].Name, ns, got)
+		}
+	}
+}
+
+func shouldGrowByPowersOfTwo(t *testing.T) {
+	p := &monitoringv1.Prometheus{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+
+	for n, expected := range map[int]int{0: 4, 4: 4, 5: 8, 8: 8, 9: 16} {
+		ruleFiles := map[string]map[string]string{}
+		for i := 0; i < n; i++ {
+			ns := fmt.Sprintf("ns-%d", i)
+			ruleFiles[ns] = map[string]string{ns + "-rules.yaml": "a"}
+		}
+
+		configMaps, err := makeRulesConfigMaps(p, ruleFiles, nil)
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if len(configMaps) != expected {
+			t.Fatalf("expected %d ConfigMaps for %d namespaces but got %d", expected, n, len(configMaps))
+		}
+	}
+}
+
+// Changing the rules of a namespace shouldn't change the ConfigMaps of the
+// other namespaces, even when the number of ConfigMaps grows or shrinks.
+func shouldKeepOtherNamespacesUnchanged(t *testing.T) {
+	p := &monitoringv1.Prometheus{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	ruleFiles := map[string]map[string]string{
+		"team-a": {"team-a-rules.yaml": "a"},
+		"team-b": {"team-b-rules.yaml": "b"},
+		"team-c": {"team-c-rules.yaml": "c"},
+	}
+
+	var current []v1.ConfigMap
+	sync := func(expected int, unchanged ...string) {
+		t.Helper()
+
+		assigned := map[string]string{}
+		previous := map[string]v1.ConfigMap{}
+		for _, cm := range current {
+			if ns, ok := cm.Labels[labelRuleNamespace]; ok {
+				assigned[cm.Name] = ns
+				previous[ns] = cm
+			}
+		}
+
+		configMaps, err := makeRulesConfigMaps(p, ruleFiles, assigned)
+		if err != nil {
+			t.Fatalf("expected no error but got: %v", err)
+		}
+		if len(configMaps) != expected {
+			t.Fatalf("expected %d ConfigMaps but got %d", expected, len(configMaps))
+		}
+
+		for _, ns := range unchanged {
+			found := false
+			for _, cm := range configMaps {
+				if cm.Labels[labelRuleNamespace] != ns {
+					continue
+				}
+				found = true
+				if !reflect.DeepEqual(cm, previous[ns]) {
+					t.Fatalf("expected the ConfigMap of namespace %q to be unchanged, got %v instead of %v", ns, cm, previous[ns])
+				}
+			}
+			if !found {
+				t.Fatalf("expected a ConfigMap for namespace %q", ns)
+			}
+		}
+		current = configMaps
+	}
+
+	sync(4)
+
+	// team-b needs a second ConfigMap and new namespaces are added.
+	ruleFiles["team-b"]["team-b-large.yaml"] = strings.Repeat("b", maxConfigMapDataSize)
+	for _, ns := range []string{"team-d", "team-e", "team-f"} {
+		ruleFiles[ns] = map[string]string{ns + "-rules.yaml": ns}
+	}
+	sync(8, "team-a", "team-c")
+
+	// Removing rules doesn't move team-f to a lower ConfigMap.
+	delete(ruleFiles["team-b"], "team-b-large.yaml")
+	delete(ruleFiles, "team-d")
+	delete(ruleFiles, "team-e")
+	sync(8, "team-a", "team-c", "team-f")
+
+	// The number of ConfigMaps shrinks once the last ones are unused.
+	delete(ruleFiles, "team-f")
+	sync(4, "team-a", "team-b", "team-c")
+}
+
+func TestConfigMapInUse(t *testing.T) {
+	sset := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Generation: 2},
+		Spec: appsv1.StatefulSetSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Volumes: []v1.Volume{rulesVolume([]string{"prometheus-test-rules-0"})},
+				},
+			},
+		},
+		Status: appsv1.StatefulSetStatus{
+			ObservedGeneration: 2,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			CurrentRevision:    "rev-2",
+			UpdateRevision:     "rev-2",
+		},
+	}
+
+	if configMapInUse(nil, "prometheus-test-rulefiles-0") {
+		t.Fatal("expected no ConfigMap to be in use without StatefulSet")
+	}
+	if !configMapInUse(sset, "prometheus-test-rules-0") {
+		t.Fatal("expected the ConfigMap of the Pod template to be in use")
+	}
+	if configMapInUse(sset, "prometheus-test-rulefiles-0") {
+		t.Fatal("expected the ConfigMap of the previous revision not to be in use")
+	}
+
+	// The Pods of the previous revision are still running.
+	sset.Status.UpdatedReplicas = 1
+	sset.Status.CurrentRevision = "rev-1"
+	if !configMapInUse(sset, "prometheus-test-rulefiles-0") {
+		t.Fatal("expected the ConfigMap of the previous revision to be in use during the rollout")
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		features []string
+		expr     string
+		errs     int
+		warnings int
+	}{
+		{
+			name:    "valid expression",
+			version: "2.21.0",
+			expr:    "rate(http_requests_total[5m]) > 0",
+		},
+		{
+			name:    "invalid expression",
+			version: "2.21.0",
+			expr:    "rate(http_requests_total[5m]",
+			errs:    1,
+		},
+		{
+			name:    "invalid expression with newer version",
+			version: "2.40.0",
+			expr:    "rate(http_requests_total[5m]",
+			errs:    1,
+		},
+		{
+			name:     "function unknown to the parser",
+			version:  "2.26.0",
+			expr:     "last_over_time(http_requests_total[5m])",
+			warnings: 1,
+		},
+		{
+			name:    "function too recent for the version",
+			version: "2.25.0",
+			expr:    "last_over_time(http_requests_total[5m])",
+			errs:    1,
+		},
+		{
+			name:     "function unknown to the operator with newer version",
+			version:  "2.52.0",
+			expr:     "mad_over_time(http_requests_total[5m])",
+			warnings: 1,
+		},
+		{
+			name:    "unknown function",
+			version: "2.21.0",
+			expr:    "mad_over_time(http_requests_total[5m])",
+			errs:    1,
+		},
+		{
+			name:    "function unknown to the parser with invalid expression",
+			version: "2.40.0",
+			expr:    "last_over_time(http_requests_total[5m]",
+			errs:    1,
+		},
+		{
+			name:    "negative offset with old version",
+			version: "2.25.0",
+			expr:    "http_requests_total offset -5m",
+			errs:    1,
+		},
+		{
+			name:     "negative offset with feature flag",
+			version:  "2.26.0",
+			features: []string{"promql-negative-offset"},
+			expr:     "http_requests_total offset -5m",
+		},
+		{
+			name:    "negative offset with recent version",
+			version: "2.33.0",
+			expr:    "http_requests_total offset -5m",
+		},
+		{
+			name:    "@ modifier with old version",
+			version: "2.21.0",
+			expr:    "http_requests_total @ 1609746000",
+			errs:    1,
+		},
+		{
+			name:    "@ modifier without feature flag",
+			version: "2.25.0",
+			expr:    "http_requests_total @ 1609746000",
+			errs:    1,
+		},
+		{
+			name:     "@ modifier with feature flag",
+			version:  "2.25.0",
+			features: []string{"promql-at-modifier"},
+			expr:     "http_requests_total @ 1609746000",
+		},
+		{
+			name:    "@ modifier with recent version",
+			version: "2.33.0",
+			expr:    "http_requests_total @ 1609746000",
+		},
+		{
+			name:    "@ in label matcher",
+			version: "2.21.0",
+			expr:    `http_requests_total{user="foo@example.com"}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := monitoringv1.PrometheusRuleSpec{
+				Groups: []monitoringv1.RuleGroup{{
+					Name: "group",
+					Rules: []monitoringv1.Rule{
+						{Record: "valid", Expr: intstr.FromString("up")},
+						{Alert: "Test", Expr: intstr.FromString(tc.expr)},
+					},
+				}},
+			}
+
+			errs, warnings := validateRules(semver.MustParse(tc.version), tc.features, spec)
+			if len(errs) != tc.errs {
+				t.Fatalf("expected %d errors, got %v", tc.errs, errs)
+			}
+			if len(warnings) != tc.warnings {
+				t.Fatalf("expected %d warnings, got %v", tc.warnings, warnings)
+			}
+			for _, err := range append(errs, warnings...) {
+				if !strings.HasPrefix(err, `group "group", rule "Test": `) {
+					t.Fatalf("unexpected error %q", err)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateRuleStatus(t *testing.T) {
+	rule := &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "default",
+			Generation: 1,
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	c := &Operator{
+		mclient:       monitoringfake.NewSimpleClientset(rule.DeepCopy()),
+		eventRecorder: recorder,
+	}
+
+	p1 := &monitoringv1.Prometheus{ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "default"}}
+	p2 := &monitoringv1.Prometheus{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default"}}
+	get := func() *monitoringv1.PrometheusRule {
+		got, err := c.mclient.MonitoringV1().PrometheusRules("default").Get(context.Background(), "test", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	condition := func(rule *monitoringv1.PrometheusRule, p *monitoringv1.Prometheus) monitoringv1.Condition {
+		i := ruleBindingIndex(rule.Status, p.Namespace, p.Name)
+		if i < 0 || len(rule.Status.Prometheuses[i].Conditions) != 1 {
+			t.Fatalf("expected 1 condition for %s, got %v", p.Name, rule.Status)
+		}
+		return rule.Status.Prometheuses[i].Conditions[0]
+	}
+
+	c.updateRuleStatus(context.Background(), rule, p1, semver.MustParse("2.21.0"), []string{"boom"})
+	got := get()
+	cond := condition(got, p1)
+	if cond.Type != monitoringv1.RulesValid || cond.Status != monitoringv1.ConditionFalse || cond.Reason != invalidRulesReason {
+		t.Fatalf("unexpected condition %v", cond)
+	}
+	if !strings.Contains(cond.Message, "boom") || !strings.Contains(cond.Message, "default/old") {
+		t.Fatalf("expected message to contain the rule error and the instance, got %q", cond.Message)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(recorder.Events))
+	}
+
+	// Another instance accepting the rules doesn't change the state reported
+	// for the first one.
+	c.updateRuleStatus(context.Background(), got, p2, semver.MustParse("2.33.0"), nil)
+	got = get()
+	if cond := condition(got, p2); cond.Status != monitoringv1.ConditionTrue || cond.Message != "" {
+		t.Fatalf("unexpected condition %v", cond)
+	}
+	if cond := condition(got, p1); cond.Status != monitoringv1.ConditionFalse {
+		t.Fatalf("unexpected condition %v", cond)
+	}
+
+	// The same errors don't record another event.
+	c.updateRuleStatus(context.Background(), got, p1, semver.MustParse("2.21.0"), []string{"boom"})
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(recorder.Events))
+	}
+
+	c.updateRuleStatus(context.Background(), got, p1, semver.MustParse("2.21.0"), nil)
+	got = get()
+	if cond := condition(got, p1); cond.Status != monitoringv1.ConditionTrue || cond.Message != "" {
+		t.Fatalf("unexpected condition %v", cond)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(recorder.Events))
+	}
+	if len(got.Status.Prometheuses) != 2 {
+		t.Fatalf("expected 2 instances, got %v", got.Status.Prometheuses)
+	}
+}
@@ -31,6 +31,7 @@ import (
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
 )
 
 const (
@@ -42,12 +43,25 @@ const (
 	confOutDir                      = "/etc/prometheus/config_out"
 	tlsAssetsDir                    = "/etc/prometheus/certs"
 	rulesDir                        = "/etc/prometheus/rules"
+	rulesVolumeName                 = "rules"
 	secretsDir                      = "/etc/prometheus/secrets/"
 	configmapsDir                   = "/etc/prometheus/configmaps/"
 	configFilename                  = "prometheus.yaml.gz"
 	configEnvsubstFilename          = "prometheus.env.yaml"
+	configRawFilename               = "prometheus.yaml"
+	additionalScrapeConfigsDir      = "/etc/prometheus/additional-scrape-configs"
+	additionalScrapeConfigsFilename = "additional-scrape-configs.yaml"
+	scrapeConfigsDir                = "/etc/prometheus/scrape-configs"
+	scrapeConfigsFilename           = "scrape-configs.yaml"
+	boundTokenDir                   = "/etc/prometheus/bound-token"
+	boundTokenFilename              = "token"
+	webConfigDir                    = "/etc/prometheus/web_config"
 	sSetInputHashName               = "prometheus-operator-input-hash"
 	defaultPortName                 = "web"
+
+	// localProbe is the command of the probes when the web server listens
+	// on the loopback interface.
+	localProbe = `if [ -x "$(command -v curl)" ]; then curl %s; elif [ -x "$(command -v wget)" ]; then wget -q -O /dev/null %s; else exit 1; fi`
 )
 
 var (
@@ -59,6 +73,18 @@ var (
 		managedByOperatorLabel: managedByOperatorLabelValue,
 	}
 	probeTimeoutSeconds int32 = 3
+
+	// maxScrapeConfigsSecrets is the maximum number of Secrets holding the
+	// split scrape configurations. The Secrets are mounted as optional
+	// sources of a projected volume so that their number can change without
+	// modifying the StatefulSet.
+	maxScrapeConfigsSecrets = 32
+	// maxScrapeConfigsSecretDataSize leaves a buffer for the metadata which
+	// count into the maximum size of a Secret.
+	maxScrapeConfigsSecretDataSize = int(float64(v1.MaxSecretSize) * 0.75)
+	// defaultBoundTokenExpirationSeconds is the default validity duration
+	// of the bound ServiceAccount token.
+	defaultBoundTokenExpirationSeconds int64 = 3600
 )
 
 func makeStatefulSet(
@@ -156,42 +182,56 @@ func makeStatefulSet(
 	if p.Spec.ImagePullSecrets != nil && len(p.Spec.ImagePullSecrets) > 0 {
 		statefulset.Spec.Template.Spec.ImagePullSecrets = p.Spec.ImagePullSecrets
 	}
-	storageSpec := p.Spec.Storage
+	addStorageVolume(statefulset, p.Spec.Storage, volumeName(p.Name))
+
+	for _, volume := range p.Spec.Volumes {
+		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, volume)
+	}
+
+	statefulset, err = k8sutil.PatchStatefulSet(statefulset, p.Spec.StatefulSetPatch)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to apply statefulset patch")
+	}
+
+	return statefulset, nil
+}
+
+// addStorageVolume adds the volume or the volume claim template of the
+// storage to the StatefulSet. It defaults to an emptyDir volume.
+func addStorageVolume(statefulset *appsv1.StatefulSet, storageSpec *monitoringv1.StorageSpec, volName string) {
 	if storageSpec == nil {
 		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, v1.Volume{
-			Name: volumeName(p.Name),
+			Name: volName,
 			VolumeSource: v1.VolumeSource{
 				EmptyDir: &v1.EmptyDirVolumeSource{},
 			},
 		})
-	} else if storageSpec.EmptyDir != nil {
+		return
+	}
+
+	if storageSpec.EmptyDir != nil {
 		emptyDir := storageSpec.EmptyDir
 		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, v1.Volume{
-			Name: volumeName(p.Name),
+			Name: volName,
 			VolumeSource: v1.VolumeSource{
 				EmptyDir: emptyDir,
 			},
 		})
-	} else {
-		pvcTemplate := operator.MakeVolumeClaimTemplate(storageSpec.VolumeClaimTemplate)
-		if pvcTemplate.Name == "" {
-			pvcTemplate.Name = volumeName(p.Name)
-		}
-		if storageSpec.VolumeClaimTemplate.Spec.AccessModes == nil {
-			pvcTemplate.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
-		} else {
-			pvcTemplate.Spec.AccessModes = storageSpec.VolumeClaimTemplate.Spec.AccessModes
-		}
-		pvcTemplate.Spec.Resources = storageSpec.VolumeClaimTemplate.Spec.Resources
-		pvcTemplate.Spec.Selector = storageSpec.VolumeClaimTemplate.Spec.Selector
-		statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, *pvcTemplate)
+		return
 	}
 
-	for _, volume := range p.Spec.Volumes {
-		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, volume)
+	pvcTemplate := operator.MakeVolumeClaimTemplate(storageSpec.VolumeClaimTemplate)
+	if pvcTemplate.Name == "" {
+		pvcTemplate.Name = volName
 	}
-
-	return statefulset, nil
+	if storageSpec.VolumeClaimTemplate.Spec.AccessModes == nil {
+		pvcTemplate.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	} else {
+		pvcTemplate.Spec.AccessModes = storageSpec.VolumeClaimTemplate.Spec.AccessModes
+	}
+	pvcTemplate.Spec.Resources = storageSpec.VolumeClaimTemplate.Spec.Resources
+	pvcTemplate.Spec.Selector = storageSpec.VolumeClaimTemplate.Spec.Selector
+	statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, *pvcTemplate)
 }
 
 func makeEmptyConfigurationSecret(p *monitoringv1.Prometheus, config Config) (*v1.Secret, error) {
@@ -209,7 +249,38 @@ func makeConfigSecret(p *monitoringv1.Prometheus, config Config) *v1.Secret {
 	return &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   configSecretName(p.Name),
-			Labels: config.Labels.Merge(managedByOperatorLabels),
+			Labels: config.Labels.Merge(managedLabels(p)),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         p.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               p.Kind,
+					Name:               p.Name,
+					UID:                p.UID,
+				},
+			},
+		},
+		Data: configSecretData(p),
+	}
+}
+
+// configSecretData returns the empty data of the configuration Secret.
+// Without the config reloader, the Secret holds the uncompressed
+// configuration read by Prometheus instead of the compressed one.
+func configSecretData(p *monitoringv1.Prometheus) map[string][]byte {
+	if httpReload(p) {
+		return map[string][]byte{configRawFilename: {}}
+	}
+	return map[string][]byte{configFilename: {}}
+}
+
+func makeTLSAssetSecret(p *monitoringv1.Prometheus, config Config) *v1.Secret {
+	boolTrue := true
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   tlsAssetsSecretName(p.Name),
+			Labels: config.Labels.Merge(managedLabels(p)),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         p.APIVersion,
@@ -221,8 +292,44 @@ func makeConfigSecret(p *monitoringv1.Prometheus, config Config) *v1.Secret {
 				},
 			},
 		},
-		Data: map[string][]byte{
-			configFilename: {},
+		Data: map[string][]byte{},
+	}
+}
+
+// managedLabels returns the labels of the objects generated for the
+// Prometheus object. The name label lets the garbage collection find the
+// owner of the objects.
+func managedLabels(p *monitoringv1.Prometheus) map[string]string {
+	labels := map[string]string{labelPrometheusName: p.Name}
+	for k, v := range managedByOperatorLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// serviceAccountName returns the name of the ServiceAccount used to run the
+// Prometheus Pods.
+func serviceAccountName(p *monitoringv1.Prometheus) string {
+	if p.Spec.ManageServiceAccount && p.Spec.ServiceAccountName == "" {
+		return prefixedName(p.Name)
+	}
+	return p.Spec.ServiceAccountName
+}
+
+func makeServiceAccount(p *monitoringv1.Prometheus, config Config) *v1.ServiceAccount {
+	return &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceAccountName(p),
+			Labels:      config.Labels.Merge(p.Spec.ServiceAccountLabels),
+			Annotations: p.Spec.ServiceAccountAnnotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Name:       p.GetName(),
+					Kind:       p.Kind,
+					APIVersion: p.APIVersion,
+					UID:        p.GetUID(),
+				},
+			},
 		},
 	}
 }
@@ -337,8 +444,14 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 				)
 			}
 		}
+		// Without the config reloader, Prometheus reads the uncompressed
+		// configuration from the Secret.
+		configFile := path.Join(confOutDir, configEnvsubstFilename)
+		if httpReload(&p) {
+			configFile = path.Join(confDir, configRawFilename)
+		}
 		promArgs = append(promArgs,
-			fmt.Sprintf("-config.file=%s", path.Join(confOutDir, configEnvsubstFilename)),
+			fmt.Sprintf("-config.file=%s", configFile),
 			fmt.Sprintf("-storage.tsdb.path=%s", storageDir),
 			retentionTimeFlag+p.Spec.Retention,
 			"-web.enable-lifecycle",
@@ -425,6 +538,54 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 		promArgs = append(promArgs, "-storage.tsdb.allow-overlapping-blocks")
 	}
 
+	if version.GTE(semver.MustParse("2.5.0")) && p.Spec.RemoteReadLimits != nil {
+		limits := p.Spec.RemoteReadLimits
+		if limits.SampleLimit != nil {
+			promArgs = append(promArgs, fmt.Sprintf("-storage.remote.read-sample-limit=%d", *limits.SampleLimit))
+		}
+		if limits.ConcurrentLimit != nil {
+			promArgs = append(promArgs, fmt.Sprintf("-storage.remote.read-concurrent-limit=%d", *limits.ConcurrentLimit))
+		}
+		if limits.MaxBytesInFrame != nil && version.GTE(semver.MustParse("2.13.0")) {
+			promArgs = append(promArgs, fmt.Sprintf("-storage.remote.read-max-bytes-in-frame=%d", *limits.MaxBytesInFrame))
+		}
+	}
+
+	if err := validateEnableFeatures(p.Spec.EnableFeatures); err != nil {
+		return nil, err
+	}
+
+	var features []string
+	if version.GTE(semver.MustParse("2.25.0")) {
+		features = append(features, p.Spec.EnableFeatures...)
+	}
+
+	if version.GTE(semver.MustParse("2.47.0")) && p.Spec.OTLP != nil {
+		features = append(features, "otlp-write-receiver")
+	}
+
+	// The replica external label refers to the POD_NAME environment
+	// variable, substituted by the config reloader otherwise.
+	if httpReload(&p) {
+		features = append(features, "expand-external-labels")
+	}
+
+	seenFeatures := map[string]struct{}{}
+	for _, f := range features {
+		if _, found := seenFeatures[f]; found {
+			continue
+		}
+		seenFeatures[f] = struct{}{}
+		promArgs = append(promArgs, "-enable-feature="+f)
+	}
+
+	// The web settings are checked against the Prometheus version before
+	// generating the StatefulSet.
+	web := p.Spec.Web
+	if web != nil {
+		promArgs = append(promArgs, "-web.config.file="+path.Join(webConfigDir, webconfig.ConfigFile))
+	}
+
 	var ports []v1.ContainerPort
 	if p.Spec.ListenLocal {
 		promArgs = append(promArgs, "-web.listen-address=127.0.0.1:9090")
@@ -445,7 +606,7 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 	}
 
 	localReloadURL := &url.URL{
-		Scheme: "http",
+		Scheme: webconfig.Scheme(web),
 		Host:   c.LocalHost + ":9090",
 		Path:   path.Clean(webRoutePrefix + "/-/reload"),
 	}
@@ -467,25 +628,18 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 				},
 			},
 		},
-		{
+	}
+	if !httpReload(&p) {
+		volumes = append(volumes, v1.Volume{
 			Name: "config-out",
 			VolumeSource: v1.VolumeSource{
 				EmptyDir: &v1.EmptyDirVolumeSource{},
 			},
-		},
+		})
 	}
 
-	for _, name := range ruleConfigMapNames {
-		volumes = append(volumes, v1.Volume{
-			Name: name,
-			VolumeSource: v1.VolumeSource{
-				ConfigMap: &v1.ConfigMapVolumeSource{
-					LocalObjectReference: v1.LocalObjectReference{
-						Name: name,
-					},
-				},
-			},
-		})
+	if len(ruleConfigMapNames) != 0 {
+		volumes = append(volumes, rulesVolume(ruleConfigMapNames))
 	}
 
 	volName := volumeName(p.Name)
@@ -495,12 +649,21 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 		}
 	}
 
-	promVolumeMounts := []v1.VolumeMount{
-		{
-			Name:      "config-out",
+	configMount := v1.VolumeMount{
+		Name:      "config-out",
+		ReadOnly:  true,
+		MountPath: confOutDir,
+	}
+	if httpReload(&p) {
+		configMount = v1.VolumeMount{
+			Name:      "config",
 			ReadOnly:  true,
-			MountPath: confOutDir,
-		},
+			MountPath: confDir,
+		}
+	}
+
+	promVolumeMounts := []v1.VolumeMount{
+		configMount,
 		{
 			Name:      "tls-assets",
 			ReadOnly:  true,
@@ -513,11 +676,17 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 		},
 	}
 
+	if web != nil {
+		vol, mount := webconfig.Volume(webConfigSecretName(p.Name), webConfigDir)
+		volumes = append(volumes, vol)
+		promVolumeMounts = append(promVolumeMounts, mount)
+	}
+
 	promVolumeMounts = append(promVolumeMounts, p.Spec.VolumeMounts...)
-	for _, name := range ruleConfigMapNames {
+	if len(ruleConfigMapNames) != 0 {
 		promVolumeMounts = append(promVolumeMounts, v1.VolumeMount{
-			Name:      name,
-			MountPath: rulesDir + "/" + name,
+			Name:      rulesVolumeName,
+			MountPath: rulesDir,
 		})
 	}
 
@@ -564,6 +733,13 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 			Name:      "config-out",
 			MountPath: confOutDir,
 		},
+		// The TLS assets are watched so that Prometheus reloads its
+		// configuration when certificates are rotated (e.g. by cert-manager).
+		{
+			Name:      "tls-assets",
+			ReadOnly:  true,
+			MountPath: tlsAssetsDir,
+		},
 	}
 
 	configReloadArgs := []string{
@@ -571,9 +747,85 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 		fmt.Sprintf("--reload-url=%s", localReloadURL),
 		fmt.Sprintf("--config-file=%s", path.Join(confDir, configFilename)),
 		fmt.Sprintf("--config-envsubst-file=%s", path.Join(confOutDir, configEnvsubstFilename)),
+		fmt.Sprintf("--watched-dir=%s", tlsAssetsDir),
 	}
 
-	const localProbe = `if [ -x "$(command -v curl)" ]; then curl %s; elif [ -x "$(command -v wget)" ]; then wget -q -O /dev/null %s; else exit 1; fi`
+	// The additional scrape configurations are mounted from a dedicated
+	// Secret and watched by the reloader so that they can change without
+	// modifying the generated configuration.
+	if additionalScrapeConfigsAsFile(&p) {
+		volumes = append(volumes, v1.Volume{
+			Name: "additional-scrape-configs",
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: additionalScrapeConfigsSecretName(p.Name),
+				},
+			},
+		})
+		additionalScrapeConfigsMount := v1.VolumeMount{
+			Name:      "additional-scrape-configs",
+			ReadOnly:  true,
+			MountPath: additionalScrapeConfigsDir,
+		}
+		promVolumeMounts = append(promVolumeMounts, additionalScrapeConfigsMount)
+		configReloadVolumeMounts = append(configReloadVolumeMounts, additionalScrapeConfigsMount)
+		configReloadArgs = append(configReloadArgs, fmt.Sprintf("--watched-dir=%s", additionalScrapeConfigsDir))
+	}
+
+	// The remote rule files are written by the operator to a ConfigMap.
+	if remoteRuleFiles(&p) {
+		volumes = append(volumes, remoteRulesVolume(p.Name))
+		promVolumeMounts = append(promVolumeMounts, v1.VolumeMount{
+			Name:      remoteRulesVolumeName,
+			ReadOnly:  true,
+			MountPath: remoteRulesDir,
+		})
+	}
+
+	// The split scrape configurations are projected from optional Secrets
+	// into a single directory included by a glob pattern.
+	if splitScrapeConfigs(&p) {
+		boolTrue := true
+		sources := make([]v1.VolumeProjection, 0, maxScrapeConfigsSecrets)
+		for i := 0; i < maxScrapeConfigsSecrets; i++ {
+			sources = append(sources, v1.VolumeProjection{
+				Secret: &v1.SecretProjection{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: scrapeConfigsSecretName(p.Name, i),
+					},
+					Items: []v1.KeyToPath{
+						{
+							Key:  scrapeConfigsFilename,
+							Path: fmt.Sprintf("%d.yaml", i),
+						},
+					},
+					Optional: &boolTrue,
+				},
+			})
+		}
+		volumes = append(volumes, v1.Volume{
+			Name: "scrape-configs",
+			VolumeSource: v1.VolumeSource{
+				Projected: &v1.ProjectedVolumeSource{
+					Sources: sources,
+				},
+			},
+		})
+		scrapeConfigsMount := v1.VolumeMount{
+			Name:      "scrape-configs",
+			ReadOnly:  true,
+			MountPath: scrapeConfigsDir,
+		}
+		promVolumeMounts = append(promVolumeMounts, scrapeConfigsMount)
+		configReloadVolumeMounts = append(configReloadVolumeMounts, scrapeConfigsMount)
+		configReloadArgs = append(configReloadArgs, fmt.Sprintf("--watched-dir=%s", scrapeConfigsDir))
+	}
+
+	if p.Spec.BoundServiceAccountToken != nil {
+		vol, mount := makeBoundTokenVolume(p.Spec.BoundServiceAccountToken)
+		volumes = append(volumes, vol)
+		promVolumeMounts = append(promVolumeMounts, mount)
+	}
 
 	var livenessProbeHandler v1.Handler
 	var readinessProbeHandler v1.Handler
@@ -591,10 +843,7 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 					},
 				}
 			} else {
-				livenessProbeHandler.HTTPGet = &v1.HTTPGetAction{
-					Path: healthyPath,
-					Port: intstr.FromString(p.Spec.PortName),
-				}
+				livenessProbeHandler = webconfig.ProbeHandler(web, healthyPath, intstr.FromString(p.Spec.PortName))
 			}
 		}
 		{
@@ -610,10 +859,7 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 				}
 
 			} else {
-				readinessProbeHandler.HTTPGet = &v1.HTTPGetAction{
-					Path: readyPath,
-					Port: intstr.FromString(p.Spec.PortName),
-				}
+				readinessProbeHandler = webconfig.ProbeHandler(web, readyPath, intstr.FromString(p.Spec.PortName))
 			}
 		}
 
@@ -673,7 +919,7 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 
 	var additionalContainers []v1.Container
 
-	if len(ruleConfigMapNames) != 0 {
+	if len(ruleConfigMapNames) != 0 && !httpReload(&p) {
 		container := v1.Container{
 			Name:  "rules-configmap-reloader",
 			Image: c.ConfigReloaderImage,
@@ -683,6 +929,7 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 			VolumeMounts: []v1.VolumeMount{},
 			Resources: v1.ResourceRequirements{
 				Limits: v1.ResourceList{}, Requests: v1.ResourceList{}},
+			SecurityContext:          p.Spec.RulesConfigmapReloaderSecurityContext,
 			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 		}
 
@@ -695,13 +942,18 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 			container.Resources.Requests[v1.ResourceMemory] = resource.MustParse(c.ConfigReloaderMemory)
 		}
 
-		for _, name := range ruleConfigMapNames {
-			mountPath := rulesDir + "/" + name
+		container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
+			Name:      rulesVolumeName,
+			MountPath: rulesDir,
+		})
+		container.Args = append(container.Args, fmt.Sprintf("--volume-dir=%s", rulesDir))
+
+		if remoteRuleFiles(&p) {
 			container.VolumeMounts = append(container.VolumeMounts, v1.VolumeMount{
-				Name:      name,
-				MountPath: mountPath,
+				Name:      remoteRulesVolumeName,
+				MountPath: remoteRulesDir,
 			})
-			container.Args = append(container.Args, fmt.Sprintf("--volume-dir=%s", mountPath))
+			container.Args = append(container.Args, fmt.Sprintf("--volume-dir=%s", remoteRulesDir))
 		}
 
 		additionalContainers = append(additionalContainers, container)
@@ -728,7 +980,7 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 		}
 
 		thanosArgs := []string{"sidecar",
-			fmt.Sprintf("--prometheus.url=http://%s:9090%s", c.LocalHost, path.Clean(webRoutePrefix)),
+			fmt.Sprintf("--prometheus.url=%s://%s:9090%s", webconfig.Scheme(web), c.LocalHost, path.Clean(webRoutePrefix)),
 			fmt.Sprintf("--grpc-address=%s:10901", bindAddress),
 			fmt.Sprintf("--http-address=%s:10902", bindAddress),
 		}
@@ -746,9 +998,48 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 			}
 		}
 
+		thanosProbeHandler := func(probePath string) v1.Handler {
+			if p.Spec.Thanos.ListenLocal {
+				localPath := fmt.Sprintf("http://127.0.0.1:10902%s", probePath)
+				return v1.Handler{
+					Exec: &v1.ExecAction{
+						Command: []string{
+							"sh",
+							"-c",
+							fmt.Sprintf(localProbe, localPath, localPath),
+						},
+					},
+				}
+			}
+
+			return v1.Handler{
+				HTTPGet: &v1.HTTPGetAction{
+					Path: probePath,
+					Port: intstr.FromString("http"),
+				},
+			}
+		}
+
+		thanosLivenessProbe := &v1.Probe{
+			Handler:          thanosProbeHandler("/-/healthy"),
+			PeriodSeconds:    30,
+			TimeoutSeconds:   probeTimeoutSeconds,
+			FailureThreshold: 4,
+		}
+		applyProbeTiming(thanosLivenessProbe, p.Spec.Thanos.LivenessProbe)
+
+		thanosReadinessProbe := &v1.Probe{
+			Handler:          thanosProbeHandler("/-/ready"),
+			PeriodSeconds:    5,
+			TimeoutSeconds:   probeTimeoutSeconds,
+			FailureThreshold: 20, // Allow Prometheus to start up before the sidecar is ready.
+		}
+		applyProbeTiming(thanosReadinessProbe, p.Spec.Thanos.ReadinessProbe)
+
 		container := v1.Container{
 			Name:                     "thanos-sidecar",
 			Image:                    thanosImage,
+			SecurityContext:          p.Spec.Thanos.SecurityContext,
 			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 			Args:                     thanosArgs,
 			Env: []v1.EnvVar{
@@ -771,7 +1062,9 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 					ContainerPort: 10901,
 				},
 			},
-			Resources: p.Spec.Thanos.Resources,
+			Resources:      p.Spec.Thanos.Resources,
+			LivenessProbe:  thanosLivenessProbe,
+			ReadinessProbe: thanosReadinessProbe,
 		}
 
 		if p.Spec.Thanos.ObjectStorageConfig != nil {
@@ -822,52 +1115,58 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 		if p.Spec.Thanos.MinTime != "" {
 			container.Args = append(container.Args, "--min-time="+p.Spec.Thanos.MinTime)
 		}
+		if p.Spec.Thanos.UploadCompacted {
+			container.Args = append(container.Args, "--shipper.upload-compacted")
+		}
 		additionalContainers = append(additionalContainers, container)
 	}
-	if disableCompaction {
+	if p.Spec.Thanos != nil && p.Spec.Thanos.MinBlockDuration != "" {
+		promArgs = append(promArgs, "--storage.tsdb.min-block-duration="+p.Spec.Thanos.MinBlockDuration)
+	}
+	if p.Spec.Thanos != nil && p.Spec.Thanos.MaxBlockDuration != "" {
+		promArgs = append(promArgs, "--storage.tsdb.max-block-duration="+p.Spec.Thanos.MaxBlockDuration)
+	} else if disableCompaction {
 		promArgs = append(promArgs, "--storage.tsdb.max-block-duration=2h")
 	}
 
-	prometheusConfigReloaderResources := v1.ResourceRequirements{
-		Limits: v1.ResourceList{}, Requests: v1.ResourceList{}}
-	if c.ConfigReloaderCPU != "0" {
-		prometheusConfigReloaderResources.Limits[v1.ResourceCPU] = resource.MustParse(c.ConfigReloaderCPU)
-		prometheusConfigReloaderResources.Requests[v1.ResourceCPU] = resource.MustParse(c.ConfigReloaderCPU)
+	prometheusConfigReloaderResources := configReloaderResources(c)
+
+	podNameEnv := v1.EnvVar{
+		Name: "POD_NAME",
+		ValueFrom: &v1.EnvVarSource{
+			FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
+		},
 	}
-	if c.ConfigReloaderMemory != "0" {
-		prometheusConfigReloaderResources.Limits[v1.ResourceMemory] = resource.MustParse(c.ConfigReloaderMemory)
-		prometheusConfigReloaderResources.Requests[v1.ResourceMemory] = resource.MustParse(c.ConfigReloaderMemory)
+
+	prometheusContainer := v1.Container{
+		Name:                     "prometheus",
+		Image:                    prometheusImagePath,
+		Ports:                    ports,
+		Args:                     promArgs,
+		VolumeMounts:             promVolumeMounts,
+		LivenessProbe:            livenessProbe,
+		ReadinessProbe:           readinessProbe,
+		Resources:                p.Spec.Resources,
+		TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 	}
 
-	operatorContainers := append([]v1.Container{
-		{
-			Name:                     "prometheus",
-			Image:                    prometheusImagePath,
-			Ports:                    ports,
-			Args:                     promArgs,
-			VolumeMounts:             promVolumeMounts,
-			LivenessProbe:            livenessProbe,
-			ReadinessProbe:           readinessProbe,
-			Resources:                p.Spec.Resources,
-			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
-		}, {
+	operatorContainers := []v1.Container{prometheusContainer}
+	if httpReload(&p) {
+		operatorContainers[0].Env = []v1.EnvVar{podNameEnv}
+	} else {
+		operatorContainers = append(operatorContainers, v1.Container{
 			Name:                     "prometheus-config-reloader",
 			Image:                    c.PrometheusConfigReloaderImage,
+			SecurityContext:          p.Spec.ConfigReloaderSecurityContext,
 			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
-			Env: []v1.EnvVar{
-				{
-					Name: "POD_NAME",
-					ValueFrom: &v1.EnvVarSource{
-						FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"},
-					},
-				},
-			},
-			Command:      []string{"/bin/prometheus-config-reloader"},
-			Args:         configReloadArgs,
-			VolumeMounts: configReloadVolumeMounts,
-			Resources:    prometheusConfigReloaderResources,
-		},
-	}, additionalContainers...)
+			Env:                      []v1.EnvVar{podNameEnv},
+			Command:                  []string{"/bin/prometheus-config-reloader"},
+			Args:                     configReloadArgs,
+			VolumeMounts:             configReloadVolumeMounts,
+			Resources:                prometheusConfigReloaderResources,
+		})
+	}
+	operatorContainers = append(operatorContainers, additionalContainers...)
 
 	containers, err := k8sutil.MergePatchContainers(operatorContainers, p.Spec.Containers)
 	if err != nil {
@@ -894,18 +1193,82 @@ func makeStatefulSetSpec(p monitoringv1.Prometheus, c *Config, ruleConfigMapName
 				Containers:                    containers,
 				InitContainers:                p.Spec.InitContainers,
 				SecurityContext:               p.Spec.SecurityContext,
-				ServiceAccountName:            p.Spec.ServiceAccountName,
+				ServiceAccountName:            serviceAccountName(&p),
 				NodeSelector:                  p.Spec.NodeSelector,
 				PriorityClassName:             p.Spec.PriorityClassName,
+				HostAliases:                   p.Spec.HostAliases,
 				TerminationGracePeriodSeconds: &terminationGracePeriod,
 				Volumes:                       volumes,
 				Tolerations:                   p.Spec.Tolerations,
 				Affinity:                      p.Spec.Affinity,
+				TopologySpreadConstraints:     makeTopologySpreadConstraints(p, finalSelectorLabels),
 			},
 		},
 	}, nil
 }
 
+// makeTopologySpreadConstraints returns the topology spread constraints of
+// the Pods. The given selector labels are added to the label selector of the
+// constraints with additionalLabelSelectors and used as label selector of
+// the constraints without one.
+func makeTopologySpreadConstraints(p monitoringv1.Prometheus, selectorLabels map[string]string) []v1.TopologySpreadConstraint {
+	var tscs []v1.TopologySpreadConstraint
+	for _, c := range p.Spec.TopologySpreadConstraints {
+		tsc := *c.TopologySpreadConstraint.DeepCopy()
+		if c.AdditionalLabelSelectors == nil && tsc.LabelSelector != nil {
+			tscs = append(tscs, tsc)
+			continue
+		}
+
+		if tsc.LabelSelector == nil {
+			tsc.LabelSelector = &metav1.LabelSelector{}
+		}
+		if tsc.LabelSelector.MatchLabels == nil {
+			tsc.LabelSelector.MatchLabels = map[string]string{}
+		}
+		// The operator doesn't shard Prometheus so both OnResource and
+		// OnShard select the Pods of the Prometheus object.
+		for k, v := range selectorLabels {
+			tsc.LabelSelector.MatchLabels[k] = v
+		}
+
+		tscs = append(tscs, tsc)
+	}
+
+	return tscs
+}
+
+// validateEnableFeatures returns an error if a feature flag can't be passed
+// to the --enable-feature flag of Prometheus, which takes a comma-separated
+// list of feature names.
+func validateEnableFeatures(features []string) error {
+	for _, f := range features {
+		if f == "" || strings.ContainsAny(f, ", \t\n") {
+			return errors.Errorf("invalid feature flag %q in enableFeatures", f)
+		}
+		if f == "agent" {
+			return errors.New("the agent feature flag can't be enabled, use a PrometheusAgent object instead")
+		}
+	}
+	return nil
+}
+
+// configReloaderResources returns the resources of the
+// prometheus-config-reloader container.
+func configReloaderResources(c *Config) v1.ResourceRequirements {
+	resources := v1.ResourceRequirements{
+		Limits: v1.ResourceList{}, Requests: v1.ResourceList{}}
+	if c.ConfigReloaderCPU != "0" {
+		resources.Limits[v1.ResourceCPU] = resource.MustParse(c.ConfigReloaderCPU)
+		resources.Requests[v1.ResourceCPU] = resource.MustParse(c.ConfigReloaderCPU)
+	}
+	if c.ConfigReloaderMemory != "0" {
+		resources.Limits[v1.ResourceMemory] = resource.MustParse(c.ConfigReloaderMemory)
+		resources.Requests[v1.ResourceMemory] = resource.MustParse(c.ConfigReloaderMemory)
+	}
+	return resources
+}
+
 func configSecretName(name string) string {
 	return prefixedName(name)
 }
@@ -914,6 +1277,43 @@ func tlsAssetsSecretName(name string) string {
 	return fmt.Sprintf("%s-tls-assets", prefixedName(name))
 }
 
+func webConfigSecretName(name string) string {
+	return fmt.Sprintf("%s-web-config", prefixedName(name))
+}
+
+func datasourceConfigMapName(name string) string {
+	return fmt.Sprintf("%s-datasource", prefixedName(name))
+}
+
+// applyProbeTiming overrides the timing parameters of the probe with the
+// fields set in the spec.
+func applyProbeTiming(probe *v1.Probe, spec *monitoringv1.ProbeTiming) {
+	if spec == nil {
+		return
+	}
+
+	if spec.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = *spec.InitialDelaySeconds
+	}
+	if spec.PeriodSeconds != nil {
+		probe.PeriodSeconds = *spec.PeriodSeconds
+	}
+	if spec.TimeoutSeconds != nil {
+		probe.TimeoutSeconds = *spec.TimeoutSeconds
+	}
+	if spec.FailureThreshold != nil {
+		probe.FailureThreshold = *spec.FailureThreshold
+	}
+}
+
+func scrapeConfigsSecretName(name string, index int) string {
+	return fmt.Sprintf("%s-scrape-configs-%d", prefixedName(name), index)
+}
+
+func additionalScrapeConfigsSecretName(name string) string {
+	return fmt.Sprintf("%s-additional-scrape-configs", prefixedName(name))
+}
+
 func volumeName(name string) string {
 	return fmt.Sprintf("%s-db", prefixedName(name))
 }
@@ -933,3 +1333,37 @@ func subPathForStorage(s *monitoringv1.StorageSpec) string {
 
 	return "prometheus-db"
 }
+
+// makeBoundTokenVolume returns the projected volume of the bound
+// ServiceAccount token and its mount in the Prometheus container.
+func makeBoundTokenVolume(t *monitoringv1.BoundServiceAccountToken) (v1.Volume, v1.VolumeMount) {
+	expirationSeconds := defaultBoundTokenExpirationSeconds
+	if t.ExpirationSeconds != nil {
+		expirationSeconds = *t.ExpirationSeconds
+	}
+
+	vol := v1.Volume{
+		Name: "bound-token",
+		VolumeSource: v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{
+						ServiceAccountToken: &v1.ServiceAccountTokenProjection{
+							Audience:          t.Audience,
+							ExpirationSeconds: &expirationSeconds,
+							Path:              boundTokenFilename,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	mount := v1.VolumeMount{
+		Name:      "bound-token",
+		ReadOnly:  true,
+		MountPath: boundTokenDir,
+	}
+
+	return vol, mount
+}
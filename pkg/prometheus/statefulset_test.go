@@ -26,6 +26,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
 
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -101,6 +104,25 @@ func TestPodLabelsAnnotations(t *testing.T) {
 		t.Fatal("Pod annotaitons are not properly propagated")
 	}
 }
+
+func TestHostAliases(t *testing.T) {
+	hostAliases := []v1.HostAlias{
+		{
+			IP:        "10.0.0.1",
+			Hostnames: []string{"foo.example.com", "bar.example.com"},
+		},
+	}
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		Spec: monitoringv1.PrometheusSpec{
+			HostAliases: hostAliases,
+		},
+	}, defaultTestConfig, nil, "")
+	require.NoError(t, err)
+	if !reflect.DeepEqual(hostAliases, sset.Spec.Template.Spec.HostAliases) {
+		t.Fatal("Host aliases are not properly propagated")
+	}
+}
+
 func TestPodLabelsShouldNotBeSelectorLabels(t *testing.T) {
 	labels := map[string]string{
 		"testlabel": "testvalue",
@@ -221,9 +243,9 @@ func TestStatefulSetVolumeInitial(t *testing.T) {
 									SubPath:   "",
 								},
 								{
-									Name:      "rules-configmap-one",
+									Name:      "rules",
 									ReadOnly:  false,
-									MountPath: "/etc/prometheus/rules/rules-configmap-one",
+									MountPath: "/etc/prometheus/rules",
 									SubPath:   "",
 								},
 								{
@@ -259,11 +281,17 @@ func TestStatefulSetVolumeInitial(t *testing.T) {
 							},
 						},
 						{
-							Name: "rules-configmap-one",
+							Name: "rules",
 							VolumeSource: v1.VolumeSource{
-								ConfigMap: &v1.ConfigMapVolumeSource{
-									LocalObjectReference: v1.LocalObjectReference{
-										Name: "rules-configmap-one",
+								Projected: &v1.ProjectedVolumeSource{
+									Sources: []v1.VolumeProjection{
+										{
+											ConfigMap: &v1.ConfigMapProjection{
+												LocalObjectReference: v1.LocalObjectReference{
+													Name: "rules-configmap-one",
+												},
+											},
+										},
 									},
 								},
 							},
@@ -893,6 +921,29 @@ func TestThanosObjectStorage(t *testing.T) {
 	}
 }
 
+func TestThanosBlockDurationsAndUploadCompacted(t *testing.T) {
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		Spec: monitoringv1.PrometheusSpec{
+			Thanos: &monitoringv1.ThanosSpec{
+				ObjectStorageConfig: &v1.SecretKeySelector{
+					Key: "thanos-config-secret-test",
+				},
+				UploadCompacted:  true,
+				MinBlockDuration: "1h",
+				MaxBlockDuration: "1h",
+			},
+		},
+	}, defaultTestConfig, nil, "")
+	require.NoError(t, err)
+
+	promArgs := sset.Spec.Template.Spec.Containers[0].Args
+	require.Contains(t, promArgs, "--storage.tsdb.min-block-duration=1h")
+	require.Contains(t, promArgs, "--storage.tsdb.max-block-duration=1h")
+	require.NotContains(t, promArgs, "--storage.tsdb.max-block-duration=2h")
+
+	require.Contains(t, sset.Spec.Template.Spec.Containers[2].Args, "--shipper.upload-compacted")
+}
+
 func TestThanosTracing(t *testing.T) {
 	testKey := "thanos-config-secret-test"
 
@@ -1092,6 +1143,43 @@ func TestSidecarsNoMemoryLimits(t *testing.T) {
 	}
 }
 
+func TestSidecarsSecurityContext(t *testing.T) {
+	boolTrue, boolFalse := true, false
+	newSecurityContext := func(uid int64) *v1.SecurityContext {
+		return &v1.SecurityContext{
+			RunAsUser:                &uid,
+			ReadOnlyRootFilesystem:   &boolTrue,
+			AllowPrivilegeEscalation: &boolFalse,
+			Capabilities:             &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+		}
+	}
+
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		Spec: monitoringv1.PrometheusSpec{
+			ConfigReloaderSecurityContext:         newSecurityContext(1),
+			RulesConfigmapReloaderSecurityContext: newSecurityContext(2),
+			Thanos: &monitoringv1.ThanosSpec{
+				SecurityContext: newSecurityContext(3),
+			},
+		},
+	}, defaultTestConfig, []string{"rules"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error while making StatefulSet: %v", err)
+	}
+
+	expected := map[string]*v1.SecurityContext{
+		"prometheus":                 nil,
+		"prometheus-config-reloader": newSecurityContext(1),
+		"rules-configmap-reloader":   newSecurityContext(2),
+		"thanos-sidecar":             newSecurityContext(3),
+	}
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(c.SecurityContext, expected[c.Name]) {
+			t.Fatalf("expected security context %v for container %q, got %v", expected[c.Name], c.Name, c.SecurityContext)
+		}
+	}
+}
+
 func TestAdditionalContainers(t *testing.T) {
 	// The base to compare everything against
 	baseSet, err := makeStatefulSet(monitoringv1.Prometheus{}, defaultTestConfig, nil, "")
@@ -1235,3 +1323,749 @@ func TestTerminationPolicy(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoteReadLimits(t *testing.T) {
+	limits := &monitoringv1.RemoteReadLimits{
+		SampleLimit:     pointer.Int64Ptr(1000),
+		ConcurrentLimit: pointer.Int32Ptr(5),
+		MaxBytesInFrame: pointer.Int64Ptr(1048576),
+	}
+
+	tests := []struct {
+		version       string
+		expectedArg   string
+		shouldContain bool
+	}{
+		{"v2.4.0", "--storage.remote.read-sample-limit=1000", false},
+		{"v2.4.0", "--storage.remote.read-concurrent-limit=5", false},
+		{"v2.5.0", "--storage.remote.read-sample-limit=1000", true},
+		{"v2.5.0", "--storage.remote.read-concurrent-limit=5", true},
+		{"v2.12.0", "--storage.remote.read-max-bytes-in-frame=1048576", false},
+		{"v2.13.0", "--storage.remote.read-max-bytes-in-frame=1048576", true},
+	}
+
+	for _, test := range tests {
+		sset, err := makeStatefulSet(monitoringv1.Prometheus{
+			Spec: monitoringv1.PrometheusSpec{
+				Version:          test.version,
+				RemoteReadLimits: limits,
+			},
+		}, defaultTestConfig, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		promArgs := sset.Spec.Template.Spec.Containers[0].Args
+		found := false
+		for _, flag := range promArgs {
+			if flag == test.expectedArg {
+				found = true
+				break
+			}
+		}
+
+		if found != test.shouldContain {
+			if test.shouldContain {
+				t.Fatalf("expected Prometheus args to contain %v, but got %v", test.expectedArg, promArgs)
+			} else {
+				t.Fatalf("expected Prometheus args to NOT contain %v, but got %v", test.expectedArg, promArgs)
+			}
+		}
+	}
+}
+
+func TestServiceAccountName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     monitoringv1.PrometheusSpec
+		expected string
+	}{
+		{
+			name: "not managed",
+		},
+		{
+			name: "not managed with name",
+			spec: monitoringv1.PrometheusSpec{
+				ServiceAccountName: "custom",
+			},
+			expected: "custom",
+		},
+		{
+			name: "managed",
+			spec: monitoringv1.PrometheusSpec{
+				ManageServiceAccount: true,
+			},
+			expected: "prometheus-test",
+		},
+		{
+			name: "managed with name",
+			spec: monitoringv1.PrometheusSpec{
+				ManageServiceAccount: true,
+				ServiceAccountName:   "custom",
+			},
+			expected: "custom",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeStatefulSet(monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: tc.spec,
+			}, defaultTestConfig, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sset.Spec.Template.Spec.ServiceAccountName != tc.expected {
+				t.Fatalf("expected service account name %q, got %q", tc.expected, sset.Spec.Template.Spec.ServiceAccountName)
+			}
+		})
+	}
+}
+
+func TestAdditionalScrapeConfigsAsFileVolume(t *testing.T) {
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Version: "v2.43.0",
+			AdditionalScrapeConfigs: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "additional-scrape-configs"},
+				Key:                  "scrape-configs.yaml",
+			},
+			AdditionalScrapeConfigsAsFile: true,
+		},
+	}, defaultTestConfig, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.Name == "additional-scrape-configs" {
+			found = true
+			if vol.Secret == nil || vol.Secret.SecretName != "prometheus-test-additional-scrape-configs" {
+				t.Fatalf("unexpected volume source: %v", vol.VolumeSource)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("additional scrape configs volume not found")
+	}
+
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		if c.Name != "prometheus" && c.Name != "prometheus-config-reloader" {
+			continue
+		}
+
+		mounted := false
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == "additional-scrape-configs" && vm.MountPath == additionalScrapeConfigsDir && vm.ReadOnly {
+				mounted = true
+			}
+		}
+		if !mounted {
+			t.Fatalf("additional scrape configs not mounted in container %q", c.Name)
+		}
+
+		if c.Name != "prometheus-config-reloader" {
+			continue
+		}
+		watched := false
+		for _, arg := range c.Args {
+			if arg == "--watched-dir="+additionalScrapeConfigsDir {
+				watched = true
+			}
+		}
+		if !watched {
+			t.Fatalf("expected --watched-dir argument in %v", c.Args)
+		}
+	}
+}
+
+func TestSplitScrapeConfigsVolume(t *testing.T) {
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Version:            "v2.43.0",
+			SplitScrapeConfigs: true,
+		},
+	}, defaultTestConfig, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.Name != "scrape-configs" {
+			continue
+		}
+		found = true
+		if vol.Projected == nil || len(vol.Projected.Sources) != maxScrapeConfigsSecrets {
+			t.Fatalf("unexpected volume source: %v", vol.VolumeSource)
+		}
+		src := vol.Projected.Sources[1].Secret
+		if src.Name != "prometheus-test-scrape-configs-1" || src.Optional == nil || !*src.Optional {
+			t.Fatalf("unexpected projected secret: %v", src)
+		}
+		if src.Items[0].Key != scrapeConfigsFilename || src.Items[0].Path != "1.yaml" {
+			t.Fatalf("unexpected projected items: %v", src.Items)
+		}
+	}
+	if !found {
+		t.Fatal("scrape configs volume not found")
+	}
+
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		if c.Name != "prometheus" && c.Name != "prometheus-config-reloader" {
+			continue
+		}
+
+		mounted := false
+		for _, vm := range c.VolumeMounts {
+			if vm.Name == "scrape-configs" && vm.MountPath == scrapeConfigsDir && vm.ReadOnly {
+				mounted = true
+			}
+		}
+		if !mounted {
+			t.Fatalf("scrape configs not mounted in container %q", c.Name)
+		}
+	}
+
+	// The volume isn't added for Prometheus versions not supporting
+	// scrape_config_files.
+	sset, err = makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Version:            "v2.42.0",
+			SplitScrapeConfigs: true,
+		},
+	}, defaultTestConfig, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.Name == "scrape-configs" {
+			t.Fatal("unexpected scrape configs volume")
+		}
+	}
+}
+
+func TestThanosSidecarProbes(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		thanos            monitoringv1.ThanosSpec
+		expectedLiveness  *v1.Probe
+		expectedReadiness *v1.Probe
+	}{
+		{
+			name: "default",
+			expectedLiveness: &v1.Probe{
+				Handler: v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path: "/-/healthy",
+						Port: intstr.FromString("http"),
+					},
+				},
+				TimeoutSeconds:   3,
+				PeriodSeconds:    30,
+				FailureThreshold: 4,
+			},
+			expectedReadiness: &v1.Probe{
+				Handler: v1.Handler{
+					HTTPGet: &v1.HTTPGetAction{
+						Path: "/-/ready",
+						Port: intstr.FromString("http"),
+					},
+				},
+				TimeoutSeconds:   3,
+				PeriodSeconds:    5,
+				FailureThreshold: 20,
+			},
+		},
+		{
+			name: "listen local with overrides",
+			thanos: monitoringv1.ThanosSpec{
+				ListenLocal: true,
+				LivenessProbe: &monitoringv1.ProbeTiming{
+					InitialDelaySeconds: pointer.Int32Ptr(10),
+					FailureThreshold:    pointer.Int32Ptr(8),
+				},
+				ReadinessProbe: &monitoringv1.ProbeTiming{
+					PeriodSeconds:  pointer.Int32Ptr(10),
+					TimeoutSeconds: pointer.Int32Ptr(5),
+				},
+			},
+			expectedLiveness: &v1.Probe{
+				Handler: v1.Handler{
+					Exec: &v1.ExecAction{
+						Command: []string{
+							`sh`,
+							`-c`,
+							`if [ -x "$(command -v curl)" ]; then curl http://127.0.0.1:10902/-/healthy; elif [ -x "$(command -v wget)" ]; then wget -q -O /dev/null http://127.0.0.1:10902/-/healthy; else exit 1; fi`,
+						},
+					},
+				},
+				InitialDelaySeconds: 10,
+				TimeoutSeconds:      3,
+				PeriodSeconds:       30,
+				FailureThreshold:    8,
+			},
+			expectedReadiness: &v1.Probe{
+				Handler: v1.Handler{
+					Exec: &v1.ExecAction{
+						Command: []string{
+							`sh`,
+							`-c`,
+							`if [ -x "$(command -v curl)" ]; then curl http://127.0.0.1:10902/-/ready; elif [ -x "$(command -v wget)" ]; then wget -q -O /dev/null http://127.0.0.1:10902/-/ready; else exit 1; fi`,
+						},
+					},
+				},
+				TimeoutSeconds:   5,
+				PeriodSeconds:    10,
+				FailureThreshold: 20,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			thanos := tc.thanos
+			sset, err := makeStatefulSet(monitoringv1.Prometheus{
+				Spec: monitoringv1.PrometheusSpec{
+					Thanos: &thanos,
+				},
+			}, defaultTestConfig, nil, "")
+			if err != nil {
+				t.Fatalf("Unexpected error while making StatefulSet: %v", err)
+			}
+
+			for _, c := range sset.Spec.Template.Spec.Containers {
+				if c.Name != "thanos-sidecar" {
+					continue
+				}
+
+				if !reflect.DeepEqual(c.LivenessProbe, tc.expectedLiveness) {
+					t.Fatalf("Liveness probe doesn't match expected. \n\nExpected: %+v\n\nGot: %+v", tc.expectedLiveness, c.LivenessProbe)
+				}
+				if !reflect.DeepEqual(c.ReadinessProbe, tc.expectedReadiness) {
+					t.Fatalf("Readiness probe doesn't match expected. \n\nExpected: %+v\n\nGot: %+v", tc.expectedReadiness, c.ReadinessProbe)
+				}
+				return
+			}
+
+			t.Fatal("thanos-sidecar container not found")
+		})
+	}
+}
+
+func TestStatefulSetPatch(t *testing.T) {
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			StatefulSetPatch: &runtime.RawExtension{
+				Raw: []byte(`{"spec":{"revisionHistoryLimit":3,"template":{"spec":{"containers":[{"name":"prometheus","workingDir":"/prometheus"}]}}}}`),
+			},
+		},
+	}, defaultTestConfig, nil, "")
+	require.NoError(t, err)
+
+	require.Equal(t, pointer.Int32Ptr(3), sset.Spec.RevisionHistoryLimit)
+
+	var found bool
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		if c.Name != "prometheus" {
+			continue
+		}
+		found = true
+		require.Equal(t, "/prometheus", c.WorkingDir)
+		require.NotEmpty(t, c.Args, "expected the generated arguments to be preserved")
+	}
+	require.True(t, found, "prometheus container not found")
+}
+
+func TestOTLPReceiverFeature(t *testing.T) {
+	for _, tc := range []struct {
+		version       string
+		otlp          *monitoringv1.OTLPConfig
+		shouldContain bool
+	}{
+		{"v2.46.0", &monitoringv1.OTLPConfig{}, false},
+		{"v2.47.0", nil, false},
+		{"v2.47.0", &monitoringv1.OTLPConfig{}, true},
+	} {
+		sset, err := makeStatefulSet(monitoringv1.Prometheus{
+			Spec: monitoringv1.PrometheusSpec{
+				Version: tc.version,
+				OTLP:    tc.otlp,
+			},
+		}, defaultTestConfig, nil, "")
+		require.NoError(t, err)
+
+		promArgs := sset.Spec.Template.Spec.Containers[0].Args
+		found := false
+		for _, flag := range promArgs {
+			if flag == "--enable-feature=otlp-write-receiver" {
+				found = true
+				break
+			}
+		}
+
+		if found != tc.shouldContain {
+			t.Fatalf("version %s: expected OTLP receiver feature to be enabled: %v, got args %v", tc.version, tc.shouldContain, promArgs)
+		}
+	}
+}
+
+func TestEnableFeatures(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		version  string
+		features []string
+		otlp     *monitoringv1.OTLPConfig
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "features",
+			version:  "v2.47.0",
+			features: []string{"exemplar-storage", "otlp-write-receiver", "exemplar-storage"},
+			otlp:     &monitoringv1.OTLPConfig{},
+			expected: []string{"--enable-feature=exemplar-storage", "--enable-feature=otlp-write-receiver"},
+		},
+		{
+			name:     "unsupported version",
+			version:  "v2.24.0",
+			features: []string{"exemplar-storage"},
+		},
+		{
+			name:     "comma-separated features",
+			version:  "v2.47.0",
+			features: []string{"exemplar-storage,memory-snapshot-on-shutdown"},
+			err:      true,
+		},
+		{
+			name:     "agent feature",
+			version:  "v2.47.0",
+			features: []string{"agent"},
+			err:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeStatefulSet(monitoringv1.Prometheus{
+				Spec: monitoringv1.PrometheusSpec{
+					Version:        tc.version,
+					EnableFeatures: tc.features,
+					OTLP:           tc.otlp,
+				},
+			}, defaultTestConfig, nil, "")
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var features []string
+			for _, arg := range sset.Spec.Template.Spec.Containers[0].Args {
+				if strings.HasPrefix(arg, "--enable-feature=") {
+					features = append(features, arg)
+				}
+			}
+			require.Equal(t, tc.expected, features)
+		})
+	}
+}
+
+func TestBoundServiceAccountTokenVolume(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		token *monitoringv1.BoundServiceAccountToken
+
+		expectedAudience          string
+		expectedExpirationSeconds int64
+	}{
+		{
+			name:                      "defaults",
+			token:                     &monitoringv1.BoundServiceAccountToken{},
+			expectedExpirationSeconds: defaultBoundTokenExpirationSeconds,
+		},
+		{
+			name: "custom audience and expiration",
+			token: &monitoringv1.BoundServiceAccountToken{
+				Audience:          "metrics",
+				ExpirationSeconds: pointer.Int64Ptr(600),
+			},
+			expectedAudience:          "metrics",
+			expectedExpirationSeconds: 600,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeStatefulSet(monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: monitoringv1.PrometheusSpec{
+					BoundServiceAccountToken: tc.token,
+				},
+			}, defaultTestConfig, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found := false
+			for _, vol := range sset.Spec.Template.Spec.Volumes {
+				if vol.Name != "bound-token" {
+					continue
+				}
+				found = true
+				if vol.Projected == nil || len(vol.Projected.Sources) != 1 || vol.Projected.Sources[0].ServiceAccountToken == nil {
+					t.Fatalf("unexpected volume source: %v", vol.VolumeSource)
+				}
+				src := vol.Projected.Sources[0].ServiceAccountToken
+				if src.Audience != tc.expectedAudience || *src.ExpirationSeconds != tc.expectedExpirationSeconds || src.Path != boundTokenFilename {
+					t.Fatalf("unexpected token projection: %v", src)
+				}
+			}
+			if !found {
+				t.Fatal("bound token volume not found")
+			}
+
+			mounted := false
+			for _, vm := range sset.Spec.Template.Spec.Containers[0].VolumeMounts {
+				if vm.Name == "bound-token" && vm.MountPath == boundTokenDir && vm.ReadOnly {
+					mounted = true
+				}
+			}
+			if !mounted {
+				t.Fatal("bound token volume not mounted in the prometheus container")
+			}
+		})
+	}
+
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}, defaultTestConfig, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.Name == "bound-token" {
+			t.Fatal("unexpected bound token volume")
+		}
+	}
+}
+
+func TestHTTPReloadStrategy(t *testing.T) {
+	httpStrategy := monitoringv1.HTTPReloadStrategyType
+	sset, err := makeStatefulSet(monitoringv1.Prometheus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.PrometheusSpec{
+			Version:        "v2.27.0",
+			ReloadStrategy: &httpStrategy,
+		},
+	}, defaultTestConfig, []string{"rules-configmap-one"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := sset.Spec.Template.Spec.Containers
+	if len(containers) != 1 || containers[0].Name != "prometheus" {
+		t.Fatalf("expected only the prometheus container, got %v", containers)
+	}
+
+	args := map[string]bool{}
+	for _, a := range containers[0].Args {
+		args[a] = true
+	}
+	for _, a := range []string{"--config.file=/etc/prometheus/config/prometheus.yaml", "--enable-feature=expand-external-labels"} {
+		if !args[a] {
+			t.Fatalf("expected argument %q, got %v", a, containers[0].Args)
+		}
+	}
+
+	if len(containers[0].Env) != 1 || containers[0].Env[0].Name != "POD_NAME" {
+		t.Fatalf("expected the POD_NAME environment variable, got %v", containers[0].Env)
+	}
+
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.Name == "config-out" {
+			t.Fatal("unexpected config-out volume")
+		}
+	}
+
+	found := false
+	for _, m := range containers[0].VolumeMounts {
+		if m.Name == "config" && m.MountPath == confDir {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the config volume to be mounted in %s", confDir)
+	}
+}
+
+func TestTopologySpreadConstraints(t *testing.T) {
+	onResource := monitoringv1.ResourceNameLabelSelector
+	tsc := v1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       "topology.kubernetes.io/zone",
+		WhenUnsatisfiable: v1.DoNotSchedule,
+	}
+	customSelector := tsc
+	customSelector.LabelSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"team": "a"},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		constraint monitoringv1.TopologySpreadConstraint
+
+		expectedMatchLabels map[string]string
+	}{
+		{
+			name:       "default label selector",
+			constraint: monitoringv1.TopologySpreadConstraint{TopologySpreadConstraint: tsc},
+			expectedMatchLabels: map[string]string{
+				"app":        "prometheus",
+				"prometheus": "test",
+			},
+		},
+		{
+			name:                "custom label selector",
+			constraint:          monitoringv1.TopologySpreadConstraint{TopologySpreadConstraint: customSelector},
+			expectedMatchLabels: map[string]string{"team": "a"},
+		},
+		{
+			name: "custom label selector on resource",
+			constraint: monitoringv1.TopologySpreadConstraint{
+				TopologySpreadConstraint: customSelector,
+				AdditionalLabelSelectors: &onResource,
+			},
+			expectedMatchLabels: map[string]string{
+				"app":        "prometheus",
+				"prometheus": "test",
+				"team":       "a",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: monitoringv1.PrometheusSpec{
+					TopologySpreadConstraints: []monitoringv1.TopologySpreadConstraint{tc.constraint},
+				},
+			}
+			sset, err := makeStatefulSet(p, defaultTestConfig, nil, "")
+			require.NoError(t, err)
+
+			tscs := sset.Spec.Template.Spec.TopologySpreadConstraints
+			require.Len(t, tscs, 1)
+			require.Equal(t, tc.expectedMatchLabels, tscs[0].LabelSelector.MatchLabels)
+			require.Equal(t, tsc.TopologyKey, tscs[0].TopologyKey)
+
+			// The Prometheus spec must not be modified.
+			require.Equal(t, tc.constraint, p.Spec.TopologySpreadConstraints[0])
+		})
+	}
+}
+
+func TestWebConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		version        string
+		web            *monitoringv1.WebSpec
+		expectedArg    bool
+		expectedScheme v1.URIScheme
+		expectedTCP    bool
+	}{
+		{
+			name: "no web config",
+		},
+		{
+			name:    "tls",
+			version: "v2.24.0",
+			web: &monitoringv1.WebSpec{
+				TLSConfig: &monitoringv1.WebTLSConfig{
+					Cert: monitoringv1.SecretOrConfigMap{
+						Secret: &v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "web-tls"},
+							Key:                  "tls.crt",
+						},
+					},
+					KeySecret: v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "web-tls"},
+						Key:                  "tls.key",
+					},
+				},
+			},
+			expectedArg:    true,
+			expectedScheme: v1.URISchemeHTTPS,
+		},
+		{
+			name: "basic auth",
+			web: &monitoringv1.WebSpec{
+				BasicAuthUsers: []monitoringv1.WebBasicAuthUser{
+					{
+						Username: "admin",
+						PasswordHash: v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "web-users"},
+							Key:                  "admin",
+						},
+					},
+				},
+			},
+			expectedArg: true,
+			expectedTCP: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sset, err := makeStatefulSet(monitoringv1.Prometheus{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: monitoringv1.PrometheusSpec{
+					Version: tc.version,
+					Web:     tc.web,
+				},
+			}, defaultTestConfig, nil, "")
+			require.NoError(t, err)
+
+			prom := sset.Spec.Template.Spec.Containers[0]
+			arg := "--web.config.file=/etc/prometheus/web_config/web-config.yaml"
+			if !tc.expectedArg {
+				require.NotContains(t, prom.Args, arg)
+				return
+			}
+			require.Contains(t, prom.Args, arg)
+
+			var found bool
+			for _, vol := range sset.Spec.Template.Spec.Volumes {
+				if vol.Name == "web-config" {
+					found = true
+					require.Equal(t, "prometheus-test-web-config", vol.Secret.SecretName)
+				}
+			}
+			require.True(t, found, "web-config volume not found")
+
+			if tc.expectedTCP {
+				require.NotNil(t, prom.ReadinessProbe.TCPSocket)
+				require.Nil(t, prom.ReadinessProbe.HTTPGet)
+				return
+			}
+			require.Equal(t, tc.expectedScheme, prom.ReadinessProbe.HTTPGet.Scheme)
+			require.Equal(t, tc.expectedScheme, prom.LivenessProbe.HTTPGet.Scheme)
+		})
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// Event reasons reported on the monitoring objects.
+	targetsDownReason         = "TargetsDown"
+	targetsDroppedReason      = "AllTargetsDropped"
+	noTargetsDiscoveredReason = "NoTargetsDiscovered"
+)
+
+// targetsResponse is the response of the Prometheus /api/v1/targets
+// endpoint.
+type targetsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ActiveTargets []struct {
+			DiscoveredLabels map[string]string `json:"discoveredLabels"`
+			ScrapePool       string            `json:"scrapePool"`
+			ScrapeURL        string            `json:"scrapeUrl"`
+			LastError        string            `json:"lastError"`
+			Health           string            `json:"health"`
+		} `json:"activeTargets"`
+		DroppedTargets []struct {
+			DiscoveredLabels map[string]string `json:"discoveredLabels"`
+		} `json:"droppedTargets"`
+	} `json:"data"`
+}
+
+// scrapePoolStats summarizes the targets of a scrape pool.
+type scrapePoolStats struct {
+	active    int
+	down      int
+	dropped   int
+	lastError string
+}
+
+// targetDiagnosis is a problem found for the targets of a monitoring object.
+type targetDiagnosis struct {
+	object  runtime.Object
+	reason  string
+	message string
+}
+
+// targetsChecker periodically retrieves the scrape targets of the managed
+// Prometheus instances and reports problems as Kubernetes Events on the
+// ServiceMonitors, PodMonitors and NodeMonitors generating them.
+type targetsChecker struct {
+	logger        log.Logger
+	kclient       kubernetes.Interface
+	recorder      record.EventRecorder
+	client        *http.Client
+	clusterDomain string
+
+	mtx sync.Mutex
+	// jobs maps the Prometheus keys to the monitoring objects selected
+	// during the last reconciliation, indexed by job name.
+	jobs map[string]map[string]runtime.Object
+}
+
+func newTargetsChecker(logger log.Logger, kclient kubernetes.Interface, recorder record.EventRecorder, clusterDomain string) *targetsChecker {
+	return &targetsChecker{
+		logger:        logger,
+		kclient:       kclient,
+		recorder:      recorder,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		clusterDomain: clusterDomain,
+		jobs:          map[string]map[string]runtime.Object{},
+	}
+}
+
+// setMonitors records the monitoring objects selected for the Prometheus
+// instance.
+func (tc *targetsChecker) setMonitors(
+	key string,
+	smons map[string]*monitoringv1.ServiceMonitor,
+	pmons map[string]*monitoringv1.PodMonitor,
+	nmons map[string]*monitoringv1.NodeMonitor,
+) {
+	jobs := map[string]runtime.Object{}
+	for _, sm := range smons {
+		for i := range sm.Spec.Endpoints {
+			jobs[fmt.Sprintf("%s/%s/%d", sm.Namespace, sm.Name, i)] = sm
+		}
+	}
+	for _, pm := range pmons {
+		for i := range pm.Spec.PodMetricsEndpoints {
+			jobs[fmt.Sprintf("%s/%s/%d", pm.Namespace, pm.Name, i)] = pm
+		}
+	}
+	for _, nm := range nmons {
+		for i := range nm.Spec.Endpoints {
+			jobs[fmt.Sprintf("nodeMonitor/%s/%s/%d", nm.Namespace, nm.Name, i)] = nm
+		}
+	}
+
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	tc.jobs[key] = jobs
+}
+
+// forget removes the state of a deleted Prometheus instance.
+func (tc *targetsChecker) forget(key string) {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	delete(tc.jobs, key)
+}
+
+func (tc *targetsChecker) monitors(key string) map[string]runtime.Object {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
+	return tc.jobs[key]
+}
+
+// Run checks the targets of the Prometheus instances returned by list at
+// every interval until the context is canceled.
+func (tc *targetsChecker) Run(ctx context.Context, interval time.Duration, list func() []*monitoringv1.Prometheus) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, p := range list() {
+			if err := tc.check(ctx, p); err != nil {
+				level.Debug(tc.logger).Log("msg", "checking targets failed", "prometheus", p.Name, "namespace", p.Namespace, "err", err)
+			}
+		}
+	}
+}
+
+func (tc *targetsChecker) check(ctx context.Context, p *monitoringv1.Prometheus) error {
+	// Loopback-only instances can't be reached by the operator.
+	if p.Spec.Paused || p.Spec.ListenLocal {
+		return nil
+	}
+	if err := checkWeb(p); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", p.Namespace, p.Name)
+	jobs := tc.monitors(key)
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	resp, err := tc.fetchTargets(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diagnoseTargets(key, jobs, resp) {
+		tc.recorder.Event(d.object, v1.EventTypeWarning, d.reason, d.message)
+	}
+
+	return nil
+}
+
+// fetchTargets retrieves the targets from the first ready Prometheus pod.
+func (tc *targetsChecker) fetchTargets(ctx context.Context, p *monitoringv1.Prometheus) (*targetsResponse, error) {
+	pods, err := tc.kclient.CoreV1().Pods(p.Namespace).List(ctx, ListOptions(p.Name))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods failed")
+	}
+
+	var podName, podIP string
+	for _, pod := range pods.Items {
+		if ready, err := k8sutil.PodRunningAndReady(pod); err == nil && ready && pod.Status.PodIP != "" {
+			podName, podIP = pod.Name, pod.Status.PodIP
+			break
+		}
+	}
+	if podIP == "" {
+		return nil, errors.New("no ready pod")
+	}
+
+	tlsConfig, err := webconfig.ClientTLSConfig(ctx, tc.kclient.CoreV1(), p.Namespace, p.Spec.Web)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid web settings")
+	}
+
+	routePrefix := "/"
+	if p.Spec.RoutePrefix != "" {
+		routePrefix = p.Spec.RoutePrefix
+	}
+	u := fmt.Sprintf("%s://%s:9090%s", webconfig.Scheme(p.Spec.Web), podIP, path.Join(routePrefix, "/api/v1/targets"))
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := webClient(tc.client, tlsConfig, podHost(podName, p.Namespace, tc.clusterDomain))
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	var targets targetsResponse
+	if err := json.NewDecoder(res.Body).Decode(&targets); err != nil {
+		return nil, errors.Wrap(err, "decoding response failed")
+	}
+	if targets.Status != "success" {
+		return nil, errors.Errorf("unexpected status %q", targets.Status)
+	}
+
+	return &targets, nil
+}
+
+// diagnoseTargets correlates the targets with the monitoring objects from
+// which the jobs are generated and returns the problems found.
+func diagnoseTargets(prometheusKey string, jobs map[string]runtime.Object, resp *targetsResponse) []targetDiagnosis {
+	stats := map[string]*scrapePoolStats{}
+	getStats := func(job string) *scrapePoolStats {
+		s, ok := stats[job]
+		if !ok {
+			s = &scrapePoolStats{}
+			stats[job] = s
+		}
+		return s
+	}
+
+	for _, t := range resp.Data.ActiveTargets {
+		job := t.ScrapePool
+		if job == "" {
+			job = t.DiscoveredLabels["job"]
+		}
+
+		s := getStats(job)
+		s.active++
+		if t.Health == "down" {
+			s.down++
+			if s.lastError == "" {
+				s.lastError = fmt.Sprintf("%s: %s", t.ScrapeURL, t.LastError)
+			}
+		}
+	}
+
+	for _, t := range resp.Data.DroppedTargets {
+		getStats(t.DiscoveredLabels["job"]).dropped++
+	}
+
+	// Iterate in a stable order to emit the Events deterministically.
+	names := make([]string, 0, len(jobs))
+	for job := range jobs {
+		names = append(names, job)
+	}
+	sort.Strings(names)
+
+	var res []targetDiagnosis
+	for _, job := range names {
+		obj := jobs[job]
+		s := getStats(job)
+		endpoint := job[strings.LastIndex(job, "/")+1:]
+
+		switch {
+		case s.down > 0:
+			res = append(res, targetDiagnosis{
+				object:  obj,
+				reason:  targetsDownReason,
+				message: fmt.Sprintf("%d/%d targets of endpoint %s are down in Prometheus %s, last error: %s", s.down, s.active, endpoint, prometheusKey, s.lastError),
+			})
+		case s.active == 0 && s.dropped > 0:
+			res = append(res, targetDiagnosis{
+				object:  obj,
+				reason:  targetsDroppedReason,
+				message: fmt.Sprintf("all %d targets discovered for endpoint %s are dropped by relabeling in Prometheus %s, check the port and the relabelings", s.dropped, endpoint, prometheusKey),
+			})
+		case s.active == 0:
+			res = append(res, targetDiagnosis{
+				object:  obj,
+				reason:  noTargetsDiscoveredReason,
+				message: fmt.Sprintf("no target discovered for endpoint %s in Prometheus %s, check the selector, the namespace selector and the RBAC permissions of Prometheus", endpoint, prometheusKey),
+			})
+		}
+	}
+
+	return res
+}
+
+// newEventRecorder returns a recorder publishing Events for the monitoring
+// objects.
+func newEventRecorder(kclient kubernetes.Interface, scheme *runtime.Scheme) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kclient.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, v1.EventSource{Component: "prometheus-operator"})
+}
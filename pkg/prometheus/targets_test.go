@@ -0,0 +1,123 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiagnoseTargets(t *testing.T) {
+	sm := &monitoringv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sm"},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Endpoints: []monitoringv1.Endpoint{{Port: "web"}, {Port: "metrics"}},
+		},
+	}
+	pm := &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pm"},
+		Spec: monitoringv1.PodMonitorSpec{
+			PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{{Port: "web"}},
+		},
+	}
+	healthy := &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "healthy"},
+		Spec: monitoringv1.PodMonitorSpec{
+			PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{{Port: "web"}},
+		},
+	}
+	// The NodeMonitor shares its namespace and name with the ServiceMonitor.
+	nm := &monitoringv1.NodeMonitor{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sm"},
+		Spec: monitoringv1.NodeMonitorSpec{
+			Endpoints: []monitoringv1.NodeMetricsEndpoint{{}},
+		},
+	}
+
+	tc := newTargetsChecker(log.NewNopLogger(), nil, nil, "")
+	tc.setMonitors(
+		"default/test",
+		map[string]*monitoringv1.ServiceMonitor{"default/sm": sm},
+		map[string]*monitoringv1.PodMonitor{"default/pm": pm, "default/healthy": healthy},
+		map[string]*monitoringv1.NodeMonitor{"default/sm": nm},
+	)
+
+	var resp targetsResponse
+	if err := json.Unmarshal([]byte(`{
+  "status": "success",
+  "data": {
+    "activeTargets": [
+      {"scrapePool": "default/sm/0", "scrapeUrl": "http://10.0.0.1:8080/metrics", "health": "down", "lastError": "connection refused"},
+      {"scrapePool": "default/sm/0", "scrapeUrl": "http://10.0.0.2:8080/metrics", "health": "up"},
+      {"discoveredLabels": {"job": "default/healthy/0"}, "scrapeUrl": "http://10.0.0.3:8080/metrics", "health": "up"},
+      {"scrapePool": "nodeMonitor/default/sm/0", "scrapeUrl": "http://10.0.1.1:9100/metrics", "health": "up"}
+    ],
+    "droppedTargets": [
+      {"discoveredLabels": {"job": "default/pm/0"}},
+      {"discoveredLabels": {"job": "default/pm/0"}}
+    ]
+  }
+}`), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	diags := diagnoseTargets("default/test", tc.monitors("default/test"), &resp)
+
+	expected := []struct {
+		object  interface{}
+		reason  string
+		message string
+	}{
+		{
+			object:  pm,
+			reason:  targetsDroppedReason,
+			message: "all 2 targets discovered for endpoint 0 are dropped by relabeling in Prometheus default/test, check the port and the relabelings",
+		},
+		{
+			object:  sm,
+			reason:  targetsDownReason,
+			message: "1/2 targets of endpoint 0 are down in Prometheus default/test, last error: http://10.0.0.1:8080/metrics: connection refused",
+		},
+		{
+			object:  sm,
+			reason:  noTargetsDiscoveredReason,
+			message: "no target discovered for endpoint 1 in Prometheus default/test, check the selector, the namespace selector and the RBAC permissions of Prometheus",
+		},
+	}
+
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnoses, got %d: %+v", len(expected), len(diags), diags)
+	}
+	for i, e := range expected {
+		if diags[i].object != e.object {
+			t.Fatalf("diagnosis %d: unexpected object %v", i, diags[i].object)
+		}
+		if diags[i].reason != e.reason {
+			t.Fatalf("diagnosis %d: expected reason %q, got %q", i, e.reason, diags[i].reason)
+		}
+		if diags[i].message != e.message {
+			t.Fatalf("diagnosis %d: expected message %q, got %q", i, e.message, diags[i].message)
+		}
+	}
+
+	tc.forget("default/test")
+	if len(tc.monitors("default/test")) != 0 {
+		t.Fatal("expected no monitors after forget")
+	}
+}
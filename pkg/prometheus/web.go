@@ -0,0 +1,95 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
+	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
+)
+
+// webConfigVersion is the first Prometheus version supporting the web
+// configuration file.
+var webConfigVersion = semver.MustParse("2.24.0")
+
+// checkWeb returns an error if the web settings can't be applied to the
+// Prometheus object.
+func checkWeb(p *monitoringv1.Prometheus) error {
+	if p.Spec.Web == nil {
+		return nil
+	}
+
+	version, err := semver.ParseTolerant(operator.StringValOrDefault(p.Spec.Version, operator.DefaultPrometheusVersion))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Prometheus version")
+	}
+	if version.LT(webConfigVersion) {
+		return errors.Errorf("web requires Prometheus %s or newer", webConfigVersion)
+	}
+
+	// The operator reloads Prometheus and the config reloader and Thanos
+	// sidecars query it without credentials. Besides, the sidecars only
+	// support plain HTTP.
+	if webconfig.BasicAuthEnabled(p.Spec.Web) {
+		return errors.New("web.basicAuthUsers isn't supported, the operator and the sidecars can't authenticate")
+	}
+	if webconfig.ClientCertRequired(p.Spec.Web) {
+		return errors.New("web.tlsConfig can't require client certificates, the operator and the sidecars have none")
+	}
+	if webconfig.TLSEnabled(p.Spec.Web) {
+		if !httpReload(p) {
+			return errors.Errorf("web.tlsConfig requires the %s reload strategy, the config reloader sidecars don't support TLS", monitoringv1.HTTPReloadStrategyType)
+		}
+		if p.Spec.Thanos != nil {
+			return errors.New("web.tlsConfig can't be used with the Thanos sidecar, which doesn't support TLS")
+		}
+	}
+
+	return nil
+}
+
+// podHost returns the DNS name of the Prometheus Pod behind the governing
+// service.
+func podHost(pod, namespace, clusterDomain string) string {
+	return k8sutil.ServiceHost(pod+"."+governingServiceName, namespace, clusterDomain)
+}
+
+// webClient returns the HTTP client sending requests to the Prometheus Pod
+// with the given DNS name. base is returned as is when TLS isn't enabled,
+// otherwise the server certificate is verified for the DNS name with
+// tlsConfig, as returned by webconfig.ClientTLSConfig.
+func webClient(base *http.Client, tlsConfig *tls.Config, host string) *http.Client {
+	if tlsConfig == nil {
+		return base
+	}
+
+	cfg := tlsConfig.Clone()
+	cfg.ServerName = host
+	return &http.Client{
+		Timeout: base.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: cfg,
+			// The client is only used for the requests of one sync.
+			DisableKeepAlives: true,
+		},
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckWeb(t *testing.T) {
+	httpStrategy := monitoringv1.HTTPReloadStrategyType
+	tlsConfig := func(clientAuthType string) *monitoringv1.WebSpec {
+		return &monitoringv1.WebSpec{
+			TLSConfig: &monitoringv1.WebTLSConfig{ClientAuthType: clientAuthType},
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		spec     monitoringv1.PrometheusSpec
+		expected bool
+	}{
+		{
+			name: "no web settings",
+			spec: monitoringv1.PrometheusSpec{Version: "v2.20.0"},
+		},
+		{
+			name: "supported version",
+			spec: monitoringv1.PrometheusSpec{
+				Version: "v2.24.0",
+				Web:     &monitoringv1.WebSpec{HTTPConfig: &monitoringv1.WebHTTPConfig{}},
+			},
+		},
+		{
+			name: "unsupported version",
+			spec: monitoringv1.PrometheusSpec{
+				Version: "v2.23.0",
+				Web:     &monitoringv1.WebSpec{HTTPConfig: &monitoringv1.WebHTTPConfig{}},
+			},
+			expected: true,
+		},
+		{
+			name: "basic auth",
+			spec: monitoringv1.PrometheusSpec{
+				Version:        "v2.27.0",
+				ReloadStrategy: &httpStrategy,
+				Web: &monitoringv1.WebSpec{
+					BasicAuthUsers: []monitoringv1.WebBasicAuthUser{{Username: "admin"}},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "tls with http reload strategy",
+			spec: monitoringv1.PrometheusSpec{
+				Version:        "v2.27.0",
+				ReloadStrategy: &httpStrategy,
+				Web:            tlsConfig("VerifyClientCertIfGiven"),
+			},
+		},
+		{
+			name: "tls with required client certificates",
+			spec: monitoringv1.PrometheusSpec{
+				Version:        "v2.27.0",
+				ReloadStrategy: &httpStrategy,
+				Web:            tlsConfig("RequireAnyClientCert"),
+			},
+			expected: true,
+		},
+		{
+			name: "tls with config reloader sidecars",
+			spec: monitoringv1.PrometheusSpec{
+				Version: "v2.27.0",
+				Web:     tlsConfig(""),
+			},
+			expected: true,
+		},
+		{
+			name: "tls with thanos sidecar",
+			spec: monitoringv1.PrometheusSpec{
+				Version:        "v2.27.0",
+				ReloadStrategy: &httpStrategy,
+				Web:            tlsConfig(""),
+				Thanos:         &monitoringv1.ThanosSpec{},
+			},
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkWeb(&monitoringv1.Prometheus{Spec: tc.spec})
+			if tc.expected != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestWebClient(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+		Data: map[string][]byte{
+			"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+		},
+	}
+	web := &monitoringv1.WebSpec{
+		TLSConfig: &monitoringv1.WebTLSConfig{
+			Cert: monitoringv1.SecretOrConfigMap{
+				Secret: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "web-tls"},
+					Key:                  "tls.crt",
+				},
+			},
+		},
+	}
+	tlsConfig, err := webconfig.ClientTLSConfig(context.Background(), fake.NewSimpleClientset(secret).CoreV1(), "default", web)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := &http.Client{Timeout: 10 * time.Second}
+	if c := webClient(base, nil, "example.com"); c != base {
+		t.Fatal("expected the base client without TLS")
+	}
+
+	// The test certificate is valid for example.com.
+	res, err := webClient(base, tlsConfig, "example.com").Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if _, err := webClient(base, tlsConfig, "prometheus.example.org").Get(srv.URL); err == nil {
+		t.Fatal("expected a certificate verification error for another host name")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thanos
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var relabelActions = map[string]struct{}{
+	"replace":   {},
+	"keep":      {},
+	"drop":      {},
+	"hashmod":   {},
+	"labelmap":  {},
+	"labeldrop": {},
+	"labelkeep": {},
+}
+
+// makeAlertRelabelConfig validates the alert relabeling rules and returns
+// the value of the --alert.relabel-config argument.
+func makeAlertRelabelConfig(rcs []*monitoringv1.RelabelConfig) (string, error) {
+	relabelings := make([]yaml.MapSlice, 0, len(rcs))
+	for i, rc := range rcs {
+		if err := validateRelabelConfig(rc); err != nil {
+			return "", errors.Wrapf(err, "alertRelabelConfigs[%d]", i)
+		}
+		relabelings = append(relabelings, makeRelabelConfig(rc))
+	}
+
+	b, err := yaml.Marshal(relabelings)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal alert relabel configs")
+	}
+
+	return string(b), nil
+}
+
+func validateRelabelConfig(rc *monitoringv1.RelabelConfig) error {
+	if rc == nil {
+		return errors.New("empty relabel config")
+	}
+
+	action := strings.ToLower(rc.Action)
+	if action == "" {
+		action = "replace"
+	}
+	if _, ok := relabelActions[action]; !ok {
+		return errors.Errorf("unknown action %q", rc.Action)
+	}
+
+	if _, err := regexp.Compile("^(?:" + rc.Regex + ")$"); err != nil {
+		return errors.Wrapf(err, "invalid regex %q", rc.Regex)
+	}
+
+	if (action == "replace" || action == "hashmod") && rc.TargetLabel == "" {
+		return errors.Errorf("targetLabel is required for the %s action", action)
+	}
+
+	if action == "hashmod" && rc.Modulus == 0 {
+		return errors.New("modulus is required for the hashmod action")
+	}
+
+	return nil
+}
+
+func makeRelabelConfig(rc *monitoringv1.RelabelConfig) yaml.MapSlice {
+	relabeling := yaml.MapSlice{}
+
+	if len(rc.SourceLabels) > 0 {
+		relabeling = append(relabeling, yaml.MapItem{Key: "source_labels", Value: rc.SourceLabels})
+	}
+	if rc.Separator != "" {
+		relabeling = append(relabeling, yaml.MapItem{Key: "separator", Value: rc.Separator})
+	}
+	if rc.TargetLabel != "" {
+		relabeling = append(relabeling, yaml.MapItem{Key: "target_label", Value: rc.TargetLabel})
+	}
+	if rc.Regex != "" {
+		relabeling = append(relabeling, yaml.MapItem{Key: "regex", Value: rc.Regex})
+	}
+	if rc.Modulus != 0 {
+		relabeling = append(relabeling, yaml.MapItem{Key: "modulus", Value: rc.Modulus})
+	}
+	if rc.Replacement != "" {
+		relabeling = append(relabeling, yaml.MapItem{Key: "replacement", Value: rc.Replacement})
+	}
+	if rc.Action != "" {
+		relabeling = append(relabeling, yaml.MapItem{Key: "action", Value: strings.ToLower(rc.Action)})
+	}
+
+	return relabeling
+}
+
+// makeQueryConfig validates the querier endpoints and returns the value of
+// the --query.config argument.
+func makeQueryConfig(cfgs []monitoringv1.ThanosQueryEndpointConfig) (string, error) {
+	queryConfigs := make([]yaml.MapSlice, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		qc, err := makeQueryEndpointConfig(cfg)
+		if err != nil {
+			return "", errors.Wrapf(err, "queryEndpointConfigs[%d]", i)
+		}
+		queryConfigs = append(queryConfigs, qc)
+	}
+
+	b, err := yaml.Marshal(queryConfigs)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal query configs")
+	}
+
+	return string(b), nil
+}
+
+func makeQueryEndpointConfig(cfg monitoringv1.ThanosQueryEndpointConfig) (yaml.MapSlice, error) {
+	if len(cfg.StaticConfigs) == 0 {
+		return nil, errors.New("at least one static config is required")
+	}
+	for _, addr := range cfg.StaticConfigs {
+		if addr == "" || strings.Contains(addr, "://") {
+			return nil, errors.Errorf("invalid address %q, expected host:port", addr)
+		}
+	}
+
+	switch cfg.Scheme {
+	case "", "http", "https":
+	default:
+		return nil, errors.Errorf("unsupported scheme %q", cfg.Scheme)
+	}
+
+	qc := yaml.MapSlice{}
+
+	if tls := cfg.TLSConfig; tls != nil {
+		if tls.CA != (monitoringv1.SecretOrConfigMap{}) || tls.Cert != (monitoringv1.SecretOrConfigMap{}) || tls.KeySecret != nil || tls.CertManagerRef != nil {
+			return nil, errors.New("only the file-based TLS settings are supported")
+		}
+
+		tlsConfig := yaml.MapSlice{}
+		for _, item := range []struct {
+			key   string
+			value string
+		}{
+			{"ca_file", tls.CAFile},
+			{"cert_file", tls.CertFile},
+			{"key_file", tls.KeyFile},
+			{"server_name", tls.ServerName},
+		} {
+			if item.value != "" {
+				tlsConfig = append(tlsConfig, yaml.MapItem{Key: item.key, Value: item.value})
+			}
+		}
+		if tls.InsecureSkipVerify {
+			tlsConfig = append(tlsConfig, yaml.MapItem{Key: "insecure_skip_verify", Value: true})
+		}
+
+		qc = append(qc, yaml.MapItem{Key: "http_config", Value: yaml.MapSlice{
+			{Key: "tls_config", Value: tlsConfig},
+		}})
+	}
+
+	qc = append(qc, yaml.MapItem{Key: "static_configs", Value: cfg.StaticConfigs})
+
+	if cfg.Scheme != "" {
+		qc = append(qc, yaml.MapItem{Key: "scheme", Value: cfg.Scheme})
+	}
+	if cfg.PathPrefix != "" {
+		qc = append(qc, yaml.MapItem{Key: "path_prefix", Value: cfg.PathPrefix})
+	}
+
+	return qc, nil
+}
+
+// escapeArg escapes the $(VAR) sequences of a container argument which would
+// otherwise be expanded by the kubelet.
+func escapeArg(s string) string {
+	return strings.Replace(s, "$(", "$$(", -1)
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package thanos
+
+import (
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateRelabelConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rc    *monitoringv1.RelabelConfig
+		valid bool
+	}{
+		{
+			name:  "default action",
+			rc:    &monitoringv1.RelabelConfig{SourceLabels: []string{"cluster"}, TargetLabel: "region"},
+			valid: true,
+		},
+		{
+			name:  "case insensitive action",
+			rc:    &monitoringv1.RelabelConfig{Action: "LabelDrop", Regex: "replica"},
+			valid: true,
+		},
+		{
+			name: "unknown action",
+			rc:   &monitoringv1.RelabelConfig{Action: "delete"},
+		},
+		{
+			name: "invalid regex",
+			rc:   &monitoringv1.RelabelConfig{Action: "keep", Regex: "a(b"},
+		},
+		{
+			name: "replace without target label",
+			rc:   &monitoringv1.RelabelConfig{Action: "replace", SourceLabels: []string{"cluster"}},
+		},
+		{
+			name: "hashmod without modulus",
+			rc:   &monitoringv1.RelabelConfig{Action: "hashmod", TargetLabel: "shard"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRelabelConfig(tc.rc)
+			if tc.valid && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestMakeQueryConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfgs     []monitoringv1.ThanosQueryEndpointConfig
+		expected string
+		err      bool
+	}{
+		{
+			name: "static configs",
+			cfgs: []monitoringv1.ThanosQueryEndpointConfig{
+				{StaticConfigs: []string{"thanos-query-0:10902", "thanos-query-1:10902"}},
+			},
+			expected: `- static_configs:
+  - thanos-query-0:10902
+  - thanos-query-1:10902
+`,
+		},
+		{
+			name: "https with tls",
+			cfgs: []monitoringv1.ThanosQueryEndpointConfig{
+				{
+					StaticConfigs: []string{"thanos-query:10902"},
+					Scheme:        "https",
+					PathPrefix:    "/thanos",
+					TLSConfig: &monitoringv1.TLSConfig{
+						CAFile:     "/etc/tls/ca.crt",
+						ServerName: "thanos-query",
+					},
+				},
+			},
+			expected: `- http_config:
+    tls_config:
+      ca_file: /etc/tls/ca.crt
+      server_name: thanos-query
+  static_configs:
+  - thanos-query:10902
+  scheme: https
+  path_prefix: /thanos
+`,
+		},
+		{
+			name: "no static configs",
+			cfgs: []monitoringv1.ThanosQueryEndpointConfig{{}},
+			err:  true,
+		},
+		{
+			name: "unsupported tls settings",
+			cfgs: []monitoringv1.ThanosQueryEndpointConfig{
+				{
+					StaticConfigs: []string{"thanos-query:10902"},
+					TLSConfig: &monitoringv1.TLSConfig{
+						KeySecret: &v1.SecretKeySelector{Key: "tls.key"},
+					},
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := makeQueryConfig(tc.cfgs)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEscapeArg(t *testing.T) {
+	if got := escapeArg("replacement: $(HOSTNAME)-$1"); got != "replacement: $$(HOSTNAME)-$1" {
+		t.Fatalf("unexpected escaped argument %q", got)
+	}
+}
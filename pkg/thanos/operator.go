@@ -17,6 +17,7 @@ package thanos
 import (
 	"context"
 	"fmt"
+	"path"
 	"reflect"
 	"strings"
 	"time"
@@ -24,11 +25,13 @@ import (
 	"github.com/mitchellh/hashstructure"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	monitoringclient "github.com/prometheus-operator/prometheus-operator/pkg/client/versioned"
+	"github.com/prometheus-operator/prometheus-operator/pkg/datasource"
 	"github.com/prometheus-operator/prometheus-operator/pkg/informers"
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/listwatch"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
 	prometheusoperator "github.com/prometheus-operator/prometheus-operator/pkg/prometheus"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -49,7 +52,6 @@ import (
 )
 
 const (
-	resyncPeriod     = 5 * time.Minute
 	thanosRulerLabel = "thanos-ruler"
 )
 
@@ -60,6 +62,10 @@ type Operator struct {
 	mclient monitoringclient.Interface
 	logger  log.Logger
 
+	// nsWatcher updates the namespaces of the informers when a namespace
+	// selector is configured.
+	nsWatcher *operator.NamespaceWatcher
+
 	thanosRulerInfs *informers.ForResource
 	cmapInfs        *informers.ForResource
 	ruleInfs        *informers.ForResource
@@ -73,11 +79,15 @@ type Operator struct {
 	metrics *operator.Metrics
 
 	config Config
+	// gcInterval is kept out of Config since Config is part of the
+	// StatefulSet input hash.
+	gcInterval time.Duration
 }
 
 // Config defines configuration parameters for the Operator.
 type Config struct {
 	Host                   string
+	ClusterDomain          string
 	TLSInsecure            bool
 	TLSConfig              rest.TLSClientConfig
 	ConfigReloaderImage    string
@@ -90,6 +100,8 @@ type Config struct {
 	LogLevel               string
 	LogFormat              string
 	ThanosRulerSelector    string
+	Controller             operator.ControllerConfig
+	Mode                   string
 }
 
 // New creates a new controller.
@@ -113,14 +125,20 @@ func New(ctx context.Context, conf prometheusoperator.Config, logger log.Logger,
 		return nil, errors.Wrap(err, "can not parse thanos ruler selector value")
 	}
 
+	if err := conf.ThanosRulerController.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid controller configuration")
+	}
+
 	o := &Operator{
-		kclient: client,
-		mclient: mclient,
-		logger:  logger,
-		queue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "thanos"),
-		metrics: operator.NewMetrics("thanos", r),
+		kclient:    client,
+		mclient:    mclient,
+		logger:     logger,
+		queue:      conf.ThanosRulerController.NewRateLimitingQueue("thanos"),
+		metrics:    operator.NewMetrics("thanos", r),
+		gcInterval: conf.GCInterval,
 		config: Config{
 			Host:                   conf.Host,
+			ClusterDomain:          conf.ClusterDomain,
 			TLSInsecure:            conf.TLSInsecure,
 			TLSConfig:              conf.TLSConfig,
 			ConfigReloaderImage:    conf.ConfigReloaderImage,
@@ -133,12 +151,22 @@ func New(ctx context.Context, conf prometheusoperator.Config, logger log.Logger,
 			LogLevel:               conf.LogLevel,
 			LogFormat:              conf.LogFormat,
 			ThanosRulerSelector:    conf.ThanosRulerSelector,
+			Controller:             conf.ThanosRulerController,
+			Mode:                   conf.Mode,
 		},
 	}
+	resyncPeriod := conf.ThanosRulerController.ResyncPeriod
+
+	if conf.Namespaces.Selector != "" {
+		o.nsWatcher, err = operator.NewNamespaceWatcher(logger, client, conf.Namespaces.Selector, resyncPeriod, o.enqueueAll)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	o.cmapInfs, err = informers.NewInformersForResource(
 		informers.NewKubeInformerFactories(
-			o.config.Namespaces.ThanosRulerAllowList,
+			o.nsWatcher.AllowList(o.config.Namespaces.ThanosRulerAllowList),
 			o.config.Namespaces.DenyList,
 			o.kclient,
 			resyncPeriod,
@@ -154,7 +182,7 @@ func New(ctx context.Context, conf prometheusoperator.Config, logger log.Logger,
 
 	o.thanosRulerInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			o.config.Namespaces.ThanosRulerAllowList,
+			o.nsWatcher.AllowList(o.config.Namespaces.ThanosRulerAllowList),
 			o.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -170,7 +198,7 @@ func New(ctx context.Context, conf prometheusoperator.Config, logger log.Logger,
 
 	o.ruleInfs, err = informers.NewInformersForResource(
 		informers.NewMonitoringInformerFactories(
-			o.config.Namespaces.AllowList,
+			o.nsWatcher.AllowList(o.config.Namespaces.AllowList),
 			o.config.Namespaces.DenyList,
 			mclient,
 			resyncPeriod,
@@ -184,7 +212,7 @@ func New(ctx context.Context, conf prometheusoperator.Config, logger log.Logger,
 
 	o.ssetInfs, err = informers.NewInformersForResource(
 		informers.NewKubeInformerFactories(
-			o.config.Namespaces.ThanosRulerAllowList,
+			o.nsWatcher.AllowList(o.config.Namespaces.ThanosRulerAllowList),
 			o.config.Namespaces.DenyList,
 			o.kclient,
 			resyncPeriod,
@@ -196,6 +224,9 @@ func New(ctx context.Context, conf prometheusoperator.Config, logger log.Logger,
 		return nil, errors.Wrap(err, "error creating statefulset informers")
 	}
 
+	o.nsWatcher.Watch(o.config.Namespaces.ThanosRulerAllowList, o.thanosRulerInfs, o.cmapInfs, o.ssetInfs)
+	o.nsWatcher.Watch(o.config.Namespaces.AllowList, o.ruleInfs)
+
 	newNamespaceInformer := func(o *Operator, allowList map[string]struct{}) cache.SharedIndexInformer {
 		// nsResyncPeriod is used to control how often the namespace informer
 		// should resync. If the unprivileged ListerWatcher is used, then the
@@ -321,7 +352,13 @@ func (o *Operator) Run(ctx context.Context) error {
 		return nil
 	}
 
-	go o.worker(ctx)
+	for i := 0; i < o.config.Controller.Workers; i++ {
+		go o.worker(ctx)
+	}
+
+	if err := o.nsWatcher.Init(ctx); err != nil {
+		return err
+	}
 
 	go o.thanosRulerInfs.Start(ctx.Done())
 	go o.cmapInfs.Start(ctx.Done())
@@ -335,11 +372,50 @@ func (o *Operator) Run(ctx context.Context) error {
 		return err
 	}
 	o.addHandlers()
+	go o.nsWatcher.Run(ctx)
+
+	if o.gcInterval > 0 {
+		go o.orphanCollector().Run(ctx, o.gcInterval)
+	}
 
 	<-ctx.Done()
 	return nil
 }
 
+// volumeResizer returns the resizer of the volumes of the ThanosRuler
+// StatefulSets.
+func (o *Operator) volumeResizer() *operator.VolumeResizer {
+	return &operator.VolumeResizer{
+		Logger:     o.logger,
+		KubeClient: o.kclient,
+		Metrics:    o.metrics,
+	}
+}
+
+// orphanCollector returns the garbage collector of the ConfigMaps generated
+// for ThanosRuler resources which don't exist anymore.
+func (o *Operator) orphanCollector() *operator.OrphanCollector {
+	return &operator.OrphanCollector{
+		Logger:           log.With(o.logger, "component", "gc"),
+		KubeClient:       o.kclient,
+		Metrics:          o.metrics,
+		AllowList:        o.config.Namespaces.ThanosRulerAllowList,
+		DenyList:         o.config.Namespaces.DenyList,
+		NamespaceWatcher: o.nsWatcher,
+		Owners: []operator.OrphanOwner{{
+			Kind:  monitoringv1.ThanosRulerKind,
+			Label: labelThanosRulerName,
+			Exists: func(ctx context.Context, namespace, name string) (bool, error) {
+				_, err := o.mclient.MonitoringV1().ThanosRulers(namespace).Get(ctx, name, metav1.GetOptions{})
+				if apierrors.IsNotFound(err) {
+					return false, nil
+				}
+				return err == nil, err
+			},
+		}},
+	}
+}
+
 func (o *Operator) keyFunc(obj interface{}) (string, bool) {
 	k, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
@@ -535,6 +611,18 @@ func (o *Operator) handleStatefulSetUpdate(oldo, curo interface{}) {
 	}
 }
 
+// enqueueAll enqueues all the ThanosRuler objects.
+func (o *Operator) enqueueAll() {
+	objs, err := o.thanosRulerInfs.List(labels.Everything())
+	if err != nil {
+		level.Error(o.logger).Log("msg", "listing all ThanosRuler instances from cache failed", "err", err)
+		return
+	}
+	for _, obj := range objs {
+		o.enqueue(obj)
+	}
+}
+
 // enqueue adds a key to the queue. If obj is a key already it gets added
 // directly. Otherwise, the key is extracted via keyFunc.
 func (o *Operator) enqueue(obj interface{}) {
@@ -569,7 +657,9 @@ func (o *Operator) processNextWorkItem(ctx context.Context) bool {
 	defer o.queue.Done(key)
 
 	o.metrics.ReconcileCounter().Inc()
+	start := time.Now()
 	err := o.sync(ctx, key.(string))
+	o.metrics.ObserveReconcile(time.Since(start), err)
 	if err == nil {
 		o.queue.Forget(key)
 		return true
@@ -603,17 +693,140 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 
 	level.Info(o.logger).Log("msg", "sync thanos-ruler", "key", key)
 
+	err = o.syncStatefulSet(ctx, key, tr)
+	if sErr := o.updateStatus(ctx, tr, err); sErr != nil {
+		level.Warn(o.logger).Log("msg", "failed to update thanos-ruler status", "key", key, "err", sErr)
+	}
+
+	return err
+}
+
+// createOrUpdateWebConfigSecret writes the web configuration file and the
+// TLS assets it references to the Secret mounted in the Thanos Ruler
+// container.
+func (o *Operator) createOrUpdateWebConfigSecret(ctx context.Context, tr *monitoringv1.ThanosRuler) error {
+	if tr.Spec.Web == nil {
+		return nil
+	}
+
+	data, err := webconfig.MakeSecretData(ctx, o.kclient.CoreV1(), tr.Namespace, webConfigDir, tr.Spec.Web)
+	if err != nil {
+		return errors.Wrap(err, "invalid web configuration")
+	}
+
+	boolTrue := true
+	secretLabels := map[string]string{labelThanosRulerName: tr.Name}
+	for k, v := range managedByOperatorLabels {
+		secretLabels[k] = v
+	}
+	s := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   webConfigSecretName(tr.Name),
+			Labels: o.config.Labels.Merge(secretLabels),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         tr.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               tr.Kind,
+					Name:               tr.Name,
+					UID:                tr.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+
+	return k8sutil.CreateOrUpdateSecret(ctx, o.kclient.CoreV1().Secrets(tr.Namespace), s)
+}
+
+// createOrUpdateDatasourceConfigMap synchronizes the ConfigMap describing
+// the Grafana datasource of the Thanos Ruler, deleting it when the
+// ThanosRuler object doesn't request it anymore.
+func (o *Operator) createOrUpdateDatasourceConfigMap(ctx context.Context, tr *monitoringv1.ThanosRuler) error {
+	cmClient := o.kclient.CoreV1().ConfigMaps(tr.Namespace)
+
+	if tr.Spec.ExportDatasource == nil {
+		err := cmClient.Delete(ctx, datasourceConfigMapName(tr.Name), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, "deleting datasource configmap failed")
+		}
+		return nil
+	}
+
+	u := fmt.Sprintf("%s://%s:10902", webconfig.Scheme(tr.Spec.Web), k8sutil.ServiceHost(governingServiceName, tr.Namespace, o.config.ClusterDomain))
+	if tr.Spec.RoutePrefix != "" {
+		u += path.Clean("/" + tr.Spec.RoutePrefix)
+	}
+
+	data, err := datasource.MakeConfigMapData(tr.Spec.ExportDatasource, path.Join(tr.Namespace, tr.Name), u)
+	if err != nil {
+		return errors.Wrap(err, "invalid datasource")
+	}
+
+	cmLabels := map[string]string{labelThanosRulerName: tr.Name}
+	for k, v := range managedByOperatorLabels {
+		cmLabels[k] = v
+	}
+	for k, v := range datasource.Labels(tr.Spec.ExportDatasource) {
+		cmLabels[k] = v
+	}
+
+	boolTrue := true
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   datasourceConfigMapName(tr.Name),
+			Labels: o.config.Labels.Merge(cmLabels),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         tr.APIVersion,
+					BlockOwnerDeletion: &boolTrue,
+					Controller:         &boolTrue,
+					Kind:               tr.Kind,
+					Name:               tr.Name,
+					UID:                tr.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+
+	return k8sutil.CreateOrUpdateConfigMap(ctx, cmClient, cm)
+}
+
+// syncStatefulSet ensures that the rule ConfigMaps, the governing service and
+// the StatefulSet of the ThanosRuler are up to date.
+func (o *Operator) syncStatefulSet(ctx context.Context, key string, tr *monitoringv1.ThanosRuler) error {
 	ruleConfigMapNames, err := o.createOrUpdateRuleConfigMaps(ctx, tr)
 	if err != nil {
 		return err
 	}
 
+	if err = o.createOrUpdateWebConfigSecret(ctx, tr); err != nil {
+		return errors.Wrap(err, "synchronizing web config secret failed")
+	}
+
+	if err = o.createOrUpdateDatasourceConfigMap(ctx, tr); err != nil {
+		return errors.Wrap(err, "synchronizing datasource configmap failed")
+	}
+
+	if o.config.Mode == operator.ModeConfigOnly {
+		return nil
+	}
+
 	// Create governing service if it doesn't exist.
 	svcClient := o.kclient.CoreV1().Services(tr.Namespace)
 	if err = k8sutil.CreateOrUpdateService(ctx, svcClient, makeStatefulSetService(tr, o.config)); err != nil {
 		return errors.Wrap(err, "synchronizing governing service failed")
 	}
 
+	if tr.Spec.ManageServiceAccount {
+		saClient := o.kclient.CoreV1().ServiceAccounts(tr.Namespace)
+		if err = k8sutil.CreateOrUpdateServiceAccount(ctx, saClient, makeServiceAccount(tr, o.config)); err != nil {
+			return errors.Wrap(err, "synchronizing service account failed")
+		}
+	}
+
 	// Ensure we have a StatefulSet running Thanos deployed.
 	ssetClient := o.kclient.AppsV1().StatefulSets(tr.Namespace)
 	obj, err := o.ssetInfs.Get(thanosKeyToStatefulSetKey(key))
@@ -633,6 +846,7 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 		if _, err := ssetClient.Create(ctx, sset, metav1.CreateOptions{}); err != nil {
 			return errors.Wrap(err, "creating thanos statefulset failed")
 		}
+		o.metrics.GeneratedObjectCounter("statefulset", operator.OperationCreate).Inc()
 		return nil
 	}
 
@@ -657,9 +871,18 @@ func (o *Operator) sync(ctx context.Context, key string) error {
 	oldSSetInputHash := obj.(*appsv1.StatefulSet).ObjectMeta.Annotations[sSetInputHashName]
 	if newSSetInputHash == oldSSetInputHash {
 		level.Debug(o.logger).Log("msg", "new statefulset generation inputs match current, skipping any actions")
+		o.metrics.GeneratedObjectCounter("statefulset", operator.OperationUnchanged).Inc()
 		return nil
 	}
 
+	o.metrics.GeneratedObjectCounter("statefulset", operator.OperationUpdate).Inc()
+
+	// The StatefulSet is recreated by the next reconciliation once the
+	// volumes are resized.
+	if resized, err := o.volumeResizer().Resize(ctx, tr.Spec.Storage, obj.(*appsv1.StatefulSet), sset); err != nil || resized {
+		return err
+	}
+
 	_, err = ssetClient.Update(ctx, sset, metav1.UpdateOptions{})
 	sErr, ok := err.(*apierrors.StatusError)
 
@@ -713,6 +936,49 @@ func createSSetInputHash(tr monitoringv1.ThanosRuler, c Config, ruleConfigMapNam
 	return fmt.Sprintf("%d", hash), nil
 }
 
+// updateStatus updates the status subresource of the ThanosRuler object with
+// the current state of its pods and the outcome of the last reconciliation.
+func (o *Operator) updateStatus(ctx context.Context, tr *monitoringv1.ThanosRuler, reconcileErr error) error {
+	status, _, err := ThanosRulerStatus(ctx, o.kclient, tr)
+	if err != nil {
+		if !apierrors.IsNotFound(errors.Cause(err)) {
+			return err
+		}
+		// The StatefulSet doesn't exist when the reconciliation failed
+		// before creating it or in config-only mode.
+		status = &monitoringv1.ThanosRulerStatus{Paused: tr.Spec.Paused}
+	}
+
+	desired := minReplicas
+	if tr.Spec.Replicas != nil {
+		desired = *tr.Spec.Replicas
+	}
+	// The operator doesn't deploy any pod in config-only mode.
+	if o.config.Mode == operator.ModeConfigOnly {
+		desired = 0
+	}
+
+	var current []monitoringv1.Condition
+	if tr.Status != nil {
+		current = tr.Status.Conditions
+	}
+	status.Selector = ListOptions(tr.Name).LabelSelector
+	status.Conditions = operator.MakeConditions(tr.Generation, desired, status.AvailableReplicas, reconcileErr, current)
+
+	// Skip the update when nothing changed to avoid triggering another
+	// reconciliation.
+	if reflect.DeepEqual(tr.Status, status) {
+		return nil
+	}
+
+	tr.Status = status
+	if _, err := o.mclient.MonitoringV1().ThanosRulers(tr.Namespace).UpdateStatus(ctx, tr, metav1.UpdateOptions{}); err != nil {
+		return errors.Wrap(err, "failed to update status subresource")
+	}
+
+	return nil
+}
+
 func ListOptions(name string) metav1.ListOptions {
 	return metav1.ListOptions{
 		LabelSelector: fields.SelectorFromSet(fields.Set(map[string]string{
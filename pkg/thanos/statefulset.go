@@ -24,6 +24,7 @@ import (
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/prometheus-operator/prometheus-operator/pkg/k8sutil"
 	"github.com/prometheus-operator/prometheus-operator/pkg/operator"
+	"github.com/prometheus-operator/prometheus-operator/pkg/webconfig"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -40,6 +41,7 @@ const (
 	defaultEvaluationInterval = "15s"
 	defaultReplicaLabelName   = "thanos_ruler_replica"
 	sSetInputHashName         = "prometheus-operator-input-hash"
+	webConfigDir              = "/etc/thanos/web_config"
 )
 
 var (
@@ -140,6 +142,11 @@ func makeStatefulSet(tr *monitoringv1.ThanosRuler, config Config, ruleConfigMapN
 		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, volume)
 	}
 
+	statefulset, err = k8sutil.PatchStatefulSet(statefulset, tr.Spec.StatefulSetPatch)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to apply statefulset patch")
+	}
+
 	return statefulset, nil
 }
 
@@ -148,9 +155,12 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 	// details see https://github.com/prometheus-operator/prometheus-operator/issues/1659
 	tr = tr.DeepCopy()
 
-	if tr.Spec.QueryConfig == nil && len(tr.Spec.QueryEndpoints) < 1 {
+	if tr.Spec.QueryConfig == nil && len(tr.Spec.QueryEndpointConfigs) == 0 && len(tr.Spec.QueryEndpoints) < 1 {
 		return nil, errors.New(tr.GetName() + ": thanos ruler requires query config or at least one query endpoint to be specified")
 	}
+	if tr.Spec.QueryConfig != nil && len(tr.Spec.QueryEndpointConfigs) > 0 {
+		return nil, errors.New(tr.GetName() + ": queryConfig and queryEndpointConfigs are mutually exclusive")
+	}
 
 	trBaseImage := operator.StringValOrDefault(config.ThanosDefaultBaseImage, operator.DefaultThanosBaseImage)
 	trImagePath, err := operator.BuildImagePath(trBaseImage, operator.DefaultThanosVersion, "", "")
@@ -196,6 +206,22 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 		trCLIArgs = append(trCLIArgs, fmt.Sprintf("--alert.label-drop=%s", lb))
 	}
 
+	if tr.Spec.RuleConcurrentEval != nil {
+		trCLIArgs = append(trCLIArgs, fmt.Sprintf("--rule-concurrent-evaluation=%d", *tr.Spec.RuleConcurrentEval))
+	}
+	if tr.Spec.ForOutageTolerance != "" {
+		trCLIArgs = append(trCLIArgs, "--for-outage-tolerance="+tr.Spec.ForOutageTolerance)
+	}
+	if tr.Spec.ForGracePeriod != "" {
+		trCLIArgs = append(trCLIArgs, "--for-grace-period="+tr.Spec.ForGracePeriod)
+	}
+	if tr.Spec.ResendDelay != "" {
+		trCLIArgs = append(trCLIArgs, "--resend-delay="+tr.Spec.ResendDelay)
+	}
+	for _, lb := range tr.Spec.RestoreIgnoredLabels {
+		trCLIArgs = append(trCLIArgs, "--restore-ignored-label="+lb)
+	}
+
 	ports := []v1.ContainerPort{
 		{
 			Name:          "grpc",
@@ -229,6 +255,12 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 				SecretKeyRef: tr.Spec.QueryConfig,
 			},
 		})
+	} else if len(tr.Spec.QueryEndpointConfigs) > 0 {
+		queryConfig, err := makeQueryConfig(tr.Spec.QueryEndpointConfigs)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid query endpoint configs")
+		}
+		trCLIArgs = append(trCLIArgs, "--query.config="+escapeArg(queryConfig))
 	} else if len(tr.Spec.QueryEndpoints) > 0 {
 		for _, endpoint := range tr.Spec.QueryEndpoints {
 			trCLIArgs = append(trCLIArgs, fmt.Sprintf("--query=%s", endpoint))
@@ -252,6 +284,14 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 		}
 	}
 
+	if len(tr.Spec.AlertRelabelConfigs) > 0 {
+		alertRelabelConfig, err := makeAlertRelabelConfig(tr.Spec.AlertRelabelConfigs)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid alert relabel configs")
+		}
+		trCLIArgs = append(trCLIArgs, "--alert.relabel-config="+escapeArg(alertRelabelConfig))
+	}
+
 	if tr.Spec.ObjectStorageConfig != nil {
 		trCLIArgs = append(trCLIArgs, "--objstore.config=$(OBJSTORE_CONFIG)")
 		trEnvVars = append(trEnvVars, v1.EnvVar{
@@ -293,12 +333,16 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 		trCLIArgs = append(trCLIArgs, fmt.Sprintf("--web.route-prefix=%s", tr.Spec.RoutePrefix))
 	}
 
+	if tr.Spec.Web != nil {
+		trCLIArgs = append(trCLIArgs, fmt.Sprintf("--http.config=%s", path.Join(webConfigDir, webconfig.ConfigFile)))
+	}
+
 	if tr.Spec.AlertQueryURL != "" {
 		trCLIArgs = append(trCLIArgs, fmt.Sprintf("--alert.query-url=%s", tr.Spec.AlertQueryURL))
 	}
 
 	localReloadURL := &url.URL{
-		Scheme: "http",
+		Scheme: webconfig.Scheme(tr.Spec.Web),
 		Host:   config.LocalHost + ":10902",
 		Path:   path.Clean(tr.Spec.RoutePrefix + "/-/reload"),
 	}
@@ -314,6 +358,7 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 			VolumeMounts: []v1.VolumeMount{},
 			Resources: v1.ResourceRequirements{
 				Limits: v1.ResourceList{}, Requests: v1.ResourceList{}},
+			SecurityContext:          tr.Spec.RulesConfigmapReloaderSecurityContext,
 			TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 		}
 
@@ -386,6 +431,12 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 		})
 	}
 
+	if tr.Spec.Web != nil {
+		vol, mount := webconfig.Volume(webConfigSecretName(tr.Name), webConfigDir)
+		trVolumes = append(trVolumes, vol)
+		trVolumeMounts = append(trVolumeMounts, mount)
+	}
+
 	operatorContainers := append([]v1.Container{
 		{
 			Name:                     "thanos-ruler",
@@ -425,7 +476,8 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 			Spec: v1.PodSpec{
 				NodeSelector:                  tr.Spec.NodeSelector,
 				PriorityClassName:             tr.Spec.PriorityClassName,
-				ServiceAccountName:            tr.Spec.ServiceAccountName,
+				HostAliases:                   tr.Spec.HostAliases,
+				ServiceAccountName:            serviceAccountName(tr),
 				TerminationGracePeriodSeconds: &terminationGracePeriod,
 				Containers:                    containers,
 				InitContainers:                tr.Spec.InitContainers,
@@ -438,6 +490,33 @@ func makeStatefulSetSpec(tr *monitoringv1.ThanosRuler, config Config, ruleConfig
 	}, nil
 }
 
+// serviceAccountName returns the name of the ServiceAccount used to run the
+// Thanos Ruler Pods.
+func serviceAccountName(tr *monitoringv1.ThanosRuler) string {
+	if tr.Spec.ManageServiceAccount && tr.Spec.ServiceAccountName == "" {
+		return prefixedName(tr.Name)
+	}
+	return tr.Spec.ServiceAccountName
+}
+
+func makeServiceAccount(tr *monitoringv1.ThanosRuler, config Config) *v1.ServiceAccount {
+	return &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceAccountName(tr),
+			Labels:      config.Labels.Merge(tr.Spec.ServiceAccountLabels),
+			Annotations: tr.Spec.ServiceAccountAnnotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Name:       tr.GetName(),
+					Kind:       tr.Kind,
+					APIVersion: tr.APIVersion,
+					UID:        tr.GetUID(),
+				},
+			},
+		},
+	}
+}
+
 func makeStatefulSetService(tr *monitoringv1.ThanosRuler, config Config) *v1.Service {
 
 	if tr.Spec.PortName == "" {
@@ -487,6 +566,14 @@ func prefixedName(name string) string {
 	return fmt.Sprintf("thanos-ruler-%s", name)
 }
 
+func webConfigSecretName(name string) string {
+	return fmt.Sprintf("%s-web-config", prefixedName(name))
+}
+
+func datasourceConfigMapName(name string) string {
+	return fmt.Sprintf("%s-datasource", prefixedName(name))
+}
+
 func volumeName(name string) string {
 	return fmt.Sprintf("%s-data", prefixedName(name))
 }
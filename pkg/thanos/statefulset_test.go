@@ -105,6 +105,25 @@ func TestPodLabelsAnnotations(t *testing.T) {
 	}
 }
 
+func TestHostAliases(t *testing.T) {
+	hostAliases := []v1.HostAlias{
+		{
+			IP:        "10.0.0.1",
+			Hostnames: []string{"foo.example.com", "bar.example.com"},
+		},
+	}
+	sset, err := makeStatefulSet(&monitoringv1.ThanosRuler{
+		Spec: monitoringv1.ThanosRulerSpec{
+			QueryEndpoints: emptyQueryEndpoints,
+			HostAliases:    hostAliases,
+		},
+	}, defaultTestConfig, nil, "")
+	require.NoError(t, err)
+	if !reflect.DeepEqual(hostAliases, sset.Spec.Template.Spec.HostAliases) {
+		t.Fatal("Host aliases are not properly propagated")
+	}
+}
+
 func TestStatefulSetVolumes(t *testing.T) {
 	expected := &appsv1.StatefulSet{
 		Spec: appsv1.StatefulSetSpec{
@@ -535,3 +554,176 @@ func TestExternalQueryURL(t *testing.T) {
 	}
 	t.Fatalf("Thanos ruler is missing expected argument: %s", expectedArg)
 }
+
+func TestEvaluationFlags(t *testing.T) {
+	concurrentEval := int32(4)
+	sset, err := makeStatefulSet(&monitoringv1.ThanosRuler{
+		Spec: monitoringv1.ThanosRulerSpec{
+			QueryEndpoints:       emptyQueryEndpoints,
+			RuleConcurrentEval:   &concurrentEval,
+			ForOutageTolerance:   "2h",
+			ForGracePeriod:       "15m",
+			ResendDelay:          "2m",
+			RestoreIgnoredLabels: []string{"replica", "pod"},
+		},
+	}, defaultTestConfig, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error while making StatefulSet: %v", err)
+	}
+
+	args := map[string]struct{}{}
+	for _, arg := range sset.Spec.Template.Spec.Containers[0].Args {
+		args[arg] = struct{}{}
+	}
+
+	for _, expectedArg := range []string{
+		"--rule-concurrent-evaluation=4",
+		"--for-outage-tolerance=2h",
+		"--for-grace-period=15m",
+		"--resend-delay=2m",
+		"--restore-ignored-label=replica",
+		"--restore-ignored-label=pod",
+	} {
+		if _, found := args[expectedArg]; !found {
+			t.Fatalf("Thanos ruler is missing expected argument: %s", expectedArg)
+		}
+	}
+}
+
+func TestAlertRelabelAndQueryEndpointConfigs(t *testing.T) {
+	sset, err := makeStatefulSet(&monitoringv1.ThanosRuler{
+		Spec: monitoringv1.ThanosRulerSpec{
+			QueryEndpointConfigs: []monitoringv1.ThanosQueryEndpointConfig{
+				{StaticConfigs: []string{"thanos-query:10902"}},
+			},
+			AlertRelabelConfigs: []*monitoringv1.RelabelConfig{
+				{Action: "labeldrop", Regex: "replica"},
+			},
+		},
+	}, defaultTestConfig, nil, "")
+	if err != nil {
+		t.Fatalf("Unexpected error while making StatefulSet: %v", err)
+	}
+
+	args := sset.Spec.Template.Spec.Containers[0].Args
+	for _, expectedArg := range []string{
+		"--query.config=- static_configs:\n  - thanos-query:10902\n",
+		"--alert.relabel-config=- regex: replica\n  action: labeldrop\n",
+	} {
+		found := false
+		for _, arg := range args {
+			if arg == expectedArg {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("Thanos ruler is missing expected argument %q in %v", expectedArg, args)
+		}
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--query=") {
+			t.Fatalf("unexpected argument %q", arg)
+		}
+	}
+}
+
+func TestInvalidAlertRelabelAndQueryEndpointConfigs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec monitoringv1.ThanosRulerSpec
+	}{
+		{
+			name: "invalid relabel regex",
+			spec: monitoringv1.ThanosRulerSpec{
+				QueryEndpoints: emptyQueryEndpoints,
+				AlertRelabelConfigs: []*monitoringv1.RelabelConfig{
+					{Action: "drop", Regex: "foo(", SourceLabels: []string{"severity"}},
+				},
+			},
+		},
+		{
+			name: "query config and query endpoint configs",
+			spec: monitoringv1.ThanosRulerSpec{
+				QueryConfig: &v1.SecretKeySelector{Key: "query.yaml"},
+				QueryEndpointConfigs: []monitoringv1.ThanosQueryEndpointConfig{
+					{StaticConfigs: []string{"thanos-query:10902"}},
+				},
+			},
+		},
+		{
+			name: "invalid query endpoint",
+			spec: monitoringv1.ThanosRulerSpec{
+				QueryEndpointConfigs: []monitoringv1.ThanosQueryEndpointConfig{
+					{StaticConfigs: []string{"http://thanos-query:10902"}},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := makeStatefulSet(&monitoringv1.ThanosRuler{Spec: tc.spec}, defaultTestConfig, nil, "")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestRulesConfigmapReloaderSecurityContext(t *testing.T) {
+	boolTrue := true
+	sc := &v1.SecurityContext{ReadOnlyRootFilesystem: &boolTrue}
+
+	sset, err := makeStatefulSet(&monitoringv1.ThanosRuler{
+		Spec: monitoringv1.ThanosRulerSpec{
+			QueryEndpoints:                        emptyQueryEndpoints,
+			RulesConfigmapReloaderSecurityContext: sc,
+		},
+	}, defaultTestConfig, []string{"rules"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error while making StatefulSet: %v", err)
+	}
+
+	for _, c := range sset.Spec.Template.Spec.Containers {
+		switch c.Name {
+		case "rules-configmap-reloader":
+			require.Equal(t, sc, c.SecurityContext)
+		default:
+			require.Nil(t, c.SecurityContext)
+		}
+	}
+}
+
+func TestWebConfig(t *testing.T) {
+	sset, err := makeStatefulSet(&monitoringv1.ThanosRuler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: monitoringv1.ThanosRulerSpec{
+			QueryEndpoints: emptyQueryEndpoints,
+			Web: &monitoringv1.WebSpec{
+				BasicAuthUsers: []monitoringv1.WebBasicAuthUser{
+					{
+						Username: "admin",
+						PasswordHash: v1.SecretKeySelector{
+							LocalObjectReference: v1.LocalObjectReference{Name: "web-users"},
+							Key:                  "admin",
+						},
+					},
+				},
+			},
+		},
+	}, defaultTestConfig, nil, "")
+	require.NoError(t, err)
+
+	require.Contains(t, sset.Spec.Template.Spec.Containers[0].Args, "--http.config=/etc/thanos/web_config/web-config.yaml")
+
+	var found bool
+	for _, vol := range sset.Spec.Template.Spec.Volumes {
+		if vol.Name == "web-config" {
+			found = true
+			require.Equal(t, "thanos-ruler-test-web-config", vol.Secret.SecretName)
+		}
+	}
+	require.True(t, found, "web-config volume not found")
+}
@@ -0,0 +1,373 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webconfig generates the web configuration file of Prometheus,
+// Alertmanager and Thanos Ruler, which enables TLS and basic authentication
+// for their web server.
+// See https://prometheus.io/docs/prometheus/latest/configuration/https/
+package webconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"path"
+
+	"github.com/pkg/errors"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	yaml "gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// ConfigFile is the key of the web configuration file in the Secret.
+	ConfigFile = "web-config.yaml"
+
+	volumeName = "web-config"
+
+	certKey     = "tls.crt"
+	keyKey      = "tls.key"
+	clientCAKey = "client-ca.crt"
+)
+
+var curves = map[string]struct{}{
+	"CurveP256": {},
+	"CurveP384": {},
+	"CurveP521": {},
+	"X25519":    {},
+}
+
+// TLSEnabled returns whether the web server serves HTTPS.
+func TLSEnabled(spec *monitoringv1.WebSpec) bool {
+	return spec != nil && spec.TLSConfig != nil
+}
+
+// BasicAuthEnabled returns whether the web server requires basic
+// authentication.
+func BasicAuthEnabled(spec *monitoringv1.WebSpec) bool {
+	return spec != nil && len(spec.BasicAuthUsers) > 0
+}
+
+// ClientCertRequired returns whether the web server rejects the clients
+// without a certificate.
+func ClientCertRequired(spec *monitoringv1.WebSpec) bool {
+	if !TLSEnabled(spec) {
+		return false
+	}
+
+	switch spec.TLSConfig.ClientAuthType {
+	case "RequireAnyClientCert", "RequireAndVerifyClientCert":
+		return true
+	case "":
+		return spec.TLSConfig.ClientCA != nil
+	}
+	return false
+}
+
+// Scheme returns the URL scheme of the web server.
+func Scheme(spec *monitoringv1.WebSpec) string {
+	if TLSEnabled(spec) {
+		return "https"
+	}
+	return "http"
+}
+
+// ProbeHandler returns the handler of the probes checking the given path of
+// the web server. The probes can't authenticate, so they fall back to TCP
+// checks when basic authentication is enabled.
+func ProbeHandler(spec *monitoringv1.WebSpec, probePath string, port intstr.IntOrString) v1.Handler {
+	if BasicAuthEnabled(spec) {
+		return v1.Handler{
+			TCPSocket: &v1.TCPSocketAction{
+				Port: port,
+			},
+		}
+	}
+
+	handler := v1.Handler{
+		HTTPGet: &v1.HTTPGetAction{
+			Path: probePath,
+			Port: port,
+		},
+	}
+	if TLSEnabled(spec) {
+		handler.HTTPGet.Scheme = v1.URISchemeHTTPS
+	}
+
+	return handler
+}
+
+// Validate checks the web settings which can't be validated by the CRD
+// schema.
+func Validate(spec *monitoringv1.WebSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if tlsConfig := spec.TLSConfig; tlsConfig != nil {
+		if err := validateSecretOrConfigMap(tlsConfig.Cert); err != nil {
+			return errors.Wrap(err, "tlsConfig.cert")
+		}
+		if tlsConfig.KeySecret.Name == "" || tlsConfig.KeySecret.Key == "" {
+			return errors.New("tlsConfig.keySecret: name and key must be set")
+		}
+		if tlsConfig.ClientCA != nil {
+			if err := validateSecretOrConfigMap(*tlsConfig.ClientCA); err != nil {
+				return errors.Wrap(err, "tlsConfig.clientCA")
+			}
+		}
+		switch tlsConfig.ClientAuthType {
+		case "VerifyClientCertIfGiven", "RequireAndVerifyClientCert":
+			if tlsConfig.ClientCA == nil {
+				return errors.Errorf("tlsConfig.clientAuthType %s requires tlsConfig.clientCA", tlsConfig.ClientAuthType)
+			}
+		}
+
+		suites := map[string]struct{}{}
+		for _, s := range tls.CipherSuites() {
+			suites[s.Name] = struct{}{}
+		}
+		for _, s := range tlsConfig.CipherSuites {
+			if _, ok := suites[s]; !ok {
+				return errors.Errorf("tlsConfig.cipherSuites: unknown or insecure cipher suite %q", s)
+			}
+		}
+		for _, c := range tlsConfig.CurvePreferences {
+			if _, ok := curves[c]; !ok {
+				return errors.Errorf("tlsConfig.curvePreferences: unknown curve %q", c)
+			}
+		}
+	}
+
+	users := map[string]struct{}{}
+	for i, u := range spec.BasicAuthUsers {
+		if u.Username == "" {
+			return errors.Errorf("basicAuthUsers[%d]: username must be set", i)
+		}
+		if _, ok := users[u.Username]; ok {
+			return errors.Errorf("basicAuthUsers[%d]: duplicate username %q", i, u.Username)
+		}
+		users[u.Username] = struct{}{}
+	}
+
+	return nil
+}
+
+func validateSecretOrConfigMap(sel monitoringv1.SecretOrConfigMap) error {
+	if err := sel.Validate(); err != nil {
+		return err
+	}
+	if sel.Secret == nil && sel.ConfigMap == nil {
+		return errors.New("either configMap or secret must be set")
+	}
+	return nil
+}
+
+// MakeSecretData returns the data of the Secret holding the web
+// configuration file and the TLS assets it references. mountDir is the
+// directory where the Secret is mounted in the containers.
+func MakeSecretData(ctx context.Context, client corev1client.CoreV1Interface, namespace string, mountDir string, spec *monitoringv1.WebSpec) (map[string][]byte, error) {
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+
+	data := map[string][]byte{}
+	var cfg yaml.MapSlice
+
+	if tlsConfig := spec.TLSConfig; tlsConfig != nil {
+		type asset struct {
+			sel   monitoringv1.SecretOrConfigMap
+			key   string
+			field string
+		}
+		assets := []asset{
+			{tlsConfig.Cert, certKey, "tlsConfig.cert"},
+			{monitoringv1.SecretOrConfigMap{Secret: &tlsConfig.KeySecret}, keyKey, "tlsConfig.keySecret"},
+		}
+		if tlsConfig.ClientCA != nil {
+			assets = append(assets, asset{*tlsConfig.ClientCA, clientCAKey, "tlsConfig.clientCA"})
+		}
+		for _, a := range assets {
+			b, err := getKey(ctx, client, namespace, a.sel)
+			if err != nil {
+				return nil, errors.Wrap(err, a.field)
+			}
+			data[a.key] = b
+		}
+
+		cfg = append(cfg, yaml.MapItem{Key: "tls_server_config", Value: makeTLSServerConfig(mountDir, tlsConfig)})
+	}
+
+	if spec.HTTPConfig != nil && spec.HTTPConfig.HTTP2 != nil {
+		cfg = append(cfg, yaml.MapItem{
+			Key:   "http_server_config",
+			Value: yaml.MapSlice{{Key: "http2", Value: *spec.HTTPConfig.HTTP2}},
+		})
+	}
+
+	if len(spec.BasicAuthUsers) > 0 {
+		users := yaml.MapSlice{}
+		for i, u := range spec.BasicAuthUsers {
+			b, err := getKey(ctx, client, namespace, monitoringv1.SecretOrConfigMap{Secret: &u.PasswordHash})
+			if err != nil {
+				return nil, errors.Wrapf(err, "basicAuthUsers[%d]", i)
+			}
+			users = append(users, yaml.MapItem{Key: u.Username, Value: string(b)})
+		}
+		cfg = append(cfg, yaml.MapItem{Key: "basic_auth_users", Value: users})
+	}
+
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal web configuration")
+	}
+	data[ConfigFile] = b
+
+	return data, nil
+}
+
+func makeTLSServerConfig(mountDir string, tlsConfig *monitoringv1.WebTLSConfig) yaml.MapSlice {
+	cfg := yaml.MapSlice{
+		{Key: "cert_file", Value: path.Join(mountDir, certKey)},
+		{Key: "key_file", Value: path.Join(mountDir, keyKey)},
+	}
+
+	clientAuthType := tlsConfig.ClientAuthType
+	if tlsConfig.ClientCA != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "client_ca_file", Value: path.Join(mountDir, clientCAKey)})
+		if clientAuthType == "" {
+			clientAuthType = "RequireAndVerifyClientCert"
+		}
+	}
+	if clientAuthType != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "client_auth_type", Value: clientAuthType})
+	}
+
+	if tlsConfig.MinVersion != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "min_version", Value: tlsConfig.MinVersion})
+	}
+	if tlsConfig.MaxVersion != "" {
+		cfg = append(cfg, yaml.MapItem{Key: "max_version", Value: tlsConfig.MaxVersion})
+	}
+	if len(tlsConfig.CipherSuites) > 0 {
+		cfg = append(cfg, yaml.MapItem{Key: "cipher_suites", Value: tlsConfig.CipherSuites})
+	}
+	if tlsConfig.PreferServerCipherSuites != nil {
+		cfg = append(cfg, yaml.MapItem{Key: "prefer_server_cipher_suites", Value: *tlsConfig.PreferServerCipherSuites})
+	}
+	if len(tlsConfig.CurvePreferences) > 0 {
+		cfg = append(cfg, yaml.MapItem{Key: "curve_preferences", Value: tlsConfig.CurvePreferences})
+	}
+
+	return cfg
+}
+
+func getKey(ctx context.Context, client corev1client.CoreV1Interface, namespace string, sel monitoringv1.SecretOrConfigMap) ([]byte, error) {
+	if sel.Secret != nil {
+		s, err := client.Secrets(namespace).Get(ctx, sel.Secret.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get secret %q", sel.Secret.Name)
+		}
+		b, ok := s.Data[sel.Secret.Key]
+		if !ok {
+			return nil, errors.Errorf("key %q in secret %q not found", sel.Secret.Key, sel.Secret.Name)
+		}
+		return b, nil
+	}
+
+	cm, err := client.ConfigMaps(namespace).Get(ctx, sel.ConfigMap.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get configmap %q", sel.ConfigMap.Name)
+	}
+	s, ok := cm.Data[sel.ConfigMap.Key]
+	if !ok {
+		return nil, errors.Errorf("key %q in configmap %q not found", sel.ConfigMap.Key, sel.ConfigMap.Name)
+	}
+	return []byte(s), nil
+}
+
+// ClientTLSConfig returns the TLS configuration of the clients of the web
+// server, nil if TLS isn't enabled. The clients verify the server
+// certificate against the certificates of tlsConfig.cert, which holds the
+// server certificate and optionally its chain.
+func ClientTLSConfig(ctx context.Context, client corev1client.CoreV1Interface, namespace string, spec *monitoringv1.WebSpec) (*tls.Config, error) {
+	if !TLSEnabled(spec) {
+		return nil, nil
+	}
+
+	b, err := getKey(ctx, client, namespace, spec.TLSConfig.Cert)
+	if err != nil {
+		return nil, errors.Wrap(err, "tlsConfig.cert")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("tlsConfig.cert: no PEM certificate found")
+	}
+
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// References returns whether the web settings reference the given Secret or
+// ConfigMap. kind is either "Secret" or "ConfigMap".
+func References(spec *monitoringv1.WebSpec, kind, name string) bool {
+	if spec == nil {
+		return false
+	}
+
+	var sels []monitoringv1.SecretOrConfigMap
+	if tlsConfig := spec.TLSConfig; tlsConfig != nil {
+		sels = append(sels, tlsConfig.Cert, monitoringv1.SecretOrConfigMap{Secret: &tlsConfig.KeySecret})
+		if tlsConfig.ClientCA != nil {
+			sels = append(sels, *tlsConfig.ClientCA)
+		}
+	}
+	for i := range spec.BasicAuthUsers {
+		sels = append(sels, monitoringv1.SecretOrConfigMap{Secret: &spec.BasicAuthUsers[i].PasswordHash})
+	}
+
+	for _, sel := range sels {
+		switch {
+		case kind == "Secret" && sel.Secret != nil && sel.Secret.Name == name:
+			return true
+		case kind == "ConfigMap" && sel.ConfigMap != nil && sel.ConfigMap.Name == name:
+			return true
+		}
+	}
+
+	return false
+}
+
+// Volume returns the volume of the web configuration Secret and its mount
+// at mountDir.
+func Volume(secretName, mountDir string) (v1.Volume, v1.VolumeMount) {
+	vol := v1.Volume{
+		Name: volumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: secretName,
+			},
+		},
+	}
+	mount := v1.VolumeMount{
+		Name:      volumeName,
+		ReadOnly:  true,
+		MountPath: mountDir,
+	}
+
+	return vol, mount
+}
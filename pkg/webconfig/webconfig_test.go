@@ -0,0 +1,244 @@
+// Copyright 2020 The prometheus-operator Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconfig
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func secretKey(name, key string) v1.SecretKeySelector {
+	return v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: name},
+		Key:                  key,
+	}
+}
+
+func TestMakeSecretData(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "ns"},
+			Data: map[string][]byte{
+				"tls.crt": []byte("cert"),
+				"tls.key": []byte("key"),
+			},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-ca", Namespace: "ns"},
+			Data: map[string]string{
+				"ca.crt": "ca",
+			},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-users", Namespace: "ns"},
+			Data: map[string][]byte{
+				"admin": []byte("$2y$10$hash"),
+			},
+		},
+	)
+
+	boolFalse := false
+	caSel := monitoringv1.SecretOrConfigMap{
+		ConfigMap: &v1.ConfigMapKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "web-ca"},
+			Key:                  "ca.crt",
+		},
+	}
+	certSel := secretKey("web-tls", "tls.crt")
+	for _, tc := range []struct {
+		name     string
+		spec     *monitoringv1.WebSpec
+		expected map[string]string
+		err      bool
+	}{
+		{
+			name: "empty",
+			spec: &monitoringv1.WebSpec{},
+			expected: map[string]string{
+				ConfigFile: "{}\n",
+			},
+		},
+		{
+			name: "tls with client CA",
+			spec: &monitoringv1.WebSpec{
+				TLSConfig: &monitoringv1.WebTLSConfig{
+					Cert:         monitoringv1.SecretOrConfigMap{Secret: &certSel},
+					KeySecret:    secretKey("web-tls", "tls.key"),
+					ClientCA:     &caSel,
+					MinVersion:   "TLS12",
+					CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+				},
+				HTTPConfig: &monitoringv1.WebHTTPConfig{
+					HTTP2: &boolFalse,
+				},
+			},
+			expected: map[string]string{
+				"tls.crt":       "cert",
+				"tls.key":       "key",
+				"client-ca.crt": "ca",
+				ConfigFile: `tls_server_config:
+  cert_file: /etc/web/tls.crt
+  key_file: /etc/web/tls.key
+  client_ca_file: /etc/web/client-ca.crt
+  client_auth_type: RequireAndVerifyClientCert
+  min_version: TLS12
+  cipher_suites:
+  - TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+http_server_config:
+  http2: false
+`,
+			},
+		},
+		{
+			name: "basic auth",
+			spec: &monitoringv1.WebSpec{
+				BasicAuthUsers: []monitoringv1.WebBasicAuthUser{
+					{Username: "admin", PasswordHash: secretKey("web-users", "admin")},
+				},
+			},
+			expected: map[string]string{
+				ConfigFile: `basic_auth_users:
+  admin: $2y$10$hash
+`,
+			},
+		},
+		{
+			name: "missing key",
+			spec: &monitoringv1.WebSpec{
+				BasicAuthUsers: []monitoringv1.WebBasicAuthUser{
+					{Username: "admin", PasswordHash: secretKey("web-users", "missing")},
+				},
+			},
+			err: true,
+		},
+		{
+			name: "client auth type without client CA",
+			spec: &monitoringv1.WebSpec{
+				TLSConfig: &monitoringv1.WebTLSConfig{
+					Cert:           monitoringv1.SecretOrConfigMap{Secret: &certSel},
+					KeySecret:      secretKey("web-tls", "tls.key"),
+					ClientAuthType: "RequireAndVerifyClientCert",
+				},
+			},
+			err: true,
+		},
+		{
+			name: "unknown cipher suite",
+			spec: &monitoringv1.WebSpec{
+				TLSConfig: &monitoringv1.WebTLSConfig{
+					Cert:         monitoringv1.SecretOrConfigMap{Secret: &certSel},
+					KeySecret:    secretKey("web-tls", "tls.key"),
+					CipherSuites: []string{"TLS_FOO"},
+				},
+			},
+			err: true,
+		},
+		{
+			name: "duplicate user",
+			spec: &monitoringv1.WebSpec{
+				BasicAuthUsers: []monitoringv1.WebBasicAuthUser{
+					{Username: "admin", PasswordHash: secretKey("web-users", "admin")},
+					{Username: "admin", PasswordHash: secretKey("web-users", "admin")},
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := MakeSecretData(context.Background(), client.CoreV1(), "ns", "/etc/web", tc.spec)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got := make(map[string]string, len(data))
+			for k, v := range data {
+				got[k] = string(v)
+			}
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	certSel := secretKey("web-tls", "tls.crt")
+	spec := &monitoringv1.WebSpec{
+		TLSConfig: &monitoringv1.WebTLSConfig{
+			Cert:      monitoringv1.SecretOrConfigMap{Secret: &certSel},
+			KeySecret: secretKey("web-key", "tls.key"),
+			ClientCA: &monitoringv1.SecretOrConfigMap{
+				ConfigMap: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "web-ca"},
+					Key:                  "ca.crt",
+				},
+			},
+		},
+		BasicAuthUsers: []monitoringv1.WebBasicAuthUser{
+			{Username: "admin", PasswordHash: secretKey("web-users", "admin")},
+		},
+	}
+
+	for _, name := range []string{"web-tls", "web-key", "web-users"} {
+		require.True(t, References(spec, "Secret", name), name)
+	}
+	require.True(t, References(spec, "ConfigMap", "web-ca"))
+	require.False(t, References(spec, "Secret", "web-ca"))
+	require.False(t, References(spec, "ConfigMap", "web-tls"))
+	require.False(t, References(nil, "Secret", "web-tls"))
+}
+
+func TestClientCertRequired(t *testing.T) {
+	ca := &monitoringv1.SecretOrConfigMap{ConfigMap: &v1.ConfigMapKeySelector{Key: "ca.crt"}}
+
+	for _, tc := range []struct {
+		spec     *monitoringv1.WebSpec
+		expected bool
+	}{
+		{spec: nil},
+		{spec: &monitoringv1.WebSpec{}},
+		{spec: &monitoringv1.WebSpec{TLSConfig: &monitoringv1.WebTLSConfig{}}},
+		{spec: &monitoringv1.WebSpec{TLSConfig: &monitoringv1.WebTLSConfig{ClientCA: ca}}, expected: true},
+		{spec: &monitoringv1.WebSpec{TLSConfig: &monitoringv1.WebTLSConfig{ClientCA: ca, ClientAuthType: "VerifyClientCertIfGiven"}}},
+		{spec: &monitoringv1.WebSpec{TLSConfig: &monitoringv1.WebTLSConfig{ClientAuthType: "RequireAnyClientCert"}}, expected: true},
+	} {
+		require.Equal(t, tc.expected, ClientCertRequired(tc.spec))
+	}
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+		Data:       map[string][]byte{"tls.crt": []byte("not a certificate")},
+	}).CoreV1()
+
+	cfg, err := ClientTLSConfig(context.Background(), client, "default", &monitoringv1.WebSpec{})
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+
+	certSel := secretKey("web-tls", "tls.crt")
+	_, err = ClientTLSConfig(context.Background(), client, "default", &monitoringv1.WebSpec{
+		TLSConfig: &monitoringv1.WebTLSConfig{
+			Cert: monitoringv1.SecretOrConfigMap{Secret: &certSel},
+		},
+	})
+	require.Error(t, err)
+}
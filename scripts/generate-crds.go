@@ -48,9 +48,11 @@ var (
 
 	crdNames = []crdName{
 		{"alertmanager", "alertmanagers"},
+		{"nodemonitor", "nodemonitors"},
 		{"podmonitor", "podmonitors"},
 		{"probe", "probes"},
 		{"prometheus", "prometheuses"},
+		{"prometheusagent", "prometheusagents"},
 		{"prometheusrule", "prometheusrules"},
 		{"servicemonitor", "servicemonitors"},
 		{"thanosruler", "thanosrulers"},
@@ -1492,7 +1492,7 @@ func testPromRulesExceedingConfigMapLimit(t *testing.T) {
 	}
 
 	for i := range prometheusRules {
-		_, err := framework.WaitForConfigMapExist(ns, "prometheus-"+p.Name+"-rulefiles-"+strconv.Itoa(i))
+		_, err := framework.WaitForConfigMapExist(ns, prometheus.RuleConfigMapName(p.Name, i))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1511,11 +1511,11 @@ func testPromRulesExceedingConfigMapLimit(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = framework.WaitForConfigMapExist(ns, "prometheus-"+p.Name+"-rulefiles-0")
+	_, err = framework.WaitForConfigMapExist(ns, prometheus.RuleConfigMapName(p.Name, 0))
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = framework.WaitForConfigMapNotExist(ns, "prometheus-"+p.Name+"-rulefiles-1")
+	err = framework.WaitForConfigMapEmpty(ns, prometheus.RuleConfigMapName(p.Name, 1))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1606,6 +1606,7 @@ func testPromOnlyUpdatedOnRelevantChanges(t *testing.T) {
 	testCTX.SetupPrometheusRBAC(t, ns, framework.KubeClient)
 
 	name := "test"
+	rulesConfigMapName := prometheus.RuleConfigMapName(name, 0)
 	prometheus := framework.MakeBasicPrometheus(ns, name, name, 1)
 
 	// Adding an annotation to Prometheus lead to high CPU usage in the past
@@ -1643,7 +1644,7 @@ func testPromOnlyUpdatedOnRelevantChanges(t *testing.T) {
 					KubeClient.
 					CoreV1().
 					ConfigMaps(ns).
-					Get(context.TODO(), "prometheus-"+prometheusName+"-rulefiles-0", metav1.GetOptions{})
+					Get(context.TODO(), rulesConfigMapName, metav1.GetOptions{})
 			},
 			// The Prometheus Operator first creates the ConfigMap for the
 			// given Prometheus stateful set and then updates it with the matching
@@ -1813,7 +1814,7 @@ func testPromWhenDeleteCRDCleanUpViaOwnerRef(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	configMapName := fmt.Sprintf("prometheus-%v-rulefiles-0", p.Name)
+	configMapName := prometheus.RuleConfigMapName(p.Name, 0)
 
 	_, err = framework.WaitForConfigMapExist(ns, configMapName)
 	if err != nil {
@@ -35,8 +35,10 @@ var (
 		Resources: []string{"customresourcedefinitions"},
 		ResourceNames: []string{
 			"alertmanagers.monitoring.coreos.com",
+			"nodemonitors.monitoring.coreos.com",
 			"podmonitors.monitoring.coreos.com",
 			"probes.monitoring.coreos.com",
+			"prometheusagents.monitoring.coreos.com",
 			"prometheuses.monitoring.coreos.com",
 			"prometheusrules.monitoring.coreos.com",
 			"servicemonitors.monitoring.coreos.com",
@@ -92,3 +92,20 @@ func (f *Framework) WaitForConfigMapNotExist(ns, name string) error {
 
 	return errors.Wrapf(err, "waiting for ConfigMap '%v' in namespace '%v' to not exist", name, ns)
 }
+
+func (f *Framework) WaitForConfigMapEmpty(ns, name string) error {
+	err := wait.Poll(2*time.Second, f.DefaultTimeout, func() (bool, error) {
+		configMap, err := f.
+			KubeClient.
+			CoreV1().
+			ConfigMaps(ns).
+			Get(context.TODO(), name, metav1.GetOptions{})
+
+		if err != nil {
+			return false, err
+		}
+		return len(configMap.Data) == 0, nil
+	})
+
+	return errors.Wrapf(err, "waiting for ConfigMap '%v' in namespace '%v' to be empty", name, ns)
+}
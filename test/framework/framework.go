@@ -226,6 +226,20 @@ func (f *Framework) CreatePrometheusOperator(ns, opImage string, namespaceAllowl
 		return nil, errors.Wrap(err, "initialize PodMonitor CRD")
 	}
 
+	err = f.CreateCRDAndWaitUntilReady(monitoringv1.NodeMonitorName, func(opts metav1.ListOptions) (runtime.Object, error) {
+		return f.MonClientV1.NodeMonitors(v1.NamespaceAll).List(context.TODO(), opts)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "initialize NodeMonitor CRD")
+	}
+
+	err = f.CreateCRDAndWaitUntilReady(monitoringv1.PrometheusAgentName, func(opts metav1.ListOptions) (runtime.Object, error) {
+		return f.MonClientV1.PrometheusAgents(v1.NamespaceAll).List(context.TODO(), opts)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "initialize PrometheusAgent CRD")
+	}
+
 	err = f.CreateCRDAndWaitUntilReady(monitoringv1.ProbeName, func(opts metav1.ListOptions) (object runtime.Object, err error) {
 		return f.MonClientV1.Probes(v1.NamespaceAll).List(context.TODO(), opts)
 	})